@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE employees (
+                           id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                           company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+                           external_id VARCHAR(100),
+                           email VARCHAR(255) NOT NULL,
+                           name VARCHAR(255) NOT NULL,
+                           department VARCHAR(255),
+                           cost_center VARCHAR(100),
+                           manager_id UUID REFERENCES employees(id) ON DELETE SET NULL,
+                           status VARCHAR(50) NOT NULL DEFAULT 'active',
+                           created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                           updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE employees ADD CONSTRAINT chk_employee_status CHECK (status IN ('active', 'inactive'));
+ALTER TABLE employees ADD CONSTRAINT uq_employees_company_email UNIQUE (company_id, email);
+
+CREATE UNIQUE INDEX idx_employees_company_external_id ON employees(company_id, external_id) WHERE external_id IS NOT NULL;
+CREATE INDEX idx_employees_company_id ON employees(company_id);
+CREATE INDEX idx_employees_manager_id ON employees(manager_id);
+CREATE INDEX idx_employees_status ON employees(status);
+
+CREATE TRIGGER update_employees_updated_at BEFORE UPDATE ON employees
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE cards ADD COLUMN employee_ref_id UUID REFERENCES employees(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_cards_employee_ref_id ON cards(employee_ref_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_cards_employee_ref_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS employee_ref_id;
+DROP TRIGGER IF EXISTS update_employees_updated_at ON employees;
+DROP TABLE IF EXISTS employees;
+-- +goose StatementEnd
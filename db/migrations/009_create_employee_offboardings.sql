@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE companies ADD COLUMN balance DECIMAL(15, 2) NOT NULL DEFAULT 0.00;
+
+ALTER TABLE employees DROP CONSTRAINT chk_employee_status;
+ALTER TABLE employees ADD CONSTRAINT chk_employee_status CHECK (status IN ('active', 'inactive', 'offboarded'));
+
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep'));
+ALTER TABLE transactions DROP CONSTRAINT chk_status;
+ALTER TABLE transactions ADD CONSTRAINT chk_status CHECK (status IN ('pending', 'completed', 'failed', 'voided'));
+
+CREATE TABLE employee_offboardings (
+                                       id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                       company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+                                       employee_id UUID NOT NULL UNIQUE REFERENCES employees(id) ON DELETE CASCADE,
+                                       card_action VARCHAR(50) NOT NULL,
+                                       reason TEXT,
+                                       status VARCHAR(50) NOT NULL DEFAULT 'in_progress',
+                                       last_error TEXT,
+                                       started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                                       completed_at TIMESTAMP WITH TIME ZONE,
+                                       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                                       updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE employee_offboardings ADD CONSTRAINT chk_offboarding_card_action CHECK (card_action IN ('block', 'cancel'));
+ALTER TABLE employee_offboardings ADD CONSTRAINT chk_offboarding_status CHECK (status IN ('in_progress', 'completed', 'failed'));
+
+CREATE INDEX idx_employee_offboardings_company_id ON employee_offboardings(company_id);
+CREATE INDEX idx_employee_offboardings_status ON employee_offboardings(status);
+
+CREATE TRIGGER update_employee_offboardings_updated_at BEFORE UPDATE ON employee_offboardings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- One row per card handled by an offboarding. It is written in the same
+-- transaction as the card changes, so a retried offboarding skips cards that
+-- were already processed.
+CREATE TABLE employee_offboarding_cards (
+                                            offboarding_id UUID NOT NULL REFERENCES employee_offboardings(id) ON DELETE CASCADE,
+                                            card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+                                            last_four VARCHAR(4) NOT NULL,
+                                            previous_status VARCHAR(50) NOT NULL,
+                                            new_status VARCHAR(50) NOT NULL,
+                                            balance_returned DECIMAL(15, 2) NOT NULL DEFAULT 0.00,
+                                            holds_voided INTEGER NOT NULL DEFAULT 0,
+                                            controls_revoked INTEGER NOT NULL DEFAULT 0,
+                                            processed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                                            PRIMARY KEY (offboarding_id, card_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS employee_offboarding_cards;
+DROP TRIGGER IF EXISTS update_employee_offboardings_updated_at ON employee_offboardings;
+DROP TABLE IF EXISTS employee_offboardings;
+
+ALTER TABLE transactions DROP CONSTRAINT chk_status;
+ALTER TABLE transactions ADD CONSTRAINT chk_status CHECK (status IN ('pending', 'completed', 'failed'));
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge'));
+
+ALTER TABLE employees DROP CONSTRAINT chk_employee_status;
+ALTER TABLE employees ADD CONSTRAINT chk_employee_status CHECK (status IN ('active', 'inactive'));
+
+ALTER TABLE companies DROP COLUMN IF EXISTS balance;
+-- +goose StatementEnd
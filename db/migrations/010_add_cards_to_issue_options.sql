@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cards_to_issue
+    ADD COLUMN card_holder_name VARCHAR(255),
+    ADD COLUMN card_type VARCHAR(50),
+    ADD COLUMN spending_limit DECIMAL(15, 2),
+    ADD COLUMN daily_limit DECIMAL(15, 2),
+    ADD COLUMN monthly_limit DECIMAL(15, 2),
+    ADD COLUMN department VARCHAR(255),
+    ADD COLUMN initial_funding DECIMAL(15, 2);
+
+ALTER TABLE cards_to_issue ADD CONSTRAINT chk_cards_to_issue_card_type CHECK (card_type IS NULL OR card_type IN ('virtual', 'physical'));
+ALTER TABLE cards_to_issue ADD CONSTRAINT chk_cards_to_issue_initial_funding CHECK (initial_funding IS NULL OR initial_funding >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE cards_to_issue DROP CONSTRAINT IF EXISTS chk_cards_to_issue_initial_funding;
+ALTER TABLE cards_to_issue DROP CONSTRAINT IF EXISTS chk_cards_to_issue_card_type;
+ALTER TABLE cards_to_issue
+    DROP COLUMN IF EXISTS initial_funding,
+    DROP COLUMN IF EXISTS department,
+    DROP COLUMN IF EXISTS monthly_limit,
+    DROP COLUMN IF EXISTS daily_limit,
+    DROP COLUMN IF EXISTS spending_limit,
+    DROP COLUMN IF EXISTS card_type,
+    DROP COLUMN IF EXISTS card_holder_name;
+-- +goose StatementEnd
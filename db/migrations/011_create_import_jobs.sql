@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE import_jobs (
+                             id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                             company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+                             file_name VARCHAR(255) NOT NULL,
+                             file_path TEXT NOT NULL,
+                             file_size BIGINT NOT NULL DEFAULT 0,
+                             dry_run BOOLEAN NOT NULL DEFAULT false,
+                             status VARCHAR(50) NOT NULL DEFAULT 'queued',
+                             bytes_processed BIGINT NOT NULL DEFAULT 0,
+                             last_line INTEGER NOT NULL DEFAULT 0,
+                             total_rows INTEGER NOT NULL DEFAULT 0,
+                             accepted INTEGER NOT NULL DEFAULT 0,
+                             rejected INTEGER NOT NULL DEFAULT 0,
+                             duplicates INTEGER NOT NULL DEFAULT 0,
+                             error TEXT,
+                             started_at TIMESTAMP WITH TIME ZONE,
+                             completed_at TIMESTAMP WITH TIME ZONE,
+                             created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                             updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE import_jobs ADD CONSTRAINT chk_import_job_status CHECK (status IN ('queued', 'running', 'completed', 'failed'));
+
+CREATE INDEX idx_import_jobs_company_id ON import_jobs(company_id);
+CREATE INDEX idx_import_jobs_status ON import_jobs(status);
+
+CREATE TRIGGER update_import_jobs_updated_at BEFORE UPDATE ON import_jobs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE import_job_rows (
+                                 job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+                                 line INTEGER NOT NULL,
+                                 employee_id VARCHAR(255),
+                                 employee_email VARCHAR(255),
+                                 status VARCHAR(50) NOT NULL,
+                                 reasons TEXT[] NOT NULL DEFAULT '{}',
+                                 created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                                 PRIMARY KEY (job_id, line)
+);
+
+ALTER TABLE import_job_rows ADD CONSTRAINT chk_import_job_row_status CHECK (status IN ('rejected', 'duplicate'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS import_job_rows;
+DROP TRIGGER IF EXISTS update_import_jobs_updated_at ON import_jobs;
+DROP TABLE IF EXISTS import_jobs;
+-- +goose StatementEnd
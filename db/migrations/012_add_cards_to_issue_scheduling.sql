@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cards_to_issue DROP CONSTRAINT chk_cards_to_issue_status;
+ALTER TABLE cards_to_issue ADD CONSTRAINT chk_cards_to_issue_status CHECK (status IN ('pending', 'generated', 'cancelled'));
+
+ALTER TABLE cards_to_issue
+    ADD COLUMN scheduled_for TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN policy JSONB;
+
+CREATE INDEX idx_cards_to_issue_scheduled_for ON cards_to_issue(scheduled_for)
+    WHERE status = 'pending' AND scheduled_for IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_cards_to_issue_scheduled_for;
+ALTER TABLE cards_to_issue
+    DROP COLUMN IF EXISTS policy,
+    DROP COLUMN IF EXISTS scheduled_for;
+
+UPDATE cards_to_issue SET status = 'pending' WHERE status = 'cancelled';
+ALTER TABLE cards_to_issue DROP CONSTRAINT chk_cards_to_issue_status;
+ALTER TABLE cards_to_issue ADD CONSTRAINT chk_cards_to_issue_status CHECK (status IN ('pending', 'generated'));
+-- +goose StatementEnd
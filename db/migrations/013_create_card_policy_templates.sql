@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE card_policy_templates (
+                                       id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                       company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+                                       name VARCHAR(100) NOT NULL,
+                                       description TEXT,
+                                       card_type VARCHAR(50),
+                                       spending_limit DECIMAL(15, 2),
+                                       daily_limit DECIMAL(15, 2),
+                                       monthly_limit DECIMAL(15, 2),
+                                       controls JSONB NOT NULL DEFAULT '[]',
+                                       is_default BOOLEAN NOT NULL DEFAULT false,
+                                       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+                                       updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE card_policy_templates ADD CONSTRAINT chk_policy_template_card_type CHECK (card_type IN ('virtual', 'physical'));
+ALTER TABLE card_policy_templates ADD CONSTRAINT chk_policy_template_spending_limit CHECK (spending_limit > 0);
+ALTER TABLE card_policy_templates ADD CONSTRAINT chk_policy_template_daily_limit CHECK (daily_limit > 0);
+ALTER TABLE card_policy_templates ADD CONSTRAINT chk_policy_template_monthly_limit CHECK (monthly_limit > 0);
+ALTER TABLE card_policy_templates ADD CONSTRAINT uq_policy_templates_company_name UNIQUE (company_id, name);
+
+-- At most one default template per company.
+CREATE UNIQUE INDEX idx_policy_templates_company_default ON card_policy_templates(company_id) WHERE is_default;
+
+CREATE TRIGGER update_card_policy_templates_updated_at BEFORE UPDATE ON card_policy_templates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE cards ADD COLUMN policy_template_id UUID REFERENCES card_policy_templates(id) ON DELETE SET NULL;
+ALTER TABLE spending_controls ADD COLUMN policy_template_id UUID REFERENCES card_policy_templates(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_cards_policy_template_id ON cards(policy_template_id);
+CREATE INDEX idx_spending_controls_policy_template_id ON spending_controls(policy_template_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_spending_controls_policy_template_id;
+DROP INDEX IF EXISTS idx_cards_policy_template_id;
+ALTER TABLE spending_controls DROP COLUMN IF EXISTS policy_template_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS policy_template_id;
+DROP TRIGGER IF EXISTS update_card_policy_templates_updated_at ON card_policy_templates;
+DROP TABLE IF EXISTS card_policy_templates;
+-- +goose StatementEnd
@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cards ADD COLUMN token VARCHAR(64);
+UPDATE cards SET token = 'tok_' || replace(gen_random_uuid()::text, '-', '');
+ALTER TABLE cards ALTER COLUMN token SET NOT NULL;
+ALTER TABLE cards ADD CONSTRAINT uq_cards_token UNIQUE (token);
+
+ALTER TABLE cards ADD COLUMN secret_key_id VARCHAR(100);
+ALTER TABLE cards ADD COLUMN secret_data_key BYTEA;
+ALTER TABLE cards ADD COLUMN secret_ciphertext BYTEA;
+ALTER TABLE cards ADD COLUMN pan_fingerprint VARCHAR(64);
+ALTER TABLE cards ADD CONSTRAINT uq_cards_pan_fingerprint UNIQUE (pan_fingerprint);
+
+-- Plaintext card numbers are encrypted and cleared by the application at
+-- startup, so the column only holds rows that have not been migrated yet.
+DROP INDEX IF EXISTS idx_cards_card_number;
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS cards_card_number_key;
+ALTER TABLE cards ALTER COLUMN card_number DROP NOT NULL;
+CREATE INDEX idx_cards_card_number_pending ON cards(id) WHERE card_number IS NOT NULL;
+
+CREATE TABLE card_reveals (
+                              id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                              card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+                              company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+                              client_ip VARCHAR(64),
+                              user_agent TEXT,
+                              created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_card_reveals_card_id ON card_reveals(card_id);
+CREATE INDEX idx_card_reveals_company_id ON card_reveals(company_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Encrypted card numbers cannot be restored to plaintext, so card_number is
+-- left nullable.
+DROP TABLE IF EXISTS card_reveals;
+DROP INDEX IF EXISTS idx_cards_card_number_pending;
+CREATE INDEX IF NOT EXISTS idx_cards_card_number ON cards(card_number);
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS uq_cards_pan_fingerprint;
+ALTER TABLE cards DROP COLUMN IF EXISTS pan_fingerprint;
+ALTER TABLE cards DROP COLUMN IF EXISTS secret_ciphertext;
+ALTER TABLE cards DROP COLUMN IF EXISTS secret_data_key;
+ALTER TABLE cards DROP COLUMN IF EXISTS secret_key_id;
+ALTER TABLE cards DROP CONSTRAINT IF EXISTS uq_cards_token;
+ALTER TABLE cards DROP COLUMN IF EXISTS token;
+-- +goose StatementEnd
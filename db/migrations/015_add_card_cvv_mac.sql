@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- cvv_hash held an unsalted SHA-256 of the CVV. CVVs are now stored as a keyed
+-- MAC; cards without one get their CVV from the encrypted card data, or a new
+-- CVV, when the application starts.
+ALTER TABLE cards ADD COLUMN cvv_mac VARCHAR(64);
+ALTER TABLE cards ADD COLUMN cvv_failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cards DROP COLUMN cvv_hash;
+
+CREATE INDEX idx_cards_cvv_mac_pending ON cards(id) WHERE cvv_mac IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_cards_cvv_mac_pending;
+ALTER TABLE cards ADD COLUMN cvv_hash VARCHAR(255);
+ALTER TABLE cards DROP COLUMN IF EXISTS cvv_failed_attempts;
+ALTER TABLE cards DROP COLUMN IF EXISTS cvv_mac;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cards ADD COLUMN pin_hash VARCHAR(64);
+ALTER TABLE cards ADD COLUMN pin_failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE cards ADD COLUMN pin_locked_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE cards DROP COLUMN IF EXISTS pin_locked_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS pin_failed_attempts;
+ALTER TABLE cards DROP COLUMN IF EXISTS pin_hash;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions ADD COLUMN channel VARCHAR(20) NOT NULL DEFAULT 'online';
+ALTER TABLE transactions ADD COLUMN terminal_id VARCHAR(64);
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_channel CHECK (channel IN ('online', 'chip', 'contactless', 'atm'));
+
+CREATE INDEX idx_transactions_channel ON transactions(channel);
+
+ALTER TABLE spending_controls DROP CONSTRAINT chk_control_type;
+ALTER TABLE spending_controls ADD CONSTRAINT chk_control_type CHECK (control_type IN ('merchant_category', 'merchant_name', 'time_based', 'location', 'channel'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM spending_controls WHERE control_type = 'channel';
+ALTER TABLE spending_controls DROP CONSTRAINT chk_control_type;
+ALTER TABLE spending_controls ADD CONSTRAINT chk_control_type CHECK (control_type IN ('merchant_category', 'merchant_name', 'time_based', 'location'));
+
+DROP INDEX IF EXISTS idx_transactions_channel;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transaction_channel;
+ALTER TABLE transactions DROP COLUMN IF EXISTS terminal_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS channel;
+-- +goose StatementEnd
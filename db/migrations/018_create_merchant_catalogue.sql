@@ -0,0 +1,147 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Merchant category codes (ISO 18245) grouped into the categories used by
+-- spending controls. The table is reference data and is seeded here.
+CREATE TABLE merchant_category_codes (
+                                         mcc VARCHAR(4) PRIMARY KEY,
+                                         description VARCHAR(255) NOT NULL,
+                                         category VARCHAR(100) NOT NULL
+);
+
+ALTER TABLE merchant_category_codes ADD CONSTRAINT chk_mcc_format CHECK (mcc ~ '^[0-9]{4}$');
+
+CREATE INDEX idx_merchant_category_codes_category ON merchant_category_codes(category);
+
+INSERT INTO merchant_category_codes (mcc, description, category)
+SELECT lpad(code::text, 4, '0'), 'Airlines, air carriers', 'travel' FROM generate_series(3000, 3299) AS code;
+
+INSERT INTO merchant_category_codes (mcc, description, category)
+SELECT lpad(code::text, 4, '0'), 'Car rental agencies', 'travel' FROM generate_series(3351, 3441) AS code;
+
+INSERT INTO merchant_category_codes (mcc, description, category)
+SELECT lpad(code::text, 4, '0'), 'Hotels, motels, resorts', 'travel' FROM generate_series(3501, 3999) AS code;
+
+INSERT INTO merchant_category_codes (mcc, description, category) VALUES
+    ('4111', 'Local and suburban commuter passenger transportation', 'transport'),
+    ('4112', 'Passenger railways', 'transport'),
+    ('4121', 'Taxicabs and limousines', 'transport'),
+    ('4131', 'Bus lines', 'transport'),
+    ('4784', 'Tolls and bridge fees', 'transport'),
+    ('7523', 'Parking lots and garages', 'transport'),
+    ('4411', 'Cruise lines', 'travel'),
+    ('4511', 'Airlines and air carriers', 'travel'),
+    ('4582', 'Airports, flying fields and terminals', 'travel'),
+    ('4722', 'Travel agencies and tour operators', 'travel'),
+    ('7011', 'Hotels, motels and resorts', 'travel'),
+    ('7512', 'Automobile rental agency', 'travel'),
+    ('5541', 'Service stations', 'fuel'),
+    ('5542', 'Automated fuel dispensers', 'fuel'),
+    ('5983', 'Fuel dealers', 'fuel'),
+    ('5811', 'Caterers', 'food'),
+    ('5812', 'Eating places and restaurants', 'food'),
+    ('5813', 'Drinking places, bars and taverns', 'food'),
+    ('5814', 'Fast food restaurants', 'food'),
+    ('5411', 'Grocery stores and supermarkets', 'groceries'),
+    ('5422', 'Freezer and locker meat provisioners', 'groceries'),
+    ('5441', 'Candy, nut and confectionery stores', 'groceries'),
+    ('5451', 'Dairy products stores', 'groceries'),
+    ('5462', 'Bakeries', 'groceries'),
+    ('5499', 'Miscellaneous food stores', 'groceries'),
+    ('5200', 'Home supply warehouse stores', 'retail'),
+    ('5251', 'Hardware stores', 'retail'),
+    ('5311', 'Department stores', 'retail'),
+    ('5331', 'Variety stores', 'retail'),
+    ('5399', 'Miscellaneous general merchandise', 'retail'),
+    ('5611', 'Men''s and boys'' clothing stores', 'retail'),
+    ('5621', 'Women''s ready-to-wear stores', 'retail'),
+    ('5651', 'Family clothing stores', 'retail'),
+    ('5661', 'Shoe stores', 'retail'),
+    ('5691', 'Men''s and women''s clothing stores', 'retail'),
+    ('5699', 'Miscellaneous apparel and accessory shops', 'retail'),
+    ('5712', 'Furniture and home furnishings stores', 'retail'),
+    ('5722', 'Household appliance stores', 'retail'),
+    ('5732', 'Electronics stores', 'retail'),
+    ('5734', 'Computer software stores', 'retail'),
+    ('5942', 'Book stores', 'retail'),
+    ('5943', 'Stationery and office supply stores', 'retail'),
+    ('5944', 'Jewelry, watch and clock stores', 'retail'),
+    ('5945', 'Hobby, toy and game shops', 'retail'),
+    ('5999', 'Miscellaneous and specialty retail stores', 'retail'),
+    ('5815', 'Digital goods: books, movies, music', 'entertainment'),
+    ('5816', 'Digital goods: games', 'entertainment'),
+    ('5817', 'Digital goods: applications', 'entertainment'),
+    ('5818', 'Digital goods: large digital goods merchant', 'entertainment'),
+    ('7832', 'Motion picture theaters', 'entertainment'),
+    ('7922', 'Theatrical producers and ticket agencies', 'entertainment'),
+    ('7929', 'Bands, orchestras and entertainers', 'entertainment'),
+    ('7991', 'Tourist attractions and exhibits', 'entertainment'),
+    ('7996', 'Amusement parks, carnivals and circuses', 'entertainment'),
+    ('7997', 'Membership clubs and country clubs', 'entertainment'),
+    ('7999', 'Recreation services', 'entertainment'),
+    ('7800', 'Government-owned lotteries', 'gambling'),
+    ('7801', 'Government-licensed online casinos', 'gambling'),
+    ('7802', 'Government-licensed horse and dog racing', 'gambling'),
+    ('7995', 'Betting, including lottery tickets and casino chips', 'gambling'),
+    ('4829', 'Wire transfers and money orders', 'cash'),
+    ('6010', 'Manual cash disbursements', 'cash'),
+    ('6011', 'Automated cash disbursements', 'cash'),
+    ('6051', 'Quasi cash: foreign currency, money orders, travelers cheques', 'cash'),
+    ('6540', 'Stored value card purchase and load', 'cash'),
+    ('4814', 'Telecommunication services', 'services'),
+    ('4816', 'Computer network and information services', 'services'),
+    ('4899', 'Cable, satellite and other pay television', 'services'),
+    ('7311', 'Advertising services', 'services'),
+    ('7333', 'Commercial photography, art and graphics', 'services'),
+    ('7349', 'Cleaning and maintenance services', 'services'),
+    ('7372', 'Computer programming and data processing', 'services'),
+    ('7392', 'Management, consulting and public relations', 'services'),
+    ('7399', 'Business services', 'services'),
+    ('8111', 'Legal services and attorneys', 'services'),
+    ('8931', 'Accounting, auditing and bookkeeping', 'services'),
+    ('4900', 'Utilities: electric, gas, water and sanitary', 'utilities'),
+    ('5912', 'Drug stores and pharmacies', 'health'),
+    ('8011', 'Doctors and physicians', 'health'),
+    ('8021', 'Dentists and orthodontists', 'health'),
+    ('8062', 'Hospitals', 'health'),
+    ('8099', 'Medical services and health practitioners', 'health'),
+    ('8211', 'Elementary and secondary schools', 'education'),
+    ('8220', 'Colleges, universities and professional schools', 'education'),
+    ('8241', 'Correspondence schools', 'education'),
+    ('8244', 'Business and secretarial schools', 'education'),
+    ('8299', 'Schools and educational services', 'education'),
+    ('9211', 'Court costs, including alimony and child support', 'government'),
+    ('9222', 'Fines', 'government'),
+    ('9311', 'Tax payments', 'government'),
+    ('9399', 'Government services', 'government'),
+    ('9402', 'Postal services', 'government');
+
+CREATE TABLE merchants (
+                           id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                           name VARCHAR(255) NOT NULL,
+                           mcc VARCHAR(4) NOT NULL REFERENCES merchant_category_codes(mcc),
+                           created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX uq_merchants_name ON merchants(lower(name));
+CREATE INDEX idx_merchants_mcc ON merchants(mcc);
+
+ALTER TABLE transactions ADD COLUMN mcc VARCHAR(4);
+ALTER TABLE transactions ADD COLUMN merchant_id UUID REFERENCES merchants(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_transactions_mcc ON transactions(mcc);
+CREATE INDEX idx_transactions_merchant_id ON transactions(merchant_id);
+
+-- Free-text categories are compared case-insensitively, so store them in
+-- their normalized form.
+UPDATE transactions SET merchant_category = lower(trim(merchant_category)) WHERE merchant_category IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_merchant_id;
+DROP INDEX IF EXISTS idx_transactions_mcc;
+ALTER TABLE transactions DROP COLUMN IF EXISTS merchant_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS mcc;
+DROP TABLE IF EXISTS merchants;
+DROP TABLE IF EXISTS merchant_category_codes;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE spending_controls DROP CONSTRAINT chk_control_type;
+ALTER TABLE spending_controls ADD CONSTRAINT chk_control_type CHECK (control_type IN ('merchant_category', 'merchant_name', 'time_based', 'location', 'channel', 'category_limit'));
+
+CREATE INDEX idx_transactions_card_category_created_at ON transactions(card_id, merchant_category, created_at);
+CREATE INDEX idx_transactions_card_merchant_created_at ON transactions(card_id, merchant_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_card_merchant_created_at;
+DROP INDEX IF EXISTS idx_transactions_card_category_created_at;
+
+DELETE FROM spending_controls WHERE control_type = 'category_limit';
+ALTER TABLE spending_controls DROP CONSTRAINT chk_control_type;
+ALTER TABLE spending_controls ADD CONSTRAINT chk_control_type CHECK (control_type IN ('merchant_category', 'merchant_name', 'time_based', 'location', 'channel'));
+-- +goose StatementEnd
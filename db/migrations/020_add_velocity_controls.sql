@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE spending_controls DROP CONSTRAINT chk_control_type;
+ALTER TABLE spending_controls ADD CONSTRAINT chk_control_type CHECK (control_type IN ('merchant_category', 'merchant_name', 'time_based', 'location', 'channel', 'category_limit', 'velocity'));
+
+CREATE TABLE card_declines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    status_code INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_card_declines_card_created_at ON card_declines(card_id, created_at);
+CREATE INDEX idx_transactions_card_created_at ON transactions(card_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_card_created_at;
+DROP TABLE IF EXISTS card_declines;
+
+DELETE FROM spending_controls WHERE control_type = 'velocity';
+ALTER TABLE spending_controls DROP CONSTRAINT chk_control_type;
+ALTER TABLE spending_controls ADD CONSTRAINT chk_control_type CHECK (control_type IN ('merchant_category', 'merchant_name', 'time_based', 'location', 'channel', 'category_limit'));
+-- +goose StatementEnd
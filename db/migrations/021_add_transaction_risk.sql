@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions
+    ADD COLUMN risk_score INTEGER,
+    ADD COLUMN risk_action VARCHAR(20),
+    ADD COLUMN risk_reasons JSONB,
+    ADD COLUMN review_status VARCHAR(20),
+    ADD COLUMN reviewed_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE transactions ADD CONSTRAINT chk_transactions_risk_action CHECK (risk_action IN ('allow', 'review'));
+ALTER TABLE transactions ADD CONSTRAINT chk_transactions_review_status CHECK (review_status IN ('pending', 'approved', 'rejected'));
+
+CREATE INDEX idx_transactions_review_status ON transactions(company_id, review_status, created_at) WHERE review_status IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transactions_review_status;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transactions_review_status;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transactions_risk_action;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS review_status,
+    DROP COLUMN IF EXISTS risk_reasons,
+    DROP COLUMN IF EXISTS risk_action,
+    DROP COLUMN IF EXISTS risk_score;
+-- +goose StatementEnd
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep', 'provisional_credit', 'credit_reversal'));
+
+CREATE TABLE disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    reason VARCHAR(30) NOT NULL,
+    description TEXT,
+    amount DECIMAL(15, 2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'opened',
+    provisional_credit_transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    reversal_transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL,
+    evidence_due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolve_by TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    resolution_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_dispute_reason CHECK (reason IN ('fraud', 'duplicate', 'not_received', 'not_as_described', 'incorrect_amount', 'cancelled', 'other')),
+    CONSTRAINT chk_dispute_status CHECK (status IN ('opened', 'under_review', 'won', 'lost')),
+    CONSTRAINT chk_dispute_amount CHECK (amount > 0)
+);
+
+CREATE UNIQUE INDEX idx_disputes_transaction_id ON disputes(transaction_id);
+CREATE INDEX idx_disputes_company_status ON disputes(company_id, status, created_at);
+CREATE INDEX idx_disputes_card_id ON disputes(card_id);
+
+CREATE TABLE dispute_evidence (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dispute_id UUID NOT NULL REFERENCES disputes(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    description TEXT,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_dispute_evidence_dispute_id ON dispute_evidence(dispute_id);
+
+CREATE TRIGGER update_disputes_updated_at BEFORE UPDATE ON disputes
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS dispute_evidence;
+DROP TABLE IF EXISTS disputes;
+
+DELETE FROM transactions WHERE transaction_type IN ('provisional_credit', 'credit_reversal');
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep'));
+-- +goose StatementEnd
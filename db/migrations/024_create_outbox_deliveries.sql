@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE outbox_deliveries (
+    event_id UUID NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    subscriber VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processed', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    processed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, subscriber)
+);
+
+CREATE INDEX idx_outbox_deliveries_due ON outbox_deliveries(next_attempt_at) WHERE status = 'pending';
+
+CREATE TRIGGER update_outbox_deliveries_updated_at BEFORE UPDATE ON outbox_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox_deliveries;
+-- +goose StatementEnd
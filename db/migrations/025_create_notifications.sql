@@ -0,0 +1,87 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cards ADD COLUMN expiry_notified_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_cards_expiry_unnotified ON cards(expiry_date) WHERE expiry_notified_at IS NULL;
+
+CREATE TABLE notification_preferences (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    trigger VARCHAR(50) NOT NULL,
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('email', 'webhook', 'in_app')),
+    is_enabled BOOLEAN NOT NULL DEFAULT true,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    notify_cardholder BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (company_id, trigger, channel)
+);
+
+CREATE TABLE notification_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    trigger VARCHAR(50) NOT NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (company_id, trigger)
+);
+
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    trigger VARCHAR(50) NOT NULL,
+    dedupe_key VARCHAR(100) NOT NULL,
+    card_id UUID REFERENCES cards(id) ON DELETE SET NULL,
+    subject TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (company_id, trigger, dedupe_key)
+);
+
+CREATE INDEX idx_notifications_company ON notifications(company_id, created_at DESC);
+
+CREATE TABLE notification_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL CHECK (status IN ('sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (notification_id, channel)
+);
+
+CREATE TABLE inbox_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    notification_id UUID NOT NULL UNIQUE REFERENCES notifications(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_inbox_notifications_company ON inbox_notifications(company_id, created_at DESC);
+
+CREATE TRIGGER update_notification_preferences_updated_at BEFORE UPDATE ON notification_preferences
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_notification_templates_updated_at BEFORE UPDATE ON notification_templates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_notification_deliveries_updated_at BEFORE UPDATE ON notification_deliveries
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS inbox_notifications;
+DROP TABLE IF EXISTS notification_deliveries;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS notification_templates;
+DROP TABLE IF EXISTS notification_preferences;
+DROP INDEX IF EXISTS idx_cards_expiry_unnotified;
+ALTER TABLE cards DROP COLUMN IF EXISTS expiry_notified_at;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE inbox_notifications ADD COLUMN read_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_inbox_notifications_unread ON inbox_notifications(company_id, created_at DESC) WHERE read_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_inbox_notifications_unread;
+ALTER TABLE inbox_notifications DROP COLUMN IF EXISTS read_at;
+-- +goose StatementEnd
@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep', 'provisional_credit', 'credit_reversal', 'refund'));
+
+-- Stores are merchants using the merchant-facing API. Each store is listed
+-- in the merchant catalogue, so its payments carry its name and MCC.
+CREATE TABLE stores (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id UUID NOT NULL UNIQUE REFERENCES merchants(id) ON DELETE RESTRICT,
+    email VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended')),
+    api_key_prefix VARCHAR(32) NOT NULL UNIQUE,
+    api_key_hash VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX uq_stores_email ON stores(lower(email));
+
+CREATE TABLE store_payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    store_id UUID NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id) ON DELETE CASCADE,
+    reference VARCHAR(100),
+    card_last_four VARCHAR(4) NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    captured_amount DECIMAL(15, 2) NOT NULL DEFAULT 0.00,
+    refunded_amount DECIMAL(15, 2) NOT NULL DEFAULT 0.00,
+    status VARCHAR(20) NOT NULL DEFAULT 'authorized' CHECK (status IN ('authorized', 'captured', 'voided', 'refunded')),
+    captured_at TIMESTAMP WITH TIME ZONE,
+    voided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_store_payment_amounts CHECK (captured_amount <= amount AND refunded_amount <= captured_amount)
+);
+
+CREATE UNIQUE INDEX uq_store_payments_reference ON store_payments(store_id, reference) WHERE reference IS NOT NULL;
+CREATE INDEX idx_store_payments_store ON store_payments(store_id, created_at DESC);
+
+CREATE TABLE store_refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id UUID NOT NULL REFERENCES store_payments(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id) ON DELETE CASCADE,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_store_refunds_payment ON store_refunds(payment_id);
+
+CREATE TRIGGER update_stores_updated_at BEFORE UPDATE ON stores
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_store_payments_updated_at BEFORE UPDATE ON store_payments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS store_refunds;
+DROP TABLE IF EXISTS store_payments;
+DROP TABLE IF EXISTS stores;
+DELETE FROM transactions WHERE transaction_type = 'refund';
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep', 'provisional_credit', 'credit_reversal'));
+-- +goose StatementEnd
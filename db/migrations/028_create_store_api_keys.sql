@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Stores sign their requests with an API key pair instead of sending a bearer
+-- key. The secret is needed to check signatures, so it is stored like webhook
+-- secrets.
+CREATE TABLE store_api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    store_id UUID NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+    key_id VARCHAR(32) NOT NULL UNIQUE,
+    secret VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_store_api_keys_store ON store_api_keys(store_id, created_at DESC);
+
+-- Bearer keys become key pairs so existing stores keep working: the key ID is
+-- the ID part of the old key and the secret is its stored SHA-256 hash, which
+-- the store can compute from the key it holds. Stores replace these keys by
+-- rotating.
+INSERT INTO store_api_keys (store_id, key_id, secret, created_at)
+SELECT id, api_key_prefix, api_key_hash, created_at
+FROM stores
+WHERE api_key_prefix IS NOT NULL AND api_key_hash IS NOT NULL;
+
+ALTER TABLE stores
+    DROP COLUMN api_key_prefix,
+    DROP COLUMN api_key_hash;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS store_api_keys;
+
+ALTER TABLE stores
+    ADD COLUMN api_key_prefix VARCHAR(32) UNIQUE,
+    ADD COLUMN api_key_hash VARCHAR(64);
+-- +goose StatementEnd
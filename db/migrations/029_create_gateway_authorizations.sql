@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Payments approved through the ISO 8583 gateway. Acquirers identify a
+-- payment by terminal and retrieval reference number, so a repeated request
+-- or a reversal can be matched to it.
+CREATE TABLE gateway_authorizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id) ON DELETE CASCADE,
+    card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+    mti VARCHAR(4) NOT NULL,
+    stan VARCHAR(6) NOT NULL,
+    rrn VARCHAR(12) NOT NULL,
+    terminal_id VARCHAR(8) NOT NULL,
+    auth_code VARCHAR(6) NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL CHECK (amount > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'approved' CHECK (status IN ('approved', 'reversed')),
+    reversed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX uq_gateway_authorizations_rrn ON gateway_authorizations(terminal_id, rrn);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS gateway_authorizations;
+-- +goose StatementEnd
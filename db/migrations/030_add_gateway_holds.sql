@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- An authorization request (0100) places a hold that a financial advice
+-- (0220) completes, while a financial request (0200) is settled at once.
+-- Declined requests are kept too, so a repeated request is answered with the
+-- original response code without running the checks again.
+ALTER TABLE gateway_authorizations
+    ALTER COLUMN transaction_id DROP NOT NULL,
+    ALTER COLUMN card_id DROP NOT NULL,
+    ALTER COLUMN auth_code SET DEFAULT '',
+    ADD COLUMN response_code VARCHAR(2) NOT NULL DEFAULT '00',
+    ADD COLUMN completed_at TIMESTAMP WITH TIME ZONE,
+    DROP CONSTRAINT IF EXISTS gateway_authorizations_status_check,
+    ADD CONSTRAINT gateway_authorizations_status_check
+        CHECK (status IN ('authorized', 'approved', 'completed', 'declined', 'reversed'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM gateway_authorizations WHERE status = 'declined';
+UPDATE gateway_authorizations SET status = 'approved' WHERE status IN ('authorized', 'completed');
+
+ALTER TABLE gateway_authorizations
+    DROP CONSTRAINT IF EXISTS gateway_authorizations_status_check,
+    ADD CONSTRAINT gateway_authorizations_status_check CHECK (status IN ('approved', 'reversed')),
+    DROP COLUMN IF EXISTS completed_at,
+    DROP COLUMN IF EXISTS response_code,
+    ALTER COLUMN auth_code DROP DEFAULT,
+    ALTER COLUMN card_id SET NOT NULL,
+    ALTER COLUMN transaction_id SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A worker holds a lease on the job it runs and keeps extending it. Running
+-- jobs whose lease has expired belonged to a worker that stopped and are
+-- queued again.
+ALTER TABLE import_jobs ADD COLUMN lease_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_import_jobs_lease_expires_at ON import_jobs(lease_expires_at) WHERE status = 'running';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_import_jobs_lease_expires_at;
+ALTER TABLE import_jobs DROP COLUMN IF EXISTS lease_expires_at;
+-- +goose StatementEnd
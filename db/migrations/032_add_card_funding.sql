@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Cards keep the department they were issued for, and their initial funding
+-- is moved from the company balance with a funding transaction.
+ALTER TABLE cards ADD COLUMN department VARCHAR(255);
+
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep', 'provisional_credit', 'credit_reversal', 'refund', 'funding'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM transactions WHERE transaction_type = 'funding';
+ALTER TABLE transactions DROP CONSTRAINT chk_transaction_type;
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_type CHECK (transaction_type IN ('purchase', 'charge', 'sweep', 'provisional_credit', 'credit_reversal', 'refund'));
+
+ALTER TABLE cards DROP COLUMN IF EXISTS department;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- API key secrets are stored sealed with the vault key, which no longer fits
+-- the old column. Secrets still in plaintext are sealed when the server
+-- starts.
+ALTER TABLE store_api_keys ALTER COLUMN secret TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Sealed secrets cannot be turned back into plaintext here, so the column
+-- stays wide enough to hold them.
+SELECT 1;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A purchase keeps the amount credited back for it by voids, refunds and
+-- dispute credits, so the credits together never exceed what was paid.
+ALTER TABLE transactions
+    ADD COLUMN refunded_amount DECIMAL(15, 2) NOT NULL DEFAULT 0.00,
+    ADD CONSTRAINT chk_transactions_refunded_amount CHECK (refunded_amount >= 0 AND refunded_amount <= amount);
+
+UPDATE transactions t
+SET refunded_amount = LEAST(t.amount, credited.amount)
+FROM (
+    SELECT transaction_id, SUM(amount) AS amount
+    FROM (
+        SELECT p.transaction_id, r.amount
+        FROM store_refunds r
+        JOIN store_payments p ON p.id = r.payment_id
+        UNION ALL
+        SELECT transaction_id, amount
+        FROM disputes
+        WHERE reversal_transaction_id IS NULL
+    ) credits
+    GROUP BY transaction_id
+) credited
+WHERE credited.transaction_id = t.id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions
+    DROP CONSTRAINT IF EXISTS chk_transactions_refunded_amount,
+    DROP COLUMN IF EXISTS refunded_amount;
+-- +goose StatementEnd
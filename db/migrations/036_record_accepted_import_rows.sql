@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accepted rows are kept in the job report too, with the card to issue that
+-- was created for them. Dry runs create no card, so the reference stays NULL.
+ALTER TABLE import_job_rows
+    ADD COLUMN card_to_issue_id UUID REFERENCES cards_to_issue(id) ON DELETE SET NULL;
+
+ALTER TABLE import_job_rows DROP CONSTRAINT chk_import_job_row_status;
+ALTER TABLE import_job_rows ADD CONSTRAINT chk_import_job_row_status CHECK (status IN ('accepted', 'rejected', 'duplicate'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM import_job_rows WHERE status = 'accepted';
+
+ALTER TABLE import_job_rows DROP CONSTRAINT chk_import_job_row_status;
+ALTER TABLE import_job_rows ADD CONSTRAINT chk_import_job_row_status CHECK (status IN ('rejected', 'duplicate'));
+
+ALTER TABLE import_job_rows DROP COLUMN IF EXISTS card_to_issue_id;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every top-up of a company balance is recorded, so the balance that card
+-- initial funding is drawn from can be reconciled with incoming money.
+CREATE TABLE company_fundings (
+                                  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                  company_id UUID NOT NULL REFERENCES companies(id) ON DELETE CASCADE,
+                                  amount DECIMAL(15, 2) NOT NULL,
+                                  reference VARCHAR(255),
+                                  balance_after DECIMAL(15, 2) NOT NULL,
+                                  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE company_fundings ADD CONSTRAINT chk_company_funding_amount CHECK (amount > 0);
+
+CREATE INDEX idx_company_fundings_company_id ON company_fundings(company_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS company_fundings;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Declines are kept with their authorization reason instead of the HTTP
+-- status, since payments arrive on transports other than HTTP too.
+ALTER TABLE card_declines ADD COLUMN reason VARCHAR(50);
+
+UPDATE card_declines
+SET reason = CASE status_code
+                 WHEN 401 THEN 'invalid_cvv'
+                 WHEN 402 THEN 'insufficient_funds'
+                 ELSE 'declined'
+             END;
+
+ALTER TABLE card_declines ALTER COLUMN reason SET NOT NULL;
+ALTER TABLE card_declines DROP COLUMN status_code;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE card_declines ADD COLUMN status_code INTEGER;
+
+UPDATE card_declines
+SET status_code = CASE
+                      WHEN reason IN ('invalid_cvv', 'invalid_pin') THEN 401
+                      WHEN reason = 'insufficient_funds' THEN 402
+                      ELSE 403
+                  END;
+
+ALTER TABLE card_declines ALTER COLUMN status_code SET NOT NULL;
+ALTER TABLE card_declines DROP COLUMN reason;
+-- +goose StatementEnd
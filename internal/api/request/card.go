@@ -1,11 +1,35 @@
 package request
 
+import "ccards/pkg/models"
+
 type CardSetSpendingLimit struct {
 	SpendingLimit int `json:"spending_limit" binding:"required,min=1,max=50000"`
 }
 
 type CardUpdateSpendingControl struct {
-	ControlType  string   `json:"control_type" binding:"required,oneof=merchant_category"`
+	ControlType  string   `json:"control_type" binding:"required,oneof=merchant_category channel category_limit velocity"`
 	AllowedCategories []string `json:"allowed_categories"`
 	BlockedCategories []string `json:"blocked_categories"`
+	AllowedMCCs       []string `json:"allowed_mccs"`
+	BlockedMCCs       []string `json:"blocked_mccs"`
+
+	AllowedChannels          []string            `json:"allowed_channels"`
+	BlockedChannels          []string            `json:"blocked_channels"`
+	ChannelBlockedCategories map[string][]string `json:"channel_blocked_categories"`
+
+	Limits []models.CategoryLimit `json:"limits"`
+
+	MaxTransactions   int    `json:"max_transactions"`
+	TransactionWindow string `json:"transaction_window"`
+	MaxDeclines       int    `json:"max_declines"`
+	DeclineWindow     string `json:"decline_window"`
+}
+
+type CardSetPIN struct {
+	PIN string `json:"pin" binding:"required,numeric,min=4,max=6"`
+}
+
+type CardChangePIN struct {
+	CurrentPIN string `json:"current_pin" binding:"required,numeric,min=4,max=6"`
+	NewPIN     string `json:"new_pin" binding:"required,numeric,min=4,max=6"`
 }
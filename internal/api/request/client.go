@@ -1,5 +1,13 @@
 package request
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
 type RegisterCompany struct {
 	Name    string `json:"name" binding:"required,min=2,max=255"`
 	Email   string `json:"email" binding:"required,email"`
@@ -7,6 +15,13 @@ type RegisterCompany struct {
 	Phone   string `json:"phone" binding:"max=50"`
 }
 
+// FundCompany tops up a company balance, which card initial funding is drawn
+// from. Reference identifies the incoming payment, e.g. a bank transfer.
+type FundCompany struct {
+	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	Reference string  `json:"reference" binding:"max=255"`
+}
+
 type LoginCompany struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
@@ -15,3 +30,16 @@ type LoginCompany struct {
 type RefreshToken struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
+
+// IssueCards selects which pending cards to issue and when. Without IDs every
+// pending card is issued; with a future ScheduledFor the cards are issued by
+// the scheduler on that date instead of immediately.
+type IssueCards struct {
+	CardToIssueIDs []uuid.UUID            `json:"card_to_issue_ids"`
+	ScheduledFor   *time.Time             `json:"scheduled_for"`
+	Policy         *models.IssuancePolicy `json:"policy"`
+}
+
+type CancelCardsToIssue struct {
+	CardToIssueIDs []uuid.UUID `json:"card_to_issue_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,16 @@
+package request
+
+import "github.com/google/uuid"
+
+type CreateDispute struct {
+	TransactionID uuid.UUID `json:"transaction_id" binding:"required"`
+	Reason        string    `json:"reason" binding:"required,oneof=fraud duplicate not_received not_as_described incorrect_amount cancelled other"`
+	Description   string    `json:"description" binding:"max=2000"`
+	// Amount defaults to the full transaction amount.
+	Amount *float64 `json:"amount" binding:"omitempty,gt=0"`
+}
+
+type ResolveDispute struct {
+	Outcome string `json:"outcome" binding:"required,oneof=won lost"`
+	Note    string `json:"note" binding:"max=2000"`
+}
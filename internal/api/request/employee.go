@@ -0,0 +1,27 @@
+package request
+
+import "github.com/google/uuid"
+
+type CreateEmployee struct {
+	ExternalID *string    `json:"external_id" binding:"omitempty,max=100"`
+	Email      string     `json:"email" binding:"required,email"`
+	Name       string     `json:"name" binding:"required,min=1,max=255"`
+	Department *string    `json:"department" binding:"omitempty,max=255"`
+	CostCenter *string    `json:"cost_center" binding:"omitempty,max=100"`
+	ManagerID  *uuid.UUID `json:"manager_id"`
+}
+
+type UpdateEmployee struct {
+	ExternalID *string    `json:"external_id" binding:"omitempty,max=100"`
+	Email      *string    `json:"email" binding:"omitempty,email"`
+	Name       *string    `json:"name" binding:"omitempty,min=1,max=255"`
+	Department *string    `json:"department" binding:"omitempty,max=255"`
+	CostCenter *string    `json:"cost_center" binding:"omitempty,max=100"`
+	ManagerID  *uuid.UUID `json:"manager_id"`
+	Status     *string    `json:"status" binding:"omitempty,oneof=active inactive"`
+}
+
+type OffboardEmployee struct {
+	CardAction string  `json:"card_action" binding:"required,oneof=block cancel"`
+	Reason     *string `json:"reason" binding:"omitempty,max=500"`
+}
@@ -0,0 +1,6 @@
+package request
+
+type CreateMerchant struct {
+	Name string `json:"name" binding:"required,min=1,max=255"`
+	MCC  string `json:"mcc" binding:"required,len=4,numeric"`
+}
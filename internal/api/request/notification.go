@@ -0,0 +1,21 @@
+package request
+
+// NotificationPreference sets whether a trigger is notified on a channel and
+// who receives it. Recipients are email addresses for email and https URLs of
+// public hosts for webhook; the in-app channel has none.
+type NotificationPreference struct {
+	Trigger    string   `json:"trigger" binding:"required,oneof=low_balance limit_nearly_reached payment_declined card_blocked card_expiring"`
+	Channel    string   `json:"channel" binding:"required,oneof=email webhook in_app"`
+	Recipients []string `json:"recipients" binding:"max=20,dive,required,max=2000"`
+	// NotifyCardholder also emails the employee holding the card.
+	NotifyCardholder bool `json:"notify_cardholder"`
+	// IsEnabled defaults to true.
+	IsEnabled *bool `json:"is_enabled"`
+}
+
+// NotificationTemplate replaces a trigger's built-in template. Subject and
+// body are Go text/template sources.
+type NotificationTemplate struct {
+	Subject string `json:"subject" binding:"required,max=255"`
+	Body    string `json:"body" binding:"required,max=5000"`
+}
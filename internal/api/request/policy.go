@@ -0,0 +1,16 @@
+package request
+
+import "ccards/pkg/models"
+
+// PolicyTemplate is the body for creating a policy template and for replacing
+// an existing one.
+type PolicyTemplate struct {
+	Name          string                   `json:"name" binding:"required,min=1,max=100"`
+	Description   *string                  `json:"description" binding:"omitempty,max=500"`
+	CardType      *string                  `json:"card_type" binding:"omitempty,oneof=virtual physical"`
+	SpendingLimit *float64                 `json:"spending_limit" binding:"omitempty,gt=0"`
+	DailyLimit    *float64                 `json:"daily_limit" binding:"omitempty,gt=0"`
+	MonthlyLimit  *float64                 `json:"monthly_limit" binding:"omitempty,gt=0"`
+	Controls      []models.IssuanceControl `json:"controls"`
+	IsDefault     bool                     `json:"is_default"`
+}
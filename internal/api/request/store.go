@@ -0,0 +1,37 @@
+package request
+
+type RegisterStore struct {
+	Name  string `json:"name" binding:"required,max=255"`
+	Email string `json:"email" binding:"required,email,max=255"`
+	MCC   string `json:"mcc" binding:"required,len=4,numeric"`
+}
+
+// StoreAuthorization is a store's request to authorize a card payment. The
+// card is identified by its number and expiry date, as printed on it, or by
+// its token.
+type StoreAuthorization struct {
+	CardNumber  string  `json:"card_number,omitempty" binding:"required_without=Token,omitempty,numeric,min=12,max=19"`
+	Token       string  `json:"token,omitempty" binding:"required_without=CardNumber,omitempty,max=64"`
+	ExpiryMonth int     `json:"expiry_month,omitempty" binding:"required_with=CardNumber,omitempty,min=1,max=12"`
+	ExpiryYear  int     `json:"expiry_year,omitempty" binding:"required_with=CardNumber,omitempty,min=2000,max=2100"`
+	CVV         string  `json:"cvv,omitempty" binding:"omitempty,len=3,numeric"`
+	PIN         string  `json:"pin,omitempty" binding:"omitempty,numeric,min=4,max=6"`
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Channel     string  `json:"channel,omitempty" binding:"omitempty,oneof=online chip contactless atm"`
+	TerminalID  string  `json:"terminal_id,omitempty" binding:"omitempty,max=64"`
+	// Reference is the store's own payment reference, unique per store.
+	Reference string `json:"reference,omitempty" binding:"omitempty,max=100"`
+}
+
+// StoreCapture captures an authorized payment. Without an amount the full
+// authorized amount is captured.
+type StoreCapture struct {
+	Amount *float64 `json:"amount,omitempty" binding:"omitempty,gt=0"`
+}
+
+// StoreRefund refunds a captured payment. Without an amount everything not
+// yet refunded is refunded.
+type StoreRefund struct {
+	Amount *float64 `json:"amount,omitempty" binding:"omitempty,gt=0"`
+	Reason string   `json:"reason,omitempty" binding:"omitempty,max=500"`
+}
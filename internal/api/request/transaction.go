@@ -1,10 +1,25 @@
 package request
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
 
 type Transaction struct {
-	CompanyID        uuid.UUID `json:"company_id" binding:"required"`
-	CardID           uuid.UUID `json:"card_id" binding:"required"`
-	Amount           float64   `json:"amount" binding:"required"`
-	MerchantCategory string    `json:"merchant_category" binding:"required"`
+	CompanyID        uuid.UUID  `json:"company_id" binding:"required"`
+	CardID           uuid.UUID  `json:"card_id" binding:"required_without=Token"`
+	Token            string     `json:"token,omitempty" binding:"required_without=CardID,omitempty,max=64"`
+	Amount           float64    `json:"amount" binding:"required"`
+	MerchantCategory string     `json:"merchant_category" binding:"required_without_all=MCC MerchantID"`
+	MCC              string     `json:"mcc,omitempty" binding:"omitempty,len=4,numeric"`
+	MerchantID       *uuid.UUID `json:"merchant_id,omitempty"`
+	MerchantName     string     `json:"merchant_name,omitempty" binding:"omitempty,max=255"`
+	Channel          string     `json:"channel,omitempty" binding:"omitempty,oneof=online chip contactless atm"`
+	TerminalID       string     `json:"terminal_id,omitempty" binding:"omitempty,max=64"`
+	CVV              string     `json:"cvv,omitempty" binding:"omitempty,len=3,numeric"`
+	PIN              string     `json:"pin,omitempty" binding:"omitempty,numeric,min=4,max=6"`
+
+	// Risk is the assessment of the RiskScore check, never set by the client.
+	Risk *models.RiskAssessment `json:"-"`
 }
@@ -0,0 +1,21 @@
+package request
+
+import "time"
+
+// WebhookEndpoint registers or replaces a webhook endpoint. The URL must use
+// https; the service also checks that it points to a public address. Without
+// event types the endpoint receives every event.
+type WebhookEndpoint struct {
+	URL         string   `json:"url" binding:"required,url,startswith=https://,max=2000"`
+	EventTypes  []string `json:"event_types" binding:"omitempty,dive,oneof=payment.completed payment.declined card.issued card.blocked card.status_changed card.expiring"`
+	Description string   `json:"description" binding:"max=255"`
+	// IsActive defaults to true.
+	IsActive *bool `json:"is_active"`
+}
+
+// ReplayWebhookEvents queues events again for an endpoint. With Since every
+// matching event created since then is sent again; without it the endpoint's
+// dead letters are retried.
+type ReplayWebhookEvents struct {
+	Since *time.Time `json:"since"`
+}
@@ -0,0 +1,29 @@
+package response
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CardReveal carries a card's full PAN and CVV. It is only returned by the
+// audited reveal endpoint.
+type CardReveal struct {
+	CardID         uuid.UUID `json:"card_id"`
+	Token          string    `json:"token"`
+	CardHolderName string    `json:"card_holder_name"`
+	PAN            string    `json:"pan"`
+	CVV            string    `json:"cvv,omitempty"`
+	ExpiryDate     time.Time `json:"expiry_date"`
+}
+
+// IssuedCard is returned once when a card is issued. It is the only response,
+// besides the reveal endpoint, that carries the card's CVV.
+type IssuedCard struct {
+	CardID     uuid.UUID `json:"card_id"`
+	EmployeeID uuid.UUID `json:"employee_id"`
+	Token      string    `json:"token"`
+	MaskedPAN  string    `json:"masked_pan"`
+	CVV        string    `json:"cvv"`
+	ExpiryDate time.Time `json:"expiry_date"`
+}
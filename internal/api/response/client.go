@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"ccards/pkg/models"
 )
 
 type Company struct {
@@ -14,6 +16,7 @@ type Company struct {
 	Address   string    `json:"address,omitempty"`
 	Phone     string    `json:"phone,omitempty"`
 	Status    string    `json:"status"`
+	Balance   float64   `json:"balance"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -39,3 +42,24 @@ type RefreshTokenResponse struct {
 	ExpiresAt   time.Time `json:"expires_at"`
 	TokenType   string    `json:"token_type"`
 }
+
+const (
+	CSVRowAccepted  = "accepted"
+	CSVRowRejected  = "rejected"
+	CSVRowDuplicate = "duplicate"
+)
+
+type CSVRowResult struct {
+	Line          int        `json:"line"`
+	EmployeeID    string     `json:"employee_id,omitempty"`
+	EmployeeEmail string     `json:"employee_email,omitempty"`
+	Status        string     `json:"status"`
+	Reasons       []string   `json:"reasons,omitempty"`
+	CardToIssueID *uuid.UUID `json:"card_to_issue_id,omitempty"`
+}
+
+type ImportJobStatus struct {
+	*models.ImportJob
+	Progress float64        `json:"progress"`
+	Rows     []CSVRowResult `json:"rows"`
+}
@@ -0,0 +1,24 @@
+package response
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+type OffboardingReport struct {
+	OffboardingID        uuid.UUID                `json:"offboarding_id"`
+	EmployeeID           uuid.UUID                `json:"employee_id"`
+	CardAction           string                   `json:"card_action"`
+	Status               string                   `json:"status"`
+	LastError            *string                  `json:"last_error,omitempty"`
+	Cards                []*models.OffboardedCard `json:"cards"`
+	CardsProcessed       int                      `json:"cards_processed"`
+	TotalBalanceReturned float64                  `json:"total_balance_returned"`
+	TotalHoldsVoided     int                      `json:"total_holds_voided"`
+	TotalControlsRevoked int                      `json:"total_controls_revoked"`
+	StartedAt            time.Time                `json:"started_at"`
+	CompletedAt          *time.Time               `json:"completed_at,omitempty"`
+}
@@ -1,6 +1,7 @@
 package response
 
 import (
+	"ccards/pkg/models"
 	"github.com/google/uuid"
 	"time"
 )
@@ -13,11 +14,21 @@ type Transaction struct {
 	Amount           float64    `json:"amount"`
 	MerchantName     *string    `json:"merchant_name,omitempty"`
 	MerchantCategory *string    `json:"merchant_category,omitempty"`
+	MCC              *string    `json:"mcc,omitempty"`
+	MerchantID       *uuid.UUID `json:"merchant_id,omitempty"`
 	Description      string     `json:"description"`
 	Status           string     `json:"status"`
+	Channel          string     `json:"channel"`
+	TerminalID       *string    `json:"terminal_id,omitempty"`
 	ProcessedAt      *time.Time `json:"processed_at,omitempty"`
 	CreatedAt        time.Time  `json:"created_at"`
 	UpdatedAt        time.Time  `json:"updated_at"`
+
+	RiskScore    *int                `json:"risk_score,omitempty"`
+	RiskAction   *string             `json:"risk_action,omitempty"`
+	RiskReasons  []models.RiskReason `json:"risk_reasons,omitempty"`
+	ReviewStatus *string             `json:"review_status,omitempty"`
+	ReviewedAt   *time.Time          `json:"reviewed_at,omitempty"`
 }
 
 type TransactionResponse struct {
@@ -2,12 +2,16 @@ package card
 
 import (
 	"ccards/internal/api/request"
+	"encoding/json"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"ccards/pkg/errors"
 	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/validation"
 )
 
 type Handler struct {
@@ -138,12 +142,38 @@ func (h *Handler) UpdateSpendingControl(c *gin.Context) {
 		controlValue = middleware.MerchantCategoryControl{
 			AllowedCategories: req.AllowedCategories,
 			BlockedCategories: req.BlockedCategories,
+			AllowedMCCs:       req.AllowedMCCs,
+			BlockedMCCs:       req.BlockedMCCs,
+		}
+	case "channel":
+		controlValue = middleware.ChannelControl{
+			AllowedChannels:   req.AllowedChannels,
+			BlockedChannels:   req.BlockedChannels,
+			BlockedCategories: req.ChannelBlockedCategories,
+		}
+	case "category_limit":
+		controlValue = middleware.CategoryLimitControl{Limits: req.Limits}
+	case "velocity":
+		controlValue = middleware.VelocityControl{
+			MaxTransactions:   req.MaxTransactions,
+			TransactionWindow: req.TransactionWindow,
+			MaxDeclines:       req.MaxDeclines,
+			DeclineWindow:     req.DeclineWindow,
 		}
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported control type"})
 		return
 	}
 
+	value, err := json.Marshal(controlValue)
+	if err == nil {
+		err = validation.ValidateControlValue(req.ControlType, value)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Update the spending control
 	err = h.service.UpdateSpendingControl(c, card.ID, req.ControlType, controlValue)
 	if err != nil {
@@ -156,3 +186,146 @@ func (h *Handler) UpdateSpendingControl(c *gin.Context) {
 		"control_type": req.ControlType,
 	})
 }
+
+// RevealCard returns the card's full PAN and CVV. Every reveal is audited.
+func (h *Handler) RevealCard(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	cardUUID, err := uuid.Parse(c.Query("cardId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID format"})
+		return
+	}
+
+	revealed, err := h.service.RevealCard(c.Request.Context(), &models.CardReveal{
+		CardID:    cardUUID,
+		CompanyID: companyID,
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
+		case errors.ErrSecretUnavailable:
+			c.JSON(http.StatusConflict, gin.H{"error": "Card details are not available for this card"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reveal card"})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, revealed)
+}
+
+// companyCard resolves the cardId or token query parameter to a card of the
+// authenticated company. It writes the error response and returns nil when
+// the card cannot be resolved.
+func (h *Handler) companyCard(c *gin.Context) *models.Card {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil
+	}
+
+	var card *models.Card
+	if token := c.Query("token"); token != "" {
+		card, err = h.service.GetCardByToken(c.Request.Context(), companyID, token)
+	} else {
+		cardUUID, parseErr := uuid.Parse(c.Query("cardId"))
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid card ID format"})
+			return nil
+		}
+		card, err = h.service.GetCardByCompanyIDAndCardID(c.Request.Context(), companyID, cardUUID)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve card"})
+		return nil
+	}
+	if card == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
+		return nil
+	}
+
+	return card
+}
+
+func pinErrorResponse(c *gin.Context, err error, fallback string) {
+	switch err {
+	case errors.ErrPINNotSupported, errors.ErrWeakPIN:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.ErrPINAlreadySet, errors.ErrPINNotSet:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.ErrIncorrectPIN:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect PIN"})
+	case errors.ErrPINLocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Card PIN is locked"})
+	case errors.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// SetPIN sets the PIN of a physical card that has none yet.
+func (h *Handler) SetPIN(c *gin.Context) {
+	var req request.CardSetPIN
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	card := h.companyCard(c)
+	if card == nil {
+		return
+	}
+
+	if err := h.service.SetPIN(c.Request.Context(), card, req.PIN); err != nil {
+		pinErrorResponse(c, err, "Failed to set PIN")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN set successfully"})
+}
+
+// ChangePIN replaces a card's PIN after checking the current one.
+func (h *Handler) ChangePIN(c *gin.Context) {
+	var req request.CardChangePIN
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	card := h.companyCard(c)
+	if card == nil {
+		return
+	}
+
+	if err := h.service.ChangePIN(c.Request.Context(), card, req.CurrentPIN, req.NewPIN); err != nil {
+		pinErrorResponse(c, err, "Failed to change PIN")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN changed successfully"})
+}
+
+// UnlockPIN lifts a PIN lockout caused by too many wrong PINs.
+func (h *Handler) UnlockPIN(c *gin.Context) {
+	card := h.companyCard(c)
+	if card == nil {
+		return
+	}
+
+	if err := h.service.UnlockPIN(c.Request.Context(), card); err != nil {
+		pinErrorResponse(c, err, "Failed to unlock PIN")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN unlocked successfully"})
+}
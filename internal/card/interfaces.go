@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 
+	"ccards/internal/api/response"
 	"ccards/pkg/models"
 )
 
@@ -12,12 +13,28 @@ type Repository interface {
 	GetCardsByCompanyID(ctx context.Context, companyID uuid.UUID) ([]*models.Card, error)
 	UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spendingLimit int) (*models.Card, error)
 	GetCardByCompanyIDAndCardID(ctx context.Context, companyID uuid.UUID, cardID uuid.UUID) (*models.Card, error)
+	GetCardByToken(ctx context.Context, companyID uuid.UUID, token string) (*models.Card, error)
 	UpdateSpendingControl(ctx context.Context, cardID uuid.UUID, controlType string, controlValue interface{}) error
+	GetCardSecret(ctx context.Context, companyID uuid.UUID, cardID uuid.UUID) (*models.Card, error)
+	RecordReveal(ctx context.Context, reveal *models.CardReveal) error
+	EncryptCardNumbers(ctx context.Context, seal func(pan string) (*models.CardSecret, error)) (int, error)
+	MigrateCVVs(ctx context.Context, migrate func(cardID uuid.UUID, secret *models.CardSecret) (*models.CardSecret, string, error)) (int, error)
+	SetPIN(ctx context.Context, cardID uuid.UUID, pinHash string, currentHash *string) error
+	RecordPINFailure(ctx context.Context, cardID uuid.UUID, maxAttempts int) (int, error)
+	ResetPINFailures(ctx context.Context, cardID uuid.UUID) error
+	UnlockPIN(ctx context.Context, cardID uuid.UUID) error
 }
 
 type Service interface {
 	GetCardsByCompanyID(ctx context.Context, companyID uuid.UUID) ([]*models.Card, error)
 	GetCardByCompanyIDAndCardID(ctx context.Context, companyID uuid.UUID, cardID uuid.UUID) (*models.Card, error)
+	GetCardByToken(ctx context.Context, companyID uuid.UUID, token string) (*models.Card, error)
 	UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spendingLimit int) (*models.Card, error)
 	UpdateSpendingControl(ctx context.Context, cardID uuid.UUID, controlType string, controlValue interface{}) error
+	RevealCard(ctx context.Context, reveal *models.CardReveal) (*response.CardReveal, error)
+	EncryptStoredCardNumbers(ctx context.Context) (int, error)
+	MigrateCardCVVs(ctx context.Context) (int, error)
+	SetPIN(ctx context.Context, card *models.Card, pin string) error
+	ChangePIN(ctx context.Context, card *models.Card, currentPIN, newPIN string) error
+	UnlockPIN(ctx context.Context, card *models.Card) error
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 
+	apperrors "ccards/pkg/errors"
 	"ccards/pkg/models"
 )
 
@@ -23,9 +24,7 @@ func NewRepository(db *sql.DB) Repository {
 
 func (r *repository) GetCardsByCompanyID(ctx context.Context, companyID uuid.UUID) ([]*models.Card, error) {
 	query := `
-		SELECT id, company_id, card_number, card_holder_name, employee_id, employee_email, 
-		       card_type, status, balance, spending_limit, daily_limit, monthly_limit, 
-		       expiry_date, cvv_hash, last_four, created_at, updated_at, blocked_at, blocked_reason
+		SELECT ` + models.CardColumns + `
 		FROM cards
 		WHERE company_id = $1
 		ORDER BY created_at DESC
@@ -40,14 +39,7 @@ func (r *repository) GetCardsByCompanyID(ctx context.Context, companyID uuid.UUI
 	var cards []*models.Card
 	for rows.Next() {
 		var card models.Card
-		err := rows.Scan(
-			&card.ID, &card.CompanyID, &card.CardNumber, &card.CardHolderName,
-			&card.EmployeeID, &card.EmployeeEmail, &card.CardType, &card.Status,
-			&card.Balance, &card.SpendingLimit, &card.DailyLimit, &card.MonthlyLimit,
-			&card.ExpiryDate, &card.CVVHash, &card.LastFour, &card.CreatedAt,
-			&card.UpdatedAt, &card.BlockedAt, &card.BlockedReason,
-		)
-		if err != nil {
+		if err := models.ScanCard(rows, &card); err != nil {
 			return nil, err
 		}
 		cards = append(cards, &card)
@@ -65,19 +57,10 @@ func (r *repository) UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spen
 		UPDATE cards
 		SET spending_limit = $2, updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1
-		RETURNING id, company_id, card_number, card_holder_name, employee_id, employee_email, 
-		       card_type, status, balance, spending_limit, daily_limit, monthly_limit, 
-		       expiry_date, cvv_hash, last_four, created_at, updated_at, blocked_at, blocked_reason
-	`
+		RETURNING ` + models.CardColumns
 
 	var card models.Card
-	err := r.db.QueryRowContext(ctx, query, id, spendingLimit).Scan(
-		&card.ID, &card.CompanyID, &card.CardNumber, &card.CardHolderName,
-		&card.EmployeeID, &card.EmployeeEmail, &card.CardType, &card.Status,
-		&card.Balance, &card.SpendingLimit, &card.DailyLimit, &card.MonthlyLimit,
-		&card.ExpiryDate, &card.CVVHash, &card.LastFour, &card.CreatedAt,
-		&card.UpdatedAt, &card.BlockedAt, &card.BlockedReason,
-	)
+	err := models.ScanCard(r.db.QueryRowContext(ctx, query, id, spendingLimit), &card)
 	if err != nil {
 		return nil, err
 	}
@@ -87,22 +70,14 @@ func (r *repository) UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spen
 
 func (r *repository) GetCardByCompanyIDAndCardID(ctx context.Context, companyID uuid.UUID, cardID uuid.UUID) (*models.Card, error) {
 	query := `
-		SELECT id, company_id, card_number, card_holder_name, employee_id, employee_email, 
-		       card_type, status, balance, spending_limit, daily_limit, monthly_limit, 
-		       expiry_date, cvv_hash, last_four, created_at, updated_at, blocked_at, blocked_reason
+		SELECT ` + models.CardColumns + `
 		FROM cards
 		WHERE company_id = $1 AND id = $2
 		ORDER BY created_at DESC
 	`
 
 	var card models.Card
-	err := r.db.QueryRowContext(ctx, query, companyID, cardID).Scan(
-		&card.ID, &card.CompanyID, &card.CardNumber, &card.CardHolderName,
-		&card.EmployeeID, &card.EmployeeEmail, &card.CardType, &card.Status,
-		&card.Balance, &card.SpendingLimit, &card.DailyLimit, &card.MonthlyLimit,
-		&card.ExpiryDate, &card.CVVHash, &card.LastFour, &card.CreatedAt,
-		&card.UpdatedAt, &card.BlockedAt, &card.BlockedReason,
-	)
+	err := models.ScanCard(r.db.QueryRowContext(ctx, query, companyID, cardID), &card)
 	if err != nil {
 		return nil, err
 	}
@@ -110,6 +85,27 @@ func (r *repository) GetCardByCompanyIDAndCardID(ctx context.Context, companyID
 	return &card, nil
 }
 
+// GetCardByToken returns the company's card with the given token, or nil when
+// there is none.
+func (r *repository) GetCardByToken(ctx context.Context, companyID uuid.UUID, token string) (*models.Card, error) {
+	query := `
+		SELECT ` + models.CardColumns + `
+		FROM cards
+		WHERE company_id = $1 AND token = $2
+	`
+
+	var card models.Card
+	err := models.ScanCard(r.db.QueryRowContext(ctx, query, companyID, token), &card)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get card by token: %w", err)
+	}
+
+	return &card, nil
+}
+
 func (r *repository) UpdateSpendingControl(ctx context.Context, cardID uuid.UUID, controlType string, controlValue interface{}) error {
 	// First, check if a spending control of this type already exists for the card
 	checkQuery := `
@@ -169,3 +165,287 @@ func (r *repository) UpdateSpendingControl(ctx context.Context, cardID uuid.UUID
 
 	return nil
 }
+
+// GetCardSecret returns the card with its encrypted PAN and CVV. Secret is nil
+// for cards that have not been encrypted yet.
+func (r *repository) GetCardSecret(ctx context.Context, companyID uuid.UUID, cardID uuid.UUID) (*models.Card, error) {
+	query := `
+		SELECT id, company_id, token, card_holder_name, expiry_date, last_four,
+		       secret_key_id, secret_data_key, secret_ciphertext, pan_fingerprint
+		FROM cards
+		WHERE company_id = $1 AND id = $2
+	`
+
+	var card models.Card
+	var secret models.CardSecret
+	var keyID, fingerprint sql.NullString
+
+	err := r.db.QueryRowContext(ctx, query, companyID, cardID).Scan(
+		&card.ID, &card.CompanyID, &card.Token, &card.CardHolderName, &card.ExpiryDate, &card.LastFour,
+		&keyID, &secret.DataKey, &secret.Ciphertext, &fingerprint,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get card secret: %w", err)
+	}
+
+	card.MaskedPAN = models.MaskPAN(card.LastFour)
+	if keyID.Valid {
+		secret.KeyID = keyID.String
+		secret.Fingerprint = fingerprint.String
+		card.Secret = &secret
+	}
+
+	return &card, nil
+}
+
+func (r *repository) RecordReveal(ctx context.Context, reveal *models.CardReveal) error {
+	query := `
+		INSERT INTO card_reveals (card_id, company_id, client_ip, user_agent)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query, reveal.CardID, reveal.CompanyID, reveal.ClientIP, reveal.UserAgent).
+		Scan(&reveal.ID, &reveal.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record card reveal: %w", err)
+	}
+
+	return nil
+}
+
+const secretBatchSize = 500
+
+// inBatches calls batch until it processes fewer than secretBatchSize cards
+// and returns the total number of cards processed.
+func inBatches(batch func() (int, error)) (int, error) {
+	total := 0
+	for {
+		processed, err := batch()
+		total += processed
+		if err != nil {
+			return total, err
+		}
+		if processed < secretBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// EncryptCardNumbers encrypts card numbers still stored in plaintext and
+// clears them, in batches. It returns the number of cards encrypted.
+func (r *repository) EncryptCardNumbers(ctx context.Context, seal func(pan string) (*models.CardSecret, error)) (int, error) {
+	return inBatches(func() (int, error) {
+		return r.encryptCardNumberBatch(ctx, seal)
+	})
+}
+
+func (r *repository) encryptCardNumberBatch(ctx context.Context, seal func(pan string) (*models.CardSecret, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, card_number
+		FROM cards
+		WHERE card_number IS NOT NULL
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		secretBatchSize,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch card numbers: %w", err)
+	}
+
+	pans := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var pan string
+		if err := rows.Scan(&id, &pan); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan card number: %w", err)
+		}
+		pans[id] = pan
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	for id, pan := range pans {
+		secret, err := seal(pan)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE cards
+			SET secret_key_id = $2, secret_data_key = $3, secret_ciphertext = $4, pan_fingerprint = $5,
+			    card_number = NULL
+			WHERE id = $1`,
+			id, secret.KeyID, secret.DataKey, secret.Ciphertext, secret.Fingerprint,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store encrypted card number: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(pans), nil
+}
+
+// MigrateCVVs stores a CVV MAC for encrypted cards that do not have one yet,
+// in batches. migrate returns the card's secret, re-sealed if its CVV changed,
+// and the MAC. It returns the number of cards migrated.
+func (r *repository) MigrateCVVs(ctx context.Context, migrate func(cardID uuid.UUID, secret *models.CardSecret) (*models.CardSecret, string, error)) (int, error) {
+	return inBatches(func() (int, error) {
+		return r.migrateCVVBatch(ctx, migrate)
+	})
+}
+
+func (r *repository) migrateCVVBatch(ctx context.Context, migrate func(cardID uuid.UUID, secret *models.CardSecret) (*models.CardSecret, string, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, secret_key_id, secret_data_key, secret_ciphertext, pan_fingerprint
+		FROM cards
+		WHERE cvv_mac IS NULL AND secret_key_id IS NOT NULL
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		secretBatchSize,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch cards without CVV MAC: %w", err)
+	}
+
+	secrets := make(map[uuid.UUID]*models.CardSecret)
+	for rows.Next() {
+		var id uuid.UUID
+		secret := &models.CardSecret{}
+		if err := rows.Scan(&id, &secret.KeyID, &secret.DataKey, &secret.Ciphertext, &secret.Fingerprint); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan card secret: %w", err)
+		}
+		secrets[id] = secret
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	for id, secret := range secrets {
+		secret, mac, err := migrate(id, secret)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE cards
+			SET secret_key_id = $2, secret_data_key = $3, secret_ciphertext = $4, cvv_mac = $5
+			WHERE id = $1`,
+			id, secret.KeyID, secret.DataKey, secret.Ciphertext, mac,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store CVV MAC: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(secrets), nil
+}
+
+// SetPIN stores a new PIN hash and clears any lockout. The update only applies
+// while the stored hash still equals currentHash (nil for a card without a
+// PIN), so concurrent changes cannot overwrite each other; otherwise it
+// returns ErrNotFound.
+func (r *repository) SetPIN(ctx context.Context, cardID uuid.UUID, pinHash string, currentHash *string) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE cards
+		SET pin_hash = $2, pin_failed_attempts = 0, pin_locked_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND pin_hash IS NOT DISTINCT FROM $3`,
+		cardID, pinHash, currentHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set PIN: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// RecordPINFailure counts a wrong PIN and locks the PIN once maxAttempts
+// consecutive attempts have failed. It returns the number of failed attempts.
+// Counting and locking happen in one statement, so concurrent attempts cannot
+// get past the limit.
+func (r *repository) RecordPINFailure(ctx context.Context, cardID uuid.UUID, maxAttempts int) (int, error) {
+	var attempts int
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE cards
+		SET pin_failed_attempts = pin_failed_attempts + 1,
+		    pin_locked_at = CASE WHEN pin_failed_attempts + 1 >= $2 THEN COALESCE(pin_locked_at, CURRENT_TIMESTAMP) ELSE pin_locked_at END
+		WHERE id = $1
+		RETURNING pin_failed_attempts`,
+		cardID, maxAttempts,
+	).Scan(&attempts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record PIN failure: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// ResetPINFailures clears the failure count after a correct PIN.
+func (r *repository) ResetPINFailures(ctx context.Context, cardID uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE cards SET pin_failed_attempts = 0 WHERE id = $1 AND pin_failed_attempts > 0`, cardID)
+	if err != nil {
+		return fmt.Errorf("failed to reset PIN attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) UnlockPIN(ctx context.Context, cardID uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE cards
+		SET pin_failed_attempts = 0, pin_locked_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		cardID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unlock PIN: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+
+	return nil
+}
@@ -2,19 +2,28 @@ package card
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/google/uuid"
 
+	"ccards/internal/api/response"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
 	"ccards/pkg/models"
+	"ccards/pkg/vault"
 )
 
 type service struct {
-	repo Repository
+	repo       Repository
+	vault      *vault.Vault
+	cardConfig config.CardConfig
 }
 
-func NewService(repo Repository) Service {
+func NewService(repo Repository, cardVault *vault.Vault, cardConfig config.CardConfig) Service {
 	return &service{
-		repo: repo,
+		repo:       repo,
+		vault:      cardVault,
+		cardConfig: cardConfig,
 	}
 }
 
@@ -26,6 +35,10 @@ func (s *service) GetCardByCompanyIDAndCardID(ctx context.Context, companyID uui
 	return s.repo.GetCardByCompanyIDAndCardID(ctx, companyID, cardID)
 }
 
+func (s *service) GetCardByToken(ctx context.Context, companyID uuid.UUID, token string) (*models.Card, error) {
+	return s.repo.GetCardByToken(ctx, companyID, token)
+}
+
 func (s *service) UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spendingLimit int) (*models.Card, error) {
 	return s.repo.UpdateSpendingLimit(ctx, id, spendingLimit)
 }
@@ -33,3 +46,157 @@ func (s *service) UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spendin
 func (s *service) UpdateSpendingControl(ctx context.Context, cardID uuid.UUID, controlType string, controlValue interface{}) error {
 	return s.repo.UpdateSpendingControl(ctx, cardID, controlType, controlValue)
 }
+
+// RevealCard decrypts a card's PAN and CVV. The reveal is recorded before the
+// secrets are returned, so a reveal that cannot be audited fails.
+func (s *service) RevealCard(ctx context.Context, reveal *models.CardReveal) (*response.CardReveal, error) {
+	card, err := s.repo.GetCardSecret(ctx, reveal.CompanyID, reveal.CardID)
+	if err != nil {
+		return nil, err
+	}
+	if card == nil {
+		return nil, errors.ErrNotFound
+	}
+	if card.Secret == nil {
+		return nil, errors.ErrSecretUnavailable
+	}
+
+	pan, cvv, err := s.vault.OpenCard(card.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt card: %w", err)
+	}
+
+	if err := s.repo.RecordReveal(ctx, reveal); err != nil {
+		return nil, err
+	}
+
+	return &response.CardReveal{
+		CardID:         card.ID,
+		Token:          card.Token,
+		CardHolderName: card.CardHolderName,
+		PAN:            pan,
+		CVV:            cvv,
+		ExpiryDate:     card.ExpiryDate,
+	}, nil
+}
+
+// EncryptStoredCardNumbers encrypts card numbers stored in plaintext before
+// PAN encryption was introduced. Only a hash of those cards' CVVs was ever
+// stored, so their CVV cannot be revealed.
+func (s *service) EncryptStoredCardNumbers(ctx context.Context) (int, error) {
+	return s.repo.EncryptCardNumbers(ctx, func(pan string) (*models.CardSecret, error) {
+		return s.vault.SealCard(pan, "")
+	})
+}
+
+// MigrateCardCVVs stores a CVV MAC for cards encrypted before CVVs were
+// MAC-protected. Cards whose stored CVV is missing or not three digits get a
+// new CVV, which can be read with the reveal endpoint.
+func (s *service) MigrateCardCVVs(ctx context.Context) (int, error) {
+	return s.repo.MigrateCVVs(ctx, func(cardID uuid.UUID, secret *models.CardSecret) (*models.CardSecret, string, error) {
+		pan, cvv, err := s.vault.OpenCard(secret)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decrypt card %s: %w", cardID, err)
+		}
+
+		if !validCVV(cvv) {
+			if cvv, err = vault.NewCVV(); err != nil {
+				return nil, "", err
+			}
+			if secret, err = s.vault.SealCard(pan, cvv); err != nil {
+				return nil, "", err
+			}
+		}
+
+		return secret, s.vault.CVVMAC(cardID, cvv), nil
+	})
+}
+
+func validCVV(cvv string) bool {
+	if len(cvv) != 3 {
+		return false
+	}
+	for _, r := range cvv {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SetPIN sets the PIN of a physical card that does not have one yet.
+func (s *service) SetPIN(ctx context.Context, card *models.Card, pin string) error {
+	if card.CardType != models.CardTypePhysical {
+		return errors.ErrPINNotSupported
+	}
+	if card.PINSet {
+		return errors.ErrPINAlreadySet
+	}
+	if weakPIN(pin) {
+		return errors.ErrWeakPIN
+	}
+
+	err := s.repo.SetPIN(ctx, card.ID, s.vault.PINHash(card.ID, pin), nil)
+	if err == errors.ErrNotFound {
+		return errors.ErrPINAlreadySet
+	}
+	return err
+}
+
+// ChangePIN replaces the card's PIN after checking the current one. A wrong
+// current PIN counts towards the lockout like a wrong PIN at a terminal.
+func (s *service) ChangePIN(ctx context.Context, card *models.Card, currentPIN, newPIN string) error {
+	if card.CardType != models.CardTypePhysical {
+		return errors.ErrPINNotSupported
+	}
+	if !card.PINSet {
+		return errors.ErrPINNotSet
+	}
+	if card.PINLockedAt != nil {
+		return errors.ErrPINLocked
+	}
+
+	if !s.vault.VerifyPIN(card.ID, currentPIN, card.PINHash) {
+		attempts, err := s.repo.RecordPINFailure(ctx, card.ID, s.cardConfig.PINMaxAttempts)
+		if err != nil {
+			return err
+		}
+		if attempts >= s.cardConfig.PINMaxAttempts {
+			return errors.ErrPINLocked
+		}
+		return errors.ErrIncorrectPIN
+	}
+
+	if weakPIN(newPIN) {
+		return errors.ErrWeakPIN
+	}
+
+	currentHash := card.PINHash
+	err := s.repo.SetPIN(ctx, card.ID, s.vault.PINHash(card.ID, newPIN), &currentHash)
+	if err == errors.ErrNotFound {
+		return errors.ErrIncorrectPIN
+	}
+	return err
+}
+
+// UnlockPIN clears a PIN lockout and the failed attempt count.
+func (s *service) UnlockPIN(ctx context.Context, card *models.Card) error {
+	if card.CardType != models.CardTypePhysical {
+		return errors.ErrPINNotSupported
+	}
+
+	return s.repo.UnlockPIN(ctx, card.ID)
+}
+
+// weakPIN reports whether every digit of the PIN is the same, or the digits
+// run up or down in sequence, as in 1111, 1234 or 4321.
+func weakPIN(pin string) bool {
+	same, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		diff := int(pin[i]) - int(pin[i-1])
+		same = same && diff == 0
+		ascending = ascending && diff == 1
+		descending = descending && diff == -1
+	}
+	return same || ascending || descending
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/response"
+	"ccards/pkg/models"
+)
+
+// Columns understood by the card onboarding CSV. They are matched by header
+// name, so they may appear in any order; unknown columns are ignored.
+const (
+	csvColumnEmployeeID     = "employee_id"
+	csvColumnEmployeeEmail  = "employee_email"
+	csvColumnName           = "name"
+	csvColumnCardType       = "card_type"
+	csvColumnSpendingLimit  = "spending_limit"
+	csvColumnDailyLimit     = "daily_limit"
+	csvColumnMonthlyLimit   = "monthly_limit"
+	csvColumnDepartment     = "department"
+	csvColumnInitialFunding = "initial_funding"
+)
+
+const maxCSVTextLength = 255
+
+var requiredCSVColumns = []string{csvColumnEmployeeID, csvColumnEmployeeEmail}
+
+type csvHeader map[string]int
+
+// csvRow is a data line that has been validated on its own but not yet
+// checked against the employee directory or existing cards.
+type csvRow struct {
+	result     response.CSVRowResult
+	employeeID uuid.UUID
+	externalID string
+	card       *models.CardToIssue
+}
+
+func (r *csvRow) reject(format string, args ...interface{}) {
+	r.result.Status = response.CSVRowRejected
+	r.result.Reasons = append(r.result.Reasons, fmt.Sprintf(format, args...))
+}
+
+func (r *csvRow) markDuplicate(format string, args ...interface{}) {
+	r.result.Status = response.CSVRowDuplicate
+	r.result.Reasons = append(r.result.Reasons, fmt.Sprintf(format, args...))
+}
+
+func (r *csvRow) accepted() bool {
+	return r.result.Status == response.CSVRowAccepted
+}
+
+func parseCSVHeader(record []string) (csvHeader, error) {
+	header := make(csvHeader, len(record))
+	for i, name := range record {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "" {
+			continue
+		}
+		if _, exists := header[name]; exists {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		header[name] = i
+	}
+
+	for _, column := range requiredCSVColumns {
+		if _, ok := header[column]; !ok {
+			return nil, fmt.Errorf("missing required column %q", column)
+		}
+	}
+
+	return header, nil
+}
+
+func (h csvHeader) value(record []string, column string) string {
+	i, ok := h[column]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// csvChunkReader reads an onboarding CSV a chunk of rows at a time. It can
+// start from a byte offset inside the file so interrupted imports resume after
+// the last saved chunk, while still reporting absolute line numbers.
+type csvChunkReader struct {
+	reader     *csv.Reader
+	header     csvHeader
+	clientID   uuid.UUID
+	baseOffset int64
+	baseLine   int
+	lastLine   int
+}
+
+// readCSVHeader reads and validates the header line, returning the column
+// mapping together with the byte offset and line where the data starts.
+func readCSVHeader(data io.Reader) (csvHeader, int64, int, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	record, err := reader.Read()
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	header, err := parseCSVHeader(record)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid CSV format: %w", err)
+	}
+
+	return header, reader.InputOffset(), recordEndLine(reader, record), nil
+}
+
+func newCSVChunkReader(data io.Reader, header csvHeader, clientID uuid.UUID, offset int64, line int) *csvChunkReader {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	return &csvChunkReader{
+		reader:     reader,
+		header:     header,
+		clientID:   clientID,
+		baseOffset: offset,
+		baseLine:   line,
+		lastLine:   line,
+	}
+}
+
+// next returns up to n rows. Lines that cannot be parsed or fail validation
+// are returned as rejected rows rather than failing the import. It returns
+// io.EOF once the file is exhausted and no rows were read.
+func (r *csvChunkReader) next(n int) ([]*csvRow, error) {
+	var rows []*csvRow
+	for len(rows) < n {
+		record, err := r.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			row := &csvRow{result: response.CSVRowResult{Line: r.baseLine + parseErr.StartLine}}
+			row.reject("malformed CSV line: %v", parseErr.Err)
+			rows = append(rows, row)
+			r.lastLine = r.baseLine + parseErr.Line
+			continue
+		}
+
+		line, _ := r.reader.FieldPos(0)
+		rows = append(rows, parseCSVRow(r.header, record, r.baseLine+line, r.clientID))
+		r.lastLine = r.baseLine + recordEndLine(r.reader, record)
+	}
+
+	if len(rows) == 0 {
+		return nil, io.EOF
+	}
+
+	return rows, nil
+}
+
+// offset is the position in the file just after the last row returned.
+func (r *csvChunkReader) offset() int64 {
+	return r.baseOffset + r.reader.InputOffset()
+}
+
+// recordEndLine returns the line the record just read ends on, accounting for
+// quoted fields that span several lines.
+func recordEndLine(reader *csv.Reader, record []string) int {
+	line, _ := reader.FieldPos(0)
+	for _, field := range record {
+		line += strings.Count(field, "\n")
+	}
+	return line
+}
+
+func parseCSVRow(header csvHeader, record []string, line int, clientID uuid.UUID) *csvRow {
+	now := time.Now()
+	row := &csvRow{
+		result: response.CSVRowResult{
+			Line:          line,
+			EmployeeID:    header.value(record, csvColumnEmployeeID),
+			EmployeeEmail: header.value(record, csvColumnEmployeeEmail),
+			Status:        response.CSVRowAccepted,
+		},
+		card: &models.CardToIssue{
+			ID:        uuid.New(),
+			ClientID:  clientID,
+			CardID:    uuid.New(),
+			Status:    models.CardToIssueStatusPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+	}
+
+	rawID := row.result.EmployeeID
+	if rawID == "" {
+		row.reject("employee_id is required")
+	} else if id, err := uuid.Parse(rawID); err == nil {
+		row.employeeID = id
+	} else {
+		// Not a UUID, so it has to be resolved through the directory.
+		row.externalID = rawID
+	}
+
+	email := row.result.EmployeeEmail
+	if email == "" {
+		row.reject("employee_email is required")
+	} else if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		row.reject("employee_email %q is not a valid email address", email)
+	}
+	row.card.EmployeeEmail = email
+
+	if name := header.value(record, csvColumnName); name != "" {
+		if len(name) > maxCSVTextLength {
+			row.reject("name must be at most %d characters", maxCSVTextLength)
+		}
+		row.card.CardHolderName = &name
+	}
+
+	if cardType := strings.ToLower(header.value(record, csvColumnCardType)); cardType != "" {
+		if cardType != models.CardTypeVirtual && cardType != models.CardTypePhysical {
+			row.reject("card_type must be %q or %q", models.CardTypeVirtual, models.CardTypePhysical)
+		}
+		row.card.CardType = &cardType
+	}
+
+	if department := header.value(record, csvColumnDepartment); department != "" {
+		if len(department) > maxCSVTextLength {
+			row.reject("department must be at most %d characters", maxCSVTextLength)
+		}
+		row.card.Department = &department
+	}
+
+	row.card.SpendingLimit = parseCSVAmount(row, header.value(record, csvColumnSpendingLimit), csvColumnSpendingLimit, false)
+	row.card.DailyLimit = parseCSVAmount(row, header.value(record, csvColumnDailyLimit), csvColumnDailyLimit, false)
+	row.card.MonthlyLimit = parseCSVAmount(row, header.value(record, csvColumnMonthlyLimit), csvColumnMonthlyLimit, false)
+	row.card.InitialFunding = parseCSVAmount(row, header.value(record, csvColumnInitialFunding), csvColumnInitialFunding, true)
+
+	if row.card.DailyLimit != nil && row.card.MonthlyLimit != nil && *row.card.DailyLimit > *row.card.MonthlyLimit {
+		row.reject("daily_limit cannot exceed monthly_limit")
+	}
+
+	return row
+}
+
+// parseCSVAmount parses an optional money column, recording a rejection
+// reason on the row when the value is invalid.
+func parseCSVAmount(row *csvRow, value, column string, allowZero bool) *float64 {
+	if value == "" {
+		return nil
+	}
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		row.reject("%s %q is not a valid amount", column, value)
+		return nil
+	}
+	if amount < 0 || (amount == 0 && !allowZero) {
+		if allowZero {
+			row.reject("%s cannot be negative", column)
+		} else {
+			row.reject("%s must be greater than zero", column)
+		}
+		return nil
+	}
+	if dot := strings.IndexByte(value, '.'); dot >= 0 && len(value)-dot-1 > 2 {
+		row.reject("%s must have at most two decimal places", column)
+		return nil
+	}
+
+	return &amount
+}
@@ -5,13 +5,16 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"ccards/internal/api/request"
 	"ccards/internal/api/response"
 	"ccards/pkg/errors"
 	"ccards/pkg/middleware"
+	"ccards/pkg/utils"
 )
 
 type Handler struct {
@@ -45,6 +48,35 @@ func (h *Handler) RegisterCompany(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
+// FundCompany tops up a company balance. Cards issued with initial funding
+// draw it from this balance.
+func (h *Handler) FundCompany(c *gin.Context) {
+	companyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid company ID"})
+		return
+	}
+
+	var req request.FundCompany
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	funding, err := h.service.FundCompany(c.Request.Context(), companyID, &req)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Company not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fund company"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, funding)
+}
+
 func (h *Handler) Login(c *gin.Context) {
 	var req request.LoginCompany
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -110,6 +142,7 @@ func (h *Handler) GetCompany(c *gin.Context) {
 		Address:   company.Address,
 		Phone:     company.Phone,
 		Status:    company.Status,
+		Balance:   company.Balance,
 		CreatedAt: company.CreatedAt,
 		UpdatedAt: company.UpdatedAt,
 	}
@@ -117,6 +150,8 @@ func (h *Handler) GetCompany(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// UploadCardCSV streams the uploaded file straight to disk and queues it as a
+// background import job, so large files are never held in memory.
 func (h *Handler) UploadCardCSV(c *gin.Context) {
 	companyID, err := middleware.GetCompanyIDFromContext(c)
 	if err != nil {
@@ -124,31 +159,110 @@ func (h *Handler) UploadCardCSV(c *gin.Context) {
 		return
 	}
 
-	file, header, err := c.Request.FormFile("file")
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get file from request"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request must be a multipart form upload"})
 		return
 	}
-	defer file.Close()
 
-	if !strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File must be a CSV"})
+	dryRun := utils.GetBoolParam(c, "dry_run", false)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get file from request"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		if !strings.HasSuffix(strings.ToLower(part.FileName()), ".csv") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File must be a CSV"})
+			return
+		}
+
+		job, err := h.service.StartCardImport(c.Request.Context(), companyID, part.FileName(), part, dryRun)
+		part.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to process CSV: %v", err)})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "CSV uploaded, import queued",
+			"job":     job,
+		})
 		return
 	}
+}
 
-	fileBytes, err := io.ReadAll(file)
+func (h *Handler) GetImportJob(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	if err := h.service.ProcessCardCSVUpload(c.Request.Context(), companyID, fileBytes); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to process CSV: %v", err)})
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID format"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "CSV uploaded and processed successfully",
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+	offset := (page - 1) * pageSize
+
+	status, err := h.service.GetImportJob(c.Request.Context(), companyID, jobID, pageSize, offset)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve import job"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *Handler) ResumeImportJob(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID format"})
+		return
+	}
+
+	job, err := h.service.ResumeImportJob(c.Request.Context(), companyID, jobID)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		case errors.ErrImportNotResumable:
+			c.JSON(http.StatusConflict, gin.H{"error": "Only failed import jobs can be resumed"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume import job"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Import job resumed",
+		"job":     job,
 	})
 }
 
@@ -178,14 +292,53 @@ func (h *Handler) IssueNewCards(c *gin.Context) {
 		return
 	}
 
-	issuedCount, err := h.service.IssueNewCards(c.Request.Context(), companyID)
+	// The body is optional; an empty request issues every pending card.
+	var req request.IssueCards
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ScheduledFor != nil && req.ScheduledFor.After(time.Now()) {
+		scheduledCount, err := h.service.ScheduleCardIssuance(c.Request.Context(), companyID, req.CardToIssueIDs, *req.ScheduledFor, req.Policy)
+		if err != nil {
+			switch {
+			case errors.Is(err, errors.ErrNotFound):
+				c.JSON(http.StatusOK, gin.H{
+					"message":         "No pending cards to schedule",
+					"scheduled_count": 0,
+				})
+			case errors.Is(err, errors.ErrInvalidPolicy):
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to schedule cards",
+					"details": err.Error(),
+				})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":         fmt.Sprintf("Scheduled %d cards for issuance", scheduledCount),
+			"scheduled_count": scheduledCount,
+			"scheduled_for":   req.ScheduledFor,
+		})
+		return
+	}
+
+	issued, err := h.service.IssueNewCards(c.Request.Context(), companyID, req.CardToIssueIDs, req.Policy)
 	if err != nil {
-		switch err {
-		case errors.ErrNotFound:
+		switch {
+		case errors.Is(err, errors.ErrNotFound):
 			c.JSON(http.StatusOK, gin.H{
 				"message":      "No pending cards to issue",
 				"issued_count": 0,
 			})
+		case errors.Is(err, errors.ErrInvalidPolicy):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errors.ErrInsufficientFunds):
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Company balance does not cover the cards' initial funding"})
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to issue cards",
@@ -195,8 +348,35 @@ func (h *Handler) IssueNewCards(c *gin.Context) {
 		return
 	}
 
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"message":      fmt.Sprintf("Successfully issued %d cards", len(issued)),
+		"issued_count": len(issued),
+		"cards":        issued,
+	})
+}
+
+func (h *Handler) CancelCardsToIssue(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.CancelCardsToIssue
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancelledCount, err := h.service.CancelCardsToIssue(c.Request.Context(), companyID, req.CardToIssueIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel cards"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":      fmt.Sprintf("Successfully issued %d cards", issuedCount),
-		"issued_count": issuedCount,
+		"message":         fmt.Sprintf("Cancelled %d pending cards", cancelledCount),
+		"cancelled_count": cancelledCount,
 	})
 }
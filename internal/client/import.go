@@ -0,0 +1,399 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/response"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+// StartCardImport streams an onboarding CSV to disk and queues it as a
+// background import job. Only the header is checked up front; rows are
+// validated by the worker in chunks.
+func (s *service) StartCardImport(ctx context.Context, clientID uuid.UUID, fileName string, csvData io.Reader, dryRun bool) (*models.ImportJob, error) {
+	if err := os.MkdirAll(s.importConfig.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+
+	job := &models.ImportJob{
+		ID:        uuid.New(),
+		CompanyID: clientID,
+		FileName:  filepath.Base(fileName),
+		DryRun:    dryRun,
+		Status:    models.ImportJobStatusQueued,
+	}
+	job.FilePath = filepath.Join(s.importConfig.Dir, job.ID.String()+".csv")
+
+	size, err := saveImportFile(job.FilePath, csvData)
+	if err != nil {
+		os.Remove(job.FilePath)
+		return nil, err
+	}
+	job.FileSize = size
+
+	if err := checkImportHeader(job.FilePath); err != nil {
+		os.Remove(job.FilePath)
+		return nil, err
+	}
+
+	if err := s.repo.CreateImportJob(ctx, job); err != nil {
+		os.Remove(job.FilePath)
+		return nil, err
+	}
+
+	s.enqueueImportJob(job.ID)
+
+	return job, nil
+}
+
+func (s *service) GetImportJob(ctx context.Context, clientID, jobID uuid.UUID, limit, offset int) (*response.ImportJobStatus, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	job, err := s.repo.GetImportJob(ctx, clientID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	rows, err := s.repo.GetImportJobRows(ctx, job.ID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &response.ImportJobStatus{
+		ImportJob: job,
+		Rows:      make([]response.CSVRowResult, 0, len(rows)),
+	}
+
+	switch {
+	case job.Status == models.ImportJobStatusCompleted:
+		status.Progress = 100
+	case job.FileSize > 0:
+		status.Progress = float64(job.BytesProcessed) * 100 / float64(job.FileSize)
+	}
+
+	for _, row := range rows {
+		status.Rows = append(status.Rows, response.CSVRowResult{
+			Line:          row.Line,
+			EmployeeID:    row.EmployeeID,
+			EmployeeEmail: row.EmployeeEmail,
+			Status:        row.Status,
+			Reasons:       row.Reasons,
+			CardToIssueID: row.CardToIssueID,
+		})
+	}
+
+	return status, nil
+}
+
+// ResumeImportJob queues a failed job again. Processing continues after the
+// last chunk that was saved before the failure.
+func (s *service) ResumeImportJob(ctx context.Context, clientID, jobID uuid.UUID) (*models.ImportJob, error) {
+	job, err := s.repo.GetImportJob(ctx, clientID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.ErrNotFound
+	}
+	if job.Status != models.ImportJobStatusFailed {
+		return nil, errors.ErrImportNotResumable
+	}
+
+	job.Status = models.ImportJobStatusQueued
+	job.Error = nil
+	if err := s.repo.UpdateImportJobStatus(ctx, job); err != nil {
+		return nil, err
+	}
+
+	s.enqueueImportJob(job.ID)
+
+	return job, nil
+}
+
+// importQueueSize bounds the jobs handed to the workers directly. Jobs that
+// do not fit stay queued in the database until the next poll.
+const importQueueSize = 64
+
+// RunImportJob processes a queued job chunk by chunk. Each chunk is saved in
+// its own transaction together with the job's progress. The job is claimed
+// under a lease that is renewed while it runs. If the context is cancelled or
+// the lease is lost the job is left running, and it is queued again once its
+// lease expires; any other error marks it failed so it can be resumed through
+// the API.
+func (s *service) RunImportJob(ctx context.Context, jobID uuid.UUID) error {
+	job, err := s.repo.ClaimImportJob(ctx, jobID, s.importConfig.LeaseDuration)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return nil
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go s.keepImportLease(jobCtx, cancel, job.ID)
+
+	if err := s.processImportJob(jobCtx, job); err != nil {
+		if jobCtx.Err() != nil {
+			return err
+		}
+
+		message := err.Error()
+		job.Status = models.ImportJobStatusFailed
+		job.Error = &message
+		if updateErr := s.repo.UpdateImportJobStatus(ctx, job); updateErr != nil {
+			return fmt.Errorf("import failed: %v (and could not be recorded: %w)", err, updateErr)
+		}
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	now := time.Now()
+	job.Status = models.ImportJobStatusCompleted
+	job.CompletedAt = &now
+	if err := s.repo.UpdateImportJobStatus(ctx, job); err != nil {
+		return err
+	}
+
+	if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove import file %s: %v", job.FilePath, err)
+	}
+
+	return nil
+}
+
+// keepImportLease renews the lease on a running job until ctx is done. When
+// the job is no longer running under this worker it calls cancel so the
+// worker stops at the next chunk.
+func (s *service) keepImportLease(ctx context.Context, cancel context.CancelFunc, jobID uuid.UUID) {
+	interval := s.importConfig.LeaseDuration / 3
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := s.repo.ExtendImportJobLease(ctx, jobID, s.importConfig.LeaseDuration)
+			if err != nil {
+				log.Printf("Warning: failed to extend lease on import job %s: %v", jobID, err)
+				continue
+			}
+			if !held {
+				log.Printf("Import job %s: lease lost, stopping", jobID)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// StartImportWorkers starts the background import workers and a poller that
+// queues running jobs whose lease has expired again and hands queued jobs to
+// the workers. Both stop when ctx is cancelled.
+func (s *service) StartImportWorkers(ctx context.Context) {
+	for i := 0; i < s.importConfig.Workers; i++ {
+		go s.runImportWorker(ctx)
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.importConfig.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.pollImportJobs(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pollImportJobs requeues jobs whose worker stopped and queues every job
+// waiting in the database for the workers.
+func (s *service) pollImportJobs(ctx context.Context) {
+	requeued, err := s.repo.RequeueStaleImportJobs(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to requeue stale import jobs: %v", err)
+	}
+	if requeued > 0 {
+		log.Printf("Requeued %d import jobs with an expired lease", requeued)
+	}
+
+	ids, err := s.repo.GetQueuedImportJobIDs(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to load queued import jobs: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		s.enqueueImportJob(id)
+	}
+}
+
+func (s *service) runImportWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case jobID := <-s.importQueue:
+			if err := s.RunImportJob(ctx, jobID); err != nil {
+				log.Printf("Import job %s: %v", jobID, err)
+			}
+		}
+	}
+}
+
+// enqueueImportJob hands a job to the workers without waiting. When the queue
+// is full the job stays queued in the database and the next poll picks it up;
+// a job handed over twice is only claimed once.
+func (s *service) enqueueImportJob(jobID uuid.UUID) {
+	select {
+	case s.importQueue <- jobID:
+	default:
+	}
+}
+
+func (s *service) processImportJob(ctx context.Context, job *models.ImportJob) error {
+	file, err := os.Open(job.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	header, dataOffset, dataLine, err := readCSVHeader(file)
+	if err != nil {
+		return err
+	}
+
+	if job.BytesProcessed == 0 {
+		job.BytesProcessed = dataOffset
+		job.LastLine = dataLine
+	}
+
+	if _, err := file.Seek(job.BytesProcessed, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek import file: %w", err)
+	}
+
+	reader := newCSVChunkReader(file, header, job.CompanyID, job.BytesProcessed, job.LastLine)
+	firstLine := make(map[uuid.UUID]int)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rows, err := reader.next(s.importConfig.ChunkSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.resolveCSVEmployees(ctx, job.CompanyID, rows); err != nil {
+			return err
+		}
+
+		if err := s.markCSVDuplicates(ctx, job.CompanyID, rows, firstLine); err != nil {
+			return err
+		}
+
+		var cardsToIssue []*models.CardToIssue
+		var reportRows []*models.ImportJobRow
+
+		for _, row := range rows {
+			reportRow := &models.ImportJobRow{
+				JobID:         job.ID,
+				Line:          row.result.Line,
+				EmployeeID:    row.result.EmployeeID,
+				EmployeeEmail: row.result.EmployeeEmail,
+				Status:        row.result.Status,
+				Reasons:       row.result.Reasons,
+			}
+
+			switch row.result.Status {
+			case response.CSVRowAccepted:
+				job.Accepted++
+				// A dry run creates no card, so its accepted rows have none
+				// to point at.
+				if !job.DryRun {
+					row.card.EmployeeID = row.employeeID
+					cardsToIssue = append(cardsToIssue, row.card)
+					reportRow.CardToIssueID = &row.card.ID
+				}
+			case response.CSVRowRejected:
+				job.Rejected++
+			case response.CSVRowDuplicate:
+				job.Duplicates++
+			}
+
+			reportRows = append(reportRows, reportRow)
+		}
+
+		job.TotalRows += len(rows)
+		job.BytesProcessed = reader.offset()
+		job.LastLine = reader.lastLine
+
+		if err := s.repo.SaveImportChunk(ctx, job, cardsToIssue, reportRows); err != nil {
+			return err
+		}
+	}
+}
+
+func saveImportFile(path string, data io.Reader) (int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create import file: %w", err)
+	}
+
+	size, err := io.Copy(file, data)
+	if err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to save import file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to save import file: %w", err)
+	}
+
+	return size, nil
+}
+
+func checkImportHeader(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	_, _, _, err = readCSVHeader(file)
+	return err
+}
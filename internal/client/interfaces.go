@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -10,18 +12,44 @@ import (
 	"ccards/pkg/models"
 )
 
+// CardBuilder builds the cards and spending controls for pending rows that
+// have been locked for issuance.
+type CardBuilder func(ctx context.Context, pending []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, error)
+
 type Repository interface {
 	CreateCompany(ctx context.Context, company *models.Company) error
 	GetCompanyByID(ctx context.Context, id uuid.UUID) (*models.Company, error)
 	GetCompanyByEmail(ctx context.Context, email string) (*models.Company, error)
+	FundCompany(ctx context.Context, funding *models.CompanyFunding) error
 
 	CreateCardsToIssue(ctx context.Context, cards []*models.CardToIssue) error
 	GetCardsToIssueByClientID(ctx context.Context, clientID uuid.UUID) ([]*models.CardToIssue, error)
 	UpdateCardToIssueStatus(ctx context.Context, id uuid.UUID, status string) error
 
 	GetPendingCardsToIssue(ctx context.Context, companyID uuid.UUID) ([]*models.CardToIssue, error)
+	GetDueScheduledCardsToIssue(ctx context.Context, now time.Time) ([]*models.CardToIssue, error)
+	ScheduleCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, scheduledFor time.Time, policy *models.IssuancePolicy) (int, error)
+	CancelCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (int, error)
 	CreateCardsInBatch(ctx context.Context, cards []*models.Card) error
-	UpdateCardsToIssueStatusBatch(ctx context.Context, ids []uuid.UUID, status string) error
+	IssuePendingCards(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, build CardBuilder) (int, error)
+
+	GetPolicyTemplatesByIDs(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*models.CardPolicyTemplate, error)
+	GetDefaultPolicyTemplate(ctx context.Context, companyID uuid.UUID) (*models.CardPolicyTemplate, error)
+
+	GetEmployeesByIDs(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*models.Employee, error)
+	GetEmployeesByExternalIDs(ctx context.Context, companyID uuid.UUID, externalIDs []string) (map[string]*models.Employee, error)
+	GetEmployeeIDsWithCards(ctx context.Context, companyID uuid.UUID, employeeIDs []uuid.UUID) (map[uuid.UUID]bool, error)
+	GetEmployeeIDsPendingIssue(ctx context.Context, clientID uuid.UUID, employeeIDs []uuid.UUID) (map[uuid.UUID]bool, error)
+
+	CreateImportJob(ctx context.Context, job *models.ImportJob) error
+	GetImportJob(ctx context.Context, companyID, id uuid.UUID) (*models.ImportJob, error)
+	ClaimImportJob(ctx context.Context, id uuid.UUID, lease time.Duration) (*models.ImportJob, error)
+	ExtendImportJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) (bool, error)
+	RequeueStaleImportJobs(ctx context.Context) (int64, error)
+	GetQueuedImportJobIDs(ctx context.Context) ([]uuid.UUID, error)
+	SaveImportChunk(ctx context.Context, job *models.ImportJob, cards []*models.CardToIssue, rows []*models.ImportJobRow) error
+	UpdateImportJobStatus(ctx context.Context, job *models.ImportJob) error
+	GetImportJobRows(ctx context.Context, jobID uuid.UUID, limit, offset int) ([]*models.ImportJobRow, error)
 }
 
 type Service interface {
@@ -30,8 +58,17 @@ type Service interface {
 	RefreshToken(ctx context.Context, refreshToken string) (*response.RefreshTokenResponse, error)
 	GetCompanyByID(ctx context.Context, id uuid.UUID) (*models.Company, error)
 	GetCompanyByEmail(ctx context.Context, email string) (*models.Company, error)
-	ProcessCardCSVUpload(ctx context.Context, clientID uuid.UUID, csvData []byte) error
+	FundCompany(ctx context.Context, companyID uuid.UUID, req *request.FundCompany) (*models.CompanyFunding, error)
+	StartCardImport(ctx context.Context, clientID uuid.UUID, fileName string, csvData io.Reader, dryRun bool) (*models.ImportJob, error)
+	GetImportJob(ctx context.Context, clientID, jobID uuid.UUID, limit, offset int) (*response.ImportJobStatus, error)
+	ResumeImportJob(ctx context.Context, clientID, jobID uuid.UUID) (*models.ImportJob, error)
+	RunImportJob(ctx context.Context, jobID uuid.UUID) error
+	StartImportWorkers(ctx context.Context)
 	GetCardsToIssueByClientID(ctx context.Context, clientID uuid.UUID) ([]*models.CardToIssue, error)
 
-	IssueNewCards(ctx context.Context, companyID uuid.UUID) (int, error)
+	IssueNewCards(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, policy *models.IssuancePolicy) ([]*response.IssuedCard, error)
+	ScheduleCardIssuance(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, scheduledFor time.Time, policy *models.IssuancePolicy) (int, error)
+	CancelCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (int, error)
+	IssueScheduledCards(ctx context.Context, now time.Time) (int, error)
+	StartIssuanceScheduler(ctx context.Context, interval time.Duration)
 }
@@ -3,14 +3,19 @@ package client
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 
+	apperrors "ccards/pkg/errors"
 	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+	"ccards/pkg/vault"
 )
 
 type repository struct {
@@ -41,13 +46,13 @@ func (r *repository) CreateCompany(ctx context.Context, company *models.Company)
 func (r *repository) GetCompanyByID(ctx context.Context, id uuid.UUID) (*models.Company, error) {
 	company := &models.Company{}
 	query := `
-		SELECT id, client_id, name, email, address, phone, status, created_at, updated_at
+		SELECT id, client_id, name, email, address, phone, status, balance, created_at, updated_at
 		FROM companies
 		WHERE id = $1`
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&company.ID, &company.ClientID, &company.Name, &company.Email, &company.Address, &company.Phone, &company.Status,
-		&company.CreatedAt, &company.UpdatedAt,
+		&company.Balance, &company.CreatedAt, &company.UpdatedAt,
 	)
 
 	if err != nil {
@@ -82,54 +87,99 @@ func (r *repository) GetCompanyByEmail(ctx context.Context, email string) (*mode
 	return company, nil
 }
 
-func (r *repository) CreateCardsToIssue(ctx context.Context, cards []*models.CardToIssue) error {
-	if len(cards) == 0 {
-		return nil
+// FundCompany credits the company balance and records the top-up in the same
+// transaction. It returns ErrNotFound when the company does not exist.
+func (r *repository) FundCompany(ctx context.Context, funding *models.CompanyFunding) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	query := `
-        INSERT INTO cards_to_issue (
-            id, client_id, card_id, employee_id, employee_email, status, created_at, updated_at
-        ) VALUES `
+	err = tx.QueryRowContext(ctx, `
+		UPDATE companies
+		SET balance = balance + $2
+		WHERE id = $1
+		RETURNING balance`,
+		funding.CompanyID, funding.Amount,
+	).Scan(&funding.Balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrNotFound
+		}
+		return fmt.Errorf("failed to credit company balance: %w", err)
+	}
 
-	values := make([]string, 0, len(cards))
-	args := make([]interface{}, 0, len(cards)*8)
-
-	for i, card := range cards {
-		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			i*8+1, i*8+2, i*8+3, i*8+4, i*8+5, i*8+6, i*8+7, i*8+8))
-
-		args = append(args,
-			card.ID,
-			card.ClientID,
-			card.CardID,
-			card.EmployeeID,
-			card.EmployeeEmail,
-			card.Status,
-			card.CreatedAt,
-			card.UpdatedAt,
-		)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO company_fundings (id, company_id, amount, reference, balance_after)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING created_at`,
+		funding.ID, funding.CompanyID, funding.Amount, funding.Reference, funding.Balance,
+	).Scan(&funding.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record company funding: %w", err)
 	}
 
-	query += strings.Join(values, ", ")
+	return tx.Commit()
+}
 
-	_, err := r.db.ExecContext(ctx, query, args...)
+const cardToIssueColumns = `
+	id, client_id, card_id, employee_id, employee_email, card_holder_name, card_type,
+	spending_limit, daily_limit, monthly_limit, department, initial_funding,
+	scheduled_for, policy, status, created_at, updated_at`
+
+func scanCardToIssue(row models.RowScanner, card *models.CardToIssue) error {
+	var policyJSON []byte
+	err := row.Scan(
+		&card.ID,
+		&card.ClientID,
+		&card.CardID,
+		&card.EmployeeID,
+		&card.EmployeeEmail,
+		&card.CardHolderName,
+		&card.CardType,
+		&card.SpendingLimit,
+		&card.DailyLimit,
+		&card.MonthlyLimit,
+		&card.Department,
+		&card.InitialFunding,
+		&card.ScheduledFor,
+		&policyJSON,
+		&card.Status,
+		&card.CreatedAt,
+		&card.UpdatedAt,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to create cards to issue: %w", err)
+		return err
+	}
+
+	if policyJSON != nil {
+		card.Policy = &models.IssuancePolicy{}
+		if err := json.Unmarshal(policyJSON, card.Policy); err != nil {
+			return fmt.Errorf("failed to decode issuance policy: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (r *repository) GetCardsToIssueByClientID(ctx context.Context, clientID uuid.UUID) ([]*models.CardToIssue, error) {
-	query := `
-        SELECT id, client_id, card_id, employee_id, employee_email, status, created_at, updated_at
-        FROM cards_to_issue
-        WHERE client_id = $1
-        ORDER BY created_at DESC
-    `
+// policyValue encodes an issuance policy for a JSONB column, mapping a nil
+// policy to NULL.
+func policyValue(policy *models.IssuancePolicy) (interface{}, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode issuance policy: %w", err)
+	}
+
+	return data, nil
+}
 
-	rows, err := r.db.QueryContext(ctx, query, clientID)
+func (r *repository) queryCardsToIssue(ctx context.Context, query string, args ...interface{}) ([]*models.CardToIssue, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get cards to issue: %w", err)
 	}
@@ -138,17 +188,7 @@ func (r *repository) GetCardsToIssueByClientID(ctx context.Context, clientID uui
 	var cards []*models.CardToIssue
 	for rows.Next() {
 		card := &models.CardToIssue{}
-		err := rows.Scan(
-			&card.ID,
-			&card.ClientID,
-			&card.CardID,
-			&card.EmployeeID,
-			&card.EmployeeEmail,
-			&card.Status,
-			&card.CreatedAt,
-			&card.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanCardToIssue(rows, card); err != nil {
 			return nil, fmt.Errorf("failed to scan card to issue: %w", err)
 		}
 		cards = append(cards, card)
@@ -161,6 +201,103 @@ func (r *repository) GetCardsToIssueByClientID(ctx context.Context, clientID uui
 	return cards, nil
 }
 
+// Postgres accepts at most 65535 bind parameters per statement, so multi-row
+// inserts are split into batches well below that limit.
+const (
+	cardsToIssueBatchSize = 1000
+	cardsBatchSize        = 1000
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *repository) CreateCardsToIssue(ctx context.Context, cards []*models.CardToIssue) error {
+	if len(cards) <= cardsToIssueBatchSize {
+		return insertCardsToIssue(ctx, r.db, cards)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertCardsToIssue(ctx, tx, cards); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertCardsToIssue(ctx context.Context, exec execer, cards []*models.CardToIssue) error {
+	const columnCount = 17
+
+	for start := 0; start < len(cards); start += cardsToIssueBatchSize {
+		end := start + cardsToIssueBatchSize
+		if end > len(cards) {
+			end = len(cards)
+		}
+		batch := cards[start:end]
+
+		query := `INSERT INTO cards_to_issue (` + cardToIssueColumns + `) VALUES `
+		values := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, len(batch)*columnCount)
+
+		for i, card := range batch {
+			placeholders := make([]string, columnCount)
+			for j := range placeholders {
+				placeholders[j] = fmt.Sprintf("$%d", i*columnCount+j+1)
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+
+			policy, err := policyValue(card.Policy)
+			if err != nil {
+				return err
+			}
+
+			args = append(args,
+				card.ID,
+				card.ClientID,
+				card.CardID,
+				card.EmployeeID,
+				card.EmployeeEmail,
+				card.CardHolderName,
+				card.CardType,
+				card.SpendingLimit,
+				card.DailyLimit,
+				card.MonthlyLimit,
+				card.Department,
+				card.InitialFunding,
+				card.ScheduledFor,
+				policy,
+				card.Status,
+				card.CreatedAt,
+				card.UpdatedAt,
+			)
+		}
+
+		query += strings.Join(values, ", ")
+
+		if _, err := exec.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to create cards to issue: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *repository) GetCardsToIssueByClientID(ctx context.Context, clientID uuid.UUID) ([]*models.CardToIssue, error) {
+	query := `
+        SELECT ` + cardToIssueColumns + `
+        FROM cards_to_issue
+        WHERE client_id = $1
+        ORDER BY created_at DESC
+    `
+
+	return r.queryCardsToIssue(ctx, query, clientID)
+}
+
 func (r *repository) UpdateCardToIssueStatus(ctx context.Context, id uuid.UUID, status string) error {
 	query := `
         UPDATE cards_to_issue
@@ -185,46 +322,107 @@ func (r *repository) UpdateCardToIssueStatus(ctx context.Context, id uuid.UUID,
 	return nil
 }
 
+// GetPendingCardsToIssue returns the pending rows that are ready to be issued,
+// leaving out rows scheduled for a later date.
 func (r *repository) GetPendingCardsToIssue(ctx context.Context, companyID uuid.UUID) ([]*models.CardToIssue, error) {
 	query := `
-        SELECT id, client_id, card_id, employee_id, employee_email, status, created_at, updated_at
+        SELECT ` + cardToIssueColumns + `
         FROM cards_to_issue
         WHERE client_id = $1 AND status = $2
+          AND (scheduled_for IS NULL OR scheduled_for <= CURRENT_TIMESTAMP)
         ORDER BY created_at ASC
     `
 
-	var cards []*models.CardToIssue
-	rows, err := r.db.QueryContext(ctx, query, companyID, models.CardToIssueStatusPending)
+	return r.queryCardsToIssue(ctx, query, companyID, models.CardToIssueStatusPending)
+}
+
+// GetDueScheduledCardsToIssue returns pending rows of every company whose
+// scheduled issuance date has been reached.
+func (r *repository) GetDueScheduledCardsToIssue(ctx context.Context, now time.Time) ([]*models.CardToIssue, error) {
+	query := `
+        SELECT ` + cardToIssueColumns + `
+        FROM cards_to_issue
+        WHERE status = $1 AND scheduled_for IS NOT NULL AND scheduled_for <= $2
+        ORDER BY client_id, scheduled_for ASC
+    `
+
+	return r.queryCardsToIssue(ctx, query, models.CardToIssueStatusPending, now)
+}
+
+// ScheduleCardsToIssue sets the issuance date and policy of pending rows. With
+// no IDs every pending row of the company is scheduled.
+func (r *repository) ScheduleCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, scheduledFor time.Time, policy *models.IssuancePolicy) (int, error) {
+	policyJSON, err := policyValue(policy)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pending cards: %w", err)
+		return 0, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		card := &models.CardToIssue{}
-		err := rows.Scan(
-			&card.ID,
-			&card.ClientID,
-			&card.CardID,
-			&card.EmployeeID,
-			&card.EmployeeEmail,
-			&card.Status,
-			&card.CreatedAt,
-			&card.UpdatedAt,
-		)
+	query := `
+        UPDATE cards_to_issue
+        SET scheduled_for = $3, policy = $4, updated_at = CURRENT_TIMESTAMP
+        WHERE client_id = $1 AND status = $2
+    `
+	args := []interface{}{companyID, models.CardToIssueStatusPending, scheduledFor, policyJSON}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($5)`
+		args = append(args, pq.Array(ids))
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to schedule cards to issue: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+func (r *repository) CancelCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (int, error) {
+	query := `
+        UPDATE cards_to_issue
+        SET status = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE client_id = $1 AND status = $2 AND id = ANY($4)
+    `
+
+	result, err := r.db.ExecContext(ctx, query, companyID, models.CardToIssueStatusPending, models.CardToIssueStatusCancelled, pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel cards to issue: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+func insertSpendingControls(ctx context.Context, tx *sql.Tx, controls []*models.SpendingControl) error {
+	query := `
+        INSERT INTO spending_controls (card_id, control_type, control_value, is_active, policy_template_id)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+
+	for _, control := range controls {
+		value, err := json.Marshal(control.ControlValue)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan card to issue: %w", err)
+			return fmt.Errorf("failed to marshal control value: %w", err)
 		}
-		cards = append(cards, card)
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over rows: %w", err)
+		if _, err := tx.ExecContext(ctx, query, control.CardID, control.ControlType, value, control.IsActive, control.PolicyTemplateID); err != nil {
+			return fmt.Errorf("failed to insert spending control: %w", err)
+		}
 	}
 
-	return cards, nil
+	return nil
 }
 
+// CreateCardsInBatch inserts cards in one transaction, the same way
+// IssuePendingCards does, without touching cards_to_issue.
 func (r *repository) CreateCardsInBatch(ctx context.Context, cards []*models.Card) error {
 	if len(cards) == 0 {
 		return nil
@@ -237,6 +435,14 @@ func (r *repository) CreateCardsInBatch(ctx context.Context, cards []*models.Car
 	}
 	defer tx.Rollback()
 
+	if err := insertCards(ctx, tx, cards); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertCards(ctx context.Context, tx *sql.Tx, cards []*models.Card) error {
 	// Collect unique company IDs to check for existence
 	companyIDs := make(map[uuid.UUID]bool)
 	for _, card := range cards {
@@ -273,69 +479,677 @@ func (r *repository) CreateCardsInBatch(ctx context.Context, cards []*models.Car
 		}
 	}
 
-	// Prepare batch insert
-	query := `
+	const columnCount = 24
+
+	for start := 0; start < len(cards); start += cardsBatchSize {
+		end := start + cardsBatchSize
+		if end > len(cards) {
+			end = len(cards)
+		}
+		batch := cards[start:end]
+
+		query := `
         INSERT INTO cards (
-            id, company_id, card_number, card_holder_name, employee_id, employee_email,
+            id, company_id, token, card_holder_name, employee_id, employee_email, employee_ref_id, department,
             card_type, status, balance, spending_limit, daily_limit, monthly_limit,
-            expiry_date, cvv_hash, last_four, created_at, updated_at
+            expiry_date, cvv_mac, last_four, created_at, updated_at, policy_template_id,
+            secret_key_id, secret_data_key, secret_ciphertext, pan_fingerprint
         ) VALUES `
 
-	values := make([]string, 0, len(cards))
-	args := make([]interface{}, 0, len(cards)*17)
-
-	for i, card := range cards {
-		values = append(values, fmt.Sprintf(
-			"($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			i*17+1, i*17+2, i*17+3, i*17+4, i*17+5, i*17+6, i*17+7, i*17+8, i*17+9,
-			i*17+10, i*17+11, i*17+12, i*17+13, i*17+14, i*17+15, i*17+16, i*17+17,
-		))
-
-		args = append(args,
-			card.ID,
-			card.CompanyID,
-			card.CardNumber,
-			card.CardHolderName,
-			card.EmployeeID,
-			card.EmployeeEmail,
-			card.CardType,
-			card.Status,
-			card.Balance,
-			card.SpendingLimit,
-			card.DailyLimit,
-			card.MonthlyLimit,
-			card.ExpiryDate,
-			card.CVVHash,
-			card.LastFour,
-			card.CreatedAt,
-			card.UpdatedAt,
+		values := make([]string, 0, len(batch))
+		args := make([]interface{}, 0, len(batch)*columnCount)
+
+		for i, card := range batch {
+			placeholders := make([]string, columnCount)
+			for j := range placeholders {
+				placeholders[j] = fmt.Sprintf("$%d", i*columnCount+j+1)
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+
+			if card.Token == "" {
+				card.Token = vault.NewToken()
+			}
+
+			var keyID, fingerprint *string
+			var dataKey, ciphertext []byte
+			if card.Secret != nil {
+				keyID = &card.Secret.KeyID
+				fingerprint = &card.Secret.Fingerprint
+				dataKey = card.Secret.DataKey
+				ciphertext = card.Secret.Ciphertext
+			}
+
+			args = append(args,
+				card.ID,
+				card.CompanyID,
+				card.Token,
+				card.CardHolderName,
+				card.EmployeeID,
+				card.EmployeeEmail,
+				card.EmployeeRefID,
+				card.Department,
+				card.CardType,
+				card.Status,
+				card.Balance,
+				card.SpendingLimit,
+				card.DailyLimit,
+				card.MonthlyLimit,
+				card.ExpiryDate,
+				card.CVVMAC,
+				card.LastFour,
+				card.CreatedAt,
+				card.UpdatedAt,
+				card.PolicyTemplateID,
+				keyID,
+				dataKey,
+				ciphertext,
+				fingerprint,
+			)
+		}
+
+		query += strings.Join(values, ", ")
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert cards: %w", err)
+		}
+
+		events := make([]*outbox.Event, 0, len(batch))
+		for _, card := range batch {
+			events = append(events, &outbox.Event{
+				CompanyID: card.CompanyID,
+				Type:      models.EventCardIssued,
+				Data: models.CardIssuedEvent{
+					CardID:         card.ID,
+					CardType:       card.CardType,
+					CardHolderName: card.CardHolderName,
+					EmployeeID:     card.EmployeeID,
+					EmployeeEmail:  card.EmployeeEmail,
+					LastFour:       card.LastFour,
+					ExpiryDate:     card.ExpiryDate,
+				},
+			})
+		}
+		if err := outbox.Enqueue(ctx, tx, events...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IssuePendingCards issues pending cards in a single transaction. The pending
+// rows are locked with FOR UPDATE SKIP LOCKED, so concurrent calls never pick
+// up the same rows, and rows issued by an earlier call are no longer pending.
+// The cards, their spending controls and the cards_to_issue status change are
+// committed together or not at all. With no IDs every due pending row of the
+// company is issued. It returns the number of cards issued.
+func (r *repository) IssuePendingCards(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, build CardBuilder) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT ` + cardToIssueColumns + `
+        FROM cards_to_issue
+        WHERE client_id = $1 AND status = $2`
+	args := []interface{}{companyID, models.CardToIssueStatusPending}
+	if len(ids) > 0 {
+		query += ` AND id = ANY($3)`
+		args = append(args, pq.Array(ids))
+	} else {
+		query += ` AND (scheduled_for IS NULL OR scheduled_for <= CURRENT_TIMESTAMP)`
+	}
+	query += `
+        ORDER BY created_at ASC
+        FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch pending cards: %w", err)
+	}
+
+	var pending []*models.CardToIssue
+	for rows.Next() {
+		card := &models.CardToIssue{}
+		if err := scanCardToIssue(rows, card); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan card to issue: %w", err)
+		}
+		pending = append(pending, card)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to fetch pending cards: %w", err)
+	}
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	cards, controls, err := build(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := insertCards(ctx, tx, cards); err != nil {
+		return 0, fmt.Errorf("failed to create cards: %w", err)
+	}
+
+	if err := fundCards(ctx, tx, companyID, cards); err != nil {
+		return 0, err
+	}
+
+	if err := insertSpendingControls(ctx, tx, controls); err != nil {
+		return 0, fmt.Errorf("failed to create spending controls: %w", err)
+	}
+
+	issuedIDs := make([]uuid.UUID, 0, len(pending))
+	for _, card := range pending {
+		issuedIDs = append(issuedIDs, card.ID)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+        UPDATE cards_to_issue
+        SET status = $1, updated_at = CURRENT_TIMESTAMP
+        WHERE id = ANY($2) AND status = $3`,
+		models.CardToIssueStatusGenerated, pq.Array(issuedIDs), models.CardToIssueStatusPending,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update status: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if int(updated) != len(pending) {
+		return 0, fmt.Errorf("failed to update status: %d of %d cards to issue updated", updated, len(pending))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(cards), nil
+}
+
+// fundCards moves the initial balance of new cards from the company balance
+// and records a funding transaction for each funded card. It returns
+// ErrInsufficientFunds when the company balance does not cover them all.
+func fundCards(ctx context.Context, tx *sql.Tx, companyID uuid.UUID, cards []*models.Card) error {
+	total := 0.0
+	for _, card := range cards {
+		total += card.Balance
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	result, err := tx.ExecContext(ctx, `
+        UPDATE companies
+        SET balance = balance - $2
+        WHERE id = $1 AND balance >= $2`,
+		companyID, total,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to debit company balance: %w", err)
+	}
+
+	debited, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if debited == 0 {
+		return apperrors.ErrInsufficientFunds
+	}
+
+	for _, card := range cards {
+		if card.Balance <= 0 {
+			continue
+		}
+
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO transactions (id, card_id, company_id, transaction_type, amount, description, status, processed_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)`,
+			uuid.New(), card.ID, companyID, models.TransactionTypeFunding, card.Balance,
+			"Initial card funding", models.TransactionStatusCompleted,
 		)
+		if err != nil {
+			return fmt.Errorf("failed to record card funding: %w", err)
+		}
 	}
 
-	query += strings.Join(values, ", ")
+	return nil
+}
 
-	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
-		return fmt.Errorf("failed to insert cards: %w", err)
+func (r *repository) GetPolicyTemplatesByIDs(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*models.CardPolicyTemplate, error) {
+	templates := make(map[uuid.UUID]*models.CardPolicyTemplate)
+	if len(ids) == 0 {
+		return templates, nil
 	}
 
-	return tx.Commit()
+	query := `
+        SELECT ` + models.PolicyTemplateColumns + `
+        FROM card_policy_templates
+        WHERE company_id = $1 AND id = ANY($2)
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, companyID, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy templates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		template := &models.CardPolicyTemplate{}
+		if err := models.ScanPolicyTemplate(rows, template); err != nil {
+			return nil, fmt.Errorf("failed to scan policy template: %w", err)
+		}
+		templates[template.ID] = template
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return templates, nil
+}
+
+func (r *repository) GetDefaultPolicyTemplate(ctx context.Context, companyID uuid.UUID) (*models.CardPolicyTemplate, error) {
+	query := `
+        SELECT ` + models.PolicyTemplateColumns + `
+        FROM card_policy_templates
+        WHERE company_id = $1 AND is_default
+    `
+
+	template := &models.CardPolicyTemplate{}
+	err := models.ScanPolicyTemplate(r.db.QueryRowContext(ctx, query, companyID), template)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get default policy template: %w", err)
+	}
+
+	return template, nil
 }
 
-func (r *repository) UpdateCardsToIssueStatusBatch(ctx context.Context, ids []uuid.UUID, status string) error {
+func (r *repository) GetEmployeesByIDs(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*models.Employee, error) {
+	employees := make(map[uuid.UUID]*models.Employee)
 	if len(ids) == 0 {
-		return nil
+		return employees, nil
+	}
+
+	found, err := r.queryEmployees(ctx, `WHERE company_id = $1 AND id = ANY($2)`, companyID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, employee := range found {
+		employees[employee.ID] = employee
+	}
+
+	return employees, nil
+}
+
+func (r *repository) GetEmployeesByExternalIDs(ctx context.Context, companyID uuid.UUID, externalIDs []string) (map[string]*models.Employee, error) {
+	employees := make(map[string]*models.Employee)
+	if len(externalIDs) == 0 {
+		return employees, nil
+	}
+
+	found, err := r.queryEmployees(ctx, `WHERE company_id = $1 AND external_id = ANY($2)`, companyID, pq.Array(externalIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, employee := range found {
+		employees[*employee.ExternalID] = employee
 	}
 
+	return employees, nil
+}
+
+func (r *repository) queryEmployees(ctx context.Context, where string, args ...interface{}) ([]*models.Employee, error) {
 	query := `
-        UPDATE cards_to_issue
-        SET status = $1, updated_at = CURRENT_TIMESTAMP
-        WHERE id = ANY($2)
+        SELECT id, company_id, external_id, email, name, department, cost_center, manager_id, status, created_at, updated_at
+        FROM employees
+        ` + where
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get employees: %w", err)
+	}
+	defer rows.Close()
+
+	var employees []*models.Employee
+	for rows.Next() {
+		employee := &models.Employee{}
+		err := rows.Scan(
+			&employee.ID,
+			&employee.CompanyID,
+			&employee.ExternalID,
+			&employee.Email,
+			&employee.Name,
+			&employee.Department,
+			&employee.CostCenter,
+			&employee.ManagerID,
+			&employee.Status,
+			&employee.CreatedAt,
+			&employee.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan employee: %w", err)
+		}
+		employees = append(employees, employee)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return employees, nil
+}
+
+// GetEmployeeIDsWithCards returns which of the given employees already hold a
+// card that has not been cancelled.
+func (r *repository) GetEmployeeIDsWithCards(ctx context.Context, companyID uuid.UUID, employeeIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	holders := make(map[uuid.UUID]bool)
+	if len(employeeIDs) == 0 {
+		return holders, nil
+	}
+
+	textIDs := make([]string, len(employeeIDs))
+	for i, id := range employeeIDs {
+		textIDs[i] = id.String()
+	}
+
+	query := `
+        SELECT COALESCE(employee_ref_id::text, employee_id)
+        FROM cards
+        WHERE company_id = $1
+          AND status <> $2
+          AND (employee_ref_id = ANY($3) OR employee_id = ANY($4))
     `
 
-	_, err := r.db.ExecContext(ctx, query, status, pq.Array(ids))
+	rows, err := r.db.QueryContext(ctx, query, companyID, models.CardStatusCancelled, pq.Array(employeeIDs), pq.Array(textIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card holders: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rawID string
+		if err := rows.Scan(&rawID); err != nil {
+			return nil, fmt.Errorf("failed to scan card holder: %w", err)
+		}
+		if id, err := uuid.Parse(rawID); err == nil {
+			holders[id] = true
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return holders, nil
+}
+
+// GetEmployeeIDsPendingIssue returns which of the given employees already have
+// a card waiting to be issued.
+func (r *repository) GetEmployeeIDsPendingIssue(ctx context.Context, clientID uuid.UUID, employeeIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	pending := make(map[uuid.UUID]bool)
+	if len(employeeIDs) == 0 {
+		return pending, nil
+	}
+
+	query := `
+        SELECT DISTINCT employee_id
+        FROM cards_to_issue
+        WHERE client_id = $1 AND status = $2 AND employee_id = ANY($3)
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, clientID, models.CardToIssueStatusPending, pq.Array(employeeIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending cards: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan employee ID: %w", err)
+		}
+		pending[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return pending, nil
+}
+
+const importJobColumns = `
+	id, company_id, file_name, file_path, file_size, dry_run, status, bytes_processed, last_line,
+	total_rows, accepted, rejected, duplicates, error, started_at, completed_at, created_at, updated_at`
+
+func scanImportJob(row models.RowScanner, job *models.ImportJob) error {
+	return row.Scan(
+		&job.ID, &job.CompanyID, &job.FileName, &job.FilePath, &job.FileSize, &job.DryRun, &job.Status,
+		&job.BytesProcessed, &job.LastLine, &job.TotalRows, &job.Accepted, &job.Rejected, &job.Duplicates,
+		&job.Error, &job.StartedAt, &job.CompletedAt, &job.CreatedAt, &job.UpdatedAt,
+	)
+}
+
+func (r *repository) CreateImportJob(ctx context.Context, job *models.ImportJob) error {
+	query := `
+		INSERT INTO import_jobs (id, company_id, file_name, file_path, file_size, dry_run, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		job.ID, job.CompanyID, job.FileName, job.FilePath, job.FileSize, job.DryRun, job.Status,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+		return fmt.Errorf("failed to create import job: %w", err)
 	}
 
 	return nil
 }
+
+func (r *repository) GetImportJob(ctx context.Context, companyID, id uuid.UUID) (*models.ImportJob, error) {
+	query := `SELECT ` + importJobColumns + ` FROM import_jobs WHERE id = $1 AND company_id = $2`
+
+	job := &models.ImportJob{}
+	if err := scanImportJob(r.db.QueryRowContext(ctx, query, id, companyID), job); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ClaimImportJob marks a queued job as running and returns it. Jobs left
+// running by a previous process can be claimed again so they resume. It
+// returns nil when the job is not waiting to be processed.
+// ClaimImportJob moves a queued job to running under a lease that runs out
+// after lease. It returns nil when the job is not queued, so a job is only
+// ever run by the worker that claimed it.
+func (r *repository) ClaimImportJob(ctx context.Context, id uuid.UUID, lease time.Duration) (*models.ImportJob, error) {
+	query := `
+		UPDATE import_jobs
+		SET status = $2, started_at = COALESCE(started_at, CURRENT_TIMESTAMP), error = NULL,
+			lease_expires_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 millisecond'
+		WHERE id = $1 AND status = $3
+		RETURNING ` + importJobColumns
+
+	job := &models.ImportJob{}
+	err := scanImportJob(r.db.QueryRowContext(ctx, query, id, models.ImportJobStatusRunning, models.ImportJobStatusQueued, lease.Milliseconds()), job)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim import job: %w", err)
+	}
+
+	return job, nil
+}
+
+// ExtendImportJobLease renews the lease on a running job. It reports false
+// when the job is no longer running, for example because its lease expired
+// and it was queued again.
+func (r *repository) ExtendImportJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) (bool, error) {
+	query := `
+		UPDATE import_jobs
+		SET lease_expires_at = CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond'
+		WHERE id = $1 AND status = $2`
+
+	result, err := r.db.ExecContext(ctx, query, id, models.ImportJobStatusRunning, lease.Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to extend import job lease: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// RequeueStaleImportJobs queues running jobs whose lease has expired again,
+// so another worker picks them up after the last saved chunk.
+func (r *repository) RequeueStaleImportJobs(ctx context.Context) (int64, error) {
+	query := `
+		UPDATE import_jobs
+		SET status = $2, lease_expires_at = NULL
+		WHERE status = $1 AND (lease_expires_at IS NULL OR lease_expires_at < CURRENT_TIMESTAMP)`
+
+	result, err := r.db.ExecContext(ctx, query, models.ImportJobStatusRunning, models.ImportJobStatusQueued)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue stale import jobs: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+func (r *repository) GetQueuedImportJobIDs(ctx context.Context) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM import_jobs
+		WHERE status = $1
+		ORDER BY created_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, models.ImportJobStatusQueued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queued import jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan import job ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return ids, nil
+}
+
+// SaveImportChunk stores the outcome of one chunk of an import in a single
+// transaction: the accepted cards, the report row of every line, and the
+// job's progress. A job interrupted between chunks therefore resumes exactly
+// after the last saved chunk.
+func (r *repository) SaveImportChunk(ctx context.Context, job *models.ImportJob, cards []*models.CardToIssue, rows []*models.ImportJobRow) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertCardsToIssue(ctx, tx, cards); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO import_job_rows (job_id, line, employee_id, employee_email, status, reasons, card_to_issue_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (job_id, line) DO NOTHING`,
+			row.JobID, row.Line, row.EmployeeID, row.EmployeeEmail, row.Status, pq.Array(row.Reasons), row.CardToIssueID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record import row: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE import_jobs
+		SET bytes_processed = $2, last_line = $3, total_rows = $4, accepted = $5, rejected = $6, duplicates = $7
+		WHERE id = $1`,
+		job.ID, job.BytesProcessed, job.LastLine, job.TotalRows, job.Accepted, job.Rejected, job.Duplicates,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update import progress: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) UpdateImportJobStatus(ctx context.Context, job *models.ImportJob) error {
+	query := `
+		UPDATE import_jobs
+		SET status = $2, error = $3, completed_at = $4, lease_expires_at = NULL
+		WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, job.ID, job.Status, job.Error, job.CompletedAt); err != nil {
+		return fmt.Errorf("failed to update import job status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetImportJobRows(ctx context.Context, jobID uuid.UUID, limit, offset int) ([]*models.ImportJobRow, error) {
+	query := `
+		SELECT job_id, line, COALESCE(employee_id, ''), COALESCE(employee_email, ''), status, reasons, card_to_issue_id
+		FROM import_job_rows
+		WHERE job_id = $1
+		ORDER BY line ASC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, jobID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import rows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.ImportJobRow
+	for rows.Next() {
+		row := &models.ImportJobRow{}
+		if err := rows.Scan(&row.JobID, &row.Line, &row.EmployeeID, &row.EmployeeEmail, &row.Status, pq.Array(&row.Reasons), &row.CardToIssueID); err != nil {
+			return nil, fmt.Errorf("failed to scan import row: %w", err)
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return result, nil
+}
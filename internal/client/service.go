@@ -1,14 +1,10 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
-	"encoding/csv"
-	"encoding/hex"
 	"fmt"
-	"io"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,19 +16,27 @@ import (
 	"ccards/pkg/errors"
 	"ccards/pkg/models"
 	"ccards/pkg/utils"
+	"ccards/pkg/validation"
+	"ccards/pkg/vault"
 )
 
 type service struct {
-	repo      Repository
-	jwtConfig config.JWTConfig
-	redis     *redis.Client
+	repo         Repository
+	jwtConfig    config.JWTConfig
+	importConfig config.ImportConfig
+	importQueue  chan uuid.UUID
+	vault        *vault.Vault
+	redis        *redis.Client
 }
 
-func NewService(repo Repository, jwtConfig config.JWTConfig, redis *redis.Client) Service {
+func NewService(repo Repository, jwtConfig config.JWTConfig, importConfig config.ImportConfig, cardVault *vault.Vault, redis *redis.Client) Service {
 	return &service{
-		repo:      repo,
-		jwtConfig: jwtConfig,
-		redis:     redis,
+		repo:         repo,
+		jwtConfig:    jwtConfig,
+		importConfig: importConfig,
+		importQueue:  make(chan uuid.UUID, importQueueSize),
+		vault:        cardVault,
+		redis:        redis,
 	}
 }
 
@@ -152,72 +156,99 @@ func (s *service) GetCompanyByEmail(ctx context.Context, email string) (*models.
 	return s.repo.GetCompanyByEmail(ctx, email)
 }
 
-func (s *service) ProcessCardCSVUpload(ctx context.Context, clientID uuid.UUID, csvData []byte) error {
-	reader := csv.NewReader(bytes.NewReader(csvData))
-
-	header, err := reader.Read()
-	if err != nil {
-		return fmt.Errorf("failed to read CSV header: %w", err)
+// FundCompany tops up the company balance that card initial funding is drawn
+// from.
+func (s *service) FundCompany(ctx context.Context, companyID uuid.UUID, req *request.FundCompany) (*models.CompanyFunding, error) {
+	funding := &models.CompanyFunding{
+		ID:        uuid.New(),
+		CompanyID: companyID,
+		Amount:    req.Amount,
+		Reference: req.Reference,
 	}
 
-	expectedHeaders := []string{"employee_id", "employee_email"}
-	if len(header) < len(expectedHeaders) {
-		return fmt.Errorf("invalid CSV format: expected at least %d columns", len(expectedHeaders))
+	if err := s.repo.FundCompany(ctx, funding); err != nil {
+		return nil, err
 	}
 
-	for i, expectedHeader := range expectedHeaders {
-		if header[i] != expectedHeader {
-			return fmt.Errorf("invalid CSV format: expected header %q at position %d, got %q", expectedHeader, i, header[i])
+	return funding, nil
+}
+
+// resolveCSVEmployees maps employee IDs that are not UUIDs onto the company's
+// employee directory through their external ID.
+func (s *service) resolveCSVEmployees(ctx context.Context, companyID uuid.UUID, rows []*csvRow) error {
+	var externalIDs []string
+	for _, row := range rows {
+		if row.externalID != "" {
+			externalIDs = append(externalIDs, row.externalID)
 		}
 	}
 
-	var cardsToIssue []*models.CardToIssue
-	lineNumber := 1
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	employees, err := s.repo.GetEmployeesByExternalIDs(ctx, companyID, externalIDs)
+	if err != nil {
+		return fmt.Errorf("failed to resolve employees: %w", err)
+	}
 
-	for {
-		lineNumber++
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
+	for _, row := range rows {
+		if row.externalID == "" {
+			continue
 		}
-		if err != nil {
-			return fmt.Errorf("error reading line %d: %w", lineNumber, err)
+		employee, ok := employees[row.externalID]
+		if !ok {
+			row.reject("employee_id %q is not a UUID and does not match any employee external ID", row.externalID)
+			continue
 		}
+		row.employeeID = employee.ID
+	}
 
-		if len(record) < 2 {
-			return fmt.Errorf("invalid data at line %d: expected at least 2 columns", lineNumber)
-		}
+	return nil
+}
 
-		employeeID, err := uuid.Parse(record[0])
-		if err != nil {
-			return fmt.Errorf("invalid employee ID at line %d: %w", lineNumber, err)
-		}
+// markCSVDuplicates flags accepted rows for employees that appear earlier in
+// the file, already hold a card or already have a card waiting to be issued.
+// firstLine carries the employees seen in earlier chunks of the same file.
+func (s *service) markCSVDuplicates(ctx context.Context, clientID uuid.UUID, rows []*csvRow, firstLine map[uuid.UUID]int) error {
+	var employeeIDs []uuid.UUID
 
-		employeeEmail := record[1]
-		if employeeEmail == "" {
-			return fmt.Errorf("empty employee email at line %d", lineNumber)
+	for _, row := range rows {
+		if !row.accepted() {
+			continue
 		}
-
-		cardToIssue := &models.CardToIssue{
-			ID:            uuid.New(),
-			ClientID:      clientID,
-			CardID:        uuid.New(),
-			EmployeeID:    employeeID,
-			EmployeeEmail: employeeEmail,
-			Status:        models.CardToIssueStatusPending,
-			CreatedAt:     time.Now(),
-			UpdatedAt:     time.Now(),
+		if line, seen := firstLine[row.employeeID]; seen {
+			row.markDuplicate("duplicate of line %d", line)
+			continue
 		}
+		firstLine[row.employeeID] = row.result.Line
+		employeeIDs = append(employeeIDs, row.employeeID)
+	}
+
+	if len(employeeIDs) == 0 {
+		return nil
+	}
 
-		cardsToIssue = append(cardsToIssue, cardToIssue)
+	holders, err := s.repo.GetEmployeeIDsWithCards(ctx, clientID, employeeIDs)
+	if err != nil {
+		return fmt.Errorf("failed to check existing cards: %w", err)
 	}
 
-	if len(cardsToIssue) == 0 {
-		return fmt.Errorf("no valid records found in CSV")
+	pending, err := s.repo.GetEmployeeIDsPendingIssue(ctx, clientID, employeeIDs)
+	if err != nil {
+		return fmt.Errorf("failed to check pending cards: %w", err)
 	}
 
-	if err := s.repo.CreateCardsToIssue(ctx, cardsToIssue); err != nil {
-		return fmt.Errorf("failed to create cards to issue: %w", err)
+	for _, row := range rows {
+		if !row.accepted() {
+			continue
+		}
+		switch {
+		case holders[row.employeeID]:
+			row.markDuplicate("employee already has a card")
+		case pending[row.employeeID]:
+			row.markDuplicate("employee already has a card waiting to be issued")
+		}
 	}
 
 	return nil
@@ -262,67 +293,336 @@ func calculateLuhnCheckDigit(cardNumber string) string {
 	return fmt.Sprintf("%d", checkDigit)
 }
 
-func generateCVVHash() string {
-	b := make([]byte, 2)
-	rand.Read(b)
-	cvv := fmt.Sprintf("%03d", int(b[0])<<8+int(b[1])%1000)
+// IssueNewCards issues the given pending cards, or every pending card that is
+// due when no IDs are given. The optional policy is applied to all of them.
+// Issuance is atomic, so calling it again never issues a card twice. The
+// returned cards carry their CVV, which is not returned again except by the
+// card reveal endpoint.
+func (s *service) IssueNewCards(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, policy *models.IssuancePolicy) ([]*response.IssuedCard, error) {
+	if err := validation.ValidateIssuancePolicy(policy); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkPolicyTemplate(ctx, companyID, policy); err != nil {
+		return nil, err
+	}
+
+	var issuedCards []*response.IssuedCard
+	issued, err := s.repo.IssuePendingCards(ctx, companyID, ids, func(ctx context.Context, pending []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, error) {
+		if policy != nil {
+			for _, card := range pending {
+				card.Policy = policy
+			}
+		}
 
-	hash := sha256.Sum256([]byte(cvv))
-	return hex.EncodeToString(hash[:])
+		cards, controls, details, err := s.buildCards(ctx, companyID, pending)
+		issuedCards = details
+		return cards, controls, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if issued == 0 {
+		return nil, errors.ErrNotFound
+	}
+
+	return issuedCards, nil
 }
 
-func (s *service) IssueNewCards(ctx context.Context, companyID uuid.UUID) (int, error) {
-	pendingCards, err := s.repo.GetPendingCardsToIssue(ctx, companyID)
+// ScheduleCardIssuance marks pending cards to be issued by the scheduler on
+// the given date with the optional policy. Without IDs every pending card of
+// the company is scheduled.
+func (s *service) ScheduleCardIssuance(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, scheduledFor time.Time, policy *models.IssuancePolicy) (int, error) {
+	if err := validation.ValidateIssuancePolicy(policy); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkPolicyTemplate(ctx, companyID, policy); err != nil {
+		return 0, err
+	}
+
+	scheduled, err := s.repo.ScheduleCardsToIssue(ctx, companyID, ids, scheduledFor, policy)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch pending cards: %w", err)
+		return 0, err
 	}
 
-	if len(pendingCards) == 0 {
+	if scheduled == 0 {
 		return 0, errors.ErrNotFound
 	}
 
+	return scheduled, nil
+}
+
+func (s *service) CancelCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (int, error) {
+	return s.repo.CancelCardsToIssue(ctx, companyID, ids)
+}
+
+// IssueScheduledCards issues every scheduled card whose date has been reached,
+// using the policy stored when it was scheduled. A failure for one company
+// does not stop the others; the first error is returned.
+func (s *service) IssueScheduledCards(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.GetDueScheduledCardsToIssue(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch scheduled cards: %w", err)
+	}
+
+	byCompany := make(map[uuid.UUID][]uuid.UUID)
+	var companyIDs []uuid.UUID
+	for _, pending := range due {
+		if _, ok := byCompany[pending.ClientID]; !ok {
+			companyIDs = append(companyIDs, pending.ClientID)
+		}
+		byCompany[pending.ClientID] = append(byCompany[pending.ClientID], pending.ID)
+	}
+
+	issued := 0
+	var firstErr error
+	for _, companyID := range companyIDs {
+		count, err := s.repo.IssuePendingCards(ctx, companyID, byCompany[companyID], func(ctx context.Context, pending []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, error) {
+			cards, controls, _, err := s.buildCards(ctx, companyID, pending)
+			return cards, controls, err
+		})
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("company %s: %w", companyID, err)
+			}
+			continue
+		}
+		issued += count
+	}
+
+	return issued, firstErr
+}
+
+// StartIssuanceScheduler issues scheduled cards every interval until ctx is
+// cancelled.
+func (s *service) StartIssuanceScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				issued, err := s.IssueScheduledCards(ctx, now)
+				if err != nil {
+					log.Printf("Warning: scheduled card issuance failed: %v", err)
+				}
+				if issued > 0 {
+					log.Printf("Issued %d scheduled cards", issued)
+				}
+			}
+		}
+	}()
+}
+
+// checkPolicyTemplate makes sure the template chosen by the policy belongs to
+// the company.
+func (s *service) checkPolicyTemplate(ctx context.Context, companyID uuid.UUID, policy *models.IssuancePolicy) error {
+	if policy == nil || policy.TemplateID == nil {
+		return nil
+	}
+
+	templates, err := s.repo.GetPolicyTemplatesByIDs(ctx, companyID, []uuid.UUID{*policy.TemplateID})
+	if err != nil {
+		return err
+	}
+	if _, ok := templates[*policy.TemplateID]; !ok {
+		return fmt.Errorf("%w: policy template %s not found", errors.ErrInvalidPolicy, *policy.TemplateID)
+	}
+
+	return nil
+}
+
+// policyTemplates returns the template each pending row is issued with: the
+// one chosen by its policy, or the company's default template otherwise. A
+// chosen template deleted after the cards were scheduled also falls back to
+// the default. Rows without any template map to nil.
+func (s *service) policyTemplates(ctx context.Context, companyID uuid.UUID, pendingCards []*models.CardToIssue) (map[uuid.UUID]*models.CardPolicyTemplate, error) {
+	var templateIDs []uuid.UUID
+	for _, pending := range pendingCards {
+		if pending.Policy != nil && pending.Policy.TemplateID != nil {
+			templateIDs = append(templateIDs, *pending.Policy.TemplateID)
+		}
+	}
+
+	chosen, err := s.repo.GetPolicyTemplatesByIDs(ctx, companyID, templateIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch policy templates: %w", err)
+	}
+
+	defaultTemplate, err := s.repo.GetDefaultPolicyTemplate(ctx, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch default policy template: %w", err)
+	}
+
+	templates := make(map[uuid.UUID]*models.CardPolicyTemplate, len(pendingCards))
+	for _, pending := range pendingCards {
+		templates[pending.ID] = defaultTemplate
+		if pending.Policy != nil && pending.Policy.TemplateID != nil {
+			if template, ok := chosen[*pending.Policy.TemplateID]; ok {
+				templates[pending.ID] = template
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+// buildCards generates the cards and their spending controls for pending rows
+// locked by IssuePendingCards, applying each row's policy template. It also
+// returns the issued card details, including each card's CVV.
+func (s *service) buildCards(ctx context.Context, companyID uuid.UUID, pendingCards []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, []*response.IssuedCard, error) {
+	employeeIDs := make([]uuid.UUID, 0, len(pendingCards))
+	for _, pending := range pendingCards {
+		employeeIDs = append(employeeIDs, pending.EmployeeID)
+	}
+
+	employees, err := s.repo.GetEmployeesByIDs(ctx, companyID, employeeIDs)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to fetch employees: %w", err)
+	}
+
+	templates, err := s.policyTemplates(ctx, companyID, pendingCards)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	var newCards []*models.Card
-	var cardToIssueIDs []uuid.UUID
+	var controls []*models.SpendingControl
+	var issued []*response.IssuedCard
 
 	for _, pending := range pendingCards {
 		cardNumber := generateCardNumber()
-		cvvHash := generateCVVHash()
 		lastFour := cardNumber[len(cardNumber)-4:]
+
+		cvv, err := vault.NewCVV()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		secret, err := s.vault.SealCard(cardNumber, cvv)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to encrypt card number: %w", err)
+		}
 		expiryDate := time.Now().AddDate(3, 0, 0)
 
+		cardHolderName := fmt.Sprintf("Employee - %s", pending.EmployeeEmail)
+		var employeeRefID *uuid.UUID
+		if employee, ok := employees[pending.EmployeeID]; ok {
+			cardHolderName = employee.Name
+			employeeRefID = &employee.ID
+		}
+		if pending.CardHolderName != nil {
+			cardHolderName = *pending.CardHolderName
+		}
+
+		balance := 0.00
+		if pending.InitialFunding != nil {
+			balance = *pending.InitialFunding
+		}
+
 		card := &models.Card{
 			ID:             pending.CardID, // -> pre generated cardid
 			CompanyID:      companyID,
-			CardNumber:     cardNumber,
-			CardHolderName: fmt.Sprintf("Employee - %s", pending.EmployeeEmail),
+			Token:          vault.NewToken(),
+			CardHolderName: cardHolderName,
 			EmployeeID:     pending.EmployeeID.String(),
 			EmployeeEmail:  pending.EmployeeEmail,
+			EmployeeRefID:  employeeRefID,
+			Department:     pending.Department,
 			CardType:       models.CardTypeVirtual,
 			Status:         models.CardStatusActive,
-			Balance:        0.00,
-			SpendingLimit:  nil, // Can be set later
-			DailyLimit:     nil,
-			MonthlyLimit:   nil,
+			Balance:        balance,
 			ExpiryDate:     expiryDate,
-			CVVHash:        cvvHash,
+			CVVMAC:         s.vault.CVVMAC(pending.CardID, cvv),
 			LastFour:       lastFour,
 			CreatedAt:      time.Now(),
 			UpdatedAt:      time.Now(),
+			Secret:         secret,
+		}
+		template := templates[pending.ID]
+		applyIssuanceSettings(card, pending, template)
+
+		if template != nil {
+			card.PolicyTemplateID = &template.ID
+			for _, control := range template.Controls {
+				controls = append(controls, &models.SpendingControl{
+					CardID:           card.ID,
+					ControlType:      control.ControlType,
+					ControlValue:     control.Value,
+					IsActive:         true,
+					PolicyTemplateID: &template.ID,
+				})
+			}
+		}
+
+		if pending.Policy != nil {
+			for _, control := range pending.Policy.Controls {
+				controls = append(controls, &models.SpendingControl{
+					CardID:       card.ID,
+					ControlType:  control.ControlType,
+					ControlValue: control.Value,
+					IsActive:     true,
+				})
+			}
 		}
 
 		newCards = append(newCards, card)
-		cardToIssueIDs = append(cardToIssueIDs, pending.ID)
+		issued = append(issued, &response.IssuedCard{
+			CardID:     card.ID,
+			EmployeeID: pending.EmployeeID,
+			Token:      card.Token,
+			MaskedPAN:  models.MaskPAN(card.LastFour),
+			CVV:        cvv,
+			ExpiryDate: card.ExpiryDate,
+		})
 	}
 
-	if err := s.repo.CreateCardsInBatch(ctx, newCards); err != nil {
-		return 0, fmt.Errorf("failed to create cards: %w", err)
+	return newCards, controls, issued, nil
+}
+
+// applyIssuanceSettings sets the card type and limits, preferring the values
+// given for the employee over those of the issuance policy, and those over the
+// policy template.
+func applyIssuanceSettings(card *models.Card, pending *models.CardToIssue, template *models.CardPolicyTemplate) {
+	if template != nil {
+		if template.CardType != nil {
+			card.CardType = *template.CardType
+		}
+		card.SpendingLimit = template.SpendingLimit
+		card.DailyLimit = template.DailyLimit
+		card.MonthlyLimit = template.MonthlyLimit
 	}
 
-	if err := s.repo.UpdateCardsToIssueStatusBatch(ctx, cardToIssueIDs, models.CardToIssueStatusGenerated); err != nil {
-		// Log error but don't fail the operation since cards are already created
-		// You might want to implement a retry mechanism here
-		fmt.Printf("Warning: Failed to update cards_to_issue status: %v\n", err)
+	if policy := pending.Policy; policy != nil {
+		if policy.CardType != nil {
+			card.CardType = *policy.CardType
+		}
+		if policy.SpendingLimit != nil {
+			card.SpendingLimit = policy.SpendingLimit
+		}
+		if policy.DailyLimit != nil {
+			card.DailyLimit = policy.DailyLimit
+		}
+		if policy.MonthlyLimit != nil {
+			card.MonthlyLimit = policy.MonthlyLimit
+		}
 	}
 
-	return len(newCards), nil
+	if pending.CardType != nil {
+		card.CardType = *pending.CardType
+	}
+	if pending.SpendingLimit != nil {
+		card.SpendingLimit = pending.SpendingLimit
+	}
+	if pending.DailyLimit != nil {
+		card.DailyLimit = pending.DailyLimit
+	}
+	if pending.MonthlyLimit != nil {
+		card.MonthlyLimit = pending.MonthlyLimit
+	}
 }
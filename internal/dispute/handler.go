@@ -0,0 +1,248 @@
+package dispute
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateDispute(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.CreateDispute
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.service.FileDispute(c.Request.Context(), companyID, &req)
+	if err != nil {
+		disputeErrorResponse(c, err, "Failed to file dispute")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dispute)
+}
+
+func (h *Handler) GetDisputes(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.DisputeStatusOpened, models.DisputeStatusUnderReview, models.DisputeStatusWon, models.DisputeStatusLost:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute status"})
+		return
+	}
+
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+
+	disputes, err := h.service.ListDisputes(c.Request.Context(), companyID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve disputes"})
+		return
+	}
+	if disputes == nil {
+		disputes = []*models.Dispute{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disputes":  disputes,
+		"count":     len(disputes),
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *Handler) GetDispute(c *gin.Context) {
+	companyID, disputeID, ok := disputeParams(c)
+	if !ok {
+		return
+	}
+
+	dispute, err := h.service.GetDispute(c.Request.Context(), companyID, disputeID)
+	if err != nil {
+		disputeErrorResponse(c, err, "Failed to retrieve dispute")
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+// SubmitDispute moves an opened dispute under review.
+func (h *Handler) SubmitDispute(c *gin.Context) {
+	companyID, disputeID, ok := disputeParams(c)
+	if !ok {
+		return
+	}
+
+	dispute, err := h.service.SubmitDispute(c.Request.Context(), companyID, disputeID)
+	if err != nil {
+		disputeErrorResponse(c, err, "Failed to submit dispute")
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+func (h *Handler) ResolveDispute(c *gin.Context) {
+	companyID, disputeID, ok := disputeParams(c)
+	if !ok {
+		return
+	}
+
+	var req request.ResolveDispute
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dispute, err := h.service.ResolveDispute(c.Request.Context(), companyID, disputeID, &req)
+	if err != nil {
+		disputeErrorResponse(c, err, "Failed to resolve dispute")
+		return
+	}
+
+	c.JSON(http.StatusOK, dispute)
+}
+
+// UploadEvidence attaches the multipart "file" field to the dispute, with an
+// optional "description" field.
+func (h *Handler) UploadEvidence(c *gin.Context) {
+	companyID, disputeID, ok := disputeParams(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxEvidenceSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get file from request"})
+		return
+	}
+	if fileHeader.Size > MaxEvidenceSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Evidence files are limited to %d MB", MaxEvidenceSize>>20)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	contentType := fileHeader.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(content)
+	}
+
+	evidence := &models.DisputeEvidence{
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Content:     content,
+	}
+	if description := strings.TrimSpace(c.PostForm("description")); description != "" {
+		evidence.Description = &description
+	}
+
+	if err := h.service.AddEvidence(c.Request.Context(), companyID, disputeID, evidence); err != nil {
+		disputeErrorResponse(c, err, "Failed to add evidence")
+		return
+	}
+
+	c.JSON(http.StatusCreated, evidence)
+}
+
+func (h *Handler) DownloadEvidence(c *gin.Context) {
+	companyID, disputeID, ok := disputeParams(c)
+	if !ok {
+		return
+	}
+
+	evidenceID, err := uuid.Parse(c.Param("evidenceId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid evidence ID format"})
+		return
+	}
+
+	evidence, err := h.service.GetEvidence(c.Request.Context(), companyID, disputeID, evidenceID)
+	if err != nil {
+		disputeErrorResponse(c, err, "Failed to retrieve evidence")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", evidence.FileName))
+	c.Data(http.StatusOK, evidence.ContentType, evidence.Content)
+}
+
+func disputeParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	disputeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dispute ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return companyID, disputeID, true
+}
+
+func disputeErrorResponse(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, errors.ErrNotDisputable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only completed purchases can be disputed"})
+	case errors.Is(err, errors.ErrDisputeAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dispute amount must be greater than zero and at most the transaction amount"})
+	case errors.Is(err, errors.ErrBadRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Evidence file is empty or too large"})
+	case errors.Is(err, errors.ErrDisputeExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction is already disputed"})
+	case errors.Is(err, errors.ErrDisputeTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Dispute status does not allow this action"})
+	case errors.Is(err, errors.ErrDisputeDeadline):
+		c.JSON(http.StatusConflict, gin.H{"error": "Dispute deadline has passed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
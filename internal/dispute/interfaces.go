@@ -0,0 +1,34 @@
+package dispute
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	GetTransaction(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error)
+
+	CreateDispute(ctx context.Context, dispute *models.Dispute) error
+	GetDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error)
+	GetDisputes(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Dispute, error)
+	UpdateDisputeStatus(ctx context.Context, companyID, disputeID uuid.UUID, from, to string) (*models.Dispute, error)
+	ResolveDispute(ctx context.Context, companyID, disputeID uuid.UUID, outcome string, note *string) (*models.Dispute, error)
+
+	AddEvidence(ctx context.Context, evidence *models.DisputeEvidence) error
+	GetEvidence(ctx context.Context, companyID, disputeID, evidenceID uuid.UUID) (*models.DisputeEvidence, error)
+}
+
+type Service interface {
+	FileDispute(ctx context.Context, companyID uuid.UUID, req *request.CreateDispute) (*models.Dispute, error)
+	GetDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error)
+	ListDisputes(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Dispute, error)
+	SubmitDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error)
+	ResolveDispute(ctx context.Context, companyID, disputeID uuid.UUID, req *request.ResolveDispute) (*models.Dispute, error)
+
+	AddEvidence(ctx context.Context, companyID, disputeID uuid.UUID, evidence *models.DisputeEvidence) error
+	GetEvidence(ctx context.Context, companyID, disputeID, evidenceID uuid.UUID) (*models.DisputeEvidence, error)
+}
@@ -0,0 +1,333 @@
+package dispute
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/ledger"
+	"ccards/pkg/models"
+)
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) GetTransaction(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	transaction := &models.Transaction{}
+	err := models.ScanTransaction(r.db.QueryRowContext(ctx, `
+		SELECT `+models.TransactionColumns+`
+		FROM transactions
+		WHERE id = $1 AND company_id = $2`,
+		transactionID, companyID,
+	), transaction)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// CreateDispute stores the dispute and books its provisional credit to the
+// card in one transaction. It fails with ErrDisputeAmount when refunds
+// credited the purchase back in the meantime.
+func (r *repository) CreateDispute(ctx context.Context, dispute *models.Dispute) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	purchase, err := ledger.LockPurchase(ctx, tx, dispute.TransactionID)
+	if err != nil {
+		return err
+	}
+	if purchase == nil {
+		return apperrors.ErrNotFound
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO disputes (
+			id, company_id, card_id, transaction_id, reason, description, amount, status,
+			evidence_due_at, resolve_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at`,
+		dispute.ID, dispute.CompanyID, dispute.CardID, dispute.TransactionID, dispute.Reason,
+		dispute.Description, dispute.Amount, dispute.Status, dispute.EvidenceDueAt, dispute.ResolveBy,
+	).Scan(&dispute.CreatedAt, &dispute.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return apperrors.ErrDisputeExists
+		}
+		return fmt.Errorf("failed to create dispute: %w", err)
+	}
+
+	if dispute.Amount > purchase.Refundable() {
+		return apperrors.ErrDisputeAmount
+	}
+
+	toCompany, err := ledger.Credit(ctx, tx, purchase, dispute.Amount)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Provisional credit for dispute %s", dispute.ID)
+	if toCompany {
+		description += " (credited to company balance)"
+	}
+	creditID, err := insertLedgerEntry(ctx, tx, dispute, models.TransactionTypeProvisionalCredit, dispute.Amount, description)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE disputes SET provisional_credit_transaction_id = $2 WHERE id = $1`, dispute.ID, creditID); err != nil {
+		return fmt.Errorf("failed to link provisional credit: %w", err)
+	}
+	dispute.ProvisionalCreditTransactionID = &creditID
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error) {
+	dispute := &models.Dispute{}
+	err := models.ScanDispute(r.db.QueryRowContext(ctx, `
+		SELECT `+models.DisputeColumns+`
+		FROM disputes
+		WHERE id = $1 AND company_id = $2`,
+		disputeID, companyID,
+	), dispute)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get dispute: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, dispute_id, file_name, content_type, size_bytes, description, created_at
+		FROM dispute_evidence
+		WHERE dispute_id = $1
+		ORDER BY created_at`,
+		dispute.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispute evidence: %w", err)
+	}
+	defer rows.Close()
+
+	dispute.Evidence = []*models.DisputeEvidence{}
+	for rows.Next() {
+		evidence := &models.DisputeEvidence{}
+		err := rows.Scan(&evidence.ID, &evidence.DisputeID, &evidence.FileName, &evidence.ContentType,
+			&evidence.SizeBytes, &evidence.Description, &evidence.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dispute evidence: %w", err)
+		}
+		dispute.Evidence = append(dispute.Evidence, evidence)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return dispute, nil
+}
+
+// GetDisputes lists the company's disputes, newest first, optionally with one
+// status only.
+func (r *repository) GetDisputes(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Dispute, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+models.DisputeColumns+`
+		FROM disputes
+		WHERE company_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`,
+		companyID, status, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disputes: %w", err)
+	}
+	defer rows.Close()
+
+	var disputes []*models.Dispute
+	for rows.Next() {
+		dispute := &models.Dispute{}
+		if err := models.ScanDispute(rows, dispute); err != nil {
+			return nil, fmt.Errorf("failed to scan dispute: %w", err)
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return disputes, nil
+}
+
+// UpdateDisputeStatus moves a dispute from one status to another. It returns
+// ErrDisputeTransition when the dispute is no longer in the from status.
+func (r *repository) UpdateDisputeStatus(ctx context.Context, companyID, disputeID uuid.UUID, from, to string) (*models.Dispute, error) {
+	dispute := &models.Dispute{}
+	err := models.ScanDispute(r.db.QueryRowContext(ctx, `
+		UPDATE disputes
+		SET status = $4
+		WHERE id = $1 AND company_id = $2 AND status = $3
+		RETURNING `+models.DisputeColumns,
+		disputeID, companyID, from, to,
+	), dispute)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrDisputeTransition
+		}
+		return nil, fmt.Errorf("failed to update dispute status: %w", err)
+	}
+
+	return dispute, nil
+}
+
+// ResolveDispute closes a dispute under review. A lost dispute reverses the
+// provisional credit: the card is debited as far as its balance allows and
+// the rest is charged to the company balance.
+func (r *repository) ResolveDispute(ctx context.Context, companyID, disputeID uuid.UUID, outcome string, note *string) (*models.Dispute, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	dispute := &models.Dispute{}
+	err = models.ScanDispute(tx.QueryRowContext(ctx, `
+		UPDATE disputes
+		SET status = $3, resolved_at = CURRENT_TIMESTAMP, resolution_note = $4
+		WHERE id = $1 AND company_id = $2 AND status = $5
+		RETURNING `+models.DisputeColumns,
+		disputeID, companyID, outcome, note, models.DisputeStatusUnderReview,
+	), dispute)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrDisputeTransition
+		}
+		return nil, fmt.Errorf("failed to resolve dispute: %w", err)
+	}
+
+	if outcome == models.DisputeStatusLost {
+		purchase, err := ledger.LockPurchase(ctx, tx, dispute.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if purchase != nil {
+			if err := ledger.Uncredit(ctx, tx, purchase, dispute.Amount); err != nil {
+				return nil, err
+			}
+		}
+
+		var balance float64
+		err = tx.QueryRowContext(ctx, `SELECT balance FROM cards WHERE id = $1 FOR UPDATE`, dispute.CardID).Scan(&balance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock card for update: %w", err)
+		}
+
+		fromCard := math.Min(balance, dispute.Amount)
+		fromCompany := dispute.Amount - fromCard
+
+		description := fmt.Sprintf("Reversal of provisional credit for lost dispute %s", dispute.ID)
+		if fromCompany > 0 {
+			description += fmt.Sprintf(" (%.2f charged to company balance)", fromCompany)
+		}
+
+		reversalID, err := insertLedgerEntry(ctx, tx, dispute, models.TransactionTypeCreditReversal, dispute.Amount, description)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE cards SET balance = balance - $2 WHERE id = $1`, dispute.CardID, fromCard); err != nil {
+			return nil, fmt.Errorf("failed to debit card: %w", err)
+		}
+		if fromCompany > 0 {
+			if _, err := tx.ExecContext(ctx, `UPDATE companies SET balance = balance - $2 WHERE id = $1`, dispute.CompanyID, fromCompany); err != nil {
+				return nil, fmt.Errorf("failed to debit company: %w", err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE disputes SET reversal_transaction_id = $2 WHERE id = $1`, dispute.ID, reversalID); err != nil {
+			return nil, fmt.Errorf("failed to link credit reversal: %w", err)
+		}
+		dispute.ReversalTransactionID = &reversalID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return dispute, nil
+}
+
+func (r *repository) AddEvidence(ctx context.Context, evidence *models.DisputeEvidence) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO dispute_evidence (id, dispute_id, file_name, content_type, size_bytes, description, content)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at`,
+		evidence.ID, evidence.DisputeID, evidence.FileName, evidence.ContentType, evidence.SizeBytes,
+		evidence.Description, evidence.Content,
+	).Scan(&evidence.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add dispute evidence: %w", err)
+	}
+
+	return nil
+}
+
+// GetEvidence returns an evidence file with its content.
+func (r *repository) GetEvidence(ctx context.Context, companyID, disputeID, evidenceID uuid.UUID) (*models.DisputeEvidence, error) {
+	evidence := &models.DisputeEvidence{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT e.id, e.dispute_id, e.file_name, e.content_type, e.size_bytes, e.description, e.content, e.created_at
+		FROM dispute_evidence e
+		JOIN disputes d ON d.id = e.dispute_id
+		WHERE e.id = $1 AND e.dispute_id = $2 AND d.company_id = $3`,
+		evidenceID, disputeID, companyID,
+	).Scan(&evidence.ID, &evidence.DisputeID, &evidence.FileName, &evidence.ContentType,
+		&evidence.SizeBytes, &evidence.Description, &evidence.Content, &evidence.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get dispute evidence: %w", err)
+	}
+
+	return evidence, nil
+}
+
+// insertLedgerEntry books a dispute movement on the card's transaction
+// history.
+func insertLedgerEntry(ctx context.Context, tx *sql.Tx, dispute *models.Dispute, transactionType string, amount float64, description string) (uuid.UUID, error) {
+	id := uuid.New()
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO transactions (id, card_id, company_id, transaction_type, amount, description, status, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)`,
+		id, dispute.CardID, dispute.CompanyID, transactionType, amount, description, models.TransactionStatusCompleted,
+	)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to record %s: %w", transactionType, err)
+	}
+	return id, nil
+}
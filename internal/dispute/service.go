@@ -0,0 +1,178 @@
+package dispute
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/ledger"
+	"ccards/pkg/models"
+)
+
+const (
+	// FilingWindow is how long after a payment it can still be disputed.
+	FilingWindow = 120 * 24 * time.Hour
+	// EvidenceWindow is how long after filing evidence can be attached.
+	EvidenceWindow = 10 * 24 * time.Hour
+	// ResolutionWindow is how long after filing a dispute should be resolved.
+	ResolutionWindow = 45 * 24 * time.Hour
+
+	// MaxEvidenceSize is the largest evidence file accepted, in bytes.
+	MaxEvidenceSize = 5 << 20
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+// FileDispute opens a dispute on a completed purchase and credits the
+// disputed amount back to the card provisionally. Only the part of the
+// purchase not yet refunded can be disputed.
+func (s *service) FileDispute(ctx context.Context, companyID uuid.UUID, req *request.CreateDispute) (*models.Dispute, error) {
+	transaction, err := s.repo.GetTransaction(ctx, companyID, req.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, errors.ErrNotFound
+	}
+	if transaction.TransactionType != models.TransactionTypePurchase || transaction.Status != models.TransactionStatusCompleted {
+		return nil, errors.ErrNotDisputable
+	}
+
+	now := time.Now()
+	if now.Sub(transaction.CreatedAt) > FilingWindow {
+		return nil, errors.ErrDisputeDeadline
+	}
+
+	// Refunds and other credits for the purchase cannot be disputed again.
+	disputable := ledger.Round(transaction.Amount - transaction.RefundedAmount)
+	amount := disputable
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	if amount <= 0 || amount > disputable {
+		return nil, errors.ErrDisputeAmount
+	}
+
+	dispute := &models.Dispute{
+		ID:            uuid.New(),
+		CompanyID:     companyID,
+		CardID:        transaction.CardID,
+		TransactionID: transaction.ID,
+		Reason:        req.Reason,
+		Amount:        amount,
+		Status:        models.DisputeStatusOpened,
+		EvidenceDueAt: now.Add(EvidenceWindow),
+		ResolveBy:     now.Add(ResolutionWindow),
+	}
+	if description := strings.TrimSpace(req.Description); description != "" {
+		dispute.Description = &description
+	}
+
+	if err := s.repo.CreateDispute(ctx, dispute); err != nil {
+		return nil, err
+	}
+
+	return dispute, nil
+}
+
+func (s *service) GetDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error) {
+	dispute, err := s.repo.GetDispute(ctx, companyID, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil {
+		return nil, errors.ErrNotFound
+	}
+	return dispute, nil
+}
+
+func (s *service) ListDisputes(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Dispute, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.GetDisputes(ctx, companyID, status, limit, offset)
+}
+
+// SubmitDispute moves an opened dispute under review.
+func (s *service) SubmitDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error) {
+	dispute, err := s.GetDispute(ctx, companyID, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != models.DisputeStatusOpened {
+		return nil, errors.ErrDisputeTransition
+	}
+
+	return s.repo.UpdateDisputeStatus(ctx, companyID, disputeID, models.DisputeStatusOpened, models.DisputeStatusUnderReview)
+}
+
+// ResolveDispute closes a dispute under review. A won dispute keeps the
+// provisional credit; a lost one reverses it.
+func (s *service) ResolveDispute(ctx context.Context, companyID, disputeID uuid.UUID, req *request.ResolveDispute) (*models.Dispute, error) {
+	dispute, err := s.GetDispute(ctx, companyID, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != models.DisputeStatusUnderReview {
+		return nil, errors.ErrDisputeTransition
+	}
+
+	var note *string
+	if trimmed := strings.TrimSpace(req.Note); trimmed != "" {
+		note = &trimmed
+	}
+
+	return s.repo.ResolveDispute(ctx, companyID, disputeID, req.Outcome, note)
+}
+
+// AddEvidence attaches a file to an open dispute before its evidence
+// deadline.
+func (s *service) AddEvidence(ctx context.Context, companyID, disputeID uuid.UUID, evidence *models.DisputeEvidence) error {
+	dispute, err := s.GetDispute(ctx, companyID, disputeID)
+	if err != nil {
+		return err
+	}
+	if dispute.Status != models.DisputeStatusOpened && dispute.Status != models.DisputeStatusUnderReview {
+		return errors.ErrDisputeTransition
+	}
+	if time.Now().After(dispute.EvidenceDueAt) {
+		return errors.ErrDisputeDeadline
+	}
+	if len(evidence.Content) == 0 || len(evidence.Content) > MaxEvidenceSize {
+		return errors.ErrBadRequest
+	}
+
+	evidence.ID = uuid.New()
+	evidence.DisputeID = dispute.ID
+	evidence.SizeBytes = len(evidence.Content)
+
+	return s.repo.AddEvidence(ctx, evidence)
+}
+
+func (s *service) GetEvidence(ctx context.Context, companyID, disputeID, evidenceID uuid.UUID) (*models.DisputeEvidence, error) {
+	evidence, err := s.repo.GetEvidence(ctx, companyID, disputeID, evidenceID)
+	if err != nil {
+		return nil, err
+	}
+	if evidence == nil {
+		return nil, errors.ErrNotFound
+	}
+	return evidence, nil
+}
@@ -0,0 +1,259 @@
+package employee
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/utils"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateEmployee(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.CreateEmployee
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	employee, err := h.service.CreateEmployee(c.Request.Context(), companyID, &req)
+	if err != nil {
+		switch err {
+		case errors.ErrEmployeeExists:
+			c.JSON(http.StatusConflict, gin.H{"error": "Employee with this email or external ID already exists"})
+		case errors.ErrInvalidManager:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Manager not found in company"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create employee"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, employee)
+}
+
+func (h *Handler) GetEmployees(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+	offset := (page - 1) * pageSize
+
+	employees, err := h.service.ListEmployees(c.Request.Context(), companyID, c.Query("status"), pageSize, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employees"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"employees": employees,
+		"count":     len(employees),
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *Handler) GetEmployee(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	employeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID format"})
+		return
+	}
+
+	employee, err := h.service.GetEmployee(c.Request.Context(), companyID, employeeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee"})
+		return
+	}
+	if employee == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+func (h *Handler) UpdateEmployee(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	employeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID format"})
+		return
+	}
+
+	var req request.UpdateEmployee
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	employee, err := h.service.UpdateEmployee(c.Request.Context(), companyID, employeeID, &req)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		case errors.ErrEmployeeExists:
+			c.JSON(http.StatusConflict, gin.H{"error": "Employee with this email or external ID already exists"})
+		case errors.ErrInvalidManager:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Manager not found in company"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update employee"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, employee)
+}
+
+func (h *Handler) DeleteEmployee(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	employeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID format"})
+		return
+	}
+
+	if err := h.service.DeleteEmployee(c.Request.Context(), companyID, employeeID); err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete employee"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Employee deleted successfully"})
+}
+
+func (h *Handler) GetEmployeeCards(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	employeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID format"})
+		return
+	}
+
+	cards, err := h.service.GetEmployeeCards(c.Request.Context(), companyID, employeeID)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee cards"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cards": cards,
+		"count": len(cards),
+	})
+}
+
+func (h *Handler) OffboardEmployee(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	employeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID format"})
+		return
+	}
+
+	var req request.OffboardEmployee
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.service.OffboardEmployee(c.Request.Context(), companyID, employeeID, &req)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found"})
+		case errors.ErrOffboardingConflict:
+			c.JSON(http.StatusConflict, gin.H{"error": "Offboarding already started with a different card action or reason; retry it with the same ones"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to offboard employee, the request can be retried",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) GetOffboardingReport(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	employeeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee ID format"})
+		return
+	}
+
+	report, err := h.service.GetOffboardingReport(c.Request.Context(), companyID, employeeID)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offboarding not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve offboarding report"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,40 @@
+package employee
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/internal/api/response"
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	CreateEmployee(ctx context.Context, employee *models.Employee) error
+	GetEmployeeByID(ctx context.Context, companyID, id uuid.UUID) (*models.Employee, error)
+	GetEmployeesByCompanyID(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Employee, error)
+	UpdateEmployee(ctx context.Context, employee *models.Employee) error
+	DeleteEmployee(ctx context.Context, companyID, id uuid.UUID) error
+
+	GetCardsByEmployeeID(ctx context.Context, companyID, employeeID uuid.UUID) ([]*models.Card, error)
+
+	GetOffboardingByEmployeeID(ctx context.Context, companyID, employeeID uuid.UUID) (*models.EmployeeOffboarding, error)
+	StartOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding) error
+	OffboardCard(ctx context.Context, offboarding *models.EmployeeOffboarding, cardID uuid.UUID) (*models.OffboardedCard, error)
+	GetOffboardedCards(ctx context.Context, offboardingID uuid.UUID) ([]*models.OffboardedCard, error)
+	FinishOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding) error
+}
+
+type Service interface {
+	CreateEmployee(ctx context.Context, companyID uuid.UUID, req *request.CreateEmployee) (*models.Employee, error)
+	GetEmployee(ctx context.Context, companyID, id uuid.UUID) (*models.Employee, error)
+	ListEmployees(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Employee, error)
+	UpdateEmployee(ctx context.Context, companyID, id uuid.UUID, req *request.UpdateEmployee) (*models.Employee, error)
+	DeleteEmployee(ctx context.Context, companyID, id uuid.UUID) error
+
+	GetEmployeeCards(ctx context.Context, companyID, employeeID uuid.UUID) ([]*models.Card, error)
+
+	OffboardEmployee(ctx context.Context, companyID, employeeID uuid.UUID, req *request.OffboardEmployee) (*response.OffboardingReport, error)
+	GetOffboardingReport(ctx context.Context, companyID, employeeID uuid.UUID) (*response.OffboardingReport, error)
+}
@@ -0,0 +1,472 @@
+package employee
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+const employeeColumns = `id, company_id, external_id, email, name, department, cost_center, manager_id, status, created_at, updated_at`
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func scanEmployee(row models.RowScanner, employee *models.Employee) error {
+	return row.Scan(
+		&employee.ID, &employee.CompanyID, &employee.ExternalID, &employee.Email, &employee.Name,
+		&employee.Department, &employee.CostCenter, &employee.ManagerID, &employee.Status,
+		&employee.CreatedAt, &employee.UpdatedAt,
+	)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (r *repository) CreateEmployee(ctx context.Context, employee *models.Employee) error {
+	query := `
+		INSERT INTO employees (id, company_id, external_id, email, name, department, cost_center, manager_id, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		employee.ID, employee.CompanyID, employee.ExternalID, employee.Email, employee.Name,
+		employee.Department, employee.CostCenter, employee.ManagerID, employee.Status,
+	).Scan(&employee.CreatedAt, &employee.UpdatedAt)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrEmployeeExists
+		}
+		return fmt.Errorf("failed to create employee: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetEmployeeByID(ctx context.Context, companyID, id uuid.UUID) (*models.Employee, error) {
+	query := `
+		SELECT ` + employeeColumns + `
+		FROM employees
+		WHERE company_id = $1 AND id = $2`
+
+	employee := &models.Employee{}
+	err := scanEmployee(r.db.QueryRowContext(ctx, query, companyID, id), employee)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get employee: %w", err)
+	}
+
+	return employee, nil
+}
+
+func (r *repository) GetEmployeesByCompanyID(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Employee, error) {
+	query := `
+		SELECT ` + employeeColumns + `
+		FROM employees
+		WHERE company_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY name ASC, created_at ASC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.QueryContext(ctx, query, companyID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get employees: %w", err)
+	}
+	defer rows.Close()
+
+	var employees []*models.Employee
+	for rows.Next() {
+		employee := &models.Employee{}
+		if err := scanEmployee(rows, employee); err != nil {
+			return nil, fmt.Errorf("failed to scan employee: %w", err)
+		}
+		employees = append(employees, employee)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return employees, nil
+}
+
+func (r *repository) UpdateEmployee(ctx context.Context, employee *models.Employee) error {
+	query := `
+		UPDATE employees
+		SET external_id = $3, email = $4, name = $5, department = $6, cost_center = $7,
+		    manager_id = $8, status = $9, updated_at = CURRENT_TIMESTAMP
+		WHERE company_id = $1 AND id = $2
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		employee.CompanyID, employee.ID, employee.ExternalID, employee.Email, employee.Name,
+		employee.Department, employee.CostCenter, employee.ManagerID, employee.Status,
+	).Scan(&employee.UpdatedAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrNotFound
+		}
+		if isUniqueViolation(err) {
+			return apperrors.ErrEmployeeExists
+		}
+		return fmt.Errorf("failed to update employee: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) DeleteEmployee(ctx context.Context, companyID, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM employees WHERE company_id = $1 AND id = $2`, companyID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete employee: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// GetCardsByEmployeeID also matches cards issued before the employee directory
+// existed, which only carry the employee ID as free text.
+func (r *repository) GetCardsByEmployeeID(ctx context.Context, companyID, employeeID uuid.UUID) ([]*models.Card, error) {
+	query := `
+		SELECT ` + models.CardColumns + `
+		FROM cards
+		WHERE company_id = $1 AND (employee_ref_id = $2 OR employee_id = $2::text)
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, companyID, employeeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get employee cards: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []*models.Card
+	for rows.Next() {
+		var card models.Card
+		if err := models.ScanCard(rows, &card); err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
+		}
+		cards = append(cards, &card)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return cards, nil
+}
+
+func (r *repository) GetOffboardingByEmployeeID(ctx context.Context, companyID, employeeID uuid.UUID) (*models.EmployeeOffboarding, error) {
+	query := `
+		SELECT id, company_id, employee_id, card_action, reason, status, last_error, started_at, completed_at
+		FROM employee_offboardings
+		WHERE company_id = $1 AND employee_id = $2`
+
+	offboarding := &models.EmployeeOffboarding{}
+	err := r.db.QueryRowContext(ctx, query, companyID, employeeID).Scan(
+		&offboarding.ID, &offboarding.CompanyID, &offboarding.EmployeeID, &offboarding.CardAction,
+		&offboarding.Reason, &offboarding.Status, &offboarding.LastError, &offboarding.StartedAt,
+		&offboarding.CompletedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get offboarding: %w", err)
+	}
+
+	return offboarding, nil
+}
+
+// StartOffboarding creates the offboarding record, or moves an existing
+// failed one back to in progress so it can be retried. Cards may already have
+// been handled with the existing card action and reason, so a retry with
+// different ones fails with ErrOffboardingConflict.
+func (r *repository) StartOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding) error {
+	query := `
+		INSERT INTO employee_offboardings (id, company_id, employee_id, card_action, reason, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (employee_id) DO UPDATE
+		SET status = EXCLUDED.status, last_error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE employee_offboardings.card_action = EXCLUDED.card_action
+		  AND employee_offboardings.reason IS NOT DISTINCT FROM EXCLUDED.reason
+		RETURNING id, started_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		offboarding.ID, offboarding.CompanyID, offboarding.EmployeeID, offboarding.CardAction,
+		offboarding.Reason, models.OffboardingStatusInProgress,
+	).Scan(&offboarding.ID, &offboarding.StartedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.ErrOffboardingConflict
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start offboarding: %w", err)
+	}
+
+	offboarding.Status = models.OffboardingStatusInProgress
+	offboarding.LastError = nil
+
+	return nil
+}
+
+// OffboardCard freezes a single card, voids its pending holds, revokes its
+// spending controls and sweeps its balance back to the company, all in one
+// database transaction. Cards already handled by this offboarding are skipped
+// and their stored result is returned.
+func (r *repository) OffboardCard(ctx context.Context, offboarding *models.EmployeeOffboarding, cardID uuid.UUID) (*models.OffboardedCard, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var card models.Card
+	lockQuery := `SELECT ` + models.CardColumns + ` FROM cards WHERE id = $1 AND company_id = $2 FOR UPDATE`
+	if err := models.ScanCard(tx.QueryRowContext(ctx, lockQuery, cardID, offboarding.CompanyID), &card); err != nil {
+		return nil, fmt.Errorf("failed to lock card: %w", err)
+	}
+
+	existing := &models.OffboardedCard{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT offboarding_id, card_id, last_four, previous_status, new_status, balance_returned,
+		       holds_voided, controls_revoked, processed_at
+		FROM employee_offboarding_cards
+		WHERE offboarding_id = $1 AND card_id = $2`, offboarding.ID, cardID,
+	).Scan(
+		&existing.OffboardingID, &existing.CardID, &existing.LastFour, &existing.PreviousStatus,
+		&existing.NewStatus, &existing.BalanceReturned, &existing.HoldsVoided, &existing.ControlsRevoked,
+		&existing.ProcessedAt,
+	)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to check offboarded card: %w", err)
+	}
+
+	result := &models.OffboardedCard{
+		OffboardingID:  offboarding.ID,
+		CardID:         card.ID,
+		LastFour:       card.LastFour,
+		PreviousStatus: card.Status,
+		NewStatus:      card.Status,
+	}
+
+	// Gateway holds are reversed with their pending purchases, so a later
+	// completion advice for one is refused instead of debiting the emptied
+	// card.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE gateway_authorizations
+		SET status = $2, reversed_at = CURRENT_TIMESTAMP
+		WHERE card_id = $1 AND status = $3`,
+		card.ID, models.GatewayAuthorizationStatusReversed, models.GatewayAuthorizationStatusAuthorized,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reverse gateway holds: %w", err)
+	}
+
+	voidResult, err := tx.ExecContext(ctx, `
+		UPDATE transactions
+		SET status = $2, processed_at = CURRENT_TIMESTAMP
+		WHERE card_id = $1 AND status = $3`,
+		card.ID, models.TransactionStatusVoided, models.TransactionStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to void pending holds: %w", err)
+	}
+	holdsVoided, err := voidResult.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	result.HoldsVoided = int(holdsVoided)
+
+	controlResult, err := tx.ExecContext(ctx, `
+		UPDATE spending_controls
+		SET is_active = false, updated_at = CURRENT_TIMESTAMP
+		WHERE card_id = $1 AND is_active = true`, card.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke spending controls: %w", err)
+	}
+	controlsRevoked, err := controlResult.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	result.ControlsRevoked = int(controlsRevoked)
+
+	if card.Balance > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO transactions (id, card_id, company_id, transaction_type, amount, description, status, processed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)`,
+			uuid.New(), card.ID, card.CompanyID, models.TransactionTypeSweep, card.Balance,
+			"Offboarding balance sweep", models.TransactionStatusCompleted,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record balance sweep: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `UPDATE cards SET balance = 0 WHERE id = $1`, card.ID); err != nil {
+			return nil, fmt.Errorf("failed to clear card balance: %w", err)
+		}
+
+		if _, err = tx.ExecContext(ctx, `UPDATE companies SET balance = balance + $2 WHERE id = $1`, card.CompanyID, card.Balance); err != nil {
+			return nil, fmt.Errorf("failed to return balance to company: %w", err)
+		}
+
+		result.BalanceReturned = card.Balance
+	}
+
+	// A cancelled card is final, so blocking never downgrades it.
+	if card.Status != models.CardStatusCancelled {
+		newStatus := models.CardStatusBlocked
+		if offboarding.CardAction == models.OffboardingCardActionCancel {
+			newStatus = models.CardStatusCancelled
+		}
+
+		reason := "Employee offboarded"
+		if offboarding.Reason != nil && *offboarding.Reason != "" {
+			reason += ": " + *offboarding.Reason
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE cards
+			SET status = $2, blocked_at = CURRENT_TIMESTAMP, blocked_reason = $3
+			WHERE id = $1`, card.ID, newStatus, reason,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update card status: %w", err)
+		}
+		result.NewStatus = newStatus
+
+		eventType := models.EventCardStatusChanged
+		if newStatus == models.CardStatusBlocked {
+			eventType = models.EventCardBlocked
+		}
+		err = outbox.Enqueue(ctx, tx, &outbox.Event{
+			CompanyID: card.CompanyID,
+			Type:      eventType,
+			Data: models.CardStatusChangedEvent{
+				CardID: card.ID,
+				Status: newStatus,
+				Reason: reason,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO employee_offboarding_cards (
+			offboarding_id, card_id, last_four, previous_status, new_status,
+			balance_returned, holds_voided, controls_revoked
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING processed_at`,
+		result.OffboardingID, result.CardID, result.LastFour, result.PreviousStatus, result.NewStatus,
+		result.BalanceReturned, result.HoldsVoided, result.ControlsRevoked,
+	).Scan(&result.ProcessedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record offboarded card: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *repository) GetOffboardedCards(ctx context.Context, offboardingID uuid.UUID) ([]*models.OffboardedCard, error) {
+	query := `
+		SELECT offboarding_id, card_id, last_four, previous_status, new_status, balance_returned,
+		       holds_voided, controls_revoked, processed_at
+		FROM employee_offboarding_cards
+		WHERE offboarding_id = $1
+		ORDER BY processed_at ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, offboardingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offboarded cards: %w", err)
+	}
+	defer rows.Close()
+
+	var cards []*models.OffboardedCard
+	for rows.Next() {
+		card := &models.OffboardedCard{}
+		err := rows.Scan(
+			&card.OffboardingID, &card.CardID, &card.LastFour, &card.PreviousStatus, &card.NewStatus,
+			&card.BalanceReturned, &card.HoldsVoided, &card.ControlsRevoked, &card.ProcessedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan offboarded card: %w", err)
+		}
+		cards = append(cards, card)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return cards, nil
+}
+
+// FinishOffboarding records the final offboarding status. A completed
+// offboarding also marks the employee as offboarded.
+func (r *repository) FinishOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		UPDATE employee_offboardings
+		SET status = $2, last_error = $3,
+		    completed_at = CASE WHEN $2 = 'completed' THEN CURRENT_TIMESTAMP ELSE NULL END
+		WHERE id = $1
+		RETURNING completed_at`,
+		offboarding.ID, offboarding.Status, offboarding.LastError,
+	).Scan(&offboarding.CompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update offboarding: %w", err)
+	}
+
+	if offboarding.Status == models.OffboardingStatusCompleted {
+		_, err = tx.ExecContext(ctx, `UPDATE employees SET status = $3 WHERE company_id = $1 AND id = $2`,
+			offboarding.CompanyID, offboarding.EmployeeID, models.EmployeeStatusOffboarded)
+		if err != nil {
+			return fmt.Errorf("failed to update employee status: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
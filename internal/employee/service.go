@@ -0,0 +1,249 @@
+package employee
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/internal/api/response"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) CreateEmployee(ctx context.Context, companyID uuid.UUID, req *request.CreateEmployee) (*models.Employee, error) {
+	employee := &models.Employee{
+		ID:         uuid.New(),
+		CompanyID:  companyID,
+		ExternalID: req.ExternalID,
+		Email:      req.Email,
+		Name:       req.Name,
+		Department: req.Department,
+		CostCenter: req.CostCenter,
+		ManagerID:  req.ManagerID,
+		Status:     models.EmployeeStatusActive,
+	}
+
+	if err := s.validateManager(ctx, employee); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateEmployee(ctx, employee); err != nil {
+		return nil, err
+	}
+
+	return employee, nil
+}
+
+func (s *service) GetEmployee(ctx context.Context, companyID, id uuid.UUID) (*models.Employee, error) {
+	return s.repo.GetEmployeeByID(ctx, companyID, id)
+}
+
+func (s *service) ListEmployees(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Employee, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.GetEmployeesByCompanyID(ctx, companyID, status, limit, offset)
+}
+
+func (s *service) UpdateEmployee(ctx context.Context, companyID, id uuid.UUID, req *request.UpdateEmployee) (*models.Employee, error) {
+	employee, err := s.repo.GetEmployeeByID(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if employee == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	if req.ExternalID != nil {
+		employee.ExternalID = req.ExternalID
+	}
+	if req.Email != nil {
+		employee.Email = *req.Email
+	}
+	if req.Name != nil {
+		employee.Name = *req.Name
+	}
+	if req.Department != nil {
+		employee.Department = req.Department
+	}
+	if req.CostCenter != nil {
+		employee.CostCenter = req.CostCenter
+	}
+	if req.ManagerID != nil {
+		employee.ManagerID = req.ManagerID
+	}
+	if req.Status != nil {
+		employee.Status = *req.Status
+	}
+
+	if err := s.validateManager(ctx, employee); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateEmployee(ctx, employee); err != nil {
+		return nil, err
+	}
+
+	return employee, nil
+}
+
+func (s *service) DeleteEmployee(ctx context.Context, companyID, id uuid.UUID) error {
+	return s.repo.DeleteEmployee(ctx, companyID, id)
+}
+
+func (s *service) GetEmployeeCards(ctx context.Context, companyID, employeeID uuid.UUID) ([]*models.Card, error) {
+	employee, err := s.repo.GetEmployeeByID(ctx, companyID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if employee == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return s.repo.GetCardsByEmployeeID(ctx, companyID, employeeID)
+}
+
+// validateManager makes sure the manager exists within the same company and
+// is not the employee themselves.
+func (s *service) validateManager(ctx context.Context, employee *models.Employee) error {
+	if employee.ManagerID == nil {
+		return nil
+	}
+
+	if *employee.ManagerID == employee.ID {
+		return errors.ErrInvalidManager
+	}
+
+	manager, err := s.repo.GetEmployeeByID(ctx, employee.CompanyID, *employee.ManagerID)
+	if err != nil {
+		return err
+	}
+	if manager == nil {
+		return errors.ErrInvalidManager
+	}
+
+	return nil
+}
+
+// OffboardEmployee freezes every card held by the employee and returns their
+// balances to the company. Each card is handled in its own database
+// transaction and recorded against the offboarding, so calling it again after
+// a partial failure only processes the remaining cards. Once completed, later
+// calls return the stored report.
+func (s *service) OffboardEmployee(ctx context.Context, companyID, employeeID uuid.UUID, req *request.OffboardEmployee) (*response.OffboardingReport, error) {
+	employee, err := s.repo.GetEmployeeByID(ctx, companyID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if employee == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	existing, err := s.repo.GetOffboardingByEmployeeID(ctx, companyID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.Status == models.OffboardingStatusCompleted {
+		return s.buildOffboardingReport(ctx, existing)
+	}
+
+	offboarding := &models.EmployeeOffboarding{
+		ID:         uuid.New(),
+		CompanyID:  companyID,
+		EmployeeID: employeeID,
+		CardAction: req.CardAction,
+		Reason:     req.Reason,
+	}
+	if err := s.repo.StartOffboarding(ctx, offboarding); err != nil {
+		return nil, err
+	}
+
+	cards, err := s.repo.GetCardsByEmployeeID(ctx, companyID, employeeID)
+	if err != nil {
+		return nil, s.failOffboarding(ctx, offboarding, err)
+	}
+
+	for _, card := range cards {
+		if _, err := s.repo.OffboardCard(ctx, offboarding, card.ID); err != nil {
+			return nil, s.failOffboarding(ctx, offboarding, fmt.Errorf("card %s: %w", card.ID, err))
+		}
+	}
+
+	offboarding.Status = models.OffboardingStatusCompleted
+	if err := s.repo.FinishOffboarding(ctx, offboarding); err != nil {
+		return nil, err
+	}
+
+	return s.buildOffboardingReport(ctx, offboarding)
+}
+
+func (s *service) GetOffboardingReport(ctx context.Context, companyID, employeeID uuid.UUID) (*response.OffboardingReport, error) {
+	offboarding, err := s.repo.GetOffboardingByEmployeeID(ctx, companyID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	if offboarding == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return s.buildOffboardingReport(ctx, offboarding)
+}
+
+func (s *service) failOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding, cause error) error {
+	message := cause.Error()
+	offboarding.Status = models.OffboardingStatusFailed
+	offboarding.LastError = &message
+
+	if err := s.repo.FinishOffboarding(ctx, offboarding); err != nil {
+		return fmt.Errorf("offboarding failed: %v (and could not be recorded: %w)", cause, err)
+	}
+
+	return fmt.Errorf("offboarding failed: %w", cause)
+}
+
+func (s *service) buildOffboardingReport(ctx context.Context, offboarding *models.EmployeeOffboarding) (*response.OffboardingReport, error) {
+	cards, err := s.repo.GetOffboardedCards(ctx, offboarding.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &response.OffboardingReport{
+		OffboardingID:  offboarding.ID,
+		EmployeeID:     offboarding.EmployeeID,
+		CardAction:     offboarding.CardAction,
+		Status:         offboarding.Status,
+		LastError:      offboarding.LastError,
+		Cards:          cards,
+		CardsProcessed: len(cards),
+		StartedAt:      offboarding.StartedAt,
+		CompletedAt:    offboarding.CompletedAt,
+	}
+	if report.Cards == nil {
+		report.Cards = []*models.OffboardedCard{}
+	}
+
+	for _, card := range cards {
+		report.TotalBalanceReturned += card.BalanceReturned
+		report.TotalHoldsVoided += card.HoldsVoided
+		report.TotalControlsRevoked += card.ControlsRevoked
+	}
+
+	return report, nil
+}
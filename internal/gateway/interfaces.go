@@ -0,0 +1,28 @@
+package gateway
+
+import (
+	"context"
+
+	"ccards/pkg/authorization"
+	"ccards/pkg/iso8583"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+type Repository interface {
+	GetAuthorization(ctx context.Context, terminalID, rrn string) (*models.GatewayAuthorization, error)
+	CreateAuthorization(ctx context.Context, authorization *models.GatewayAuthorization, purchase *models.Transaction) error
+	RecordDecline(ctx context.Context, authorization *models.GatewayAuthorization, event *outbox.Event) error
+	CompleteAuthorization(ctx context.Context, terminalID, rrn string, amount float64) (*models.GatewayAuthorization, error)
+	ReverseAuthorization(ctx context.Context, terminalID, rrn string) (*models.GatewayAuthorization, error)
+}
+
+type Service interface {
+	Handle(ctx context.Context, msg *iso8583.Message) *iso8583.Message
+}
+
+// Authorizer decides whether a payment may go ahead; *authorization.Engine
+// implements it.
+type Authorizer interface {
+	Authorize(ctx context.Context, req *authorization.Request) (*authorization.Decision, error)
+}
@@ -0,0 +1,331 @@
+package gateway
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/ledger"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+const authorizationColumns = `id, transaction_id, card_id, mti, stan, rrn, terminal_id, auth_code,
+		response_code, amount, status, completed_at, reversed_at, created_at`
+
+func scanAuthorization(row models.RowScanner, authorization *models.GatewayAuthorization) error {
+	return row.Scan(
+		&authorization.ID, &authorization.TransactionID, &authorization.CardID, &authorization.MTI,
+		&authorization.STAN, &authorization.RRN, &authorization.TerminalID, &authorization.AuthCode,
+		&authorization.ResponseCode, &authorization.Amount, &authorization.Status, &authorization.CompletedAt,
+		&authorization.ReversedAt, &authorization.CreatedAt,
+	)
+}
+
+// GetAuthorization returns the request a terminal sent with a retrieval
+// reference number, or nil when there is none.
+func (r *repository) GetAuthorization(ctx context.Context, terminalID, rrn string) (*models.GatewayAuthorization, error) {
+	var authorization models.GatewayAuthorization
+	err := scanAuthorization(r.db.QueryRowContext(ctx, `
+		SELECT `+authorizationColumns+`
+		FROM gateway_authorizations
+		WHERE terminal_id = $1 AND rrn = $2`,
+		terminalID, rrn,
+	), &authorization)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gateway authorization: %w", err)
+	}
+
+	return &authorization, nil
+}
+
+// CreateAuthorization records an approved request with its purchase in one
+// transaction. A pending purchase is a hold: it reserves the amount on the
+// card until it is completed or reversed. A completed purchase is debited
+// from the card at once. Both need the amount available on the card after
+// its other holds. A terminal cannot use a retrieval reference number twice.
+func (r *repository) CreateAuthorization(ctx context.Context, authorization *models.GatewayAuthorization, purchase *models.Transaction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	available, err := ledger.AvailableBalance(ctx, tx, purchase.CardID)
+	if err != nil {
+		return err
+	}
+	if available < purchase.Amount {
+		return apperrors.ErrInsufficientFunds
+	}
+
+	var riskReasons []byte
+	if purchase.RiskReasons != nil {
+		if riskReasons, err = json.Marshal(purchase.RiskReasons); err != nil {
+			return fmt.Errorf("failed to encode risk reasons: %w", err)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO transactions (
+			id, card_id, company_id, transaction_type, amount,
+			merchant_name, merchant_category, mcc, merchant_id, description, status,
+			channel, terminal_id, processed_at,
+			risk_score, risk_action, risk_reasons, review_status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING created_at, updated_at`,
+		purchase.ID, purchase.CardID, purchase.CompanyID, purchase.TransactionType, purchase.Amount,
+		purchase.MerchantName, purchase.MerchantCategory, purchase.MCC, purchase.MerchantID, purchase.Description,
+		purchase.Status, purchase.Channel, purchase.TerminalID, purchase.ProcessedAt,
+		purchase.RiskScore, purchase.RiskAction, riskReasons, purchase.ReviewStatus,
+	).Scan(&purchase.CreatedAt, &purchase.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if purchase.Status == models.TransactionStatusCompleted {
+		if _, err := tx.ExecContext(ctx, `UPDATE cards SET balance = balance - $2 WHERE id = $1`, purchase.CardID, purchase.Amount); err != nil {
+			return fmt.Errorf("failed to debit card: %w", err)
+		}
+	}
+
+	if err := insertAuthorization(ctx, tx, authorization); err != nil {
+		return err
+	}
+
+	if purchase.Status == models.TransactionStatusCompleted {
+		err = outbox.Enqueue(ctx, tx, &outbox.Event{
+			CompanyID: purchase.CompanyID,
+			Type:      models.EventPaymentCompleted,
+			Data:      purchase,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record payment event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RecordDecline records a declined request, so a repeated request is
+// answered with the same response code, together with its payment.declined
+// event when there is one.
+func (r *repository) RecordDecline(ctx context.Context, authorization *models.GatewayAuthorization, event *outbox.Event) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertAuthorization(ctx, tx, authorization); err != nil {
+		return err
+	}
+
+	if event != nil {
+		if err := outbox.Enqueue(ctx, tx, event); err != nil {
+			return fmt.Errorf("failed to record payment event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CompleteAuthorization settles the hold a terminal placed with a retrieval
+// reference number for amount, which cannot be more than the amount held.
+// The purchase is debited from the card for amount and the rest of the hold
+// is released. Completing a hold twice changes nothing, since acquirers
+// repeat advices until they are answered. It returns nil when the terminal
+// has no such request.
+func (r *repository) CompleteAuthorization(ctx context.Context, terminalID, rrn string, amount float64) (*models.GatewayAuthorization, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	authorization, err := lockAuthorization(ctx, tx, terminalID, rrn)
+	if err != nil || authorization == nil {
+		return nil, err
+	}
+	switch authorization.Status {
+	case models.GatewayAuthorizationStatusCompleted:
+		return authorization, nil
+	case models.GatewayAuthorizationStatusAuthorized:
+	default:
+		return nil, apperrors.ErrPaymentTransition
+	}
+	if amount > authorization.Amount {
+		return nil, apperrors.ErrPaymentAmount
+	}
+
+	// The hold being completed is part of what is held on the card.
+	available, err := ledger.AvailableBalance(ctx, tx, *authorization.CardID)
+	if err != nil {
+		return nil, err
+	}
+	if available+authorization.Amount < amount {
+		return nil, apperrors.ErrInsufficientFunds
+	}
+
+	var purchase models.Transaction
+	err = models.ScanTransaction(tx.QueryRowContext(ctx, `
+		UPDATE transactions
+		SET amount = $2, status = $3, processed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+models.TransactionColumns,
+		authorization.TransactionID, amount, models.TransactionStatusCompleted,
+	), &purchase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete purchase: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE cards SET balance = balance - $2 WHERE id = $1`, authorization.CardID, amount); err != nil {
+		return nil, fmt.Errorf("failed to debit card: %w", err)
+	}
+
+	err = scanAuthorization(tx.QueryRowContext(ctx, `
+		UPDATE gateway_authorizations
+		SET amount = $2, status = $3, completed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+authorizationColumns,
+		authorization.ID, amount, models.GatewayAuthorizationStatusCompleted,
+	), authorization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete gateway authorization: %w", err)
+	}
+
+	err = outbox.Enqueue(ctx, tx, &outbox.Event{
+		CompanyID: purchase.CompanyID,
+		Type:      models.EventPaymentCompleted,
+		Data:      &purchase,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record payment event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return authorization, nil
+}
+
+// ReverseAuthorization voids the purchase of an approved request. A hold is
+// released; the part of a settled purchase not yet credited back by a dispute
+// is returned to the card. Reversing a request
+// twice changes nothing, since acquirers repeat reversals until they are
+// answered. It returns nil when the terminal has no approved request with
+// the retrieval reference number.
+func (r *repository) ReverseAuthorization(ctx context.Context, terminalID, rrn string) (*models.GatewayAuthorization, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	authorization, err := lockAuthorization(ctx, tx, terminalID, rrn)
+	if err != nil || authorization == nil {
+		return nil, err
+	}
+	switch authorization.Status {
+	case models.GatewayAuthorizationStatusReversed:
+		return authorization, nil
+	case models.GatewayAuthorizationStatusDeclined:
+		return nil, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE transactions SET status = $2 WHERE id = $1`, authorization.TransactionID, models.TransactionStatusVoided); err != nil {
+		return nil, fmt.Errorf("failed to void purchase: %w", err)
+	}
+	if authorization.Status != models.GatewayAuthorizationStatusAuthorized {
+		purchase, err := ledger.LockPurchase(ctx, tx, *authorization.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if credit := purchase.Refundable(); credit > 0 {
+			if _, err := ledger.Credit(ctx, tx, purchase, credit); err != nil {
+				return nil, fmt.Errorf("failed to credit card: %w", err)
+			}
+		}
+	}
+
+	err = scanAuthorization(tx.QueryRowContext(ctx, `
+		UPDATE gateway_authorizations
+		SET status = $2, reversed_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+authorizationColumns,
+		authorization.ID, models.GatewayAuthorizationStatusReversed,
+	), authorization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reverse gateway authorization: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return authorization, nil
+}
+
+func lockAuthorization(ctx context.Context, tx *sql.Tx, terminalID, rrn string) (*models.GatewayAuthorization, error) {
+	var authorization models.GatewayAuthorization
+	err := scanAuthorization(tx.QueryRowContext(ctx, `
+		SELECT `+authorizationColumns+`
+		FROM gateway_authorizations
+		WHERE terminal_id = $1 AND rrn = $2
+		FOR UPDATE`,
+		terminalID, rrn,
+	), &authorization)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock gateway authorization: %w", err)
+	}
+
+	return &authorization, nil
+}
+
+func insertAuthorization(ctx context.Context, tx *sql.Tx, authorization *models.GatewayAuthorization) error {
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO gateway_authorizations (
+			id, transaction_id, card_id, mti, stan, rrn, terminal_id, auth_code, response_code, amount, status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING created_at`,
+		authorization.ID, authorization.TransactionID, authorization.CardID, authorization.MTI, authorization.STAN,
+		authorization.RRN, authorization.TerminalID, authorization.AuthCode, authorization.ResponseCode,
+		authorization.Amount, authorization.Status,
+	).Scan(&authorization.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return apperrors.ErrPaymentExists
+		}
+		return fmt.Errorf("failed to create gateway authorization: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"ccards/pkg/config"
+	"ccards/pkg/iso8583"
+)
+
+// Server accepts ISO 8583 connections from the card processor. Messages on a
+// connection are answered in the order they arrive.
+type Server struct {
+	service Service
+	config  config.GatewayConfig
+}
+
+func NewServer(service Service, gatewayConfig config.GatewayConfig) *Server {
+	return &Server{
+		service: service,
+		config:  gatewayConfig,
+	}
+}
+
+// Start listens on the configured address and serves connections until ctx
+// is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.GetAddress())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting ISO 8583 gateway on %s", listener.Addr())
+	go s.Serve(ctx, listener)
+	return nil
+}
+
+// Serve accepts connections on listener until ctx is cancelled, then closes
+// the listener and every open connection.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("gateway: failed to accept connection: %v", err)
+			}
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(s.config.IdleTimeout)); err != nil {
+			return
+		}
+
+		frame, err := iso8583.ReadFrame(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.Printf("gateway: closing connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		resp := s.handleFrame(ctx, frame)
+		if resp == nil {
+			log.Printf("gateway: closing connection from %s: unreadable message", conn.RemoteAddr())
+			return
+		}
+		if err := iso8583.WriteMessage(conn, resp); err != nil {
+			log.Printf("gateway: failed to write response to %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handleFrame answers one message. A message that cannot be read is answered
+// with a format error when its MTI can be made out, and not at all otherwise.
+func (s *Server) handleFrame(ctx context.Context, frame []byte) *iso8583.Message {
+	msg, err := iso8583.Unpack(frame)
+	if err != nil {
+		log.Printf("gateway: %v", err)
+		if len(frame) < 4 || strings.Trim(string(frame[:4]), "0123456789") != "" {
+			return nil
+		}
+
+		resp := iso8583.NewMessage(iso8583.ResponseMTI(string(frame[:4])))
+		resp.Set(iso8583.FieldResponseCode, iso8583.ResponseFormatError)
+		return resp
+	}
+
+	return s.service.Handle(ctx, msg)
+}
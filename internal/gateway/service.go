@@ -0,0 +1,414 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/authorization"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/iso8583"
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+)
+
+// Fields echoed from a request into its response.
+var (
+	authorizationResponseFields = []int{
+		iso8583.FieldProcessingCode, iso8583.FieldAmount, iso8583.FieldTransmissionDateTime, iso8583.FieldSTAN,
+		iso8583.FieldLocalTime, iso8583.FieldLocalDate, iso8583.FieldAcquirerID, iso8583.FieldRRN,
+		iso8583.FieldTerminalID, iso8583.FieldCardAcceptorID, iso8583.FieldCurrencyCode,
+	}
+	reversalResponseFields = []int{
+		iso8583.FieldProcessingCode, iso8583.FieldAmount, iso8583.FieldTransmissionDateTime, iso8583.FieldSTAN,
+		iso8583.FieldAcquirerID, iso8583.FieldRRN, iso8583.FieldTerminalID, iso8583.FieldCardAcceptorID,
+		iso8583.FieldCurrencyCode, iso8583.FieldOriginalDataElements,
+	}
+)
+
+// merchantNameLength is the length of the name in the card acceptor name and
+// location field; city and country follow it.
+const merchantNameLength = 23
+
+type service struct {
+	repo       Repository
+	authorizer Authorizer
+	vault      *vault.Vault
+	config     config.GatewayConfig
+}
+
+// NewService creates a new gateway service
+func NewService(repo Repository, authorizer Authorizer, cardVault *vault.Vault, gatewayConfig config.GatewayConfig) Service {
+	return &service{
+		repo:       repo,
+		authorizer: authorizer,
+		vault:      cardVault,
+		config:     gatewayConfig,
+	}
+}
+
+// Handle answers an authorization (0100), financial (0200), financial
+// advice (0220) or reversal (0400) request. Any other message is answered as
+// an invalid transaction.
+func (s *service) Handle(ctx context.Context, msg *iso8583.Message) *iso8583.Message {
+	switch msg.MTI {
+	case iso8583.MTIAuthorizationRequest, iso8583.MTIFinancialRequest:
+		return s.authorize(ctx, msg)
+	case iso8583.MTIFinancialAdvice:
+		return s.complete(ctx, msg)
+	case iso8583.MTIReversalRequest:
+		return s.reverse(ctx, msg)
+	default:
+		resp := iso8583.NewMessage(iso8583.ResponseMTI(msg.MTI))
+		resp.Copy(msg, iso8583.FieldSTAN, iso8583.FieldRRN, iso8583.FieldTerminalID)
+		resp.Set(iso8583.FieldResponseCode, iso8583.ResponseInvalidTransaction)
+		return resp
+	}
+}
+
+// authorize runs the payment through the authorization checks. An approved
+// authorization request places a hold on the card that a financial advice
+// completes; an approved financial request is debited at once. A repeated
+// request is answered with the original response without running the checks
+// again, and a retrieval reference number the terminal used for another
+// request is answered as a duplicate.
+func (s *service) authorize(ctx context.Context, msg *iso8583.Message) *iso8583.Message {
+	resp := iso8583.NewMessage(iso8583.ResponseMTI(msg.MTI))
+	resp.Copy(msg, authorizationResponseFields...)
+	respond := func(code string) *iso8583.Message {
+		resp.Set(iso8583.FieldResponseCode, code)
+		return resp
+	}
+
+	for _, field := range []int{iso8583.FieldPAN, iso8583.FieldProcessingCode, iso8583.FieldAmount,
+		iso8583.FieldSTAN, iso8583.FieldRRN, iso8583.FieldTerminalID} {
+		if strings.TrimSpace(msg.Get(field)) == "" {
+			return respond(iso8583.ResponseFormatError)
+		}
+	}
+
+	channel, ok := s.channel(msg)
+	if !ok {
+		return respond(iso8583.ResponseInvalidTransaction)
+	}
+	if msg.Has(iso8583.FieldCurrencyCode) && msg.Get(iso8583.FieldCurrencyCode) != s.config.CurrencyCode {
+		return respond(iso8583.ResponseInvalidTransaction)
+	}
+
+	amount, err := s.amount(msg.Get(iso8583.FieldAmount))
+	if err != nil {
+		return respond(iso8583.ResponseInvalidAmount)
+	}
+
+	var expiryYear, expiryMonth int
+	if msg.Has(iso8583.FieldExpiryDate) {
+		expiryYear, expiryMonth, err = expiryDate(msg.Get(iso8583.FieldExpiryDate))
+		if err != nil {
+			return respond(iso8583.ResponseFormatError)
+		}
+	}
+
+	terminalID := strings.TrimSpace(msg.Get(iso8583.FieldTerminalID))
+	rrn := strings.TrimSpace(msg.Get(iso8583.FieldRRN))
+	stan := msg.Get(iso8583.FieldSTAN)
+
+	original, err := s.repo.GetAuthorization(ctx, terminalID, rrn)
+	if err != nil {
+		log.Printf("gateway: failed to look up payment %s: %v", rrn, err)
+		return respond(iso8583.ResponseSystemError)
+	}
+	if original != nil {
+		if original.MTI != msg.MTI || original.STAN != stan {
+			return respond(iso8583.ResponseDuplicate)
+		}
+		if original.AuthCode != "" {
+			resp.Set(iso8583.FieldAuthorizationCode, original.AuthCode)
+		}
+		return respond(original.ResponseCode)
+	}
+
+	// Payments without an MCC are filed under "other"; the authorization
+	// checks resolve an MCC to its category.
+	req := &authorization.Request{
+		PANFingerprint:   s.vault.Fingerprint(msg.Get(iso8583.FieldPAN)),
+		Amount:           amount,
+		MerchantName:     merchantName(msg.Get(iso8583.FieldCardAcceptorNameLocale)),
+		MerchantCategory: models.MerchantCategoryOther,
+		MCC:              msg.Get(iso8583.FieldMerchantType),
+		Channel:          channel,
+		TerminalID:       terminalID,
+		ExpiryYear:       expiryYear,
+		ExpiryMonth:      expiryMonth,
+	}
+
+	answer := &models.GatewayAuthorization{
+		ID:         uuid.New(),
+		MTI:        msg.MTI,
+		STAN:       stan,
+		RRN:        rrn,
+		TerminalID: terminalID,
+		Amount:     amount,
+	}
+	decline := func(code string, declined *authorization.Decline) *iso8583.Message {
+		answer.Status = models.GatewayAuthorizationStatusDeclined
+		answer.ResponseCode = code
+		if req.Card != nil {
+			answer.CardID = &req.Card.ID
+		}
+		if err := s.repo.RecordDecline(ctx, answer, authorization.DeclinedEvent(req, declined)); err != nil {
+			if errors.Is(err, errors.ErrPaymentExists) {
+				return respond(iso8583.ResponseDuplicate)
+			}
+			log.Printf("gateway: failed to record declined payment %s: %v", rrn, err)
+		}
+		return respond(code)
+	}
+
+	decision, err := s.authorizer.Authorize(ctx, req)
+	if err != nil {
+		log.Printf("gateway: failed to authorize payment %s: %v", rrn, err)
+		return respond(iso8583.ResponseSystemError)
+	}
+	if !decision.Approved {
+		return decline(responseCode(decision.Decline.Reason), decision.Decline)
+	}
+
+	now := time.Now()
+	purchase := &models.Transaction{
+		ID:               uuid.New(),
+		CardID:           req.Card.ID,
+		CompanyID:        req.Card.CompanyID,
+		TransactionType:  models.TransactionTypePurchase,
+		Amount:           amount,
+		MerchantName:     optionalString(req.MerchantName),
+		MerchantCategory: &req.MerchantCategory,
+		MCC:              optionalString(req.MCC),
+		MerchantID:       req.MerchantID,
+		Description:      "Card purchase",
+		Status:           models.TransactionStatusCompleted,
+		Channel:          channel,
+		TerminalID:       optionalString(req.TerminalID),
+		ProcessedAt:      &now,
+	}
+	if req.Risk != nil {
+		score, action := req.Risk.Score, req.Risk.Action
+		purchase.RiskScore = &score
+		purchase.RiskAction = &action
+		purchase.RiskReasons = req.Risk.Reasons
+		if action == models.RiskActionReview {
+			reviewStatus := models.ReviewStatusPending
+			purchase.ReviewStatus = &reviewStatus
+		}
+	}
+
+	answer.TransactionID = &purchase.ID
+	answer.CardID = &req.Card.ID
+	answer.AuthCode = fmt.Sprintf("%06d", rand.IntN(1000000))
+	answer.ResponseCode = iso8583.ResponseApproved
+	answer.Status = models.GatewayAuthorizationStatusApproved
+	if msg.MTI == iso8583.MTIAuthorizationRequest {
+		purchase.Status = models.TransactionStatusPending
+		purchase.ProcessedAt = nil
+		answer.Status = models.GatewayAuthorizationStatusAuthorized
+	}
+
+	if err := s.repo.CreateAuthorization(ctx, answer, purchase); err != nil {
+		switch {
+		case errors.Is(err, errors.ErrInsufficientFunds):
+			answer.TransactionID = nil
+			answer.AuthCode = ""
+			return decline(iso8583.ResponseInsufficientFunds, &authorization.Decline{
+				Reason:  authorization.ReasonInsufficientFunds,
+				Message: "Insufficient balance",
+			})
+		case errors.Is(err, errors.ErrPaymentExists):
+			return respond(iso8583.ResponseDuplicate)
+		default:
+			log.Printf("gateway: failed to record payment %s: %v", rrn, err)
+			return respond(iso8583.ResponseSystemError)
+		}
+	}
+
+	resp.Set(iso8583.FieldAuthorizationCode, answer.AuthCode)
+	return respond(iso8583.ResponseApproved)
+}
+
+// complete settles the hold an authorization request placed, found by
+// terminal and retrieval reference number, for the amount in the advice. The
+// amount cannot be more than the amount held; the rest of the hold is
+// released.
+func (s *service) complete(ctx context.Context, msg *iso8583.Message) *iso8583.Message {
+	resp := iso8583.NewMessage(iso8583.MTIFinancialAdviceResponse)
+	resp.Copy(msg, authorizationResponseFields...)
+	respond := func(code string) *iso8583.Message {
+		resp.Set(iso8583.FieldResponseCode, code)
+		return resp
+	}
+
+	terminalID := strings.TrimSpace(msg.Get(iso8583.FieldTerminalID))
+	rrn := strings.TrimSpace(msg.Get(iso8583.FieldRRN))
+	if terminalID == "" || rrn == "" || !msg.Has(iso8583.FieldSTAN) || !msg.Has(iso8583.FieldAmount) {
+		return respond(iso8583.ResponseFormatError)
+	}
+
+	amount, err := s.amount(msg.Get(iso8583.FieldAmount))
+	if err != nil {
+		return respond(iso8583.ResponseInvalidAmount)
+	}
+
+	completed, err := s.repo.CompleteAuthorization(ctx, terminalID, rrn, amount)
+	switch {
+	case errors.Is(err, errors.ErrPaymentAmount):
+		return respond(iso8583.ResponseInvalidAmount)
+	case errors.Is(err, errors.ErrPaymentTransition):
+		return respond(iso8583.ResponseInvalidTransaction)
+	case errors.Is(err, errors.ErrInsufficientFunds):
+		return respond(iso8583.ResponseInsufficientFunds)
+	case err != nil:
+		log.Printf("gateway: failed to complete payment %s: %v", rrn, err)
+		return respond(iso8583.ResponseSystemError)
+	case completed == nil:
+		return respond(iso8583.ResponseRecordNotFound)
+	}
+
+	resp.Set(iso8583.FieldAuthorizationCode, completed.AuthCode)
+	return respond(iso8583.ResponseApproved)
+}
+
+// reverse cancels the payment a terminal made with the retrieval reference
+// number in the request. Only full reversals are supported.
+func (s *service) reverse(ctx context.Context, msg *iso8583.Message) *iso8583.Message {
+	resp := iso8583.NewMessage(iso8583.MTIReversalResponse)
+	resp.Copy(msg, reversalResponseFields...)
+	respond := func(code string) *iso8583.Message {
+		resp.Set(iso8583.FieldResponseCode, code)
+		return resp
+	}
+
+	terminalID := strings.TrimSpace(msg.Get(iso8583.FieldTerminalID))
+	rrn := strings.TrimSpace(msg.Get(iso8583.FieldRRN))
+	if terminalID == "" || rrn == "" || !msg.Has(iso8583.FieldSTAN) {
+		return respond(iso8583.ResponseFormatError)
+	}
+
+	reversed, err := s.repo.ReverseAuthorization(ctx, terminalID, rrn)
+	if err != nil {
+		log.Printf("gateway: failed to reverse payment %s: %v", rrn, err)
+		return respond(iso8583.ResponseSystemError)
+	}
+	if reversed == nil {
+		return respond(iso8583.ResponseRecordNotFound)
+	}
+
+	return respond(iso8583.ResponseApproved)
+}
+
+// channel maps the processing code and POS entry mode to a transaction
+// channel. Only purchases and cash withdrawals are supported.
+func (s *service) channel(msg *iso8583.Message) (string, bool) {
+	processingCode := msg.Get(iso8583.FieldProcessingCode)
+	if len(processingCode) < 2 {
+		return "", false
+	}
+	switch processingCode[:2] {
+	case "00":
+	case "01":
+		return models.TransactionChannelATM, true
+	default:
+		return "", false
+	}
+
+	entryMode := msg.Get(iso8583.FieldPOSEntryMode)
+	if len(entryMode) < 2 {
+		return models.TransactionChannelOnline, true
+	}
+	switch entryMode[:2] {
+	case "05", "95":
+		return models.TransactionChannelChip, true
+	case "07", "91":
+		return models.TransactionChannelContactless, true
+	default:
+		return models.TransactionChannelOnline, true
+	}
+}
+
+// amount converts an amount in minor units of the gateway currency.
+func (s *service) amount(value string) (float64, error) {
+	minor, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if minor <= 0 {
+		return 0, fmt.Errorf("amount must be greater than zero")
+	}
+
+	return float64(minor) / math.Pow10(s.config.CurrencyExponent), nil
+}
+
+// expiryDate parses an expiry date in YYMM form.
+func expiryDate(value string) (int, int, error) {
+	if len(value) != 4 {
+		return 0, 0, fmt.Errorf("expiry date must be 4 digits")
+	}
+	year, err := strconv.Atoi(value[:2])
+	if err != nil {
+		return 0, 0, err
+	}
+	month, err := strconv.Atoi(value[2:])
+	if err != nil {
+		return 0, 0, err
+	}
+	if month < 1 || month > 12 {
+		return 0, 0, fmt.Errorf("invalid expiry month %d", month)
+	}
+
+	return 2000 + year, month, nil
+}
+
+// responseCode maps a decline reason to its ISO 8583 response code.
+func responseCode(reason string) string {
+	switch reason {
+	case authorization.ReasonCardNotFound:
+		return iso8583.ResponseInvalidCard
+	case authorization.ReasonExpiryMismatch, authorization.ReasonCardExpired:
+		return iso8583.ResponseExpiredCard
+	case authorization.ReasonCardInactive:
+		return iso8583.ResponseRestrictedCard
+	case authorization.ReasonPINLocked:
+		return iso8583.ResponsePINTriesExceeded
+	case authorization.ReasonInsufficientFunds:
+		return iso8583.ResponseInsufficientFunds
+	case authorization.ReasonSpendingLimit, authorization.ReasonDailyLimit, authorization.ReasonMonthlyLimit:
+		return iso8583.ResponseExceedsLimit
+	case authorization.ReasonVelocity:
+		return iso8583.ResponseExceedsFrequency
+	case authorization.ReasonSuspectedFraud:
+		return iso8583.ResponseSuspectedFraud
+	case authorization.ReasonSpendingControl:
+		return iso8583.ResponseNotPermitted
+	default:
+		return iso8583.ResponseDoNotHonor
+	}
+}
+
+func merchantName(nameLocation string) string {
+	if len(nameLocation) > merchantNameLength {
+		nameLocation = nameLocation[:merchantNameLength]
+	}
+	return strings.TrimSpace(nameLocation)
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
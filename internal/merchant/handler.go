@@ -0,0 +1,95 @@
+package merchant
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) GetCategoryCodes(c *gin.Context) {
+	codes, err := h.service.ListCategoryCodes(c.Request.Context(), c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merchant category codes"})
+		return
+	}
+	if codes == nil {
+		codes = []*models.MerchantCategoryCode{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"codes": codes,
+		"count": len(codes),
+	})
+}
+
+func (h *Handler) CreateMerchant(c *gin.Context) {
+	var req request.CreateMerchant
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	merchant, err := h.service.CreateMerchant(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrUnknownMCC):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown merchant category code"})
+		case errors.Is(err, errors.ErrMerchantExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Merchant with this name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create merchant"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, merchant)
+}
+
+func (h *Handler) GetMerchants(c *gin.Context) {
+	merchants, err := h.service.ListMerchants(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merchants"})
+		return
+	}
+	if merchants == nil {
+		merchants = []*models.Merchant{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"merchants": merchants,
+		"count":     len(merchants),
+	})
+}
+
+func (h *Handler) GetMerchant(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merchant ID format"})
+		return
+	}
+
+	merchant, err := h.service.GetMerchant(c.Request.Context(), merchantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve merchant"})
+		return
+	}
+	if merchant == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merchant not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, merchant)
+}
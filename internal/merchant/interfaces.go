@@ -0,0 +1,27 @@
+package merchant
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	GetCategoryCodes(ctx context.Context, category string) ([]*models.MerchantCategoryCode, error)
+	GetCategoryCode(ctx context.Context, mcc string) (*models.MerchantCategoryCode, error)
+
+	CreateMerchant(ctx context.Context, merchant *models.Merchant) error
+	GetMerchantByID(ctx context.Context, id uuid.UUID) (*models.Merchant, error)
+	GetMerchants(ctx context.Context) ([]*models.Merchant, error)
+}
+
+type Service interface {
+	ListCategoryCodes(ctx context.Context, category string) ([]*models.MerchantCategoryCode, error)
+
+	CreateMerchant(ctx context.Context, req *request.CreateMerchant) (*models.Merchant, error)
+	GetMerchant(ctx context.Context, id uuid.UUID) (*models.Merchant, error)
+	ListMerchants(ctx context.Context) ([]*models.Merchant, error)
+}
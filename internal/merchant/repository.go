@@ -0,0 +1,135 @@
+package merchant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// GetCategoryCodes lists the MCC catalogue, optionally limited to one
+// category.
+func (r *repository) GetCategoryCodes(ctx context.Context, category string) ([]*models.MerchantCategoryCode, error) {
+	query := `
+		SELECT mcc, description, category
+		FROM merchant_category_codes
+		WHERE $1 = '' OR category = $1
+		ORDER BY mcc`
+
+	rows, err := r.db.QueryContext(ctx, query, category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merchant category codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []*models.MerchantCategoryCode
+	for rows.Next() {
+		code := &models.MerchantCategoryCode{}
+		if err := rows.Scan(&code.MCC, &code.Description, &code.Category); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant category code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return codes, nil
+}
+
+func (r *repository) GetCategoryCode(ctx context.Context, mcc string) (*models.MerchantCategoryCode, error) {
+	code := &models.MerchantCategoryCode{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT mcc, description, category
+		FROM merchant_category_codes
+		WHERE mcc = $1`,
+		mcc,
+	).Scan(&code.MCC, &code.Description, &code.Category)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get merchant category code: %w", err)
+	}
+
+	return code, nil
+}
+
+func (r *repository) CreateMerchant(ctx context.Context, merchant *models.Merchant) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO merchants (id, name, mcc)
+		VALUES ($1, $2, $3)
+		RETURNING created_at`,
+		merchant.ID, merchant.Name, merchant.MCC,
+	).Scan(&merchant.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return apperrors.ErrMerchantExists
+		}
+		return fmt.Errorf("failed to create merchant: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetMerchantByID(ctx context.Context, id uuid.UUID) (*models.Merchant, error) {
+	merchant := &models.Merchant{}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT m.id, m.name, m.mcc, c.category, m.created_at
+		FROM merchants m
+		JOIN merchant_category_codes c ON c.mcc = m.mcc
+		WHERE m.id = $1`,
+		id,
+	).Scan(&merchant.ID, &merchant.Name, &merchant.MCC, &merchant.Category, &merchant.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get merchant: %w", err)
+	}
+
+	return merchant, nil
+}
+
+func (r *repository) GetMerchants(ctx context.Context) ([]*models.Merchant, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.name, m.mcc, c.category, m.created_at
+		FROM merchants m
+		JOIN merchant_category_codes c ON c.mcc = m.mcc
+		ORDER BY m.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merchants: %w", err)
+	}
+	defer rows.Close()
+
+	var merchants []*models.Merchant
+	for rows.Next() {
+		merchant := &models.Merchant{}
+		if err := rows.Scan(&merchant.ID, &merchant.Name, &merchant.MCC, &merchant.Category, &merchant.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant: %w", err)
+		}
+		merchants = append(merchants, merchant)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return merchants, nil
+}
@@ -0,0 +1,57 @@
+package merchant
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) ListCategoryCodes(ctx context.Context, category string) ([]*models.MerchantCategoryCode, error) {
+	return s.repo.GetCategoryCodes(ctx, strings.ToLower(strings.TrimSpace(category)))
+}
+
+// CreateMerchant registers a merchant under a catalogued MCC. Payments that
+// name the merchant take their MCC and category from the registry.
+func (s *service) CreateMerchant(ctx context.Context, req *request.CreateMerchant) (*models.Merchant, error) {
+	code, err := s.repo.GetCategoryCode(ctx, req.MCC)
+	if err != nil {
+		return nil, err
+	}
+	if code == nil {
+		return nil, errors.ErrUnknownMCC
+	}
+
+	merchant := &models.Merchant{
+		ID:       uuid.New(),
+		Name:     strings.TrimSpace(req.Name),
+		MCC:      code.MCC,
+		Category: code.Category,
+	}
+
+	if err := s.repo.CreateMerchant(ctx, merchant); err != nil {
+		return nil, err
+	}
+
+	return merchant, nil
+}
+
+func (s *service) GetMerchant(ctx context.Context, id uuid.UUID) (*models.Merchant, error) {
+	return s.repo.GetMerchantByID(ctx, id)
+}
+
+func (s *service) ListMerchants(ctx context.Context) ([]*models.Merchant, error) {
+	return s.repo.GetMerchants(ctx)
+}
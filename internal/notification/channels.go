@@ -0,0 +1,196 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ccards/pkg/config"
+	"ccards/pkg/endpoint"
+	"ccards/pkg/models"
+)
+
+// maxResponseBytes bounds how much of a webhook recipient's response is read.
+const maxResponseBytes = 64 << 10
+
+type emailChannel struct {
+	config config.SMTPConfig
+}
+
+// NewEmailChannel returns a channel that emails notifications through an
+// SMTP server. STARTTLS is used when the server offers it.
+func NewEmailChannel(smtpConfig config.SMTPConfig) Channel {
+	return &emailChannel{config: smtpConfig}
+}
+
+func (e *emailChannel) Name() string {
+	return models.NotificationChannelEmail
+}
+
+func (e *emailChannel) Send(ctx context.Context, notification *models.Notification, recipients []string) error {
+	addr := net.JoinHostPort(e.config.Host, strconv.Itoa(e.config.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mail server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: e.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if e.config.Username != "" {
+		auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate with mail server: %w", err)
+		}
+	}
+
+	if err := client.Mail(e.config.From); err != nil {
+		return fmt.Errorf("mail server rejected sender: %w", err)
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("mail server rejected recipient %s: %w", recipient, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(e.message(notification, recipients)); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return client.Quit()
+}
+
+// message builds a plain text email. Line breaks in the subject are replaced,
+// so a template cannot add headers.
+func (e *emailChannel) message(notification *models.Notification, recipients []string) []byte {
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(notification.Subject)
+	body := strings.ReplaceAll(strings.ReplaceAll(notification.Body, "\r\n", "\n"), "\n", "\r\n")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", notification.CreatedAt.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@ccards>\r\n", notification.ID)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(body)
+	msg.WriteString("\r\n")
+	return msg.Bytes()
+}
+
+type webhookChannel struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+// NewWebhookChannel returns a channel that posts notifications as JSON to the
+// recipient URLs. Any 2xx response counts as delivered. Recipients are held
+// to the same rules as webhook endpoints: https and public addresses only,
+// checked when they are saved and again on every connection, unless
+// allowPrivate is set.
+func NewWebhookChannel(timeout time.Duration, allowPrivate bool) Channel {
+	return &webhookChannel{
+		client:       endpoint.NewClient(timeout, allowPrivate),
+		allowPrivate: allowPrivate,
+	}
+}
+
+func (w *webhookChannel) Name() string {
+	return models.NotificationChannelWebhook
+}
+
+func (w *webhookChannel) CheckRecipient(ctx context.Context, recipient string) error {
+	if w.allowPrivate {
+		return checkHTTPURL(recipient)
+	}
+	return endpoint.CheckURL(ctx, recipient)
+}
+
+// checkHTTPURL accepts any absolute http or https URL.
+func checkHTTPURL(recipient string) error {
+	u, err := url.Parse(recipient)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("not an http or https URL")
+	}
+	return nil
+}
+
+func (w *webhookChannel) Send(ctx context.Context, notification *models.Notification, recipients []string) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	for _, url := range recipients {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("invalid webhook URL %s: %w", url, err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "ccards-notifications/1.0")
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("%s responded with status %d", url, resp.StatusCode)
+		}
+	}
+
+	return nil
+}
+
+type inAppChannel struct {
+	repo Repository
+}
+
+// NewInAppChannel returns a channel that puts notifications in the company's
+// in-app inbox.
+func NewInAppChannel(repo Repository) Channel {
+	return &inAppChannel{repo: repo}
+}
+
+func (i *inAppChannel) Name() string {
+	return models.NotificationChannelInApp
+}
+
+func (i *inAppChannel) Send(ctx context.Context, notification *models.Notification, recipients []string) error {
+	return i.repo.AddToInbox(ctx, notification)
+}
@@ -1 +1,232 @@
 package notification
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetPreferences lists the company's preference for every trigger and
+// channel, including the defaults it has not changed.
+func (h *Handler) GetPreferences(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	preferences, err := h.service.GetPreferences(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": preferences,
+		"count":       len(preferences),
+	})
+}
+
+func (h *Handler) UpdatePreference(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.NotificationPreference
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preference, err := h.service.UpdatePreference(c.Request.Context(), companyID, &req)
+	if err != nil {
+		notificationErrorResponse(c, err, "Failed to update notification preference")
+		return
+	}
+
+	c.JSON(http.StatusOK, preference)
+}
+
+// GetTemplates lists the template of every trigger. is_custom tells a
+// company's own template from the built-in one.
+func (h *Handler) GetTemplates(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templates, err := h.service.GetTemplates(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func (h *Handler) UpdateTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.NotificationTemplate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.service.UpdateTemplate(c.Request.Context(), companyID, c.Param("trigger"), &req)
+	if err != nil {
+		notificationErrorResponse(c, err, "Failed to update notification template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// ResetTemplate drops the company's template of a trigger and returns the
+// built-in one used from now on.
+func (h *Handler) ResetTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	template, err := h.service.ResetTemplate(c.Request.Context(), companyID, c.Param("trigger"))
+	if err != nil {
+		notificationErrorResponse(c, err, "Failed to reset notification template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// GetInbox lists the company's in-app notifications, newest first, with the
+// number still unread. unread=true lists only those.
+func (h *Handler) GetInbox(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	unreadOnly := utils.GetBoolParam(c, "unread", false)
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+
+	notifications, err := h.service.ListInbox(c.Request.Context(), companyID, unreadOnly, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inbox"})
+		return
+	}
+	if notifications == nil {
+		notifications = []*models.InboxNotification{}
+	}
+
+	unread, err := h.service.GetUnreadCount(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve inbox"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"count":         len(notifications),
+		"unread_count":  unread,
+		"page":          page,
+		"page_size":     pageSize,
+	})
+}
+
+func (h *Handler) GetUnreadCount(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	unread, err := h.service.GetUnreadCount(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+func (h *Handler) MarkRead(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	inboxID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID format"})
+		return
+	}
+
+	inbox, err := h.service.MarkRead(c.Request.Context(), companyID, inboxID)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, inbox)
+}
+
+func (h *Handler) MarkAllRead(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	marked, err := h.service.MarkAllRead(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": marked})
+}
+
+func notificationErrorResponse(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown notification trigger"})
+	case errors.Is(err, errors.ErrBadRequest), errors.Is(err, errors.ErrInvalidTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -1,6 +1,68 @@
 package notification
 
-// Service defines the methods that the notification service must implement
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/models"
+)
+
+// Channel sends rendered notifications. An error makes the notification be
+// sent on the channel again later, so channels must tolerate duplicates.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, notification *models.Notification, recipients []string) error
+}
+
+// RecipientChecker is implemented by channels that decide which recipients
+// they can be given. UpdatePreference rejects recipients it refuses.
+type RecipientChecker interface {
+	CheckRecipient(ctx context.Context, recipient string) error
+}
+
+type Repository interface {
+	GetPreferences(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationPreference, error)
+	UpsertPreference(ctx context.Context, preference *models.NotificationPreference) (*models.NotificationPreference, error)
+	GetTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationTemplate, error)
+	GetTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (*models.NotificationTemplate, error)
+	UpsertTemplate(ctx context.Context, template *models.NotificationTemplate) (*models.NotificationTemplate, error)
+	DeleteTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (bool, error)
+
+	// Trigger operations
+	GetCard(ctx context.Context, companyID, cardID uuid.UUID) (*models.Card, error)
+	GetCardSpending(ctx context.Context, cardID uuid.UUID, from, to time.Time) (float64, error)
+	MarkExpiringCards(ctx context.Context, before time.Time, limit int) (int, error)
+
+	// Delivery operations
+	CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error)
+	GetSentChannels(ctx context.Context, notificationID uuid.UUID) ([]string, error)
+	MarkSent(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string) error
+	MarkFailed(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string, reason string) error
+	AddToInbox(ctx context.Context, notification *models.Notification) error
+
+	// Inbox operations
+	GetInbox(ctx context.Context, companyID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.InboxNotification, error)
+	CountUnread(ctx context.Context, companyID uuid.UUID) (int, error)
+	MarkRead(ctx context.Context, companyID, inboxID uuid.UUID) (*models.InboxNotification, error)
+	MarkAllRead(ctx context.Context, companyID uuid.UUID) (int, error)
+}
+
 type Service interface {
-	// Add your service methods here
-}
\ No newline at end of file
+	GetPreferences(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationPreference, error)
+	UpdatePreference(ctx context.Context, companyID uuid.UUID, req *request.NotificationPreference) (*models.NotificationPreference, error)
+	GetTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationTemplate, error)
+	UpdateTemplate(ctx context.Context, companyID uuid.UUID, trigger string, req *request.NotificationTemplate) (*models.NotificationTemplate, error)
+	ResetTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (*models.NotificationTemplate, error)
+
+	ListInbox(ctx context.Context, companyID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.InboxNotification, error)
+	GetUnreadCount(ctx context.Context, companyID uuid.UUID) (int, error)
+	MarkRead(ctx context.Context, companyID, inboxID uuid.UUID) (*models.InboxNotification, error)
+	MarkAllRead(ctx context.Context, companyID uuid.UUID) (int, error)
+
+	HandleEvent(ctx context.Context, event *models.Event) error
+	CheckExpiringCards(ctx context.Context) (int, error)
+	StartExpiryScheduler(ctx context.Context, interval time.Duration)
+}
@@ -0,0 +1,461 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+const preferenceColumns = `company_id, trigger, channel, is_enabled, recipients, notify_cardholder, updated_at`
+
+const templateColumns = `company_id, trigger, subject, body, updated_at`
+
+const notificationColumns = `id, company_id, trigger, dedupe_key, card_id, subject, body, created_at`
+
+const inboxColumns = `i.id, i.notification_id, n.trigger, n.card_id, n.subject, n.body, i.read_at, i.created_at`
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func scanPreference(row models.RowScanner, preference *models.NotificationPreference) error {
+	return row.Scan(
+		&preference.CompanyID, &preference.Trigger, &preference.Channel, &preference.IsEnabled,
+		pq.Array(&preference.Recipients), &preference.NotifyCardholder, &preference.UpdatedAt,
+	)
+}
+
+func scanTemplate(row models.RowScanner, template *models.NotificationTemplate) error {
+	template.IsCustom = true
+	return row.Scan(&template.CompanyID, &template.Trigger, &template.Subject, &template.Body, &template.UpdatedAt)
+}
+
+func scanNotification(row models.RowScanner, notification *models.Notification) error {
+	return row.Scan(
+		&notification.ID, &notification.CompanyID, &notification.Trigger, &notification.DedupeKey,
+		&notification.CardID, &notification.Subject, &notification.Body, &notification.CreatedAt,
+	)
+}
+
+func scanInboxNotification(row models.RowScanner, inbox *models.InboxNotification) error {
+	return row.Scan(
+		&inbox.ID, &inbox.NotificationID, &inbox.Trigger, &inbox.CardID,
+		&inbox.Subject, &inbox.Body, &inbox.ReadAt, &inbox.CreatedAt,
+	)
+}
+
+func (r *repository) GetPreferences(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationPreference, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+preferenceColumns+`
+		FROM notification_preferences
+		WHERE company_id = $1
+		ORDER BY trigger, channel`,
+		companyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	var preferences []*models.NotificationPreference
+	for rows.Next() {
+		preference := &models.NotificationPreference{}
+		if err := scanPreference(rows, preference); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		preferences = append(preferences, preference)
+	}
+
+	return preferences, rows.Err()
+}
+
+func (r *repository) UpsertPreference(ctx context.Context, preference *models.NotificationPreference) (*models.NotificationPreference, error) {
+	if preference.Recipients == nil {
+		preference.Recipients = []string{}
+	}
+
+	stored := &models.NotificationPreference{}
+	err := scanPreference(r.db.QueryRowContext(ctx, `
+		INSERT INTO notification_preferences (company_id, trigger, channel, is_enabled, recipients, notify_cardholder)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (company_id, trigger, channel) DO UPDATE
+		SET is_enabled = EXCLUDED.is_enabled, recipients = EXCLUDED.recipients,
+		    notify_cardholder = EXCLUDED.notify_cardholder
+		RETURNING `+preferenceColumns,
+		preference.CompanyID, preference.Trigger, preference.Channel, preference.IsEnabled,
+		pq.Array(preference.Recipients), preference.NotifyCardholder,
+	), stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save notification preference: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (r *repository) GetTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM notification_templates
+		WHERE company_id = $1
+		ORDER BY trigger`,
+		companyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*models.NotificationTemplate
+	for rows.Next() {
+		template := &models.NotificationTemplate{}
+		if err := scanTemplate(rows, template); err != nil {
+			return nil, fmt.Errorf("failed to scan notification template: %w", err)
+		}
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+func (r *repository) GetTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (*models.NotificationTemplate, error) {
+	template := &models.NotificationTemplate{}
+	err := scanTemplate(r.db.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM notification_templates
+		WHERE company_id = $1 AND trigger = $2`,
+		companyID, trigger,
+	), template)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification template: %w", err)
+	}
+
+	return template, nil
+}
+
+func (r *repository) UpsertTemplate(ctx context.Context, template *models.NotificationTemplate) (*models.NotificationTemplate, error) {
+	stored := &models.NotificationTemplate{}
+	err := scanTemplate(r.db.QueryRowContext(ctx, `
+		INSERT INTO notification_templates (company_id, trigger, subject, body)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (company_id, trigger) DO UPDATE
+		SET subject = EXCLUDED.subject, body = EXCLUDED.body
+		RETURNING `+templateColumns,
+		template.CompanyID, template.Trigger, template.Subject, template.Body,
+	), stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save notification template: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (r *repository) DeleteTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM notification_templates WHERE company_id = $1 AND trigger = $2`,
+		companyID, trigger,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete notification template: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted > 0, nil
+}
+
+func (r *repository) GetCard(ctx context.Context, companyID, cardID uuid.UUID) (*models.Card, error) {
+	card := &models.Card{}
+	err := models.ScanCard(r.db.QueryRowContext(ctx, `
+		SELECT `+models.CardColumns+`
+		FROM cards
+		WHERE id = $1 AND company_id = $2`,
+		cardID, companyID,
+	), card)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get card: %w", err)
+	}
+
+	return card, nil
+}
+
+// GetCardSpending sums the card's completed purchases created from from up to
+// and including to.
+func (r *repository) GetCardSpending(ctx context.Context, cardID uuid.UUID, from, to time.Time) (float64, error) {
+	var spending float64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE card_id = $1 AND status = $2 AND transaction_type = $3
+		  AND created_at >= $4 AND created_at <= $5`,
+		cardID, models.TransactionStatusCompleted, models.TransactionTypePurchase, from, to,
+	).Scan(&spending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get card spending: %w", err)
+	}
+
+	return spending, nil
+}
+
+// MarkExpiringCards marks active cards expiring before the given time as
+// notified and records a card.expiring event for each, in one transaction.
+// Every card is marked once, so its event is recorded once. It returns the
+// number of cards marked.
+func (r *repository) MarkExpiringCards(ctx context.Context, before time.Time, limit int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH due AS (
+			SELECT id
+			FROM cards
+			WHERE status = $1 AND expiry_notified_at IS NULL
+			  AND expiry_date > CURRENT_TIMESTAMP AND expiry_date <= $2
+			ORDER BY expiry_date ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE cards c
+		SET expiry_notified_at = CURRENT_TIMESTAMP
+		FROM due
+		WHERE c.id = due.id
+		RETURNING c.id, c.company_id, c.card_holder_name, c.employee_email, c.last_four, c.expiry_date`,
+		models.CardStatusActive, before, limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark expiring cards: %w", err)
+	}
+
+	var events []*outbox.Event
+	for rows.Next() {
+		var companyID uuid.UUID
+		var event models.CardExpiringEvent
+		err := rows.Scan(&event.CardID, &companyID, &event.CardHolderName, &event.EmployeeEmail,
+			&event.LastFour, &event.ExpiryDate)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan expiring card: %w", err)
+		}
+		events = append(events, &outbox.Event{
+			CompanyID: companyID,
+			Type:      models.EventCardExpiring,
+			Data:      event,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read expiring cards: %w", err)
+	}
+
+	if err := outbox.Enqueue(ctx, tx, events...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(events), nil
+}
+
+// CreateNotification stores a notification, or returns the one already stored
+// for the same company, trigger and dedupe key.
+func (r *repository) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	stored := &models.Notification{}
+	err := scanNotification(r.db.QueryRowContext(ctx, `
+		INSERT INTO notifications (id, company_id, trigger, dedupe_key, card_id, subject, body)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (company_id, trigger, dedupe_key) DO UPDATE SET dedupe_key = EXCLUDED.dedupe_key
+		RETURNING `+notificationColumns,
+		notification.ID, notification.CompanyID, notification.Trigger, notification.DedupeKey,
+		notification.CardID, notification.Subject, notification.Body,
+	), stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (r *repository) GetSentChannels(ctx context.Context, notificationID uuid.UUID) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT channel FROM notification_deliveries WHERE notification_id = $1 AND status = $2`,
+		notificationID, models.NotificationDeliveryStatusSent,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []string
+	for rows.Next() {
+		var channel string
+		if err := rows.Scan(&channel); err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", err)
+		}
+		channels = append(channels, channel)
+	}
+
+	return channels, rows.Err()
+}
+
+func (r *repository) MarkSent(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string) error {
+	return r.recordDelivery(ctx, notificationID, channel, recipients, models.NotificationDeliveryStatusSent, nil)
+}
+
+func (r *repository) MarkFailed(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string, reason string) error {
+	return r.recordDelivery(ctx, notificationID, channel, recipients, models.NotificationDeliveryStatusFailed, &reason)
+}
+
+func (r *repository) recordDelivery(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string, status string, reason *string) error {
+	if recipients == nil {
+		recipients = []string{}
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO notification_deliveries (notification_id, channel, recipients, status, attempts, last_error, sent_at)
+		VALUES ($1, $2, $3, $4, 1, $5, CASE WHEN $4 = $6 THEN CURRENT_TIMESTAMP END)
+		ON CONFLICT (notification_id, channel) DO UPDATE
+		SET recipients = EXCLUDED.recipients, status = EXCLUDED.status,
+		    attempts = notification_deliveries.attempts + 1,
+		    last_error = EXCLUDED.last_error, sent_at = EXCLUDED.sent_at`,
+		notificationID, channel, pq.Array(recipients), status, reason, models.NotificationDeliveryStatusSent,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record notification delivery: %w", err)
+	}
+
+	return nil
+}
+
+// AddToInbox adds a notification to its company's in-app inbox. A
+// notification already in the inbox is left as it is.
+func (r *repository) AddToInbox(ctx context.Context, notification *models.Notification) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO inbox_notifications (company_id, notification_id)
+		VALUES ($1, $2)
+		ON CONFLICT (notification_id) DO NOTHING`,
+		notification.CompanyID, notification.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add notification to inbox: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetInbox(ctx context.Context, companyID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.InboxNotification, error) {
+	query := `
+		SELECT ` + inboxColumns + `
+		FROM inbox_notifications i
+		JOIN notifications n ON n.id = i.notification_id
+		WHERE i.company_id = $1`
+	if unreadOnly {
+		query += ` AND i.read_at IS NULL`
+	}
+	query += `
+		ORDER BY i.created_at DESC, i.id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, companyID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inbox notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []*models.InboxNotification
+	for rows.Next() {
+		inbox := &models.InboxNotification{}
+		if err := scanInboxNotification(rows, inbox); err != nil {
+			return nil, fmt.Errorf("failed to scan inbox notification: %w", err)
+		}
+		notifications = append(notifications, inbox)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (r *repository) CountUnread(ctx context.Context, companyID uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM inbox_notifications
+		WHERE company_id = $1 AND read_at IS NULL`,
+		companyID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread inbox notifications: %w", err)
+	}
+
+	return count, nil
+}
+
+// MarkRead marks an inbox notification as read. A notification already read
+// keeps its original read time. It returns nil if the company has no such
+// notification.
+func (r *repository) MarkRead(ctx context.Context, companyID, inboxID uuid.UUID) (*models.InboxNotification, error) {
+	inbox := &models.InboxNotification{}
+	err := scanInboxNotification(r.db.QueryRowContext(ctx, `
+		WITH i AS (
+			UPDATE inbox_notifications
+			SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+			WHERE id = $1 AND company_id = $2
+			RETURNING id, notification_id, read_at, created_at
+		)
+		SELECT `+inboxColumns+`
+		FROM i
+		JOIN notifications n ON n.id = i.notification_id`,
+		inboxID, companyID,
+	), inbox)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to mark inbox notification as read: %w", err)
+	}
+
+	return inbox, nil
+}
+
+// MarkAllRead marks every unread inbox notification of the company as read
+// and returns how many there were.
+func (r *repository) MarkAllRead(ctx context.Context, companyID uuid.UUID) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE inbox_notifications
+		SET read_at = CURRENT_TIMESTAMP
+		WHERE company_id = $1 AND read_at IS NULL`,
+		companyID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark inbox notifications as read: %w", err)
+	}
+
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark inbox notifications as read: %w", err)
+	}
+
+	return int(marked), nil
+}
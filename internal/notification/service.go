@@ -1,12 +1,487 @@
 package notification
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/config"
+	"ccards/pkg/endpoint"
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+// SubscriberName is the name notifications subscribe to outbox events under.
+const SubscriberName = "notifications"
+
+// expiryBatchSize bounds the expiring cards marked per statement.
+const expiryBatchSize = 500
+
 type service struct {
-	// Add dependencies here
+	repo     Repository
+	config   config.NotificationConfig
+	channels map[string]Channel
+}
+
+// NewService creates a notification service sending on the given channels.
+// Preferences for a channel that is not given are kept but not sent.
+func NewService(repo Repository, notificationConfig config.NotificationConfig, channels ...Channel) Service {
+	byName := make(map[string]Channel, len(channels))
+	for _, channel := range channels {
+		byName[channel.Name()] = channel
+	}
+
+	return &service{
+		repo:     repo,
+		config:   notificationConfig,
+		channels: byName,
+	}
+}
+
+// GetPreferences returns the company's preference for every trigger and
+// channel. Without a stored preference only the in-app inbox is enabled.
+func (s *service) GetPreferences(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationPreference, error) {
+	stored, err := s.repo.GetPreferences(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]*models.NotificationPreference, len(stored))
+	for _, preference := range stored {
+		byKey[preference.Trigger+"/"+preference.Channel] = preference
+	}
+
+	preferences := make([]*models.NotificationPreference, 0, len(models.NotificationTriggers)*len(models.NotificationChannels))
+	for _, trigger := range models.NotificationTriggers {
+		for _, channel := range models.NotificationChannels {
+			if preference, ok := byKey[trigger+"/"+channel]; ok {
+				preferences = append(preferences, preference)
+				continue
+			}
+			preferences = append(preferences, &models.NotificationPreference{
+				CompanyID:  companyID,
+				Trigger:    trigger,
+				Channel:    channel,
+				IsEnabled:  channel == models.NotificationChannelInApp,
+				Recipients: []string{},
+			})
+		}
+	}
+
+	return preferences, nil
+}
+
+func (s *service) UpdatePreference(ctx context.Context, companyID uuid.UUID, req *request.NotificationPreference) (*models.NotificationPreference, error) {
+	preference := &models.NotificationPreference{
+		CompanyID:  companyID,
+		Trigger:    req.Trigger,
+		Channel:    req.Channel,
+		IsEnabled:  req.IsEnabled == nil || *req.IsEnabled,
+		Recipients: req.Recipients,
+	}
+
+	switch req.Channel {
+	case models.NotificationChannelEmail:
+		for _, recipient := range req.Recipients {
+			address, err := mail.ParseAddress(recipient)
+			if err != nil || address.Address != recipient {
+				return nil, fmt.Errorf("%w: invalid email recipient %q", apperrors.ErrBadRequest, recipient)
+			}
+		}
+		preference.NotifyCardholder = req.NotifyCardholder
+	case models.NotificationChannelWebhook:
+		// Without the webhook channel recipients get the strictest check.
+		check := endpoint.CheckURL
+		if checker, ok := s.channels[req.Channel].(RecipientChecker); ok {
+			check = checker.CheckRecipient
+		}
+		for _, recipient := range req.Recipients {
+			if err := check(ctx, recipient); err != nil {
+				return nil, fmt.Errorf("%w: invalid webhook recipient %q: %v", apperrors.ErrBadRequest, recipient, err)
+			}
+		}
+	default:
+		preference.Recipients = nil
+	}
+
+	return s.repo.UpsertPreference(ctx, preference)
+}
+
+// GetTemplates returns the company's template for every trigger, custom or
+// built in.
+func (s *service) GetTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationTemplate, error) {
+	stored, err := s.repo.GetTemplates(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	custom := make(map[string]*models.NotificationTemplate, len(stored))
+	for _, tmpl := range stored {
+		custom[tmpl.Trigger] = tmpl
+	}
+
+	templates := make([]*models.NotificationTemplate, 0, len(models.NotificationTriggers))
+	for _, trigger := range models.NotificationTriggers {
+		if tmpl, ok := custom[trigger]; ok {
+			templates = append(templates, tmpl)
+			continue
+		}
+		templates = append(templates, defaultTemplate(companyID, trigger))
+	}
+
+	return templates, nil
+}
+
+// UpdateTemplate replaces a trigger's template after rendering it with sample
+// data, so a broken template is rejected instead of failing notifications.
+func (s *service) UpdateTemplate(ctx context.Context, companyID uuid.UUID, trigger string, req *request.NotificationTemplate) (*models.NotificationTemplate, error) {
+	if defaultTemplate(companyID, trigger) == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	tmpl := &models.NotificationTemplate{
+		CompanyID: companyID,
+		Trigger:   trigger,
+		Subject:   req.Subject,
+		Body:      req.Body,
+	}
+	if _, _, err := render(tmpl, sampleData); err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpsertTemplate(ctx, tmpl)
+}
+
+// ResetTemplate deletes a trigger's custom template and returns the built-in
+// one, which is used from now on.
+func (s *service) ResetTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (*models.NotificationTemplate, error) {
+	tmpl := defaultTemplate(companyID, trigger)
+	if tmpl == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	if _, err := s.repo.DeleteTemplate(ctx, companyID, trigger); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// ListInbox returns a page of the company's inbox, newest first.
+func (s *service) ListInbox(ctx context.Context, companyID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.InboxNotification, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.GetInbox(ctx, companyID, unreadOnly, limit, offset)
+}
+
+func (s *service) GetUnreadCount(ctx context.Context, companyID uuid.UUID) (int, error) {
+	return s.repo.CountUnread(ctx, companyID)
+}
+
+func (s *service) MarkRead(ctx context.Context, companyID, inboxID uuid.UUID) (*models.InboxNotification, error) {
+	inbox, err := s.repo.MarkRead(ctx, companyID, inboxID)
+	if err != nil {
+		return nil, err
+	}
+	if inbox == nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	return inbox, nil
+}
+
+func (s *service) MarkAllRead(ctx context.Context, companyID uuid.UUID) (int, error) {
+	return s.repo.MarkAllRead(ctx, companyID)
 }
 
-// NewService creates a new notification service
-func NewService() Service {
-	return &service{}
+// HandleEvent raises the notifications an outbox event triggers. It is the
+// notifications' outbox subscriber: an error makes the outbox hand the event
+// over again, and channels that already sent a notification are skipped then.
+func (s *service) HandleEvent(ctx context.Context, event *models.Event) error {
+	switch event.Type {
+	case models.EventPaymentCompleted:
+		return s.paymentCompleted(ctx, event)
+	case models.EventPaymentDeclined:
+		return s.paymentDeclined(ctx, event)
+	case models.EventCardBlocked:
+		return s.cardBlocked(ctx, event)
+	case models.EventCardExpiring:
+		return s.cardExpiring(ctx, event)
+	default:
+		return nil
+	}
 }
 
-// Implement the Service interface methods here
\ No newline at end of file
+// paymentCompleted notifies when a purchase takes the card's balance below
+// the low balance threshold, or its daily or monthly spending to the limit
+// warning ratio. Only the purchase that crosses the line notifies.
+func (s *service) paymentCompleted(ctx context.Context, event *models.Event) error {
+	var transaction models.Transaction
+	if err := json.Unmarshal(event.Data, &transaction); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+	if transaction.TransactionType != models.TransactionTypePurchase {
+		return nil
+	}
+
+	card, err := s.repo.GetCard(ctx, event.CompanyID, transaction.CardID)
+	if err != nil || card == nil {
+		return err
+	}
+
+	var errs []error
+
+	// The balance may have changed since the payment; later payments notify
+	// on their own when they cross the threshold.
+	threshold := s.config.LowBalanceThreshold
+	if card.Balance < threshold && card.Balance+transaction.Amount >= threshold {
+		data := cardData(card)
+		data.Amount = transaction.Amount
+		data.Balance = card.Balance
+		data.Threshold = threshold
+		errs = append(errs, s.notify(ctx, card, models.NotificationTriggerLowBalance, event.ID.String(), data))
+	}
+
+	paidAt := transaction.CreatedAt.Local()
+	periods := []struct {
+		name  string
+		limit *float64
+		from  time.Time
+	}{
+		{"daily", card.DailyLimit, time.Date(paidAt.Year(), paidAt.Month(), paidAt.Day(), 0, 0, 0, 0, paidAt.Location())},
+		{"monthly", card.MonthlyLimit, time.Date(paidAt.Year(), paidAt.Month(), 1, 0, 0, 0, 0, paidAt.Location())},
+	}
+	for _, period := range periods {
+		if period.limit == nil || *period.limit <= 0 {
+			continue
+		}
+
+		spent, err := s.repo.GetCardSpending(ctx, card.ID, period.from, transaction.CreatedAt)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		warning := *period.limit * s.config.LimitWarningRatio
+		if spent >= warning && spent-transaction.Amount < warning {
+			data := cardData(card)
+			data.Amount = transaction.Amount
+			data.Period = period.name
+			data.Spent = spent
+			data.Limit = *period.limit
+			dedupeKey := event.ID.String() + ":" + period.name
+			errs = append(errs, s.notify(ctx, card, models.NotificationTriggerLimitNearlyReached, dedupeKey, data))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *service) paymentDeclined(ctx context.Context, event *models.Event) error {
+	var declined models.PaymentDeclinedEvent
+	if err := json.Unmarshal(event.Data, &declined); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+
+	card, err := s.repo.GetCard(ctx, event.CompanyID, declined.CardID)
+	if err != nil || card == nil {
+		return err
+	}
+
+	data := cardData(card)
+	data.Amount = declined.Amount
+	data.MerchantCategory = declined.MerchantCategory
+	return s.notify(ctx, card, models.NotificationTriggerPaymentDeclined, event.ID.String(), data)
+}
+
+func (s *service) cardBlocked(ctx context.Context, event *models.Event) error {
+	var blocked models.CardStatusChangedEvent
+	if err := json.Unmarshal(event.Data, &blocked); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+
+	card, err := s.repo.GetCard(ctx, event.CompanyID, blocked.CardID)
+	if err != nil || card == nil {
+		return err
+	}
+
+	data := cardData(card)
+	data.Reason = blocked.Reason
+	return s.notify(ctx, card, models.NotificationTriggerCardBlocked, event.ID.String(), data)
+}
+
+func (s *service) cardExpiring(ctx context.Context, event *models.Event) error {
+	var expiring models.CardExpiringEvent
+	if err := json.Unmarshal(event.Data, &expiring); err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+
+	card, err := s.repo.GetCard(ctx, event.CompanyID, expiring.CardID)
+	if err != nil || card == nil {
+		return err
+	}
+
+	data := cardData(card)
+	data.ExpiryDate = expiring.ExpiryDate
+	return s.notify(ctx, card, models.NotificationTriggerCardExpiring, event.ID.String(), data)
+}
+
+func cardData(card *models.Card) *TemplateData {
+	return &TemplateData{
+		CardHolderName: card.CardHolderName,
+		LastFour:       card.LastFour,
+		ExpiryDate:     card.ExpiryDate,
+	}
+}
+
+// notify renders a trigger's template and sends it on every enabled channel
+// that has not sent it yet. The dedupe key identifies the occurrence, so
+// handling an event again never notifies twice on a channel. Channels are
+// independent: a failure on one is returned after the others were tried.
+func (s *service) notify(ctx context.Context, card *models.Card, trigger, dedupeKey string, data *TemplateData) error {
+	preferences, err := s.GetPreferences(ctx, card.CompanyID)
+	if err != nil {
+		return err
+	}
+
+	var enabled []*models.NotificationPreference
+	for _, preference := range preferences {
+		if preference.Trigger == trigger && preference.IsEnabled {
+			enabled = append(enabled, preference)
+		}
+	}
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	tmpl, err := s.repo.GetTemplate(ctx, card.CompanyID, trigger)
+	if err != nil {
+		return err
+	}
+	if tmpl == nil {
+		tmpl = defaultTemplate(card.CompanyID, trigger)
+	}
+
+	subject, body, err := render(tmpl, data)
+	if err != nil {
+		// A custom template that no longer renders falls back to the
+		// built-in one rather than dropping the notification.
+		log.Printf("Warning: notification template %s of company %s failed: %v", trigger, card.CompanyID, err)
+		if subject, body, err = render(defaultTemplate(card.CompanyID, trigger), data); err != nil {
+			return err
+		}
+	}
+
+	cardID := card.ID
+	notification, err := s.repo.CreateNotification(ctx, &models.Notification{
+		ID:        uuid.New(),
+		CompanyID: card.CompanyID,
+		Trigger:   trigger,
+		DedupeKey: dedupeKey,
+		CardID:    &cardID,
+		Subject:   subject,
+		Body:      body,
+	})
+	if err != nil {
+		return err
+	}
+
+	sent, err := s.repo.GetSentChannels(ctx, notification.ID)
+	if err != nil {
+		return err
+	}
+	alreadySent := make(map[string]bool, len(sent))
+	for _, channel := range sent {
+		alreadySent[channel] = true
+	}
+
+	var errs []error
+	for _, preference := range enabled {
+		if alreadySent[preference.Channel] {
+			continue
+		}
+
+		channel, ok := s.channels[preference.Channel]
+		if !ok {
+			continue
+		}
+
+		recipients := append([]string{}, preference.Recipients...)
+		if preference.NotifyCardholder && card.EmployeeEmail != "" {
+			recipients = append(recipients, card.EmployeeEmail)
+		}
+		if len(recipients) == 0 && preference.Channel != models.NotificationChannelInApp {
+			continue
+		}
+
+		if err := channel.Send(ctx, notification, recipients); err != nil {
+			errs = append(errs, fmt.Errorf("%s notification failed: %w", preference.Channel, err))
+			err = s.repo.MarkFailed(ctx, notification.ID, preference.Channel, recipients, err.Error())
+		} else {
+			err = s.repo.MarkSent(ctx, notification.ID, preference.Channel, recipients)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// CheckExpiringCards records a card.expiring event for every active card
+// expiring within the warning period that was not notified yet. It returns
+// the number of cards found.
+func (s *service) CheckExpiringCards(ctx context.Context) (int, error) {
+	before := time.Now().AddDate(0, 0, s.config.ExpiryWarningDays)
+
+	total := 0
+	for {
+		marked, err := s.repo.MarkExpiringCards(ctx, before, expiryBatchSize)
+		if err != nil {
+			return total, err
+		}
+		total += marked
+		if marked < expiryBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// StartExpiryScheduler checks for expiring cards every interval until ctx is
+// cancelled.
+func (s *service) StartExpiryScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				found, err := s.CheckExpiringCards(ctx)
+				if err != nil {
+					log.Printf("Warning: expiring card check failed: %v", err)
+				}
+				if found > 0 {
+					log.Printf("Found %d expiring cards", found)
+				}
+			}
+		}
+	}()
+}
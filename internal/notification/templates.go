@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+// TemplateData is what notification templates are rendered with. Fields that
+// do not apply to a trigger are left zero.
+type TemplateData struct {
+	CardHolderName   string
+	LastFour         string
+	Amount           float64
+	Balance          float64
+	Threshold        float64
+	Period           string
+	Spent            float64
+	Limit            float64
+	MerchantCategory string
+	Reason           string
+	ExpiryDate       time.Time
+}
+
+// templateFuncs are available to every template: money formats an amount with
+// two decimals, date formats a time as YYYY-MM-DD.
+var templateFuncs = template.FuncMap{
+	"money": func(amount float64) string { return fmt.Sprintf("%.2f", amount) },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+}
+
+// defaultTemplates are the built-in subject and body of every trigger.
+var defaultTemplates = map[string][2]string{
+	models.NotificationTriggerLowBalance: {
+		`Low balance on card ending {{.LastFour}}`,
+		`The balance of {{.CardHolderName}}'s card ending {{.LastFour}} fell to {{money .Balance}} after a payment of {{money .Amount}}, below the {{money .Threshold}} warning threshold.`,
+	},
+	models.NotificationTriggerLimitNearlyReached: {
+		`Card ending {{.LastFour}} is close to its {{.Period}} limit`,
+		`{{.CardHolderName}}'s card ending {{.LastFour}} has spent {{money .Spent}} of its {{money .Limit}} {{.Period}} limit.`,
+	},
+	models.NotificationTriggerPaymentDeclined: {
+		`Payment declined on card ending {{.LastFour}}`,
+		`A payment of {{money .Amount}}{{if .MerchantCategory}} at a {{.MerchantCategory}} merchant{{end}} on {{.CardHolderName}}'s card ending {{.LastFour}} was declined.`,
+	},
+	models.NotificationTriggerCardBlocked: {
+		`Card ending {{.LastFour}} was blocked`,
+		`{{.CardHolderName}}'s card ending {{.LastFour}} was blocked{{if .Reason}}: {{.Reason}}{{end}}.`,
+	},
+	models.NotificationTriggerCardExpiring: {
+		`Card ending {{.LastFour}} expires on {{date .ExpiryDate}}`,
+		`{{.CardHolderName}}'s card ending {{.LastFour}} expires on {{date .ExpiryDate}}. Issue a replacement before then to avoid declined payments.`,
+	},
+}
+
+// sampleData is used to check custom templates before they are saved.
+var sampleData = &TemplateData{
+	CardHolderName:   "Jane Doe",
+	LastFour:         "4242",
+	Amount:           120,
+	Balance:          80,
+	Threshold:        100,
+	Period:           "daily",
+	Spent:            850,
+	Limit:            1000,
+	MerchantCategory: "travel",
+	Reason:           "too many declined transactions",
+	ExpiryDate:       time.Now().AddDate(0, 0, 30),
+}
+
+func defaultTemplate(companyID uuid.UUID, trigger string) *models.NotificationTemplate {
+	source, ok := defaultTemplates[trigger]
+	if !ok {
+		return nil
+	}
+
+	return &models.NotificationTemplate{
+		CompanyID: companyID,
+		Trigger:   trigger,
+		Subject:   source[0],
+		Body:      source[1],
+	}
+}
+
+// render renders a template's subject and body.
+func render(tmpl *models.NotificationTemplate, data *TemplateData) (string, string, error) {
+	subject, err := execute(tmpl.Trigger+" subject", tmpl.Subject, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	body, err := execute(tmpl.Trigger+" body", tmpl.Body, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return subject, body, nil
+}
+
+func execute(name, source string, data *TemplateData) (string, error) {
+	parsed, err := template.New(name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errors.ErrInvalidTemplate, err)
+	}
+
+	var out bytes.Buffer
+	if err := parsed.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("%w: %v", errors.ErrInvalidTemplate, err)
+	}
+
+	return out.String(), nil
+}
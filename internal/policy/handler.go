@@ -0,0 +1,191 @@
+package policy
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.PolicyTemplate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.service.CreateTemplate(c.Request.Context(), companyID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrInvalidPolicy):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errors.ErrTemplateExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Policy template with this name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create policy template"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+func (h *Handler) GetTemplates(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templates, err := h.service.ListTemplates(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve policy templates"})
+		return
+	}
+	if templates == nil {
+		templates = []*models.CardPolicyTemplate{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+func (h *Handler) GetTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy template ID format"})
+		return
+	}
+
+	template, err := h.service.GetTemplate(c.Request.Context(), companyID, templateID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve policy template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Policy template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *Handler) UpdateTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy template ID format"})
+		return
+	}
+
+	var req request.PolicyTemplate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := h.service.UpdateTemplate(c.Request.Context(), companyID, templateID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Policy template not found"})
+		case errors.Is(err, errors.ErrInvalidPolicy):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errors.ErrTemplateExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Policy template with this name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update policy template"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy template ID format"})
+		return
+	}
+
+	if err := h.service.DeleteTemplate(c.Request.Context(), companyID, templateID); err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Policy template not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete policy template"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Policy template deleted successfully"})
+}
+
+func (h *Handler) ReapplyTemplate(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	templateID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy template ID format"})
+		return
+	}
+
+	updated, err := h.service.ReapplyTemplate(c.Request.Context(), companyID, templateID)
+	if err != nil {
+		switch err {
+		case errors.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Policy template not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-apply policy template"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Policy template re-applied successfully",
+		"cards_updated": updated,
+	})
+}
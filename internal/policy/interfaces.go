@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	CreateTemplate(ctx context.Context, template *models.CardPolicyTemplate) error
+	GetTemplateByID(ctx context.Context, companyID, id uuid.UUID) (*models.CardPolicyTemplate, error)
+	GetTemplatesByCompanyID(ctx context.Context, companyID uuid.UUID) ([]*models.CardPolicyTemplate, error)
+	UpdateTemplate(ctx context.Context, template *models.CardPolicyTemplate) error
+	DeleteTemplate(ctx context.Context, companyID, id uuid.UUID) error
+
+	ReapplyTemplate(ctx context.Context, template *models.CardPolicyTemplate) (int, error)
+}
+
+type Service interface {
+	CreateTemplate(ctx context.Context, companyID uuid.UUID, req *request.PolicyTemplate) (*models.CardPolicyTemplate, error)
+	GetTemplate(ctx context.Context, companyID, id uuid.UUID) (*models.CardPolicyTemplate, error)
+	ListTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.CardPolicyTemplate, error)
+	UpdateTemplate(ctx context.Context, companyID, id uuid.UUID, req *request.PolicyTemplate) (*models.CardPolicyTemplate, error)
+	DeleteTemplate(ctx context.Context, companyID, id uuid.UUID) error
+
+	ReapplyTemplate(ctx context.Context, companyID, id uuid.UUID) (int, error)
+}
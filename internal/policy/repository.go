@@ -0,0 +1,261 @@
+package policy
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func controlsValue(controls []models.IssuanceControl) ([]byte, error) {
+	if controls == nil {
+		controls = []models.IssuanceControl{}
+	}
+
+	data, err := json.Marshal(controls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode controls: %w", err)
+	}
+
+	return data, nil
+}
+
+// clearDefault unsets the company's current default template so another one
+// can take its place without violating the one-default-per-company index.
+func clearDefault(ctx context.Context, tx *sql.Tx, companyID, keepID uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE card_policy_templates
+		SET is_default = false
+		WHERE company_id = $1 AND id <> $2 AND is_default`,
+		companyID, keepID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear default template: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) CreateTemplate(ctx context.Context, template *models.CardPolicyTemplate) error {
+	controls, err := controlsValue(template.Controls)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if template.IsDefault {
+		if err := clearDefault(ctx, tx, template.CompanyID, template.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO card_policy_templates (
+			id, company_id, name, description, card_type, spending_limit, daily_limit,
+			monthly_limit, controls, is_default
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		template.ID, template.CompanyID, template.Name, template.Description, template.CardType,
+		template.SpendingLimit, template.DailyLimit, template.MonthlyLimit, controls, template.IsDefault,
+	).Scan(&template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return apperrors.ErrTemplateExists
+		}
+		return fmt.Errorf("failed to create policy template: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *repository) GetTemplateByID(ctx context.Context, companyID, id uuid.UUID) (*models.CardPolicyTemplate, error) {
+	query := `
+		SELECT ` + models.PolicyTemplateColumns + `
+		FROM card_policy_templates
+		WHERE company_id = $1 AND id = $2`
+
+	template := &models.CardPolicyTemplate{}
+	err := models.ScanPolicyTemplate(r.db.QueryRowContext(ctx, query, companyID, id), template)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get policy template: %w", err)
+	}
+
+	return template, nil
+}
+
+func (r *repository) GetTemplatesByCompanyID(ctx context.Context, companyID uuid.UUID) ([]*models.CardPolicyTemplate, error) {
+	query := `
+		SELECT ` + models.PolicyTemplateColumns + `
+		FROM card_policy_templates
+		WHERE company_id = $1
+		ORDER BY is_default DESC, name ASC`
+
+	rows, err := r.db.QueryContext(ctx, query, companyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*models.CardPolicyTemplate
+	for rows.Next() {
+		template := &models.CardPolicyTemplate{}
+		if err := models.ScanPolicyTemplate(rows, template); err != nil {
+			return nil, fmt.Errorf("failed to scan policy template: %w", err)
+		}
+		templates = append(templates, template)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return templates, nil
+}
+
+func (r *repository) UpdateTemplate(ctx context.Context, template *models.CardPolicyTemplate) error {
+	controls, err := controlsValue(template.Controls)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if template.IsDefault {
+		if err := clearDefault(ctx, tx, template.CompanyID, template.ID); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE card_policy_templates
+		SET name = $3, description = $4, card_type = $5, spending_limit = $6, daily_limit = $7,
+		    monthly_limit = $8, controls = $9, is_default = $10, updated_at = CURRENT_TIMESTAMP
+		WHERE company_id = $1 AND id = $2
+		RETURNING updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		template.CompanyID, template.ID, template.Name, template.Description, template.CardType,
+		template.SpendingLimit, template.DailyLimit, template.MonthlyLimit, controls, template.IsDefault,
+	).Scan(&template.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperrors.ErrNotFound
+		}
+		if isUniqueViolation(err) {
+			return apperrors.ErrTemplateExists
+		}
+		return fmt.Errorf("failed to update policy template: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTemplate removes a template. Cards issued with it keep their limits and
+// controls but are no longer linked to it.
+func (r *repository) DeleteTemplate(ctx context.Context, companyID, id uuid.UUID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM card_policy_templates WHERE company_id = $1 AND id = $2`, companyID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete policy template: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return apperrors.ErrNotFound
+	}
+
+	return nil
+}
+
+// ReapplyTemplate resets the limits and template controls of every card issued
+// with the template to the template's current values, in one transaction.
+// Cancelled cards are left alone. It returns the number of cards updated.
+func (r *repository) ReapplyTemplate(ctx context.Context, template *models.CardPolicyTemplate) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE cards
+		SET spending_limit = $3, daily_limit = $4, monthly_limit = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE company_id = $1 AND policy_template_id = $2 AND status <> $6`,
+		template.CompanyID, template.ID, template.SpendingLimit, template.DailyLimit, template.MonthlyLimit,
+		models.CardStatusCancelled,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update card limits: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM spending_controls
+		WHERE policy_template_id = $1
+		  AND card_id IN (SELECT id FROM cards WHERE company_id = $2 AND policy_template_id = $1 AND status <> $3)`,
+		template.ID, template.CompanyID, models.CardStatusCancelled,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove template controls: %w", err)
+	}
+
+	for _, control := range template.Controls {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO spending_controls (card_id, control_type, control_value, is_active, policy_template_id)
+			SELECT id, $3, $4, true, $1
+			FROM cards
+			WHERE policy_template_id = $1 AND company_id = $2 AND status <> $5`,
+			template.ID, template.CompanyID, control.ControlType, []byte(control.Value), models.CardStatusCancelled,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert template controls: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(updated), nil
+}
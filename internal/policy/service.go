@@ -0,0 +1,111 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/validation"
+)
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) CreateTemplate(ctx context.Context, companyID uuid.UUID, req *request.PolicyTemplate) (*models.CardPolicyTemplate, error) {
+	template := &models.CardPolicyTemplate{
+		ID:        uuid.New(),
+		CompanyID: companyID,
+	}
+	applyRequest(template, req)
+
+	if err := validateTemplate(template); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (s *service) GetTemplate(ctx context.Context, companyID, id uuid.UUID) (*models.CardPolicyTemplate, error) {
+	return s.repo.GetTemplateByID(ctx, companyID, id)
+}
+
+func (s *service) ListTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.CardPolicyTemplate, error) {
+	return s.repo.GetTemplatesByCompanyID(ctx, companyID)
+}
+
+// UpdateTemplate replaces a template's settings. Cards already issued with it
+// keep their current settings until the template is re-applied.
+func (s *service) UpdateTemplate(ctx context.Context, companyID, id uuid.UUID, req *request.PolicyTemplate) (*models.CardPolicyTemplate, error) {
+	template, err := s.repo.GetTemplateByID(ctx, companyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if template == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	applyRequest(template, req)
+
+	if err := validateTemplate(template); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateTemplate(ctx, template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func (s *service) DeleteTemplate(ctx context.Context, companyID, id uuid.UUID) error {
+	return s.repo.DeleteTemplate(ctx, companyID, id)
+}
+
+func (s *service) ReapplyTemplate(ctx context.Context, companyID, id uuid.UUID) (int, error) {
+	template, err := s.repo.GetTemplateByID(ctx, companyID, id)
+	if err != nil {
+		return 0, err
+	}
+	if template == nil {
+		return 0, errors.ErrNotFound
+	}
+
+	return s.repo.ReapplyTemplate(ctx, template)
+}
+
+func applyRequest(template *models.CardPolicyTemplate, req *request.PolicyTemplate) {
+	template.Name = req.Name
+	template.Description = req.Description
+	template.CardType = req.CardType
+	template.SpendingLimit = req.SpendingLimit
+	template.DailyLimit = req.DailyLimit
+	template.MonthlyLimit = req.MonthlyLimit
+	template.Controls = req.Controls
+	template.IsDefault = req.IsDefault
+
+	if template.Controls == nil {
+		template.Controls = []models.IssuanceControl{}
+	}
+}
+
+func validateTemplate(template *models.CardPolicyTemplate) error {
+	return validation.ValidateIssuancePolicy(&models.IssuancePolicy{
+		CardType:      template.CardType,
+		SpendingLimit: template.SpendingLimit,
+		DailyLimit:    template.DailyLimit,
+		MonthlyLimit:  template.MonthlyLimit,
+		Controls:      template.Controls,
+	})
+}
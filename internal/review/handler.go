@@ -0,0 +1,132 @@
+package review
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetReviews lists the company's payments flagged for fraud review. The
+// status query parameter selects pending (default), approved or rejected
+// reviews.
+func (h *Handler) GetReviews(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.ReviewStatusPending)
+	switch status {
+	case models.ReviewStatusPending, models.ReviewStatusApproved, models.ReviewStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review status"})
+		return
+	}
+
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+
+	reviews, err := h.service.ListReviews(c.Request.Context(), companyID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
+		return
+	}
+	if reviews == nil {
+		reviews = []*models.Transaction{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews":   reviews,
+		"count":     len(reviews),
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *Handler) GetReview(c *gin.Context) {
+	companyID, transactionID, ok := reviewParams(c)
+	if !ok {
+		return
+	}
+
+	transaction, err := h.service.GetReview(c.Request.Context(), companyID, transactionID)
+	if err != nil {
+		reviewErrorResponse(c, err, "Failed to retrieve review")
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+func (h *Handler) ApproveReview(c *gin.Context) {
+	companyID, transactionID, ok := reviewParams(c)
+	if !ok {
+		return
+	}
+
+	transaction, err := h.service.ApproveReview(c.Request.Context(), companyID, transactionID)
+	if err != nil {
+		reviewErrorResponse(c, err, "Failed to approve review")
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+// RejectReview confirms a flagged payment as fraud; the card is blocked.
+func (h *Handler) RejectReview(c *gin.Context) {
+	companyID, transactionID, ok := reviewParams(c)
+	if !ok {
+		return
+	}
+
+	transaction, err := h.service.RejectReview(c.Request.Context(), companyID, transactionID)
+	if err != nil {
+		reviewErrorResponse(c, err, "Failed to reject review")
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+func reviewParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return companyID, transactionID, true
+}
+
+func reviewErrorResponse(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	case errors.Is(err, errors.ErrReviewClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Review already resolved"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
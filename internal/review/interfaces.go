@@ -0,0 +1,22 @@
+package review
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	GetReviews(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Transaction, error)
+	GetReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error)
+	ResolveReview(ctx context.Context, companyID, transactionID uuid.UUID, status string, blockReason *string) (*models.Transaction, error)
+}
+
+type Service interface {
+	ListReviews(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Transaction, error)
+	GetReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error)
+	ApproveReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error)
+	RejectReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error)
+}
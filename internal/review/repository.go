@@ -0,0 +1,134 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// GetReviews lists the company's reviewed or flagged payments with the given
+// review status, oldest first so the queue is worked in order.
+func (r *repository) GetReviews(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Transaction, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+models.TransactionColumns+`
+		FROM transactions
+		WHERE company_id = $1 AND review_status = $2
+		ORDER BY created_at ASC
+		LIMIT $3 OFFSET $4`,
+		companyID, status, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var transactions []*models.Transaction
+	for rows.Next() {
+		transaction := &models.Transaction{}
+		if err := models.ScanTransaction(rows, transaction); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return transactions, nil
+}
+
+func (r *repository) GetReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	transaction := &models.Transaction{}
+	err := models.ScanTransaction(r.db.QueryRowContext(ctx, `
+		SELECT `+models.TransactionColumns+`
+		FROM transactions
+		WHERE id = $1 AND company_id = $2 AND review_status IS NOT NULL`,
+		transactionID, companyID,
+	), transaction)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get review: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// ResolveReview closes a pending review. When blockReason is set the card is
+// blocked in the same transaction.
+func (r *repository) ResolveReview(ctx context.Context, companyID, transactionID uuid.UUID, status string, blockReason *string) (*models.Transaction, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	transaction := &models.Transaction{}
+	err = models.ScanTransaction(tx.QueryRowContext(ctx, `
+		UPDATE transactions
+		SET review_status = $3, reviewed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND company_id = $2 AND review_status = $4
+		RETURNING `+models.TransactionColumns,
+		transactionID, companyID, status, models.ReviewStatusPending,
+	), transaction)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apperrors.ErrReviewClosed
+		}
+		return nil, fmt.Errorf("failed to resolve review: %w", err)
+	}
+
+	if blockReason != nil {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE cards
+			SET status = $2, blocked_at = CURRENT_TIMESTAMP, blocked_reason = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND status = $4`,
+			transaction.CardID, models.CardStatusBlocked, *blockReason, models.CardStatusActive,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to block card: %w", err)
+		}
+
+		blocked, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if blocked > 0 {
+			err = outbox.Enqueue(ctx, tx, &outbox.Event{
+				CompanyID: companyID,
+				Type:      models.EventCardBlocked,
+				Data: models.CardStatusChangedEvent{
+					CardID: transaction.CardID,
+					Status: models.CardStatusBlocked,
+					Reason: *blockReason,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return transaction, nil
+}
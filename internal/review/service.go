@@ -0,0 +1,71 @@
+package review
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+const rejectedBlockReason = "fraud confirmed by review"
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) ListReviews(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Transaction, error) {
+	if status == "" {
+		status = models.ReviewStatusPending
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.GetReviews(ctx, companyID, status, limit, offset)
+}
+
+func (s *service) GetReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	transaction, err := s.repo.GetReview(ctx, companyID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, errors.ErrNotFound
+	}
+	return transaction, nil
+}
+
+// ApproveReview marks a flagged payment as legitimate.
+func (s *service) ApproveReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	return s.resolve(ctx, companyID, transactionID, models.ReviewStatusApproved, nil)
+}
+
+// RejectReview marks a flagged payment as fraud and blocks its card.
+func (s *service) RejectReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	reason := rejectedBlockReason
+	return s.resolve(ctx, companyID, transactionID, models.ReviewStatusRejected, &reason)
+}
+
+func (s *service) resolve(ctx context.Context, companyID, transactionID uuid.UUID, status string, blockReason *string) (*models.Transaction, error) {
+	transaction, err := s.GetReview(ctx, companyID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.ReviewStatus == nil || *transaction.ReviewStatus != models.ReviewStatusPending {
+		return nil, errors.ErrReviewClosed
+	}
+
+	return s.repo.ResolveReview(ctx, companyID, transactionID, status, blockReason)
+}
@@ -3,42 +3,88 @@ package router
 import (
 	"ccards/internal/card"
 	"ccards/internal/client"
+	"ccards/internal/dispute"
+	"ccards/internal/employee"
+	"ccards/internal/merchant"
+	"ccards/internal/notification"
+	"ccards/internal/policy"
+	"ccards/internal/review"
+	"ccards/internal/store"
+	"ccards/internal/stream"
 	"ccards/internal/transaction"
+	"ccards/internal/webhook"
+	"ccards/pkg/authorization"
 	"ccards/pkg/config"
 	"ccards/pkg/middleware"
+	"ccards/pkg/vault"
 	"database/sql"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 )
 
 type Router struct {
-	engine             *gin.Engine
-	clientHandler      *client.Handler
-	cardHandler        *card.Handler
-	transactionHandler *transaction.Handler
-	config             *config.Config
-	redisClient        *redis.Client
-	db                 *sql.DB
+	engine              *gin.Engine
+	clientHandler       *client.Handler
+	cardHandler         *card.Handler
+	disputeHandler      *dispute.Handler
+	employeeHandler     *employee.Handler
+	merchantHandler     *merchant.Handler
+	notificationHandler *notification.Handler
+	policyHandler       *policy.Handler
+	reviewHandler       *review.Handler
+	storeHandler        *store.Handler
+	streamHandler       *stream.Handler
+	transactionHandler  *transaction.Handler
+	webhookHandler      *webhook.Handler
+	config              *config.Config
+	redisClient         *redis.Client
+	db                  *sql.DB
+	vault               *vault.Vault
+	paymentChecks       []authorization.Check
 }
 
 type RouterConfig struct {
-	ClientHandler      *client.Handler
-	CardHandler        *card.Handler
-	TransactionHandler *transaction.Handler
-	Config             *config.Config
-	RedisClient        *redis.Client
-	DB                 *sql.DB
+	ClientHandler       *client.Handler
+	CardHandler         *card.Handler
+	DisputeHandler      *dispute.Handler
+	EmployeeHandler     *employee.Handler
+	MerchantHandler     *merchant.Handler
+	NotificationHandler *notification.Handler
+	PolicyHandler       *policy.Handler
+	ReviewHandler       *review.Handler
+	StoreHandler        *store.Handler
+	StreamHandler       *stream.Handler
+	TransactionHandler  *transaction.Handler
+	WebhookHandler      *webhook.Handler
+	Config              *config.Config
+	RedisClient         *redis.Client
+	DB                  *sql.DB
+	Vault               *vault.Vault
+	// PaymentChecks are the checks company and store payments run through
+	// once their card is found.
+	PaymentChecks []authorization.Check
 }
 
 func NewRouter(cfg RouterConfig) *Router {
 	return &Router{
-		engine:             gin.New(),
-		clientHandler:      cfg.ClientHandler,
-		cardHandler:        cfg.CardHandler,
-		transactionHandler: cfg.TransactionHandler,
-		config:             cfg.Config,
-		redisClient:        cfg.RedisClient,
-		db:                 cfg.DB,
+		engine:              gin.New(),
+		clientHandler:       cfg.ClientHandler,
+		cardHandler:         cfg.CardHandler,
+		disputeHandler:      cfg.DisputeHandler,
+		employeeHandler:     cfg.EmployeeHandler,
+		merchantHandler:     cfg.MerchantHandler,
+		notificationHandler: cfg.NotificationHandler,
+		policyHandler:       cfg.PolicyHandler,
+		reviewHandler:       cfg.ReviewHandler,
+		storeHandler:        cfg.StoreHandler,
+		streamHandler:       cfg.StreamHandler,
+		transactionHandler:  cfg.TransactionHandler,
+		webhookHandler:      cfg.WebhookHandler,
+		config:              cfg.Config,
+		redisClient:         cfg.RedisClient,
+		db:                  cfg.DB,
+		vault:               cfg.Vault,
+		paymentChecks:       cfg.PaymentChecks,
 	}
 }
 
@@ -54,6 +100,8 @@ func (r *Router) Setup() *gin.Engine {
 	admin := r.engine.Group("/admin")
 	{
 		admin.POST("/company/register", r.clientHandler.RegisterCompany)
+		admin.POST("/company/:id/fund", r.clientHandler.FundCompany)
+		admin.POST("/store/register", r.storeHandler.RegisterStore)
 	}
 
 	authGroup := r.engine.Group("/auth/company")
@@ -61,6 +109,30 @@ func (r *Router) Setup() *gin.Engine {
 		authGroup.POST("/login", r.clientHandler.Login)
 	}
 
+	// The merchant-facing API: stores sign requests with their API keys and
+	// take card payments through the same checks as company payments.
+	storeGroup := r.engine.Group("/store")
+	merchantAuthMiddleware := middleware.NewMerchantAuthMiddleware(r.config, r.db, r.redisClient, r.vault)
+	storeGroup.Use(merchantAuthMiddleware.MerchantAuth())
+	{
+		storeGroup.GET("", r.storeHandler.GetStore)
+		storeGroup.GET("/api-keys", r.storeHandler.GetAPIKeys)
+		storeGroup.POST("/api-keys/rotate", r.storeHandler.RotateAPIKey)
+		storeGroup.DELETE("/api-keys/:id", r.storeHandler.RevokeAPIKey)
+		storeGroup.POST("/payments/authorize",
+			middleware.DeclineEvents(r.db),
+			middleware.StoreCard(r.db, r.vault),
+			middleware.Authorize(r.paymentChecks...),
+			r.storeHandler.Authorize,
+		)
+		storeGroup.GET("/payments", r.storeHandler.GetPayments)
+		storeGroup.GET("/payments/:id", r.storeHandler.GetPayment)
+		storeGroup.POST("/payments/:id/capture", r.storeHandler.CapturePayment)
+		storeGroup.POST("/payments/:id/void", r.storeHandler.VoidPayment)
+		storeGroup.POST("/payments/:id/refunds", r.storeHandler.RefundPayment)
+		storeGroup.GET("/payments/:id/refunds", r.storeHandler.GetRefunds)
+	}
+
 	apiGroup := r.engine.Group("/api")
 	clientAuthMiddleware := middleware.NewClientAuthMiddleware(r.config, r.redisClient)
 	apiGroup.Use(clientAuthMiddleware.ClientAuth())
@@ -69,10 +141,94 @@ func (r *Router) Setup() *gin.Engine {
 		{
 			companyGroup.GET("", r.clientHandler.GetCompany)
 			companyGroup.POST("/upload-csv", r.clientHandler.UploadCardCSV)
+			companyGroup.GET("/import-jobs/:id", r.clientHandler.GetImportJob)
+			companyGroup.POST("/import-jobs/:id/resume", r.clientHandler.ResumeImportJob)
 			companyGroup.GET("/card-to-issue", r.clientHandler.GetCardsToIssue)
+			companyGroup.POST("/card-to-issue/cancel", r.clientHandler.CancelCardsToIssue)
 			companyGroup.POST("/issue-cards", r.clientHandler.IssueNewCards)
 		}
 
+		employeeGroup := apiGroup.Group("/employees")
+		{
+			employeeGroup.POST("", r.employeeHandler.CreateEmployee)
+			employeeGroup.GET("", r.employeeHandler.GetEmployees)
+			employeeGroup.GET("/:id", r.employeeHandler.GetEmployee)
+			employeeGroup.PUT("/:id", r.employeeHandler.UpdateEmployee)
+			employeeGroup.DELETE("/:id", r.employeeHandler.DeleteEmployee)
+			employeeGroup.GET("/:id/cards", r.employeeHandler.GetEmployeeCards)
+			employeeGroup.POST("/:id/offboard", r.employeeHandler.OffboardEmployee)
+			employeeGroup.GET("/:id/offboarding", r.employeeHandler.GetOffboardingReport)
+		}
+
+		apiGroup.GET("/merchant-category-codes", r.merchantHandler.GetCategoryCodes)
+
+		merchantGroup := apiGroup.Group("/merchants")
+		{
+			merchantGroup.POST("", r.merchantHandler.CreateMerchant)
+			merchantGroup.GET("", r.merchantHandler.GetMerchants)
+			merchantGroup.GET("/:id", r.merchantHandler.GetMerchant)
+		}
+
+		policyGroup := apiGroup.Group("/policy-templates")
+		{
+			policyGroup.POST("", r.policyHandler.CreateTemplate)
+			policyGroup.GET("", r.policyHandler.GetTemplates)
+			policyGroup.GET("/:id", r.policyHandler.GetTemplate)
+			policyGroup.PUT("/:id", r.policyHandler.UpdateTemplate)
+			policyGroup.DELETE("/:id", r.policyHandler.DeleteTemplate)
+			policyGroup.POST("/:id/reapply", r.policyHandler.ReapplyTemplate)
+		}
+
+		disputeGroup := apiGroup.Group("/disputes")
+		{
+			disputeGroup.POST("", r.disputeHandler.CreateDispute)
+			disputeGroup.GET("", r.disputeHandler.GetDisputes)
+			disputeGroup.GET("/:id", r.disputeHandler.GetDispute)
+			disputeGroup.POST("/:id/evidence", r.disputeHandler.UploadEvidence)
+			disputeGroup.GET("/:id/evidence/:evidenceId", r.disputeHandler.DownloadEvidence)
+			disputeGroup.POST("/:id/submit", r.disputeHandler.SubmitDispute)
+			disputeGroup.POST("/:id/resolve", r.disputeHandler.ResolveDispute)
+		}
+
+		webhookGroup := apiGroup.Group("/webhooks")
+		{
+			webhookGroup.POST("", r.webhookHandler.CreateEndpoint)
+			webhookGroup.GET("", r.webhookHandler.GetEndpoints)
+			webhookGroup.GET("/:id", r.webhookHandler.GetEndpoint)
+			webhookGroup.PUT("/:id", r.webhookHandler.UpdateEndpoint)
+			webhookGroup.DELETE("/:id", r.webhookHandler.DeleteEndpoint)
+			webhookGroup.POST("/:id/rotate-secret", r.webhookHandler.RotateSecret)
+			webhookGroup.GET("/:id/deliveries", r.webhookHandler.GetDeliveries)
+			webhookGroup.POST("/:id/deliveries/:deliveryId/replay", r.webhookHandler.ReplayDelivery)
+			webhookGroup.POST("/:id/replay", r.webhookHandler.ReplayEvents)
+		}
+
+		notificationGroup := apiGroup.Group("/notifications")
+		{
+			notificationGroup.GET("/preferences", r.notificationHandler.GetPreferences)
+			notificationGroup.PUT("/preferences", r.notificationHandler.UpdatePreference)
+			notificationGroup.GET("/templates", r.notificationHandler.GetTemplates)
+			notificationGroup.PUT("/templates/:trigger", r.notificationHandler.UpdateTemplate)
+			notificationGroup.DELETE("/templates/:trigger", r.notificationHandler.ResetTemplate)
+			notificationGroup.GET("/inbox", r.notificationHandler.GetInbox)
+			notificationGroup.GET("/inbox/unread-count", r.notificationHandler.GetUnreadCount)
+			notificationGroup.POST("/inbox/read-all", r.notificationHandler.MarkAllRead)
+			notificationGroup.POST("/inbox/:id/read", r.notificationHandler.MarkRead)
+		}
+
+		eventGroup := apiGroup.Group("/events")
+		{
+			eventGroup.GET("/stream", r.streamHandler.Stream)
+		}
+
+		reviewGroup := apiGroup.Group("/reviews")
+		{
+			reviewGroup.GET("", r.reviewHandler.GetReviews)
+			reviewGroup.GET("/:id", r.reviewHandler.GetReview)
+			reviewGroup.POST("/:id/approve", r.reviewHandler.ApproveReview)
+			reviewGroup.POST("/:id/reject", r.reviewHandler.RejectReview)
+		}
+
 		cardGroup := apiGroup.Group("/cards")
 		{
 			cardGroup.GET("", r.cardHandler.GetCards)
@@ -81,15 +237,17 @@ func (r *Router) Setup() *gin.Engine {
 			cardGroup.POST("/update/unblock", r.cardHandler.Unblock)                    // companyID, cardID
 			cardGroup.POST("/update/charge", r.cardHandler.Charge)                      // companyID, cardID, amount
 			cardGroup.POST("/update/spending-control", r.cardHandler.UpdateSpendingControl)
+			cardGroup.POST("/reveal", r.cardHandler.RevealCard)
+			cardGroup.POST("/pin", r.cardHandler.SetPIN)
+			cardGroup.PUT("/pin", r.cardHandler.ChangePIN)
+			cardGroup.POST("/pin/unlock", r.cardHandler.UnlockPIN)
 
 			transactionGroup := cardGroup.Group("/transactions")
 			{
 				transactionGroup.Use(
+					middleware.DeclineEvents(r.db),
 					middleware.ValidCard(r.db),
-					middleware.UsableCard(),
-					middleware.SufficientAmount(),
-					middleware.WithinDailyLimit(r.db),
-					middleware.SpendingLimit(r.db),
+					middleware.Authorize(r.paymentChecks...),
 				)
 
 				transactionGroup.POST("", r.transactionHandler.Pay)
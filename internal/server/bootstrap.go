@@ -3,10 +3,23 @@ package server
 import (
 	"ccards/internal/card"
 	"ccards/internal/client"
+	"ccards/internal/dispute"
+	"ccards/internal/employee"
+	"ccards/internal/gateway"
+	"ccards/internal/merchant"
+	"ccards/internal/notification"
+	"ccards/internal/policy"
+	"ccards/internal/review"
 	"ccards/internal/router"
+	"ccards/internal/store"
+	"ccards/internal/stream"
 	"ccards/internal/transaction"
+	"ccards/internal/webhook"
+	"ccards/pkg/authorization"
 	"ccards/pkg/config"
 	"ccards/pkg/database"
+	"ccards/pkg/outbox"
+	"ccards/pkg/vault"
 	"context"
 	"database/sql"
 	"errors"
@@ -65,28 +78,165 @@ func (b *Bootstrap) Run() error {
 	}
 	b.redis = redisClient
 
+	cardVault, err := vault.New(cfg.Vault)
+	if err != nil {
+		return fmt.Errorf("failed to initialize card vault: %w", err)
+	}
+
 	// client
 	clientRepo := client.NewRepository(db)
-	clientService := client.NewService(clientRepo, cfg.JWT, b.redis)
+	clientService := client.NewService(clientRepo, cfg.JWT, cfg.Import, cardVault, b.redis)
 	clientHandler := client.NewHandler(clientService)
 
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	clientService.StartImportWorkers(backgroundCtx)
+	clientService.StartIssuanceScheduler(backgroundCtx, cfg.Issuance.SchedulerInterval)
+
+	// events are dispatched once every subscriber below is registered
+	eventDispatcher := outbox.NewDispatcher(db, cfg.Outbox)
+
 	// cards
 	cardRepo := card.NewRepository(db)
-	cardService := card.NewService(cardRepo)
+	cardService := card.NewService(cardRepo, cardVault, cfg.Card)
+
+	encrypted, err := cardService.EncryptStoredCardNumbers(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt stored card numbers: %w", err)
+	}
+	if encrypted > 0 {
+		log.Printf("Encrypted %d stored card numbers", encrypted)
+	}
+
+	migrated, err := cardService.MigrateCardCVVs(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to migrate card CVVs: %w", err)
+	}
+	if migrated > 0 {
+		log.Printf("Stored CVV MACs for %d cards", migrated)
+	}
 	cardHandler := card.NewHandler(cardService)
 
+	// employees
+	employeeRepo := employee.NewRepository(db)
+	employeeService := employee.NewService(employeeRepo)
+	employeeHandler := employee.NewHandler(employeeService)
+
+	// merchants
+	merchantRepo := merchant.NewRepository(db)
+	merchantService := merchant.NewService(merchantRepo)
+	merchantHandler := merchant.NewHandler(merchantService)
+
+	// policy templates
+	policyRepo := policy.NewRepository(db)
+	policyService := policy.NewService(policyRepo)
+	policyHandler := policy.NewHandler(policyService)
+
+	// disputes
+	disputeRepo := dispute.NewRepository(db)
+	disputeService := dispute.NewService(disputeRepo)
+	disputeHandler := dispute.NewHandler(disputeService)
+
+	// fraud reviews
+	reviewRepo := review.NewRepository(db)
+	reviewService := review.NewService(reviewRepo)
+	reviewHandler := review.NewHandler(reviewService)
+
+	// stores
+	storeRepo := store.NewRepository(db)
+	storeService := store.NewService(storeRepo, cardVault, cfg.Store)
+
+	sealedKeys, err := storeService.EncryptStoredSecrets(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt store API key secrets: %w", err)
+	}
+	if sealedKeys > 0 {
+		log.Printf("Encrypted %d store API key secrets", sealedKeys)
+	}
+	storeHandler := store.NewHandler(storeService)
+
 	// transaction
 	transactionRepo := transaction.NewRepository(db)
 	transactionService := transaction.NewService(transactionRepo)
 	transactionHandler := transaction.NewHandler(transactionService)
 
+	// webhooks
+	webhookRepo := webhook.NewRepository(db)
+	webhookService := webhook.NewService(webhookRepo, cardVault, cfg.Webhook)
+
+	sealedSecrets, err := webhookService.EncryptStoredSecrets(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt webhook secrets: %w", err)
+	}
+	if sealedSecrets > 0 {
+		log.Printf("Encrypted %d webhook secrets", sealedSecrets)
+	}
+	webhookService.StartDispatcher(backgroundCtx, cfg.Webhook.DispatchInterval)
+	eventDispatcher.Subscribe(webhook.SubscriberName, webhookService.HandleEvent)
+	webhookHandler := webhook.NewHandler(webhookService)
+
+	// notifications
+	notificationRepo := notification.NewRepository(db)
+	channels := []notification.Channel{
+		notification.NewInAppChannel(notificationRepo),
+		notification.NewWebhookChannel(cfg.Notification.WebhookTimeout, cfg.Webhook.AllowPrivateTargets),
+	}
+	if cfg.Notification.SMTP.Host != "" {
+		channels = append(channels, notification.NewEmailChannel(cfg.Notification.SMTP))
+	} else {
+		log.Printf("SMTP host not configured, email notifications are disabled")
+	}
+	notificationService := notification.NewService(notificationRepo, cfg.Notification, channels...)
+	notificationService.StartExpiryScheduler(backgroundCtx, cfg.Notification.ExpiryCheckInterval)
+	eventDispatcher.Subscribe(notification.SubscriberName, notificationService.HandleEvent)
+	notificationHandler := notification.NewHandler(notificationService)
+
+	// real-time event stream
+	streamRepo := stream.NewRepository(db)
+	streamService := stream.NewService(streamRepo, b.redis, cfg.Stream)
+	eventDispatcher.Subscribe(stream.SubscriberName, streamService.HandleEvent)
+	streamHandler := stream.NewHandler(streamService)
+
+	// payments take the same checks whichever way they arrive
+	paymentChecks := authorization.DefaultChecks(authorization.Dependencies{
+		DB:          db,
+		Redis:       b.redis,
+		Vault:       cardVault,
+		PINAttempts: cardRepo,
+		Card:        cfg.Card,
+		Risk:        cfg.Risk,
+	})
+
+	// ISO 8583 gateway
+	if cfg.Gateway.Enabled {
+		gatewayRepo := gateway.NewRepository(db)
+		gatewayAuthorizer := authorization.NewEngine(append([]authorization.Check{authorization.ValidCard(db)}, paymentChecks...)...)
+		gatewayService := gateway.NewService(gatewayRepo, gatewayAuthorizer, cardVault, cfg.Gateway)
+		if err := gateway.NewServer(gatewayService, cfg.Gateway).Start(backgroundCtx); err != nil {
+			return fmt.Errorf("failed to start ISO 8583 gateway: %w", err)
+		}
+	}
+
+	eventDispatcher.Start(backgroundCtx, cfg.Outbox.DispatchInterval)
+
 	r := router.NewRouter(router.RouterConfig{
-		ClientHandler:      clientHandler,
-		CardHandler:        cardHandler,
-		TransactionHandler: transactionHandler,
-		Config:             b.config,
-		RedisClient:        b.redis,
-		DB:                 b.db,
+		ClientHandler:       clientHandler,
+		CardHandler:         cardHandler,
+		DisputeHandler:      disputeHandler,
+		EmployeeHandler:     employeeHandler,
+		MerchantHandler:     merchantHandler,
+		NotificationHandler: notificationHandler,
+		PolicyHandler:       policyHandler,
+		ReviewHandler:       reviewHandler,
+		StoreHandler:        storeHandler,
+		StreamHandler:       streamHandler,
+		TransactionHandler:  transactionHandler,
+		WebhookHandler:      webhookHandler,
+		Config:              b.config,
+		RedisClient:         b.redis,
+		DB:                  b.db,
+		Vault:               cardVault,
+		PaymentChecks:       paymentChecks,
 	})
 
 	b.router = r.Setup()
@@ -119,6 +269,8 @@ func (b *Bootstrap) Run() error {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	stopBackground()
+
 	if err := b.db.Close(); err != nil {
 		log.Printf("Error closing database: %v", err)
 	}
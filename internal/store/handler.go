@@ -1,13 +1,323 @@
 package store
 
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+)
+
 type Handler struct {
 	service Service
 }
 
 func NewHandler(service Service) *Handler {
-	return &Handler{
-		service: service,
+	return &Handler{service: service}
+}
+
+// RegisterStore creates a store. The API key secret is only returned here.
+func (h *Handler) RegisterStore(c *gin.Context) {
+	var req request.RegisterStore
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	store, apiKey, err := h.service.Register(c.Request.Context(), &req)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to register store")
+		return
 	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"store":   store,
+		"api_key": apiKey,
+	})
 }
 
-// Add your handler methods here
\ No newline at end of file
+// RotateAPIKey creates a new API key and returns it with its secret.
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	apiKey, err := h.service.RotateAPIKey(c.Request.Context(), storeID)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to rotate API key")
+		return
+	}
+
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	apiKeys, err := h.service.ListAPIKeys(c.Request.Context(), storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+	if apiKeys == nil {
+		apiKeys = []*models.StoreAPIKey{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys": apiKeys,
+		"count":    len(apiKeys),
+	})
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID format"})
+		return
+	}
+
+	apiKey, err := h.service.RevokeAPIKey(c.Request.Context(), storeID, id, c.GetString("api_key_id"))
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to revoke API key")
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKey)
+}
+
+func (h *Handler) GetStore(c *gin.Context) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	store, err := h.service.GetStore(c.Request.Context(), storeID)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to retrieve store")
+		return
+	}
+
+	c.JSON(http.StatusOK, store)
+}
+
+// Authorize records a payment once the payment middlewares have approved it.
+func (h *Handler) Authorize(c *gin.Context) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	req, err := middleware.GetAuthorizationRequest(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid transaction request format in context"})
+		return
+	}
+	authReq, ok := c.MustGet("store_authorization").(*request.StoreAuthorization)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid authorization request format in context"})
+		return
+	}
+
+	payment, err := h.service.Authorize(c.Request.Context(), storeID, req, authReq.Reference)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to authorize payment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+func (h *Handler) GetPayments(c *gin.Context) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.StorePaymentStatusAuthorized, models.StorePaymentStatusCaptured,
+		models.StorePaymentStatusVoided, models.StorePaymentStatusRefunded:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment status"})
+		return
+	}
+
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+
+	payments, err := h.service.ListPayments(c.Request.Context(), storeID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payments"})
+		return
+	}
+	if payments == nil {
+		payments = []*models.StorePayment{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payments":  payments,
+		"count":     len(payments),
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+func (h *Handler) GetPayment(c *gin.Context) {
+	storeID, paymentID, ok := paymentParams(c)
+	if !ok {
+		return
+	}
+
+	payment, err := h.service.GetPayment(c.Request.Context(), storeID, paymentID)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to retrieve payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// CapturePayment captures an authorized payment, in full unless an amount is
+// given.
+func (h *Handler) CapturePayment(c *gin.Context) {
+	storeID, paymentID, ok := paymentParams(c)
+	if !ok {
+		return
+	}
+
+	var req request.StoreCapture
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	payment, err := h.service.Capture(c.Request.Context(), storeID, paymentID, &req)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to capture payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+func (h *Handler) VoidPayment(c *gin.Context) {
+	storeID, paymentID, ok := paymentParams(c)
+	if !ok {
+		return
+	}
+
+	payment, err := h.service.Void(c.Request.Context(), storeID, paymentID)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to void payment")
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// RefundPayment refunds a captured payment, everything not yet refunded unless
+// an amount is given.
+func (h *Handler) RefundPayment(c *gin.Context) {
+	storeID, paymentID, ok := paymentParams(c)
+	if !ok {
+		return
+	}
+
+	var req request.StoreRefund
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	refund, err := h.service.Refund(c.Request.Context(), storeID, paymentID, &req)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to refund payment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, refund)
+}
+
+func (h *Handler) GetRefunds(c *gin.Context) {
+	storeID, paymentID, ok := paymentParams(c)
+	if !ok {
+		return
+	}
+
+	refunds, err := h.service.ListRefunds(c.Request.Context(), storeID, paymentID)
+	if err != nil {
+		storeErrorResponse(c, err, "Failed to retrieve refunds")
+		return
+	}
+	if refunds == nil {
+		refunds = []*models.StoreRefund{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refunds": refunds,
+		"count":   len(refunds),
+	})
+}
+
+func paymentParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	storeID, err := middleware.GetStoreIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return storeID, paymentID, true
+}
+
+func storeErrorResponse(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, errors.ErrStoreExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A store with this email already exists"})
+	case errors.Is(err, errors.ErrAPIKeyInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "The API key signing this request cannot be revoked"})
+	case errors.Is(err, errors.ErrUnknownMCC):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown merchant category code"})
+	case errors.Is(err, errors.ErrPaymentExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment reference already used"})
+	case errors.Is(err, errors.ErrPaymentTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": "Payment status does not allow this action"})
+	case errors.Is(err, errors.ErrPaymentAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than zero and at most the amount remaining"})
+	case errors.Is(err, errors.ErrInsufficientFunds):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient funds"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
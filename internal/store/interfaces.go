@@ -1,6 +1,51 @@
 package store
 
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	CreateStore(ctx context.Context, store *models.Store, apiKey *models.StoreAPIKey) error
+	GetStore(ctx context.Context, storeID uuid.UUID) (*models.Store, error)
+
+	// API key operations
+	CreateAPIKey(ctx context.Context, apiKey *models.StoreAPIKey, retireAt time.Time) error
+	GetAPIKeys(ctx context.Context, storeID uuid.UUID) ([]*models.StoreAPIKey, error)
+	RevokeAPIKey(ctx context.Context, storeID, id uuid.UUID) (*models.StoreAPIKey, error)
+	EncryptAPIKeySecrets(ctx context.Context, seal func(secret string) (string, error)) (int, error)
+
+	// Payment operations
+	CreatePayment(ctx context.Context, payment *models.StorePayment, purchase *models.Transaction) error
+	GetPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error)
+	GetPayments(ctx context.Context, storeID uuid.UUID, status string, limit, offset int) ([]*models.StorePayment, error)
+	CapturePayment(ctx context.Context, storeID, paymentID uuid.UUID, amount *float64) (*models.StorePayment, error)
+	VoidPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error)
+	RefundPayment(ctx context.Context, storeID, paymentID uuid.UUID, amount *float64, reason *string) (*models.StoreRefund, error)
+	GetRefunds(ctx context.Context, storeID, paymentID uuid.UUID) ([]*models.StoreRefund, error)
+}
+
 // Service defines the methods that the store service must implement
 type Service interface {
-	// Add your service methods here
-}
\ No newline at end of file
+	Register(ctx context.Context, req *request.RegisterStore) (*models.Store, *models.StoreAPIKey, error)
+	GetStore(ctx context.Context, storeID uuid.UUID) (*models.Store, error)
+
+	RotateAPIKey(ctx context.Context, storeID uuid.UUID) (*models.StoreAPIKey, error)
+	ListAPIKeys(ctx context.Context, storeID uuid.UUID) ([]*models.StoreAPIKey, error)
+	RevokeAPIKey(ctx context.Context, storeID, id uuid.UUID, currentKeyID string) (*models.StoreAPIKey, error)
+	EncryptStoredSecrets(ctx context.Context) (int, error)
+
+	Authorize(ctx context.Context, storeID uuid.UUID, req *authorization.Request, reference string) (*models.StorePayment, error)
+	GetPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error)
+	ListPayments(ctx context.Context, storeID uuid.UUID, status string, limit, offset int) ([]*models.StorePayment, error)
+	Capture(ctx context.Context, storeID, paymentID uuid.UUID, req *request.StoreCapture) (*models.StorePayment, error)
+	Void(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error)
+	Refund(ctx context.Context, storeID, paymentID uuid.UUID, req *request.StoreRefund) (*models.StoreRefund, error)
+	ListRefunds(ctx context.Context, storeID, paymentID uuid.UUID) ([]*models.StoreRefund, error)
+}
@@ -1 +1,647 @@
 package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/ledger"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+const storeColumns = `s.id, s.merchant_id, m.name, m.mcc, s.email, s.status, s.created_at, s.updated_at`
+
+// apiKeyColumns leaves out the secret, which is only returned on creation.
+const apiKeyColumns = `id, store_id, key_id, expires_at, revoked_at, created_at`
+
+const refundColumns = `r.id, r.payment_id, r.transaction_id, r.amount, r.reason, r.created_at`
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func scanStore(row models.RowScanner, store *models.Store) error {
+	return row.Scan(
+		&store.ID, &store.MerchantID, &store.Name, &store.MCC, &store.Email, &store.Status,
+		&store.CreatedAt, &store.UpdatedAt,
+	)
+}
+
+func scanAPIKey(row models.RowScanner, apiKey *models.StoreAPIKey) error {
+	return row.Scan(&apiKey.ID, &apiKey.StoreID, &apiKey.KeyID, &apiKey.ExpiresAt, &apiKey.RevokedAt, &apiKey.CreatedAt)
+}
+
+func insertAPIKey(ctx context.Context, tx *sql.Tx, apiKey *models.StoreAPIKey) error {
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO store_api_keys (id, store_id, key_id, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at`,
+		apiKey.ID, apiKey.StoreID, apiKey.KeyID, apiKey.Secret,
+	).Scan(&apiKey.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+func scanRefund(row models.RowScanner, refund *models.StoreRefund) error {
+	return row.Scan(&refund.ID, &refund.PaymentID, &refund.TransactionID, &refund.Amount, &refund.Reason, &refund.CreatedAt)
+}
+
+// CreateStore registers a store with its merchant catalogue entry and first
+// API key.
+func (r *repository) CreateStore(ctx context.Context, store *models.Store, apiKey *models.StoreAPIKey) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO merchants (id, name, mcc)
+		VALUES ($1, $2, $3)`,
+		store.MerchantID, store.Name, store.MCC,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return apperrors.ErrStoreExists
+			case "23503":
+				return apperrors.ErrUnknownMCC
+			}
+		}
+		return fmt.Errorf("failed to create merchant: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO stores (id, merchant_id, email, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at`,
+		store.ID, store.MerchantID, store.Email, store.Status,
+	).Scan(&store.CreatedAt, &store.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return apperrors.ErrStoreExists
+		}
+		return fmt.Errorf("failed to create store: %w", err)
+	}
+
+	if err := insertAPIKey(ctx, tx, apiKey); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetStore(ctx context.Context, storeID uuid.UUID) (*models.Store, error) {
+	store := &models.Store{}
+	err := scanStore(r.db.QueryRowContext(ctx, `
+		SELECT `+storeColumns+`
+		FROM stores s
+		JOIN merchants m ON m.id = s.merchant_id
+		WHERE s.id = $1`,
+		storeID,
+	), store)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get store: %w", err)
+	}
+
+	return store, nil
+}
+
+// CreateAPIKey adds an API key to a store. The store's other keys stop
+// working at retireAt, or earlier if they were already due to.
+func (r *repository) CreateAPIKey(ctx context.Context, apiKey *models.StoreAPIKey, retireAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE store_api_keys
+		SET expires_at = $2
+		WHERE store_id = $1
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > $2)`,
+		apiKey.StoreID, retireAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to retire API keys: %w", err)
+	}
+
+	if err := insertAPIKey(ctx, tx, apiKey); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetAPIKeys(ctx context.Context, storeID uuid.UUID) ([]*models.StoreAPIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM store_api_keys
+		WHERE store_id = $1
+		ORDER BY created_at DESC`,
+		storeID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var apiKeys []*models.StoreAPIKey
+	for rows.Next() {
+		apiKey := &models.StoreAPIKey{}
+		if err := scanAPIKey(rows, apiKey); err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+// RevokeAPIKey stops an API key at once. Revoking a revoked key keeps its
+// first revocation time. It returns nil if the store has no such key.
+func (r *repository) RevokeAPIKey(ctx context.Context, storeID, id uuid.UUID) (*models.StoreAPIKey, error) {
+	apiKey := &models.StoreAPIKey{}
+	err := scanAPIKey(r.db.QueryRowContext(ctx, `
+		UPDATE store_api_keys
+		SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND store_id = $2
+		RETURNING `+apiKeyColumns,
+		id, storeID,
+	), apiKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// EncryptAPIKeySecrets seals API key secrets still stored in plaintext and
+// returns the number of keys updated.
+func (r *repository) EncryptAPIKeySecrets(ctx context.Context, seal func(secret string) (string, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, secret
+		FROM store_api_keys
+		WHERE secret NOT LIKE 'vault:%'
+		FOR UPDATE`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch API key secrets: %w", err)
+	}
+
+	secrets := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan API key secret: %w", err)
+		}
+		secrets[id] = secret
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	for id, secret := range secrets {
+		sealed, err := seal(secret)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE store_api_keys SET secret = $2 WHERE id = $1`, id, sealed)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store encrypted API key secret: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(secrets), nil
+}
+
+// CreatePayment records an authorized store payment. The purchase is debited
+// from the card and its payment.completed event recorded in the same
+// transaction, exactly as for a company's own payments.
+func (r *repository) CreatePayment(ctx context.Context, payment *models.StorePayment, purchase *models.Transaction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	available, err := ledger.AvailableBalance(ctx, tx, purchase.CardID)
+	if err != nil {
+		return err
+	}
+	if available < purchase.Amount {
+		return apperrors.ErrInsufficientFunds
+	}
+
+	var riskReasons []byte
+	if purchase.RiskReasons != nil {
+		if riskReasons, err = json.Marshal(purchase.RiskReasons); err != nil {
+			return fmt.Errorf("failed to encode risk reasons: %w", err)
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO transactions (
+			id, card_id, company_id, transaction_type, amount,
+			merchant_name, merchant_category, mcc, merchant_id, description, status,
+			channel, terminal_id, processed_at,
+			risk_score, risk_action, risk_reasons, review_status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING created_at, updated_at`,
+		purchase.ID, purchase.CardID, purchase.CompanyID, purchase.TransactionType, purchase.Amount,
+		purchase.MerchantName, purchase.MerchantCategory, purchase.MCC, purchase.MerchantID, purchase.Description,
+		purchase.Status, purchase.Channel, purchase.TerminalID, purchase.ProcessedAt,
+		purchase.RiskScore, purchase.RiskAction, riskReasons, purchase.ReviewStatus,
+	).Scan(&purchase.CreatedAt, &purchase.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE cards SET balance = balance - $2 WHERE id = $1`, purchase.CardID, purchase.Amount); err != nil {
+		return fmt.Errorf("failed to debit card: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO store_payments (
+			id, store_id, card_id, company_id, transaction_id, reference, card_last_four, amount, status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at`,
+		payment.ID, payment.StoreID, payment.CardID, payment.CompanyID, payment.TransactionID,
+		payment.Reference, payment.CardLastFour, payment.Amount, payment.Status,
+	).Scan(&payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return apperrors.ErrPaymentExists
+		}
+		return fmt.Errorf("failed to create store payment: %w", err)
+	}
+
+	err = outbox.Enqueue(ctx, tx, &outbox.Event{
+		CompanyID: purchase.CompanyID,
+		Type:      models.EventPaymentCompleted,
+		Data:      purchase,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record payment event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	payment := &models.StorePayment{}
+	err := models.ScanStorePayment(r.db.QueryRowContext(ctx, `
+		SELECT `+models.StorePaymentColumns+`
+		FROM store_payments
+		WHERE id = $1 AND store_id = $2`,
+		paymentID, storeID,
+	), payment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get store payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+func (r *repository) GetPayments(ctx context.Context, storeID uuid.UUID, status string, limit, offset int) ([]*models.StorePayment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+models.StorePaymentColumns+`
+		FROM store_payments
+		WHERE store_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3 OFFSET $4`,
+		storeID, status, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*models.StorePayment
+	for rows.Next() {
+		payment := &models.StorePayment{}
+		if err := models.ScanStorePayment(rows, payment); err != nil {
+			return nil, fmt.Errorf("failed to scan store payment: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// lockPayment reads a store payment for update. It returns nil if the store
+// has no such payment.
+func lockPayment(ctx context.Context, tx *sql.Tx, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	payment := &models.StorePayment{}
+	err := models.ScanStorePayment(tx.QueryRowContext(ctx, `
+		SELECT `+models.StorePaymentColumns+`
+		FROM store_payments
+		WHERE id = $1 AND store_id = $2
+		FOR UPDATE`,
+		paymentID, storeID,
+	), payment)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock store payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// CapturePayment captures an authorized payment, all of it when amount is
+// nil. Capturing less releases the rest to the card and lowers the purchase
+// to the captured amount.
+func (r *repository) CapturePayment(ctx context.Context, storeID, paymentID uuid.UUID, amount *float64) (*models.StorePayment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := lockPayment(ctx, tx, storeID, paymentID)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.Status != models.StorePaymentStatusAuthorized {
+		return nil, apperrors.ErrPaymentTransition
+	}
+
+	captured := payment.Amount
+	if amount != nil {
+		captured = ledger.Round(*amount)
+	}
+	if captured <= 0 || captured > payment.Amount {
+		return nil, apperrors.ErrPaymentAmount
+	}
+
+	purchase, err := ledger.LockPurchase(ctx, tx, payment.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+	// A dispute may already have credited part of the purchase back.
+	if captured < purchase.Refunded {
+		return nil, apperrors.ErrPaymentAmount
+	}
+
+	if released := ledger.Round(payment.Amount - captured); released > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE transactions SET amount = $2 WHERE id = $1`, payment.TransactionID, captured); err != nil {
+			return nil, fmt.Errorf("failed to update purchase amount: %w", err)
+		}
+		if _, err := ledger.CreditCard(ctx, tx, payment.CardID, payment.CompanyID, released); err != nil {
+			return nil, fmt.Errorf("failed to release uncaptured amount: %w", err)
+		}
+	}
+
+	err = models.ScanStorePayment(tx.QueryRowContext(ctx, `
+		UPDATE store_payments
+		SET status = $2, captured_amount = $3, captured_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+models.StorePaymentColumns,
+		payment.ID, models.StorePaymentStatusCaptured, captured,
+	), payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture store payment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return payment, nil
+}
+
+// VoidPayment cancels an authorized payment: the purchase is voided and the
+// part of its amount not yet credited back by a dispute is returned to the
+// card.
+func (r *repository) VoidPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := lockPayment(ctx, tx, storeID, paymentID)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.Status != models.StorePaymentStatusAuthorized {
+		return nil, apperrors.ErrPaymentTransition
+	}
+
+	purchase, err := ledger.LockPurchase(ctx, tx, payment.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE transactions SET status = $2 WHERE id = $1`, payment.TransactionID, models.TransactionStatusVoided); err != nil {
+		return nil, fmt.Errorf("failed to void purchase: %w", err)
+	}
+	if released := purchase.Refundable(); released > 0 {
+		if _, err := ledger.Credit(ctx, tx, purchase, released); err != nil {
+			return nil, fmt.Errorf("failed to release authorized amount: %w", err)
+		}
+	}
+
+	err = models.ScanStorePayment(tx.QueryRowContext(ctx, `
+		UPDATE store_payments
+		SET status = $2, voided_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+models.StorePaymentColumns,
+		payment.ID, models.StorePaymentStatusVoided,
+	), payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to void store payment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return payment, nil
+}
+
+// RefundPayment refunds a captured payment, everything not yet refunded when
+// amount is nil. The refund is credited to the card as a refund transaction
+// at the same merchant as the purchase. Amounts a dispute already credited
+// back cannot be refunded again.
+func (r *repository) RefundPayment(ctx context.Context, storeID, paymentID uuid.UUID, amount *float64, reason *string) (*models.StoreRefund, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	payment, err := lockPayment(ctx, tx, storeID, paymentID)
+	if err != nil || payment == nil {
+		return nil, err
+	}
+	if payment.Status != models.StorePaymentStatusCaptured {
+		return nil, apperrors.ErrPaymentTransition
+	}
+
+	purchase, err := ledger.LockPurchase(ctx, tx, payment.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := math.Min(ledger.Round(payment.CapturedAmount-payment.RefundedAmount), purchase.Refundable())
+	refunded := remaining
+	if amount != nil {
+		refunded = ledger.Round(*amount)
+	}
+	if refunded <= 0 || refunded > remaining {
+		return nil, apperrors.ErrPaymentAmount
+	}
+
+	refund := &models.StoreRefund{
+		ID:            uuid.New(),
+		PaymentID:     payment.ID,
+		TransactionID: uuid.New(),
+		Amount:        refunded,
+		Reason:        reason,
+	}
+
+	toCompany, err := ledger.Credit(ctx, tx, purchase, refund.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to credit card: %w", err)
+	}
+
+	description := fmt.Sprintf("Refund of store payment %s", payment.ID)
+	if toCompany {
+		description += " (credited to company balance)"
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO transactions (
+			id, card_id, company_id, transaction_type, amount,
+			merchant_name, merchant_category, mcc, merchant_id, description, status,
+			channel, processed_at
+		)
+		SELECT $1, card_id, company_id, $2, $3,
+			merchant_name, merchant_category, mcc, merchant_id, $4, $5,
+			channel, CURRENT_TIMESTAMP
+		FROM transactions
+		WHERE id = $6`,
+		refund.TransactionID, models.TransactionTypeRefund, refund.Amount,
+		description, models.TransactionStatusCompleted,
+		payment.TransactionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record refund: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO store_refunds (id, payment_id, transaction_id, amount, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`,
+		refund.ID, refund.PaymentID, refund.TransactionID, refund.Amount, refund.Reason,
+	).Scan(&refund.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store refund: %w", err)
+	}
+
+	status := models.StorePaymentStatusCaptured
+	if ledger.Round(payment.RefundedAmount+refunded) == payment.CapturedAmount {
+		status = models.StorePaymentStatusRefunded
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE store_payments
+		SET refunded_amount = refunded_amount + $2, status = $3
+		WHERE id = $1`,
+		payment.ID, refund.Amount, status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update store payment: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return refund, nil
+}
+
+func (r *repository) GetRefunds(ctx context.Context, storeID, paymentID uuid.UUID) ([]*models.StoreRefund, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+refundColumns+`
+		FROM store_refunds r
+		JOIN store_payments p ON p.id = r.payment_id
+		WHERE r.payment_id = $1 AND p.store_id = $2
+		ORDER BY r.created_at`,
+		paymentID, storeID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store refunds: %w", err)
+	}
+	defer rows.Close()
+
+	var refunds []*models.StoreRefund
+	for rows.Next() {
+		refund := &models.StoreRefund{}
+		if err := scanRefund(rows, refund); err != nil {
+			return nil, fmt.Errorf("failed to scan store refund: %w", err)
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, rows.Err()
+}
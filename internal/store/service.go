@@ -1,12 +1,277 @@
 package store
 
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+	"ccards/pkg/vault"
+)
+
 type service struct {
-	// Add dependencies here
+	repo   Repository
+	vault  *vault.Vault
+	config config.StoreConfig
 }
 
 // NewService creates a new store service
-func NewService() Service {
-	return &service{}
+func NewService(repo Repository, secretVault *vault.Vault, storeConfig config.StoreConfig) Service {
+	return &service{
+		repo:   repo,
+		vault:  secretVault,
+		config: storeConfig,
+	}
+}
+
+// newAPIKey returns a new API key with its secret sealed for storage, and
+// the secret itself.
+func (s *service) newAPIKey(storeID uuid.UUID) (*models.StoreAPIKey, string, error) {
+	keyID, secret, err := utils.GenerateAPIKeyPair()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	sealed, err := s.vault.SealSecret(secret, utils.APIKeySecretPurpose)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt API key secret: %w", err)
+	}
+
+	return &models.StoreAPIKey{
+		ID:      uuid.New(),
+		StoreID: storeID,
+		KeyID:   keyID,
+		Secret:  sealed,
+	}, secret, nil
 }
 
-// Implement the Service interface methods here
\ No newline at end of file
+// Register creates a store and returns it with its first API key, whose
+// secret is not shown again.
+func (s *service) Register(ctx context.Context, req *request.RegisterStore) (*models.Store, *models.StoreAPIKey, error) {
+	store := &models.Store{
+		ID:         uuid.New(),
+		MerchantID: uuid.New(),
+		Name:       strings.TrimSpace(req.Name),
+		MCC:        req.MCC,
+		Email:      strings.ToLower(strings.TrimSpace(req.Email)),
+		Status:     models.StoreStatusActive,
+	}
+
+	apiKey, secret, err := s.newAPIKey(store.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.repo.CreateStore(ctx, store, apiKey); err != nil {
+		return nil, nil, err
+	}
+
+	apiKey.Secret = secret
+	return store, apiKey, nil
+}
+
+func (s *service) GetStore(ctx context.Context, storeID uuid.UUID) (*models.Store, error) {
+	store, err := s.repo.GetStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return store, nil
+}
+
+// RotateAPIKey creates a new API key. The store's other keys keep working
+// for the rotation grace period, so it can switch over without downtime.
+func (s *service) RotateAPIKey(ctx context.Context, storeID uuid.UUID) (*models.StoreAPIKey, error) {
+	apiKey, secret, err := s.newAPIKey(storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateAPIKey(ctx, apiKey, time.Now().Add(s.config.KeyRotationGrace)); err != nil {
+		return nil, err
+	}
+
+	apiKey.Secret = secret
+	return apiKey, nil
+}
+
+// EncryptStoredSecrets seals API key secrets stored before they were
+// encrypted. It returns the number of keys updated.
+func (s *service) EncryptStoredSecrets(ctx context.Context) (int, error) {
+	return s.repo.EncryptAPIKeySecrets(ctx, func(secret string) (string, error) {
+		return s.vault.SealSecret(secret, utils.APIKeySecretPurpose)
+	})
+}
+
+func (s *service) ListAPIKeys(ctx context.Context, storeID uuid.UUID) ([]*models.StoreAPIKey, error) {
+	return s.repo.GetAPIKeys(ctx, storeID)
+}
+
+// RevokeAPIKey stops an API key at once. The key the request is signed with
+// cannot be revoked, so a store cannot lock itself out.
+func (s *service) RevokeAPIKey(ctx context.Context, storeID, id uuid.UUID, currentKeyID string) (*models.StoreAPIKey, error) {
+	apiKeys, err := s.repo.GetAPIKeys(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(apiKeys, func(apiKey *models.StoreAPIKey) bool { return apiKey.ID == id })
+	if idx < 0 {
+		return nil, errors.ErrNotFound
+	}
+	if apiKeys[idx].KeyID == currentKeyID {
+		return nil, errors.ErrAPIKeyInUse
+	}
+
+	apiKey, err := s.repo.RevokeAPIKey(ctx, storeID, id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return apiKey, nil
+}
+
+// Authorize records a payment the payment middlewares accepted. The amount is
+// taken from the card straight away and held until the payment is captured
+// or voided.
+func (s *service) Authorize(ctx context.Context, storeID uuid.UUID, req *authorization.Request, reference string) (*models.StorePayment, error) {
+	card := req.Card
+	now := time.Now()
+	purchase := &models.Transaction{
+		ID:               uuid.New(),
+		CardID:           card.ID,
+		CompanyID:        card.CompanyID,
+		TransactionType:  models.TransactionTypePurchase,
+		Amount:           req.Amount,
+		MerchantName:     optionalString(req.MerchantName),
+		MerchantCategory: &req.MerchantCategory,
+		MCC:              optionalString(req.MCC),
+		MerchantID:       req.MerchantID,
+		Description:      "Card purchase",
+		Status:           models.TransactionStatusCompleted,
+		Channel:          req.Channel,
+		TerminalID:       optionalString(req.TerminalID),
+		ProcessedAt:      &now,
+	}
+
+	if req.Risk != nil {
+		score, action := req.Risk.Score, req.Risk.Action
+		purchase.RiskScore = &score
+		purchase.RiskAction = &action
+		purchase.RiskReasons = req.Risk.Reasons
+		if action == models.RiskActionReview {
+			reviewStatus := models.ReviewStatusPending
+			purchase.ReviewStatus = &reviewStatus
+		}
+	}
+
+	payment := &models.StorePayment{
+		ID:            uuid.New(),
+		StoreID:       storeID,
+		CardID:        card.ID,
+		CompanyID:     card.CompanyID,
+		TransactionID: purchase.ID,
+		Reference:     optionalString(reference),
+		CardLastFour:  card.LastFour,
+		Amount:        req.Amount,
+		Status:        models.StorePaymentStatusAuthorized,
+	}
+
+	if err := s.repo.CreatePayment(ctx, payment, purchase); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (s *service) GetPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	payment, err := s.repo.GetPayment(ctx, storeID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return payment, nil
+}
+
+func (s *service) ListPayments(ctx context.Context, storeID uuid.UUID, status string, limit, offset int) ([]*models.StorePayment, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.GetPayments(ctx, storeID, status, limit, offset)
+}
+
+func (s *service) Capture(ctx context.Context, storeID, paymentID uuid.UUID, req *request.StoreCapture) (*models.StorePayment, error) {
+	payment, err := s.repo.CapturePayment(ctx, storeID, paymentID, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return payment, nil
+}
+
+func (s *service) Void(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	payment, err := s.repo.VoidPayment(ctx, storeID, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return payment, nil
+}
+
+func (s *service) Refund(ctx context.Context, storeID, paymentID uuid.UUID, req *request.StoreRefund) (*models.StoreRefund, error) {
+	refund, err := s.repo.RefundPayment(ctx, storeID, paymentID, req.Amount, optionalString(strings.TrimSpace(req.Reason)))
+	if err != nil {
+		return nil, err
+	}
+	if refund == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return refund, nil
+}
+
+func (s *service) ListRefunds(ctx context.Context, storeID, paymentID uuid.UUID) ([]*models.StoreRefund, error) {
+	if _, err := s.GetPayment(ctx, storeID, paymentID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetRefunds(ctx, storeID, paymentID)
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/pkg/middleware"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Stream sends the company's events as Server-Sent Events until the client
+// disconnects. Each event's id is its event ID; a client reconnecting with it
+// in the Last-Event-ID header is sent the events it missed first.
+func (h *Handler) Stream(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var lastEventID *uuid.UUID
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		id, err := uuid.Parse(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID format"})
+			return
+		}
+		lastEventID = &id
+	}
+
+	ctx := c.Request.Context()
+	events, err := h.service.Subscribe(ctx, companyID, lastEventID)
+	if err != nil {
+		log.Printf("Warning: failed to open event stream for company %s: %v", companyID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Event stream unavailable"})
+		return
+	}
+
+	// The stream stays open past the server's write timeout.
+	err = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Warning: failed to clear write deadline of event stream: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.service.HeartbeatInterval())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Warning: failed to marshal streamed event %s: %v", event.ID, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,20 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+type Repository interface {
+	GetEventsSince(ctx context.Context, companyID, lastEventID uuid.UUID, eventTypes []string, limit int) ([]*models.Event, error)
+}
+
+type Service interface {
+	HandleEvent(ctx context.Context, event *models.Event) error
+	Subscribe(ctx context.Context, companyID uuid.UUID, lastEventID *uuid.UUID) (<-chan *models.Event, error)
+	HeartbeatInterval() time.Duration
+}
@@ -0,0 +1,53 @@
+package stream
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"ccards/pkg/models"
+)
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+// GetEventsSince returns the company's events of the given types created
+// after lastEventID, oldest first. An unknown lastEventID returns nothing.
+func (r *repository) GetEventsSince(ctx context.Context, companyID, lastEventID uuid.UUID, eventTypes []string, limit int) ([]*models.Event, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ev.id, ev.company_id, ev.event_type, ev.payload, ev.created_at
+		FROM outbox_events ev
+		WHERE ev.company_id = $1 AND ev.event_type = ANY($2)
+			AND (ev.created_at, ev.id) > (
+				SELECT last.created_at, last.id
+				FROM outbox_events last
+				WHERE last.id = $3 AND last.company_id = $1
+			)
+		ORDER BY ev.created_at, ev.id
+		LIMIT $4`,
+		companyID, pq.Array(eventTypes), lastEventID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events since %s: %w", lastEventID, err)
+	}
+	defer rows.Close()
+
+	var events []*models.Event
+	for rows.Next() {
+		event := &models.Event{}
+		if err := rows.Scan(&event.ID, &event.CompanyID, &event.Type, &event.Data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
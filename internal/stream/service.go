@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+)
+
+// SubscriberName is the name the stream subscribes to outbox events under.
+const SubscriberName = "stream"
+
+// EventTypes are the events streamed to clients: authorizations, declines and
+// card status changes.
+var EventTypes = []string{
+	models.EventPaymentCompleted,
+	models.EventPaymentDeclined,
+	models.EventCardBlocked,
+	models.EventCardStatusChanged,
+}
+
+// subscriptionBuffer is the number of events a slow client may fall behind
+// before the stream stops reading from Redis for it.
+const subscriptionBuffer = 64
+
+type service struct {
+	repo   Repository
+	redis  *redis.Client
+	config config.StreamConfig
+}
+
+func NewService(repo Repository, redisClient *redis.Client, streamConfig config.StreamConfig) Service {
+	return &service{
+		repo:   repo,
+		redis:  redisClient,
+		config: streamConfig,
+	}
+}
+
+func channelName(companyID uuid.UUID) string {
+	return fmt.Sprintf("stream:%s", companyID.String())
+}
+
+// HandleEvent publishes an outbox event to the company's Redis channel, which
+// every server instance with an open stream of the company listens on.
+func (s *service) HandleEvent(ctx context.Context, event *models.Event) error {
+	if !slices.Contains(EventTypes, event.Type) {
+		return nil
+	}
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if err := s.redis.Publish(ctx, channelName(event.CompanyID), message).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+// Subscribe streams the company's events until ctx is done. With a
+// lastEventID the events created after it are sent first, so a client that
+// reconnects does not miss what happened in between. The channel is closed
+// when ctx is done or the Redis subscription ends.
+func (s *service) Subscribe(ctx context.Context, companyID uuid.UUID, lastEventID *uuid.UUID) (<-chan *models.Event, error) {
+	pubsub := s.redis.Subscribe(ctx, channelName(companyID))
+	// Wait for the subscription, so that nothing published after the missed
+	// events are read slips through.
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	var missed []*models.Event
+	if lastEventID != nil {
+		var err error
+		missed, err = s.repo.GetEventsSince(ctx, companyID, *lastEventID, EventTypes, s.config.ReplayLimit)
+		if err != nil {
+			pubsub.Close()
+			return nil, err
+		}
+	}
+
+	events := make(chan *models.Event, subscriptionBuffer)
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+
+		// Events published while the missed ones were read arrive twice.
+		replayed := make(map[uuid.UUID]bool, len(missed))
+		for _, event := range missed {
+			replayed[event.ID] = true
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				event := &models.Event{}
+				if err := json.Unmarshal([]byte(message.Payload), event); err != nil {
+					log.Printf("Warning: failed to decode streamed event: %v", err)
+					continue
+				}
+				if replayed[event.ID] {
+					continue
+				}
+
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+func (s *service) HeartbeatInterval() time.Duration {
+	return s.config.HeartbeatInterval
+}
@@ -6,6 +6,7 @@ import (
 
 	"ccards/internal/api/request"
 	"ccards/internal/api/response"
+	"ccards/pkg/middleware"
 	"ccards/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,26 +20,14 @@ func NewHandler(service Service) *Handler {
 	return &Handler{service: service}
 }
 
+// Pay records a payment once the payment middlewares have approved it.
 func (h *Handler) Pay(c *gin.Context) {
-	var req request.Transaction
-
-	if txReq, exists := c.Get("transaction_request"); exists {
-		txReqPtr, ok := txReq.(*request.Transaction)
-		if !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Invalid transaction request format in context",
-			})
-			return
-		}
-		req = *txReqPtr
-	} else {
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":   "Invalid request body",
-				"details": err.Error(),
-			})
-			return
-		}
+	authReq, err := middleware.GetAuthorizationRequest(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Transaction request not found",
+		})
+		return
 	}
 
 	companyID, exists := c.Get("company_id")
@@ -57,37 +46,35 @@ func (h *Handler) Pay(c *gin.Context) {
 		return
 	}
 
-	if req.CompanyID != companyUUID {
+	if authReq.CompanyID != companyUUID {
 		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Company ID mismatch",
 		})
 		return
 	}
 
-	card, exists := c.Get("card")
-	if !exists {
+	if authReq.Card == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Card information not found",
 		})
 		return
 	}
+	cardLastFour := authReq.Card.LastFour
 
-	cardModel, ok := card.(*models.Card)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Invalid card information format",
-		})
-		return
+	req := request.Transaction{
+		CompanyID:        authReq.CompanyID,
+		CardID:           authReq.CardID,
+		Amount:           authReq.Amount,
+		MerchantCategory: authReq.MerchantCategory,
+		MCC:              authReq.MCC,
+		MerchantID:       authReq.MerchantID,
+		MerchantName:     authReq.MerchantName,
+		Channel:          authReq.Channel,
+		TerminalID:       authReq.TerminalID,
+		Risk:             authReq.Risk,
 	}
-	cardLastFour := cardModel.LastFour
 
-	transaction, remainingBalance, err := h.service.ProcessPayment(
-		c.Request.Context(),
-		req.CompanyID,
-		req.CardID,
-		req.Amount,
-		req.MerchantCategory,
-	)
+	transaction, remainingBalance, err := h.service.ProcessPayment(c.Request.Context(), &req)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -98,20 +85,7 @@ func (h *Handler) Pay(c *gin.Context) {
 	}
 
 	resp := response.TransactionResponse{
-		Transaction: response.Transaction{
-			ID:               transaction.ID,
-			CardID:           transaction.CardID,
-			CompanyID:        transaction.CompanyID,
-			TransactionType:  transaction.TransactionType,
-			Amount:           transaction.Amount,
-			MerchantName:     transaction.MerchantName,
-			MerchantCategory: transaction.MerchantCategory,
-			Description:      transaction.Description,
-			Status:           transaction.Status,
-			ProcessedAt:      transaction.ProcessedAt,
-			CreatedAt:        transaction.CreatedAt,
-			UpdatedAt:        transaction.UpdatedAt,
-		},
+		Transaction:      toResponse(transaction),
 		RemainingBalance: remainingBalance,
 		CardLastFour:     cardLastFour,
 	}
@@ -177,20 +151,7 @@ func (h *Handler) GetTransactionHistory(c *gin.Context) {
 
 	respTransactions := make([]response.Transaction, len(transactions))
 	for i, tx := range transactions {
-		respTransactions[i] = response.Transaction{
-			ID:               tx.ID,
-			CardID:           tx.CardID,
-			CompanyID:        tx.CompanyID,
-			TransactionType:  tx.TransactionType,
-			Amount:           tx.Amount,
-			MerchantName:     tx.MerchantName,
-			MerchantCategory: tx.MerchantCategory,
-			Description:      tx.Description,
-			Status:           tx.Status,
-			ProcessedAt:      tx.ProcessedAt,
-			CreatedAt:        tx.CreatedAt,
-			UpdatedAt:        tx.UpdatedAt,
-		}
+		respTransactions[i] = toResponse(tx)
 	}
 
 	resp := response.TransactionListResponse{
@@ -231,7 +192,13 @@ func (h *Handler) GetTransaction(c *gin.Context) {
 		return
 	}
 
-	resp := response.Transaction{
+	resp := toResponse(transaction)
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func toResponse(transaction *models.Transaction) response.Transaction {
+	return response.Transaction{
 		ID:               transaction.ID,
 		CardID:           transaction.CardID,
 		CompanyID:        transaction.CompanyID,
@@ -239,12 +206,19 @@ func (h *Handler) GetTransaction(c *gin.Context) {
 		Amount:           transaction.Amount,
 		MerchantName:     transaction.MerchantName,
 		MerchantCategory: transaction.MerchantCategory,
+		MCC:              transaction.MCC,
+		MerchantID:       transaction.MerchantID,
 		Description:      transaction.Description,
 		Status:           transaction.Status,
+		Channel:          transaction.Channel,
+		TerminalID:       transaction.TerminalID,
 		ProcessedAt:      transaction.ProcessedAt,
 		CreatedAt:        transaction.CreatedAt,
 		UpdatedAt:        transaction.UpdatedAt,
+		RiskScore:        transaction.RiskScore,
+		RiskAction:       transaction.RiskAction,
+		RiskReasons:      transaction.RiskReasons,
+		ReviewStatus:     transaction.ReviewStatus,
+		ReviewedAt:       transaction.ReviewedAt,
 	}
-
-	c.JSON(http.StatusOK, resp)
 }
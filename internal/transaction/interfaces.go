@@ -1,7 +1,9 @@
 package transaction
 
 import (
+	"ccards/internal/api/request"
 	"ccards/pkg/models"
+	"ccards/pkg/outbox"
 	"context"
 	"database/sql"
 	"github.com/google/uuid"
@@ -20,11 +22,13 @@ type Repository interface {
 	UpdateCardBalance(ctx context.Context, tx *sql.Tx, cardID uuid.UUID, amount float64) error
 	GetCardBalance(ctx context.Context, cardID uuid.UUID) (float64, error)
 
+	RecordEvents(ctx context.Context, tx *sql.Tx, events ...*outbox.Event) error
+
 	BeginTx(ctx context.Context) (*sql.Tx, error)
 }
 
 type Service interface {
-	ProcessPayment(ctx context.Context, companyID, cardID uuid.UUID, amount float64, merchantCategory string) (*models.Transaction, float64, error)
+	ProcessPayment(ctx context.Context, req *request.Transaction) (*models.Transaction, float64, error)
 	GetTransaction(ctx context.Context, id uuid.UUID) (*models.Transaction, error)
 	GetCardTransactions(ctx context.Context, cardID uuid.UUID, limit, offset int) ([]*models.Transaction, error)
 	GetCompanyTransactions(ctx context.Context, companyID uuid.UUID, limit, offset int) ([]*models.Transaction, error)
@@ -3,10 +3,13 @@ package transaction
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"ccards/pkg/ledger"
 	"ccards/pkg/models"
+	"ccards/pkg/outbox"
 	"github.com/google/uuid"
 )
 
@@ -22,12 +25,25 @@ func (r *repository) CreateTransaction(ctx context.Context, tx *sql.Tx, transact
 	now := time.Now()
 	transaction.CreatedAt = now
 	transaction.UpdatedAt = now
+	if transaction.Channel == "" {
+		transaction.Channel = models.TransactionChannelOnline
+	}
+
+	var riskReasons []byte
+	if transaction.RiskReasons != nil {
+		var err error
+		if riskReasons, err = json.Marshal(transaction.RiskReasons); err != nil {
+			return fmt.Errorf("failed to encode risk reasons: %w", err)
+		}
+	}
 
 	query := `
         INSERT INTO transactions (
             id, card_id, company_id, transaction_type, amount,
-            merchant_name, merchant_category, description, status, created_at, updated_at
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+            merchant_name, merchant_category, mcc, merchant_id, description, status,
+            channel, terminal_id, created_at, updated_at,
+            risk_score, risk_action, risk_reasons, review_status
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
         RETURNING created_at, updated_at`
 
 	err := tx.QueryRowContext(
@@ -40,10 +56,18 @@ func (r *repository) CreateTransaction(ctx context.Context, tx *sql.Tx, transact
 		transaction.Amount,
 		transaction.MerchantName,
 		transaction.MerchantCategory,
+		transaction.MCC,
+		transaction.MerchantID,
 		transaction.Description,
 		transaction.Status,
+		transaction.Channel,
+		transaction.TerminalID,
 		transaction.CreatedAt,
 		transaction.UpdatedAt,
+		transaction.RiskScore,
+		transaction.RiskAction,
+		riskReasons,
+		transaction.ReviewStatus,
 	).Scan(&transaction.CreatedAt, &transaction.UpdatedAt)
 
 	if err != nil {
@@ -56,26 +80,11 @@ func (r *repository) CreateTransaction(ctx context.Context, tx *sql.Tx, transact
 func (r *repository) GetTransactionByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error) {
 	var transaction models.Transaction
 	query := `
-        SELECT id, card_id, company_id, transaction_type, amount,
-               merchant_name, merchant_category, description, status,
-               processed_at, created_at, updated_at
+        SELECT ` + models.TransactionColumns + `
         FROM transactions
         WHERE id = $1`
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&transaction.ID,
-		&transaction.CardID,
-		&transaction.CompanyID,
-		&transaction.TransactionType,
-		&transaction.Amount,
-		&transaction.MerchantName,
-		&transaction.MerchantCategory,
-		&transaction.Description,
-		&transaction.Status,
-		&transaction.ProcessedAt,
-		&transaction.CreatedAt,
-		&transaction.UpdatedAt,
-	)
+	err := models.ScanTransaction(r.db.QueryRowContext(ctx, query, id), &transaction)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -89,9 +98,7 @@ func (r *repository) GetTransactionByID(ctx context.Context, id uuid.UUID) (*mod
 
 func (r *repository) GetTransactionsByCardID(ctx context.Context, cardID uuid.UUID, limit, offset int) ([]*models.Transaction, error) {
 	query := `
-        SELECT id, card_id, company_id, transaction_type, amount,
-               merchant_name, merchant_category, description, status,
-               processed_at, created_at, updated_at
+        SELECT ` + models.TransactionColumns + `
         FROM transactions
         WHERE card_id = $1
         ORDER BY created_at DESC
@@ -106,20 +113,7 @@ func (r *repository) GetTransactionsByCardID(ctx context.Context, cardID uuid.UU
 	var transactions []*models.Transaction
 	for rows.Next() {
 		var transaction models.Transaction
-		err := rows.Scan(
-			&transaction.ID,
-			&transaction.CardID,
-			&transaction.CompanyID,
-			&transaction.TransactionType,
-			&transaction.Amount,
-			&transaction.MerchantName,
-			&transaction.MerchantCategory,
-			&transaction.Description,
-			&transaction.Status,
-			&transaction.ProcessedAt,
-			&transaction.CreatedAt,
-			&transaction.UpdatedAt,
-		)
+		err := models.ScanTransaction(rows, &transaction)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
@@ -131,9 +125,7 @@ func (r *repository) GetTransactionsByCardID(ctx context.Context, cardID uuid.UU
 
 func (r *repository) GetTransactionsByCompanyID(ctx context.Context, companyID uuid.UUID, limit, offset int) ([]*models.Transaction, error) {
 	query := `
-        SELECT id, card_id, company_id, transaction_type, amount,
-               merchant_name, merchant_category, description, status,
-               processed_at, created_at, updated_at
+        SELECT ` + models.TransactionColumns + `
         FROM transactions
         WHERE company_id = $1
         ORDER BY created_at DESC
@@ -148,20 +140,7 @@ func (r *repository) GetTransactionsByCompanyID(ctx context.Context, companyID u
 	var transactions []*models.Transaction
 	for rows.Next() {
 		var transaction models.Transaction
-		err := rows.Scan(
-			&transaction.ID,
-			&transaction.CardID,
-			&transaction.CompanyID,
-			&transaction.TransactionType,
-			&transaction.Amount,
-			&transaction.MerchantName,
-			&transaction.MerchantCategory,
-			&transaction.Description,
-			&transaction.Status,
-			&transaction.ProcessedAt,
-			&transaction.CreatedAt,
-			&transaction.UpdatedAt,
-		)
+		err := models.ScanTransaction(rows, &transaction)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction: %w", err)
 		}
@@ -237,18 +216,13 @@ func (r *repository) GetTotalSpentThisMonth(ctx context.Context, cardID uuid.UUI
 }
 
 func (r *repository) UpdateCardBalance(ctx context.Context, tx *sql.Tx, cardID uuid.UUID, amount float64) error {
-	var currentBalance float64
-	lockQuery := `SELECT balance FROM cards WHERE id = $1 FOR UPDATE`
-
-	err := tx.QueryRowContext(ctx, lockQuery, cardID).Scan(&currentBalance)
+	// Amounts held for gateway authorizations cannot be spent.
+	available, err := ledger.AvailableBalance(ctx, tx, cardID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("card not found")
-		}
-		return fmt.Errorf("failed to lock card for update: %w", err)
+		return err
 	}
 
-	if currentBalance < amount {
+	if available < amount {
 		return fmt.Errorf("insufficient balance")
 	}
 
@@ -276,6 +250,11 @@ func (r *repository) GetCardBalance(ctx context.Context, cardID uuid.UUID) (floa
 	return balance, nil
 }
 
+// RecordEvents records events in the outbox with the payment's transaction.
+func (r *repository) RecordEvents(ctx context.Context, tx *sql.Tx, events ...*outbox.Event) error {
+	return outbox.Enqueue(ctx, tx, events...)
+}
+
 func (r *repository) BeginTx(ctx context.Context) (*sql.Tx, error) {
 	return r.db.BeginTx(ctx, nil)
 }
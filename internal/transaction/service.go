@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	"ccards/internal/api/request"
 	"ccards/pkg/models"
+	"ccards/pkg/outbox"
 	"github.com/google/uuid"
 )
 
@@ -17,7 +19,25 @@ func NewService(repo Repository) Service {
 	return &service{repo: repo}
 }
 
-func (s *service) ProcessPayment(ctx context.Context, companyID, cardID uuid.UUID, amount float64, merchantCategory string) (*models.Transaction, float64, error) {
+func (s *service) ProcessPayment(ctx context.Context, req *request.Transaction) (*models.Transaction, float64, error) {
+	channel := req.Channel
+	if channel == "" {
+		channel = models.TransactionChannelOnline
+	}
+
+	var terminalID *string
+	if req.TerminalID != "" {
+		terminalID = &req.TerminalID
+	}
+
+	var mcc, merchantName *string
+	if req.MCC != "" {
+		mcc = &req.MCC
+	}
+	if req.MerchantName != "" {
+		merchantName = &req.MerchantName
+	}
+
 	tx, err := s.repo.BeginTx(ctx)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
@@ -26,13 +46,31 @@ func (s *service) ProcessPayment(ctx context.Context, companyID, cardID uuid.UUI
 
 	transaction := &models.Transaction{
 		ID:               uuid.New(),
-		CardID:           cardID,
-		CompanyID:        companyID,
+		CardID:           req.CardID,
+		CompanyID:        req.CompanyID,
 		TransactionType:  models.TransactionTypePurchase,
-		Amount:           amount,
-		MerchantCategory: &merchantCategory,
+		Amount:           req.Amount,
+		MerchantName:     merchantName,
+		MerchantCategory: &req.MerchantCategory,
+		MCC:              mcc,
+		MerchantID:       req.MerchantID,
 		Description:      "Card purchase",
 		Status:           models.TransactionStatusPending,
+		Channel:          channel,
+		TerminalID:       terminalID,
+	}
+
+	// Payments the risk engine let through are stored with their score;
+	// those it flagged wait in the review queue.
+	if req.Risk != nil {
+		score, action := req.Risk.Score, req.Risk.Action
+		transaction.RiskScore = &score
+		transaction.RiskAction = &action
+		transaction.RiskReasons = req.Risk.Reasons
+		if action == models.RiskActionReview {
+			reviewStatus := models.ReviewStatusPending
+			transaction.ReviewStatus = &reviewStatus
+		}
 	}
 
 	// Insert transaction
@@ -41,7 +79,7 @@ func (s *service) ProcessPayment(ctx context.Context, companyID, cardID uuid.UUI
 	}
 
 	// Update card balance
-	if err := s.repo.UpdateCardBalance(ctx, tx, cardID, amount); err != nil {
+	if err := s.repo.UpdateCardBalance(ctx, tx, req.CardID, req.Amount); err != nil {
 		return nil, 0, fmt.Errorf("failed to update card balance: %w", err)
 	}
 
@@ -50,22 +88,33 @@ func (s *service) ProcessPayment(ctx context.Context, companyID, cardID uuid.UUI
 		return nil, 0, fmt.Errorf("failed to update transaction status: %w", err)
 	}
 
+	// Update transaction object with completed status and processed time
+	transaction.Status = models.TransactionStatusCompleted
+	now := time.Now()
+	transaction.ProcessedAt = &now
+
+	// The event is committed with the payment, so a webhook is sent for every
+	// completed payment and never for a rolled back one.
+	err = s.repo.RecordEvents(ctx, tx, &outbox.Event{
+		CompanyID: transaction.CompanyID,
+		Type:      models.EventPaymentCompleted,
+		Data:      transaction,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to record payment event: %w", err)
+	}
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return nil, 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	// Get updated balance
-	balance, err := s.repo.GetCardBalance(ctx, cardID)
+	balance, err := s.repo.GetCardBalance(ctx, req.CardID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get updated balance: %w", err)
 	}
 
-	// Update transaction object with completed status and processed time
-	transaction.Status = models.TransactionStatusCompleted
-	now := time.Now()
-	transaction.ProcessedAt = &now
-
 	return transaction, balance, nil
 }
 
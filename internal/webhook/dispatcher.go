@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ccards/pkg/models"
+)
+
+// Headers sent with every delivery. The signature lets endpoints check that
+// a delivery comes from us and was not altered; see Sign.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// SubscriberName is the name webhooks subscribe to outbox events under.
+const SubscriberName = "webhooks"
+
+const (
+	// dispatchBatchSize bounds the deliveries sent per batch.
+	dispatchBatchSize = 100
+	// dispatchWorkers is the number of deliveries sent concurrently.
+	dispatchWorkers = 10
+	// maxResponseBytes bounds how much of an endpoint's response is read.
+	maxResponseBytes = 64 << 10
+)
+
+// Sign returns the signature of a delivery body sent at timestamp (Unix
+// seconds): "v1=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>",
+// keyed with the endpoint secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// StartDispatcher dispatches webhooks every interval until ctx is cancelled.
+func (s *service) StartDispatcher(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				delivered, err := s.Dispatch(ctx)
+				if err != nil {
+					log.Printf("Warning: webhook dispatch failed: %v", err)
+				}
+				if delivered > 0 {
+					log.Printf("Delivered %d webhooks", delivered)
+				}
+			}
+		}
+	}()
+}
+
+// HandleEvent queues an outbox event for the endpoints subscribed to it. It
+// is the webhooks' outbox subscriber; the deliveries are sent by Dispatch.
+func (s *service) HandleEvent(ctx context.Context, event *models.Event) error {
+	_, err := s.repo.CreateDeliveries(ctx, event)
+	return err
+}
+
+// Dispatch sends one batch of due deliveries. It returns the number of
+// deliveries that succeeded.
+func (s *service) Dispatch(ctx context.Context) (int, error) {
+	// Claimed deliveries are held long enough for the whole batch to be sent
+	// before another dispatcher may pick them up again.
+	lease := s.config.Timeout * time.Duration(dispatchBatchSize/dispatchWorkers+1)
+	jobs, err := s.repo.ClaimDeliveries(ctx, dispatchBatchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		delivered int
+	)
+	sem := make(chan struct{}, dispatchWorkers)
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job *Job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ok, err := s.deliver(ctx, job)
+			if err != nil {
+				log.Printf("Warning: failed to record webhook delivery %s: %v", job.Delivery.ID, err)
+			}
+			if ok {
+				mu.Lock()
+				delivered++
+				mu.Unlock()
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	return delivered, nil
+}
+
+// deliver sends one delivery and records the outcome. A failed attempt is
+// retried with exponential backoff until MaxAttempts attempts were made; the
+// delivery then becomes a dead letter.
+func (s *service) deliver(ctx context.Context, job *Job) (bool, error) {
+	body, err := json.Marshal(job.Event)
+	if err != nil {
+		return false, s.fail(ctx, job, nil, fmt.Sprintf("failed to encode event: %v", err))
+	}
+
+	secret, err := s.vault.OpenSecret(job.Secret, secretPurpose)
+	if err != nil {
+		return false, s.fail(ctx, job, nil, fmt.Sprintf("failed to decrypt signing secret: %v", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, s.fail(ctx, job, nil, fmt.Sprintf("invalid endpoint URL: %v", err))
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ccards-webhooks/1.0")
+	req.Header.Set(EventHeader, job.Event.Type)
+	req.Header.Set(DeliveryHeader, job.Delivery.ID.String())
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, s.fail(ctx, job, nil, err.Error())
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		statusCode := resp.StatusCode
+		return false, s.fail(ctx, job, &statusCode, fmt.Sprintf("endpoint responded with status %d", statusCode))
+	}
+
+	return true, s.repo.MarkDelivered(ctx, job.Delivery.ID, resp.StatusCode)
+}
+
+func (s *service) fail(ctx context.Context, job *Job, statusCode *int, reason string) error {
+	attempts := job.Delivery.Attempts + 1
+	if attempts >= s.config.MaxAttempts {
+		return s.repo.MarkFailed(ctx, job.Delivery.ID, statusCode, reason, nil)
+	}
+
+	nextAttemptAt := time.Now().Add(s.backoff(attempts))
+	return s.repo.MarkFailed(ctx, job.Delivery.ID, statusCode, reason, &nextAttemptAt)
+}
+
+// backoff returns the wait after the given number of failed attempts:
+// RetryBackoff, doubling with every attempt, capped at MaxBackoff.
+func (s *service) backoff(attempts int) time.Duration {
+	wait := s.config.RetryBackoff
+	for i := 1; i < attempts && wait < s.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > s.config.MaxBackoff {
+		wait = s.config.MaxBackoff
+	}
+	return wait
+}
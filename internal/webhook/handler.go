@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/errors"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// CreateEndpoint registers a webhook endpoint. The response is the only one
+// that includes the endpoint's signing secret.
+func (h *Handler) CreateEndpoint(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req request.WebhookEndpoint
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endpoint, err := h.service.CreateEndpoint(c.Request.Context(), companyID, &req)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to create webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusCreated, endpoint)
+}
+
+func (h *Handler) GetEndpoints(c *gin.Context) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	endpoints, err := h.service.ListEndpoints(c.Request.Context(), companyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook endpoints"})
+		return
+	}
+	if endpoints == nil {
+		endpoints = []*models.WebhookEndpoint{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoints": endpoints,
+		"count":     len(endpoints),
+	})
+}
+
+func (h *Handler) GetEndpoint(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.service.GetEndpoint(c.Request.Context(), companyID, endpointID)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to retrieve webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+func (h *Handler) UpdateEndpoint(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	var req request.WebhookEndpoint
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endpoint, err := h.service.UpdateEndpoint(c.Request.Context(), companyID, endpointID, &req)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to update webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+func (h *Handler) RotateSecret(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.service.RotateSecret(c.Request.Context(), companyID, endpointID)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to rotate webhook secret")
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoint)
+}
+
+func (h *Handler) DeleteEndpoint(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteEndpoint(c.Request.Context(), companyID, endpointID); err != nil {
+		webhookErrorResponse(c, err, "Failed to delete webhook endpoint")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook endpoint deleted successfully"})
+}
+
+// GetDeliveries lists the endpoint's deliveries, newest first. status=dead
+// lists the dead letter queue.
+func (h *Handler) GetDeliveries(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", models.WebhookDeliveryStatusPending, models.WebhookDeliveryStatusDelivered, models.WebhookDeliveryStatusDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery status"})
+		return
+	}
+
+	page := utils.GetIntParam(c, "page", 1)
+	pageSize := utils.GetIntParam(c, "page_size", 20)
+
+	deliveries, err := h.service.ListDeliveries(c.Request.Context(), companyID, endpointID, status, pageSize, (page-1)*pageSize)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to retrieve webhook deliveries")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+		"page":       page,
+		"page_size":  pageSize,
+	})
+}
+
+func (h *Handler) ReplayDelivery(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID format"})
+		return
+	}
+
+	delivery, err := h.service.ReplayDelivery(c.Request.Context(), companyID, endpointID, deliveryID)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to replay webhook delivery")
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ReplayEvents queues the endpoint's events since the given time again. The
+// body is optional; without since the dead letters are retried.
+func (h *Handler) ReplayEvents(c *gin.Context) {
+	companyID, endpointID, ok := endpointParams(c)
+	if !ok {
+		return
+	}
+
+	var req request.ReplayWebhookEvents
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replayed, err := h.service.ReplayEvents(c.Request.Context(), companyID, endpointID, req.Since)
+	if err != nil {
+		webhookErrorResponse(c, err, "Failed to replay webhook events")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+func endpointParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	companyID, err := middleware.GetCompanyIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook endpoint ID format"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return companyID, endpointID, true
+}
+
+func webhookErrorResponse(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, errors.ErrWebhookURL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/models"
+)
+
+// Job is a delivery claimed by the dispatcher, with the endpoint and event it
+// sends. Secret is the endpoint's signing secret as stored, sealed with the
+// vault key.
+type Job struct {
+	Delivery *models.WebhookDelivery
+	URL      string
+	Secret   string
+	Event    *models.Event
+}
+
+type Repository interface {
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	GetEndpoints(ctx context.Context, companyID uuid.UUID) ([]*models.WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error)
+	UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error)
+	UpdateEndpointSecret(ctx context.Context, companyID, endpointID uuid.UUID, secret string) (*models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (bool, error)
+	EncryptEndpointSecrets(ctx context.Context, seal func(secret string) (string, error)) (int, error)
+
+	GetDeliveries(ctx context.Context, companyID, endpointID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, companyID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	ReplayDeadDeliveries(ctx context.Context, companyID, endpointID uuid.UUID) (int, error)
+	ReplayEvents(ctx context.Context, companyID, endpointID uuid.UUID, since time.Time) (int, error)
+
+	// Dispatcher operations
+	CreateDeliveries(ctx context.Context, event *models.Event) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Job, error)
+	MarkDelivered(ctx context.Context, deliveryID uuid.UUID, statusCode int) error
+	MarkFailed(ctx context.Context, deliveryID uuid.UUID, statusCode *int, reason string, nextAttemptAt *time.Time) error
+}
+
+type Service interface {
+	CreateEndpoint(ctx context.Context, companyID uuid.UUID, req *request.WebhookEndpoint) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, companyID uuid.UUID) ([]*models.WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error)
+	UpdateEndpoint(ctx context.Context, companyID, endpointID uuid.UUID, req *request.WebhookEndpoint) (*models.WebhookEndpoint, error)
+	RotateSecret(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) error
+	EncryptStoredSecrets(ctx context.Context) (int, error)
+
+	ListDeliveries(ctx context.Context, companyID, endpointID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, companyID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+	ReplayEvents(ctx context.Context, companyID, endpointID uuid.UUID, since *time.Time) (int, error)
+
+	HandleEvent(ctx context.Context, event *models.Event) error
+	Dispatch(ctx context.Context) (int, error)
+	StartDispatcher(ctx context.Context, interval time.Duration)
+}
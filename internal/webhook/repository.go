@@ -0,0 +1,427 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"ccards/pkg/models"
+)
+
+const endpointColumns = `id, company_id, url, secret, event_types, description, is_active, created_at, updated_at`
+
+// deliveryColumns selects deliveries joined with their event as ev.
+const deliveryColumns = `d.id, d.endpoint_id, d.event_id, ev.event_type, d.status, d.attempts, d.next_attempt_at,
+		d.last_status_code, d.last_error, d.delivered_at, d.created_at, d.updated_at`
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+func scanEndpoint(row models.RowScanner, endpoint *models.WebhookEndpoint) error {
+	return row.Scan(
+		&endpoint.ID, &endpoint.CompanyID, &endpoint.URL, &endpoint.Secret, pq.Array(&endpoint.EventTypes),
+		&endpoint.Description, &endpoint.IsActive, &endpoint.CreatedAt, &endpoint.UpdatedAt,
+	)
+}
+
+func scanDelivery(row models.RowScanner, delivery *models.WebhookDelivery, extra ...interface{}) error {
+	return row.Scan(append([]interface{}{
+		&delivery.ID, &delivery.EndpointID, &delivery.EventID, &delivery.EventType, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError,
+		&delivery.DeliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt,
+	}, extra...)...)
+}
+
+func (r *repository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	if endpoint.EventTypes == nil {
+		endpoint.EventTypes = []string{}
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_endpoints (id, company_id, url, secret, event_types, description, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at, updated_at`,
+		endpoint.ID, endpoint.CompanyID, endpoint.URL, endpoint.Secret, pq.Array(endpoint.EventTypes),
+		endpoint.Description, endpoint.IsActive,
+	).Scan(&endpoint.CreatedAt, &endpoint.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (r *repository) GetEndpoints(ctx context.Context, companyID uuid.UUID) ([]*models.WebhookEndpoint, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+endpointColumns+`
+		FROM webhook_endpoints
+		WHERE company_id = $1
+		ORDER BY created_at ASC`,
+		companyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []*models.WebhookEndpoint
+	for rows.Next() {
+		endpoint := &models.WebhookEndpoint{}
+		if err := scanEndpoint(rows, endpoint); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook endpoint: %w", err)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints, rows.Err()
+}
+
+func (r *repository) GetEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoint := &models.WebhookEndpoint{}
+	err := scanEndpoint(r.db.QueryRowContext(ctx, `
+		SELECT `+endpointColumns+`
+		FROM webhook_endpoints
+		WHERE id = $1 AND company_id = $2`,
+		endpointID, companyID,
+	), endpoint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook endpoint: %w", err)
+	}
+
+	return endpoint, nil
+}
+
+func (r *repository) UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	if endpoint.EventTypes == nil {
+		endpoint.EventTypes = []string{}
+	}
+
+	updated := &models.WebhookEndpoint{}
+	err := scanEndpoint(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_endpoints
+		SET url = $3, event_types = $4, description = $5, is_active = $6
+		WHERE id = $1 AND company_id = $2
+		RETURNING `+endpointColumns,
+		endpoint.ID, endpoint.CompanyID, endpoint.URL, pq.Array(endpoint.EventTypes), endpoint.Description, endpoint.IsActive,
+	), updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook endpoint: %w", err)
+	}
+
+	return updated, nil
+}
+
+func (r *repository) UpdateEndpointSecret(ctx context.Context, companyID, endpointID uuid.UUID, secret string) (*models.WebhookEndpoint, error) {
+	endpoint := &models.WebhookEndpoint{}
+	err := scanEndpoint(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_endpoints
+		SET secret = $3
+		WHERE id = $1 AND company_id = $2
+		RETURNING `+endpointColumns,
+		endpointID, companyID, secret,
+	), endpoint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate webhook secret: %w", err)
+	}
+
+	return endpoint, nil
+}
+
+// EncryptEndpointSecrets seals signing secrets still stored in plaintext and
+// returns the number of endpoints updated.
+func (r *repository) EncryptEndpointSecrets(ctx context.Context, seal func(secret string) (string, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, secret
+		FROM webhook_endpoints
+		WHERE secret LIKE 'whsec\_%'
+		FOR UPDATE`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch webhook secrets: %w", err)
+	}
+
+	secrets := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan webhook secret: %w", err)
+		}
+		secrets[id] = secret
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	for id, secret := range secrets {
+		sealed, err := seal(secret)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE webhook_endpoints SET secret = $2 WHERE id = $1`, id, sealed)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store encrypted webhook secret: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(secrets), nil
+}
+
+func (r *repository) DeleteEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1 AND company_id = $2`, endpointID, companyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return deleted > 0, nil
+}
+
+func (r *repository) GetDeliveries(ctx context.Context, companyID, endpointID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d
+		JOIN outbox_events ev ON ev.id = d.event_id
+		JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.endpoint_id = $1 AND e.company_id = $2`
+	args := []interface{}{endpointID, companyID}
+	if status != "" {
+		query += ` AND d.status = $5`
+		args = append(args, limit, offset, status)
+	} else {
+		args = append(args, limit, offset)
+	}
+	query += `
+		ORDER BY d.created_at DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery := &models.WebhookDelivery{}
+		if err := scanDelivery(rows, delivery); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ReplayDelivery queues a delivery to be sent again as soon as possible, with
+// a fresh attempt count.
+func (r *repository) ReplayDelivery(ctx context.Context, companyID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	err := scanDelivery(r.db.QueryRowContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET status = $4, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = NULL
+		FROM webhook_endpoints e, outbox_events ev
+		WHERE d.id = $1 AND d.endpoint_id = $2
+		  AND e.id = d.endpoint_id AND e.company_id = $3
+		  AND ev.id = d.event_id
+		RETURNING `+deliveryColumns,
+		deliveryID, endpointID, companyID, models.WebhookDeliveryStatusPending,
+	), delivery)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+	}
+
+	return delivery, nil
+}
+
+// ReplayDeadDeliveries queues every dead letter of the endpoint again.
+func (r *repository) ReplayDeadDeliveries(ctx context.Context, companyID, endpointID uuid.UUID) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries d
+		SET status = $3, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = NULL
+		FROM webhook_endpoints e
+		WHERE d.endpoint_id = $1 AND d.status = $4
+		  AND e.id = d.endpoint_id AND e.company_id = $2`,
+		endpointID, companyID, models.WebhookDeliveryStatusPending, models.WebhookDeliveryStatusDead,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay dead webhook deliveries: %w", err)
+	}
+
+	replayed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(replayed), nil
+}
+
+// ReplayEvents queues the company's events created since the given time for
+// the endpoint, including events it was not subscribed to when they happened
+// as long as it is subscribed now. Deliveries that already exist are reset.
+func (r *repository) ReplayEvents(ctx context.Context, companyID, endpointID uuid.UUID, since time.Time) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id)
+		SELECT e.id, ev.id
+		FROM webhook_endpoints e
+		JOIN outbox_events ev ON ev.company_id = e.company_id
+		WHERE e.id = $1 AND e.company_id = $2
+		  AND ev.created_at >= $3
+		  AND (cardinality(e.event_types) = 0 OR ev.event_type = ANY(e.event_types))
+		ON CONFLICT (endpoint_id, event_id) DO UPDATE
+		SET status = $4, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = NULL`,
+		endpointID, companyID, since, models.WebhookDeliveryStatusPending,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay webhook events: %w", err)
+	}
+
+	replayed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(replayed), nil
+}
+
+// CreateDeliveries creates a delivery of the event for every active endpoint
+// of the company subscribed to it. An endpoint that already has a delivery of
+// the event keeps it, so handling an event twice is harmless. It returns the
+// number of deliveries created.
+func (r *repository) CreateDeliveries(ctx context.Context, event *models.Event) (int, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id)
+		SELECT id, $2
+		FROM webhook_endpoints
+		WHERE company_id = $1 AND is_active = true
+		  AND (cardinality(event_types) = 0 OR $3 = ANY(event_types))
+		ON CONFLICT (endpoint_id, event_id) DO NOTHING`,
+		event.CompanyID, event.ID, event.Type,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook deliveries: %w", err)
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(created), nil
+}
+
+// ClaimDeliveries claims due deliveries to active endpoints. Claimed
+// deliveries are pushed back by lease, so another dispatcher only picks one up
+// again when this one failed to record the outcome in time.
+func (r *repository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Job, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.status = $1 AND d.next_attempt_at <= CURRENT_TIMESTAMP AND e.is_active = true
+			ORDER BY d.next_attempt_at ASC
+			LIMIT $2
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = $3
+		FROM due, webhook_endpoints e, outbox_events ev
+		WHERE d.id = due.id AND e.id = d.endpoint_id AND ev.id = d.event_id
+		RETURNING `+deliveryColumns+`, e.url, e.secret, ev.company_id, ev.payload, ev.created_at`,
+		models.WebhookDeliveryStatusPending, limit, time.Now().Add(lease),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job := &Job{Delivery: &models.WebhookDelivery{}, Event: &models.Event{}}
+		err := scanDelivery(rows, job.Delivery, &job.URL, &job.Secret, &job.Event.CompanyID, &job.Event.Data, &job.Event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		job.Event.ID = job.Delivery.EventID
+		job.Event.Type = job.Delivery.EventType
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (r *repository) MarkDelivered(ctx context.Context, deliveryID uuid.UUID, statusCode int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		deliveryID, models.WebhookDeliveryStatusDelivered, statusCode,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook delivered: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at
+// nextAttemptAt, or becomes a dead letter when nextAttemptAt is nil.
+func (r *repository) MarkFailed(ctx context.Context, deliveryID uuid.UUID, statusCode *int, reason string, nextAttemptAt *time.Time) error {
+	status := models.WebhookDeliveryStatusPending
+	if nextAttemptAt == nil {
+		status = models.WebhookDeliveryStatusDead
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4,
+		    next_attempt_at = COALESCE($5, next_attempt_at)
+		WHERE id = $1`,
+		deliveryID, status, statusCode, reason, nextAttemptAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark webhook failed: %w", err)
+	}
+
+	return nil
+}
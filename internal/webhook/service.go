@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/config"
+	"ccards/pkg/endpoint"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+)
+
+// secretPrefix marks webhook signing secrets, so they are easy to recognise
+// in configuration files.
+const secretPrefix = "whsec_"
+
+// secretPurpose binds sealed signing secrets to webhooks in the vault.
+const secretPurpose = "webhook-secret"
+
+type service struct {
+	repo   Repository
+	vault  *vault.Vault
+	config config.WebhookConfig
+	client *http.Client
+}
+
+func NewService(repo Repository, secretVault *vault.Vault, webhookConfig config.WebhookConfig) Service {
+	return &service{
+		repo:   repo,
+		vault:  secretVault,
+		config: webhookConfig,
+		client: endpoint.NewClient(webhookConfig.Timeout, webhookConfig.AllowPrivateTargets),
+	}
+}
+
+func (s *service) CreateEndpoint(ctx context.Context, companyID uuid.UUID, req *request.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	if err := s.checkURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	secret, sealed, err := s.newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		ID:          uuid.New(),
+		CompanyID:   companyID,
+		URL:         req.URL,
+		Secret:      sealed,
+		EventTypes:  req.EventTypes,
+		Description: optionalString(req.Description),
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	}
+	if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	endpoint.Secret = secret
+	return endpoint, nil
+}
+
+func (s *service) ListEndpoints(ctx context.Context, companyID uuid.UUID) ([]*models.WebhookEndpoint, error) {
+	endpoints, err := s.repo.GetEndpoints(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+	return endpoints, nil
+}
+
+func (s *service) GetEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.repo.GetEndpoint(ctx, companyID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	endpoint.Secret = ""
+	return endpoint, nil
+}
+
+func (s *service) UpdateEndpoint(ctx context.Context, companyID, endpointID uuid.UUID, req *request.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	if err := s.checkURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.repo.UpdateEndpoint(ctx, &models.WebhookEndpoint{
+		ID:          endpointID,
+		CompanyID:   companyID,
+		URL:         req.URL,
+		EventTypes:  req.EventTypes,
+		Description: optionalString(req.Description),
+		IsActive:    req.IsActive == nil || *req.IsActive,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	endpoint.Secret = ""
+	return endpoint, nil
+}
+
+// RotateSecret replaces the endpoint's signing secret. Deliveries sent from
+// now on are signed with the new secret, which is only returned here.
+func (s *service) RotateSecret(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error) {
+	secret, sealed, err := s.newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := s.repo.UpdateEndpointSecret(ctx, companyID, endpointID, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	endpoint.Secret = secret
+	return endpoint, nil
+}
+
+func (s *service) DeleteEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) error {
+	deleted, err := s.repo.DeleteEndpoint(ctx, companyID, endpointID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.ErrNotFound
+	}
+
+	return nil
+}
+
+// EncryptStoredSecrets seals signing secrets stored before they were
+// encrypted. It returns the number of endpoints updated.
+func (s *service) EncryptStoredSecrets(ctx context.Context) (int, error) {
+	return s.repo.EncryptEndpointSecrets(ctx, func(secret string) (string, error) {
+		return s.vault.SealSecret(secret, secretPurpose)
+	})
+}
+
+func (s *service) ListDeliveries(ctx context.Context, companyID, endpointID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	if _, err := s.GetEndpoint(ctx, companyID, endpointID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return s.repo.GetDeliveries(ctx, companyID, endpointID, status, limit, offset)
+}
+
+func (s *service) ReplayDelivery(ctx context.Context, companyID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	delivery, err := s.repo.ReplayDelivery(ctx, companyID, endpointID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, errors.ErrNotFound
+	}
+
+	return delivery, nil
+}
+
+// ReplayEvents queues the endpoint's events created since the given time
+// again, or its dead letters when since is nil. It returns the number of
+// deliveries queued.
+func (s *service) ReplayEvents(ctx context.Context, companyID, endpointID uuid.UUID, since *time.Time) (int, error) {
+	if _, err := s.GetEndpoint(ctx, companyID, endpointID); err != nil {
+		return 0, err
+	}
+
+	if since == nil {
+		return s.repo.ReplayDeadDeliveries(ctx, companyID, endpointID)
+	}
+	return s.repo.ReplayEvents(ctx, companyID, endpointID, *since)
+}
+
+// checkURL refuses endpoint URLs that are not https or do not point to a
+// public address, unless private targets are allowed.
+func (s *service) checkURL(ctx context.Context, url string) error {
+	if s.config.AllowPrivateTargets {
+		return nil
+	}
+	return endpoint.CheckURL(ctx, url)
+}
+
+// newSecret returns a new signing secret and the secret sealed for storage.
+func (s *service) newSecret() (string, string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	secret := secretPrefix + hex.EncodeToString(key)
+
+	sealed, err := s.vault.SealSecret(secret, secretPurpose)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	return secret, sealed, nil
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,182 @@
+// Package authorization decides whether a card payment may go ahead,
+// independent of how the payment arrived. A payment is a Request run through
+// a sequence of checks; each check passes, rejects the payment with a
+// *Decline carrying a reason each transport maps to its own status or
+// response code, or fails with any other error. DefaultChecks are the checks
+// every transport runs: the HTTP routes through middleware.Authorize, and the
+// ISO 8583 gateway, batch jobs and tests through an Engine.
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+// Timezone is where spending control windows and periods are judged.
+const Timezone = "Asia/Tokyo"
+
+// Decline reasons.
+const (
+	ReasonCardNotFound      = "card_not_found"
+	ReasonExpiryMismatch    = "expiry_mismatch"
+	ReasonUnknownMerchant   = "unknown_merchant"
+	ReasonCardInactive      = "card_inactive"
+	ReasonCardExpired       = "card_expired"
+	ReasonVelocity          = "velocity"
+	ReasonInvalidCVV        = "invalid_cvv"
+	ReasonInvalidPIN        = "invalid_pin"
+	ReasonPINNotSupported   = "pin_not_supported"
+	ReasonPINLocked         = "pin_locked"
+	ReasonInsufficientFunds = "insufficient_funds"
+	ReasonSpendingLimit     = "spending_limit"
+	ReasonDailyLimit        = "daily_limit"
+	ReasonMonthlyLimit      = "monthly_limit"
+	ReasonSuspectedFraud    = "suspected_fraud"
+	ReasonSpendingControl   = "spending_control"
+	ReasonDeclined          = "declined"
+)
+
+// Request is a payment to authorize. The card is found by CardID, by its
+// Token, or by PANFingerprint when the transport only knows the card number;
+// ValidCard sets Card and Held, the amount held on it for gateway
+// authorizations. ExpiryYear and ExpiryMonth are checked against the card when
+// they are given.
+type Request struct {
+	CompanyID      uuid.UUID
+	CardID         uuid.UUID
+	Token          string
+	PANFingerprint string
+	ExpiryYear     int
+	ExpiryMonth    int
+	Card           *models.Card
+	Held           float64
+
+	Amount           float64
+	MerchantName     string
+	MerchantCategory string
+	MCC              string
+	MerchantID       *uuid.UUID
+	Channel          string
+	TerminalID       string
+	CVV              string
+	PIN              string
+
+	// Set by the checks as they run.
+	DaysUntilExpiry *int
+	Limits          *LimitUsage
+	Risk            *models.RiskAssessment
+}
+
+// Decline is a payment rejected by a check. Details are the facts behind the
+// decision, such as the limit and the amount already spent.
+type Decline struct {
+	Reason  string
+	Message string
+	Details map[string]interface{}
+}
+
+func (d *Decline) Error() string {
+	return d.Message
+}
+
+// CountsAgainstCard reports whether the decline is about the card or its
+// holder. Payments for an unknown card or merchant, or with a PIN the channel
+// cannot carry, were never really tried on the card and are not counted
+// towards its decline limit.
+func (d *Decline) CountsAgainstCard() bool {
+	switch d.Reason {
+	case ReasonCardNotFound, ReasonUnknownMerchant, ReasonPINNotSupported:
+		return false
+	default:
+		return true
+	}
+}
+
+// AsDecline returns the decline in err's chain, if any.
+func AsDecline(err error) (*Decline, bool) {
+	var decline *Decline
+	ok := errors.As(err, &decline)
+	return decline, ok
+}
+
+// Check is one step of authorizing a payment. It returns a *Decline to reject
+// the payment and any other error when it cannot decide. Checks may fill in
+// the request for the checks after them.
+type Check interface {
+	Check(ctx context.Context, req *Request) error
+}
+
+// CheckFunc adapts a function to a Check.
+type CheckFunc func(ctx context.Context, req *Request) error
+
+func (f CheckFunc) Check(ctx context.Context, req *Request) error {
+	return f(ctx, req)
+}
+
+// Recorder is a check that learns from the outcome of the payments it saw.
+// decline is nil for an approved payment.
+type Recorder interface {
+	Record(ctx context.Context, req *Request, decline *Decline)
+}
+
+// Decision is the outcome of authorizing a payment.
+type Decision struct {
+	Approved bool
+	Decline  *Decline
+}
+
+type Engine struct {
+	checks []Check
+}
+
+// NewEngine creates an engine running checks in the given order.
+func NewEngine(checks ...Check) *Engine {
+	return &Engine{checks: checks}
+}
+
+// Authorize runs the checks in order until one declines the payment. Checks
+// that record outcomes are told the decision once it is made. An error means
+// the payment could not be decided.
+func (e *Engine) Authorize(ctx context.Context, req *Request) (*Decision, error) {
+	decision := &Decision{Approved: true}
+
+	var recorders []Recorder
+	for _, check := range e.checks {
+		if recorder, ok := check.(Recorder); ok {
+			recorders = append(recorders, recorder)
+		}
+
+		err := check.Check(ctx, req)
+		if err == nil {
+			continue
+		}
+
+		decline, ok := AsDecline(err)
+		if !ok {
+			return nil, err
+		}
+		decision = &Decision{Decline: decline}
+		break
+	}
+
+	for _, recorder := range recorders {
+		recorder.Record(ctx, req, decision.Decline)
+	}
+
+	return decision, nil
+}
+
+// loadLocation returns the location spending is judged in.
+func loadLocation() *time.Location {
+	loc, err := time.LoadLocation(Timezone)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load Tokyo timezone: %v", err))
+	}
+	return loc
+}
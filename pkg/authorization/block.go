@@ -0,0 +1,57 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+// blockCard blocks an active card and records the status change in the
+// outbox in the same transaction. It reports whether this call blocked the
+// card; a card that is no longer active is left as it is.
+func blockCard(ctx context.Context, db *sql.DB, card *models.Card, reason string) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE cards
+		SET status = $2, blocked_at = CURRENT_TIMESTAMP, blocked_reason = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $4`,
+		card.ID, models.CardStatusBlocked, reason, models.CardStatusActive,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to block card: %w", err)
+	}
+
+	blocked, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if blocked == 0 {
+		return false, nil
+	}
+
+	if err := outbox.Enqueue(ctx, tx, cardBlockedEvent(card, reason)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func cardBlockedEvent(card *models.Card, reason string) *outbox.Event {
+	return &outbox.Event{
+		CompanyID: card.CompanyID,
+		Type:      models.EventCardBlocked,
+		Data: models.CardStatusChangedEvent{
+			CardID: card.ID,
+			Status: models.CardStatusBlocked,
+			Reason: reason,
+		},
+	}
+}
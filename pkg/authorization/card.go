@@ -0,0 +1,120 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"ccards/pkg/ledger"
+	"ccards/pkg/models"
+)
+
+type validCard struct {
+	db *sql.DB
+}
+
+// ValidCard loads the card of the payment and checks the expiry date the
+// payment carries, if any. A card already set on the request is kept.
+func ValidCard(db *sql.DB) Check {
+	return &validCard{db: db}
+}
+
+func (v *validCard) Check(ctx context.Context, req *Request) error {
+	if req.Card == nil {
+		query := `SELECT ` + models.CardColumns + ` FROM cards WHERE id = $1`
+		arg := interface{}(req.CardID)
+		switch {
+		case req.Token != "":
+			query = `SELECT ` + models.CardColumns + ` FROM cards WHERE token = $1`
+			arg = req.Token
+		case req.PANFingerprint != "":
+			// Cards are looked up by a keyed hash of their number, never by
+			// the number itself.
+			query = `SELECT ` + models.CardColumns + ` FROM cards WHERE pan_fingerprint = $1`
+			arg = req.PANFingerprint
+		}
+
+		var card models.Card
+		if err := models.ScanCard(v.db.QueryRowContext(ctx, query, arg), &card); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &Decline{Reason: ReasonCardNotFound, Message: "Card not found"}
+			}
+			return fmt.Errorf("failed to get card: %w", err)
+		}
+		req.Card = &card
+		req.CardID = card.ID
+	}
+
+	held, err := ledger.HeldAmount(ctx, v.db, req.Card.ID)
+	if err != nil {
+		return err
+	}
+	req.Held = held
+
+	if req.ExpiryYear != 0 &&
+		(req.Card.ExpiryDate.Year() != req.ExpiryYear || int(req.Card.ExpiryDate.Month()) != req.ExpiryMonth) {
+		return &Decline{Reason: ReasonExpiryMismatch, Message: "Card expiry date does not match"}
+	}
+
+	return nil
+}
+
+// UsableCard checks that the card is active and not past its expiry date. A
+// locked PIN only stops payments that carry a PIN. Cards expiring within 30
+// days get DaysUntilExpiry.
+func UsableCard() Check {
+	return CheckFunc(usableCard)
+}
+
+func usableCard(ctx context.Context, req *Request) error {
+	card := req.Card
+
+	if card.Status != models.CardStatusActive {
+		reason := ReasonCardInactive
+		var message string
+		switch card.Status {
+		case models.CardStatusBlocked:
+			message = "Card is blocked"
+			if card.BlockedReason != nil {
+				message += ": " + *card.BlockedReason
+			}
+		case models.CardStatusExpired:
+			reason = ReasonCardExpired
+			message = "Card has expired"
+		case models.CardStatusCancelled:
+			message = "Card has been cancelled"
+		default:
+			message = "Card is not active"
+		}
+
+		return &Decline{
+			Reason:  reason,
+			Message: message,
+			Details: map[string]interface{}{"status": card.Status},
+		}
+	}
+
+	if card.PINLockedAt != nil && req.PIN != "" {
+		return &Decline{
+			Reason:  ReasonPINLocked,
+			Message: "Card PIN is locked",
+			Details: map[string]interface{}{"pin_locked_at": card.PINLockedAt},
+		}
+	}
+
+	if time.Now().After(card.ExpiryDate) {
+		return &Decline{
+			Reason:  ReasonCardExpired,
+			Message: "Card has expired",
+			Details: map[string]interface{}{"expiry_date": card.ExpiryDate.Format("2006-01-02")},
+		}
+	}
+
+	if days := int(time.Until(card.ExpiryDate).Hours() / 24); days <= 30 {
+		req.DaysUntilExpiry = &days
+	}
+
+	return nil
+}
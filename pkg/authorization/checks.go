@@ -0,0 +1,39 @@
+package authorization
+
+import (
+	"database/sql"
+
+	"github.com/redis/go-redis/v9"
+
+	"ccards/pkg/config"
+	"ccards/pkg/vault"
+)
+
+// Dependencies are what the default checks need.
+type Dependencies struct {
+	DB          *sql.DB
+	Redis       *redis.Client
+	Vault       *vault.Vault
+	PINAttempts PINAttempts
+	Card        config.CardConfig
+	Risk        config.RiskConfig
+}
+
+// DefaultChecks returns the checks every payment goes through once its card
+// is found, in the order they run. Each transport finds the card its own way
+// with ValidCard and then runs these, so a payment is judged the same
+// whichever way it arrived. The CVV and PIN checks pass payments that carry
+// neither.
+func DefaultChecks(deps Dependencies) []Check {
+	return []Check{
+		ResolveMerchant(deps.DB),
+		UsableCard(),
+		NewVelocity(deps.DB, deps.Redis),
+		VerifyCVV(deps.DB, deps.Vault, deps.Card.CVVMaxAttempts),
+		VerifyPIN(deps.PINAttempts, deps.Vault, deps.Card.PINMaxAttempts),
+		SufficientAmount(),
+		WithinDailyLimit(deps.DB),
+		RiskScore(deps.DB, NewRiskEngine(deps.Risk)),
+		SpendingLimit(deps.DB),
+	}
+}
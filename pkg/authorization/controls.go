@@ -0,0 +1,544 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+type TimeBasedControl struct {
+	StartTime string `json:"start_time"` // Format: "HH:MM"
+	EndTime   string `json:"end_time"`   // Format: "HH:MM"
+}
+
+// MerchantCategoryControl allows or denies merchant categories and MCCs. MCC
+// entries are single codes ("5812") or inclusive ranges ("5811-5814").
+type MerchantCategoryControl struct {
+	AllowedCategories []string `json:"allowed_categories"`
+	BlockedCategories []string `json:"blocked_categories"`
+	AllowedMCCs       []string `json:"allowed_mccs,omitempty"`
+	BlockedMCCs       []string `json:"blocked_mccs,omitempty"`
+}
+
+// ChannelControl allows or denies transaction channels. BlockedCategories
+// blocks merchant categories on one channel only, for example gambling
+// online.
+type ChannelControl struct {
+	AllowedChannels   []string            `json:"allowed_channels,omitempty"`
+	BlockedChannels   []string            `json:"blocked_channels,omitempty"`
+	BlockedCategories map[string][]string `json:"blocked_categories,omitempty"`
+}
+
+// CategoryLimitControl caps spending per merchant category or merchant. Every
+// limit that matches a payment is checked.
+type CategoryLimitControl struct {
+	Limits []models.CategoryLimit `json:"limits"`
+}
+
+type spendingLimit struct {
+	db       *sql.DB
+	location *time.Location
+}
+
+// SpendingLimit checks the payment against the card's active spending
+// controls. A control that cannot be read declines the payment.
+func SpendingLimit(db *sql.DB) Check {
+	return &spendingLimit{
+		db:       db,
+		location: loadLocation(),
+	}
+}
+
+func (s *spendingLimit) Check(ctx context.Context, req *Request) error {
+	controls, err := s.getSpendingControls(ctx, req.Card.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve spending controls: %w", err)
+	}
+
+	for _, control := range controls {
+		if !control.IsActive {
+			continue
+		}
+
+		switch control.ControlType {
+		case "merchant_category":
+			if err := checkMerchantCategory(control, req.MerchantCategory, req.MCC); err != nil {
+				return &Decline{
+					Reason:  ReasonSpendingControl,
+					Message: err.Error(),
+					Details: map[string]interface{}{
+						"control_type":      "merchant_category",
+						"merchant_category": req.MerchantCategory,
+						"mcc":               req.MCC,
+					},
+				}
+			}
+
+		case "time_based":
+			if err := s.checkTimeBased(control); err != nil {
+				return &Decline{
+					Reason:  ReasonSpendingControl,
+					Message: err.Error(),
+					Details: map[string]interface{}{
+						"control_type": "time_based",
+						"current_time": time.Now().In(s.location).Format("15:04"),
+						"timezone":     Timezone,
+					},
+				}
+			}
+
+		case "channel":
+			if err := checkChannel(control, req.Channel, req.MerchantCategory); err != nil {
+				return &Decline{
+					Reason:  ReasonSpendingControl,
+					Message: err.Error(),
+					Details: map[string]interface{}{
+						"control_type":      "channel",
+						"channel":           req.Channel,
+						"merchant_category": req.MerchantCategory,
+					},
+				}
+			}
+
+		case "category_limit":
+			if err := s.checkCategoryLimits(ctx, control, req); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *spendingLimit) getSpendingControls(ctx context.Context, cardID uuid.UUID) ([]*models.SpendingControl, error) {
+	query := `
+		SELECT id, card_id, control_type, control_value, is_active, created_at, updated_at
+		FROM spending_controls
+		WHERE card_id = $1 AND is_active = true
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, cardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var controls []*models.SpendingControl
+	for rows.Next() {
+		var control models.SpendingControl
+		var controlValueJSON []byte
+
+		err := rows.Scan(
+			&control.ID,
+			&control.CardID,
+			&control.ControlType,
+			&controlValueJSON,
+			&control.IsActive,
+			&control.CreatedAt,
+			&control.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		control.ControlValue = json.RawMessage(controlValueJSON)
+		controls = append(controls, &control)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return controls, nil
+}
+
+func checkMerchantCategory(control *models.SpendingControl, merchantCategory, mcc string) error {
+	var categoryControl MerchantCategoryControl
+
+	if err := json.Unmarshal([]byte(control.ControlValue.(json.RawMessage)), &categoryControl); err != nil {
+		return fmt.Errorf("invalid merchant category control configuration: %w", err)
+	}
+
+	merchantCategory = strings.ToLower(strings.TrimSpace(merchantCategory))
+
+	for _, blocked := range categoryControl.BlockedCategories {
+		if merchantCategory == strings.ToLower(strings.TrimSpace(blocked)) {
+			return fmt.Errorf("Transaction blocked: merchant category '%s' is not allowed", merchantCategory)
+		}
+	}
+
+	if matchesMCC(categoryControl.BlockedMCCs, mcc) {
+		return fmt.Errorf("Transaction blocked: MCC '%s' is not allowed", mcc)
+	}
+
+	if len(categoryControl.AllowedCategories) > 0 || len(categoryControl.AllowedMCCs) > 0 {
+		allowed := matchesMCC(categoryControl.AllowedMCCs, mcc)
+		for _, allowedCat := range categoryControl.AllowedCategories {
+			if merchantCategory == strings.ToLower(strings.TrimSpace(allowedCat)) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("Transaction blocked: merchant category '%s' is not in allowed list", merchantCategory)
+		}
+	}
+
+	return nil
+}
+
+func checkChannel(control *models.SpendingControl, channel, merchantCategory string) error {
+	var channelControl ChannelControl
+
+	if err := json.Unmarshal([]byte(control.ControlValue.(json.RawMessage)), &channelControl); err != nil {
+		return fmt.Errorf("invalid channel control configuration: %w", err)
+	}
+
+	if channel == "" {
+		channel = models.TransactionChannelOnline
+	}
+
+	if containsFold(channelControl.BlockedChannels, channel) {
+		return fmt.Errorf("Transaction blocked: channel '%s' is not allowed", channel)
+	}
+
+	if len(channelControl.AllowedChannels) > 0 && !containsFold(channelControl.AllowedChannels, channel) {
+		return fmt.Errorf("Transaction blocked: channel '%s' is not in allowed list", channel)
+	}
+
+	merchantCategory = strings.ToLower(strings.TrimSpace(merchantCategory))
+	for blockedChannel, categories := range channelControl.BlockedCategories {
+		if strings.EqualFold(blockedChannel, channel) && containsFold(categories, merchantCategory) {
+			return fmt.Errorf("Transaction blocked: merchant category '%s' is not allowed for channel '%s'", merchantCategory, channel)
+		}
+	}
+
+	return nil
+}
+
+// matchesMCC reports whether mcc matches one of the codes or ranges in
+// patterns. An empty mcc matches nothing.
+func matchesMCC(patterns []string, mcc string) bool {
+	if mcc == "" {
+		return false
+	}
+
+	code, err := strconv.Atoi(mcc)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		low, high, err := parseMCCRange(pattern)
+		if err == nil && code >= low && code <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMCCRange parses a single MCC ("5812") or an inclusive range
+// ("5811-5814").
+func parseMCCRange(pattern string) (low, high int, err error) {
+	parts := strings.SplitN(strings.TrimSpace(pattern), "-", 2)
+	bounds := make([]int, len(parts))
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) != 4 || strings.Trim(part, "0123456789") != "" {
+			return 0, 0, fmt.Errorf("invalid MCC %q", pattern)
+		}
+		bounds[i], _ = strconv.Atoi(part)
+	}
+
+	low, high = bounds[0], bounds[len(bounds)-1]
+	if low > high {
+		return 0, 0, fmt.Errorf("invalid MCC range %q", pattern)
+	}
+	return low, high, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCategoryLimits declines the payment at the first limit it would
+// exceed. Periods start at midnight Tokyo time; weeks start on Monday.
+func (s *spendingLimit) checkCategoryLimits(ctx context.Context, control *models.SpendingControl, req *Request) error {
+	var limitControl CategoryLimitControl
+
+	if err := json.Unmarshal([]byte(control.ControlValue.(json.RawMessage)), &limitControl); err != nil {
+		return fmt.Errorf("invalid category limit control configuration: %w", err)
+	}
+
+	category := strings.ToLower(strings.TrimSpace(req.MerchantCategory))
+	for _, limit := range limitControl.Limits {
+		var spent float64
+		var err error
+		switch {
+		case limit.MerchantID != nil:
+			if req.MerchantID == nil || *req.MerchantID != *limit.MerchantID {
+				continue
+			}
+			spent, err = s.getPeriodSpending(ctx, req.Card.ID, "merchant_id", *limit.MerchantID, limit.Period)
+		default:
+			if strings.ToLower(strings.TrimSpace(limit.Category)) != category {
+				continue
+			}
+			spent, err = s.getPeriodSpending(ctx, req.Card.ID, "merchant_category", category, limit.Period)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to calculate category spending: %w", err)
+		}
+
+		total := spent + req.Amount
+		if total <= limit.Amount {
+			continue
+		}
+
+		details := map[string]interface{}{
+			"control_type":       "category_limit",
+			"period":             limit.Period,
+			"limit":              limit.Amount,
+			"current_spending":   spent,
+			"transaction_amount": req.Amount,
+			"total_would_be":     total,
+			"remaining_limit":    limit.Amount - spent,
+			"timezone":           Timezone,
+		}
+		if limit.MerchantID != nil {
+			details["merchant_id"] = *limit.MerchantID
+		} else {
+			details["merchant_category"] = category
+		}
+		return &Decline{
+			Reason:  ReasonSpendingControl,
+			Message: fmt.Sprintf("Transaction would exceed %s limit", limit.Period),
+			Details: details,
+		}
+	}
+
+	return nil
+}
+
+// getPeriodSpending sums the card's completed purchases and holds in the
+// current period where column equals value. column is always a constant
+// chosen by the caller.
+func (s *spendingLimit) getPeriodSpending(ctx context.Context, cardID uuid.UUID, column string, value interface{}, period string) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM transactions
+		WHERE card_id = $1
+		  AND ` + column + ` = $2
+		  AND status IN ($3, $6)
+		  AND transaction_type = $4
+		  AND created_at >= $5
+	`
+
+	var total float64
+	err := s.db.QueryRowContext(ctx, query,
+		cardID,
+		value,
+		models.TransactionStatusCompleted,
+		models.TransactionTypePurchase,
+		periodStart(time.Now().In(s.location), period),
+		models.TransactionStatusPending,
+	).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func periodStart(now time.Time, period string) time.Time {
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case models.SpendingPeriodWeekly:
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		return startOfDay.AddDate(0, 0, -daysSinceMonday)
+	case models.SpendingPeriodMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	default:
+		return startOfDay
+	}
+}
+
+func (s *spendingLimit) checkTimeBased(control *models.SpendingControl) error {
+	var timeControl TimeBasedControl
+
+	if err := json.Unmarshal([]byte(control.ControlValue.(json.RawMessage)), &timeControl); err != nil {
+		return fmt.Errorf("invalid time-based control configuration: %w", err)
+	}
+
+	now := time.Now().In(s.location)
+
+	startHour, startMinute, err := parseTimeString(timeControl.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid start time format: %w", err)
+	}
+
+	endHour, endMinute, err := parseTimeString(timeControl.EndTime)
+	if err != nil {
+		return fmt.Errorf("invalid end time format: %w", err)
+	}
+
+	currentMinutes := now.Hour()*60 + now.Minute()
+	startMinutes := startHour*60 + startMinute
+	endMinutes := endHour*60 + endMinute
+
+	var isAllowed bool
+	if startMinutes <= endMinutes {
+		isAllowed = currentMinutes >= startMinutes && currentMinutes <= endMinutes
+	} else {
+		isAllowed = currentMinutes >= startMinutes || currentMinutes <= endMinutes
+	}
+
+	if !isAllowed {
+		return fmt.Errorf(
+			"Transaction blocked: outside allowed time window (%s - %s JST)",
+			timeControl.StartTime,
+			timeControl.EndTime,
+		)
+	}
+
+	return nil
+}
+
+func parseTimeString(timeStr string) (hour, minute int, err error) {
+	parts := strings.Split(timeStr, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time format, expected HH:MM")
+	}
+
+	if _, err := fmt.Sscanf(parts[0], "%d", &hour); err != nil {
+		return 0, 0, fmt.Errorf("invalid hour: %w", err)
+	}
+
+	if _, err := fmt.Sscanf(parts[1], "%d", &minute); err != nil {
+		return 0, 0, fmt.Errorf("invalid minute: %w", err)
+	}
+
+	if hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("hour must be between 0 and 23")
+	}
+	if minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("minute must be between 0 and 59")
+	}
+
+	return hour, minute, nil
+}
+
+// ValidateSpendingControl checks that a spending control value is well formed
+// for its control type before it is stored.
+func ValidateSpendingControl(controlType string, value json.RawMessage) error {
+	switch controlType {
+	case "merchant_category":
+		var control MerchantCategoryControl
+		if err := json.Unmarshal(value, &control); err != nil {
+			return fmt.Errorf("invalid merchant category control: %w", err)
+		}
+		if len(control.AllowedCategories) == 0 && len(control.BlockedCategories) == 0 &&
+			len(control.AllowedMCCs) == 0 && len(control.BlockedMCCs) == 0 {
+			return fmt.Errorf("merchant category control needs allowed or blocked categories or MCCs")
+		}
+		for _, pattern := range append(append([]string{}, control.AllowedMCCs...), control.BlockedMCCs...) {
+			if _, _, err := parseMCCRange(pattern); err != nil {
+				return err
+			}
+		}
+
+	case "time_based":
+		var control TimeBasedControl
+		if err := json.Unmarshal(value, &control); err != nil {
+			return fmt.Errorf("invalid time-based control: %w", err)
+		}
+		if _, _, err := parseTimeString(control.StartTime); err != nil {
+			return fmt.Errorf("invalid start time: %w", err)
+		}
+		if _, _, err := parseTimeString(control.EndTime); err != nil {
+			return fmt.Errorf("invalid end time: %w", err)
+		}
+
+	case "channel":
+		var control ChannelControl
+		if err := json.Unmarshal(value, &control); err != nil {
+			return fmt.Errorf("invalid channel control: %w", err)
+		}
+		if len(control.AllowedChannels) == 0 && len(control.BlockedChannels) == 0 && len(control.BlockedCategories) == 0 {
+			return fmt.Errorf("channel control needs allowed channels, blocked channels or blocked categories")
+		}
+		channels := append(append([]string{}, control.AllowedChannels...), control.BlockedChannels...)
+		for channel := range control.BlockedCategories {
+			channels = append(channels, channel)
+		}
+		for _, channel := range channels {
+			if !containsFold(models.TransactionChannels, channel) {
+				return fmt.Errorf("unknown channel %q", channel)
+			}
+		}
+
+	case "category_limit":
+		var control CategoryLimitControl
+		if err := json.Unmarshal(value, &control); err != nil {
+			return fmt.Errorf("invalid category limit control: %w", err)
+		}
+		if len(control.Limits) == 0 {
+			return fmt.Errorf("category limit control needs at least one limit")
+		}
+		for _, limit := range control.Limits {
+			if (strings.TrimSpace(limit.Category) == "") == (limit.MerchantID == nil) {
+				return fmt.Errorf("category limit needs either a category or a merchant_id")
+			}
+			if limit.Amount <= 0 {
+				return fmt.Errorf("category limit amount must be greater than zero")
+			}
+			switch limit.Period {
+			case models.SpendingPeriodDaily, models.SpendingPeriodWeekly, models.SpendingPeriodMonthly:
+			default:
+				return fmt.Errorf("category limit period must be %q, %q or %q",
+					models.SpendingPeriodDaily, models.SpendingPeriodWeekly, models.SpendingPeriodMonthly)
+			}
+		}
+
+	case "velocity":
+		var control VelocityControl
+		if err := json.Unmarshal(value, &control); err != nil {
+			return fmt.Errorf("invalid velocity control: %w", err)
+		}
+		if control.MaxTransactions <= 0 && control.MaxDeclines <= 0 {
+			return fmt.Errorf("velocity control needs max_transactions or max_declines")
+		}
+		if control.MaxTransactions < 0 || control.MaxDeclines < 0 {
+			return fmt.Errorf("velocity limits must not be negative")
+		}
+		if control.MaxTransactions > 0 {
+			if err := validateVelocityWindow("transaction_window", control.TransactionWindow); err != nil {
+				return err
+			}
+		}
+		if control.MaxDeclines > 0 {
+			if err := validateVelocityWindow("decline_window", control.DeclineWindow); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported control type %q", controlType)
+	}
+
+	return nil
+}
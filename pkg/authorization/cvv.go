@@ -0,0 +1,94 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+	"ccards/pkg/vault"
+)
+
+const cvvBlockedReason = "too many failed CVV attempts"
+
+type verifyCVV struct {
+	db          *sql.DB
+	vault       *vault.Vault
+	maxAttempts int
+}
+
+// VerifyCVV checks the CVV when one is sent with the payment. Failed checks
+// are counted on the card and the card is blocked after maxAttempts
+// consecutive failures; a successful check resets the count.
+func VerifyCVV(db *sql.DB, cardVault *vault.Vault, maxAttempts int) Check {
+	return &verifyCVV{
+		db:          db,
+		vault:       cardVault,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (v *verifyCVV) Check(ctx context.Context, req *Request) error {
+	if req.CVV == "" {
+		return nil
+	}
+
+	card := req.Card
+	if v.vault.VerifyCVV(card.ID, req.CVV, card.CVVMAC) {
+		_, err := v.db.ExecContext(ctx, `UPDATE cards SET cvv_failed_attempts = 0 WHERE id = $1 AND cvv_failed_attempts > 0`, card.ID)
+		if err != nil {
+			return fmt.Errorf("failed to reset CVV attempts: %w", err)
+		}
+		return nil
+	}
+
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Count the failure and block the card in one statement, so concurrent
+	// attempts cannot get past the limit.
+	var attempts int
+	var status string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE cards
+		SET cvv_failed_attempts = cvv_failed_attempts + 1,
+		    status = CASE WHEN cvv_failed_attempts + 1 >= $2 THEN $3 ELSE status END,
+		    blocked_at = CASE WHEN cvv_failed_attempts + 1 >= $2 THEN CURRENT_TIMESTAMP ELSE blocked_at END,
+		    blocked_reason = CASE WHEN cvv_failed_attempts + 1 >= $2 THEN $4 ELSE blocked_reason END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING cvv_failed_attempts, status`,
+		card.ID, v.maxAttempts, models.CardStatusBlocked, cvvBlockedReason,
+	).Scan(&attempts, &status)
+	if err != nil {
+		return fmt.Errorf("failed to count CVV attempt: %w", err)
+	}
+
+	// Only the failure that reaches the limit blocks the card.
+	if status == models.CardStatusBlocked && attempts == v.maxAttempts {
+		if err := outbox.Enqueue(ctx, tx, cardBlockedEvent(card, cvvBlockedReason)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if status == models.CardStatusBlocked {
+		return &Decline{
+			Reason:  ReasonCardInactive,
+			Message: "Card is blocked: " + cvvBlockedReason,
+			Details: map[string]interface{}{"status": status},
+		}
+	}
+
+	return &Decline{
+		Reason:  ReasonInvalidCVV,
+		Message: "Invalid CVV",
+		Details: map[string]interface{}{"attempts_remaining": v.maxAttempts - attempts},
+	}
+}
@@ -0,0 +1,52 @@
+package authorization
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+// DeclinedEvent returns the payment.declined event for a payment declined by
+// any check, or by the transport after the checks passed. The event goes to
+// the company of the card when it was found, otherwise to the company that
+// sent the payment. It returns nil when neither is known, as for a card
+// number the gateway cannot find.
+func DeclinedEvent(req *Request, decline *Decline) *outbox.Event {
+	companyID, cardID := req.CompanyID, req.CardID
+	if req.Card != nil {
+		companyID, cardID = req.Card.CompanyID, req.Card.ID
+	}
+	if companyID == uuid.Nil {
+		return nil
+	}
+
+	return &outbox.Event{
+		CompanyID: companyID,
+		Type:      models.EventPaymentDeclined,
+		Data: models.PaymentDeclinedEvent{
+			CardID:           cardID,
+			Amount:           req.Amount,
+			MerchantCategory: req.MerchantCategory,
+			Reason:           decline.Reason,
+			DeclinedAt:       time.Now(),
+		},
+	}
+}
+
+// PublishDecline records the payment.declined event of a declined payment.
+// Transports that do not record the decline in a transaction of their own
+// use it. Failures are logged.
+func PublishDecline(ctx context.Context, exec outbox.Execer, req *Request, decline *Decline) {
+	event := DeclinedEvent(req, decline)
+	if event == nil {
+		return
+	}
+	if err := outbox.Enqueue(ctx, exec, event); err != nil {
+		log.Printf("authorization: failed to record declined payment event: %v", err)
+	}
+}
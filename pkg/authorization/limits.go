@@ -0,0 +1,157 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+// LimitUsage is how much of the card's daily and monthly limits was spent
+// before the payment. Monthly figures are nil when the card has no monthly
+// limit.
+type LimitUsage struct {
+	TodaySpending         float64
+	RemainingDailyLimit   float64
+	MonthlySpending       *float64
+	RemainingMonthlyLimit *float64
+}
+
+// SufficientAmount checks the payment against the card balance less what is
+// held on it, and the card's per-payment spending limit.
+func SufficientAmount() Check {
+	return CheckFunc(sufficientAmount)
+}
+
+func sufficientAmount(ctx context.Context, req *Request) error {
+	card := req.Card
+
+	available := card.Balance - req.Held
+	if available < req.Amount {
+		return &Decline{
+			Reason:  ReasonInsufficientFunds,
+			Message: "Insufficient balance",
+			Details: map[string]interface{}{
+				"available_balance": available,
+				"required_amount":   req.Amount,
+				"shortage":          req.Amount - available,
+			},
+		}
+	}
+
+	if card.SpendingLimit != nil && req.Amount > *card.SpendingLimit {
+		return &Decline{
+			Reason:  ReasonSpendingLimit,
+			Message: "Transaction exceeds spending limit",
+			Details: map[string]interface{}{
+				"spending_limit": *card.SpendingLimit,
+				"amount":         req.Amount,
+			},
+		}
+	}
+
+	return nil
+}
+
+type dailyLimit struct {
+	db *sql.DB
+}
+
+// WithinDailyLimit checks the payment against the card's daily and monthly
+// limits and records the spending so far in Limits. Cards without a daily
+// limit are not checked.
+func WithinDailyLimit(db *sql.DB) Check {
+	return &dailyLimit{db: db}
+}
+
+func (d *dailyLimit) Check(ctx context.Context, req *Request) error {
+	card := req.Card
+	if card.DailyLimit == nil {
+		return nil
+	}
+
+	now := time.Now()
+	todaySpending, err := d.spendingSince(ctx, card.ID, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	if err != nil {
+		return fmt.Errorf("failed to calculate daily spending: %w", err)
+	}
+
+	totalDailySpending := todaySpending + req.Amount
+	if totalDailySpending > *card.DailyLimit {
+		return &Decline{
+			Reason:  ReasonDailyLimit,
+			Message: "Transaction would exceed daily limit",
+			Details: map[string]interface{}{
+				"daily_limit":        *card.DailyLimit,
+				"current_spending":   todaySpending,
+				"transaction_amount": req.Amount,
+				"total_would_be":     totalDailySpending,
+				"remaining_limit":    *card.DailyLimit - todaySpending,
+			},
+		}
+	}
+
+	usage := &LimitUsage{
+		TodaySpending:       todaySpending,
+		RemainingDailyLimit: *card.DailyLimit - todaySpending,
+	}
+
+	if card.MonthlyLimit != nil {
+		monthlySpending, err := d.spendingSince(ctx, card.ID, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()))
+		if err != nil {
+			return fmt.Errorf("failed to calculate monthly spending: %w", err)
+		}
+
+		totalMonthlySpending := monthlySpending + req.Amount
+		if totalMonthlySpending > *card.MonthlyLimit {
+			return &Decline{
+				Reason:  ReasonMonthlyLimit,
+				Message: "Transaction would exceed monthly limit",
+				Details: map[string]interface{}{
+					"monthly_limit":      *card.MonthlyLimit,
+					"current_spending":   monthlySpending,
+					"transaction_amount": req.Amount,
+					"total_would_be":     totalMonthlySpending,
+					"remaining_limit":    *card.MonthlyLimit - monthlySpending,
+				},
+			}
+		}
+
+		remaining := *card.MonthlyLimit - monthlySpending
+		usage.MonthlySpending = &monthlySpending
+		usage.RemainingMonthlyLimit = &remaining
+	}
+
+	req.Limits = usage
+	return nil
+}
+
+// spendingSince sums the card's completed purchases and holds since start.
+func (d *dailyLimit) spendingSince(ctx context.Context, cardID uuid.UUID, start time.Time) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0) as total_spending
+		FROM transactions
+		WHERE card_id = $1
+		  AND status IN ($2, $5)
+		  AND created_at >= $3
+		  AND transaction_type = $4
+	`
+
+	var totalSpending float64
+	err := d.db.QueryRowContext(ctx, query,
+		cardID,
+		models.TransactionStatusCompleted,
+		start,
+		models.TransactionTypePurchase,
+		models.TransactionStatusPending,
+	).Scan(&totalSpending)
+	if err != nil {
+		return 0, err
+	}
+
+	return totalSpending, nil
+}
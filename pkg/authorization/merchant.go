@@ -0,0 +1,64 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"ccards/pkg/models"
+)
+
+type resolveMerchant struct {
+	db *sql.DB
+}
+
+// ResolveMerchant normalizes the merchant of a payment. A registered merchant
+// supplies the name and MCC, an MCC is mapped to its catalogue category, and a
+// free-text category is lower-cased. Later checks and the stored transaction
+// see the normalized category in MerchantCategory.
+func ResolveMerchant(db *sql.DB) Check {
+	return &resolveMerchant{db: db}
+}
+
+func (r *resolveMerchant) Check(ctx context.Context, req *Request) error {
+	if req.MerchantID != nil {
+		var name, mcc, category string
+		err := r.db.QueryRowContext(ctx, `
+			SELECT m.name, m.mcc, c.category
+			FROM merchants m
+			JOIN merchant_category_codes c ON c.mcc = m.mcc
+			WHERE m.id = $1`,
+			*req.MerchantID,
+		).Scan(&name, &mcc, &category)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return &Decline{Reason: ReasonUnknownMerchant, Message: "Unknown merchant"}
+			}
+			return fmt.Errorf("failed to get merchant: %w", err)
+		}
+
+		req.MerchantName = name
+		req.MCC = mcc
+		req.MerchantCategory = category
+		return nil
+	}
+
+	if req.MCC != "" {
+		var category string
+		err := r.db.QueryRowContext(ctx, `SELECT category FROM merchant_category_codes WHERE mcc = $1`, req.MCC).Scan(&category)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			category = models.MerchantCategoryOther
+		case err != nil:
+			return fmt.Errorf("failed to get merchant category: %w", err)
+		}
+
+		req.MerchantCategory = category
+		return nil
+	}
+
+	req.MerchantCategory = strings.ToLower(strings.TrimSpace(req.MerchantCategory))
+	return nil
+}
@@ -0,0 +1,68 @@
+package authorization
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+)
+
+// PINAttempts counts failed PIN attempts on a card. The card repository
+// implements it, so the PIN check and PIN changes share one lockout.
+type PINAttempts interface {
+	RecordPINFailure(ctx context.Context, cardID uuid.UUID, maxAttempts int) (int, error)
+	ResetPINFailures(ctx context.Context, cardID uuid.UUID) error
+}
+
+type verifyPIN struct {
+	attempts    PINAttempts
+	vault       *vault.Vault
+	maxAttempts int
+}
+
+// VerifyPIN checks the PIN of card-present payments that carry one. Failed
+// checks are counted on the card and the PIN is locked after maxAttempts
+// consecutive failures; a successful check resets the count. A locked PIN
+// is rejected earlier by UsableCard.
+func VerifyPIN(attempts PINAttempts, cardVault *vault.Vault, maxAttempts int) Check {
+	return &verifyPIN{
+		attempts:    attempts,
+		vault:       cardVault,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (v *verifyPIN) Check(ctx context.Context, req *Request) error {
+	if req.PIN == "" {
+		return nil
+	}
+
+	card := req.Card
+	if card.CardType != models.CardTypePhysical {
+		return &Decline{Reason: ReasonPINNotSupported, Message: "PIN is only supported for physical cards"}
+	}
+	if !card.PINSet {
+		return &Decline{Reason: ReasonPINNotSupported, Message: "Card has no PIN set"}
+	}
+
+	if v.vault.VerifyPIN(card.ID, req.PIN, card.PINHash) {
+		return v.attempts.ResetPINFailures(ctx, card.ID)
+	}
+
+	attempts, err := v.attempts.RecordPINFailure(ctx, card.ID, v.maxAttempts)
+	if err != nil {
+		return err
+	}
+
+	if attempts >= v.maxAttempts {
+		return &Decline{Reason: ReasonPINLocked, Message: "Card PIN is locked: too many failed PIN attempts"}
+	}
+
+	return &Decline{
+		Reason:  ReasonInvalidPIN,
+		Message: "Invalid PIN",
+		Details: map[string]interface{}{"attempts_remaining": v.maxAttempts - attempts},
+	}
+}
@@ -0,0 +1,126 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+	"ccards/pkg/risk"
+)
+
+const (
+	riskBlockedReason = "suspected fraud"
+
+	// riskHistoryDays and riskHistoryLimit bound the purchase history the
+	// risk rules see.
+	riskHistoryDays  = 90
+	riskHistoryLimit = 200
+)
+
+// NewRiskEngine creates a risk engine with the thresholds in cfg and the
+// default fraud rules, judging night hours in Tokyo time.
+func NewRiskEngine(cfg config.RiskConfig) *risk.Engine {
+	return risk.NewEngine(risk.Thresholds{
+		Review:    cfg.ReviewScore,
+		Decline:   cfg.DeclineScore,
+		BlockCard: cfg.BlockScore,
+	}, risk.DefaultRules(loadLocation())...)
+}
+
+type riskScore struct {
+	db     *sql.DB
+	engine *risk.Engine
+}
+
+// RiskScore scores the payment with engine and records the assessment in
+// Risk. Declined payments are stopped here; a block_card decision also
+// blocks the card.
+func RiskScore(db *sql.DB, engine *risk.Engine) Check {
+	return &riskScore{
+		db:     db,
+		engine: engine,
+	}
+}
+
+func (r *riskScore) Check(ctx context.Context, req *Request) error {
+	now := time.Now()
+	history, err := r.getHistory(ctx, req.Card.ID, now)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve transaction history: %w", err)
+	}
+
+	assessment := r.engine.Assess(&risk.Input{
+		Amount:   req.Amount,
+		Category: req.MerchantCategory,
+		MCC:      req.MCC,
+		Channel:  req.Channel,
+		Time:     now,
+		History:  history,
+	})
+	req.Risk = assessment
+
+	details := map[string]interface{}{
+		"risk_score":   assessment.Score,
+		"risk_action":  assessment.Action,
+		"risk_reasons": assessment.Reasons,
+	}
+
+	switch assessment.Action {
+	case models.RiskActionBlockCard:
+		if _, err := blockCard(ctx, r.db, req.Card, riskBlockedReason); err != nil {
+			return err
+		}
+
+		details["status"] = models.CardStatusBlocked
+		return &Decline{
+			Reason:  ReasonSuspectedFraud,
+			Message: "Card is blocked: " + riskBlockedReason,
+			Details: details,
+		}
+
+	case models.RiskActionDecline:
+		return &Decline{
+			Reason:  ReasonSuspectedFraud,
+			Message: "Transaction declined: suspected fraud",
+			Details: details,
+		}
+	}
+
+	return nil
+}
+
+// getHistory returns the card's recent completed purchases, newest first.
+func (r *riskScore) getHistory(ctx context.Context, cardID uuid.UUID, now time.Time) ([]risk.Payment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT amount, COALESCE(merchant_category, ''), created_at
+		FROM transactions
+		WHERE card_id = $1
+		  AND status = $2
+		  AND transaction_type = $3
+		  AND created_at >= $4
+		ORDER BY created_at DESC
+		LIMIT $5`,
+		cardID, models.TransactionStatusCompleted, models.TransactionTypePurchase,
+		now.AddDate(0, 0, -riskHistoryDays), riskHistoryLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []risk.Payment
+	for rows.Next() {
+		var p risk.Payment
+		if err := rows.Scan(&p.Amount, &p.Category, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, p)
+	}
+
+	return history, rows.Err()
+}
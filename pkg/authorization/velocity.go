@@ -0,0 +1,234 @@
+package authorization
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"ccards/pkg/models"
+)
+
+// MaxVelocityWindow is the longest window a velocity control can use. Redis
+// keeps card events for this long.
+const MaxVelocityWindow = 24 * time.Hour
+
+const velocityBlockedReason = "too many declined transactions"
+
+// VelocityControl caps how often a card can be used. At most MaxTransactions
+// payments are allowed per TransactionWindow, and the card is blocked after
+// MaxDeclines declined payments within DeclineWindow. Windows are durations
+// such as "10m" or "1h".
+type VelocityControl struct {
+	MaxTransactions   int    `json:"max_transactions,omitempty"`
+	TransactionWindow string `json:"transaction_window,omitempty"`
+	MaxDeclines       int    `json:"max_declines,omitempty"`
+	DeclineWindow     string `json:"decline_window,omitempty"`
+}
+
+type Velocity struct {
+	db          *sql.DB
+	redisClient *redis.Client
+}
+
+// NewVelocity creates the velocity check. Every card's payments and declines
+// are counted in Redis sliding windows; when Redis is not available the
+// counts are taken from Postgres instead.
+func NewVelocity(db *sql.DB, redisClient *redis.Client) *Velocity {
+	return &Velocity{
+		db:          db,
+		redisClient: redisClient,
+	}
+}
+
+// Check enforces the transaction limit of the card's velocity control.
+func (v *Velocity) Check(ctx context.Context, req *Request) error {
+	control, err := v.getVelocityControl(ctx, req.Card.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve velocity control: %w", err)
+	}
+	if control == nil || control.MaxTransactions <= 0 {
+		return nil
+	}
+
+	window, _ := time.ParseDuration(control.TransactionWindow)
+	count, err := v.countTransactions(ctx, req.Card.ID, window)
+	if err != nil {
+		return fmt.Errorf("failed to count recent transactions: %w", err)
+	}
+
+	if count >= control.MaxTransactions {
+		return &Decline{
+			Reason:  ReasonVelocity,
+			Message: fmt.Sprintf("Transaction blocked: more than %d transactions in %s", control.MaxTransactions, window),
+			Details: map[string]interface{}{
+				"control_type":     "velocity",
+				"max_transactions": control.MaxTransactions,
+				"window":           control.TransactionWindow,
+				"transactions":     count,
+			},
+		}
+	}
+
+	return nil
+}
+
+// Record counts an approved payment, or stores a decline and blocks the card
+// when it reaches the control's decline limit. The payment.declined event is
+// recorded by the transport, for every decline. Declines that do not count
+// against the card, such as an unknown merchant, are skipped. Failures are
+// logged.
+func (v *Velocity) Record(ctx context.Context, req *Request, decline *Decline) {
+	if req.Card == nil {
+		return
+	}
+
+	if decline == nil {
+		v.recordEvent(ctx, transactionsKey(req.Card.ID))
+		return
+	}
+
+	if !decline.CountsAgainstCard() {
+		return
+	}
+	if err := v.recordDecline(ctx, req, decline); err != nil {
+		log.Printf("velocity: failed to record decline for card %s: %v", req.Card.ID, err)
+	}
+}
+
+func (v *Velocity) getVelocityControl(ctx context.Context, cardID uuid.UUID) (*VelocityControl, error) {
+	var value []byte
+	err := v.db.QueryRowContext(ctx, `
+		SELECT control_value
+		FROM spending_controls
+		WHERE card_id = $1 AND control_type = 'velocity' AND is_active = true`,
+		cardID,
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var control VelocityControl
+	if err := json.Unmarshal(value, &control); err != nil {
+		return nil, fmt.Errorf("invalid velocity control configuration: %w", err)
+	}
+	return &control, nil
+}
+
+func (v *Velocity) recordDecline(ctx context.Context, req *Request, decline *Decline) error {
+	card := req.Card
+	_, err := v.db.ExecContext(ctx, `INSERT INTO card_declines (card_id, reason) VALUES ($1, $2)`, card.ID, decline.Reason)
+	if err != nil {
+		return err
+	}
+	v.recordEvent(ctx, declinesKey(card.ID))
+
+	control, err := v.getVelocityControl(ctx, card.ID)
+	if err != nil || control == nil || control.MaxDeclines <= 0 {
+		return err
+	}
+
+	window, _ := time.ParseDuration(control.DeclineWindow)
+	count, err := v.countDeclines(ctx, card.ID, window)
+	if err != nil {
+		return err
+	}
+	if count < control.MaxDeclines {
+		return nil
+	}
+
+	_, err = blockCard(ctx, v.db, card, velocityBlockedReason)
+	return err
+}
+
+func (v *Velocity) countTransactions(ctx context.Context, cardID uuid.UUID, window time.Duration) (int, error) {
+	if count, err := v.countEvents(ctx, transactionsKey(cardID), window); err == nil {
+		return count, nil
+	}
+
+	var count int
+	err := v.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM transactions
+		WHERE card_id = $1
+		  AND status = $2
+		  AND transaction_type = $3
+		  AND created_at > $4`,
+		cardID, models.TransactionStatusCompleted, models.TransactionTypePurchase, time.Now().Add(-window),
+	).Scan(&count)
+	return count, err
+}
+
+func (v *Velocity) countDeclines(ctx context.Context, cardID uuid.UUID, window time.Duration) (int, error) {
+	if count, err := v.countEvents(ctx, declinesKey(cardID), window); err == nil {
+		return count, nil
+	}
+
+	var count int
+	err := v.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM card_declines WHERE card_id = $1 AND created_at > $2`,
+		cardID, time.Now().Add(-window),
+	).Scan(&count)
+	return count, err
+}
+
+// recordEvent adds an event to a sliding window and trims events older than
+// MaxVelocityWindow. Failures are logged; counts then fall back to Postgres
+// once Redis is unavailable.
+func (v *Velocity) recordEvent(ctx context.Context, key string) {
+	if v.redisClient == nil {
+		return
+	}
+
+	now := time.Now()
+	pipe := v.redisClient.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.UnixMilli()), Member: uuid.NewString()})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now.Add(-MaxVelocityWindow).UnixMilli(), 10))
+	pipe.Expire(ctx, key, MaxVelocityWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("velocity: failed to record event %s: %v", key, err)
+	}
+}
+
+// countEvents counts the events in a sliding window ending now.
+func (v *Velocity) countEvents(ctx context.Context, key string, window time.Duration) (int, error) {
+	if v.redisClient == nil {
+		return 0, errors.New("redis is not configured")
+	}
+
+	since := time.Now().Add(-window).UnixMilli()
+	count, err := v.redisClient.ZCount(ctx, key, "("+strconv.FormatInt(since, 10), "+inf").Result()
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func transactionsKey(cardID uuid.UUID) string {
+	return fmt.Sprintf("velocity:%s:transactions", cardID.String())
+}
+
+func declinesKey(cardID uuid.UUID) string {
+	return fmt.Sprintf("velocity:%s:declines", cardID.String())
+}
+
+func validateVelocityWindow(name, window string) error {
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return fmt.Errorf("invalid velocity %s %q", name, window)
+	}
+	if d <= 0 || d > MaxVelocityWindow {
+		return fmt.Errorf("velocity %s must be greater than zero and at most %s", name, MaxVelocityWindow)
+	}
+	return nil
+}
@@ -18,6 +18,18 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Redis    RedisConfig    `mapstructure:"redis"`
+	Import   ImportConfig   `mapstructure:"import"`
+	Issuance IssuanceConfig `mapstructure:"issuance"`
+	Vault    VaultConfig    `mapstructure:"vault"`
+	Card     CardConfig     `mapstructure:"card"`
+	Risk     RiskConfig     `mapstructure:"risk"`
+	Webhook  WebhookConfig  `mapstructure:"webhook"`
+	Outbox   OutboxConfig   `mapstructure:"outbox"`
+
+	Notification NotificationConfig `mapstructure:"notification"`
+	Stream       StreamConfig       `mapstructure:"stream"`
+	Store        StoreConfig        `mapstructure:"store"`
+	Gateway      GatewayConfig      `mapstructure:"gateway"`
 }
 
 type AppConfig struct {
@@ -65,6 +77,122 @@ type RedisConfig struct {
 	PoolSize     int           `mapstructure:"pool_size"`
 }
 
+type ImportConfig struct {
+	Dir           string        `mapstructure:"dir"`
+	ChunkSize     int           `mapstructure:"chunk_size"`
+	Workers       int           `mapstructure:"workers"`
+	LeaseDuration time.Duration `mapstructure:"lease_duration"`
+	PollInterval  time.Duration `mapstructure:"poll_interval"`
+}
+
+type IssuanceConfig struct {
+	SchedulerInterval time.Duration `mapstructure:"scheduler_interval"`
+}
+
+// VaultConfig holds the master key used to encrypt card data keys. MasterKey
+// is a base64-encoded 32-byte AES key; KeyID is stored with every encrypted
+// card so the key can be identified later.
+type VaultConfig struct {
+	MasterKey string `mapstructure:"master_key"`
+	KeyID     string `mapstructure:"key_id"`
+}
+
+// CardConfig holds card security settings. A card is blocked after
+// CVVMaxAttempts consecutive failed CVV checks, and its PIN is locked after
+// PINMaxAttempts consecutive wrong PINs.
+type CardConfig struct {
+	CVVMaxAttempts int `mapstructure:"cvv_max_attempts"`
+	PINMaxAttempts int `mapstructure:"pin_max_attempts"`
+}
+
+// RiskConfig holds the fraud score thresholds. A payment scoring ReviewScore
+// or more is queued for review, DeclineScore or more is declined, and
+// BlockScore or more is declined and blocks the card.
+type RiskConfig struct {
+	ReviewScore  int `mapstructure:"review_score"`
+	DeclineScore int `mapstructure:"decline_score"`
+	BlockScore   int `mapstructure:"block_score"`
+}
+
+// WebhookConfig holds webhook delivery settings. The dispatcher runs every
+// DispatchInterval. A failed delivery is retried after RetryBackoff, doubling
+// with every attempt up to MaxBackoff, and becomes a dead letter after
+// MaxAttempts attempts. AllowPrivateTargets lets endpoints use plain http and
+// private addresses, for local development only.
+type WebhookConfig struct {
+	DispatchInterval    time.Duration `mapstructure:"dispatch_interval"`
+	Timeout             time.Duration `mapstructure:"timeout"`
+	MaxAttempts         int           `mapstructure:"max_attempts"`
+	RetryBackoff        time.Duration `mapstructure:"retry_backoff"`
+	MaxBackoff          time.Duration `mapstructure:"max_backoff"`
+	AllowPrivateTargets bool          `mapstructure:"allow_private_targets"`
+}
+
+// OutboxConfig holds the event dispatcher settings. The dispatcher runs every
+// DispatchInterval and gives each subscriber HandlerTimeout per event. A failed
+// event is retried after RetryBackoff, doubling with every attempt up to
+// MaxBackoff, and is given up on after MaxAttempts attempts.
+type OutboxConfig struct {
+	DispatchInterval time.Duration `mapstructure:"dispatch_interval"`
+	HandlerTimeout   time.Duration `mapstructure:"handler_timeout"`
+	MaxAttempts      int           `mapstructure:"max_attempts"`
+	RetryBackoff     time.Duration `mapstructure:"retry_backoff"`
+	MaxBackoff       time.Duration `mapstructure:"max_backoff"`
+}
+
+// NotificationConfig holds the notification triggers and channels. A payment
+// notifies when it takes a card's balance below LowBalanceThreshold, or its
+// daily or monthly spending to LimitWarningRatio of the limit. Cards expiring
+// within ExpiryWarningDays notify once; they are looked for every
+// ExpiryCheckInterval.
+type NotificationConfig struct {
+	LowBalanceThreshold float64       `mapstructure:"low_balance_threshold"`
+	LimitWarningRatio   float64       `mapstructure:"limit_warning_ratio"`
+	ExpiryWarningDays   int           `mapstructure:"expiry_warning_days"`
+	ExpiryCheckInterval time.Duration `mapstructure:"expiry_check_interval"`
+	WebhookTimeout      time.Duration `mapstructure:"webhook_timeout"`
+	SMTP                SMTPConfig    `mapstructure:"smtp"`
+}
+
+// SMTPConfig is the mail server notification emails are sent through. Email
+// notifications are disabled when Host is empty.
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// StreamConfig holds the real-time event stream settings. An idle stream gets
+// a keep-alive comment every HeartbeatInterval, and a client resuming with a
+// Last-Event-ID is sent at most ReplayLimit missed events.
+type StreamConfig struct {
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	ReplayLimit       int           `mapstructure:"replay_limit"`
+}
+
+// StoreConfig holds the merchant-facing API settings. A signed request is
+// accepted up to SignatureTolerance from its timestamp, and a rotated API key
+// keeps working for KeyRotationGrace.
+type StoreConfig struct {
+	SignatureTolerance time.Duration `mapstructure:"signature_tolerance"`
+	KeyRotationGrace   time.Duration `mapstructure:"key_rotation_grace"`
+}
+
+// GatewayConfig holds the ISO 8583 gateway settings. When Enabled, the
+// gateway listens on Host:Port and closes connections idle for IdleTimeout.
+// Amounts are in minor units of CurrencyCode, an ISO 4217 numeric code with
+// CurrencyExponent decimal places.
+type GatewayConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Host             string        `mapstructure:"host"`
+	Port             int           `mapstructure:"port"`
+	IdleTimeout      time.Duration `mapstructure:"idle_timeout"`
+	CurrencyCode     string        `mapstructure:"currency_code"`
+	CurrencyExponent int           `mapstructure:"currency_exponent"`
+}
+
 func LoadConfig() (*Config, error) {
 	env := os.Getenv("APP_ENVIRONMENT")
 	if env == "" {
@@ -228,6 +356,72 @@ func bindEnvironmentVariables(v *viper.Viper) {
 	v.BindEnv("redis.write_timeout", "REDIS_WRITE_TIMEOUT")
 	v.BindEnv("redis.pool_size", "REDIS_POOL_SIZE")
 
+	// Import bindings
+	v.BindEnv("import.dir", "IMPORT_DIR")
+	v.BindEnv("import.chunk_size", "IMPORT_CHUNK_SIZE")
+	v.BindEnv("import.workers", "IMPORT_WORKERS")
+	v.BindEnv("import.lease_duration", "IMPORT_LEASE_DURATION")
+	v.BindEnv("import.poll_interval", "IMPORT_POLL_INTERVAL")
+
+	// Issuance bindings
+	v.BindEnv("issuance.scheduler_interval", "ISSUANCE_SCHEDULER_INTERVAL")
+
+	// Vault bindings
+	v.BindEnv("vault.master_key", "VAULT_MASTER_KEY")
+	v.BindEnv("vault.key_id", "VAULT_KEY_ID")
+
+	// Card bindings
+	v.BindEnv("card.cvv_max_attempts", "CARD_CVV_MAX_ATTEMPTS")
+	v.BindEnv("card.pin_max_attempts", "CARD_PIN_MAX_ATTEMPTS")
+
+	// Risk bindings
+	v.BindEnv("risk.review_score", "RISK_REVIEW_SCORE")
+	v.BindEnv("risk.decline_score", "RISK_DECLINE_SCORE")
+	v.BindEnv("risk.block_score", "RISK_BLOCK_SCORE")
+
+	// Webhook bindings
+	v.BindEnv("webhook.dispatch_interval", "WEBHOOK_DISPATCH_INTERVAL")
+	v.BindEnv("webhook.timeout", "WEBHOOK_TIMEOUT")
+	v.BindEnv("webhook.max_attempts", "WEBHOOK_MAX_ATTEMPTS")
+	v.BindEnv("webhook.retry_backoff", "WEBHOOK_RETRY_BACKOFF")
+	v.BindEnv("webhook.max_backoff", "WEBHOOK_MAX_BACKOFF")
+	v.BindEnv("webhook.allow_private_targets", "WEBHOOK_ALLOW_PRIVATE_TARGETS")
+
+	// Outbox bindings
+	v.BindEnv("outbox.dispatch_interval", "OUTBOX_DISPATCH_INTERVAL")
+	v.BindEnv("outbox.handler_timeout", "OUTBOX_HANDLER_TIMEOUT")
+	v.BindEnv("outbox.max_attempts", "OUTBOX_MAX_ATTEMPTS")
+	v.BindEnv("outbox.retry_backoff", "OUTBOX_RETRY_BACKOFF")
+	v.BindEnv("outbox.max_backoff", "OUTBOX_MAX_BACKOFF")
+
+	// Notification bindings
+	v.BindEnv("notification.low_balance_threshold", "NOTIFICATION_LOW_BALANCE_THRESHOLD")
+	v.BindEnv("notification.limit_warning_ratio", "NOTIFICATION_LIMIT_WARNING_RATIO")
+	v.BindEnv("notification.expiry_warning_days", "NOTIFICATION_EXPIRY_WARNING_DAYS")
+	v.BindEnv("notification.expiry_check_interval", "NOTIFICATION_EXPIRY_CHECK_INTERVAL")
+	v.BindEnv("notification.webhook_timeout", "NOTIFICATION_WEBHOOK_TIMEOUT")
+	v.BindEnv("notification.smtp.host", "SMTP_HOST")
+	v.BindEnv("notification.smtp.port", "SMTP_PORT")
+	v.BindEnv("notification.smtp.username", "SMTP_USERNAME")
+	v.BindEnv("notification.smtp.password", "SMTP_PASSWORD")
+	v.BindEnv("notification.smtp.from", "SMTP_FROM")
+
+	// Stream bindings
+	v.BindEnv("stream.heartbeat_interval", "STREAM_HEARTBEAT_INTERVAL")
+	v.BindEnv("stream.replay_limit", "STREAM_REPLAY_LIMIT")
+
+	// Store bindings
+	v.BindEnv("store.signature_tolerance", "STORE_SIGNATURE_TOLERANCE")
+	v.BindEnv("store.key_rotation_grace", "STORE_KEY_ROTATION_GRACE")
+
+	// Gateway bindings
+	v.BindEnv("gateway.enabled", "GATEWAY_ENABLED")
+	v.BindEnv("gateway.host", "GATEWAY_HOST")
+	v.BindEnv("gateway.port", "GATEWAY_PORT")
+	v.BindEnv("gateway.idle_timeout", "GATEWAY_IDLE_TIMEOUT")
+	v.BindEnv("gateway.currency_code", "GATEWAY_CURRENCY_CODE")
+	v.BindEnv("gateway.currency_exponent", "GATEWAY_CURRENCY_EXPONENT")
+
 	// App bindings
 	v.BindEnv("app.name", "APP_NAME")
 	v.BindEnv("app.debug", "APP_DEBUG")
@@ -306,6 +500,136 @@ func setDefaults(config *Config) {
 		config.Redis.PoolSize = 10
 	}
 
+	// Import defaults
+	if config.Import.Dir == "" {
+		config.Import.Dir = filepath.Join(os.TempDir(), "ccards-imports")
+	}
+	if config.Import.ChunkSize == 0 {
+		config.Import.ChunkSize = 500
+	}
+	if config.Import.Workers == 0 {
+		config.Import.Workers = 2
+	}
+	if config.Import.LeaseDuration == 0 {
+		config.Import.LeaseDuration = time.Minute
+	}
+	if config.Import.PollInterval == 0 {
+		config.Import.PollInterval = 10 * time.Second
+	}
+
+	// Issuance defaults
+	if config.Issuance.SchedulerInterval == 0 {
+		config.Issuance.SchedulerInterval = time.Minute
+	}
+
+	// Vault defaults
+	if config.Vault.KeyID == "" {
+		config.Vault.KeyID = "default"
+	}
+
+	// Card defaults
+	if config.Card.CVVMaxAttempts == 0 {
+		config.Card.CVVMaxAttempts = 3
+	}
+	if config.Card.PINMaxAttempts == 0 {
+		config.Card.PINMaxAttempts = 3
+	}
+
+	// Risk defaults
+	if config.Risk.ReviewScore == 0 {
+		config.Risk.ReviewScore = 40
+	}
+	if config.Risk.DeclineScore == 0 {
+		config.Risk.DeclineScore = 70
+	}
+	if config.Risk.BlockScore == 0 {
+		config.Risk.BlockScore = 90
+	}
+
+	// Webhook defaults
+	if config.Webhook.DispatchInterval == 0 {
+		config.Webhook.DispatchInterval = 5 * time.Second
+	}
+	if config.Webhook.Timeout == 0 {
+		config.Webhook.Timeout = 10 * time.Second
+	}
+	if config.Webhook.MaxAttempts == 0 {
+		config.Webhook.MaxAttempts = 8
+	}
+	if config.Webhook.RetryBackoff == 0 {
+		config.Webhook.RetryBackoff = 30 * time.Second
+	}
+	if config.Webhook.MaxBackoff == 0 {
+		config.Webhook.MaxBackoff = 6 * time.Hour
+	}
+
+	// Outbox defaults
+	if config.Outbox.DispatchInterval == 0 {
+		config.Outbox.DispatchInterval = time.Second
+	}
+	if config.Outbox.HandlerTimeout == 0 {
+		config.Outbox.HandlerTimeout = 30 * time.Second
+	}
+	if config.Outbox.MaxAttempts == 0 {
+		config.Outbox.MaxAttempts = 10
+	}
+	if config.Outbox.RetryBackoff == 0 {
+		config.Outbox.RetryBackoff = 5 * time.Second
+	}
+	if config.Outbox.MaxBackoff == 0 {
+		config.Outbox.MaxBackoff = time.Hour
+	}
+
+	// Notification defaults
+	if config.Notification.LowBalanceThreshold == 0 {
+		config.Notification.LowBalanceThreshold = 100
+	}
+	if config.Notification.LimitWarningRatio == 0 {
+		config.Notification.LimitWarningRatio = 0.8
+	}
+	if config.Notification.ExpiryWarningDays == 0 {
+		config.Notification.ExpiryWarningDays = 30
+	}
+	if config.Notification.ExpiryCheckInterval == 0 {
+		config.Notification.ExpiryCheckInterval = time.Hour
+	}
+	if config.Notification.WebhookTimeout == 0 {
+		config.Notification.WebhookTimeout = 10 * time.Second
+	}
+	if config.Notification.SMTP.Port == 0 {
+		config.Notification.SMTP.Port = 25
+	}
+	if config.Notification.SMTP.From == "" {
+		config.Notification.SMTP.From = "notifications@ccards.local"
+	}
+
+	// Stream defaults
+	if config.Stream.HeartbeatInterval == 0 {
+		config.Stream.HeartbeatInterval = 15 * time.Second
+	}
+	if config.Stream.ReplayLimit == 0 {
+		config.Stream.ReplayLimit = 1000
+	}
+
+	// Store defaults
+	if config.Store.SignatureTolerance == 0 {
+		config.Store.SignatureTolerance = 5 * time.Minute
+	}
+	if config.Store.KeyRotationGrace == 0 {
+		config.Store.KeyRotationGrace = 24 * time.Hour
+	}
+
+	// Gateway defaults
+	if config.Gateway.Port == 0 {
+		config.Gateway.Port = 8583
+	}
+	if config.Gateway.IdleTimeout == 0 {
+		config.Gateway.IdleTimeout = 5 * time.Minute
+	}
+	if config.Gateway.CurrencyCode == "" {
+		config.Gateway.CurrencyCode = "392"
+	}
+
 	// App defaults
 	if config.App.Name == "" {
 		config.App.Name = "ccards"
@@ -328,6 +652,10 @@ func (c *ServerConfig) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+func (c *GatewayConfig) GetAddress() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
 func (c *RedisConfig) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
@@ -0,0 +1,139 @@
+// Package endpoint guards requests to URLs supplied by companies, such as
+// webhook endpoints and notification recipients. Only https URLs on public
+// addresses are allowed, both when a URL is saved and on every connection,
+// so an endpoint cannot be used to reach the service's own network.
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	apperrors "ccards/pkg/errors"
+)
+
+// blockedPrefixes are ranges that are not public although netip does not
+// flag them: "this network", shared address space, IETF protocol
+// assignments, benchmarking, reserved, and IPv4 addresses behind NAT64.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// internalSuffixes are host name suffixes that only resolve inside a private
+// network, such as metadata.google.internal.
+var internalSuffixes = []string{".localhost", ".local", ".localdomain", ".internal", ".intranet", ".lan", ".home.arpa"}
+
+// publicAddr reports whether addr can be reached on the public internet.
+// Loopback, private, link-local (169.254.169.254 among them), multicast and
+// reserved addresses are not.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks that events may be delivered to rawURL: it has to
+// use https and its host has to be a public address or a name that resolves
+// only to public addresses. Errors wrap ErrWebhookURL.
+func CheckURL(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", apperrors.ErrWebhookURL, err)
+	}
+	if target.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be https", apperrors.ErrWebhookURL)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("%w: host is missing", apperrors.ErrWebhookURL)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(addr) {
+			return fmt.Errorf("%w: %s is not a public address", apperrors.ErrWebhookURL, host)
+		}
+		return nil
+	}
+
+	if host == "localhost" || !strings.Contains(host, ".") {
+		return fmt.Errorf("%w: %s is an internal host name", apperrors.ErrWebhookURL, host)
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return fmt.Errorf("%w: %s is an internal host name", apperrors.ErrWebhookURL, host)
+		}
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("%w: %s does not resolve", apperrors.ErrWebhookURL, host)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s, which is not a public address", apperrors.ErrWebhookURL, host, addr)
+		}
+	}
+
+	return nil
+}
+
+// NewClient returns the HTTP client used to deliver events to
+// endpoints outside the service. Every connection, redirects included, is
+// checked when it is dialled, so a name that resolved to a public address at
+// registration cannot be pointed at an internal one later. Requests must use
+// https and never go through a proxy. allowPrivate lifts both restrictions
+// for local development and tests.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	if allowPrivate {
+		return &http.Client{Timeout: timeout, Transport: transport}
+	}
+
+	dialer.Control = func(network, address string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return fmt.Errorf("%w: %v", apperrors.ErrWebhookURL, err)
+		}
+		if !publicAddr(addrPort.Addr()) {
+			return fmt.Errorf("%w: %s is not a public address", apperrors.ErrWebhookURL, addrPort.Addr())
+		}
+		return nil
+	}
+
+	return &http.Client{Timeout: timeout, Transport: httpsOnly{transport}}
+}
+
+// httpsOnly refuses plain http requests, including redirects to them.
+type httpsOnly struct {
+	next http.RoundTripper
+}
+
+func (t httpsOnly) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme must be https", apperrors.ErrWebhookURL)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -5,6 +5,35 @@ import "errors"
 var (
 	ErrCompanyExists       = errors.New("company already exists")
 	ErrUserExists          = errors.New("user already exists")
+	ErrEmployeeExists      = errors.New("employee already exists")
+	ErrInvalidManager      = errors.New("invalid manager")
+	ErrImportNotResumable  = errors.New("import job is not resumable")
+	ErrOffboardingConflict = errors.New("offboarding already started with a different card action or reason")
+	ErrInvalidPolicy       = errors.New("invalid issuance policy")
+	ErrTemplateExists      = errors.New("policy template already exists")
+	ErrMerchantExists      = errors.New("merchant already exists")
+	ErrUnknownMCC          = errors.New("unknown merchant category code")
+	ErrSecretUnavailable   = errors.New("card secret unavailable")
+	ErrPINNotSupported     = errors.New("PIN is only supported for physical cards")
+	ErrPINAlreadySet       = errors.New("PIN already set")
+	ErrPINNotSet           = errors.New("PIN not set")
+	ErrPINLocked           = errors.New("PIN locked")
+	ErrIncorrectPIN        = errors.New("incorrect PIN")
+	ErrWeakPIN             = errors.New("PIN is too easy to guess")
+	ErrReviewClosed        = errors.New("review already resolved")
+	ErrDisputeExists       = errors.New("transaction already disputed")
+	ErrNotDisputable       = errors.New("transaction cannot be disputed")
+	ErrDisputeAmount       = errors.New("invalid dispute amount")
+	ErrDisputeTransition   = errors.New("invalid dispute status change")
+	ErrDisputeDeadline     = errors.New("dispute deadline has passed")
+	ErrInvalidTemplate     = errors.New("invalid notification template")
+	ErrStoreExists         = errors.New("store already exists")
+	ErrAPIKeyInUse         = errors.New("API key signs the current request")
+	ErrPaymentExists       = errors.New("payment reference already used")
+	ErrPaymentTransition   = errors.New("invalid payment status change")
+	ErrPaymentAmount       = errors.New("invalid payment amount")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrWebhookURL          = errors.New("webhook URL must use https and point to a public address")
 	ErrInvalidCredentials  = errors.New("invalid credentials")
 	ErrCompanySuspended    = errors.New("company account suspended")
 	ErrUnauthorized        = errors.New("unauthorized")
@@ -13,3 +42,8 @@ var (
 	ErrBadRequest          = errors.New("bad request")
 	ErrInternalServerError = errors.New("internal server error")
 )
+
+// Is reports whether any error in err's chain matches target.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
@@ -0,0 +1,313 @@
+// Package iso8583 reads and writes the ISO 8583 (1987) messages card
+// processors send for authorizations and reversals. The MTI and fields are
+// ASCII and the bitmaps binary; on a connection every message is preceded by
+// its length as two big-endian bytes. Only the fields listed in Fields are
+// supported.
+package iso8583
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Message type indicators.
+const (
+	MTIAuthorizationRequest    = "0100"
+	MTIAuthorizationResponse   = "0110"
+	MTIFinancialRequest        = "0200"
+	MTIFinancialResponse       = "0210"
+	MTIFinancialAdvice         = "0220"
+	MTIFinancialAdviceResponse = "0230"
+	MTIReversalRequest         = "0400"
+	MTIReversalResponse        = "0410"
+)
+
+// Field numbers.
+const (
+	FieldPAN                    = 2
+	FieldProcessingCode         = 3
+	FieldAmount                 = 4
+	FieldTransmissionDateTime   = 7
+	FieldSTAN                   = 11
+	FieldLocalTime              = 12
+	FieldLocalDate              = 13
+	FieldExpiryDate             = 14
+	FieldMerchantType           = 18
+	FieldPOSEntryMode           = 22
+	FieldPOSConditionCode       = 25
+	FieldAcquirerID             = 32
+	FieldRRN                    = 37
+	FieldAuthorizationCode      = 38
+	FieldResponseCode           = 39
+	FieldTerminalID             = 41
+	FieldCardAcceptorID         = 42
+	FieldCardAcceptorNameLocale = 43
+	FieldCurrencyCode           = 49
+	FieldOriginalDataElements   = 90
+)
+
+// Response codes.
+const (
+	ResponseApproved           = "00"
+	ResponseDoNotHonor         = "05"
+	ResponseInvalidTransaction = "12"
+	ResponseInvalidAmount      = "13"
+	ResponseInvalidCard        = "14"
+	ResponseRecordNotFound     = "25"
+	ResponseFormatError        = "30"
+	ResponseInsufficientFunds  = "51"
+	ResponseExpiredCard        = "54"
+	ResponseNotPermitted       = "57"
+	ResponseSuspectedFraud     = "59"
+	ResponseExceedsLimit       = "61"
+	ResponseRestrictedCard     = "62"
+	ResponseExceedsFrequency   = "65"
+	ResponsePINTriesExceeded   = "75"
+	ResponseDuplicate          = "94"
+	ResponseSystemError        = "96"
+)
+
+// MaxMessageLength is the longest message the two-byte length header allows.
+const MaxMessageLength = 1<<16 - 1
+
+var ErrFormat = errors.New("iso8583: malformed message")
+
+// Field describes how a field is encoded. Variable fields are preceded by
+// their length in LengthDigits ASCII digits; fixed fields are exactly Length
+// characters long.
+type Field struct {
+	Length       int
+	LengthDigits int
+	Numeric      bool
+}
+
+// Fields are the fields this package can read and write.
+var Fields = map[int]Field{
+	FieldPAN:                    {Length: 19, LengthDigits: 2, Numeric: true},
+	FieldProcessingCode:         {Length: 6, Numeric: true},
+	FieldAmount:                 {Length: 12, Numeric: true},
+	FieldTransmissionDateTime:   {Length: 10, Numeric: true},
+	FieldSTAN:                   {Length: 6, Numeric: true},
+	FieldLocalTime:              {Length: 6, Numeric: true},
+	FieldLocalDate:              {Length: 4, Numeric: true},
+	FieldExpiryDate:             {Length: 4, Numeric: true},
+	FieldMerchantType:           {Length: 4, Numeric: true},
+	FieldPOSEntryMode:           {Length: 3, Numeric: true},
+	FieldPOSConditionCode:       {Length: 2, Numeric: true},
+	FieldAcquirerID:             {Length: 11, LengthDigits: 2, Numeric: true},
+	FieldRRN:                    {Length: 12},
+	FieldAuthorizationCode:      {Length: 6},
+	FieldResponseCode:           {Length: 2},
+	FieldTerminalID:             {Length: 8},
+	FieldCardAcceptorID:         {Length: 15},
+	FieldCardAcceptorNameLocale: {Length: 40},
+	FieldCurrencyCode:           {Length: 3, Numeric: true},
+	FieldOriginalDataElements:   {Length: 42, Numeric: true},
+}
+
+// Message is an ISO 8583 message. Fixed fields are kept as they were sent,
+// padding included.
+type Message struct {
+	MTI    string
+	fields map[int]string
+}
+
+func NewMessage(mti string) *Message {
+	return &Message{
+		MTI:    mti,
+		fields: make(map[int]string),
+	}
+}
+
+// Set sets a field. Numeric fixed fields shorter than their length are
+// padded with leading zeros and other fixed fields with trailing spaces when
+// the message is packed.
+func (m *Message) Set(field int, value string) {
+	m.fields[field] = value
+}
+
+func (m *Message) Get(field int) string {
+	return m.fields[field]
+}
+
+func (m *Message) Has(field int) bool {
+	_, ok := m.fields[field]
+	return ok
+}
+
+// Copy copies the given fields that are present in from.
+func (m *Message) Copy(from *Message, fields ...int) {
+	for _, field := range fields {
+		if value, ok := from.fields[field]; ok {
+			m.fields[field] = value
+		}
+	}
+}
+
+// ResponseMTI returns the MTI answering a request, "0110" for "0100".
+func ResponseMTI(mti string) string {
+	if len(mti) != 4 {
+		return mti
+	}
+	return mti[:2] + string(mti[2]+1) + mti[3:]
+}
+
+// Pack encodes the message without its length header.
+func (m *Message) Pack() ([]byte, error) {
+	if len(m.MTI) != 4 || !isNumeric(m.MTI) {
+		return nil, fmt.Errorf("%w: invalid MTI %q", ErrFormat, m.MTI)
+	}
+
+	numbers := make([]int, 0, len(m.fields))
+	for field := range m.fields {
+		numbers = append(numbers, field)
+	}
+	sort.Ints(numbers)
+
+	bitmap := make([]byte, 8)
+	if len(numbers) > 0 && numbers[len(numbers)-1] > 64 {
+		bitmap = make([]byte, 16)
+		bitmap[0] |= 0x80
+	}
+
+	var body strings.Builder
+	for _, field := range numbers {
+		spec, ok := Fields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported field %d", ErrFormat, field)
+		}
+
+		value, err := encodeField(field, spec, m.fields[field])
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString(value)
+		bitmap[(field-1)/8] |= 0x80 >> ((field - 1) % 8)
+	}
+
+	data := append([]byte(m.MTI), bitmap...)
+	return append(data, body.String()...), nil
+}
+
+func encodeField(field int, spec Field, value string) (string, error) {
+	if spec.Numeric && !isNumeric(value) {
+		return "", fmt.Errorf("%w: field %d must be numeric", ErrFormat, field)
+	}
+	if len(value) > spec.Length {
+		return "", fmt.Errorf("%w: field %d is longer than %d", ErrFormat, field, spec.Length)
+	}
+
+	if spec.LengthDigits > 0 {
+		return fmt.Sprintf("%0*d", spec.LengthDigits, len(value)) + value, nil
+	}
+	if spec.Numeric {
+		return strings.Repeat("0", spec.Length-len(value)) + value, nil
+	}
+	return value + strings.Repeat(" ", spec.Length-len(value)), nil
+}
+
+// Unpack decodes a message without its length header.
+func Unpack(data []byte) (*Message, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("%w: message too short", ErrFormat)
+	}
+
+	m := NewMessage(string(data[:4]))
+	if !isNumeric(m.MTI) {
+		return nil, fmt.Errorf("%w: invalid MTI %q", ErrFormat, m.MTI)
+	}
+
+	bitmap := data[4:12]
+	pos := 12
+	if bitmap[0]&0x80 != 0 {
+		if len(data) < 20 {
+			return nil, fmt.Errorf("%w: secondary bitmap missing", ErrFormat)
+		}
+		bitmap = data[4:20]
+		pos = 20
+	}
+
+	for field := 2; field <= len(bitmap)*8; field++ {
+		if bitmap[(field-1)/8]&(0x80>>((field-1)%8)) == 0 {
+			continue
+		}
+
+		spec, ok := Fields[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported field %d", ErrFormat, field)
+		}
+
+		length := spec.Length
+		if spec.LengthDigits > 0 {
+			if pos+spec.LengthDigits > len(data) {
+				return nil, fmt.Errorf("%w: field %d truncated", ErrFormat, field)
+			}
+			n, err := strconv.Atoi(string(data[pos : pos+spec.LengthDigits]))
+			if err != nil || n > spec.Length {
+				return nil, fmt.Errorf("%w: invalid length of field %d", ErrFormat, field)
+			}
+			pos += spec.LengthDigits
+			length = n
+		}
+
+		if pos+length > len(data) {
+			return nil, fmt.Errorf("%w: field %d truncated", ErrFormat, field)
+		}
+		value := string(data[pos : pos+length])
+		if spec.Numeric && !isNumeric(value) {
+			return nil, fmt.Errorf("%w: field %d must be numeric", ErrFormat, field)
+		}
+		m.fields[field] = value
+		pos += length
+	}
+
+	if pos != len(data) {
+		return nil, fmt.Errorf("%w: %d unexpected bytes after the last field", ErrFormat, len(data)-pos)
+	}
+
+	return m, nil
+}
+
+// ReadFrame reads one length-prefixed message from r.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint16(header[:]))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// WriteMessage packs the message and writes it to w with its length header.
+func WriteMessage(w io.Writer, m *Message) error {
+	data, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxMessageLength {
+		return fmt.Errorf("%w: message too long", ErrFormat)
+	}
+
+	frame := make([]byte, 2, 2+len(data))
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	_, err = w.Write(append(frame, data...))
+	return err
+}
+
+func isNumeric(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
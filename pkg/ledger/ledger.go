@@ -0,0 +1,166 @@
+// Package ledger keeps card balances consistent across the ways a card is
+// paid with. Every balance check and debit uses AvailableBalance, so money
+// held for a gateway authorization cannot be spent again. Voids, refunds and
+// dispute credits all go through it in the caller's database transaction, so
+// together they never credit more than the purchase amount, and money for a
+// card that can no longer spend it goes to the company balance instead.
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/models"
+)
+
+// ErrCardNotFound is returned by AvailableBalance for an unknown card.
+var ErrCardNotFound = errors.New("card not found")
+
+// Queryer runs a query on a database or in a transaction.
+type Queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// HeldAmount returns what is held on the card for gateway authorizations not
+// yet completed.
+func HeldAmount(ctx context.Context, q Queryer, cardID uuid.UUID) (float64, error) {
+	var held float64
+	err := q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM gateway_authorizations
+		WHERE card_id = $1 AND status = $2`,
+		cardID, models.GatewayAuthorizationStatusAuthorized,
+	).Scan(&held)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum card holds: %w", err)
+	}
+
+	return held, nil
+}
+
+// AvailableBalance locks the card until the transaction ends and returns its
+// balance less the amounts held on it. It returns ErrCardNotFound when there
+// is no such card.
+func AvailableBalance(ctx context.Context, tx *sql.Tx, cardID uuid.UUID) (float64, error) {
+	var balance float64
+	err := tx.QueryRowContext(ctx, `SELECT balance FROM cards WHERE id = $1 FOR UPDATE`, cardID).Scan(&balance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrCardNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock card for update: %w", err)
+	}
+
+	held, err := HeldAmount(ctx, tx, cardID)
+	if err != nil {
+		return 0, err
+	}
+
+	return Round(balance - held), nil
+}
+
+// Purchase is a purchase locked for crediting.
+type Purchase struct {
+	ID        uuid.UUID
+	CardID    uuid.UUID
+	CompanyID uuid.UUID
+	Amount    float64
+	Refunded  float64
+}
+
+// Refundable returns what can still be credited for the purchase.
+func (p *Purchase) Refundable() float64 {
+	return Round(p.Amount - p.Refunded)
+}
+
+// LockPurchase locks a purchase until the transaction ends. It returns nil
+// when there is no such transaction.
+func LockPurchase(ctx context.Context, tx *sql.Tx, transactionID uuid.UUID) (*Purchase, error) {
+	purchase := &Purchase{}
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, card_id, company_id, amount, refunded_amount
+		FROM transactions
+		WHERE id = $1
+		FOR UPDATE`,
+		transactionID,
+	).Scan(&purchase.ID, &purchase.CardID, &purchase.CompanyID, &purchase.Amount, &purchase.Refunded)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock purchase: %w", err)
+	}
+
+	return purchase, nil
+}
+
+// Credit returns amount of the purchase to its card and counts it as
+// refunded. Callers check Refundable first and answer with their own error;
+// crediting more fails. It reports whether the money went to the company
+// balance because the card can no longer hold it.
+func Credit(ctx context.Context, tx *sql.Tx, purchase *Purchase, amount float64) (bool, error) {
+	amount = Round(amount)
+	if amount > purchase.Refundable() {
+		return false, fmt.Errorf("credit of %.2f exceeds the %.2f left on purchase %s", amount, purchase.Refundable(), purchase.ID)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE transactions SET refunded_amount = refunded_amount + $2 WHERE id = $1`, purchase.ID, amount); err != nil {
+		return false, fmt.Errorf("failed to update refunded amount: %w", err)
+	}
+	purchase.Refunded = Round(purchase.Refunded + amount)
+
+	return CreditCard(ctx, tx, purchase.CardID, purchase.CompanyID, amount)
+}
+
+// Uncredit takes back amount credited for the purchase, as when a dispute is
+// lost, so it can be credited again. Moving the money back is up to the
+// caller.
+func Uncredit(ctx context.Context, tx *sql.Tx, purchase *Purchase, amount float64) error {
+	amount = math.Min(Round(amount), purchase.Refunded)
+	if _, err := tx.ExecContext(ctx, `UPDATE transactions SET refunded_amount = refunded_amount - $2 WHERE id = $1`, purchase.ID, amount); err != nil {
+		return fmt.Errorf("failed to update refunded amount: %w", err)
+	}
+	purchase.Refunded = Round(purchase.Refunded - amount)
+
+	return nil
+}
+
+// CreditCard adds amount to the card's balance. Cancelled cards and cards
+// whose balance was swept by an offboarding cannot spend it, so it goes to
+// the company balance instead; CreditCard reports whether it did.
+func CreditCard(ctx context.Context, tx *sql.Tx, cardID, companyID uuid.UUID, amount float64) (bool, error) {
+	var status string
+	var offboarded bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT status, EXISTS (SELECT 1 FROM employee_offboarding_cards WHERE card_id = $1)
+		FROM cards
+		WHERE id = $1
+		FOR UPDATE`,
+		cardID,
+	).Scan(&status, &offboarded)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock card for update: %w", err)
+	}
+
+	if status == models.CardStatusCancelled || offboarded {
+		if _, err := tx.ExecContext(ctx, `UPDATE companies SET balance = balance + $2 WHERE id = $1`, companyID, amount); err != nil {
+			return false, fmt.Errorf("failed to credit company: %w", err)
+		}
+		return true, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE cards SET balance = balance + $2 WHERE id = $1`, cardID, amount); err != nil {
+		return false, fmt.Errorf("failed to credit card: %w", err)
+	}
+	return false, nil
+}
+
+// Round rounds an amount to cents, so amounts compare as stored.
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
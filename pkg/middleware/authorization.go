@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/models"
+)
+
+// authorizationRequestKey holds the payment the payment middlewares work on.
+const authorizationRequestKey = "authorization_request"
+
+// NewAuthorizationRequest turns a payment request into the request the
+// authorization checks work on, with the channel filled in.
+func NewAuthorizationRequest(txReq *request.Transaction) *authorization.Request {
+	return &authorization.Request{
+		CompanyID:        txReq.CompanyID,
+		CardID:           txReq.CardID,
+		Token:            txReq.Token,
+		Amount:           txReq.Amount,
+		MerchantName:     txReq.MerchantName,
+		MerchantCategory: txReq.MerchantCategory,
+		MCC:              txReq.MCC,
+		MerchantID:       txReq.MerchantID,
+		Channel:          paymentChannel(txReq.Channel),
+		TerminalID:       txReq.TerminalID,
+		CVV:              txReq.CVV,
+		PIN:              txReq.PIN,
+	}
+}
+
+// paymentChannel returns how the card was presented. Payments that do not say
+// are treated as card-not-present.
+func paymentChannel(channel string) string {
+	if channel == "" {
+		return models.TransactionChannelOnline
+	}
+	return channel
+}
+
+// SetAuthorizationRequest stores the payment for the payment middlewares and
+// the handler after them.
+func SetAuthorizationRequest(c *gin.Context, req *authorization.Request) {
+	c.Set(authorizationRequestKey, req)
+}
+
+// GetAuthorizationRequest returns the payment stored by ValidCard or
+// StoreCard, as the checks so far have filled it in.
+func GetAuthorizationRequest(c *gin.Context) (*authorization.Request, error) {
+	reqInterface, exists := c.Get(authorizationRequestKey)
+	if !exists {
+		return nil, fmt.Errorf("authorization request not found in context")
+	}
+
+	req, ok := reqInterface.(*authorization.Request)
+	if !ok {
+		return nil, fmt.Errorf("invalid authorization request type in context")
+	}
+
+	return req, nil
+}
+
+// runCheck adapts an authorization check to a payment middleware. Checks
+// after ValidCard need the card loaded.
+func runCheck(check authorization.Check, fallback string, needsCard bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := requestFromContext(c, needsCard)
+		if !ok {
+			return
+		}
+
+		if err := check.Check(c.Request.Context(), req); err != nil {
+			abortWithDecline(c, err, fallback)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Authorize runs checks in order on the payment stored by ValidCard or
+// StoreCard and answers the first decline. Checks that record outcomes are
+// told the outcome once the rest of the chain has answered the payment: a
+// 2xx response as approved, and a decline by a check or the handler as that
+// decline.
+func Authorize(checks ...authorization.Check) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := requestFromContext(c, true)
+		if !ok {
+			return
+		}
+
+		var recorders []authorization.Recorder
+		declined := false
+		for _, check := range checks {
+			if recorder, ok := check.(authorization.Recorder); ok {
+				recorders = append(recorders, recorder)
+			}
+
+			if err := check.Check(c.Request.Context(), req); err != nil {
+				abortWithDecline(c, err, "Failed to authorize payment")
+				declined = true
+				break
+			}
+		}
+		if !declined {
+			c.Next()
+		}
+
+		status := c.Writer.Status()
+		for _, recorder := range recorders {
+			switch {
+			case status >= 200 && status < 300:
+				recorder.Record(c.Request.Context(), req, nil)
+			case status == http.StatusUnauthorized || status == http.StatusPaymentRequired || status == http.StatusForbidden:
+				recorder.Record(c.Request.Context(), req, responseDecline(c, status))
+			}
+		}
+	}
+}
+
+// requestFromContext returns the payment, or aborts when the middlewares
+// before have not stored one.
+func requestFromContext(c *gin.Context, needsCard bool) (*authorization.Request, bool) {
+	reqInterface, exists := c.Get(authorizationRequestKey)
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction request not found"})
+		c.Abort()
+		return nil, false
+	}
+
+	req, ok := reqInterface.(*authorization.Request)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid transaction request"})
+		c.Abort()
+		return nil, false
+	}
+
+	if needsCard && req.Card == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Card information not found"})
+		c.Abort()
+		return nil, false
+	}
+
+	return req, true
+}
+
+// declineStatus is the HTTP status a decline is answered with.
+func declineStatus(decline *authorization.Decline) int {
+	switch decline.Reason {
+	case authorization.ReasonCardNotFound:
+		return http.StatusNotFound
+	case authorization.ReasonUnknownMerchant, authorization.ReasonPINNotSupported:
+		return http.StatusBadRequest
+	case authorization.ReasonInvalidCVV, authorization.ReasonInvalidPIN:
+		return http.StatusUnauthorized
+	case authorization.ReasonInsufficientFunds:
+		return http.StatusPaymentRequired
+	default:
+		return http.StatusForbidden
+	}
+}
+
+// abortWithDecline answers a failed check. Declines get their message and
+// details with the status for their reason and are kept in the context's
+// errors; any other error is answered with fallback as a server error.
+func abortWithDecline(c *gin.Context, err error, fallback string) {
+	decline, ok := authorization.AsDecline(err)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+		c.Abort()
+		return
+	}
+
+	body := gin.H{"error": decline.Message}
+	for key, value := range decline.Details {
+		body[key] = value
+	}
+	c.JSON(declineStatus(decline), body)
+	c.Error(decline)
+	c.Abort()
+}
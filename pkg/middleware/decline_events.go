@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"ccards/pkg/authorization"
+)
+
+// DeclineEvents records a payment.declined event for every payment the rest
+// of the chain declines, whether ValidCard, a check or the handler declined
+// it. It goes in front of ValidCard or StoreCard.
+func DeclineEvents(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		req, err := GetAuthorizationRequest(c)
+		if err != nil {
+			return
+		}
+
+		if decline := paymentDecline(c); decline != nil {
+			authorization.PublishDecline(c.Request.Context(), db, req, decline)
+		}
+	}
+}
+
+// paymentDecline returns the decline a payment was answered with, or nil
+// when it was not declined.
+func paymentDecline(c *gin.Context) *authorization.Decline {
+	for i := len(c.Errors) - 1; i >= 0; i-- {
+		if decline, ok := authorization.AsDecline(c.Errors[i].Err); ok {
+			return decline
+		}
+	}
+
+	status := c.Writer.Status()
+	if status == http.StatusUnauthorized || status == http.StatusPaymentRequired || status == http.StatusForbidden {
+		return responseDecline(c, status)
+	}
+	return nil
+}
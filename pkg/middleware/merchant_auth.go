@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+	"ccards/pkg/vault"
+)
+
+// Headers of a signed store API request.
+const (
+	APIKeyHeader    = "X-Api-Key"
+	TimestampHeader = "X-Timestamp"
+	NonceHeader     = "X-Nonce"
+	SignatureHeader = "X-Signature"
+)
+
+// maxNonceLength bounds the nonces remembered in Redis.
+const maxNonceLength = 64
+
+type MerchantAuthMiddleware struct {
+	storeConfig config.StoreConfig
+	db          *sql.DB
+	redisClient *redis.Client
+	vault       *vault.Vault
+}
+
+func NewMerchantAuthMiddleware(cfg *config.Config, db *sql.DB, redisClient *redis.Client, secretVault *vault.Vault) *MerchantAuthMiddleware {
+	return &MerchantAuthMiddleware{
+		storeConfig: cfg.Store,
+		db:          db,
+		redisClient: redisClient,
+		vault:       secretVault,
+	}
+}
+
+// MerchantAuth authenticates requests to the merchant-facing API. Stores sign
+// every request with one of their API keys (see utils.SignRequest). A request
+// is accepted within the signature tolerance of its timestamp and only once
+// per nonce, so a captured request cannot be replayed.
+func (m *MerchantAuthMiddleware) MerchantAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.GetHeader(APIKeyHeader)
+		timestampHeader := c.GetHeader(TimestampHeader)
+		nonce := c.GetHeader(NonceHeader)
+		signature := c.GetHeader(SignatureHeader)
+		if keyID == "" || timestampHeader == "" || nonce == "" || signature == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "X-Api-Key, X-Timestamp, X-Nonce and X-Signature headers are required",
+			})
+			c.Abort()
+			return
+		}
+
+		if len(nonce) > maxNonceLength {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid nonce"})
+			c.Abort()
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid timestamp"})
+			c.Abort()
+			return
+		}
+		if age := time.Since(time.Unix(timestamp, 0)).Abs(); age > m.storeConfig.SignatureTolerance {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Request timestamp is outside the allowed window"})
+			c.Abort()
+			return
+		}
+
+		var storeID, merchantID uuid.UUID
+		var sealed, status string
+		err = m.db.QueryRowContext(c.Request.Context(), `
+			SELECT k.store_id, s.merchant_id, k.secret, s.status
+			FROM store_api_keys k
+			JOIN stores s ON s.id = k.store_id
+			WHERE k.key_id = $1
+			  AND k.revoked_at IS NULL
+			  AND (k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP)`,
+			keyID,
+		).Scan(&storeID, &merchantID, &sealed, &status)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			c.Abort()
+			return
+		}
+
+		secret, err := m.vault.OpenSecret(sealed, utils.APIKeySecretPurpose)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read API key"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !utils.VerifyRequestSignature(secret, timestamp, nonce, c.Request.Method, c.Request.URL.RequestURI(), body, signature) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			c.Abort()
+			return
+		}
+
+		// A nonce is remembered for as long as its request could be accepted.
+		nonceKey := fmt.Sprintf("store_nonce:%s:%s", keyID, nonce)
+		fresh, err := m.redisClient.SetNX(c.Request.Context(), nonceKey, 1, 2*m.storeConfig.SignatureTolerance).Result()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Nonce validation failed"})
+			c.Abort()
+			return
+		}
+		if !fresh {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Nonce has already been used"})
+			c.Abort()
+			return
+		}
+
+		if status != models.StoreStatusActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Store is suspended"})
+			c.Abort()
+			return
+		}
+
+		c.Set("store_id", storeID)
+		c.Set("merchant_id", merchantID)
+		c.Set("api_key_id", keyID)
+		c.Next()
+	}
+}
+
+func GetStoreIDFromContext(c *gin.Context) (uuid.UUID, error) {
+	storeID, exists := c.Get("store_id")
+	if !exists {
+		return uuid.Nil, fmt.Errorf("store ID not found in context")
+	}
+
+	id, ok := storeID.(uuid.UUID)
+	if !ok {
+		return uuid.Nil, fmt.Errorf("invalid store ID type in context")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"ccards/pkg/authorization"
+)
+
+// ResolveMerchant normalizes the merchant of a payment. A registered merchant
+// supplies the name and MCC, an MCC is mapped to its catalogue category, and a
+// free-text category is lower-cased. Later middlewares and the stored
+// transaction see the normalized category in MerchantCategory.
+func ResolveMerchant(db *sql.DB) gin.HandlerFunc {
+	return runCheck(authorization.ResolveMerchant(db), "Database error", false)
+}
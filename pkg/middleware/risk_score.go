@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"ccards/pkg/authorization"
+	"ccards/pkg/config"
+	"ccards/pkg/risk"
+)
+
+type RiskScoreMiddleware struct {
+	check authorization.Check
+}
+
+func NewRiskScoreMiddleware(db *sql.DB, engine *risk.Engine) *RiskScoreMiddleware {
+	return &RiskScoreMiddleware{
+		check: authorization.RiskScore(db, engine),
+	}
+}
+
+// RiskScore scores payments with the default fraud rules, judging night
+// hours in Tokyo time.
+func RiskScore(db *sql.DB, cfg config.RiskConfig) gin.HandlerFunc {
+	return NewRiskScoreMiddleware(db, authorization.NewRiskEngine(cfg)).Handle()
+}
+
+// Handle scores the payment and records the assessment on the payment.
+// Declined payments are stopped here; a block_card decision also blocks the
+// card.
+func (m *RiskScoreMiddleware) Handle() gin.HandlerFunc {
+	return runCheck(m.check, "Failed to assess payment risk", true)
+}
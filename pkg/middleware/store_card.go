@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/vault"
+)
+
+// StoreCard is ValidCard for store payments. It finds the card by the number
+// or the token the store sends, checks the expiry date against it, and turns
+// the request into a payment at the store's merchant, so the rest of the
+// payment middlewares run unchanged.
+func StoreCard(db *sql.DB, cardVault *vault.Vault) gin.HandlerFunc {
+	validCard := authorization.ValidCard(db)
+
+	return func(c *gin.Context) {
+		merchantIDInterface, exists := c.Get("merchant_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized access"})
+			c.Abort()
+			return
+		}
+
+		merchantID, ok := merchantIDInterface.(uuid.UUID)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid merchant ID format"})
+			c.Abort()
+			return
+		}
+
+		var authReq request.StoreAuthorization
+		if err := c.ShouldBindJSON(&authReq); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			c.Abort()
+			return
+		}
+
+		payment := &authorization.Request{
+			Token:       authReq.Token,
+			ExpiryYear:  authReq.ExpiryYear,
+			ExpiryMonth: authReq.ExpiryMonth,
+			Amount:      authReq.Amount,
+			MerchantID:  &merchantID,
+			Channel:     paymentChannel(authReq.Channel),
+			TerminalID:  authReq.TerminalID,
+			CVV:         authReq.CVV,
+			PIN:         authReq.PIN,
+		}
+		if authReq.CardNumber != "" {
+			payment.PANFingerprint = cardVault.Fingerprint(authReq.CardNumber)
+		}
+		SetAuthorizationRequest(c, payment)
+		if err := validCard.Check(c.Request.Context(), payment); err != nil {
+			abortWithDecline(c, err, "Database error")
+			return
+		}
+		payment.CompanyID = payment.Card.CompanyID
+
+		c.Set("store_authorization", &authReq)
+		c.Next()
+	}
+}
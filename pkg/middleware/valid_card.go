@@ -2,16 +2,20 @@ package middleware
 
 import (
 	"ccards/internal/api/request"
-	"ccards/pkg/models"
+	"ccards/pkg/authorization"
 	"database/sql"
-	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// ValidCard reads the payment request of the company and loads its card. The
+// payment is stored for the rest of the payment middlewares, which read it
+// with GetAuthorizationRequest.
 func ValidCard(db *sql.DB) gin.HandlerFunc {
+	validCard := authorization.ValidCard(db)
+
 	return func(c *gin.Context) {
 		companyIDInterface, exists := c.Get("company_id")
 		if !exists {
@@ -40,29 +44,15 @@ func ValidCard(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Fetch card from the database
-		query := `SELECT id, company_id, card_number, card_holder_name, employee_id, employee_email, card_type, status, balance, spending_limit, daily_limit, monthly_limit, expiry_date, cvv_hash, last_four, created_at, updated_at, blocked_at, blocked_reason FROM cards WHERE id = $1`
-		row := db.QueryRowContext(c, query, txReq.CardID)
-
-		var card models.Card
-		err := row.Scan(
-			&card.ID, &card.CompanyID, &card.CardNumber, &card.CardHolderName, &card.EmployeeID, &card.EmployeeEmail,
-			&card.CardType, &card.Status, &card.Balance, &card.SpendingLimit, &card.DailyLimit, &card.MonthlyLimit,
-			&card.ExpiryDate, &card.CVVHash, &card.LastFour, &card.CreatedAt, &card.UpdatedAt, &card.BlockedAt, &card.BlockedReason,
-		)
-
-		if err != nil {
-			if errors.Is(sql.ErrNoRows, err) {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			}
-			c.Abort()
+		// The payment is stored before the card is found, so a decline here
+		// is still recorded by DeclineEvents.
+		authReq := NewAuthorizationRequest(&txReq)
+		SetAuthorizationRequest(c, authReq)
+		if err := validCard.Check(c.Request.Context(), authReq); err != nil {
+			abortWithDecline(c, err, "Database error")
 			return
 		}
 
-		c.Set("card", &card)
-		c.Set("transaction_request", &txReq)
 		c.Next()
 	}
 }
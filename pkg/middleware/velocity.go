@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"ccards/pkg/authorization"
+)
+
+// MaxVelocityWindow is the longest window a velocity control can use.
+const MaxVelocityWindow = authorization.MaxVelocityWindow
+
+type VelocityControl = authorization.VelocityControl
+
+type VelocityMiddleware struct {
+	velocity *authorization.Velocity
+}
+
+func NewVelocityMiddleware(db *sql.DB, redisClient *redis.Client) *VelocityMiddleware {
+	return &VelocityMiddleware{
+		velocity: authorization.NewVelocity(db, redisClient),
+	}
+}
+
+// Velocity counts every card's payments and declines in Redis sliding
+// windows and enforces the card's velocity control. When Redis is not
+// available the counts are taken from Postgres instead.
+func Velocity(db *sql.DB, redisClient *redis.Client) gin.HandlerFunc {
+	m := NewVelocityMiddleware(db, redisClient)
+	return m.Handle()
+}
+
+// Handle checks the payment and, once the rest of the chain has answered it,
+// records the outcome: a 2xx response as a payment, and a decline by a later
+// middleware or the handler as a decline.
+func (m *VelocityMiddleware) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := requestFromContext(c, true)
+		if !ok {
+			return
+		}
+
+		if err := m.velocity.Check(c.Request.Context(), req); err != nil {
+			abortWithDecline(c, err, "Failed to check velocity control")
+		} else {
+			c.Next()
+		}
+
+		status := c.Writer.Status()
+		switch {
+		case status >= 200 && status < 300:
+			m.velocity.Record(c.Request.Context(), req, nil)
+		case status == http.StatusUnauthorized || status == http.StatusPaymentRequired || status == http.StatusForbidden:
+			m.velocity.Record(c.Request.Context(), req, responseDecline(c, status))
+		}
+	}
+}
+
+// responseDecline returns the decline a payment was answered with. Declines
+// answered by the handler itself carry no reason beyond their status.
+func responseDecline(c *gin.Context, status int) *authorization.Decline {
+	for i := len(c.Errors) - 1; i >= 0; i-- {
+		if decline, ok := authorization.AsDecline(c.Errors[i].Err); ok {
+			return decline
+		}
+	}
+
+	if status == http.StatusPaymentRequired {
+		return &authorization.Decline{Reason: authorization.ReasonInsufficientFunds}
+	}
+	return &authorization.Decline{Reason: authorization.ReasonDeclined}
+}
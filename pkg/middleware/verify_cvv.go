@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+
+	"ccards/pkg/authorization"
+	"ccards/pkg/vault"
+)
+
+// VerifyCVV checks the CVV when one is sent with the payment. Failed checks
+// are counted on the card and the card is blocked after maxAttempts
+// consecutive failures; a successful check resets the count.
+func VerifyCVV(db *sql.DB, cardVault *vault.Vault, maxAttempts int) gin.HandlerFunc {
+	return runCheck(authorization.VerifyCVV(db, cardVault, maxAttempts), "Database error", true)
+}
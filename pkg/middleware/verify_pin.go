@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"ccards/pkg/authorization"
+	"ccards/pkg/vault"
+)
+
+// VerifyPIN checks the PIN of card-present payments that carry one. Failed
+// checks are counted on the card and the PIN is locked after maxAttempts
+// consecutive failures; a successful check resets the count. A locked PIN
+// is rejected earlier by UsableCard.
+func VerifyPIN(attempts authorization.PINAttempts, cardVault *vault.Vault, maxAttempts int) gin.HandlerFunc {
+	return runCheck(authorization.VerifyPIN(attempts, cardVault, maxAttempts), "Database error", true)
+}
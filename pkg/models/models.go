@@ -1,8 +1,10 @@
 package models
 
 import (
-	"github.com/google/uuid"
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 const (
@@ -14,6 +16,29 @@ const (
 const (
 	CardToIssueStatusPending   = "pending"
 	CardToIssueStatusGenerated = "generated"
+	CardToIssueStatusCancelled = "cancelled"
+)
+
+const (
+	EmployeeStatusActive     = "active"
+	EmployeeStatusInactive   = "inactive"
+	EmployeeStatusOffboarded = "offboarded"
+)
+
+const (
+	ImportJobStatusQueued    = "queued"
+	ImportJobStatusRunning   = "running"
+	ImportJobStatusCompleted = "completed"
+	ImportJobStatusFailed    = "failed"
+)
+
+const (
+	OffboardingStatusInProgress = "in_progress"
+	OffboardingStatusCompleted  = "completed"
+	OffboardingStatusFailed     = "failed"
+
+	OffboardingCardActionBlock  = "block"
+	OffboardingCardActionCancel = "cancel"
 )
 
 const (
@@ -29,12 +54,43 @@ const (
 const (
 	TransactionTypePurchase = "purchase"
 	TransactionTypeCharge   = "charge"
+	TransactionTypeSweep    = "sweep"
+
+	// Dispute ledger movements. A provisional credit returns a disputed
+	// amount to the card; a credit reversal takes it back when the dispute
+	// is lost.
+	TransactionTypeProvisionalCredit = "provisional_credit"
+	TransactionTypeCreditReversal    = "credit_reversal"
+
+	// A refund returns part or all of a captured store payment to the card.
+	TransactionTypeRefund = "refund"
+
+	// A funding moves a new card's initial funding from the company balance.
+	TransactionTypeFunding = "funding"
 
 	TransactionStatusPending   = "pending"
 	TransactionStatusCompleted = "completed"
 	TransactionStatusFailed    = "failed"
+	TransactionStatusVoided    = "voided"
+
+	// Channels say how the card was presented. Chip and contactless payments
+	// are card-present; online payments are card-not-present.
+	TransactionChannelOnline      = "online"
+	TransactionChannelChip        = "chip"
+	TransactionChannelContactless = "contactless"
+	TransactionChannelATM         = "atm"
 )
 
+// MerchantCategoryOther is the category of MCCs missing from the catalogue.
+const MerchantCategoryOther = "other"
+
+var TransactionChannels = []string{
+	TransactionChannelOnline,
+	TransactionChannelChip,
+	TransactionChannelContactless,
+	TransactionChannelATM,
+}
+
 type Company struct {
 	ID        uuid.UUID `json:"id"`
 	ClientID  uuid.UUID `json:"client_id"`
@@ -44,28 +100,176 @@ type Company struct {
 	Address   string    `json:"address,omitempty"`
 	Phone     string    `json:"phone,omitempty"`
 	Status    string    `json:"status"`
+	Balance   float64   `json:"balance"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// CompanyFunding is a top-up of a company balance. Balance is the company
+// balance after the top-up.
+type CompanyFunding struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CompanyID uuid.UUID `json:"company_id" db:"company_id"`
+	Amount    float64   `json:"amount" db:"amount"`
+	Reference string    `json:"reference,omitempty" db:"reference"`
+	Balance   float64   `json:"balance" db:"balance_after"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
 type CardToIssue struct {
-	ID            uuid.UUID `json:"id" db:"id"`
-	ClientID      uuid.UUID `json:"client_id" db:"client_id"`
-	CardID        uuid.UUID `json:"card_id" db:"card_id"`
-	EmployeeID    uuid.UUID `json:"employee_id" db:"employee_id"`
-	EmployeeEmail string    `json:"employee_email" db:"employee_email"`
-	Status        string    `json:"status" db:"status"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID       `json:"id" db:"id"`
+	ClientID       uuid.UUID       `json:"client_id" db:"client_id"`
+	CardID         uuid.UUID       `json:"card_id" db:"card_id"`
+	EmployeeID     uuid.UUID       `json:"employee_id" db:"employee_id"`
+	EmployeeEmail  string          `json:"employee_email" db:"employee_email"`
+	CardHolderName *string         `json:"card_holder_name,omitempty" db:"card_holder_name"`
+	CardType       *string         `json:"card_type,omitempty" db:"card_type"`
+	SpendingLimit  *float64        `json:"spending_limit,omitempty" db:"spending_limit"`
+	DailyLimit     *float64        `json:"daily_limit,omitempty" db:"daily_limit"`
+	MonthlyLimit   *float64        `json:"monthly_limit,omitempty" db:"monthly_limit"`
+	Department     *string         `json:"department,omitempty" db:"department"`
+	InitialFunding *float64        `json:"initial_funding,omitempty" db:"initial_funding"`
+	ScheduledFor   *time.Time      `json:"scheduled_for,omitempty" db:"scheduled_for"`
+	Policy         *IssuancePolicy `json:"policy,omitempty" db:"policy"`
+	Status         string          `json:"status" db:"status"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// IssuancePolicy holds the settings applied to cards when they are issued.
+// Values given for an individual employee in the onboarding CSV take
+// precedence over the policy, which in turn takes precedence over the policy
+// template. Without a template the company's default template is used.
+type IssuancePolicy struct {
+	TemplateID    *uuid.UUID        `json:"template_id,omitempty"`
+	CardType      *string           `json:"card_type,omitempty"`
+	SpendingLimit *float64          `json:"spending_limit,omitempty"`
+	DailyLimit    *float64          `json:"daily_limit,omitempty"`
+	MonthlyLimit  *float64          `json:"monthly_limit,omitempty"`
+	Controls      []IssuanceControl `json:"controls,omitempty"`
+}
+
+type IssuanceControl struct {
+	ControlType string          `json:"control_type"`
+	Value       json.RawMessage `json:"value"`
+}
+
+// CardPolicyTemplate is a named set of limits, spending controls and card
+// type that a company applies to cards at issuance.
+type CardPolicyTemplate struct {
+	ID            uuid.UUID         `json:"id" db:"id"`
+	CompanyID     uuid.UUID         `json:"company_id" db:"company_id"`
+	Name          string            `json:"name" db:"name"`
+	Description   *string           `json:"description" db:"description"`
+	CardType      *string           `json:"card_type" db:"card_type"`
+	SpendingLimit *float64          `json:"spending_limit" db:"spending_limit"`
+	DailyLimit    *float64          `json:"daily_limit" db:"daily_limit"`
+	MonthlyLimit  *float64          `json:"monthly_limit" db:"monthly_limit"`
+	Controls      []IssuanceControl `json:"controls" db:"controls"`
+	IsDefault     bool              `json:"is_default" db:"is_default"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// PolicyTemplateColumns lists the card_policy_templates columns in the order
+// ScanPolicyTemplate expects them.
+const PolicyTemplateColumns = `id, company_id, name, description, card_type, spending_limit, daily_limit,
+		monthly_limit, controls, is_default, created_at, updated_at`
+
+func ScanPolicyTemplate(row RowScanner, template *CardPolicyTemplate) error {
+	var controls []byte
+	err := row.Scan(
+		&template.ID, &template.CompanyID, &template.Name, &template.Description, &template.CardType,
+		&template.SpendingLimit, &template.DailyLimit, &template.MonthlyLimit, &controls,
+		&template.IsDefault, &template.CreatedAt, &template.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	template.Controls = []IssuanceControl{}
+	return json.Unmarshal(controls, &template.Controls)
+}
+
+type Employee struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CompanyID  uuid.UUID  `json:"company_id" db:"company_id"`
+	ExternalID *string    `json:"external_id" db:"external_id"`
+	Email      string     `json:"email" db:"email"`
+	Name       string     `json:"name" db:"name"`
+	Department *string    `json:"department" db:"department"`
+	CostCenter *string    `json:"cost_center" db:"cost_center"`
+	ManagerID  *uuid.UUID `json:"manager_id" db:"manager_id"`
+	Status     string     `json:"status" db:"status"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type EmployeeOffboarding struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	CompanyID   uuid.UUID  `json:"company_id" db:"company_id"`
+	EmployeeID  uuid.UUID  `json:"employee_id" db:"employee_id"`
+	CardAction  string     `json:"card_action" db:"card_action"`
+	Reason      *string    `json:"reason" db:"reason"`
+	Status      string     `json:"status" db:"status"`
+	LastError   *string    `json:"last_error" db:"last_error"`
+	StartedAt   time.Time  `json:"started_at" db:"started_at"`
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+}
+
+type OffboardedCard struct {
+	OffboardingID   uuid.UUID `json:"offboarding_id" db:"offboarding_id"`
+	CardID          uuid.UUID `json:"card_id" db:"card_id"`
+	LastFour        string    `json:"last_four" db:"last_four"`
+	PreviousStatus  string    `json:"previous_status" db:"previous_status"`
+	NewStatus       string    `json:"new_status" db:"new_status"`
+	BalanceReturned float64   `json:"balance_returned" db:"balance_returned"`
+	HoldsVoided     int       `json:"holds_voided" db:"holds_voided"`
+	ControlsRevoked int       `json:"controls_revoked" db:"controls_revoked"`
+	ProcessedAt     time.Time `json:"processed_at" db:"processed_at"`
+}
+
+type ImportJob struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	CompanyID      uuid.UUID  `json:"company_id" db:"company_id"`
+	FileName       string     `json:"file_name" db:"file_name"`
+	FilePath       string     `json:"-" db:"file_path"`
+	FileSize       int64      `json:"file_size" db:"file_size"`
+	DryRun         bool       `json:"dry_run" db:"dry_run"`
+	Status         string     `json:"status" db:"status"`
+	BytesProcessed int64      `json:"bytes_processed" db:"bytes_processed"`
+	LastLine       int        `json:"last_line" db:"last_line"`
+	TotalRows      int        `json:"total_rows" db:"total_rows"`
+	Accepted       int        `json:"accepted" db:"accepted"`
+	Rejected       int        `json:"rejected" db:"rejected"`
+	Duplicates     int        `json:"duplicates" db:"duplicates"`
+	Error          *string    `json:"error,omitempty" db:"error"`
+	StartedAt      *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type ImportJobRow struct {
+	JobID         uuid.UUID  `json:"job_id" db:"job_id"`
+	Line          int        `json:"line" db:"line"`
+	EmployeeID    string     `json:"employee_id" db:"employee_id"`
+	EmployeeEmail string     `json:"employee_email" db:"employee_email"`
+	Status        string     `json:"status" db:"status"`
+	Reasons       []string   `json:"reasons" db:"reasons"`
+	CardToIssueID *uuid.UUID `json:"card_to_issue_id,omitempty" db:"card_to_issue_id"`
 }
 
 type Card struct {
 	ID             uuid.UUID  `json:"id" db:"id"`
 	CompanyID      uuid.UUID  `json:"company_id" db:"company_id"`
-	CardNumber     string     `json:"card_number" db:"card_number"`
+	Token          string     `json:"token" db:"token"`
+	MaskedPAN      string     `json:"masked_pan" db:"-"`
 	CardHolderName string     `json:"card_holder_name" db:"card_holder_name"`
 	EmployeeID     string     `json:"employee_id" db:"employee_id"`
 	EmployeeEmail  string     `json:"employee_email" db:"employee_email"`
+	EmployeeRefID  *uuid.UUID `json:"employee_ref_id" db:"employee_ref_id"`
+	Department     *string    `json:"department" db:"department"`
 	CardType       string     `json:"card_type" db:"card_type"`
 	Status         string     `json:"status" db:"status"`
 	Balance        float64    `json:"balance" db:"balance"`
@@ -73,12 +277,41 @@ type Card struct {
 	DailyLimit     *float64   `json:"daily_limit" db:"daily_limit"`
 	MonthlyLimit   *float64   `json:"monthly_limit" db:"monthly_limit"`
 	ExpiryDate     time.Time  `json:"expiry_date" db:"expiry_date"`
-	CVVHash        string     `json:"cvv_hash" db:"cvv_hash"`
+	CVVMAC         string     `json:"-" db:"cvv_mac"`
 	LastFour       string     `json:"last_four" db:"last_four"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 	BlockedAt      *time.Time `json:"blocked_at" db:"blocked_at"`
 	BlockedReason  *string    `json:"blocked_reason" db:"blocked_reason"`
+
+	PolicyTemplateID *uuid.UUID `json:"policy_template_id" db:"policy_template_id"`
+
+	PINHash     string     `json:"-" db:"pin_hash"`
+	PINSet      bool       `json:"pin_set" db:"-"`
+	PINLockedAt *time.Time `json:"pin_locked_at,omitempty" db:"pin_locked_at"`
+
+	// Secret holds the encrypted PAN and CVV. It is only set when a card is
+	// issued and is never read back with the card.
+	Secret *CardSecret `json:"-" db:"-"`
+}
+
+// CardSecret is a card's PAN and CVV encrypted with a per-card data key. The
+// data key is stored encrypted with the master key identified by KeyID.
+type CardSecret struct {
+	KeyID       string `db:"secret_key_id"`
+	DataKey     []byte `db:"secret_data_key"`
+	Ciphertext  []byte `db:"secret_ciphertext"`
+	Fingerprint string `db:"pan_fingerprint"`
+}
+
+// CardReveal records a request to reveal a card's full PAN and CVV.
+type CardReveal struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CardID    uuid.UUID `json:"card_id" db:"card_id"`
+	CompanyID uuid.UUID `json:"company_id" db:"company_id"`
+	ClientIP  string    `json:"client_ip" db:"client_ip"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
 type Transaction struct {
@@ -87,13 +320,106 @@ type Transaction struct {
 	CompanyID        uuid.UUID  `json:"company_id" db:"company_id"`
 	TransactionType  string     `json:"transaction_type" db:"transaction_type"`
 	Amount           float64    `json:"amount" db:"amount"`
+	RefundedAmount   float64    `json:"refunded_amount" db:"refunded_amount"`
 	MerchantName     *string    `json:"merchant_name" db:"merchant_name"`
 	MerchantCategory *string    `json:"merchant_category" db:"merchant_category"`
+	MCC              *string    `json:"mcc" db:"mcc"`
+	MerchantID       *uuid.UUID `json:"merchant_id" db:"merchant_id"`
 	Description      string     `json:"description" db:"description"`
 	Status           string     `json:"status" db:"status"`
+	Channel          string     `json:"channel" db:"channel"`
+	TerminalID       *string    `json:"terminal_id" db:"terminal_id"`
 	ProcessedAt      *time.Time `json:"processed_at" db:"processed_at"`
 	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+
+	RiskScore    *int         `json:"risk_score" db:"risk_score"`
+	RiskAction   *string      `json:"risk_action" db:"risk_action"`
+	RiskReasons  []RiskReason `json:"risk_reasons" db:"risk_reasons"`
+	ReviewStatus *string      `json:"review_status" db:"review_status"`
+	ReviewedAt   *time.Time   `json:"reviewed_at" db:"reviewed_at"`
+}
+
+// TransactionColumns lists the transactions table columns in the order
+// ScanTransaction expects them.
+const TransactionColumns = `id, card_id, company_id, transaction_type, amount, refunded_amount,
+		merchant_name, merchant_category, mcc, merchant_id, description, status, channel, terminal_id,
+		processed_at, created_at, updated_at, risk_score, risk_action, risk_reasons, review_status, reviewed_at`
+
+func ScanTransaction(row RowScanner, transaction *Transaction) error {
+	var reasons []byte
+	err := row.Scan(
+		&transaction.ID, &transaction.CardID, &transaction.CompanyID, &transaction.TransactionType,
+		&transaction.Amount, &transaction.RefundedAmount, &transaction.MerchantName, &transaction.MerchantCategory,
+		&transaction.MCC, &transaction.MerchantID, &transaction.Description, &transaction.Status, &transaction.Channel, &transaction.TerminalID,
+		&transaction.ProcessedAt, &transaction.CreatedAt, &transaction.UpdatedAt,
+		&transaction.RiskScore, &transaction.RiskAction, &reasons, &transaction.ReviewStatus, &transaction.ReviewedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	transaction.RiskReasons = nil
+	if reasons == nil {
+		return nil
+	}
+	return json.Unmarshal(reasons, &transaction.RiskReasons)
+}
+
+const (
+	RiskActionAllow     = "allow"
+	RiskActionReview    = "review"
+	RiskActionDecline   = "decline"
+	RiskActionBlockCard = "block_card"
+
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
+// RiskReason is one fraud rule that added points to a payment's risk score.
+type RiskReason struct {
+	Rule   string `json:"rule"`
+	Points int    `json:"points"`
+	Detail string `json:"detail"`
+}
+
+// RiskAssessment is the fraud score of a payment and the action it leads to.
+type RiskAssessment struct {
+	Score   int          `json:"score"`
+	Action  string       `json:"action"`
+	Reasons []RiskReason `json:"reasons"`
+}
+
+// MerchantCategoryCode is an ISO 18245 merchant category code and the
+// category it is grouped into.
+type MerchantCategoryCode struct {
+	MCC         string `json:"mcc" db:"mcc"`
+	Description string `json:"description" db:"description"`
+	Category    string `json:"category" db:"category"`
+}
+
+type Merchant struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	MCC       string    `json:"mcc" db:"mcc"`
+	Category  string    `json:"category" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	SpendingPeriodDaily   = "daily"
+	SpendingPeriodWeekly  = "weekly"
+	SpendingPeriodMonthly = "monthly"
+)
+
+// CategoryLimit caps spending in one merchant category, or at one registered
+// merchant, over a period.
+type CategoryLimit struct {
+	Category   string     `json:"category,omitempty"`
+	MerchantID *uuid.UUID `json:"merchant_id,omitempty"`
+	Amount     float64    `json:"amount"`
+	Period     string     `json:"period"`
 }
 
 type SpendingControl struct {
@@ -104,4 +430,406 @@ type SpendingControl struct {
 	IsActive     bool        `json:"is_active" db:"is_active"`
 	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time   `json:"updated_at" db:"updated_at"`
+
+	PolicyTemplateID *uuid.UUID `json:"policy_template_id,omitempty" db:"policy_template_id"`
+}
+
+// CardColumns lists the cards table columns in the order ScanCard expects them.
+const CardColumns = `id, company_id, token, card_holder_name, employee_id, employee_email, employee_ref_id, department,
+		card_type, status, balance, spending_limit, daily_limit, monthly_limit,
+		expiry_date, COALESCE(cvv_mac, ''), last_four, created_at, updated_at, blocked_at, blocked_reason,
+		policy_template_id, COALESCE(pin_hash, ''), pin_locked_at`
+
+type RowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func ScanCard(row RowScanner, card *Card) error {
+	err := row.Scan(
+		&card.ID, &card.CompanyID, &card.Token, &card.CardHolderName,
+		&card.EmployeeID, &card.EmployeeEmail, &card.EmployeeRefID, &card.Department, &card.CardType, &card.Status,
+		&card.Balance, &card.SpendingLimit, &card.DailyLimit, &card.MonthlyLimit,
+		&card.ExpiryDate, &card.CVVMAC, &card.LastFour, &card.CreatedAt,
+		&card.UpdatedAt, &card.BlockedAt, &card.BlockedReason, &card.PolicyTemplateID,
+		&card.PINHash, &card.PINLockedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	card.MaskedPAN = MaskPAN(card.LastFour)
+	card.PINSet = card.PINHash != ""
+	return nil
+}
+
+// MaskPAN returns the display form of a card number, showing only its last
+// four digits.
+func MaskPAN(lastFour string) string {
+	return "**** **** **** " + lastFour
+}
+
+const (
+	DisputeStatusOpened      = "opened"
+	DisputeStatusUnderReview = "under_review"
+	DisputeStatusWon         = "won"
+	DisputeStatusLost        = "lost"
+)
+
+// DisputeReasons are the reasons a charge can be disputed for.
+var DisputeReasons = []string{"fraud", "duplicate", "not_received", "not_as_described", "incorrect_amount", "cancelled", "other"}
+
+// Dispute is a contested card payment. Filing it credits the disputed amount
+// back to the card provisionally; losing it reverses that credit.
+type Dispute struct {
+	ID                             uuid.UUID  `json:"id" db:"id"`
+	CompanyID                      uuid.UUID  `json:"company_id" db:"company_id"`
+	CardID                         uuid.UUID  `json:"card_id" db:"card_id"`
+	TransactionID                  uuid.UUID  `json:"transaction_id" db:"transaction_id"`
+	Reason                         string     `json:"reason" db:"reason"`
+	Description                    *string    `json:"description" db:"description"`
+	Amount                         float64    `json:"amount" db:"amount"`
+	Status                         string     `json:"status" db:"status"`
+	ProvisionalCreditTransactionID *uuid.UUID `json:"provisional_credit_transaction_id" db:"provisional_credit_transaction_id"`
+	ReversalTransactionID          *uuid.UUID `json:"reversal_transaction_id" db:"reversal_transaction_id"`
+	EvidenceDueAt                  time.Time  `json:"evidence_due_at" db:"evidence_due_at"`
+	ResolveBy                      time.Time  `json:"resolve_by" db:"resolve_by"`
+	ResolvedAt                     *time.Time `json:"resolved_at" db:"resolved_at"`
+	ResolutionNote                 *string    `json:"resolution_note" db:"resolution_note"`
+	CreatedAt                      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt                      time.Time  `json:"updated_at" db:"updated_at"`
+
+	Evidence []*DisputeEvidence `json:"evidence,omitempty" db:"-"`
+}
+
+// DisputeColumns lists the disputes table columns in the order ScanDispute
+// expects them.
+const DisputeColumns = `id, company_id, card_id, transaction_id, reason, description, amount, status,
+		provisional_credit_transaction_id, reversal_transaction_id, evidence_due_at, resolve_by,
+		resolved_at, resolution_note, created_at, updated_at`
+
+func ScanDispute(row RowScanner, dispute *Dispute) error {
+	return row.Scan(
+		&dispute.ID, &dispute.CompanyID, &dispute.CardID, &dispute.TransactionID, &dispute.Reason,
+		&dispute.Description, &dispute.Amount, &dispute.Status, &dispute.ProvisionalCreditTransactionID,
+		&dispute.ReversalTransactionID, &dispute.EvidenceDueAt, &dispute.ResolveBy, &dispute.ResolvedAt,
+		&dispute.ResolutionNote, &dispute.CreatedAt, &dispute.UpdatedAt,
+	)
+}
+
+// DisputeEvidence is a file attached to a dispute. The file content is only
+// returned by the evidence download endpoint.
+type DisputeEvidence struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	DisputeID   uuid.UUID `json:"dispute_id" db:"dispute_id"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int       `json:"size_bytes" db:"size_bytes"`
+	Description *string   `json:"description" db:"description"`
+	Content     []byte    `json:"-" db:"content"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Event types recorded in the outbox and delivered to subscribers and webhook
+// endpoints. A payment is authorized and completed in one step, so
+// payment.completed is also the authorization event. Blocking a card records
+// card.blocked; other status changes record card.status_changed.
+const (
+	EventPaymentCompleted  = "payment.completed"
+	EventPaymentDeclined   = "payment.declined"
+	EventCardIssued        = "card.issued"
+	EventCardBlocked       = "card.blocked"
+	EventCardStatusChanged = "card.status_changed"
+	EventCardExpiring      = "card.expiring"
+)
+
+// EventTypes are the event types webhook endpoints can subscribe to.
+var EventTypes = []string{EventPaymentCompleted, EventPaymentDeclined, EventCardIssued, EventCardBlocked, EventCardStatusChanged, EventCardExpiring}
+
+// Statuses of an event's delivery to an in-process subscriber.
+const (
+	EventDeliveryStatusPending   = "pending"
+	EventDeliveryStatusProcessed = "processed"
+	EventDeliveryStatusDead      = "dead"
+)
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusDead      = "dead"
+)
+
+// CardIssuedEvent is the payload of card.issued events.
+type CardIssuedEvent struct {
+	CardID         uuid.UUID `json:"card_id"`
+	CardType       string    `json:"card_type"`
+	CardHolderName string    `json:"card_holder_name"`
+	EmployeeID     string    `json:"employee_id"`
+	EmployeeEmail  string    `json:"employee_email"`
+	LastFour       string    `json:"last_four"`
+	ExpiryDate     time.Time `json:"expiry_date"`
+}
+
+// CardStatusChangedEvent is the payload of card.blocked and
+// card.status_changed events.
+type CardStatusChangedEvent struct {
+	CardID uuid.UUID `json:"card_id"`
+	Status string    `json:"status"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// CardExpiringEvent is the payload of card.expiring events, recorded once per
+// card when its expiry date comes within the notification warning period.
+type CardExpiringEvent struct {
+	CardID         uuid.UUID `json:"card_id"`
+	CardHolderName string    `json:"card_holder_name"`
+	EmployeeEmail  string    `json:"employee_email"`
+	LastFour       string    `json:"last_four"`
+	ExpiryDate     time.Time `json:"expiry_date"`
+}
+
+// PaymentDeclinedEvent is the payload of payment.declined events. Reason is
+// the authorization decline reason, whichever transport the payment arrived
+// on.
+type PaymentDeclinedEvent struct {
+	CardID           uuid.UUID `json:"card_id"`
+	Amount           float64   `json:"amount"`
+	MerchantCategory string    `json:"merchant_category,omitempty"`
+	Reason           string    `json:"reason"`
+	DeclinedAt       time.Time `json:"declined_at"`
+}
+
+// Event is an event from the outbox. Data is the event's JSON payload.
+type Event struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	CompanyID uuid.UUID       `json:"company_id" db:"company_id"`
+	Type      string          `json:"type" db:"event_type"`
+	Data      json.RawMessage `json:"data" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// WebhookEndpoint is a company URL that receives events. An endpoint without
+// event types receives every event. The secret signs deliveries and is only
+// returned when the endpoint is created or its secret rotated.
+type WebhookEndpoint struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	CompanyID   uuid.UUID `json:"company_id" db:"company_id"`
+	URL         string    `json:"url" db:"url"`
+	Secret      string    `json:"secret,omitempty" db:"secret"`
+	EventTypes  []string  `json:"event_types" db:"event_types"`
+	Description *string   `json:"description" db:"description"`
+	IsActive    bool      `json:"is_active" db:"is_active"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookDelivery tracks one event sent to one endpoint. Failed deliveries
+// are retried until they succeed or become dead letters.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	EndpointID     uuid.UUID  `json:"endpoint_id" db:"endpoint_id"`
+	EventID        uuid.UUID  `json:"event_id" db:"event_id"`
+	EventType      string     `json:"event_type" db:"event_type"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code" db:"last_status_code"`
+	LastError      *string    `json:"last_error" db:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Notification triggers.
+const (
+	NotificationTriggerLowBalance         = "low_balance"
+	NotificationTriggerLimitNearlyReached = "limit_nearly_reached"
+	NotificationTriggerPaymentDeclined    = "payment_declined"
+	NotificationTriggerCardBlocked        = "card_blocked"
+	NotificationTriggerCardExpiring       = "card_expiring"
+)
+
+var NotificationTriggers = []string{
+	NotificationTriggerLowBalance,
+	NotificationTriggerLimitNearlyReached,
+	NotificationTriggerPaymentDeclined,
+	NotificationTriggerCardBlocked,
+	NotificationTriggerCardExpiring,
+}
+
+// Notification channels.
+const (
+	NotificationChannelEmail   = "email"
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelInApp   = "in_app"
+)
+
+var NotificationChannels = []string{NotificationChannelEmail, NotificationChannelWebhook, NotificationChannelInApp}
+
+const (
+	NotificationDeliveryStatusSent   = "sent"
+	NotificationDeliveryStatusFailed = "failed"
+)
+
+// NotificationPreference tells whether a company is notified of a trigger on
+// a channel, and who receives it. Recipients are email addresses for email
+// and URLs for webhook; the in-app inbox has none. NotifyCardholder also
+// emails the employee holding the card.
+type NotificationPreference struct {
+	CompanyID        uuid.UUID  `json:"company_id" db:"company_id"`
+	Trigger          string     `json:"trigger" db:"trigger"`
+	Channel          string     `json:"channel" db:"channel"`
+	IsEnabled        bool       `json:"is_enabled" db:"is_enabled"`
+	Recipients       []string   `json:"recipients" db:"recipients"`
+	NotifyCardholder bool       `json:"notify_cardholder" db:"notify_cardholder"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// NotificationTemplate is the subject and body of a trigger's notifications,
+// as Go text/template sources. IsCustom is false for the built-in template.
+type NotificationTemplate struct {
+	CompanyID uuid.UUID  `json:"company_id" db:"company_id"`
+	Trigger   string     `json:"trigger" db:"trigger"`
+	Subject   string     `json:"subject" db:"subject"`
+	Body      string     `json:"body" db:"body"`
+	IsCustom  bool       `json:"is_custom" db:"-"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Notification is a rendered notification. DedupeKey identifies what raised
+// it, so the same occurrence never notifies twice.
+type Notification struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CompanyID uuid.UUID  `json:"company_id" db:"company_id"`
+	Trigger   string     `json:"trigger" db:"trigger"`
+	DedupeKey string     `json:"-" db:"dedupe_key"`
+	CardID    *uuid.UUID `json:"card_id" db:"card_id"`
+	Subject   string     `json:"subject" db:"subject"`
+	Body      string     `json:"body" db:"body"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// InboxNotification is a notification in a company's in-app inbox.
+type InboxNotification struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	NotificationID uuid.UUID  `json:"notification_id" db:"notification_id"`
+	Trigger        string     `json:"trigger" db:"trigger"`
+	CardID         *uuid.UUID `json:"card_id" db:"card_id"`
+	Subject        string     `json:"subject" db:"subject"`
+	Body           string     `json:"body" db:"body"`
+	ReadAt         *time.Time `json:"read_at" db:"read_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+const (
+	StoreStatusActive    = "active"
+	StoreStatusSuspended = "suspended"
+
+	// A store payment is authorized, then either captured or voided. A
+	// captured payment becomes refunded once all of it is refunded.
+	StorePaymentStatusAuthorized = "authorized"
+	StorePaymentStatusCaptured   = "captured"
+	StorePaymentStatusVoided     = "voided"
+	StorePaymentStatusRefunded   = "refunded"
+)
+
+// Store is a merchant using the merchant-facing API. Its name and MCC are
+// those of its merchant catalogue entry.
+type Store struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	MerchantID uuid.UUID `json:"merchant_id" db:"merchant_id"`
+	Name       string    `json:"name" db:"-"`
+	MCC        string    `json:"mcc" db:"-"`
+	Email      string    `json:"email" db:"email"`
+	Status     string    `json:"status" db:"status"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// StoreAPIKey is an API key pair a store signs its requests with. The secret
+// is only returned when the key is created. A rotated key keeps working until
+// ExpiresAt; a revoked one stops at once.
+type StoreAPIKey struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	StoreID   uuid.UUID  `json:"-" db:"store_id"`
+	KeyID     string     `json:"key_id" db:"key_id"`
+	Secret    string     `json:"secret,omitempty" db:"secret"`
+	ExpiresAt *time.Time `json:"expires_at" db:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// StorePayment is a card payment as its store sees it. TransactionID is the
+// purchase on the card; its amount follows the captured amount.
+type StorePayment struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	StoreID        uuid.UUID  `json:"store_id" db:"store_id"`
+	CardID         uuid.UUID  `json:"-" db:"card_id"`
+	CompanyID      uuid.UUID  `json:"-" db:"company_id"`
+	TransactionID  uuid.UUID  `json:"transaction_id" db:"transaction_id"`
+	Reference      *string    `json:"reference,omitempty" db:"reference"`
+	CardLastFour   string     `json:"card_last_four" db:"card_last_four"`
+	Amount         float64    `json:"amount" db:"amount"`
+	CapturedAmount float64    `json:"captured_amount" db:"captured_amount"`
+	RefundedAmount float64    `json:"refunded_amount" db:"refunded_amount"`
+	Status         string     `json:"status" db:"status"`
+	CapturedAt     *time.Time `json:"captured_at,omitempty" db:"captured_at"`
+	VoidedAt       *time.Time `json:"voided_at,omitempty" db:"voided_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// StorePaymentColumns lists the store_payments table columns in the order
+// ScanStorePayment expects them.
+const StorePaymentColumns = `id, store_id, card_id, company_id, transaction_id, reference, card_last_four,
+		amount, captured_amount, refunded_amount, status, captured_at, voided_at, created_at, updated_at`
+
+func ScanStorePayment(row RowScanner, payment *StorePayment) error {
+	return row.Scan(
+		&payment.ID, &payment.StoreID, &payment.CardID, &payment.CompanyID, &payment.TransactionID,
+		&payment.Reference, &payment.CardLastFour, &payment.Amount, &payment.CapturedAmount,
+		&payment.RefundedAmount, &payment.Status, &payment.CapturedAt, &payment.VoidedAt,
+		&payment.CreatedAt, &payment.UpdatedAt,
+	)
+}
+
+// StoreRefund is a refund of a captured store payment. TransactionID is the
+// refund credited to the card.
+type StoreRefund struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	PaymentID     uuid.UUID `json:"payment_id" db:"payment_id"`
+	TransactionID uuid.UUID `json:"transaction_id" db:"transaction_id"`
+	Amount        float64   `json:"amount" db:"amount"`
+	Reason        *string   `json:"reason,omitempty" db:"reason"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+const (
+	GatewayAuthorizationStatusAuthorized = "authorized"
+	GatewayAuthorizationStatusApproved   = "approved"
+	GatewayAuthorizationStatusCompleted  = "completed"
+	GatewayAuthorizationStatusDeclined   = "declined"
+	GatewayAuthorizationStatusReversed   = "reversed"
+)
+
+// GatewayAuthorization is a request answered by the ISO 8583 gateway. The
+// acquirer identifies it by terminal and retrieval reference number, which is
+// how a repeated request, a completion or a reversal finds it. An approved
+// authorization request is a hold on the card that a completion settles; a
+// financial request is settled when approved. Declined requests have no
+// transaction, and no card when the card was not found.
+type GatewayAuthorization struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	TransactionID *uuid.UUID `json:"transaction_id" db:"transaction_id"`
+	CardID        *uuid.UUID `json:"card_id" db:"card_id"`
+	MTI           string     `json:"mti" db:"mti"`
+	STAN          string     `json:"stan" db:"stan"`
+	RRN           string     `json:"rrn" db:"rrn"`
+	TerminalID    string     `json:"terminal_id" db:"terminal_id"`
+	AuthCode      string     `json:"auth_code" db:"auth_code"`
+	ResponseCode  string     `json:"response_code" db:"response_code"`
+	Amount        float64    `json:"amount" db:"amount"`
+	Status        string     `json:"status" db:"status"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	ReversedAt    *time.Time `json:"reversed_at,omitempty" db:"reversed_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
 }
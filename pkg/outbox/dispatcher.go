@@ -0,0 +1,276 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+)
+
+const (
+	// dispatchBatchSize bounds the events fanned out and handled per batch.
+	dispatchBatchSize = 100
+	// dispatchWorkers is the number of events handled concurrently.
+	dispatchWorkers = 10
+)
+
+// Handler processes an event for a subscriber. An error makes the dispatcher
+// retry the event later. Events are delivered at least once and not
+// necessarily in order, so handlers must tolerate duplicates.
+type Handler func(ctx context.Context, event *models.Event) error
+
+// Dispatcher hands outbox events to in-process subscribers. Every event gets
+// a delivery row per subscriber, so a subscriber that fails is retried on its
+// own without handling the event again for the others.
+type Dispatcher struct {
+	db          *sql.DB
+	config      config.OutboxConfig
+	subscribers map[string]Handler
+	names       []string
+}
+
+// job is an event claimed for one subscriber.
+type job struct {
+	subscriber string
+	attempts   int
+	event      *models.Event
+}
+
+func NewDispatcher(db *sql.DB, outboxConfig config.OutboxConfig) *Dispatcher {
+	return &Dispatcher{
+		db:          db,
+		config:      outboxConfig,
+		subscribers: make(map[string]Handler),
+	}
+}
+
+// Subscribe registers a handler for every event recorded from now on. The
+// name is stored with the subscriber's deliveries, so it has to stay the same
+// across releases. Subscribe before starting the dispatcher.
+func (d *Dispatcher) Subscribe(name string, handler Handler) {
+	if _, ok := d.subscribers[name]; !ok {
+		d.names = append(d.names, name)
+	}
+	d.subscribers[name] = handler
+}
+
+// Start dispatches events every interval until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := d.Dispatch(ctx); err != nil {
+					log.Printf("Warning: event dispatch failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Dispatch queues new events for every subscriber, then hands one batch of
+// due events to their subscribers. It returns the number of events handled
+// successfully.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	for {
+		queued, err := d.fanOut(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if queued < dispatchBatchSize {
+			break
+		}
+	}
+
+	// Claimed events are held long enough for the whole batch to be handled
+	// before another dispatcher may pick them up again.
+	lease := d.config.HandlerTimeout * time.Duration(dispatchBatchSize/dispatchWorkers+1)
+	jobs, err := d.claim(ctx, lease)
+	if err != nil {
+		return 0, err
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].event.CreatedAt.Before(jobs[j].event.CreatedAt)
+	})
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		handled int
+	)
+	sem := make(chan struct{}, dispatchWorkers)
+	for _, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(j *job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ok, err := d.handle(ctx, j)
+			if err != nil {
+				log.Printf("Warning: failed to record %s delivery of event %s: %v", j.subscriber, j.event.ID, err)
+			}
+			if ok {
+				mu.Lock()
+				handled++
+				mu.Unlock()
+			}
+		}(j)
+	}
+	wg.Wait()
+
+	return handled, nil
+}
+
+// fanOut creates a delivery for every subscriber of each undispatched event
+// and marks the events dispatched, in one statement. Events are locked with
+// SKIP LOCKED so concurrent dispatchers never fan out the same event twice.
+func (d *Dispatcher) fanOut(ctx context.Context) (int, error) {
+	var dispatched int
+	err := d.db.QueryRowContext(ctx, `
+		WITH batch AS (
+			SELECT id
+			FROM outbox_events
+			WHERE dispatched_at IS NULL
+			ORDER BY created_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), deliveries AS (
+			INSERT INTO outbox_deliveries (event_id, subscriber)
+			SELECT b.id, s.name
+			FROM batch b
+			CROSS JOIN unnest($2::text[]) AS s(name)
+			ON CONFLICT (event_id, subscriber) DO NOTHING
+		), marked AS (
+			UPDATE outbox_events
+			SET dispatched_at = CURRENT_TIMESTAMP
+			WHERE id IN (SELECT id FROM batch)
+			RETURNING id
+		)
+		SELECT COUNT(*) FROM marked`,
+		dispatchBatchSize, pq.Array(d.names),
+	).Scan(&dispatched)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fan out events: %w", err)
+	}
+
+	return dispatched, nil
+}
+
+// claim claims due deliveries of the registered subscribers. Claimed
+// deliveries are pushed back by lease, so another dispatcher only picks one up
+// again when this one failed to record the outcome in time.
+func (d *Dispatcher) claim(ctx context.Context, lease time.Duration) ([]*job, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		WITH due AS (
+			SELECT event_id, subscriber
+			FROM outbox_deliveries
+			WHERE status = $1 AND next_attempt_at <= CURRENT_TIMESTAMP AND subscriber = ANY($2)
+			ORDER BY next_attempt_at ASC
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox_deliveries d
+		SET next_attempt_at = $4
+		FROM due, outbox_events ev
+		WHERE d.event_id = due.event_id AND d.subscriber = due.subscriber AND ev.id = d.event_id
+		RETURNING d.subscriber, d.attempts, ev.id, ev.company_id, ev.event_type, ev.payload, ev.created_at`,
+		models.EventDeliveryStatusPending, pq.Array(d.names), dispatchBatchSize, time.Now().Add(lease),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim events: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*job
+	for rows.Next() {
+		j := &job{event: &models.Event{}}
+		err := rows.Scan(&j.subscriber, &j.attempts,
+			&j.event.ID, &j.event.CompanyID, &j.event.Type, &j.event.Data, &j.event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// handle runs a subscriber's handler and records the outcome. A failed event
+// is retried with exponential backoff until MaxAttempts attempts were made; it
+// is then marked dead and left for inspection.
+func (d *Dispatcher) handle(ctx context.Context, j *job) (bool, error) {
+	handlerErr := d.call(ctx, j)
+	if handlerErr == nil {
+		_, err := d.db.ExecContext(ctx, `
+			UPDATE outbox_deliveries
+			SET status = $3, attempts = attempts + 1, last_error = NULL, processed_at = CURRENT_TIMESTAMP
+			WHERE event_id = $1 AND subscriber = $2`,
+			j.event.ID, j.subscriber, models.EventDeliveryStatusProcessed,
+		)
+		if err != nil {
+			return true, fmt.Errorf("failed to mark event processed: %w", err)
+		}
+		return true, nil
+	}
+
+	status := models.EventDeliveryStatusPending
+	nextAttemptAt := time.Now().Add(d.backoff(j.attempts + 1))
+	if j.attempts+1 >= d.config.MaxAttempts {
+		status = models.EventDeliveryStatusDead
+		log.Printf("Warning: giving up on event %s for %s: %v", j.event.ID, j.subscriber, handlerErr)
+	}
+
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE outbox_deliveries
+		SET status = $3, attempts = attempts + 1, last_error = $4, next_attempt_at = $5
+		WHERE event_id = $1 AND subscriber = $2`,
+		j.event.ID, j.subscriber, status, handlerErr.Error(), nextAttemptAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark event failed: %w", err)
+	}
+	return false, nil
+}
+
+// call runs the handler with the handler timeout. A panicking handler fails
+// the event instead of the dispatcher.
+func (d *Dispatcher) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, d.config.HandlerTimeout)
+	defer cancel()
+
+	return d.subscribers[j.subscriber](ctx, j.event)
+}
+
+// backoff returns the wait after the given number of failed attempts:
+// RetryBackoff, doubling with every attempt, capped at MaxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.RetryBackoff
+	for i := 1; i < attempts && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.config.MaxBackoff {
+		wait = d.config.MaxBackoff
+	}
+	return wait
+}
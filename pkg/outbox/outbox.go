@@ -0,0 +1,59 @@
+// Package outbox records events in the outbox_events table. Events are
+// written with the database transaction that makes the change they describe,
+// so an event is stored exactly when that change is committed. A Dispatcher
+// hands stored events to in-process subscribers afterwards.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// batchSize bounds the number of events inserted per statement.
+const batchSize = 500
+
+// Execer is implemented by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Event is an event to record. Data is encoded as the event's JSON payload.
+type Event struct {
+	CompanyID uuid.UUID
+	Type      string
+	Data      interface{}
+}
+
+// Enqueue records events. Pass the *sql.Tx of the change the events describe.
+func Enqueue(ctx context.Context, exec Execer, events ...*Event) error {
+	for start := 0; start < len(events); start += batchSize {
+		end := start + batchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*4)
+		for i, event := range events[start:end] {
+			payload, err := json.Marshal(event.Data)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+			}
+
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+			args = append(args, uuid.New(), event.CompanyID, event.Type, payload)
+		}
+
+		query := `INSERT INTO outbox_events (id, company_id, event_type, payload) VALUES ` + strings.Join(values, ", ")
+		if _, err := exec.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to record events: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package risk scores card payments for fraud. An Engine runs a set of rules
+// over the payment and the card's recent history; each rule adds points, and
+// the total score decides whether the payment is allowed, queued for review,
+// declined or declined with the card blocked.
+package risk
+
+import (
+	"time"
+
+	"ccards/pkg/models"
+)
+
+// MaxScore is the highest score a payment can get.
+const MaxScore = 100
+
+// Payment is a completed purchase from a card's history.
+type Payment struct {
+	Amount    float64
+	Category  string
+	CreatedAt time.Time
+}
+
+// Input is the payment being authorized. History holds the card's recent
+// completed purchases, newest first.
+type Input struct {
+	Amount   float64
+	Category string
+	MCC      string
+	Channel  string
+	Time     time.Time
+	History  []Payment
+}
+
+// Rule is one fraud signal. Evaluate returns the points it adds to the score
+// and a short explanation, or zero points when the signal is absent.
+type Rule interface {
+	Name() string
+	Evaluate(in *Input) (points int, detail string)
+}
+
+// Thresholds are the scores at which a payment is reviewed, declined, or
+// declined with the card blocked.
+type Thresholds struct {
+	Review    int
+	Decline   int
+	BlockCard int
+}
+
+type Engine struct {
+	rules      []Rule
+	thresholds Thresholds
+}
+
+func NewEngine(thresholds Thresholds, rules ...Rule) *Engine {
+	return &Engine{
+		rules:      rules,
+		thresholds: thresholds,
+	}
+}
+
+// Assess scores the payment. The score is the sum of the rules' points,
+// capped at MaxScore.
+func (e *Engine) Assess(in *Input) *models.RiskAssessment {
+	assessment := &models.RiskAssessment{Reasons: []models.RiskReason{}}
+
+	for _, rule := range e.rules {
+		points, detail := rule.Evaluate(in)
+		if points <= 0 {
+			continue
+		}
+		assessment.Score += points
+		assessment.Reasons = append(assessment.Reasons, models.RiskReason{
+			Rule:   rule.Name(),
+			Points: points,
+			Detail: detail,
+		})
+	}
+
+	if assessment.Score > MaxScore {
+		assessment.Score = MaxScore
+	}
+	assessment.Action = e.action(assessment.Score)
+
+	return assessment
+}
+
+func (e *Engine) action(score int) string {
+	switch {
+	case e.thresholds.BlockCard > 0 && score >= e.thresholds.BlockCard:
+		return models.RiskActionBlockCard
+	case e.thresholds.Decline > 0 && score >= e.thresholds.Decline:
+		return models.RiskActionDecline
+	case e.thresholds.Review > 0 && score >= e.thresholds.Review:
+		return models.RiskActionReview
+	default:
+		return models.RiskActionAllow
+	}
+}
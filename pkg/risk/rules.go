@@ -0,0 +1,144 @@
+package risk
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultRules returns the rules the payment pipeline scores with. Night
+// hours are judged in loc.
+func DefaultRules(loc *time.Location) []Rule {
+	return []Rule{
+		UnusualAmount{MinHistory: 5},
+		FirstTimeCategory{MinHistory: 5, Points: 20},
+		CategorySwitching{Window: 30 * time.Minute, MinCategories: 3, Points: 25},
+		OutOfHours{Location: loc, StartHour: 0, EndHour: 6, Points: 15},
+	}
+}
+
+// UnusualAmount flags payments far above the card's average purchase. Cards
+// with fewer than MinHistory purchases are not judged.
+type UnusualAmount struct {
+	MinHistory int
+}
+
+func (r UnusualAmount) Name() string { return "unusual_amount" }
+
+func (r UnusualAmount) Evaluate(in *Input) (int, string) {
+	if len(in.History) == 0 || len(in.History) < r.MinHistory {
+		return 0, ""
+	}
+
+	var total float64
+	for _, p := range in.History {
+		total += p.Amount
+	}
+	average := total / float64(len(in.History))
+	if average <= 0 {
+		return 0, ""
+	}
+
+	ratio := in.Amount / average
+	var points int
+	switch {
+	case ratio >= 10:
+		points = 45
+	case ratio >= 5:
+		points = 30
+	case ratio >= 3:
+		points = 15
+	default:
+		return 0, ""
+	}
+
+	return points, fmt.Sprintf("amount %.2f is %.1fx the card's average purchase of %.2f", in.Amount, ratio, average)
+}
+
+// FirstTimeCategory flags the first payment in a merchant category on a card
+// with at least MinHistory purchases.
+type FirstTimeCategory struct {
+	MinHistory int
+	Points     int
+}
+
+func (r FirstTimeCategory) Name() string { return "first_time_category" }
+
+func (r FirstTimeCategory) Evaluate(in *Input) (int, string) {
+	if in.Category == "" || len(in.History) == 0 || len(in.History) < r.MinHistory {
+		return 0, ""
+	}
+
+	for _, p := range in.History {
+		if strings.EqualFold(p.Category, in.Category) {
+			return 0, ""
+		}
+	}
+
+	return r.Points, fmt.Sprintf("first payment in category '%s'", in.Category)
+}
+
+// CategorySwitching flags bursts of payments across many merchant categories:
+// MinCategories or more distinct categories, this payment included, within
+// Window.
+type CategorySwitching struct {
+	Window        time.Duration
+	MinCategories int
+	Points        int
+}
+
+func (r CategorySwitching) Name() string { return "category_switching" }
+
+func (r CategorySwitching) Evaluate(in *Input) (int, string) {
+	categories := map[string]bool{}
+	if in.Category != "" {
+		categories[strings.ToLower(in.Category)] = true
+	}
+
+	since := in.Time.Add(-r.Window)
+	for _, p := range in.History {
+		if !p.CreatedAt.After(since) {
+			break
+		}
+		if p.Category != "" {
+			categories[strings.ToLower(p.Category)] = true
+		}
+	}
+
+	if len(categories) < r.MinCategories {
+		return 0, ""
+	}
+
+	return r.Points, fmt.Sprintf("%d merchant categories within %s", len(categories), r.Window)
+}
+
+// OutOfHours flags payments made between StartHour and EndHour in Location.
+// The range may wrap past midnight, for example 22 to 5.
+type OutOfHours struct {
+	Location  *time.Location
+	StartHour int
+	EndHour   int
+	Points    int
+}
+
+func (r OutOfHours) Name() string { return "out_of_hours" }
+
+func (r OutOfHours) Evaluate(in *Input) (int, string) {
+	local := in.Time
+	if r.Location != nil {
+		local = local.In(r.Location)
+	}
+
+	hour := local.Hour()
+	var outside bool
+	if r.StartHour <= r.EndHour {
+		outside = hour >= r.StartHour && hour < r.EndHour
+	} else {
+		outside = hour >= r.StartHour || hour < r.EndHour
+	}
+	if !outside {
+		return 0, ""
+	}
+
+	return r.Points, fmt.Sprintf("payment at %s, between %02d:00 and %02d:00", local.Format("15:04"), r.StartHour, r.EndHour)
+}
@@ -1,11 +1,27 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Store API keys come in pairs: the key ID, sent with every request, and the
+// secret the request is signed with, which is never sent.
+const (
+	apiKeyIDPrefix     = "pk_"
+	apiKeySecretPrefix = "sk_"
+)
+
+// APIKeySecretPurpose binds sealed API key secrets to store API keys in the
+// vault.
+const APIKeySecretPurpose = "store-api-key"
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
@@ -33,6 +49,37 @@ func GenerateRefreshToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
+// GenerateAPIKeyPair returns a new API key ID and its secret.
+func GenerateAPIKeyPair() (keyID, secret string, err error) {
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+
+	return apiKeyIDPrefix + hex.EncodeToString(idBytes), apiKeySecretPrefix + hex.EncodeToString(secretBytes), nil
+}
+
+// SignRequest returns the signature of a store API request: "v1=" followed by
+// the hex HMAC-SHA256 of "<timestamp>.<nonce>.<method>.<request URI>.<body>",
+// keyed with the API key secret.
+func SignRequest(secret string, timestamp int64, nonce, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s.%s.%s.", timestamp, nonce, method, requestURI)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequestSignature reports whether signature is the signature of the
+// request, in constant time.
+func VerifyRequestSignature(secret string, timestamp int64, nonce, method, requestURI string, body []byte, signature string) bool {
+	expected := SignRequest(secret, timestamp, nonce, method, requestURI, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
 func randomInt(max int) int {
 	b := make([]byte, 1)
 	rand.Read(b)
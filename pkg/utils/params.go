@@ -18,3 +18,17 @@ func GetIntParam(c *gin.Context, key string, defaultValue int) int {
 
 	return value
 }
+
+func GetBoolParam(c *gin.Context, key string, defaultValue bool) bool {
+	valueStr := c.Query(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,53 @@
+// Package validation checks card policies and spending controls before they
+// are stored. It is shared by the HTTP handlers and the domain services.
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"ccards/pkg/authorization"
+	apperrors "ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+// ValidateControlValue checks that a spending control value is well formed for
+// its control type before it is stored.
+func ValidateControlValue(controlType string, value json.RawMessage) error {
+	return authorization.ValidateSpendingControl(controlType, value)
+}
+
+// ValidateIssuancePolicy checks the card type, limits and controls of an
+// issuance policy or policy template. Errors wrap ErrInvalidPolicy.
+func ValidateIssuancePolicy(policy *models.IssuancePolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	if policy.CardType != nil && *policy.CardType != models.CardTypeVirtual && *policy.CardType != models.CardTypePhysical {
+		return fmt.Errorf("%w: card_type must be %q or %q", apperrors.ErrInvalidPolicy, models.CardTypeVirtual, models.CardTypePhysical)
+	}
+
+	limits := map[string]*float64{
+		"spending_limit": policy.SpendingLimit,
+		"daily_limit":    policy.DailyLimit,
+		"monthly_limit":  policy.MonthlyLimit,
+	}
+	for name, limit := range limits {
+		if limit != nil && *limit <= 0 {
+			return fmt.Errorf("%w: %s must be greater than zero", apperrors.ErrInvalidPolicy, name)
+		}
+	}
+
+	if policy.DailyLimit != nil && policy.MonthlyLimit != nil && *policy.DailyLimit > *policy.MonthlyLimit {
+		return fmt.Errorf("%w: daily_limit cannot exceed monthly_limit", apperrors.ErrInvalidPolicy)
+	}
+
+	for _, control := range policy.Controls {
+		if err := ValidateControlValue(control.ControlType, control.Value); err != nil {
+			return fmt.Errorf("%w: %v", apperrors.ErrInvalidPolicy, err)
+		}
+	}
+
+	return nil
+}
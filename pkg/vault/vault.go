@@ -0,0 +1,290 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+)
+
+// secretPrefix starts every secret sealed by SealSecret, followed by the ID
+// of the key it was sealed with.
+const secretPrefix = "vault:"
+
+var (
+	ErrMissingKey = errors.New("vault master key is not configured")
+	ErrUnknownKey = errors.New("secret was sealed with an unknown key")
+)
+
+// Vault encrypts card PANs and CVVs with envelope encryption: every card gets
+// its own random data key, and the data key is stored encrypted with the
+// master key from config.
+type Vault struct {
+	keyID          string
+	master         cipher.AEAD
+	fingerprintKey []byte
+	cvvKey         []byte
+	pinKey         []byte
+}
+
+type cardData struct {
+	PAN string `json:"pan"`
+	CVV string `json:"cvv,omitempty"`
+}
+
+func New(cfg config.VaultConfig) (*Vault, error) {
+	if cfg.MasterKey == "" {
+		return nil, ErrMissingKey
+	}
+
+	key, err := base64.StdEncoding.DecodeString(cfg.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode vault master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("vault master key must be 32 bytes, got %d", len(key))
+	}
+
+	master, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Vault{
+		keyID:          cfg.KeyID,
+		master:         master,
+		fingerprintKey: deriveKey(key, "pan-fingerprint"),
+		cvvKey:         deriveKey(key, "cvv-mac"),
+		pinKey:         deriveKey(key, "pin-mac"),
+	}, nil
+}
+
+// deriveKey derives a purpose-specific key from the master key, so the master
+// key itself is only ever used for encryption.
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// SealCard encrypts a card's PAN and CVV. The CVV may be empty for cards whose
+// CVV was never stored in recoverable form.
+func (v *Vault) SealCard(pan, cvv string) (*models.CardSecret, error) {
+	plaintext, err := json.Marshal(cardData{PAN: pan, CVV: cvv})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode card data: %w", err)
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := seal(data, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := seal(v.master, dataKey, []byte(v.keyID))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CardSecret{
+		KeyID:       v.keyID,
+		DataKey:     wrappedKey,
+		Ciphertext:  ciphertext,
+		Fingerprint: v.Fingerprint(pan),
+	}, nil
+}
+
+// OpenCard decrypts a secret sealed by SealCard and returns the PAN and CVV.
+func (v *Vault) OpenCard(secret *models.CardSecret) (string, string, error) {
+	if secret.KeyID != v.keyID {
+		return "", "", ErrUnknownKey
+	}
+
+	dataKey, err := open(v.master, secret.DataKey, []byte(secret.KeyID))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	plaintext, err := open(data, secret.Ciphertext, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decrypt card data: %w", err)
+	}
+
+	var card cardData
+	if err := json.Unmarshal(plaintext, &card); err != nil {
+		return "", "", fmt.Errorf("failed to decode card data: %w", err)
+	}
+
+	return card.PAN, card.CVV, nil
+}
+
+// SealSecret encrypts a secret that has to be read back, such as a signing
+// secret, with the master key. The purpose is bound to the ciphertext, so a
+// secret sealed for one purpose cannot be opened for another.
+func (v *Vault) SealSecret(secret, purpose string) (string, error) {
+	ciphertext, err := seal(v.master, []byte(secret), []byte(v.keyID+"/"+purpose))
+	if err != nil {
+		return "", err
+	}
+
+	return secretPrefix + v.keyID + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// OpenSecret decrypts a secret sealed by SealSecret for the same purpose.
+func (v *Vault) OpenSecret(sealed, purpose string) (string, error) {
+	if !IsSealed(sealed) {
+		return "", errors.New("secret is not sealed")
+	}
+
+	// The key ID may contain colons, the base64 ciphertext cannot.
+	rest := strings.TrimPrefix(sealed, secretPrefix)
+	i := strings.LastIndex(rest, ":")
+	if i < 0 {
+		return "", errors.New("malformed sealed secret")
+	}
+	keyID, encoded := rest[:i], rest[i+1:]
+	if keyID != v.keyID {
+		return "", ErrUnknownKey
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode sealed secret: %w", err)
+	}
+
+	plaintext, err := open(v.master, ciphertext, []byte(keyID+"/"+purpose))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// IsSealed reports whether a stored secret was sealed by SealSecret, so
+// secrets stored before they were encrypted can be found and sealed.
+func IsSealed(secret string) bool {
+	return strings.HasPrefix(secret, secretPrefix)
+}
+
+// Fingerprint returns a keyed hash of the PAN, so duplicate PANs can be
+// detected without storing or comparing them in plaintext.
+func (v *Vault) Fingerprint(pan string) string {
+	mac := hmac.New(sha256.New, v.fingerprintKey)
+	mac.Write([]byte(pan))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CVVMAC returns a keyed MAC of a card's CVV. The card ID is part of the MAC
+// input, so the same CVV on two cards gives two different MACs.
+func (v *Vault) CVVMAC(cardID uuid.UUID, cvv string) string {
+	return cardMAC(v.cvvKey, cardID, cvv)
+}
+
+// VerifyCVV reports whether cvv matches the MAC stored for the card, in
+// constant time.
+func (v *Vault) VerifyCVV(cardID uuid.UUID, cvv, storedMAC string) bool {
+	return verifyCardMAC(v.cvvKey, cardID, cvv, storedMAC)
+}
+
+// PINHash returns a keyed hash of a card's PIN, bound to the card like
+// CVVMAC.
+func (v *Vault) PINHash(cardID uuid.UUID, pin string) string {
+	return cardMAC(v.pinKey, cardID, pin)
+}
+
+// VerifyPIN reports whether pin matches the hash stored for the card, in
+// constant time.
+func (v *Vault) VerifyPIN(cardID uuid.UUID, pin, storedHash string) bool {
+	return verifyCardMAC(v.pinKey, cardID, pin, storedHash)
+}
+
+func cardMAC(key []byte, cardID uuid.UUID, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(cardID[:])
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyCardMAC(key []byte, cardID uuid.UUID, value, stored string) bool {
+	if stored == "" {
+		return false
+	}
+	return hmac.Equal([]byte(cardMAC(key, cardID, value)), []byte(stored))
+}
+
+// NewCVV returns a random three-digit CVV.
+func NewCVV() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate CVV: %w", err)
+	}
+	return fmt.Sprintf("%03d", n.Int64()), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return aead, nil
+}
+
+// seal encrypts plaintext and prepends the random nonce to the result.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// NewToken returns a random card token. Tokens identify a card to API clients
+// and integrations in place of its PAN.
+func NewToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return "tok_" + hex.EncodeToString(b)
+}
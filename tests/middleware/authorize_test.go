@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"ccards/pkg/authorization"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outcomeRecorder passes every payment and remembers the outcomes it is told.
+type outcomeRecorder struct {
+	declines []*authorization.Decline
+}
+
+func (r *outcomeRecorder) Check(ctx context.Context, req *authorization.Request) error {
+	return nil
+}
+
+func (r *outcomeRecorder) Record(ctx context.Context, req *authorization.Request, decline *authorization.Decline) {
+	r.declines = append(r.declines, decline)
+}
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// pay runs checks on a payment of amount in front of a handler that
+	// answers with status.
+	pay := func(amount float64, status int, checks ...authorization.Check) *httptest.ResponseRecorder {
+		spendingLimit := 500.0
+		card := &models.Card{
+			ID:            uuid.New(),
+			Status:        models.CardStatusActive,
+			Balance:       1000,
+			SpendingLimit: &spendingLimit,
+			ExpiryDate:    time.Now().AddDate(1, 0, 0),
+		}
+
+		engine := gin.New()
+		engine.POST("/pay",
+			func(c *gin.Context) {
+				middleware.SetAuthorizationRequest(c, &authorization.Request{CardID: card.ID, Card: card, Amount: amount})
+			},
+			middleware.Authorize(checks...),
+			func(c *gin.Context) { c.JSON(status, gin.H{}) },
+		)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/pay", nil)
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		recorder := &outcomeRecorder{}
+
+		w := pay(100, http.StatusCreated, authorization.UsableCard(), recorder, authorization.SufficientAmount())
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, []*authorization.Decline{nil}, recorder.declines)
+	})
+
+	t.Run("declined_by_a_check", func(t *testing.T) {
+		recorder := &outcomeRecorder{}
+		var later bool
+
+		w := pay(750, http.StatusCreated, recorder, authorization.SufficientAmount(),
+			authorization.CheckFunc(func(ctx context.Context, req *authorization.Request) error {
+				later = true
+				return nil
+			}),
+		)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.False(t, later)
+		require.Len(t, recorder.declines, 1)
+		assert.Equal(t, authorization.ReasonSpendingLimit, recorder.declines[0].Reason)
+	})
+
+	t.Run("declined_by_the_handler", func(t *testing.T) {
+		recorder := &outcomeRecorder{}
+
+		w := pay(100, http.StatusPaymentRequired, recorder)
+
+		assert.Equal(t, http.StatusPaymentRequired, w.Code)
+		require.Len(t, recorder.declines, 1)
+		assert.Equal(t, authorization.ReasonInsufficientFunds, recorder.declines[0].Reason)
+	})
+}
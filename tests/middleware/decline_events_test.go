@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclineEvents(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+
+	// pay sends a payment through DeclineEvents and ValidCard to a handler
+	// that answers with status.
+	pay := func(txReq request.Transaction, status int) int {
+		engine := gin.New()
+		engine.POST("/pay",
+			func(c *gin.Context) { c.Set("company_id", txReq.CompanyID) },
+			middleware.DeclineEvents(db),
+			middleware.ValidCard(db),
+			func(c *gin.Context) { c.JSON(status, gin.H{}) },
+		)
+
+		body, err := json.Marshal(txReq)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/pay", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	declineReasons := func(t *testing.T, companyID uuid.UUID) []string {
+		rows, err := db.QueryContext(ctx, `
+			SELECT payload->>'reason' FROM outbox_events WHERE company_id = $1 AND event_type = $2`,
+			companyID, models.EventPaymentDeclined,
+		)
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var reasons []string
+		for rows.Next() {
+			var reason string
+			require.NoError(t, rows.Scan(&reason))
+			reasons = append(reasons, reason)
+		}
+		require.NoError(t, rows.Err())
+		return reasons
+	}
+
+	t.Run("card_not_found", func(t *testing.T) {
+		companyID := uuid.New()
+		insertCard(t, db, uuid.New(), companyID)
+
+		code := pay(request.Transaction{CompanyID: companyID, CardID: uuid.New(), Amount: 100, MerchantCategory: "food"}, http.StatusCreated)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, []string{authorization.ReasonCardNotFound}, declineReasons(t, companyID))
+	})
+
+	t.Run("declined_by_handler", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+		insertCard(t, db, cardID, companyID)
+
+		code := pay(request.Transaction{CompanyID: companyID, CardID: cardID, Amount: 100, MerchantCategory: "food"}, http.StatusPaymentRequired)
+		assert.Equal(t, http.StatusPaymentRequired, code)
+		assert.Equal(t, []string{authorization.ReasonInsufficientFunds}, declineReasons(t, companyID))
+	})
+
+	t.Run("approved", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+		insertCard(t, db, cardID, companyID)
+
+		code := pay(request.Transaction{CompanyID: companyID, CardID: cardID, Amount: 100, MerchantCategory: "food"}, http.StatusCreated)
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Empty(t, declineReasons(t, companyID))
+	})
+}
@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/store"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+	"ccards/pkg/vault"
+	"ccards/tests/setup"
+)
+
+func TestMerchantAuth(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+
+	cfg := *helper.Config
+	cfg.Store.SignatureTolerance = 5 * time.Minute
+	secretVault, err := vault.New(cfg.Vault)
+	require.NoError(t, err)
+	auth := middleware.NewMerchantAuthMiddleware(&cfg, db, helper.Redis, secretVault)
+
+	keyID, secret, err := utils.GenerateAPIKeyPair()
+	require.NoError(t, err)
+	sealed, err := secretVault.SealSecret(secret, utils.APIKeySecretPurpose)
+	require.NoError(t, err)
+	s := &models.Store{
+		ID:         uuid.New(),
+		MerchantID: uuid.New(),
+		Name:       "Signed Shop",
+		MCC:        "5411",
+		Email:      "signed-" + uuid.NewString() + "@example.com",
+		Status:     models.StoreStatusActive,
+	}
+	require.NoError(t, store.NewRepository(db).CreateStore(ctx, s, &models.StoreAPIKey{
+		ID: uuid.New(), StoreID: s.ID, KeyID: keyID, Secret: sealed,
+	}))
+
+	body := []byte(`{"amount":10}`)
+	signedRequest := func(timestamp time.Time, nonce string, sign func(signature string) string) (*httptest.ResponseRecorder, *gin.Context) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/store/payments/authorize?x=1", bytes.NewReader(body))
+
+		ts := timestamp.Unix()
+		signature := utils.SignRequest(secret, ts, nonce, http.MethodPost, "/store/payments/authorize?x=1", body)
+		c.Request.Header.Set(middleware.APIKeyHeader, keyID)
+		c.Request.Header.Set(middleware.TimestampHeader, strconv.FormatInt(ts, 10))
+		c.Request.Header.Set(middleware.NonceHeader, nonce)
+		c.Request.Header.Set(middleware.SignatureHeader, sign(signature))
+		return w, c
+	}
+	asSigned := func(signature string) string { return signature }
+
+	t.Run("valid_signature", func(t *testing.T) {
+		w, c := signedRequest(time.Now(), uuid.NewString(), asSigned)
+		auth.MerchantAuth()(c)
+
+		assert.False(t, c.IsAborted(), w.Body.String())
+		storeID, err := middleware.GetStoreIDFromContext(c)
+		require.NoError(t, err)
+		assert.Equal(t, s.ID, storeID)
+		assert.Equal(t, s.MerchantID, c.MustGet("merchant_id"))
+
+		// The body is still there for the handler.
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(c.Request.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, buf.Bytes())
+	})
+
+	t.Run("replayed_nonce", func(t *testing.T) {
+		nonce := uuid.NewString()
+		_, c := signedRequest(time.Now(), nonce, asSigned)
+		auth.MerchantAuth()(c)
+		require.False(t, c.IsAborted())
+
+		w, c := signedRequest(time.Now(), nonce, asSigned)
+		auth.MerchantAuth()(c)
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("stale_timestamp", func(t *testing.T) {
+		w, c := signedRequest(time.Now().Add(-10*time.Minute), uuid.NewString(), asSigned)
+		auth.MerchantAuth()(c)
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("tampered_signature", func(t *testing.T) {
+		w, c := signedRequest(time.Now(), uuid.NewString(), func(signature string) string {
+			return utils.SignRequest("sk_wrong", 0, "", http.MethodPost, "/", nil)
+		})
+		auth.MerchantAuth()(c)
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("expired_key", func(t *testing.T) {
+		_, err := db.ExecContext(ctx, `UPDATE store_api_keys SET expires_at = CURRENT_TIMESTAMP - INTERVAL '1 minute' WHERE key_id = $1`, keyID)
+		require.NoError(t, err)
+		defer db.ExecContext(ctx, `UPDATE store_api_keys SET expires_at = NULL WHERE key_id = $1`, keyID)
+
+		w, c := signedRequest(time.Now(), uuid.NewString(), asSigned)
+		auth.MerchantAuth()(c)
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("suspended_store", func(t *testing.T) {
+		_, err := db.ExecContext(ctx, `UPDATE stores SET status = $2 WHERE id = $1`, s.ID, models.StoreStatusSuspended)
+		require.NoError(t, err)
+		defer db.ExecContext(ctx, `UPDATE stores SET status = $2 WHERE id = $1`, s.ID, models.StoreStatusActive)
+
+		w, c := signedRequest(time.Now(), uuid.NewString(), asSigned)
+		auth.MerchantAuth()(c)
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
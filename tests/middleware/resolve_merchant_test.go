@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveMerchant(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+
+	gin.SetMode(gin.TestMode)
+
+	resolve := func(txReq *request.Transaction) (*authorization.Request, int, bool) {
+		w, c := setupTestContext(*txReq, txReq.CardID, txReq.CompanyID)
+		req := setAuthorizationRequest(c, txReq, nil)
+
+		middleware.ResolveMerchant(db)(c)
+		return req, w.Code, c.IsAborted()
+	}
+
+	t.Run("free_text_category", func(t *testing.T) {
+		txReq := &request.Transaction{CardID: uuid.New(), CompanyID: uuid.New(), Amount: 10, MerchantCategory: "  Food "}
+
+		req, _, aborted := resolve(txReq)
+		assert.False(t, aborted)
+		assert.Equal(t, "food", req.MerchantCategory)
+	})
+
+	t.Run("mcc", func(t *testing.T) {
+		txReq := &request.Transaction{CardID: uuid.New(), CompanyID: uuid.New(), Amount: 10, MCC: "7995"}
+
+		req, _, aborted := resolve(txReq)
+		assert.False(t, aborted)
+		assert.Equal(t, "gambling", req.MerchantCategory)
+	})
+
+	t.Run("unknown_mcc", func(t *testing.T) {
+		txReq := &request.Transaction{CardID: uuid.New(), CompanyID: uuid.New(), Amount: 10, MCC: "0001"}
+
+		req, _, aborted := resolve(txReq)
+		assert.False(t, aborted)
+		assert.Equal(t, models.MerchantCategoryOther, req.MerchantCategory)
+	})
+
+	t.Run("registered_merchant", func(t *testing.T) {
+		merchantID := uuid.New()
+		_, err := db.Exec(`INSERT INTO merchants (id, name, mcc) VALUES ($1, $2, $3)`, merchantID, "Grand Hotel "+merchantID.String()[:8], "7011")
+		require.NoError(t, err)
+
+		txReq := &request.Transaction{
+			CardID:           uuid.New(),
+			CompanyID:        uuid.New(),
+			Amount:           10,
+			MerchantID:       &merchantID,
+			MCC:              "5812",
+			MerchantCategory: "food",
+		}
+
+		req, _, aborted := resolve(txReq)
+		assert.False(t, aborted)
+		assert.Equal(t, "7011", req.MCC)
+		assert.Equal(t, "travel", req.MerchantCategory)
+		assert.Contains(t, req.MerchantName, "Grand Hotel")
+	})
+
+	t.Run("unknown_merchant", func(t *testing.T) {
+		merchantID := uuid.New()
+		txReq := &request.Transaction{CardID: uuid.New(), CompanyID: uuid.New(), Amount: 10, MerchantID: &merchantID}
+
+		_, code, aborted := resolve(txReq)
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
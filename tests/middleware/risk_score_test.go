@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"ccards/internal/api/request"
+	"ccards/pkg/authorization"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/risk"
+	"ccards/tests/setup"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiskEngine(t *testing.T) {
+	tokyo, err := time.LoadLocation(middleware.TokyoTimezone)
+	require.NoError(t, err)
+
+	noon := time.Date(2026, 3, 10, 12, 0, 0, 0, tokyo)
+	history := func(categories ...string) []risk.Payment {
+		payments := make([]risk.Payment, len(categories))
+		for i, category := range categories {
+			payments[i] = risk.Payment{Amount: 50, Category: category, CreatedAt: noon.Add(-time.Duration(i+1) * 24 * time.Hour)}
+		}
+		return payments
+	}
+
+	engine := risk.NewEngine(risk.Thresholds{Review: 40, Decline: 70, BlockCard: 90}, risk.DefaultRules(tokyo)...)
+
+	t.Run("usual_payment", func(t *testing.T) {
+		assessment := engine.Assess(&risk.Input{
+			Amount:   60,
+			Category: "food",
+			Time:     noon,
+			History:  history("food", "food", "travel", "food", "food"),
+		})
+
+		assert.Equal(t, 0, assessment.Score)
+		assert.Equal(t, models.RiskActionAllow, assessment.Action)
+		assert.Empty(t, assessment.Reasons)
+	})
+
+	t.Run("new_card_not_judged", func(t *testing.T) {
+		assessment := engine.Assess(&risk.Input{Amount: 5000, Category: "gambling", Time: noon})
+		assert.Equal(t, models.RiskActionAllow, assessment.Action)
+	})
+
+	t.Run("unusual_amount_in_new_category", func(t *testing.T) {
+		assessment := engine.Assess(&risk.Input{
+			Amount:   300,
+			Category: "gambling",
+			Time:     noon,
+			History:  history("food", "food", "travel", "food", "food"),
+		})
+
+		assert.Equal(t, 50, assessment.Score)
+		assert.Equal(t, models.RiskActionReview, assessment.Action)
+		require.Len(t, assessment.Reasons, 2)
+		assert.Equal(t, "unusual_amount", assessment.Reasons[0].Rule)
+		assert.Equal(t, "first_time_category", assessment.Reasons[1].Rule)
+	})
+
+	t.Run("category_switching", func(t *testing.T) {
+		recent := []risk.Payment{
+			{Amount: 50, Category: "retail", CreatedAt: noon.Add(-5 * time.Minute)},
+			{Amount: 50, Category: "food", CreatedAt: noon.Add(-10 * time.Minute)},
+			{Amount: 50, Category: "travel", CreatedAt: noon.Add(-2 * time.Hour)},
+		}
+		assessment := engine.Assess(&risk.Input{Amount: 50, Category: "fuel", Time: noon, History: recent})
+
+		require.Len(t, assessment.Reasons, 1)
+		assert.Equal(t, "category_switching", assessment.Reasons[0].Rule)
+		assert.Equal(t, 25, assessment.Score)
+	})
+
+	t.Run("out_of_hours", func(t *testing.T) {
+		night := time.Date(2026, 3, 10, 3, 30, 0, 0, tokyo)
+		assessment := engine.Assess(&risk.Input{Amount: 50, Category: "food", Time: night.UTC()})
+
+		require.Len(t, assessment.Reasons, 1)
+		assert.Equal(t, "out_of_hours", assessment.Reasons[0].Rule)
+	})
+
+	t.Run("score_capped", func(t *testing.T) {
+		night := time.Date(2026, 3, 10, 2, 0, 0, 0, tokyo)
+		recent := []risk.Payment{
+			{Amount: 20, Category: "retail", CreatedAt: night.Add(-5 * time.Minute)},
+			{Amount: 20, Category: "food", CreatedAt: night.Add(-10 * time.Minute)},
+			{Amount: 20, Category: "food", CreatedAt: night.Add(-24 * time.Hour)},
+			{Amount: 20, Category: "food", CreatedAt: night.Add(-48 * time.Hour)},
+			{Amount: 20, Category: "food", CreatedAt: night.Add(-72 * time.Hour)},
+		}
+		assessment := engine.Assess(&risk.Input{Amount: 1000, Category: "gambling", Time: night, History: recent})
+
+		assert.Equal(t, risk.MaxScore, assessment.Score)
+		assert.Equal(t, models.RiskActionBlockCard, assessment.Action)
+	})
+}
+
+func TestRiskScore(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+
+	// Rules that do not depend on the time of day keep the results stable.
+	newMiddleware := func(thresholds risk.Thresholds) gin.HandlerFunc {
+		engine := risk.NewEngine(thresholds,
+			risk.UnusualAmount{MinHistory: 5},
+			risk.FirstTimeCategory{MinHistory: 5, Points: 20},
+		)
+		return middleware.NewRiskScoreMiddleware(db, engine).Handle()
+	}
+
+	newCardWithHistory := func(t *testing.T) *models.Card {
+		cardID := uuid.New()
+		companyID := uuid.New()
+		insertCard(t, db, cardID, companyID)
+		for i := 0; i < 5; i++ {
+			insertCategoryTransaction(t, db, cardID, "food", nil, 20)
+		}
+		return getTestCard(cardID, companyID)
+	}
+
+	pay := func(handler gin.HandlerFunc, card *models.Card, amount float64, category string) (int, map[string]interface{}, *authorization.Request, bool) {
+		txReq := request.Transaction{
+			CompanyID:        card.CompanyID,
+			CardID:           card.ID,
+			Amount:           amount,
+			MerchantCategory: category,
+		}
+
+		w, c := setupTestContext(txReq, card.ID, card.CompanyID)
+		authReq := setAuthorizationRequest(c, &txReq, card)
+
+		handler(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response, authReq, c.IsAborted()
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		card := newCardWithHistory(t)
+
+		_, _, txReq, aborted := pay(newMiddleware(risk.Thresholds{Review: 40, Decline: 70, BlockCard: 90}), card, 25, "food")
+		assert.False(t, aborted)
+		require.NotNil(t, txReq.Risk)
+		assert.Equal(t, models.RiskActionAllow, txReq.Risk.Action)
+	})
+
+	t.Run("review", func(t *testing.T) {
+		card := newCardWithHistory(t)
+
+		_, _, txReq, aborted := pay(newMiddleware(risk.Thresholds{Review: 40, Decline: 70, BlockCard: 90}), card, 250, "gambling")
+		assert.False(t, aborted)
+		require.NotNil(t, txReq.Risk)
+		assert.Equal(t, 65, txReq.Risk.Score)
+		assert.Equal(t, models.RiskActionReview, txReq.Risk.Action)
+	})
+
+	t.Run("decline", func(t *testing.T) {
+		card := newCardWithHistory(t)
+
+		code, response, _, aborted := pay(newMiddleware(risk.Thresholds{Review: 20, Decline: 60, BlockCard: 90}), card, 250, "gambling")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, models.RiskActionDecline, response["risk_action"])
+		assert.Equal(t, 65.0, response["risk_score"])
+	})
+
+	t.Run("block_card", func(t *testing.T) {
+		card := newCardWithHistory(t)
+
+		code, response, _, aborted := pay(newMiddleware(risk.Thresholds{Review: 20, Decline: 40, BlockCard: 60}), card, 250, "gambling")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, models.RiskActionBlockCard, response["risk_action"])
+
+		var status, reason string
+		err := db.QueryRowContext(ctx, `SELECT status, blocked_reason FROM cards WHERE id = $1`, card.ID).Scan(&status, &reason)
+		require.NoError(t, err)
+		assert.Equal(t, models.CardStatusBlocked, status)
+		assert.Equal(t, "suspected fraud", reason)
+	})
+}
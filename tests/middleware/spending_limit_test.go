@@ -41,8 +41,7 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("card", getTestCard(cardID, companyID))
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
 
 		middleware.SpendingLimit(db)(c)
 
@@ -67,8 +66,7 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("card", getTestCard(cardID, companyID))
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
 
 		middleware.SpendingLimit(db)(c)
 
@@ -100,8 +98,7 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("card", getTestCard(cardID, companyID))
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
 
 		middleware.SpendingLimit(db)(c)
 
@@ -143,8 +140,7 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("card", getTestCard(cardID, companyID))
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
 
 		middleware.SpendingLimit(db)(c)
 
@@ -179,8 +175,7 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("card", getTestCard(cardID, companyID))
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
 
 		middleware.SpendingLimit(db)(c)
 
@@ -195,6 +190,284 @@ func TestSpendingLimit(t *testing.T) {
 		assert.Equal(t, "time_based", response["control_type"])
 	})
 
+	t.Run("mcc_range_blocked", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createControl(t, db, cardID, "merchant_category", middleware.MerchantCategoryControl{
+			BlockedMCCs: []string{"7800-7802", "7995"},
+		})
+
+		for mcc, blocked := range map[string]bool{"7801": true, "7995": true, "5812": false} {
+			txReq := request.Transaction{
+				CompanyID:        companyID,
+				CardID:           cardID,
+				Amount:           100.0,
+				MerchantCategory: "whatever",
+				MCC:              mcc,
+			}
+
+			w, c := setupTestContext(txReq, cardID, companyID)
+			setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+			middleware.SpendingLimit(db)(c)
+
+			assert.Equal(t, blocked, c.IsAborted(), mcc)
+			if blocked {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		}
+	})
+
+	t.Run("mcc_or_category_allowed", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createControl(t, db, cardID, "merchant_category", middleware.MerchantCategoryControl{
+			AllowedCategories: []string{"food"},
+			AllowedMCCs:       []string{"4111-4131"},
+		})
+
+		for _, tc := range []struct {
+			category string
+			mcc      string
+			allowed  bool
+		}{
+			{"transport", "4121", true},
+			{"food", "5812", true},
+			{"retail", "5311", false},
+		} {
+			txReq := request.Transaction{
+				CompanyID:        companyID,
+				CardID:           cardID,
+				Amount:           100.0,
+				MerchantCategory: tc.category,
+				MCC:              tc.mcc,
+			}
+
+			_, c := setupTestContext(txReq, cardID, companyID)
+			setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+			middleware.SpendingLimit(db)(c)
+
+			assert.Equal(t, !tc.allowed, c.IsAborted(), tc.mcc)
+		}
+	})
+
+	t.Run("category_limit_exceeded", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createControl(t, db, cardID, "category_limit", middleware.CategoryLimitControl{
+			Limits: []models.CategoryLimit{
+				{Category: "food", Amount: 50, Period: models.SpendingPeriodDaily},
+				{Category: "travel", Amount: 2000, Period: models.SpendingPeriodMonthly},
+			},
+		})
+		insertCategoryTransaction(t, db, cardID, "food", nil, 30)
+		insertCategoryTransaction(t, db, cardID, "retail", nil, 500)
+
+		txReq := request.Transaction{
+			CompanyID:        companyID,
+			CardID:           cardID,
+			Amount:           25.0,
+			MerchantCategory: "food",
+		}
+
+		w, c := setupTestContext(txReq, cardID, companyID)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+		middleware.SpendingLimit(db)(c)
+
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+
+		assert.Equal(t, "category_limit", response["control_type"])
+		assert.Equal(t, "food", response["merchant_category"])
+		assert.Equal(t, models.SpendingPeriodDaily, response["period"])
+		assert.Equal(t, 30.0, response["current_spending"])
+		assert.Equal(t, 20.0, response["remaining_limit"])
+	})
+
+	t.Run("category_limit_within", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createControl(t, db, cardID, "category_limit", middleware.CategoryLimitControl{
+			Limits: []models.CategoryLimit{{Category: "food", Amount: 50, Period: models.SpendingPeriodDaily}},
+		})
+		insertCategoryTransaction(t, db, cardID, "food", nil, 30)
+
+		for category, amount := range map[string]float64{"food": 20, "retail": 500} {
+			txReq := request.Transaction{
+				CompanyID:        companyID,
+				CardID:           cardID,
+				Amount:           amount,
+				MerchantCategory: category,
+			}
+
+			_, c := setupTestContext(txReq, cardID, companyID)
+			setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+			middleware.SpendingLimit(db)(c)
+
+			assert.False(t, c.IsAborted(), category)
+		}
+	})
+
+	t.Run("merchant_limit_exceeded", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		merchantID := uuid.New()
+		_, err := db.Exec(`INSERT INTO merchants (id, name, mcc) VALUES ($1, $2, $3)`, merchantID, "Airline "+merchantID.String()[:8], "4511")
+		require.NoError(t, err)
+
+		createControl(t, db, cardID, "category_limit", middleware.CategoryLimitControl{
+			Limits: []models.CategoryLimit{{MerchantID: &merchantID, Amount: 1000, Period: models.SpendingPeriodWeekly}},
+		})
+		insertCategoryTransaction(t, db, cardID, "travel", &merchantID, 900)
+
+		txReq := request.Transaction{
+			CompanyID:        companyID,
+			CardID:           cardID,
+			Amount:           200.0,
+			MerchantCategory: "travel",
+			MerchantID:       &merchantID,
+		}
+
+		w, c := setupTestContext(txReq, cardID, companyID)
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+		middleware.SpendingLimit(db)(c)
+
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var response map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, merchantID.String(), response["merchant_id"])
+		assert.Equal(t, 100.0, response["remaining_limit"])
+	})
+
+	t.Run("channel_blocked", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createChannelControl(t, db, cardID, middleware.ChannelControl{
+			BlockedChannels: []string{models.TransactionChannelATM},
+		})
+
+		txReq := request.Transaction{
+			CompanyID:        companyID,
+			CardID:           cardID,
+			Amount:           100.0,
+			MerchantCategory: "cash",
+			Channel:          models.TransactionChannelATM,
+		}
+
+		w, c := setupTestContext(txReq, cardID, companyID)
+
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+		middleware.SpendingLimit(db)(c)
+
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+
+		assert.Equal(t, "channel", response["control_type"])
+		assert.Equal(t, models.TransactionChannelATM, response["channel"])
+	})
+
+	t.Run("channel_not_in_allowed_list", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createChannelControl(t, db, cardID, middleware.ChannelControl{
+			AllowedChannels: []string{models.TransactionChannelChip, models.TransactionChannelContactless},
+		})
+
+		txReq := request.Transaction{
+			CompanyID:        companyID,
+			CardID:           cardID,
+			Amount:           100.0,
+			MerchantCategory: "retail",
+		}
+
+		w, c := setupTestContext(txReq, cardID, companyID)
+
+		setAuthorizationRequest(c, &txReq, getTestCard(cardID, companyID))
+
+		middleware.SpendingLimit(db)(c)
+
+		// A payment without a channel is treated as online.
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("channel_category_blocked", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		insertCard(t, db, cardID, companyID)
+
+		createChannelControl(t, db, cardID, middleware.ChannelControl{
+			BlockedCategories: map[string][]string{
+				models.TransactionChannelOnline: {"gambling"},
+			},
+		})
+
+		online := request.Transaction{
+			CompanyID:        companyID,
+			CardID:           cardID,
+			Amount:           100.0,
+			MerchantCategory: "Gambling",
+			Channel:          models.TransactionChannelOnline,
+		}
+
+		w, c := setupTestContext(online, cardID, companyID)
+		setAuthorizationRequest(c, &online, getTestCard(cardID, companyID))
+
+		middleware.SpendingLimit(db)(c)
+
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		inStore := online
+		inStore.Channel = models.TransactionChannelChip
+
+		w, c = setupTestContext(inStore, cardID, companyID)
+		setAuthorizationRequest(c, &inStore, getTestCard(cardID, companyID))
+
+		middleware.SpendingLimit(db)(c)
+
+		assert.False(t, c.IsAborted())
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
 	t.Run("missing_card_in_context", func(t *testing.T) {
 		txReq := request.Transaction{
 			CompanyID:        uuid.New(),
@@ -205,7 +478,7 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, txReq.CardID, txReq.CompanyID)
 
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, nil)
 
 		middleware.SpendingLimit(db)(c)
 
@@ -225,8 +498,6 @@ func TestSpendingLimit(t *testing.T) {
 
 		w, c := setupTestContext(request.Transaction{}, cardID, companyID)
 
-		c.Set("card", getTestCard(cardID, companyID))
-
 		middleware.SpendingLimit(db)(c)
 
 		assert.True(t, c.IsAborted())
@@ -248,7 +519,6 @@ func getTestCard(cardID, companyID uuid.UUID) *models.Card {
 	return &models.Card{
 		ID:             cardID,
 		CompanyID:      companyID,
-		CardNumber:     "4111111111111111",
 		CardHolderName: "Test User",
 		EmployeeID:     "EMP123",
 		EmployeeEmail:  "test@example.com",
@@ -356,3 +626,28 @@ func createTimeBasedControl(t *testing.T, db *sql.DB, cardID uuid.UUID, startTim
 	}
 	require.NoError(t, err, "Failed to create time-based control after %d retries", maxRetries)
 }
+
+func createChannelControl(t *testing.T, db *sql.DB, cardID uuid.UUID, control middleware.ChannelControl) {
+	createControl(t, db, cardID, "channel", control)
+}
+
+func createControl(t *testing.T, db *sql.DB, cardID uuid.UUID, controlType string, control interface{}) {
+	controlJSON, err := json.Marshal(control)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO spending_controls (id, card_id, control_type, control_value, is_active)
+		VALUES ($1, $2, $3, $4, $5)`,
+		uuid.New(), cardID, controlType, controlJSON, true,
+	)
+	require.NoError(t, err)
+}
+
+func insertCategoryTransaction(t *testing.T, db *sql.DB, cardID uuid.UUID, category string, merchantID *uuid.UUID, amount float64) {
+	_, err := db.Exec(`
+		INSERT INTO transactions (id, card_id, company_id, amount, merchant_category, merchant_id, status, transaction_type)
+		SELECT $1, id, company_id, $2, $3, $4, $5, $6 FROM cards WHERE id = $7`,
+		uuid.New(), amount, category, merchantID, models.TransactionStatusCompleted, models.TransactionTypePurchase, cardID,
+	)
+	require.NoError(t, err)
+}
@@ -38,17 +38,12 @@ func TestSufficientAmount(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.SufficientAmount()(c)
 
 		assert.False(t, c.IsAborted())
 		assert.Equal(t, http.StatusOK, w.Code)
-
-		transactionAmount, exists := c.Get("transaction_amount")
-		assert.True(t, exists)
-		assert.Equal(t, txReq.Amount, transactionAmount.(float64))
 	})
 
 	t.Run("insufficient_balance", func(t *testing.T) {
@@ -66,8 +61,7 @@ func TestSufficientAmount(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.SufficientAmount()(c)
 
@@ -99,8 +93,7 @@ func TestSufficientAmount(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.SufficientAmount()(c)
 
@@ -132,17 +125,12 @@ func TestSufficientAmount(t *testing.T) {
 
 		card := getTestCard(cardID, companyID)
 		card.SpendingLimit = nil
-		c.Set("card", card)
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.SufficientAmount()(c)
 
 		assert.False(t, c.IsAborted())
 		assert.Equal(t, http.StatusOK, w.Code)
-
-		transactionAmount, exists := c.Get("transaction_amount")
-		assert.True(t, exists)
-		assert.Equal(t, txReq.Amount, transactionAmount.(float64))
 	})
 
 	t.Run("missing_card_in_context", func(t *testing.T) {
@@ -157,7 +145,7 @@ func TestSufficientAmount(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("transaction_request", &txReq)
+		setAuthorizationRequest(c, &txReq, nil)
 
 		middleware.SufficientAmount()(c)
 
@@ -171,33 +159,6 @@ func TestSufficientAmount(t *testing.T) {
 		assert.Contains(t, response["error"], "Card information not found")
 	})
 
-	t.Run("invalid_card_type_in_context", func(t *testing.T) {
-		cardID := uuid.New()
-		companyID := uuid.New()
-
-		txReq := request.Transaction{
-			CompanyID: companyID,
-			CardID:    cardID,
-			Amount:    100.0,
-		}
-
-		w, c := setupTestContext(txReq, cardID, companyID)
-
-		c.Set("card", "not a card object")
-		c.Set("transaction_request", &txReq)
-
-		middleware.SufficientAmount()(c)
-
-		assert.True(t, c.IsAborted())
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
-
-		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		require.NoError(t, err)
-
-		assert.Contains(t, response["error"], "Invalid card information")
-	})
-
 	t.Run("missing_transaction_request_in_context", func(t *testing.T) {
 		cardID := uuid.New()
 		companyID := uuid.New()
@@ -210,9 +171,6 @@ func TestSufficientAmount(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-
 		middleware.SufficientAmount()(c)
 
 		assert.True(t, c.IsAborted())
@@ -237,9 +195,7 @@ func TestSufficientAmount(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_request", "not a transaction request")
+		c.Set("authorization_request", "not a transaction request")
 
 		middleware.SufficientAmount()(c)
 
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"ccards/internal/api/request"
 	"ccards/internal/client"
+	"ccards/pkg/authorization"
+	"ccards/pkg/middleware"
 	"ccards/pkg/models"
 	"context"
 	"database/sql"
@@ -37,6 +39,15 @@ func setupTestContext(txReq request.Transaction, cardID, companyID uuid.UUID) (*
 	return w, c
 }
 
+// setAuthorizationRequest stores the payment the way ValidCard does, for
+// middlewares tested on their own.
+func setAuthorizationRequest(c *gin.Context, txReq *request.Transaction, card *models.Card) *authorization.Request {
+	req := middleware.NewAuthorizationRequest(txReq)
+	req.Card = card
+	middleware.SetAuthorizationRequest(c, req)
+	return req
+}
+
 func insertCard(t *testing.T, db *sql.DB, cardID, companyID uuid.UUID) {
 	clientRepo := client.NewRepository(db)
 	ctx := context.Background()
@@ -84,7 +95,6 @@ func insertCard(t *testing.T, db *sql.DB, cardID, companyID uuid.UUID) {
 	card := &models.Card{
 		ID:             cardID,
 		CompanyID:      companyID,
-		CardNumber:     cardNumber,
 		CardHolderName: "Test User",
 		EmployeeID:     "EMP123",
 		EmployeeEmail:  "test@example.com",
@@ -95,7 +105,7 @@ func insertCard(t *testing.T, db *sql.DB, cardID, companyID uuid.UUID) {
 		DailyLimit:     &dailyLimit,
 		MonthlyLimit:   &monthlyLimit,
 		ExpiryDate:     time.Now().AddDate(1, 0, 0),
-		CVVHash:        "test-cvv-hash",
+		CVVMAC:         "test-cvv-hash",
 		LastFour:       lastFour,
 	}
 
@@ -37,7 +37,7 @@ func TestUsableCard(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.UsableCard()(c)
 
@@ -61,7 +61,7 @@ func TestUsableCard(t *testing.T) {
 		card.Status = models.CardStatusBlocked
 		blockedReason := "Suspicious activity"
 		card.BlockedReason = &blockedReason
-		c.Set("card", card)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.UsableCard()(c)
 
@@ -77,6 +77,43 @@ func TestUsableCard(t *testing.T) {
 		assert.Equal(t, "blocked", response["status"])
 	})
 
+	t.Run("pin_locked", func(t *testing.T) {
+		cardID := uuid.New()
+		companyID := uuid.New()
+
+		card := getTestCard(cardID, companyID)
+		card.CardType = models.CardTypePhysical
+		lockedAt := time.Now()
+		card.PINLockedAt = &lockedAt
+
+		// Payments without a PIN are not affected by the lock.
+		txReq := request.Transaction{
+			CompanyID: companyID,
+			CardID:    cardID,
+			Amount:    100.0,
+		}
+
+		_, c := setupTestContext(txReq, cardID, companyID)
+		setAuthorizationRequest(c, &txReq, card)
+
+		middleware.UsableCard()(c)
+		assert.False(t, c.IsAborted())
+
+		txReq.PIN = "2580"
+		w, c := setupTestContext(txReq, cardID, companyID)
+		setAuthorizationRequest(c, &txReq, card)
+
+		middleware.UsableCard()(c)
+
+		assert.True(t, c.IsAborted())
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "Card PIN is locked", response["error"])
+	})
+
 	t.Run("expired_card", func(t *testing.T) {
 		cardID := uuid.New()
 		companyID := uuid.New()
@@ -91,7 +128,7 @@ func TestUsableCard(t *testing.T) {
 
 		card := getTestCard(cardID, companyID)
 		card.ExpiryDate = time.Now().AddDate(-1, 0, 0)
-		c.Set("card", card)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.UsableCard()(c)
 
@@ -120,7 +157,7 @@ func TestUsableCard(t *testing.T) {
 
 		card := getTestCard(cardID, companyID)
 		card.Status = models.CardStatusCancelled
-		c.Set("card", card)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.UsableCard()(c)
 
@@ -149,21 +186,16 @@ func TestUsableCard(t *testing.T) {
 
 		card := getTestCard(cardID, companyID)
 		card.ExpiryDate = time.Now().AddDate(0, 0, 15)
-		c.Set("card", card)
+		authReq := setAuthorizationRequest(c, &txReq, card)
 
 		middleware.UsableCard()(c)
 
 		assert.False(t, c.IsAborted())
 		assert.Equal(t, http.StatusOK, w.Code)
 
-		expiryWarning, exists := c.Get("expiry_warning")
-		assert.True(t, exists)
-		assert.True(t, expiryWarning.(bool))
-
-		daysUntilExpiry, exists := c.Get("days_until_expiry")
-		assert.True(t, exists)
-		assert.LessOrEqual(t, daysUntilExpiry.(int), 15)
-		assert.GreaterOrEqual(t, daysUntilExpiry.(int), 14)
+		require.NotNil(t, authReq.DaysUntilExpiry)
+		assert.LessOrEqual(t, *authReq.DaysUntilExpiry, 15)
+		assert.GreaterOrEqual(t, *authReq.DaysUntilExpiry, 14)
 	})
 
 	t.Run("missing_card_in_context", func(t *testing.T) {
@@ -178,6 +210,8 @@ func TestUsableCard(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
+		setAuthorizationRequest(c, &txReq, nil)
+
 		middleware.UsableCard()(c)
 
 		assert.True(t, c.IsAborted())
@@ -190,7 +224,7 @@ func TestUsableCard(t *testing.T) {
 		assert.Contains(t, response["error"], "Card information not found")
 	})
 
-	t.Run("invalid_card_type_in_context", func(t *testing.T) {
+	t.Run("invalid_transaction_request_type", func(t *testing.T) {
 		cardID := uuid.New()
 		companyID := uuid.New()
 
@@ -202,7 +236,7 @@ func TestUsableCard(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("card", "not a card object")
+		c.Set("authorization_request", "not a transaction request")
 
 		middleware.UsableCard()(c)
 
@@ -213,6 +247,6 @@ func TestUsableCard(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 
-		assert.Contains(t, response["error"], "Invalid card information")
+		assert.Contains(t, response["error"], "Invalid transaction request")
 	})
 }
@@ -47,19 +47,15 @@ func TestValidCard(t *testing.T) {
 		assert.False(t, c.IsAborted())
 		assert.Equal(t, http.StatusOK, w.Code)
 
-		cardInterface, exists := c.Get("card")
-		assert.True(t, exists)
-		card, ok := cardInterface.(*models.Card)
-		assert.True(t, ok)
-		assert.Equal(t, cardID, card.ID)
-		assert.Equal(t, companyID, card.CompanyID)
-
-		txInterface, exists := c.Get("transaction_request")
-		assert.True(t, exists)
-		tx, ok := txInterface.(*request.Transaction)
-		assert.True(t, ok)
-		assert.Equal(t, cardID, tx.CardID)
-		assert.Equal(t, companyID, tx.CompanyID)
+		authReq, err := middleware.GetAuthorizationRequest(c)
+		require.NoError(t, err)
+		require.NotNil(t, authReq.Card)
+		assert.Equal(t, cardID, authReq.Card.ID)
+		assert.Equal(t, companyID, authReq.Card.CompanyID)
+		assert.Equal(t, cardID, authReq.CardID)
+		assert.Equal(t, companyID, authReq.CompanyID)
+		assert.Equal(t, 100.0, authReq.Amount)
+		assert.Equal(t, models.TransactionChannelOnline, authReq.Channel)
 	})
 
 	t.Run("company_id_mismatch", func(t *testing.T) {
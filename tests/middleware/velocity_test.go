@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"ccards/pkg/authorization"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVelocity(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+
+	// pay runs the velocity middleware in front of a handler that answers
+	// with status, standing in for the rest of the payment pipeline, behind
+	// DeclineEvents as on the payment routes.
+	pay := func(redisClient *redis.Client, card *models.Card, status int) (int, map[string]interface{}) {
+		engine := gin.New()
+		engine.POST("/pay",
+			middleware.DeclineEvents(db),
+			func(c *gin.Context) {
+				middleware.SetAuthorizationRequest(c, &authorization.Request{CompanyID: card.CompanyID, CardID: card.ID, Card: card})
+			},
+			middleware.Velocity(db, redisClient),
+			func(c *gin.Context) { c.JSON(status, gin.H{}) },
+		)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/pay", nil)
+		engine.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	newCard := func(t *testing.T) *models.Card {
+		cardID := uuid.New()
+		companyID := uuid.New()
+		insertCard(t, db, cardID, companyID)
+		return getTestCard(cardID, companyID)
+	}
+
+	cardStatus := func(t *testing.T, cardID uuid.UUID) string {
+		var status string
+		err := db.QueryRowContext(ctx, `SELECT status FROM cards WHERE id = $1`, cardID).Scan(&status)
+		require.NoError(t, err)
+		return status
+	}
+
+	t.Run("transaction_limit_exceeded", func(t *testing.T) {
+		card := newCard(t)
+		createControl(t, db, card.ID, "velocity", middleware.VelocityControl{
+			MaxTransactions:   2,
+			TransactionWindow: "1h",
+		})
+
+		for i := 0; i < 2; i++ {
+			code, _ := pay(helper.Redis, card, http.StatusCreated)
+			require.Equal(t, http.StatusCreated, code)
+		}
+
+		code, response := pay(helper.Redis, card, http.StatusCreated)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, "velocity", response["control_type"])
+		assert.Equal(t, 2.0, response["transactions"])
+	})
+
+	t.Run("postgres_fallback", func(t *testing.T) {
+		card := newCard(t)
+		createControl(t, db, card.ID, "velocity", middleware.VelocityControl{
+			MaxTransactions:   2,
+			TransactionWindow: "10m",
+		})
+
+		insertCategoryTransaction(t, db, card.ID, "food", nil, 10)
+		code, _ := pay(nil, card, http.StatusCreated)
+		assert.Equal(t, http.StatusCreated, code)
+
+		insertCategoryTransaction(t, db, card.ID, "food", nil, 10)
+		code, response := pay(nil, card, http.StatusCreated)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, "velocity", response["control_type"])
+	})
+
+	t.Run("declines_block_card", func(t *testing.T) {
+		card := newCard(t)
+		createControl(t, db, card.ID, "velocity", middleware.VelocityControl{
+			MaxDeclines:   3,
+			DeclineWindow: "10m",
+		})
+
+		pay(helper.Redis, card, http.StatusUnauthorized)
+		pay(helper.Redis, card, http.StatusPaymentRequired)
+		pay(helper.Redis, card, http.StatusBadRequest)
+		assert.Equal(t, models.CardStatusActive, cardStatus(t, card.ID))
+
+		pay(helper.Redis, card, http.StatusForbidden)
+		assert.Equal(t, models.CardStatusBlocked, cardStatus(t, card.ID))
+
+		var reason string
+		err := db.QueryRowContext(ctx, `SELECT blocked_reason FROM cards WHERE id = $1`, card.ID).Scan(&reason)
+		require.NoError(t, err)
+		assert.Equal(t, "too many declined transactions", reason)
+
+		var events int
+		err = db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM outbox_events WHERE company_id = $1 AND event_type = $2 AND payload->>'status' = $3`,
+			card.CompanyID, models.EventCardBlocked, models.CardStatusBlocked,
+		).Scan(&events)
+		require.NoError(t, err)
+		assert.Equal(t, 1, events)
+	})
+
+	t.Run("declines_recorded_without_control", func(t *testing.T) {
+		card := newCard(t)
+
+		code, _ := pay(helper.Redis, card, http.StatusForbidden)
+		assert.Equal(t, http.StatusForbidden, code)
+
+		var count int
+		err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM card_declines WHERE card_id = $1`, card.ID).Scan(&count)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, models.CardStatusActive, cardStatus(t, card.ID))
+
+		var reason string
+		err = db.QueryRowContext(ctx, `
+			SELECT payload->>'reason' FROM outbox_events WHERE company_id = $1 AND event_type = $2`,
+			card.CompanyID, models.EventPaymentDeclined,
+		).Scan(&reason)
+		require.NoError(t, err)
+		assert.Equal(t, authorization.ReasonDeclined, reason)
+	})
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"ccards/internal/api/request"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+	"ccards/tests/setup"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCVV(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+
+	cardVault, err := vault.New(helper.Config.Vault)
+	require.NoError(t, err)
+
+	const maxAttempts = 3
+
+	newCard := func(t *testing.T) *models.Card {
+		cardID := uuid.New()
+		companyID := uuid.New()
+		insertCard(t, db, cardID, companyID)
+
+		card := getTestCard(cardID, companyID)
+		card.CVVMAC = cardVault.CVVMAC(cardID, "123")
+		_, err := db.ExecContext(ctx, `UPDATE cards SET cvv_mac = $2 WHERE id = $1`, cardID, card.CVVMAC)
+		require.NoError(t, err)
+		return card
+	}
+
+	verify := func(card *models.Card, cvv string) (int, map[string]interface{}, bool) {
+		txReq := request.Transaction{
+			CompanyID: card.CompanyID,
+			CardID:    card.ID,
+			Amount:    100.0,
+			CVV:       cvv,
+		}
+
+		w, c := setupTestContext(txReq, card.ID, card.CompanyID)
+		setAuthorizationRequest(c, &txReq, card)
+
+		middleware.VerifyCVV(db, cardVault, maxAttempts)(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response, c.IsAborted()
+	}
+
+	cardState := func(t *testing.T, cardID uuid.UUID) (int, string) {
+		var attempts int
+		var status string
+		err := db.QueryRowContext(ctx, `SELECT cvv_failed_attempts, status FROM cards WHERE id = $1`, cardID).Scan(&attempts, &status)
+		require.NoError(t, err)
+		return attempts, status
+	}
+
+	t.Run("no_cvv", func(t *testing.T) {
+		card := newCard(t)
+
+		code, _, aborted := verify(card, "")
+		assert.False(t, aborted)
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("valid_cvv_resets_attempts", func(t *testing.T) {
+		card := newCard(t)
+
+		_, _, aborted := verify(card, "999")
+		assert.True(t, aborted)
+
+		code, _, aborted := verify(card, "123")
+		assert.False(t, aborted)
+		assert.Equal(t, http.StatusOK, code)
+
+		attempts, status := cardState(t, card.ID)
+		assert.Equal(t, 0, attempts)
+		assert.Equal(t, models.CardStatusActive, status)
+	})
+
+	t.Run("invalid_cvv", func(t *testing.T) {
+		card := newCard(t)
+
+		code, response, aborted := verify(card, "999")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "Invalid CVV", response["error"])
+		assert.Equal(t, float64(maxAttempts-1), response["attempts_remaining"])
+	})
+
+	t.Run("blocks_after_max_attempts", func(t *testing.T) {
+		card := newCard(t)
+
+		for i := 0; i < maxAttempts-1; i++ {
+			code, _, _ := verify(card, "999")
+			assert.Equal(t, http.StatusUnauthorized, code)
+		}
+
+		code, response, aborted := verify(card, "999")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Contains(t, response["error"], "too many failed CVV attempts")
+
+		attempts, status := cardState(t, card.ID)
+		assert.Equal(t, maxAttempts, attempts)
+		assert.Equal(t, models.CardStatusBlocked, status)
+	})
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"ccards/internal/api/request"
+	"ccards/internal/card"
+	"ccards/pkg/middleware"
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+	"ccards/tests/setup"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPIN(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	ctx := context.Background()
+
+	gin.SetMode(gin.TestMode)
+
+	cardVault, err := vault.New(helper.Config.Vault)
+	require.NoError(t, err)
+
+	cardRepo := card.NewRepository(db)
+	const maxAttempts = 3
+
+	newCard := func(t *testing.T) *models.Card {
+		cardID := uuid.New()
+		companyID := uuid.New()
+		insertCard(t, db, cardID, companyID)
+
+		card := getTestCard(cardID, companyID)
+		card.CardType = models.CardTypePhysical
+		card.PINHash = cardVault.PINHash(cardID, "2580")
+		card.PINSet = true
+		_, err := db.ExecContext(ctx, `UPDATE cards SET card_type = $2, pin_hash = $3 WHERE id = $1`, cardID, card.CardType, card.PINHash)
+		require.NoError(t, err)
+		return card
+	}
+
+	verify := func(card *models.Card, pin string) (int, map[string]interface{}, bool) {
+		txReq := request.Transaction{
+			CompanyID: card.CompanyID,
+			CardID:    card.ID,
+			Amount:    100.0,
+			PIN:       pin,
+		}
+
+		w, c := setupTestContext(txReq, card.ID, card.CompanyID)
+		setAuthorizationRequest(c, &txReq, card)
+
+		middleware.VerifyPIN(cardRepo, cardVault, maxAttempts)(c)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response, c.IsAborted()
+	}
+
+	pinState := func(t *testing.T, cardID uuid.UUID) (int, bool) {
+		var attempts int
+		var locked bool
+		err := db.QueryRowContext(ctx, `SELECT pin_failed_attempts, pin_locked_at IS NOT NULL FROM cards WHERE id = $1`, cardID).Scan(&attempts, &locked)
+		require.NoError(t, err)
+		return attempts, locked
+	}
+
+	t.Run("no_pin", func(t *testing.T) {
+		card := newCard(t)
+
+		code, _, aborted := verify(card, "")
+		assert.False(t, aborted)
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("valid_pin_resets_attempts", func(t *testing.T) {
+		card := newCard(t)
+
+		_, _, aborted := verify(card, "0000")
+		assert.True(t, aborted)
+
+		code, _, aborted := verify(card, "2580")
+		assert.False(t, aborted)
+		assert.Equal(t, http.StatusOK, code)
+
+		attempts, locked := pinState(t, card.ID)
+		assert.Equal(t, 0, attempts)
+		assert.False(t, locked)
+	})
+
+	t.Run("invalid_pin", func(t *testing.T) {
+		card := newCard(t)
+
+		code, response, aborted := verify(card, "0000")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "Invalid PIN", response["error"])
+		assert.Equal(t, float64(maxAttempts-1), response["attempts_remaining"])
+	})
+
+	t.Run("locks_after_max_attempts", func(t *testing.T) {
+		card := newCard(t)
+
+		for i := 0; i < maxAttempts-1; i++ {
+			code, _, _ := verify(card, "0000")
+			assert.Equal(t, http.StatusUnauthorized, code)
+		}
+
+		code, response, aborted := verify(card, "0000")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Contains(t, response["error"], "Card PIN is locked")
+
+		attempts, locked := pinState(t, card.ID)
+		assert.Equal(t, maxAttempts, attempts)
+		assert.True(t, locked)
+
+		var status string
+		err := db.QueryRowContext(ctx, `SELECT status FROM cards WHERE id = $1`, card.ID).Scan(&status)
+		require.NoError(t, err)
+		assert.Equal(t, models.CardStatusActive, status)
+	})
+
+	t.Run("virtual_card", func(t *testing.T) {
+		card := newCard(t)
+		card.CardType = models.CardTypeVirtual
+
+		code, _, aborted := verify(card, "2580")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("pin_not_set", func(t *testing.T) {
+		card := newCard(t)
+		card.PINHash = ""
+		card.PINSet = false
+
+		code, response, aborted := verify(card, "2580")
+		assert.True(t, aborted)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "Card has no PIN set", response["error"])
+	})
+}
@@ -38,21 +38,16 @@ func TestWithinDailyLimit(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_amount", txReq.Amount)
+		authReq := setAuthorizationRequest(c, &txReq, card)
 
 		middleware.WithinDailyLimit(db)(c)
 
 		assert.False(t, c.IsAborted())
 		assert.Equal(t, http.StatusOK, w.Code)
 
-		todaySpending, exists := c.Get("today_spending")
-		assert.True(t, exists)
-		assert.Equal(t, 0.0, todaySpending.(float64))
-
-		remainingDailyLimit, exists := c.Get("remaining_daily_limit")
-		assert.True(t, exists)
-		assert.Equal(t, *card.DailyLimit, remainingDailyLimit.(float64))
+		require.NotNil(t, authReq.Limits)
+		assert.Equal(t, 0.0, authReq.Limits.TodaySpending)
+		assert.Equal(t, *card.DailyLimit, authReq.Limits.RemainingDailyLimit)
 	})
 
 	t.Run("exceeding_daily_limit", func(t *testing.T) {
@@ -72,8 +67,7 @@ func TestWithinDailyLimit(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_amount", txReq.Amount)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.WithinDailyLimit(db)(c)
 
@@ -106,21 +100,17 @@ func TestWithinDailyLimit(t *testing.T) {
 		w, c := setupTestContext(txReq, cardID, companyID)
 
 		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_amount", txReq.Amount)
+		authReq := setAuthorizationRequest(c, &txReq, card)
 
 		middleware.WithinDailyLimit(db)(c)
 
 		assert.False(t, c.IsAborted())
 		assert.Equal(t, http.StatusOK, w.Code)
 
-		monthlySpending, exists := c.Get("monthly_spending")
-		assert.True(t, exists)
-		assert.Equal(t, 0.0, monthlySpending.(float64))
-
-		remainingMonthlyLimit, exists := c.Get("remaining_monthly_limit")
-		assert.True(t, exists)
-		assert.Equal(t, *card.MonthlyLimit, remainingMonthlyLimit.(float64))
+		require.NotNil(t, authReq.Limits)
+		require.NotNil(t, authReq.Limits.MonthlySpending)
+		assert.Equal(t, 0.0, *authReq.Limits.MonthlySpending)
+		assert.Equal(t, *card.MonthlyLimit, *authReq.Limits.RemainingMonthlyLimit)
 	})
 
 	t.Run("exceeding_monthly_limit", func(t *testing.T) {
@@ -142,8 +132,7 @@ func TestWithinDailyLimit(t *testing.T) {
 		card := getTestCard(cardID, companyID)
 		dailyLimit := 10000.0
 		card.DailyLimit = &dailyLimit
-		c.Set("card", card)
-		c.Set("transaction_amount", txReq.Amount)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.WithinDailyLimit(db)(c)
 
@@ -178,8 +167,7 @@ func TestWithinDailyLimit(t *testing.T) {
 		card := getTestCard(cardID, companyID)
 		card.DailyLimit = nil
 		card.MonthlyLimit = nil
-		c.Set("card", card)
-		c.Set("transaction_amount", txReq.Amount)
+		setAuthorizationRequest(c, &txReq, card)
 
 		middleware.WithinDailyLimit(db)(c)
 
@@ -199,7 +187,7 @@ func TestWithinDailyLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		c.Set("transaction_amount", txReq.Amount)
+		setAuthorizationRequest(c, &txReq, nil)
 
 		middleware.WithinDailyLimit(db)(c)
 
@@ -213,34 +201,7 @@ func TestWithinDailyLimit(t *testing.T) {
 		assert.Contains(t, response["error"], "Card information not found")
 	})
 
-	t.Run("invalid_card_type_in_context", func(t *testing.T) {
-		cardID := uuid.New()
-		companyID := uuid.New()
-
-		txReq := request.Transaction{
-			CompanyID: companyID,
-			CardID:    cardID,
-			Amount:    100.0,
-		}
-
-		w, c := setupTestContext(txReq, cardID, companyID)
-
-		c.Set("card", "not a card object")
-		c.Set("transaction_amount", txReq.Amount)
-
-		middleware.WithinDailyLimit(db)(c)
-
-		assert.True(t, c.IsAborted())
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
-
-		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		require.NoError(t, err)
-
-		assert.Contains(t, response["error"], "Invalid card information")
-	})
-
-	t.Run("missing_transaction_amount_in_context", func(t *testing.T) {
+	t.Run("invalid_transaction_request_type", func(t *testing.T) {
 		cardID := uuid.New()
 		companyID := uuid.New()
 
@@ -252,8 +213,7 @@ func TestWithinDailyLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
+		c.Set("authorization_request", "not a transaction request")
 
 		middleware.WithinDailyLimit(db)(c)
 
@@ -264,10 +224,10 @@ func TestWithinDailyLimit(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 
-		assert.Contains(t, response["error"], "Transaction amount not found")
+		assert.Contains(t, response["error"], "Invalid transaction request")
 	})
 
-	t.Run("invalid_transaction_amount_type", func(t *testing.T) {
+	t.Run("missing_transaction_request_in_context", func(t *testing.T) {
 		cardID := uuid.New()
 		companyID := uuid.New()
 
@@ -279,10 +239,6 @@ func TestWithinDailyLimit(t *testing.T) {
 
 		w, c := setupTestContext(txReq, cardID, companyID)
 
-		card := getTestCard(cardID, companyID)
-		c.Set("card", card)
-		c.Set("transaction_amount", "not a number")
-
 		middleware.WithinDailyLimit(db)(c)
 
 		assert.True(t, c.IsAborted())
@@ -292,7 +248,7 @@ func TestWithinDailyLimit(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 
-		assert.Contains(t, response["error"], "Invalid transaction amount")
+		assert.Contains(t, response["error"], "Transaction request not found")
 	})
 }
 
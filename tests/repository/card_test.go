@@ -13,6 +13,7 @@ import (
 	"ccards/internal/card"
 	"ccards/internal/client"
 	"ccards/pkg/models"
+	"ccards/pkg/vault"
 	"ccards/tests/setup"
 )
 
@@ -41,7 +42,6 @@ func TestGetCardByCompanyIDAndCardID(t *testing.T) {
 		card := &models.Card{
 			ID:             cardID,
 			CompanyID:      companyID,
-			CardNumber:     "4111111111111111",
 			CardHolderName: "Employee 1",
 			EmployeeID:     uuid.New().String(),
 			EmployeeEmail:  "employee1@example.com",
@@ -52,7 +52,7 @@ func TestGetCardByCompanyIDAndCardID(t *testing.T) {
 			DailyLimit:     floatPtr(200.00),
 			MonthlyLimit:   floatPtr(5000.00),
 			ExpiryDate:     time.Now().AddDate(3, 0, 0),
-			CVVHash:        "test-cvv-hash-1",
+			CVVMAC:         "test-cvv-hash-1",
 			LastFour:       "1111",
 		}
 
@@ -66,7 +66,8 @@ func TestGetCardByCompanyIDAndCardID(t *testing.T) {
 
 		assert.Equal(t, cardID, retrievedCard.ID)
 		assert.Equal(t, companyID, retrievedCard.CompanyID)
-		assert.Equal(t, card.CardNumber, retrievedCard.CardNumber)
+		assert.Equal(t, card.Token, retrievedCard.Token)
+		assert.Equal(t, "**** **** **** 1111", retrievedCard.MaskedPAN)
 		assert.Equal(t, card.CardHolderName, retrievedCard.CardHolderName)
 		assert.Equal(t, card.EmployeeEmail, retrievedCard.EmployeeEmail)
 		assert.Equal(t, card.CardType, retrievedCard.CardType)
@@ -85,6 +86,58 @@ func TestGetCardByCompanyIDAndCardID(t *testing.T) {
 	})
 }
 
+func TestGetCardByToken(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	cardRepo := card.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	companyID := uuid.New()
+	require.NoError(t, clientRepo.CreateCompany(ctx, &models.Company{
+		ID:       companyID,
+		ClientID: uuid.New(),
+		Name:     "Token Company",
+		Email:    "test-card-token-company@example.com",
+		Password: "hashed_password",
+		Address:  "123 Test St",
+		Phone:    "123-456-7890",
+		Status:   models.CompanyStatusActive,
+	}))
+
+	card := &models.Card{
+		ID:             uuid.New(),
+		CompanyID:      companyID,
+		CardHolderName: "Employee 1",
+		EmployeeID:     uuid.New().String(),
+		EmployeeEmail:  "token-employee@example.com",
+		CardType:       models.CardTypeVirtual,
+		Status:         models.CardStatusActive,
+		ExpiryDate:     time.Now().AddDate(3, 0, 0),
+		CVVMAC:         "test-cvv-hash-1",
+		LastFour:       "1111",
+	}
+	require.NoError(t, clientRepo.CreateCardsInBatch(ctx, []*models.Card{card}))
+
+	t.Run("found", func(t *testing.T) {
+		retrieved, err := cardRepo.GetCardByToken(ctx, companyID, card.Token)
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, card.ID, retrieved.ID)
+	})
+
+	t.Run("other_company", func(t *testing.T) {
+		retrieved, err := cardRepo.GetCardByToken(ctx, uuid.New(), card.Token)
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+	})
+
+	t.Run("unknown_token", func(t *testing.T) {
+		retrieved, err := cardRepo.GetCardByToken(ctx, companyID, "tok_unknown")
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+	})
+}
+
 func TestUpdateSpendingLimit(t *testing.T) {
 	helper := setup.NewTestHelper(t)
 	cardRepo := card.NewRepository(helper.DB)
@@ -111,7 +164,6 @@ func TestUpdateSpendingLimit(t *testing.T) {
 		card := &models.Card{
 			ID:             cardID,
 			CompanyID:      companyID,
-			CardNumber:     "4111111111111111",
 			CardHolderName: "Employee 1",
 			EmployeeID:     uuid.New().String(),
 			EmployeeEmail:  "employee1@example.com",
@@ -122,7 +174,7 @@ func TestUpdateSpendingLimit(t *testing.T) {
 			DailyLimit:     floatPtr(200.00),
 			MonthlyLimit:   floatPtr(5000.00),
 			ExpiryDate:     time.Now().AddDate(3, 0, 0),
-			CVVHash:        "test-cvv-hash-1",
+			CVVMAC:         "test-cvv-hash-1",
 			LastFour:       "1111",
 		}
 
@@ -180,7 +232,6 @@ func TestGetCardsByCompanyID(t *testing.T) {
 			{
 				ID:             uuid.New(),
 				CompanyID:      companyID,
-				CardNumber:     "4111111111111111",
 				CardHolderName: "Employee 1",
 				EmployeeID:     uuid.New().String(),
 				EmployeeEmail:  "employee1@example.com",
@@ -191,7 +242,7 @@ func TestGetCardsByCompanyID(t *testing.T) {
 				DailyLimit:     floatPtr(200.00),
 				MonthlyLimit:   floatPtr(5000.00),
 				ExpiryDate:     time.Now().AddDate(3, 0, 0),
-				CVVHash:        "test-cvv-hash-1",
+				CVVMAC:         "test-cvv-hash-1",
 				LastFour:       "1111",
 				CreatedAt:      time.Now().Add(-2 * time.Hour), // Older card
 				UpdatedAt:      time.Now().Add(-2 * time.Hour),
@@ -199,7 +250,6 @@ func TestGetCardsByCompanyID(t *testing.T) {
 			{
 				ID:             uuid.New(),
 				CompanyID:      companyID,
-				CardNumber:     "4222222222222222",
 				CardHolderName: "Employee 2",
 				EmployeeID:     uuid.New().String(),
 				EmployeeEmail:  "employee2@example.com",
@@ -210,7 +260,7 @@ func TestGetCardsByCompanyID(t *testing.T) {
 				DailyLimit:     floatPtr(300.00),
 				MonthlyLimit:   floatPtr(6000.00),
 				ExpiryDate:     time.Now().AddDate(3, 0, 0),
-				CVVHash:        "test-cvv-hash-2",
+				CVVMAC:         "test-cvv-hash-2",
 				LastFour:       "2222",
 				CreatedAt:      time.Now().Add(-1 * time.Hour), // Newer card
 				UpdatedAt:      time.Now().Add(-1 * time.Hour),
@@ -237,7 +287,7 @@ func TestGetCardsByCompanyID(t *testing.T) {
 			require.True(t, exists, "Retrieved card ID not found in expected cards")
 
 			assert.Equal(t, expectedCard.CompanyID, retrievedCard.CompanyID)
-			assert.Equal(t, expectedCard.CardNumber, retrievedCard.CardNumber)
+			assert.Equal(t, expectedCard.Token, retrievedCard.Token)
 			assert.Equal(t, expectedCard.CardHolderName, retrievedCard.CardHolderName)
 			assert.Equal(t, expectedCard.EmployeeID, retrievedCard.EmployeeID)
 			assert.Equal(t, expectedCard.EmployeeEmail, retrievedCard.EmployeeEmail)
@@ -287,7 +337,6 @@ func TestUpdateSpendingControl(t *testing.T) {
 		card := &models.Card{
 			ID:             cardID,
 			CompanyID:      companyID,
-			CardNumber:     "4111111111111111",
 			CardHolderName: "Employee 1",
 			EmployeeID:     uuid.New().String(),
 			EmployeeEmail:  "employee1@example.com",
@@ -298,7 +347,7 @@ func TestUpdateSpendingControl(t *testing.T) {
 			DailyLimit:     floatPtr(200.00),
 			MonthlyLimit:   floatPtr(5000.00),
 			ExpiryDate:     time.Now().AddDate(3, 0, 0),
-			CVVHash:        "test-cvv-hash-1",
+			CVVMAC:         "test-cvv-hash-1",
 			LastFour:       "1111",
 		}
 
@@ -365,7 +414,6 @@ func TestUpdateSpendingControl(t *testing.T) {
 		card := &models.Card{
 			ID:             cardID,
 			CompanyID:      companyID,
-			CardNumber:     "4333333333333333",
 			CardHolderName: "Employee 1",
 			EmployeeID:     uuid.New().String(),
 			EmployeeEmail:  "employee1@example.com",
@@ -376,7 +424,7 @@ func TestUpdateSpendingControl(t *testing.T) {
 			DailyLimit:     floatPtr(200.00),
 			MonthlyLimit:   floatPtr(5000.00),
 			ExpiryDate:     time.Now().AddDate(3, 0, 0),
-			CVVHash:        "test-cvv-hash-1",
+			CVVMAC:         "test-cvv-hash-1",
 			LastFour:       "3333",
 		}
 
@@ -452,3 +500,92 @@ func TestUpdateSpendingControl(t *testing.T) {
 func floatPtr(v float64) *float64 {
 	return &v
 }
+
+func TestEncryptCardNumbers(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	cardRepo := card.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	cardVault, err := vault.New(helper.Config.Vault)
+	require.NoError(t, err)
+
+	companyID := uuid.New()
+	err = clientRepo.CreateCompany(ctx, &models.Company{
+		ID:       companyID,
+		ClientID: uuid.New(),
+		Name:     "Test Company",
+		Email:    "test-encrypt-cards@example.com",
+		Password: "hashed_password",
+		Status:   models.CompanyStatusActive,
+	})
+	require.NoError(t, err)
+
+	cardID := uuid.New()
+	err = clientRepo.CreateCardsInBatch(ctx, []*models.Card{{
+		ID:             cardID,
+		CompanyID:      companyID,
+		CardHolderName: "Employee 1",
+		EmployeeID:     uuid.New().String(),
+		EmployeeEmail:  "employee1@example.com",
+		CardType:       models.CardTypeVirtual,
+		Status:         models.CardStatusActive,
+		ExpiryDate:     time.Now().AddDate(3, 0, 0),
+		LastFour:       "1111",
+	}})
+	require.NoError(t, err)
+
+	// Simulate a card stored before PANs were encrypted.
+	_, err = helper.DB.ExecContext(ctx, `UPDATE cards SET card_number = '4111111111111111' WHERE id = $1`, cardID)
+	require.NoError(t, err)
+
+	legacy, err := cardRepo.GetCardSecret(ctx, companyID, cardID)
+	require.NoError(t, err)
+	require.NotNil(t, legacy)
+	assert.Nil(t, legacy.Secret)
+
+	encrypted, err := cardRepo.EncryptCardNumbers(ctx, func(pan string) (*models.CardSecret, error) {
+		return cardVault.SealCard(pan, "")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, encrypted)
+
+	var plaintext *string
+	err = helper.DB.QueryRowContext(ctx, `SELECT card_number FROM cards WHERE id = $1`, cardID).Scan(&plaintext)
+	require.NoError(t, err)
+	assert.Nil(t, plaintext)
+
+	stored, err := cardRepo.GetCardSecret(ctx, companyID, cardID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.Secret)
+
+	pan, cvv, err := cardVault.OpenCard(stored.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, "4111111111111111", pan)
+	assert.Empty(t, cvv)
+
+	encrypted, err = cardRepo.EncryptCardNumbers(ctx, func(pan string) (*models.CardSecret, error) {
+		return cardVault.SealCard(pan, "")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, encrypted)
+
+	migrate := func(id uuid.UUID, secret *models.CardSecret) (*models.CardSecret, string, error) {
+		return secret, cardVault.CVVMAC(id, "123"), nil
+	}
+	migrated, err := cardRepo.MigrateCVVs(ctx, migrate)
+	require.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+
+	migrated, err = cardRepo.MigrateCVVs(ctx, migrate)
+	require.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+
+	retrieved, err := cardRepo.GetCardByCompanyIDAndCardID(ctx, companyID, cardID)
+	require.NoError(t, err)
+	assert.True(t, cardVault.VerifyCVV(cardID, "123", retrieved.CVVMAC))
+
+	reveal := &models.CardReveal{CardID: cardID, CompanyID: companyID, ClientIP: "127.0.0.1"}
+	require.NoError(t, cardRepo.RecordReveal(ctx, reveal))
+	assert.NotEqual(t, uuid.Nil, reveal.ID)
+}
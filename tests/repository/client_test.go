@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"ccards/internal/client"
+	"ccards/pkg/errors"
 	"ccards/pkg/models"
 	"ccards/tests/setup"
 )
@@ -127,6 +128,48 @@ func TestGetCompanyByID(t *testing.T) {
 	})
 }
 
+func TestFundCompany(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	repo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	t.Run("credits_the_company_balance", func(t *testing.T) {
+		company := &models.Company{
+			ID:       uuid.New(),
+			ClientID: uuid.New(),
+			Name:     "Funded Company",
+			Email:    "funded@example.com",
+			Password: "hashed_password",
+			Status:   models.CompanyStatusActive,
+		}
+		require.NoError(t, repo.CreateCompany(ctx, company))
+
+		first := &models.CompanyFunding{ID: uuid.New(), CompanyID: company.ID, Amount: 150, Reference: "wire-0001"}
+		require.NoError(t, repo.FundCompany(ctx, first))
+		assert.Equal(t, 150.0, first.Balance)
+		assert.False(t, first.CreatedAt.IsZero())
+
+		second := &models.CompanyFunding{ID: uuid.New(), CompanyID: company.ID, Amount: 50.25}
+		require.NoError(t, repo.FundCompany(ctx, second))
+		assert.Equal(t, 200.25, second.Balance)
+
+		saved, err := repo.GetCompanyByID(ctx, company.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 200.25, saved.Balance)
+
+		var fundings int
+		require.NoError(t, helper.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM company_fundings WHERE company_id = $1", company.ID,
+		).Scan(&fundings))
+		assert.Equal(t, 2, fundings)
+	})
+
+	t.Run("company_not_found", func(t *testing.T) {
+		err := repo.FundCompany(ctx, &models.CompanyFunding{ID: uuid.New(), CompanyID: uuid.New(), Amount: 10})
+		require.ErrorIs(t, err, errors.ErrNotFound)
+	})
+}
+
 func TestGetCompanyByEmail(t *testing.T) {
 	helper := setup.NewTestHelper(t)
 	repo := client.NewRepository(helper.DB)
@@ -586,7 +629,6 @@ func TestCreateCardsInBatch(t *testing.T) {
 		card := &models.Card{
 			ID:             cardID,
 			CompanyID:      companyID,
-			CardNumber:     "4111111111111111",
 			CardHolderName: "Test Employee",
 			EmployeeID:     uuid.New().String(),
 			EmployeeEmail:  "employee1@example.com",
@@ -594,7 +636,7 @@ func TestCreateCardsInBatch(t *testing.T) {
 			Status:         models.CardStatusActive,
 			Balance:        0.00,
 			ExpiryDate:     time.Now().AddDate(3, 0, 0),
-			CVVHash:        "test-cvv-hash",
+			CVVMAC:         "test-cvv-hash",
 			LastFour:       "1111",
 			CreatedAt:      time.Now(),
 			UpdatedAt:      time.Now(),
@@ -610,13 +652,13 @@ func TestCreateCardsInBatch(t *testing.T) {
 
 		var retrievedCard models.Card
 		err = helper.DB.QueryRowContext(ctx, `
-			SELECT id, company_id, card_number, card_holder_name, employee_id, employee_email, 
+			SELECT id, company_id, token, card_holder_name, employee_id, employee_email, 
 			       card_type, status, balance, last_four
 			FROM cards WHERE id = $1
 		`, cardID).Scan(
 			&retrievedCard.ID,
 			&retrievedCard.CompanyID,
-			&retrievedCard.CardNumber,
+			&retrievedCard.Token,
 			&retrievedCard.CardHolderName,
 			&retrievedCard.EmployeeID,
 			&retrievedCard.EmployeeEmail,
@@ -629,7 +671,7 @@ func TestCreateCardsInBatch(t *testing.T) {
 
 		assert.Equal(t, card.ID, retrievedCard.ID)
 		assert.Equal(t, card.CompanyID, retrievedCard.CompanyID)
-		assert.Equal(t, card.CardNumber, retrievedCard.CardNumber)
+		assert.Equal(t, card.Token, retrievedCard.Token)
 		assert.Equal(t, card.CardHolderName, retrievedCard.CardHolderName)
 		assert.Equal(t, card.EmployeeID, retrievedCard.EmployeeID)
 		assert.Equal(t, card.EmployeeEmail, retrievedCard.EmployeeEmail)
@@ -664,7 +706,6 @@ func TestCreateCardsInBatch(t *testing.T) {
 			{
 				ID:             uuid.New(),
 				CompanyID:      companyID,
-				CardNumber:     "4222222222222222",
 				CardHolderName: "Employee 2",
 				EmployeeID:     uuid.New().String(),
 				EmployeeEmail:  "employee2@example.com",
@@ -672,7 +713,7 @@ func TestCreateCardsInBatch(t *testing.T) {
 				Status:         models.CardStatusActive,
 				Balance:        0.00,
 				ExpiryDate:     time.Now().AddDate(3, 0, 0),
-				CVVHash:        "test-cvv-hash-2",
+				CVVMAC:         "test-cvv-hash-2",
 				LastFour:       "2222",
 				CreatedAt:      time.Now(),
 				UpdatedAt:      time.Now(),
@@ -680,7 +721,6 @@ func TestCreateCardsInBatch(t *testing.T) {
 			{
 				ID:             uuid.New(),
 				CompanyID:      companyID,
-				CardNumber:     "4333333333333333",
 				CardHolderName: "Employee 3",
 				EmployeeID:     uuid.New().String(),
 				EmployeeEmail:  "employee3@example.com",
@@ -688,7 +728,7 @@ func TestCreateCardsInBatch(t *testing.T) {
 				Status:         models.CardStatusActive,
 				Balance:        0.00,
 				ExpiryDate:     time.Now().AddDate(3, 0, 0),
-				CVVHash:        "test-cvv-hash-3",
+				CVVMAC:         "test-cvv-hash-3",
 				LastFour:       "3333",
 				CreatedAt:      time.Now(),
 				UpdatedAt:      time.Now(),
@@ -706,13 +746,13 @@ func TestCreateCardsInBatch(t *testing.T) {
 		for _, card := range cards {
 			var retrievedCard models.Card
 			err = helper.DB.QueryRowContext(ctx, `
-				SELECT id, company_id, card_number, card_holder_name, employee_id, employee_email, 
+				SELECT id, company_id, token, card_holder_name, employee_id, employee_email, 
 					   card_type, status, balance, last_four
 				FROM cards WHERE id = $1
 			`, card.ID).Scan(
 				&retrievedCard.ID,
 				&retrievedCard.CompanyID,
-				&retrievedCard.CardNumber,
+				&retrievedCard.Token,
 				&retrievedCard.CardHolderName,
 				&retrievedCard.EmployeeID,
 				&retrievedCard.EmployeeEmail,
@@ -725,7 +765,7 @@ func TestCreateCardsInBatch(t *testing.T) {
 
 			assert.Equal(t, card.ID, retrievedCard.ID)
 			assert.Equal(t, card.CompanyID, retrievedCard.CompanyID)
-			assert.Equal(t, card.CardNumber, retrievedCard.CardNumber)
+			assert.Equal(t, card.Token, retrievedCard.Token)
 			assert.Equal(t, card.CardHolderName, retrievedCard.CardHolderName)
 			assert.Equal(t, card.EmployeeID, retrievedCard.EmployeeID)
 			assert.Equal(t, card.EmployeeEmail, retrievedCard.EmployeeEmail)
@@ -742,107 +782,177 @@ func TestCreateCardsInBatch(t *testing.T) {
 	})
 }
 
-func TestUpdateCardsToIssueStatusBatch(t *testing.T) {
+func TestIssuePendingCards(t *testing.T) {
 	helper := setup.NewTestHelper(t)
 	repo := client.NewRepository(helper.DB)
 	ctx := context.Background()
 
-	t.Run("update_multiple_cards", func(t *testing.T) {
+	setupPending := func(t *testing.T, name string, count int) (uuid.UUID, []*models.CardToIssue) {
 		companyID := uuid.New()
-		cardIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+		company := &models.Company{
+			ID:       companyID,
+			ClientID: uuid.New(),
+			Name:     "Issuing Company",
+			Email:    fmt.Sprintf("%s@example.com", name),
+			Password: "hashed_password",
+			Address:  "123 Test St",
+			Phone:    "123-456-7890",
+			Status:   models.CompanyStatusActive,
+		}
+		require.NoError(t, repo.CreateCompany(ctx, company))
 
-		var cards []*models.CardToIssue
-		for i, id := range cardIDs {
-			card := &models.CardToIssue{
-				ID:            id,
+		var pending []*models.CardToIssue
+		for i := 0; i < count; i++ {
+			pending = append(pending, &models.CardToIssue{
+				ID:            uuid.New(),
 				ClientID:      companyID,
 				CardID:        uuid.New(),
 				EmployeeID:    uuid.New(),
-				EmployeeEmail: fmt.Sprintf("batch-update-%d@example.com", i),
+				EmployeeEmail: fmt.Sprintf("%s-%d@example.com", name, i),
 				Status:        models.CardToIssueStatusPending,
 				CreatedAt:     time.Now(),
 				UpdatedAt:     time.Now(),
-			}
-			cards = append(cards, card)
+			})
 		}
+		require.NoError(t, repo.CreateCardsToIssue(ctx, pending))
+
+		return companyID, pending
+	}
+
+	build := func(ctx context.Context, pending []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, error) {
+		var cards []*models.Card
+		for _, p := range pending {
+			cards = append(cards, &models.Card{
+				ID:             p.CardID,
+				CompanyID:      p.ClientID,
+				CardHolderName: "Test Employee",
+				EmployeeID:     p.EmployeeID.String(),
+				EmployeeEmail:  p.EmployeeEmail,
+				CardType:       models.CardTypeVirtual,
+				Status:         models.CardStatusActive,
+				ExpiryDate:     time.Now().AddDate(3, 0, 0),
+				CVVMAC:         "test-cvv-hash",
+				LastFour:       "1111",
+				CreatedAt:      time.Now(),
+				UpdatedAt:      time.Now(),
+			})
+		}
+		return cards, nil, nil
+	}
 
-		err := repo.CreateCardsToIssue(ctx, cards)
+	countCards := func(t *testing.T, companyID uuid.UUID) int {
+		var count int
+		err := helper.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM cards WHERE company_id = $1", companyID).Scan(&count)
+		require.NoError(t, err)
+		return count
+	}
+
+	t.Run("repeated_calls_issue_once", func(t *testing.T) {
+		companyID, pending := setupPending(t, "issue-once", 3)
+
+		issued, err := repo.IssuePendingCards(ctx, companyID, nil, build)
 		require.NoError(t, err)
+		assert.Equal(t, 3, issued)
 
-		err = repo.UpdateCardsToIssueStatusBatch(ctx, cardIDs, models.CardToIssueStatusGenerated)
+		issued, err = repo.IssuePendingCards(ctx, companyID, nil, build)
 		require.NoError(t, err)
+		assert.Equal(t, 0, issued)
 
-		for _, id := range cardIDs {
+		assert.Equal(t, 3, countCards(t, companyID))
+		for _, p := range pending {
 			var status string
-			err = helper.DB.QueryRowContext(ctx, "SELECT status FROM cards_to_issue WHERE id = $1", id).Scan(&status)
+			err := helper.DB.QueryRowContext(ctx, "SELECT status FROM cards_to_issue WHERE id = $1", p.ID).Scan(&status)
 			require.NoError(t, err)
 			assert.Equal(t, models.CardToIssueStatusGenerated, status)
 		}
 	})
 
-	t.Run("update_subset_of_cards", func(t *testing.T) {
-		companyID := uuid.New()
-		allCardIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	t.Run("concurrent_calls_issue_once", func(t *testing.T) {
+		companyID, _ := setupPending(t, "issue-concurrent", 20)
+
+		const callers = 5
+		results := make(chan int, callers)
+		errs := make(chan error, callers)
+		for i := 0; i < callers; i++ {
+			go func() {
+				issued, err := repo.IssuePendingCards(ctx, companyID, nil, build)
+				results <- issued
+				errs <- err
+			}()
+		}
 
-		var cards []*models.CardToIssue
-		for i, id := range allCardIDs {
-			card := &models.CardToIssue{
-				ID:            id,
-				ClientID:      companyID,
-				CardID:        uuid.New(),
-				EmployeeID:    uuid.New(),
-				EmployeeEmail: fmt.Sprintf("batch-subset-%d@example.com", i),
-				Status:        models.CardToIssueStatusPending,
-				CreatedAt:     time.Now(),
-				UpdatedAt:     time.Now(),
-			}
-			cards = append(cards, card)
+		total := 0
+		for i := 0; i < callers; i++ {
+			total += <-results
+			require.NoError(t, <-errs)
 		}
 
-		err := repo.CreateCardsToIssue(ctx, cards)
-		require.NoError(t, err)
+		assert.Equal(t, 20, total)
+		assert.Equal(t, 20, countCards(t, companyID))
+	})
 
-		updateIDs := allCardIDs[:2]
-		err = repo.UpdateCardsToIssueStatusBatch(ctx, updateIDs, models.CardToIssueStatusGenerated)
-		require.NoError(t, err)
+	t.Run("failure_rolls_back_everything", func(t *testing.T) {
+		companyID, pending := setupPending(t, "issue-rollback", 2)
+
+		// The control references a card that does not exist, so the insert
+		// fails after the cards have been written in the same transaction.
+		failing := func(ctx context.Context, rows []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, error) {
+			cards, _, err := build(ctx, rows)
+			controls := []*models.SpendingControl{
+				{CardID: uuid.New(), ControlType: "merchant_category", ControlValue: []string{"5411"}, IsActive: true},
+			}
+			return cards, controls, err
+		}
+
+		_, err := repo.IssuePendingCards(ctx, companyID, nil, failing)
+		require.Error(t, err)
 
-		for i, id := range allCardIDs {
+		assert.Equal(t, 0, countCards(t, companyID))
+		for _, p := range pending {
 			var status string
-			err = helper.DB.QueryRowContext(ctx, "SELECT status FROM cards_to_issue WHERE id = $1", id).Scan(&status)
+			err := helper.DB.QueryRowContext(ctx, "SELECT status FROM cards_to_issue WHERE id = $1", p.ID).Scan(&status)
 			require.NoError(t, err)
-
-			if i < 2 {
-				assert.Equal(t, models.CardToIssueStatusGenerated, status)
-			} else {
-				assert.Equal(t, models.CardToIssueStatusPending, status)
-			}
+			assert.Equal(t, models.CardToIssueStatusPending, status)
 		}
 	})
 
-	t.Run("empty_ids_slice", func(t *testing.T) {
-		err := repo.UpdateCardsToIssueStatusBatch(ctx, []uuid.UUID{}, models.CardToIssueStatusGenerated)
-		require.NoError(t, err, "Updating empty IDs slice should not return an error")
-	})
+	t.Run("initial_funding_debits_the_company", func(t *testing.T) {
+		companyID, _ := setupPending(t, "issue-funded", 2)
+		err := repo.FundCompany(ctx, &models.CompanyFunding{ID: uuid.New(), CompanyID: companyID, Amount: 100})
+		require.NoError(t, err)
 
-	t.Run("update_with_invalid_status", func(t *testing.T) {
-		cardID := uuid.New()
-		card := &models.CardToIssue{
-			ID:            cardID,
-			ClientID:      uuid.New(),
-			CardID:        uuid.New(),
-			EmployeeID:    uuid.New(),
-			EmployeeEmail: "batch-invalid@example.com",
-			Status:        models.CardToIssueStatusPending,
-			CreatedAt:     time.Now(),
-			UpdatedAt:     time.Now(),
+		department := "Engineering"
+		funded := func(balance float64) client.CardBuilder {
+			return func(ctx context.Context, rows []*models.CardToIssue) ([]*models.Card, []*models.SpendingControl, error) {
+				cards, controls, err := build(ctx, rows)
+				for _, card := range cards {
+					card.Balance = balance
+					card.Department = &department
+				}
+				return cards, controls, err
+			}
 		}
 
-		err := repo.CreateCardsToIssue(ctx, []*models.CardToIssue{card})
+		_, err = repo.IssuePendingCards(ctx, companyID, nil, funded(60))
+		require.ErrorIs(t, err, errors.ErrInsufficientFunds)
+		assert.Equal(t, 0, countCards(t, companyID))
+
+		issued, err := repo.IssuePendingCards(ctx, companyID, nil, funded(40))
 		require.NoError(t, err)
+		assert.Equal(t, 2, issued)
 
-		invalidStatus := "invalid_status"
-		err = repo.UpdateCardsToIssueStatusBatch(ctx, []uuid.UUID{cardID}, invalidStatus)
+		var companyBalance float64
+		require.NoError(t, helper.DB.QueryRowContext(ctx, "SELECT balance FROM companies WHERE id = $1", companyID).Scan(&companyBalance))
+		assert.Equal(t, 20.0, companyBalance)
 
-		require.Error(t, err)
+		var fundings int
+		var cardDepartment string
+		require.NoError(t, helper.DB.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM transactions WHERE company_id = $1 AND transaction_type = $2 AND amount = 40",
+			companyID, models.TransactionTypeFunding,
+		).Scan(&fundings))
+		require.NoError(t, helper.DB.QueryRowContext(ctx, "SELECT department FROM cards WHERE company_id = $1 LIMIT 1", companyID).Scan(&cardDepartment))
+		assert.Equal(t, 2, fundings)
+		assert.Equal(t, department, cardDepartment)
 	})
 }
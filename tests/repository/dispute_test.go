@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/dispute"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+func TestDisputeLedger(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	disputeRepo := dispute.NewRepository(db)
+	clientRepo := client.NewRepository(db)
+	ctx := context.Background()
+
+	insertPurchase := func(t *testing.T, company *models.Company, card *models.Card, amount float64) uuid.UUID {
+		id := uuid.New()
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO transactions (id, card_id, company_id, transaction_type, amount, merchant_category, status)
+			VALUES ($1, $2, $3, $4, $5, 'retail', $6)`,
+			id, card.ID, company.ID, models.TransactionTypePurchase, amount, models.TransactionStatusCompleted,
+		)
+		require.NoError(t, err)
+		return id
+	}
+
+	fileDispute := func(t *testing.T, company *models.Company, card *models.Card, transactionID uuid.UUID, amount float64) *models.Dispute {
+		d := &models.Dispute{
+			ID:            uuid.New(),
+			CompanyID:     company.ID,
+			CardID:        card.ID,
+			TransactionID: transactionID,
+			Reason:        "not_received",
+			Amount:        amount,
+			Status:        models.DisputeStatusOpened,
+			EvidenceDueAt: time.Now().Add(dispute.EvidenceWindow),
+			ResolveBy:     time.Now().Add(dispute.ResolutionWindow),
+		}
+		require.NoError(t, disputeRepo.CreateDispute(ctx, d))
+		return d
+	}
+
+	balances := func(t *testing.T, card *models.Card) (float64, float64) {
+		var cardBalance, companyBalance float64
+		err := db.QueryRowContext(ctx, `
+			SELECT c.balance, co.balance FROM cards c JOIN companies co ON co.id = c.company_id WHERE c.id = $1`,
+			card.ID,
+		).Scan(&cardBalance, &companyBalance)
+		require.NoError(t, err)
+		return cardBalance, companyBalance
+	}
+
+	t.Run("provisional_credit", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txID := insertPurchase(t, company, card, 80)
+
+		d := fileDispute(t, company, card, txID, 80)
+		require.NotNil(t, d.ProvisionalCreditTransactionID)
+
+		cardBalance, _ := balances(t, card)
+		assert.Equal(t, 1080.0, cardBalance)
+
+		var transactionType string
+		var amount float64
+		err := db.QueryRowContext(ctx, `SELECT transaction_type, amount FROM transactions WHERE id = $1`,
+			*d.ProvisionalCreditTransactionID).Scan(&transactionType, &amount)
+		require.NoError(t, err)
+		assert.Equal(t, models.TransactionTypeProvisionalCredit, transactionType)
+		assert.Equal(t, 80.0, amount)
+
+		stored, err := disputeRepo.GetDispute(ctx, company.ID, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DisputeStatusOpened, stored.Status)
+		assert.Empty(t, stored.Evidence)
+
+		other, err := disputeRepo.GetDispute(ctx, uuid.New(), d.ID)
+		require.NoError(t, err)
+		assert.Nil(t, other)
+	})
+
+	t.Run("duplicate_dispute", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txID := insertPurchase(t, company, card, 80)
+		fileDispute(t, company, card, txID, 80)
+
+		err := disputeRepo.CreateDispute(ctx, &models.Dispute{
+			ID:            uuid.New(),
+			CompanyID:     company.ID,
+			CardID:        card.ID,
+			TransactionID: txID,
+			Reason:        "duplicate",
+			Amount:        10,
+			Status:        models.DisputeStatusOpened,
+			EvidenceDueAt: time.Now(),
+			ResolveBy:     time.Now(),
+		})
+		assert.True(t, errors.Is(err, errors.ErrDisputeExists))
+
+		cardBalance, _ := balances(t, card)
+		assert.Equal(t, 1080.0, cardBalance)
+	})
+
+	t.Run("credits_capped_at_purchase", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txID := insertPurchase(t, company, card, 80)
+		_, err := db.ExecContext(ctx, `UPDATE transactions SET refunded_amount = 60 WHERE id = $1`, txID)
+		require.NoError(t, err)
+
+		err = disputeRepo.CreateDispute(ctx, &models.Dispute{
+			ID:            uuid.New(),
+			CompanyID:     company.ID,
+			CardID:        card.ID,
+			TransactionID: txID,
+			Reason:        "incorrect_amount",
+			Amount:        30,
+			Status:        models.DisputeStatusOpened,
+			EvidenceDueAt: time.Now(),
+			ResolveBy:     time.Now(),
+		})
+		assert.True(t, errors.Is(err, errors.ErrDisputeAmount))
+
+		fileDispute(t, company, card, txID, 20)
+		cardBalance, _ := balances(t, card)
+		assert.Equal(t, 1020.0, cardBalance)
+	})
+
+	t.Run("lost_reverses_credit", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txID := insertPurchase(t, company, card, 300)
+		d := fileDispute(t, company, card, txID, 300)
+
+		_, err := disputeRepo.ResolveDispute(ctx, company.ID, d.ID, models.DisputeStatusLost, nil)
+		assert.True(t, errors.Is(err, errors.ErrDisputeTransition), "resolving needs review first")
+
+		_, err = disputeRepo.UpdateDisputeStatus(ctx, company.ID, d.ID, models.DisputeStatusOpened, models.DisputeStatusUnderReview)
+		require.NoError(t, err)
+
+		// The card has spent part of the credit; the shortfall is charged to
+		// the company.
+		_, err = db.ExecContext(ctx, `UPDATE cards SET balance = 100 WHERE id = $1`, card.ID)
+		require.NoError(t, err)
+		_, companyBefore := balances(t, card)
+
+		note := "Merchant proved delivery"
+		resolved, err := disputeRepo.ResolveDispute(ctx, company.ID, d.ID, models.DisputeStatusLost, &note)
+		require.NoError(t, err)
+		assert.Equal(t, models.DisputeStatusLost, resolved.Status)
+		assert.NotNil(t, resolved.ResolvedAt)
+		require.NotNil(t, resolved.ReversalTransactionID)
+
+		cardBalance, companyBalance := balances(t, card)
+		assert.Equal(t, 0.0, cardBalance)
+		assert.Equal(t, companyBefore-200, companyBalance)
+	})
+
+	t.Run("won_keeps_credit", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txID := insertPurchase(t, company, card, 50)
+		d := fileDispute(t, company, card, txID, 50)
+
+		_, err := disputeRepo.UpdateDisputeStatus(ctx, company.ID, d.ID, models.DisputeStatusOpened, models.DisputeStatusUnderReview)
+		require.NoError(t, err)
+
+		resolved, err := disputeRepo.ResolveDispute(ctx, company.ID, d.ID, models.DisputeStatusWon, nil)
+		require.NoError(t, err)
+		assert.Nil(t, resolved.ReversalTransactionID)
+
+		cardBalance, _ := balances(t, card)
+		assert.Equal(t, 1050.0, cardBalance)
+	})
+
+	t.Run("evidence", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txID := insertPurchase(t, company, card, 50)
+		d := fileDispute(t, company, card, txID, 50)
+
+		evidence := &models.DisputeEvidence{
+			ID:          uuid.New(),
+			DisputeID:   d.ID,
+			FileName:    "receipt.txt",
+			ContentType: "text/plain",
+			SizeBytes:   7,
+			Content:     []byte("receipt"),
+		}
+		require.NoError(t, disputeRepo.AddEvidence(ctx, evidence))
+
+		stored, err := disputeRepo.GetEvidence(ctx, company.ID, d.ID, evidence.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("receipt"), stored.Content)
+
+		other, err := disputeRepo.GetEvidence(ctx, uuid.New(), d.ID, evidence.ID)
+		require.NoError(t, err)
+		assert.Nil(t, other)
+
+		withEvidence, err := disputeRepo.GetDispute(ctx, company.ID, d.ID)
+		require.NoError(t, err)
+		require.Len(t, withEvidence.Evidence, 1)
+		assert.Nil(t, withEvidence.Evidence[0].Content)
+	})
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/employee"
+	"ccards/internal/gateway"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+func createEmployeeTestCompany(t *testing.T, ctx context.Context, clientRepo client.Repository) *models.Company {
+	company := &models.Company{
+		ID:       uuid.New(),
+		ClientID: uuid.New(),
+		Name:     "Employee Test Company",
+		Email:    "employee-test-" + uuid.New().String()[:8] + "@example.com",
+		Password: "hashed_password",
+		Status:   models.CompanyStatusActive,
+	}
+	require.NoError(t, clientRepo.CreateCompany(ctx, company))
+	return company
+}
+
+func TestCreateEmployee(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	employeeRepo := employee.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	company := createEmployeeTestCompany(t, ctx, clientRepo)
+
+	t.Run("success", func(t *testing.T) {
+		department := "Engineering"
+		emp := &models.Employee{
+			ID:         uuid.New(),
+			CompanyID:  company.ID,
+			Email:      "jane.doe@example.com",
+			Name:       "Jane Doe",
+			Department: &department,
+			Status:     models.EmployeeStatusActive,
+		}
+
+		err := employeeRepo.CreateEmployee(ctx, emp)
+		require.NoError(t, err)
+		assert.False(t, emp.CreatedAt.IsZero())
+
+		retrieved, err := employeeRepo.GetEmployeeByID(ctx, company.ID, emp.ID)
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, emp.Name, retrieved.Name)
+		assert.Equal(t, emp.Email, retrieved.Email)
+		assert.Equal(t, department, *retrieved.Department)
+	})
+
+	t.Run("duplicate_email", func(t *testing.T) {
+		emp := &models.Employee{
+			ID:        uuid.New(),
+			CompanyID: company.ID,
+			Email:     "duplicate@example.com",
+			Name:      "First",
+			Status:    models.EmployeeStatusActive,
+		}
+		require.NoError(t, employeeRepo.CreateEmployee(ctx, emp))
+
+		duplicate := &models.Employee{
+			ID:        uuid.New(),
+			CompanyID: company.ID,
+			Email:     "duplicate@example.com",
+			Name:      "Second",
+			Status:    models.EmployeeStatusActive,
+		}
+		err := employeeRepo.CreateEmployee(ctx, duplicate)
+		assert.Equal(t, errors.ErrEmployeeExists, err)
+	})
+
+	t.Run("other_company_not_visible", func(t *testing.T) {
+		emp := &models.Employee{
+			ID:        uuid.New(),
+			CompanyID: company.ID,
+			Email:     "scoped@example.com",
+			Name:      "Scoped",
+			Status:    models.EmployeeStatusActive,
+		}
+		require.NoError(t, employeeRepo.CreateEmployee(ctx, emp))
+
+		retrieved, err := employeeRepo.GetEmployeeByID(ctx, uuid.New(), emp.ID)
+		require.NoError(t, err)
+		assert.Nil(t, retrieved)
+	})
+}
+
+func TestGetCardsByEmployeeID(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	employeeRepo := employee.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	company := createEmployeeTestCompany(t, ctx, clientRepo)
+
+	emp := &models.Employee{
+		ID:        uuid.New(),
+		CompanyID: company.ID,
+		Email:     "holder@example.com",
+		Name:      "Card Holder",
+		Status:    models.EmployeeStatusActive,
+	}
+	require.NoError(t, employeeRepo.CreateEmployee(ctx, emp))
+
+	linkedCard := &models.Card{
+		ID:             uuid.New(),
+		CompanyID:      company.ID,
+		CardHolderName: emp.Name,
+		EmployeeID:     emp.ID.String(),
+		EmployeeEmail:  emp.Email,
+		EmployeeRefID:  &emp.ID,
+		CardType:       models.CardTypeVirtual,
+		Status:         models.CardStatusActive,
+		ExpiryDate:     time.Now().AddDate(3, 0, 0),
+		CVVMAC:         "test-cvv-hash",
+		LastFour:       "1111",
+	}
+	otherCard := &models.Card{
+		ID:             uuid.New(),
+		CompanyID:      company.ID,
+		CardHolderName: "Someone Else",
+		EmployeeID:     uuid.New().String(),
+		EmployeeEmail:  "someone@example.com",
+		CardType:       models.CardTypeVirtual,
+		Status:         models.CardStatusActive,
+		ExpiryDate:     time.Now().AddDate(3, 0, 0),
+		CVVMAC:         "test-cvv-hash",
+		LastFour:       "2222",
+	}
+	require.NoError(t, clientRepo.CreateCardsInBatch(ctx, []*models.Card{linkedCard, otherCard}))
+
+	cards, err := employeeRepo.GetCardsByEmployeeID(ctx, company.ID, emp.ID)
+	require.NoError(t, err)
+	require.Len(t, cards, 1)
+	assert.Equal(t, linkedCard.ID, cards[0].ID)
+	require.NotNil(t, cards[0].EmployeeRefID)
+	assert.Equal(t, emp.ID, *cards[0].EmployeeRefID)
+}
+
+func TestOffboardCard(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	employeeRepo := employee.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	company := createEmployeeTestCompany(t, ctx, clientRepo)
+
+	emp := &models.Employee{
+		ID:        uuid.New(),
+		CompanyID: company.ID,
+		Email:     "leaver@example.com",
+		Name:      "Leaver",
+		Status:    models.EmployeeStatusActive,
+	}
+	require.NoError(t, employeeRepo.CreateEmployee(ctx, emp))
+
+	card := &models.Card{
+		ID:             uuid.New(),
+		CompanyID:      company.ID,
+		CardHolderName: emp.Name,
+		EmployeeID:     emp.ID.String(),
+		EmployeeEmail:  emp.Email,
+		EmployeeRefID:  &emp.ID,
+		CardType:       models.CardTypeVirtual,
+		Status:         models.CardStatusActive,
+		Balance:        250,
+		ExpiryDate:     time.Now().AddDate(3, 0, 0),
+		CVVMAC:         "test-cvv-hash",
+		LastFour:       "3333",
+	}
+	require.NoError(t, clientRepo.CreateCardsInBatch(ctx, []*models.Card{card}))
+
+	offboarding := &models.EmployeeOffboarding{
+		ID:         uuid.New(),
+		CompanyID:  company.ID,
+		EmployeeID: emp.ID,
+		CardAction: models.OffboardingCardActionBlock,
+	}
+	require.NoError(t, employeeRepo.StartOffboarding(ctx, offboarding))
+
+	// A retry with the same card action resumes the offboarding; a different
+	// one is refused.
+	retried := &models.EmployeeOffboarding{ID: uuid.New(), CompanyID: company.ID, EmployeeID: emp.ID, CardAction: models.OffboardingCardActionBlock}
+	require.NoError(t, employeeRepo.StartOffboarding(ctx, retried))
+	assert.Equal(t, offboarding.ID, retried.ID)
+
+	changed := &models.EmployeeOffboarding{ID: uuid.New(), CompanyID: company.ID, EmployeeID: emp.ID, CardAction: models.OffboardingCardActionCancel}
+	assert.ErrorIs(t, employeeRepo.StartOffboarding(ctx, changed), errors.ErrOffboardingConflict)
+
+	// An open gateway hold on the card.
+	gatewayRepo := gateway.NewRepository(helper.DB)
+	hold := &models.Transaction{
+		ID:              uuid.New(),
+		CardID:          card.ID,
+		CompanyID:       company.ID,
+		TransactionType: models.TransactionTypePurchase,
+		Amount:          100,
+		Description:     "Card purchase",
+		Status:          models.TransactionStatusPending,
+		Channel:         models.TransactionChannelChip,
+	}
+	require.NoError(t, gatewayRepo.CreateAuthorization(ctx, &models.GatewayAuthorization{
+		ID:            uuid.New(),
+		TransactionID: &hold.ID,
+		CardID:        &card.ID,
+		MTI:           "0100",
+		STAN:          "000001",
+		RRN:           "OFFBOARD0001",
+		TerminalID:    "TERM0001",
+		AuthCode:      "123456",
+		ResponseCode:  "00",
+		Amount:        hold.Amount,
+		Status:        models.GatewayAuthorizationStatusAuthorized,
+	}, hold))
+
+	result, err := employeeRepo.OffboardCard(ctx, offboarding, card.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.CardStatusActive, result.PreviousStatus)
+	assert.Equal(t, models.CardStatusBlocked, result.NewStatus)
+	assert.Equal(t, 250.0, result.BalanceReturned)
+	assert.Equal(t, 1, result.HoldsVoided)
+
+	// The hold was reversed, so its completion advice is refused.
+	_, err = gatewayRepo.CompleteAuthorization(ctx, "TERM0001", "OFFBOARD0001", 100)
+	assert.ErrorIs(t, err, errors.ErrPaymentTransition)
+
+	var status string
+	var balance, companyBalance float64
+	require.NoError(t, helper.DB.QueryRow(`SELECT status, balance FROM cards WHERE id = $1`, card.ID).Scan(&status, &balance))
+	require.NoError(t, helper.DB.QueryRow(`SELECT balance FROM companies WHERE id = $1`, company.ID).Scan(&companyBalance))
+	assert.Equal(t, models.CardStatusBlocked, status)
+	assert.Equal(t, 0.0, balance)
+	assert.Equal(t, 250.0, companyBalance)
+
+	// Retrying the same card returns the stored result without sweeping twice.
+	retry, err := employeeRepo.OffboardCard(ctx, offboarding, card.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 250.0, retry.BalanceReturned)
+
+	require.NoError(t, helper.DB.QueryRow(`SELECT balance FROM companies WHERE id = $1`, company.ID).Scan(&companyBalance))
+	assert.Equal(t, 250.0, companyBalance)
+}
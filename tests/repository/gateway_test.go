@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/card"
+	"ccards/internal/client"
+	"ccards/internal/gateway"
+	"ccards/internal/transaction"
+	"ccards/pkg/authorization"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/iso8583"
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+	"ccards/tests/setup"
+)
+
+func TestGatewayAuthorizations(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	gatewayRepo := gateway.NewRepository(db)
+	clientRepo := client.NewRepository(db)
+	ctx := context.Background()
+
+	// authorize approves a financial request, or places a hold for an
+	// authorization request.
+	authorize := func(t *testing.T, mti string, card *models.Card, amount float64, rrn string) (*models.GatewayAuthorization, error) {
+		now := time.Now()
+		category := "groceries"
+		purchase := &models.Transaction{
+			ID:               uuid.New(),
+			CardID:           card.ID,
+			CompanyID:        card.CompanyID,
+			TransactionType:  models.TransactionTypePurchase,
+			Amount:           amount,
+			MerchantCategory: &category,
+			Description:      "Card purchase",
+			Status:           models.TransactionStatusCompleted,
+			Channel:          models.TransactionChannelChip,
+			ProcessedAt:      &now,
+		}
+		approval := &models.GatewayAuthorization{
+			ID:            uuid.New(),
+			TransactionID: &purchase.ID,
+			CardID:        &card.ID,
+			MTI:           mti,
+			STAN:          "000001",
+			RRN:           rrn,
+			TerminalID:    "TERM0001",
+			AuthCode:      "123456",
+			ResponseCode:  "00",
+			Amount:        amount,
+			Status:        models.GatewayAuthorizationStatusApproved,
+		}
+		if mti == "0100" {
+			purchase.Status = models.TransactionStatusPending
+			purchase.ProcessedAt = nil
+			approval.Status = models.GatewayAuthorizationStatusAuthorized
+		}
+		return approval, gatewayRepo.CreateAuthorization(ctx, approval, purchase)
+	}
+
+	transactionStatus := func(t *testing.T, approval *models.GatewayAuthorization) (string, float64) {
+		var status string
+		var amount float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT status, amount FROM transactions WHERE id = $1`, approval.TransactionID).Scan(&status, &amount))
+		return status, amount
+	}
+
+	cardBalance := func(t *testing.T, card *models.Card) float64 {
+		var balance float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT balance FROM cards WHERE id = $1`, card.ID).Scan(&balance))
+		return balance
+	}
+
+	t.Run("authorize_and_reverse", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		rrn := uuid.NewString()[:12]
+
+		approval, err := authorize(t, "0200", card, 100, rrn)
+		require.NoError(t, err)
+		assert.Equal(t, 900.0, cardBalance(t, card))
+
+		_, err = authorize(t, "0200", card, 100, rrn)
+		assert.ErrorIs(t, err, errors.ErrPaymentExists)
+		assert.Equal(t, 900.0, cardBalance(t, card))
+
+		reversed, err := gatewayRepo.ReverseAuthorization(ctx, "TERM0001", rrn)
+		require.NoError(t, err)
+		assert.Equal(t, models.GatewayAuthorizationStatusReversed, reversed.Status)
+		assert.NotNil(t, reversed.ReversedAt)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+
+		status, _ := transactionStatus(t, approval)
+		assert.Equal(t, models.TransactionStatusVoided, status)
+
+		// A repeated reversal is answered without crediting the card again.
+		again, err := gatewayRepo.ReverseAuthorization(ctx, "TERM0001", rrn)
+		require.NoError(t, err)
+		assert.Equal(t, models.GatewayAuthorizationStatusReversed, again.Status)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+
+		missing, err := gatewayRepo.ReverseAuthorization(ctx, "TERM0002", rrn)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("hold_and_complete", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		rrn := uuid.NewString()[:12]
+
+		hold, err := authorize(t, "0100", card, 600, rrn)
+		require.NoError(t, err)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+		status, _ := transactionStatus(t, hold)
+		assert.Equal(t, models.TransactionStatusPending, status)
+
+		found, err := gatewayRepo.GetAuthorization(ctx, "TERM0001", rrn)
+		require.NoError(t, err)
+		assert.Equal(t, models.GatewayAuthorizationStatusAuthorized, found.Status)
+		assert.Equal(t, "00", found.ResponseCode)
+
+		// The held amount is not available to other gateway payments.
+		_, err = authorize(t, "0200", card, 500, uuid.NewString()[:12])
+		assert.ErrorIs(t, err, errors.ErrInsufficientFunds)
+
+		_, err = gatewayRepo.CompleteAuthorization(ctx, "TERM0001", rrn, 700)
+		assert.ErrorIs(t, err, errors.ErrPaymentAmount)
+
+		completed, err := gatewayRepo.CompleteAuthorization(ctx, "TERM0001", rrn, 450)
+		require.NoError(t, err)
+		assert.Equal(t, models.GatewayAuthorizationStatusCompleted, completed.Status)
+		assert.Equal(t, 450.0, completed.Amount)
+		assert.NotNil(t, completed.CompletedAt)
+		assert.Equal(t, 550.0, cardBalance(t, card))
+		status, amount := transactionStatus(t, hold)
+		assert.Equal(t, models.TransactionStatusCompleted, status)
+		assert.Equal(t, 450.0, amount)
+
+		// A repeated advice is answered without debiting the card again.
+		_, err = gatewayRepo.CompleteAuthorization(ctx, "TERM0001", rrn, 450)
+		require.NoError(t, err)
+		assert.Equal(t, 550.0, cardBalance(t, card))
+
+		missing, err := gatewayRepo.CompleteAuthorization(ctx, "TERM0002", rrn, 450)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("hold_not_available_to_other_payments", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		rrn := uuid.NewString()[:12]
+
+		_, err := authorize(t, "0100", card, 600, rrn)
+		require.NoError(t, err)
+
+		// Company payments are checked and debited against the balance less
+		// the hold.
+		engine := authorization.NewEngine(authorization.ValidCard(db), authorization.SufficientAmount())
+		decision, err := engine.Authorize(ctx, &authorization.Request{CardID: card.ID, Amount: 500})
+		require.NoError(t, err)
+		require.NotNil(t, decision.Decline)
+		assert.Equal(t, authorization.ReasonInsufficientFunds, decision.Decline.Reason)
+		assert.Equal(t, 400.0, decision.Decline.Details["available_balance"])
+
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		assert.Error(t, transaction.NewRepository(db).UpdateCardBalance(ctx, tx, card.ID, 500))
+		require.NoError(t, tx.Rollback())
+
+		completed, err := gatewayRepo.CompleteAuthorization(ctx, "TERM0001", rrn, 600)
+		require.NoError(t, err)
+		assert.Equal(t, models.GatewayAuthorizationStatusCompleted, completed.Status)
+		assert.Equal(t, 400.0, cardBalance(t, card))
+	})
+
+	t.Run("reverse_hold", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		rrn := uuid.NewString()[:12]
+
+		hold, err := authorize(t, "0100", card, 300, rrn)
+		require.NoError(t, err)
+
+		reversed, err := gatewayRepo.ReverseAuthorization(ctx, "TERM0001", rrn)
+		require.NoError(t, err)
+		assert.Equal(t, models.GatewayAuthorizationStatusReversed, reversed.Status)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+		status, _ := transactionStatus(t, hold)
+		assert.Equal(t, models.TransactionStatusVoided, status)
+
+		_, err = gatewayRepo.CompleteAuthorization(ctx, "TERM0001", rrn, 300)
+		assert.ErrorIs(t, err, errors.ErrPaymentTransition)
+	})
+
+	t.Run("declines", func(t *testing.T) {
+		rrn := uuid.NewString()[:12]
+		decline := &models.GatewayAuthorization{
+			ID:           uuid.New(),
+			MTI:          "0100",
+			STAN:         "000002",
+			RRN:          rrn,
+			TerminalID:   "TERM0001",
+			ResponseCode: "14",
+			Amount:       100,
+			Status:       models.GatewayAuthorizationStatusDeclined,
+		}
+		require.NoError(t, gatewayRepo.RecordDecline(ctx, decline, nil))
+
+		found, err := gatewayRepo.GetAuthorization(ctx, "TERM0001", rrn)
+		require.NoError(t, err)
+		assert.Equal(t, "14", found.ResponseCode)
+		assert.Nil(t, found.CardID)
+
+		decline.ID = uuid.New()
+		assert.ErrorIs(t, gatewayRepo.RecordDecline(ctx, decline, nil), errors.ErrPaymentExists)
+
+		// A decline has nothing to reverse.
+		reversed, err := gatewayRepo.ReverseAuthorization(ctx, "TERM0001", rrn)
+		require.NoError(t, err)
+		assert.Nil(t, reversed)
+	})
+
+	t.Run("insufficient_funds", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+
+		_, err := authorize(t, "0200", card, 1500, uuid.NewString()[:12])
+		assert.ErrorIs(t, err, errors.ErrInsufficientFunds)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+	})
+}
+
+func TestGatewayAuthorizationChecks(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	clientRepo := client.NewRepository(db)
+	ctx := context.Background()
+
+	cardVault, err := vault.New(helper.Config.Vault)
+	require.NoError(t, err)
+
+	// The gateway runs the same checks as the HTTP routes.
+	checks := authorization.DefaultChecks(authorization.Dependencies{
+		DB:          db,
+		Redis:       helper.Redis,
+		Vault:       cardVault,
+		PINAttempts: card.NewRepository(db),
+		Card:        helper.Config.Card,
+		Risk:        helper.Config.Risk,
+	})
+	engine := authorization.NewEngine(append([]authorization.Check{authorization.ValidCard(db)}, checks...)...)
+	svc := gateway.NewService(gateway.NewRepository(db), engine, cardVault, config.GatewayConfig{
+		CurrencyCode:     "392",
+		CurrencyExponent: 0,
+	})
+
+	pay := func(pan, rrn string) *iso8583.Message {
+		msg := iso8583.NewMessage(iso8583.MTIFinancialRequest)
+		msg.Set(iso8583.FieldPAN, pan)
+		msg.Set(iso8583.FieldProcessingCode, "000000")
+		msg.Set(iso8583.FieldAmount, "000000000100")
+		msg.Set(iso8583.FieldSTAN, rrn[len(rrn)-6:])
+		msg.Set(iso8583.FieldMerchantType, "5411")
+		msg.Set(iso8583.FieldPOSEntryMode, "071")
+		msg.Set(iso8583.FieldRRN, rrn)
+		msg.Set(iso8583.FieldTerminalID, "TERM0001")
+		msg.Set(iso8583.FieldCurrencyCode, "392")
+		return svc.Handle(ctx, msg)
+	}
+
+	t.Run("velocity_decline", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		base := time.Now().UnixNano() % 1e11
+		pan := fmt.Sprintf("4%015d", base)
+		_, err := db.ExecContext(ctx, `UPDATE cards SET pan_fingerprint = $2 WHERE id = $1`, card.ID, cardVault.Fingerprint(pan))
+		require.NoError(t, err)
+
+		control, err := json.Marshal(authorization.VelocityControl{MaxTransactions: 1, TransactionWindow: "1h"})
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO spending_controls (card_id, control_type, control_value, is_active)
+			VALUES ($1, 'velocity', $2, true)`,
+			card.ID, control,
+		)
+		require.NoError(t, err)
+
+		resp := pay(pan, fmt.Sprintf("%012d", base))
+		require.Equal(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+
+		resp = pay(pan, fmt.Sprintf("%012d", base+1))
+		assert.Equal(t, iso8583.ResponseExceedsFrequency, resp.Get(iso8583.FieldResponseCode))
+		assert.False(t, resp.Has(iso8583.FieldAuthorizationCode))
+
+		// The decline is recorded like one over HTTP.
+		var declines int
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM card_declines WHERE card_id = $1`, card.ID).Scan(&declines))
+		assert.Equal(t, 1, declines)
+	})
+
+	t.Run("blocked_card_decline_is_published", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		base := time.Now().UnixNano() % 1e11
+		pan := fmt.Sprintf("5%015d", base)
+		_, err := db.ExecContext(ctx, `UPDATE cards SET pan_fingerprint = $2, status = $3 WHERE id = $1`,
+			card.ID, cardVault.Fingerprint(pan), models.CardStatusBlocked)
+		require.NoError(t, err)
+
+		resp := pay(pan, fmt.Sprintf("%012d", base))
+		assert.NotEqual(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+
+		// UsableCard declines before the velocity check runs, and the
+		// payment.declined event is still recorded.
+		var reason string
+		require.NoError(t, db.QueryRowContext(ctx, `
+			SELECT payload->>'reason' FROM outbox_events
+			WHERE company_id = $1 AND event_type = $2 AND payload->>'card_id' = $3`,
+			card.CompanyID, models.EventPaymentDeclined, card.ID.String(),
+		).Scan(&reason))
+		assert.Equal(t, authorization.ReasonCardInactive, reason)
+	})
+}
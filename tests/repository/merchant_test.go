@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/merchant"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+func TestMerchantCatalogue(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	merchantRepo := merchant.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	t.Run("seeded_codes", func(t *testing.T) {
+		code, err := merchantRepo.GetCategoryCode(ctx, "5812")
+		require.NoError(t, err)
+		require.NotNil(t, code)
+		assert.Equal(t, "food", code.Category)
+
+		airline, err := merchantRepo.GetCategoryCode(ctx, "3058")
+		require.NoError(t, err)
+		require.NotNil(t, airline)
+		assert.Equal(t, "travel", airline.Category)
+
+		missing, err := merchantRepo.GetCategoryCode(ctx, "0001")
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+
+		gambling, err := merchantRepo.GetCategoryCodes(ctx, "gambling")
+		require.NoError(t, err)
+		require.NotEmpty(t, gambling)
+		for _, code := range gambling {
+			assert.Equal(t, "gambling", code.Category)
+		}
+	})
+
+	t.Run("create_merchant", func(t *testing.T) {
+		created := &models.Merchant{ID: uuid.New(), Name: "Corner Cafe " + uuid.NewString()[:8], MCC: "5814"}
+		require.NoError(t, merchantRepo.CreateMerchant(ctx, created))
+		assert.NotZero(t, created.CreatedAt)
+
+		retrieved, err := merchantRepo.GetMerchantByID(ctx, created.ID)
+		require.NoError(t, err)
+		require.NotNil(t, retrieved)
+		assert.Equal(t, created.Name, retrieved.Name)
+		assert.Equal(t, "food", retrieved.Category)
+
+		duplicate := &models.Merchant{ID: uuid.New(), Name: created.Name, MCC: "5812"}
+		assert.Equal(t, errors.ErrMerchantExists, merchantRepo.CreateMerchant(ctx, duplicate))
+	})
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/notification"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+func TestNotificationInbox(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	clientRepo := client.NewRepository(db)
+	repo := notification.NewRepository(db)
+	ctx := context.Background()
+
+	company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+
+	addToInbox := func(t *testing.T, subject string) {
+		stored, err := repo.CreateNotification(ctx, &models.Notification{
+			CompanyID: company.ID,
+			Trigger:   models.NotificationTriggerPaymentDeclined,
+			DedupeKey: uuid.NewString(),
+			CardID:    &card.ID,
+			Subject:   subject,
+			Body:      subject + " body",
+		})
+		require.NoError(t, err)
+		require.NoError(t, repo.AddToInbox(ctx, stored))
+		// Adding it twice keeps one inbox entry.
+		require.NoError(t, repo.AddToInbox(ctx, stored))
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	addToInbox(t, "first")
+	addToInbox(t, "second")
+	addToInbox(t, "third")
+
+	t.Run("lists_newest_first", func(t *testing.T) {
+		inbox, err := repo.GetInbox(ctx, company.ID, false, 2, 0)
+		require.NoError(t, err)
+		require.Len(t, inbox, 2)
+		assert.Equal(t, "third", inbox[0].Subject)
+		assert.Equal(t, "second", inbox[1].Subject)
+		assert.Equal(t, &card.ID, inbox[0].CardID)
+		assert.Nil(t, inbox[0].ReadAt)
+
+		inbox, err = repo.GetInbox(ctx, company.ID, false, 2, 2)
+		require.NoError(t, err)
+		require.Len(t, inbox, 1)
+		assert.Equal(t, "first", inbox[0].Subject)
+
+		unread, err := repo.CountUnread(ctx, company.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, unread)
+	})
+
+	t.Run("mark_read", func(t *testing.T) {
+		inbox, err := repo.GetInbox(ctx, company.ID, false, 1, 0)
+		require.NoError(t, err)
+		require.Len(t, inbox, 1)
+
+		read, err := repo.MarkRead(ctx, company.ID, inbox[0].ID)
+		require.NoError(t, err)
+		require.NotNil(t, read)
+		require.NotNil(t, read.ReadAt)
+		assert.Equal(t, "third", read.Subject)
+
+		// Reading it again keeps the first read time.
+		again, err := repo.MarkRead(ctx, company.ID, inbox[0].ID)
+		require.NoError(t, err)
+		assert.True(t, read.ReadAt.Equal(*again.ReadAt))
+
+		// Another company cannot read it.
+		other, err := repo.MarkRead(ctx, uuid.New(), inbox[0].ID)
+		require.NoError(t, err)
+		assert.Nil(t, other)
+
+		unread, err := repo.GetInbox(ctx, company.ID, true, 10, 0)
+		require.NoError(t, err)
+		assert.Len(t, unread, 2)
+	})
+
+	t.Run("mark_all_read", func(t *testing.T) {
+		marked, err := repo.MarkAllRead(ctx, company.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, marked)
+
+		unread, err := repo.CountUnread(ctx, company.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, unread)
+	})
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+	"ccards/tests/setup"
+)
+
+func TestOutboxDispatcher(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	clientRepo := client.NewRepository(db)
+	ctx := context.Background()
+
+	outboxConfig := config.OutboxConfig{
+		HandlerTimeout: time.Second,
+		MaxAttempts:    2,
+		RetryBackoff:   time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+	}
+
+	// recorder collects the events of one company a subscriber was handed,
+	// failing the first failures calls.
+	type recorder struct {
+		mu       sync.Mutex
+		events   []*models.Event
+		failures int
+	}
+	subscriber := func(companyID uuid.UUID, r *recorder) outbox.Handler {
+		return func(ctx context.Context, event *models.Event) error {
+			if event.CompanyID != companyID {
+				return nil
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.failures > 0 {
+				r.failures--
+				return errors.New("subscriber unavailable")
+			}
+			r.events = append(r.events, event)
+			return nil
+		}
+	}
+
+	// dispatch drains the outbox, which other tests share.
+	dispatch := func(t *testing.T, dispatcher *outbox.Dispatcher) {
+		for {
+			handled, err := dispatcher.Dispatch(ctx)
+			require.NoError(t, err)
+			if handled < 100 {
+				return
+			}
+		}
+	}
+
+	enqueue := func(t *testing.T, companyID uuid.UUID) {
+		err := outbox.Enqueue(ctx, db, &outbox.Event{
+			CompanyID: companyID,
+			Type:      models.EventPaymentDeclined,
+			Data:      models.PaymentDeclinedEvent{CardID: uuid.New(), Amount: 10, Reason: "velocity"},
+		})
+		require.NoError(t, err)
+	}
+
+	deliveryStatus := func(t *testing.T, companyID uuid.UUID, subscriber string) (string, int) {
+		var status string
+		var attempts int
+		err := db.QueryRowContext(ctx, `
+			SELECT d.status, d.attempts
+			FROM outbox_deliveries d
+			JOIN outbox_events ev ON ev.id = d.event_id
+			WHERE ev.company_id = $1 AND ev.event_type = $2 AND d.subscriber = $3`,
+			companyID, models.EventPaymentDeclined, subscriber,
+		).Scan(&status, &attempts)
+		require.NoError(t, err)
+		return status, attempts
+	}
+
+	t.Run("every_subscriber_gets_the_event", func(t *testing.T) {
+		company, _ := setupTestCompanyAndCard(t, ctx, clientRepo)
+		first, second := &recorder{}, &recorder{}
+
+		dispatcher := outbox.NewDispatcher(db, outboxConfig)
+		dispatcher.Subscribe("first", subscriber(company.ID, first))
+		dispatcher.Subscribe("second", subscriber(company.ID, second))
+
+		enqueue(t, company.ID)
+		dispatch(t, dispatcher)
+
+		// The test card's card.issued event is handed out as well.
+		types := func(r *recorder) []string {
+			var result []string
+			for _, event := range r.events {
+				result = append(result, event.Type)
+			}
+			return result
+		}
+		assert.ElementsMatch(t, []string{models.EventCardIssued, models.EventPaymentDeclined}, types(first))
+		assert.ElementsMatch(t, types(first), types(second))
+
+		// Processed events are not handed out again.
+		dispatch(t, dispatcher)
+		assert.Len(t, first.events, 2)
+	})
+
+	t.Run("failed_subscriber_is_retried_alone", func(t *testing.T) {
+		company, _ := setupTestCompanyAndCard(t, ctx, clientRepo)
+		steady, flaky := &recorder{}, &recorder{failures: 2}
+
+		dispatcher := outbox.NewDispatcher(db, outboxConfig)
+		dispatcher.Subscribe("steady", subscriber(company.ID, steady))
+		dispatcher.Subscribe("flaky", subscriber(company.ID, flaky))
+
+		enqueue(t, company.ID)
+		dispatch(t, dispatcher)
+
+		status, attempts := deliveryStatus(t, company.ID, "flaky")
+		assert.Equal(t, models.EventDeliveryStatusPending, status)
+		assert.Equal(t, 1, attempts)
+
+		time.Sleep(10 * time.Millisecond)
+		dispatch(t, dispatcher)
+
+		assert.Len(t, steady.events, 2)
+		assert.Len(t, flaky.events, 2)
+		status, attempts = deliveryStatus(t, company.ID, "flaky")
+		assert.Equal(t, models.EventDeliveryStatusProcessed, status)
+		assert.Equal(t, 2, attempts)
+		status, _ = deliveryStatus(t, company.ID, "steady")
+		assert.Equal(t, models.EventDeliveryStatusProcessed, status)
+	})
+
+	t.Run("gives_up_after_max_attempts", func(t *testing.T) {
+		company, _ := setupTestCompanyAndCard(t, ctx, clientRepo)
+		broken := &recorder{failures: 100}
+
+		dispatcher := outbox.NewDispatcher(db, outboxConfig)
+		dispatcher.Subscribe("broken", subscriber(company.ID, broken))
+
+		enqueue(t, company.ID)
+		for i := 0; i < outboxConfig.MaxAttempts+1; i++ {
+			dispatch(t, dispatcher)
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		status, attempts := deliveryStatus(t, company.ID, "broken")
+		assert.Equal(t, models.EventDeliveryStatusDead, status)
+		assert.Equal(t, outboxConfig.MaxAttempts, attempts)
+
+		var lastError string
+		err := db.QueryRowContext(ctx, `
+			SELECT d.last_error
+			FROM outbox_deliveries d
+			JOIN outbox_events ev ON ev.id = d.event_id
+			WHERE ev.company_id = $1 AND ev.event_type = $2`,
+			company.ID, models.EventPaymentDeclined,
+		).Scan(&lastError)
+		require.NoError(t, err)
+		assert.Equal(t, "subscriber unavailable", lastError)
+	})
+}
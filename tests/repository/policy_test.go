@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/policy"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+func TestPolicyTemplateDefault(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	policyRepo := policy.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	company := createEmployeeTestCompany(t, ctx, clientRepo)
+
+	first := &models.CardPolicyTemplate{ID: uuid.New(), CompanyID: company.ID, Name: "First", IsDefault: true}
+	require.NoError(t, policyRepo.CreateTemplate(ctx, first))
+
+	second := &models.CardPolicyTemplate{ID: uuid.New(), CompanyID: company.ID, Name: "Second", IsDefault: true}
+	require.NoError(t, policyRepo.CreateTemplate(ctx, second))
+
+	defaultTemplate, err := clientRepo.GetDefaultPolicyTemplate(ctx, company.ID)
+	require.NoError(t, err)
+	require.NotNil(t, defaultTemplate)
+	assert.Equal(t, second.ID, defaultTemplate.ID)
+
+	retrieved, err := policyRepo.GetTemplateByID(ctx, company.ID, first.ID)
+	require.NoError(t, err)
+	assert.False(t, retrieved.IsDefault)
+
+	duplicate := &models.CardPolicyTemplate{ID: uuid.New(), CompanyID: company.ID, Name: "First"}
+	assert.Equal(t, errors.ErrTemplateExists, policyRepo.CreateTemplate(ctx, duplicate))
+}
+
+func TestReapplyPolicyTemplate(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	policyRepo := policy.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	company := createEmployeeTestCompany(t, ctx, clientRepo)
+
+	dailyLimit := 100.0
+	template := &models.CardPolicyTemplate{
+		ID:         uuid.New(),
+		CompanyID:  company.ID,
+		Name:       "Office",
+		DailyLimit: &dailyLimit,
+		Controls: []models.IssuanceControl{
+			{ControlType: "time_based", Value: json.RawMessage(`{"start_time":"09:00","end_time":"18:00"}`)},
+		},
+	}
+	require.NoError(t, policyRepo.CreateTemplate(ctx, template))
+
+	newCard := func(status string, templateID *uuid.UUID) *models.Card {
+		return &models.Card{
+			ID:               uuid.New(),
+			CompanyID:        company.ID,
+			CardHolderName:   "Template Holder",
+			EmployeeID:       uuid.New().String(),
+			EmployeeEmail:    "holder@example.com",
+			CardType:         models.CardTypeVirtual,
+			Status:           status,
+			DailyLimit:       &dailyLimit,
+			ExpiryDate:       time.Now().AddDate(3, 0, 0),
+			CVVMAC:           "test-cvv-hash",
+			LastFour:         "1111",
+			CreatedAt:        time.Now(),
+			UpdatedAt:        time.Now(),
+			PolicyTemplateID: templateID,
+		}
+	}
+	linked := newCard(models.CardStatusActive, &template.ID)
+	cancelled := newCard(models.CardStatusCancelled, &template.ID)
+	unlinked := newCard(models.CardStatusActive, nil)
+	require.NoError(t, clientRepo.CreateCardsInBatch(ctx, []*models.Card{linked, cancelled, unlinked}))
+
+	newDaily := 250.0
+	template.DailyLimit = &newDaily
+	template.Controls = []models.IssuanceControl{
+		{ControlType: "merchant_category", Value: json.RawMessage(`{"allowed_categories":["travel"]}`)},
+	}
+	require.NoError(t, policyRepo.UpdateTemplate(ctx, template))
+
+	updated, err := policyRepo.ReapplyTemplate(ctx, template)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	limitOf := func(cardID uuid.UUID) float64 {
+		var limit float64
+		err := helper.DB.QueryRowContext(ctx, "SELECT daily_limit FROM cards WHERE id = $1", cardID).Scan(&limit)
+		require.NoError(t, err)
+		return limit
+	}
+	assert.Equal(t, newDaily, limitOf(linked.ID))
+	assert.Equal(t, dailyLimit, limitOf(cancelled.ID))
+	assert.Equal(t, dailyLimit, limitOf(unlinked.ID))
+
+	var controlType string
+	err = helper.DB.QueryRowContext(ctx,
+		"SELECT control_type FROM spending_controls WHERE card_id = $1 AND policy_template_id = $2", linked.ID, template.ID,
+	).Scan(&controlType)
+	require.NoError(t, err)
+	assert.Equal(t, "merchant_category", controlType)
+}
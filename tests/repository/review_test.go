@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/review"
+	"ccards/internal/transaction"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+func TestFraudReviews(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	txRepo := transaction.NewRepository(helper.DB)
+	reviewRepo := review.NewRepository(helper.DB)
+	clientRepo := client.NewRepository(helper.DB)
+	ctx := context.Background()
+
+	createFlagged := func(t *testing.T, company *models.Company, card *models.Card) *models.Transaction {
+		score := 55
+		action := models.RiskActionReview
+		reviewStatus := models.ReviewStatusPending
+		category := "gambling"
+		txn := &models.Transaction{
+			ID:               uuid.New(),
+			CardID:           card.ID,
+			CompanyID:        company.ID,
+			TransactionType:  models.TransactionTypePurchase,
+			Amount:           400,
+			MerchantCategory: &category,
+			Description:      "Card purchase",
+			Status:           models.TransactionStatusCompleted,
+			RiskScore:        &score,
+			RiskAction:       &action,
+			RiskReasons: []models.RiskReason{
+				{Rule: "unusual_amount", Points: 35, Detail: "amount 400.00 is 8.0x the card's average purchase of 50.00"},
+				{Rule: "first_time_category", Points: 20, Detail: "first payment in category 'gambling'"},
+			},
+			ReviewStatus: &reviewStatus,
+		}
+
+		tx, err := txRepo.BeginTx(ctx)
+		require.NoError(t, err)
+		require.NoError(t, txRepo.CreateTransaction(ctx, tx, txn))
+		require.NoError(t, tx.Commit())
+		return txn
+	}
+
+	t.Run("risk_persisted", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txn := createFlagged(t, company, card)
+
+		stored, err := txRepo.GetTransactionByID(ctx, txn.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.RiskScore)
+		assert.Equal(t, 55, *stored.RiskScore)
+		assert.Equal(t, models.RiskActionReview, *stored.RiskAction)
+		assert.Equal(t, models.ReviewStatusPending, *stored.ReviewStatus)
+		require.Len(t, stored.RiskReasons, 2)
+		assert.Equal(t, "unusual_amount", stored.RiskReasons[0].Rule)
+	})
+
+	t.Run("pending_queue", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txn := createFlagged(t, company, card)
+
+		reviews, err := reviewRepo.GetReviews(ctx, company.ID, models.ReviewStatusPending, 20, 0)
+		require.NoError(t, err)
+		require.Len(t, reviews, 1)
+		assert.Equal(t, txn.ID, reviews[0].ID)
+
+		other, err := reviewRepo.GetReview(ctx, uuid.New(), txn.ID)
+		require.NoError(t, err)
+		assert.Nil(t, other)
+	})
+
+	t.Run("reject_blocks_card", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txn := createFlagged(t, company, card)
+
+		reason := "fraud confirmed by review"
+		resolved, err := reviewRepo.ResolveReview(ctx, company.ID, txn.ID, models.ReviewStatusRejected, &reason)
+		require.NoError(t, err)
+		assert.Equal(t, models.ReviewStatusRejected, *resolved.ReviewStatus)
+		assert.NotNil(t, resolved.ReviewedAt)
+
+		var status string
+		err = helper.DB.QueryRowContext(ctx, `SELECT status FROM cards WHERE id = $1`, card.ID).Scan(&status)
+		require.NoError(t, err)
+		assert.Equal(t, models.CardStatusBlocked, status)
+
+		_, err = reviewRepo.ResolveReview(ctx, company.ID, txn.ID, models.ReviewStatusApproved, nil)
+		assert.True(t, errors.Is(err, errors.ErrReviewClosed))
+	})
+}
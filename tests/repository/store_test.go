@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/dispute"
+	"ccards/internal/store"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+	"ccards/pkg/vault"
+	"ccards/tests/setup"
+)
+
+func TestStorePayments(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	storeRepo := store.NewRepository(db)
+	clientRepo := client.NewRepository(db)
+	ctx := context.Background()
+
+	newAPIKey := func(storeID uuid.UUID) *models.StoreAPIKey {
+		return &models.StoreAPIKey{
+			ID:      uuid.New(),
+			StoreID: storeID,
+			KeyID:   "pk_" + uuid.NewString()[:24],
+			Secret:  "sk_" + uuid.NewString(),
+		}
+	}
+
+	s := &models.Store{
+		ID:         uuid.New(),
+		MerchantID: uuid.New(),
+		Name:       "Corner Shop",
+		MCC:        "5411",
+		Email:      "shop-" + uuid.NewString() + "@example.com",
+		Status:     models.StoreStatusActive,
+	}
+	require.NoError(t, storeRepo.CreateStore(ctx, s, newAPIKey(s.ID)))
+
+	authorize := func(t *testing.T, card *models.Card, amount float64, reference *string) *models.StorePayment {
+		now := time.Now()
+		category := "groceries"
+		purchase := &models.Transaction{
+			ID:               uuid.New(),
+			CardID:           card.ID,
+			CompanyID:        card.CompanyID,
+			TransactionType:  models.TransactionTypePurchase,
+			Amount:           amount,
+			MerchantName:     &s.Name,
+			MerchantCategory: &category,
+			MCC:              &s.MCC,
+			MerchantID:       &s.MerchantID,
+			Description:      "Card purchase",
+			Status:           models.TransactionStatusCompleted,
+			Channel:          models.TransactionChannelOnline,
+			ProcessedAt:      &now,
+		}
+		payment := &models.StorePayment{
+			ID:            uuid.New(),
+			StoreID:       s.ID,
+			CardID:        card.ID,
+			CompanyID:     card.CompanyID,
+			TransactionID: purchase.ID,
+			Reference:     reference,
+			CardLastFour:  card.LastFour,
+			Amount:        amount,
+			Status:        models.StorePaymentStatusAuthorized,
+		}
+		require.NoError(t, storeRepo.CreatePayment(ctx, payment, purchase))
+		return payment
+	}
+
+	cardBalance := func(t *testing.T, card *models.Card) float64 {
+		var balance float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT balance FROM cards WHERE id = $1`, card.ID).Scan(&balance))
+		return balance
+	}
+
+	t.Run("store_registration", func(t *testing.T) {
+		stored, err := storeRepo.GetStore(ctx, s.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Corner Shop", stored.Name)
+		assert.Equal(t, "5411", stored.MCC)
+
+		duplicate := *s
+		duplicate.ID, duplicate.MerchantID = uuid.New(), uuid.New()
+		assert.ErrorIs(t, storeRepo.CreateStore(ctx, &duplicate, newAPIKey(duplicate.ID)), errors.ErrStoreExists)
+
+		unknownMCC := *s
+		unknownMCC.ID, unknownMCC.MerchantID, unknownMCC.Email = uuid.New(), uuid.New(), "other-"+s.Email
+		unknownMCC.MCC = "0000"
+		assert.ErrorIs(t, storeRepo.CreateStore(ctx, &unknownMCC, newAPIKey(unknownMCC.ID)), errors.ErrUnknownMCC)
+	})
+
+	t.Run("api_key_rotation", func(t *testing.T) {
+		other := &models.Store{
+			ID: uuid.New(), MerchantID: uuid.New(), Name: "Key Shop", MCC: "5411",
+			Email: "keys-" + uuid.NewString() + "@example.com", Status: models.StoreStatusActive,
+		}
+		oldKey := newAPIKey(other.ID)
+		require.NoError(t, storeRepo.CreateStore(ctx, other, oldKey))
+
+		retireAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		newKey := newAPIKey(other.ID)
+		require.NoError(t, storeRepo.CreateAPIKey(ctx, newKey, retireAt))
+
+		keys, err := storeRepo.GetAPIKeys(ctx, other.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, newKey.ID, keys[0].ID)
+		assert.Nil(t, keys[0].ExpiresAt)
+		assert.Empty(t, keys[0].Secret)
+		require.NotNil(t, keys[1].ExpiresAt)
+		assert.WithinDuration(t, retireAt, *keys[1].ExpiresAt, time.Second)
+
+		revoked, err := storeRepo.RevokeAPIKey(ctx, other.ID, oldKey.ID)
+		require.NoError(t, err)
+		require.NotNil(t, revoked.RevokedAt)
+
+		again, err := storeRepo.RevokeAPIKey(ctx, other.ID, oldKey.ID)
+		require.NoError(t, err)
+		assert.Equal(t, revoked.RevokedAt.Unix(), again.RevokedAt.Unix())
+
+		missing, err := storeRepo.RevokeAPIKey(ctx, s.ID, newKey.ID)
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("plaintext_secrets_are_sealed", func(t *testing.T) {
+		secretVault, err := vault.New(helper.Config.Vault)
+		require.NoError(t, err)
+
+		other := &models.Store{
+			ID: uuid.New(), MerchantID: uuid.New(), Name: "Legacy Shop", MCC: "5411",
+			Email: "legacy-" + uuid.NewString() + "@example.com", Status: models.StoreStatusActive,
+		}
+		apiKey := newAPIKey(other.ID)
+		require.NoError(t, storeRepo.CreateStore(ctx, other, apiKey))
+
+		sealed, err := storeRepo.EncryptAPIKeySecrets(ctx, func(secret string) (string, error) {
+			return secretVault.SealSecret(secret, utils.APIKeySecretPurpose)
+		})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, sealed, 1)
+
+		var stored string
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT secret FROM store_api_keys WHERE id = $1`, apiKey.ID).Scan(&stored))
+		secret, err := secretVault.OpenSecret(stored, utils.APIKeySecretPurpose)
+		require.NoError(t, err)
+		assert.Equal(t, apiKey.Secret, secret)
+	})
+
+	t.Run("partial_capture_releases_rest", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		reference := "order-" + uuid.NewString()
+		payment := authorize(t, card, 100, &reference)
+		assert.Equal(t, 900.0, cardBalance(t, card))
+
+		amount := 60.0
+		captured, err := storeRepo.CapturePayment(ctx, s.ID, payment.ID, &amount)
+		require.NoError(t, err)
+		assert.Equal(t, models.StorePaymentStatusCaptured, captured.Status)
+		assert.Equal(t, 60.0, captured.CapturedAmount)
+		assert.NotNil(t, captured.CapturedAt)
+		assert.Equal(t, 940.0, cardBalance(t, card))
+
+		var purchaseAmount float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT amount FROM transactions WHERE id = $1`, payment.TransactionID).Scan(&purchaseAmount))
+		assert.Equal(t, 60.0, purchaseAmount)
+
+		_, err = storeRepo.CapturePayment(ctx, s.ID, payment.ID, nil)
+		assert.ErrorIs(t, err, errors.ErrPaymentTransition)
+		_, err = storeRepo.VoidPayment(ctx, s.ID, payment.ID)
+		assert.ErrorIs(t, err, errors.ErrPaymentTransition)
+
+		// The reference cannot be used twice by the same store.
+		assert.ErrorIs(t, storeRepo.CreatePayment(ctx, &models.StorePayment{
+			ID: uuid.New(), StoreID: s.ID, CardID: card.ID, CompanyID: card.CompanyID, TransactionID: uuid.New(),
+			Reference: &reference, CardLastFour: card.LastFour, Amount: 1, Status: models.StorePaymentStatusAuthorized,
+		}, &models.Transaction{
+			ID: uuid.New(), CardID: card.ID, CompanyID: card.CompanyID, TransactionType: models.TransactionTypePurchase,
+			Amount: 1, MerchantID: &s.MerchantID, Status: models.TransactionStatusCompleted, Channel: models.TransactionChannelOnline,
+		}), errors.ErrPaymentExists)
+	})
+
+	t.Run("void_returns_amount", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		payment := authorize(t, card, 75, nil)
+
+		voided, err := storeRepo.VoidPayment(ctx, s.ID, payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StorePaymentStatusVoided, voided.Status)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+
+		var status string
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT status FROM transactions WHERE id = $1`, payment.TransactionID).Scan(&status))
+		assert.Equal(t, models.TransactionStatusVoided, status)
+
+		other, err := storeRepo.VoidPayment(ctx, uuid.New(), payment.ID)
+		require.NoError(t, err)
+		assert.Nil(t, other)
+	})
+
+	t.Run("partial_and_full_refunds", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		payment := authorize(t, card, 50, nil)
+		_, err := storeRepo.CapturePayment(ctx, s.ID, payment.ID, nil)
+		require.NoError(t, err)
+
+		_, err = storeRepo.RefundPayment(ctx, s.ID, payment.ID, &[]float64{50.01}[0], nil)
+		assert.ErrorIs(t, err, errors.ErrPaymentAmount)
+
+		reason := "Damaged"
+		first, err := storeRepo.RefundPayment(ctx, s.ID, payment.ID, &[]float64{20}[0], &reason)
+		require.NoError(t, err)
+		assert.Equal(t, 970.0, cardBalance(t, card))
+
+		var transactionType string
+		var merchantID uuid.UUID
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT transaction_type, merchant_id FROM transactions WHERE id = $1`,
+			first.TransactionID).Scan(&transactionType, &merchantID))
+		assert.Equal(t, models.TransactionTypeRefund, transactionType)
+		assert.Equal(t, s.MerchantID, merchantID)
+
+		stored, err := storeRepo.GetPayment(ctx, s.ID, payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StorePaymentStatusCaptured, stored.Status)
+		assert.Equal(t, 20.0, stored.RefundedAmount)
+
+		_, err = storeRepo.RefundPayment(ctx, s.ID, payment.ID, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+
+		stored, err = storeRepo.GetPayment(ctx, s.ID, payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.StorePaymentStatusRefunded, stored.Status)
+		assert.Equal(t, 50.0, stored.RefundedAmount)
+
+		refunds, err := storeRepo.GetRefunds(ctx, s.ID, payment.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 2)
+		assert.Equal(t, "Damaged", *refunds[0].Reason)
+		assert.Equal(t, 30.0, refunds[1].Amount)
+
+		_, err = storeRepo.RefundPayment(ctx, s.ID, payment.ID, nil, nil)
+		assert.ErrorIs(t, err, errors.ErrPaymentTransition)
+	})
+
+	fileDispute := func(t *testing.T, payment *models.StorePayment, amount float64) {
+		require.NoError(t, dispute.NewRepository(db).CreateDispute(ctx, &models.Dispute{
+			ID:            uuid.New(),
+			CompanyID:     payment.CompanyID,
+			CardID:        payment.CardID,
+			TransactionID: payment.TransactionID,
+			Reason:        "incorrect_amount",
+			Amount:        amount,
+			Status:        models.DisputeStatusOpened,
+			EvidenceDueAt: time.Now().Add(dispute.EvidenceWindow),
+			ResolveBy:     time.Now().Add(dispute.ResolutionWindow),
+		}))
+	}
+
+	t.Run("refund_capped_by_dispute_credit", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		payment := authorize(t, card, 50, nil)
+		_, err := storeRepo.CapturePayment(ctx, s.ID, payment.ID, nil)
+		require.NoError(t, err)
+		fileDispute(t, payment, 30)
+		assert.Equal(t, 980.0, cardBalance(t, card))
+
+		refund, err := storeRepo.RefundPayment(ctx, s.ID, payment.ID, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 20.0, refund.Amount)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+
+		_, err = storeRepo.RefundPayment(ctx, s.ID, payment.ID, nil, nil)
+		assert.ErrorIs(t, err, errors.ErrPaymentAmount)
+
+		var refunded float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT refunded_amount FROM transactions WHERE id = $1`, payment.TransactionID).Scan(&refunded))
+		assert.Equal(t, 50.0, refunded)
+	})
+
+	t.Run("void_after_dispute_credit", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		payment := authorize(t, card, 40, nil)
+		fileDispute(t, payment, 40)
+
+		_, err := storeRepo.VoidPayment(ctx, s.ID, payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+	})
+
+	t.Run("cancelled_card_credit_goes_to_company", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		payment := authorize(t, card, 60, nil)
+		_, err := db.ExecContext(ctx, `UPDATE cards SET status = $2 WHERE id = $1`, card.ID, models.CardStatusCancelled)
+		require.NoError(t, err)
+
+		var before float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT balance FROM companies WHERE id = $1`, company.ID).Scan(&before))
+
+		_, err = storeRepo.VoidPayment(ctx, s.ID, payment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 940.0, cardBalance(t, card))
+
+		var after float64
+		require.NoError(t, db.QueryRowContext(ctx, `SELECT balance FROM companies WHERE id = $1`, company.ID).Scan(&after))
+		assert.Equal(t, before+60, after)
+	})
+
+	t.Run("insufficient_funds", func(t *testing.T) {
+		_, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		now := time.Now()
+		err := storeRepo.CreatePayment(ctx, &models.StorePayment{
+			ID: uuid.New(), StoreID: s.ID, CardID: card.ID, CompanyID: card.CompanyID, TransactionID: uuid.New(),
+			CardLastFour: card.LastFour, Amount: 1500, Status: models.StorePaymentStatusAuthorized,
+		}, &models.Transaction{
+			ID: uuid.New(), CardID: card.ID, CompanyID: card.CompanyID, TransactionType: models.TransactionTypePurchase,
+			Amount: 1500, MerchantID: &s.MerchantID, Status: models.TransactionStatusCompleted,
+			Channel: models.TransactionChannelOnline, ProcessedAt: &now,
+		})
+		assert.ErrorIs(t, err, errors.ErrInsufficientFunds)
+		assert.Equal(t, 1000.0, cardBalance(t, card))
+	})
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/client"
+	"ccards/internal/stream"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+	"ccards/tests/setup"
+)
+
+func TestStreamEventsSince(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	clientRepo := client.NewRepository(db)
+	repo := stream.NewRepository(db)
+	ctx := context.Background()
+
+	company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+
+	enqueue := func(t *testing.T, eventType string) uuid.UUID {
+		err := outbox.Enqueue(ctx, db, &outbox.Event{
+			CompanyID: company.ID,
+			Type:      eventType,
+			Data:      models.CardStatusChangedEvent{CardID: card.ID, Status: models.CardStatusBlocked},
+		})
+		require.NoError(t, err)
+
+		var id uuid.UUID
+		err = db.QueryRowContext(ctx, `
+			SELECT id FROM outbox_events
+			WHERE company_id = $1 AND event_type = $2
+			ORDER BY created_at DESC
+			LIMIT 1`,
+			company.ID, eventType,
+		).Scan(&id)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+		return id
+	}
+
+	first := enqueue(t, models.EventPaymentDeclined)
+	second := enqueue(t, models.EventCardBlocked)
+	enqueue(t, models.EventCardIssued)
+	third := enqueue(t, models.EventCardStatusChanged)
+
+	t.Run("returns_later_events_oldest_first", func(t *testing.T) {
+		events, err := repo.GetEventsSince(ctx, company.ID, first, stream.EventTypes, 10)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, second, events[0].ID)
+		assert.Equal(t, models.EventCardBlocked, events[0].Type)
+		assert.Equal(t, third, events[1].ID)
+
+		events, err = repo.GetEventsSince(ctx, company.ID, first, stream.EventTypes, 1)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, second, events[0].ID)
+	})
+
+	t.Run("unknown_event_returns_nothing", func(t *testing.T) {
+		events, err := repo.GetEventsSince(ctx, company.ID, uuid.New(), stream.EventTypes, 10)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		// Another company's event ID is unknown as well.
+		events, err = repo.GetEventsSince(ctx, uuid.New(), first, stream.EventTypes, 10)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+}
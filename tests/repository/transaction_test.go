@@ -37,7 +37,6 @@ func setupTestCompanyAndCard(t *testing.T, ctx context.Context, clientRepo clien
 	card := &models.Card{
 		ID:             cardID,
 		CompanyID:      companyID,
-		CardNumber:     uniqueCardNumber,
 		CardHolderName: "Test Employee",
 		EmployeeID:     uuid.New().String(),
 		EmployeeEmail:  "test-employee-" + uuid.New().String() + "@example.com",
@@ -48,7 +47,7 @@ func setupTestCompanyAndCard(t *testing.T, ctx context.Context, clientRepo clien
 		DailyLimit:     createFloatPtr(1000.00),
 		MonthlyLimit:   createFloatPtr(10000.00),
 		ExpiryDate:     time.Now().AddDate(3, 0, 0),
-		CVVHash:        "test-cvv-hash",
+		CVVMAC:         "test-cvv-hash",
 		LastFour:       uniqueCardNumber[len(uniqueCardNumber)-4:],
 	}
 
@@ -130,6 +129,68 @@ func TestCreateTransaction(t *testing.T) {
 		err = tx.Commit()
 		require.NoError(t, err)
 	})
+
+	t.Run("create_transaction_with_channel", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+
+		tx, err := txRepo.BeginTx(ctx)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		terminalID := "TERM-0042"
+		txn := &models.Transaction{
+			ID:              uuid.New(),
+			CardID:          card.ID,
+			CompanyID:       company.ID,
+			TransactionType: models.TransactionTypePurchase,
+			Amount:          20.00,
+			Description:     "Card purchase",
+			Status:          models.TransactionStatusCompleted,
+			Channel:         models.TransactionChannelContactless,
+			TerminalID:      &terminalID,
+		}
+
+		err = txRepo.CreateTransaction(ctx, tx, txn)
+		require.NoError(t, err)
+
+		err = tx.Commit()
+		require.NoError(t, err)
+
+		retrievedTxn, err := txRepo.GetTransactionByID(ctx, txn.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TransactionChannelContactless, retrievedTxn.Channel)
+		require.NotNil(t, retrievedTxn.TerminalID)
+		assert.Equal(t, terminalID, *retrievedTxn.TerminalID)
+	})
+
+	t.Run("create_transaction_defaults_to_online", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+
+		tx, err := txRepo.BeginTx(ctx)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		txn := &models.Transaction{
+			ID:              uuid.New(),
+			CardID:          card.ID,
+			CompanyID:       company.ID,
+			TransactionType: models.TransactionTypePurchase,
+			Amount:          20.00,
+			Description:     "Card purchase",
+			Status:          models.TransactionStatusCompleted,
+		}
+
+		err = txRepo.CreateTransaction(ctx, tx, txn)
+		require.NoError(t, err)
+
+		err = tx.Commit()
+		require.NoError(t, err)
+
+		retrievedTxn, err := txRepo.GetTransactionByID(ctx, txn.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TransactionChannelOnline, retrievedTxn.Channel)
+		assert.Nil(t, retrievedTxn.TerminalID)
+	})
 }
 
 func TestGetTransactionByID(t *testing.T) {
@@ -290,7 +351,6 @@ func TestGetTransactionsByCompanyID(t *testing.T) {
 		card2 := &models.Card{
 			ID:             uuid.New(),
 			CompanyID:      company.ID,
-			CardNumber:     uniqueCardNumber2,
 			CardHolderName: "Employee 2",
 			EmployeeID:     uuid.New().String(),
 			EmployeeEmail:  "employee2-" + uuid.New().String() + "@example.com",
@@ -301,7 +361,7 @@ func TestGetTransactionsByCompanyID(t *testing.T) {
 			DailyLimit:     createFloatPtr(500.00),
 			MonthlyLimit:   createFloatPtr(5000.00),
 			ExpiryDate:     time.Now().AddDate(3, 0, 0),
-			CVVHash:        "test-cvv-hash-2",
+			CVVMAC:         "test-cvv-hash-2",
 			LastFour:       uniqueCardNumber2[len(uniqueCardNumber2)-4:],
 		}
 		err := clientRepo.CreateCardsInBatch(ctx, []*models.Card{card2})
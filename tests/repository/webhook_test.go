@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/client"
+	"ccards/internal/transaction"
+	"ccards/internal/webhook"
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+	"ccards/pkg/vault"
+	"ccards/tests/setup"
+)
+
+func TestWebhookOutbox(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	db := helper.DB
+	webhookRepo := webhook.NewRepository(db)
+	clientRepo := client.NewRepository(db)
+	txService := transaction.NewService(transaction.NewRepository(db))
+	ctx := context.Background()
+
+	secretVault, err := vault.New(helper.Config.Vault)
+	require.NoError(t, err)
+
+	createEndpoint := func(t *testing.T, companyID uuid.UUID, eventTypes ...string) *models.WebhookEndpoint {
+		endpoint := &models.WebhookEndpoint{
+			ID:         uuid.New(),
+			CompanyID:  companyID,
+			URL:        "https://erp.example.com/hooks",
+			Secret:     "whsec_test",
+			EventTypes: eventTypes,
+			IsActive:   true,
+		}
+		require.NoError(t, webhookRepo.CreateEndpoint(ctx, endpoint))
+		return endpoint
+	}
+
+	pay := func(t *testing.T, company *models.Company, card *models.Card) *models.Transaction {
+		txn, _, err := txService.ProcessPayment(ctx, &request.Transaction{
+			CompanyID:        company.ID,
+			CardID:           card.ID,
+			Amount:           25,
+			MerchantCategory: "retail",
+		})
+		require.NoError(t, err)
+		return txn
+	}
+
+	// fanOut hands the outbox, which other tests share, to the webhook
+	// subscriber.
+	dispatcher := outbox.NewDispatcher(db, config.OutboxConfig{
+		HandlerTimeout: time.Second,
+		MaxAttempts:    3,
+		RetryBackoff:   time.Minute,
+		MaxBackoff:     time.Hour,
+	})
+	dispatcher.Subscribe(webhook.SubscriberName, webhook.NewService(webhookRepo, secretVault, config.WebhookConfig{}).HandleEvent)
+	fanOut := func(t *testing.T) {
+		for {
+			handled, err := dispatcher.Dispatch(ctx)
+			require.NoError(t, err)
+			if handled < 100 {
+				return
+			}
+		}
+	}
+
+	deliveries := func(t *testing.T, endpoint *models.WebhookEndpoint, status string) []*models.WebhookDelivery {
+		result, err := webhookRepo.GetDeliveries(ctx, endpoint.CompanyID, endpoint.ID, status, 100, 0)
+		require.NoError(t, err)
+		return result
+	}
+
+	t.Run("payment_event_committed_with_payment", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		txn := pay(t, company, card)
+
+		var payload []byte
+		err := db.QueryRowContext(ctx, `
+			SELECT payload FROM outbox_events WHERE company_id = $1 AND event_type = $2`,
+			company.ID, models.EventPaymentCompleted,
+		).Scan(&payload)
+		require.NoError(t, err)
+
+		var event models.Transaction
+		require.NoError(t, json.Unmarshal(payload, &event))
+		assert.Equal(t, txn.ID, event.ID)
+		assert.Equal(t, models.TransactionStatusCompleted, event.Status)
+	})
+
+	t.Run("fan_out_to_subscribed_endpoints", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		all := createEndpoint(t, company.ID)
+		payments := createEndpoint(t, company.ID, models.EventPaymentCompleted)
+		declines := createEndpoint(t, company.ID, models.EventPaymentDeclined)
+
+		txn := pay(t, company, card)
+		fanOut(t)
+
+		// The card.issued event of the test card goes to the catch-all endpoint.
+		assert.Len(t, deliveries(t, all, ""), 2)
+		assert.Len(t, deliveries(t, payments, ""), 1)
+		assert.Empty(t, deliveries(t, declines, ""))
+
+		// Handling an event again does not duplicate its deliveries.
+		var event models.Event
+		err := db.QueryRowContext(ctx, `
+			SELECT id, company_id, event_type FROM outbox_events WHERE company_id = $1 AND payload->>'id' = $2`,
+			company.ID, txn.ID.String(),
+		).Scan(&event.ID, &event.CompanyID, &event.Type)
+		require.NoError(t, err)
+
+		created, err := webhookRepo.CreateDeliveries(ctx, &event)
+		require.NoError(t, err)
+		assert.Equal(t, 0, created)
+		assert.Len(t, deliveries(t, payments, ""), 1)
+	})
+
+	t.Run("retry_dead_letter_and_replay", func(t *testing.T) {
+		company, card := setupTestCompanyAndCard(t, ctx, clientRepo)
+		endpoint := createEndpoint(t, company.ID, models.EventPaymentCompleted)
+
+		since := time.Now().Add(-time.Minute)
+		pay(t, company, card)
+		fanOut(t)
+
+		var job *webhook.Job
+		jobs, err := webhookRepo.ClaimDeliveries(ctx, 1000, time.Minute)
+		require.NoError(t, err)
+		for _, claimed := range jobs {
+			if claimed.Delivery.EndpointID == endpoint.ID {
+				job = claimed
+			}
+		}
+		require.NotNil(t, job)
+		assert.Equal(t, endpoint.URL, job.URL)
+		assert.Equal(t, models.EventPaymentCompleted, job.Event.Type)
+		assert.Equal(t, company.ID, job.Event.CompanyID)
+
+		// A claimed delivery is leased and not claimed again.
+		jobs, err = webhookRepo.ClaimDeliveries(ctx, 1000, time.Minute)
+		require.NoError(t, err)
+		for _, claimed := range jobs {
+			assert.NotEqual(t, job.Delivery.ID, claimed.Delivery.ID)
+		}
+
+		statusCode := 500
+		require.NoError(t, webhookRepo.MarkFailed(ctx, job.Delivery.ID, &statusCode, "endpoint responded with status 500", nil))
+
+		dead := deliveries(t, endpoint, models.WebhookDeliveryStatusDead)
+		require.Len(t, dead, 1)
+		assert.Equal(t, 1, dead[0].Attempts)
+		assert.Equal(t, 500, *dead[0].LastStatusCode)
+
+		replayed, err := webhookRepo.ReplayDeadDeliveries(ctx, company.ID, endpoint.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+
+		pending := deliveries(t, endpoint, models.WebhookDeliveryStatusPending)
+		require.Len(t, pending, 1)
+		assert.Equal(t, 0, pending[0].Attempts)
+
+		require.NoError(t, webhookRepo.MarkDelivered(ctx, job.Delivery.ID, 200))
+		assert.Len(t, deliveries(t, endpoint, models.WebhookDeliveryStatusDelivered), 1)
+
+		// Replaying by time queues delivered events again.
+		replayed, err = webhookRepo.ReplayEvents(ctx, company.ID, endpoint.ID, since)
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		assert.Len(t, deliveries(t, endpoint, models.WebhookDeliveryStatusPending), 1)
+
+		// Other companies cannot replay the endpoint.
+		replayed, err = webhookRepo.ReplayDeadDeliveries(ctx, uuid.New(), endpoint.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, replayed)
+	})
+	t.Run("plaintext_secrets_are_sealed", func(t *testing.T) {
+		company, _ := setupTestCompanyAndCard(t, ctx, clientRepo)
+		endpoint := createEndpoint(t, company.ID)
+
+		sealed, err := webhookRepo.EncryptEndpointSecrets(ctx, func(secret string) (string, error) {
+			return secretVault.SealSecret(secret, "webhook-secret")
+		})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, sealed, 1)
+
+		stored, err := webhookRepo.GetEndpoint(ctx, company.ID, endpoint.ID)
+		require.NoError(t, err)
+		require.True(t, vault.IsSealed(stored.Secret))
+		secret, err := secretVault.OpenSecret(stored.Secret, "webhook-secret")
+		require.NoError(t, err)
+		assert.Equal(t, "whsec_test", secret)
+	})
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/pkg/authorization"
+	"ccards/pkg/models"
+)
+
+// recordingCheck passes every payment and remembers the outcomes it is told.
+type recordingCheck struct {
+	declines []*authorization.Decline
+}
+
+func (r *recordingCheck) Check(ctx context.Context, req *authorization.Request) error {
+	return nil
+}
+
+func (r *recordingCheck) Record(ctx context.Context, req *authorization.Request, decline *authorization.Decline) {
+	r.declines = append(r.declines, decline)
+}
+
+func TestAuthorizationEngine(t *testing.T) {
+	ctx := context.Background()
+
+	newRequest := func(amount float64) *authorization.Request {
+		spendingLimit := 500.0
+		return &authorization.Request{
+			Amount: amount,
+			Card: &models.Card{
+				ID:            uuid.New(),
+				Status:        models.CardStatusActive,
+				Balance:       1000,
+				SpendingLimit: &spendingLimit,
+				ExpiryDate:    time.Now().AddDate(0, 0, 10),
+			},
+		}
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		recorder := &recordingCheck{}
+		engine := authorization.NewEngine(authorization.UsableCard(), authorization.SufficientAmount(), recorder)
+
+		req := newRequest(100)
+		decision, err := engine.Authorize(ctx, req)
+		require.NoError(t, err)
+
+		assert.True(t, decision.Approved)
+		assert.Nil(t, decision.Decline)
+		require.NotNil(t, req.DaysUntilExpiry)
+		assert.Equal(t, []*authorization.Decline{nil}, recorder.declines)
+	})
+
+	t.Run("first_decline_stops_the_checks", func(t *testing.T) {
+		recorder := &recordingCheck{}
+		var later bool
+		engine := authorization.NewEngine(
+			recorder,
+			authorization.SufficientAmount(),
+			authorization.CheckFunc(func(ctx context.Context, req *authorization.Request) error {
+				later = true
+				return nil
+			}),
+		)
+
+		decision, err := engine.Authorize(ctx, newRequest(750))
+		require.NoError(t, err)
+
+		assert.False(t, decision.Approved)
+		require.NotNil(t, decision.Decline)
+		assert.Equal(t, authorization.ReasonSpendingLimit, decision.Decline.Reason)
+		assert.True(t, decision.Decline.CountsAgainstCard())
+		assert.False(t, later)
+		assert.Equal(t, []*authorization.Decline{decision.Decline}, recorder.declines)
+	})
+
+	t.Run("insufficient_funds", func(t *testing.T) {
+		engine := authorization.NewEngine(authorization.SufficientAmount())
+
+		req := newRequest(100)
+		req.Card.Balance = 50
+		decision, err := engine.Authorize(ctx, req)
+		require.NoError(t, err)
+
+		require.NotNil(t, decision.Decline)
+		assert.Equal(t, authorization.ReasonInsufficientFunds, decision.Decline.Reason)
+		assert.True(t, decision.Decline.CountsAgainstCard())
+		assert.Equal(t, 50.0, decision.Decline.Details["shortage"])
+	})
+
+	t.Run("held_amount_not_available", func(t *testing.T) {
+		engine := authorization.NewEngine(authorization.SufficientAmount())
+
+		req := newRequest(100)
+		req.Held = 950
+		decision, err := engine.Authorize(ctx, req)
+		require.NoError(t, err)
+
+		require.NotNil(t, decision.Decline)
+		assert.Equal(t, authorization.ReasonInsufficientFunds, decision.Decline.Reason)
+		assert.Equal(t, 50.0, decision.Decline.Details["available_balance"])
+	})
+
+	t.Run("unusable_card", func(t *testing.T) {
+		engine := authorization.NewEngine(authorization.UsableCard(), authorization.SufficientAmount())
+
+		req := newRequest(100)
+		req.Card.Status = models.CardStatusCancelled
+		decision, err := engine.Authorize(ctx, req)
+		require.NoError(t, err)
+
+		require.NotNil(t, decision.Decline)
+		assert.Equal(t, authorization.ReasonCardInactive, decision.Decline.Reason)
+		assert.Equal(t, "Card has been cancelled", decision.Decline.Message)
+	})
+
+	t.Run("check_failure", func(t *testing.T) {
+		recorder := &recordingCheck{}
+		engine := authorization.NewEngine(recorder, authorization.CheckFunc(func(ctx context.Context, req *authorization.Request) error {
+			return fmt.Errorf("database unavailable")
+		}))
+
+		decision, err := engine.Authorize(ctx, newRequest(100))
+		assert.Error(t, err)
+		assert.Nil(t, decision)
+		assert.Empty(t, recorder.declines)
+	})
+
+	t.Run("declines_not_about_the_card", func(t *testing.T) {
+		for _, reason := range []string{authorization.ReasonCardNotFound, authorization.ReasonUnknownMerchant, authorization.ReasonPINNotSupported} {
+			decline := &authorization.Decline{Reason: reason}
+			assert.False(t, decline.CountsAgainstCard(), reason)
+		}
+	})
+}
+
+func TestVerifyPINCheck(t *testing.T) {
+	ctx := context.Background()
+	cardVault := newTestVault(t)
+
+	newRequest := func(pin string) *authorization.Request {
+		card := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical, PINSet: true}
+		card.PINHash = cardVault.PINHash(card.ID, "2580")
+		return &authorization.Request{PIN: pin, Card: card}
+	}
+
+	t.Run("correct_pin_resets_failures", func(t *testing.T) {
+		mockRepo := new(MockCardRepository)
+		check := authorization.VerifyPIN(mockRepo, cardVault, testCardConfig.PINMaxAttempts)
+
+		req := newRequest("2580")
+		mockRepo.On("ResetPINFailures", ctx, req.Card.ID).Return(nil).Once()
+
+		assert.NoError(t, check.Check(ctx, req))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("wrong_pin_is_counted_by_the_repository", func(t *testing.T) {
+		mockRepo := new(MockCardRepository)
+		check := authorization.VerifyPIN(mockRepo, cardVault, testCardConfig.PINMaxAttempts)
+
+		req := newRequest("1111")
+		mockRepo.On("RecordPINFailure", ctx, req.Card.ID, testCardConfig.PINMaxAttempts).Return(1, nil).Once()
+
+		decline, ok := authorization.AsDecline(check.Check(ctx, req))
+		require.True(t, ok)
+		assert.Equal(t, authorization.ReasonInvalidPIN, decline.Reason)
+		assert.Equal(t, testCardConfig.PINMaxAttempts-1, decline.Details["attempts_remaining"])
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("last_attempt_locks_the_pin", func(t *testing.T) {
+		mockRepo := new(MockCardRepository)
+		check := authorization.VerifyPIN(mockRepo, cardVault, testCardConfig.PINMaxAttempts)
+
+		req := newRequest("1111")
+		mockRepo.On("RecordPINFailure", ctx, req.Card.ID, testCardConfig.PINMaxAttempts).Return(testCardConfig.PINMaxAttempts, nil).Once()
+
+		decline, ok := authorization.AsDecline(check.Check(ctx, req))
+		require.True(t, ok)
+		assert.Equal(t, authorization.ReasonPINLocked, decline.Reason)
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -10,7 +11,10 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"ccards/internal/card"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
 	"ccards/pkg/models"
+	"ccards/pkg/vault"
 )
 
 type MockCardRepository struct {
@@ -33,6 +37,14 @@ func (m *MockCardRepository) GetCardByCompanyIDAndCardID(ctx context.Context, co
 	return args.Get(0).(*models.Card), args.Error(1)
 }
 
+func (m *MockCardRepository) GetCardByToken(ctx context.Context, companyID uuid.UUID, token string) (*models.Card, error) {
+	args := m.Called(ctx, companyID, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Card), args.Error(1)
+}
+
 func (m *MockCardRepository) UpdateSpendingLimit(ctx context.Context, id uuid.UUID, spendingLimit int) (*models.Card, error) {
 	args := m.Called(ctx, id, spendingLimit)
 	if args.Get(0) == nil {
@@ -46,9 +58,76 @@ func (m *MockCardRepository) UpdateSpendingControl(ctx context.Context, cardID u
 	return args.Error(0)
 }
 
+func (m *MockCardRepository) GetCardSecret(ctx context.Context, companyID uuid.UUID, cardID uuid.UUID) (*models.Card, error) {
+	args := m.Called(ctx, companyID, cardID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) RecordReveal(ctx context.Context, reveal *models.CardReveal) error {
+	args := m.Called(ctx, reveal)
+	return args.Error(0)
+}
+
+func (m *MockCardRepository) EncryptCardNumbers(ctx context.Context, seal func(pan string) (*models.CardSecret, error)) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCardRepository) MigrateCVVs(ctx context.Context, migrate func(cardID uuid.UUID, secret *models.CardSecret) (*models.CardSecret, string, error)) (int, error) {
+	args := m.Called(ctx)
+	if err := args.Error(1); err != nil {
+		return 0, err
+	}
+
+	secrets, _ := args.Get(0).(map[uuid.UUID]*models.CardSecret)
+	for cardID, secret := range secrets {
+		sealed, mac, err := migrate(cardID, secret)
+		if err != nil {
+			return 0, err
+		}
+		m.MethodCalled("SaveCVV", cardID, sealed, mac)
+	}
+
+	return len(secrets), nil
+}
+
+func (m *MockCardRepository) SetPIN(ctx context.Context, cardID uuid.UUID, pinHash string, currentHash *string) error {
+	args := m.Called(ctx, cardID, pinHash, currentHash)
+	return args.Error(0)
+}
+
+func (m *MockCardRepository) RecordPINFailure(ctx context.Context, cardID uuid.UUID, maxAttempts int) (int, error) {
+	args := m.Called(ctx, cardID, maxAttempts)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCardRepository) ResetPINFailures(ctx context.Context, cardID uuid.UUID) error {
+	args := m.Called(ctx, cardID)
+	return args.Error(0)
+}
+
+func (m *MockCardRepository) UnlockPIN(ctx context.Context, cardID uuid.UUID) error {
+	args := m.Called(ctx, cardID)
+	return args.Error(0)
+}
+
+var testCardConfig = config.CardConfig{CVVMaxAttempts: 3, PINMaxAttempts: 3}
+
+func newTestVault(t *testing.T) *vault.Vault {
+	v, err := vault.New(config.VaultConfig{
+		MasterKey: "dGVzdC12YXVsdC1rZXktZm9yLXRlc3Rpbmctb25seSE=",
+		KeyID:     "test",
+	})
+	require.NoError(t, err)
+	return v
+}
+
 func TestGetCardByCompanyIDAndCardID(t *testing.T) {
 	mockRepo := new(MockCardRepository)
-	svc := card.NewService(mockRepo)
+	svc := card.NewService(mockRepo, newTestVault(t), testCardConfig)
 	ctx := context.Background()
 
 	t.Run("success", func(t *testing.T) {
@@ -101,7 +180,7 @@ func TestGetCardByCompanyIDAndCardID(t *testing.T) {
 
 func TestUpdateSpendingLimit(t *testing.T) {
 	mockRepo := new(MockCardRepository)
-	svc := card.NewService(mockRepo)
+	svc := card.NewService(mockRepo, newTestVault(t), testCardConfig)
 	ctx := context.Background()
 
 	t.Run("success", func(t *testing.T) {
@@ -143,7 +222,7 @@ func TestUpdateSpendingLimit(t *testing.T) {
 
 func TestGetCardsByCompanyID(t *testing.T) {
 	mockRepo := new(MockCardRepository)
-	svc := card.NewService(mockRepo)
+	svc := card.NewService(mockRepo, newTestVault(t), testCardConfig)
 	ctx := context.Background()
 
 	t.Run("success", func(t *testing.T) {
@@ -152,7 +231,6 @@ func TestGetCardsByCompanyID(t *testing.T) {
 			{
 				ID:             uuid.New(),
 				CompanyID:      companyID,
-				CardNumber:     "4111111111111111",
 				CardHolderName: "Employee 1",
 				EmployeeEmail:  "employee1@example.com",
 				CardType:       models.CardTypeVirtual,
@@ -161,7 +239,6 @@ func TestGetCardsByCompanyID(t *testing.T) {
 			{
 				ID:             uuid.New(),
 				CompanyID:      companyID,
-				CardNumber:     "4222222222222222",
 				CardHolderName: "Employee 2",
 				EmployeeEmail:  "employee2@example.com",
 				CardType:       models.CardTypeVirtual,
@@ -205,7 +282,7 @@ func TestGetCardsByCompanyID(t *testing.T) {
 
 func TestUpdateSpendingControl(t *testing.T) {
 	mockRepo := new(MockCardRepository)
-	svc := card.NewService(mockRepo)
+	svc := card.NewService(mockRepo, newTestVault(t), testCardConfig)
 	ctx := context.Background()
 
 	t.Run("success", func(t *testing.T) {
@@ -240,3 +317,260 @@ func TestUpdateSpendingControl(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestRevealCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	cardVault := newTestVault(t)
+	svc := card.NewService(mockRepo, cardVault, testCardConfig)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		companyID := uuid.New()
+		cardID := uuid.New()
+		secret, err := cardVault.SealCard("4111111111111111", "123")
+		require.NoError(t, err)
+
+		mockRepo.On("GetCardSecret", ctx, companyID, cardID).Return(&models.Card{
+			ID:             cardID,
+			CompanyID:      companyID,
+			Token:          "tok_test",
+			CardHolderName: "Jane Doe",
+			LastFour:       "1111",
+			Secret:         secret,
+		}, nil).Once()
+		mockRepo.On("RecordReveal", ctx, mock.MatchedBy(func(reveal *models.CardReveal) bool {
+			return reveal.CardID == cardID && reveal.CompanyID == companyID && reveal.ClientIP == "10.0.0.1"
+		})).Return(nil).Once()
+
+		revealed, err := svc.RevealCard(ctx, &models.CardReveal{CardID: cardID, CompanyID: companyID, ClientIP: "10.0.0.1"})
+		require.NoError(t, err)
+		assert.Equal(t, "4111111111111111", revealed.PAN)
+		assert.Equal(t, "123", revealed.CVV)
+		assert.Equal(t, "tok_test", revealed.Token)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		companyID := uuid.New()
+		cardID := uuid.New()
+		mockRepo.On("GetCardSecret", ctx, companyID, cardID).Return(nil, nil).Once()
+
+		revealed, err := svc.RevealCard(ctx, &models.CardReveal{CardID: cardID, CompanyID: companyID})
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+		assert.Nil(t, revealed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not_encrypted", func(t *testing.T) {
+		companyID := uuid.New()
+		cardID := uuid.New()
+		mockRepo.On("GetCardSecret", ctx, companyID, cardID).Return(&models.Card{ID: cardID, CompanyID: companyID}, nil).Once()
+
+		revealed, err := svc.RevealCard(ctx, &models.CardReveal{CardID: cardID, CompanyID: companyID})
+		assert.ErrorIs(t, err, errors.ErrSecretUnavailable)
+		assert.Nil(t, revealed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("audit_failure", func(t *testing.T) {
+		companyID := uuid.New()
+		cardID := uuid.New()
+		secret, err := cardVault.SealCard("4111111111111111", "123")
+		require.NoError(t, err)
+
+		mockRepo.On("GetCardSecret", ctx, companyID, cardID).Return(&models.Card{ID: cardID, CompanyID: companyID, Secret: secret}, nil).Once()
+		mockRepo.On("RecordReveal", ctx, mock.Anything).Return(assert.AnError).Once()
+
+		revealed, err := svc.RevealCard(ctx, &models.CardReveal{CardID: cardID, CompanyID: companyID})
+		require.Error(t, err)
+		assert.Nil(t, revealed)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestMigrateCardCVVs(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	cardVault := newTestVault(t)
+	svc := card.NewService(mockRepo, cardVault, testCardConfig)
+	ctx := context.Background()
+
+	withCVV := uuid.New()
+	withCVVSecret, err := cardVault.SealCard("4111111111111111", "042")
+	require.NoError(t, err)
+
+	withoutCVV := uuid.New()
+	withoutCVVSecret, err := cardVault.SealCard("4222222222222222", "")
+	require.NoError(t, err)
+
+	longCVV := uuid.New()
+	longCVVSecret, err := cardVault.SealCard("4333333333333333", "52341")
+	require.NoError(t, err)
+
+	mockRepo.On("MigrateCVVs", ctx).Return(map[uuid.UUID]*models.CardSecret{
+		withCVV:    withCVVSecret,
+		withoutCVV: withoutCVVSecret,
+		longCVV:    longCVVSecret,
+	}, nil).Once()
+
+	// A valid stored CVV is kept and the secret is not re-sealed.
+	mockRepo.On("SaveCVV", withCVV, withCVVSecret, cardVault.CVVMAC(withCVV, "042")).Once()
+
+	for _, tc := range []struct {
+		cardID uuid.UUID
+		pan    string
+	}{
+		{withoutCVV, "4222222222222222"},
+		{longCVV, "4333333333333333"},
+	} {
+		mockRepo.On("SaveCVV", tc.cardID, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			secret := args.Get(1).(*models.CardSecret)
+			pan, cvv, err := cardVault.OpenCard(secret)
+			require.NoError(t, err)
+			assert.Equal(t, tc.pan, pan)
+			assert.Regexp(t, `^[0-9]{3}$`, cvv)
+			assert.True(t, cardVault.VerifyCVV(tc.cardID, cvv, args.String(2)))
+		}).Once()
+	}
+
+	migrated, err := svc.MigrateCardCVVs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, migrated)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetPIN(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	cardVault := newTestVault(t)
+	svc := card.NewService(mockRepo, cardVault, testCardConfig)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		physical := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical}
+
+		mockRepo.On("SetPIN", ctx, physical.ID, cardVault.PINHash(physical.ID, "2580"), (*string)(nil)).Return(nil).Once()
+
+		err := svc.SetPIN(ctx, physical, "2580")
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("virtual_card", func(t *testing.T) {
+		virtual := &models.Card{ID: uuid.New(), CardType: models.CardTypeVirtual}
+
+		err := svc.SetPIN(ctx, virtual, "2580")
+		assert.Equal(t, errors.ErrPINNotSupported, err)
+	})
+
+	t.Run("already_set", func(t *testing.T) {
+		physical := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical, PINSet: true}
+
+		err := svc.SetPIN(ctx, physical, "2580")
+		assert.Equal(t, errors.ErrPINAlreadySet, err)
+	})
+
+	t.Run("set_concurrently", func(t *testing.T) {
+		physical := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical}
+
+		mockRepo.On("SetPIN", ctx, physical.ID, mock.Anything, (*string)(nil)).Return(errors.ErrNotFound).Once()
+
+		err := svc.SetPIN(ctx, physical, "2580")
+		assert.Equal(t, errors.ErrPINAlreadySet, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("weak_pin", func(t *testing.T) {
+		physical := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical}
+
+		for _, pin := range []string{"1111", "1234", "4321", "987654"} {
+			err := svc.SetPIN(ctx, physical, pin)
+			assert.Equal(t, errors.ErrWeakPIN, err, pin)
+		}
+	})
+}
+
+func TestChangePIN(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	cardVault := newTestVault(t)
+	svc := card.NewService(mockRepo, cardVault, testCardConfig)
+	ctx := context.Background()
+
+	newCard := func() *models.Card {
+		id := uuid.New()
+		return &models.Card{
+			ID:       id,
+			CardType: models.CardTypePhysical,
+			PINHash:  cardVault.PINHash(id, "2580"),
+			PINSet:   true,
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		physical := newCard()
+		currentHash := physical.PINHash
+
+		mockRepo.On("SetPIN", ctx, physical.ID, cardVault.PINHash(physical.ID, "7391"), &currentHash).Return(nil).Once()
+
+		err := svc.ChangePIN(ctx, physical, "2580", "7391")
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("incorrect_pin", func(t *testing.T) {
+		physical := newCard()
+
+		mockRepo.On("RecordPINFailure", ctx, physical.ID, testCardConfig.PINMaxAttempts).Return(1, nil).Once()
+
+		err := svc.ChangePIN(ctx, physical, "0000", "7391")
+		assert.Equal(t, errors.ErrIncorrectPIN, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("locks_after_max_attempts", func(t *testing.T) {
+		physical := newCard()
+
+		mockRepo.On("RecordPINFailure", ctx, physical.ID, testCardConfig.PINMaxAttempts).Return(testCardConfig.PINMaxAttempts, nil).Once()
+
+		err := svc.ChangePIN(ctx, physical, "0000", "7391")
+		assert.Equal(t, errors.ErrPINLocked, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("locked", func(t *testing.T) {
+		physical := newCard()
+		lockedAt := time.Now()
+		physical.PINLockedAt = &lockedAt
+
+		err := svc.ChangePIN(ctx, physical, "2580", "7391")
+		assert.Equal(t, errors.ErrPINLocked, err)
+	})
+
+	t.Run("not_set", func(t *testing.T) {
+		physical := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical}
+
+		err := svc.ChangePIN(ctx, physical, "2580", "7391")
+		assert.Equal(t, errors.ErrPINNotSet, err)
+	})
+}
+
+func TestUnlockPIN(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := card.NewService(mockRepo, newTestVault(t), testCardConfig)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		physical := &models.Card{ID: uuid.New(), CardType: models.CardTypePhysical}
+
+		mockRepo.On("UnlockPIN", ctx, physical.ID).Return(nil).Once()
+
+		err := svc.UnlockPIN(ctx, physical)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("virtual_card", func(t *testing.T) {
+		virtual := &models.Card{ID: uuid.New(), CardType: models.CardTypeVirtual}
+
+		err := svc.UnlockPIN(ctx, virtual)
+		assert.Equal(t, errors.ErrPINNotSupported, err)
+	})
+}
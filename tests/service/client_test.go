@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"ccards/internal/api/request"
+	"ccards/internal/api/response"
 	"ccards/internal/client"
 	"ccards/pkg/config"
 	"ccards/pkg/errors"
@@ -46,6 +51,11 @@ func (m *MockRepository) GetCompanyByEmail(ctx context.Context, email string) (*
 	return args.Get(0).(*models.Company), args.Error(1)
 }
 
+func (m *MockRepository) FundCompany(ctx context.Context, funding *models.CompanyFunding) error {
+	args := m.Called(ctx, funding)
+	return args.Error(0)
+}
+
 func (m *MockRepository) CreateCardsToIssue(ctx context.Context, cards []*models.CardToIssue) error {
 	args := m.Called(ctx, cards)
 	return args.Error(0)
@@ -72,16 +82,160 @@ func (m *MockRepository) GetPendingCardsToIssue(ctx context.Context, companyID u
 	return args.Get(0).([]*models.CardToIssue), args.Error(1)
 }
 
+func (m *MockRepository) GetDueScheduledCardsToIssue(ctx context.Context, now time.Time) ([]*models.CardToIssue, error) {
+	args := m.Called(ctx, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.CardToIssue), args.Error(1)
+}
+
+func (m *MockRepository) ScheduleCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, scheduledFor time.Time, policy *models.IssuancePolicy) (int, error) {
+	args := m.Called(ctx, companyID, ids, scheduledFor, policy)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepository) CancelCardsToIssue(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (int, error) {
+	args := m.Called(ctx, companyID, ids)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockRepository) CreateCardsInBatch(ctx context.Context, cards []*models.Card) error {
 	args := m.Called(ctx, cards)
 	return args.Error(0)
 }
 
-func (m *MockRepository) UpdateCardsToIssueStatusBatch(ctx context.Context, ids []uuid.UUID, status string) error {
-	args := m.Called(ctx, ids, status)
+// IssuePendingCards returns the pending rows set up for the call, runs the
+// builder on them and passes the result to SaveIssuedCards, which stands in
+// for the inserts made inside the issuance transaction.
+func (m *MockRepository) IssuePendingCards(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID, build client.CardBuilder) (int, error) {
+	args := m.Called(ctx, companyID, ids)
+	if err := args.Error(1); err != nil {
+		return 0, err
+	}
+
+	pending, _ := args.Get(0).([]*models.CardToIssue)
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	cards, controls, err := build(ctx, pending)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := m.MethodCalled("SaveIssuedCards", ctx, cards, controls).Error(0); err != nil {
+		return 0, err
+	}
+
+	return len(cards), nil
+}
+
+func (m *MockRepository) GetPolicyTemplatesByIDs(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*models.CardPolicyTemplate, error) {
+	args := m.Called(ctx, companyID, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]*models.CardPolicyTemplate), args.Error(1)
+}
+
+func (m *MockRepository) GetDefaultPolicyTemplate(ctx context.Context, companyID uuid.UUID) (*models.CardPolicyTemplate, error) {
+	args := m.Called(ctx, companyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CardPolicyTemplate), args.Error(1)
+}
+
+func (m *MockRepository) GetEmployeesByIDs(ctx context.Context, companyID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*models.Employee, error) {
+	args := m.Called(ctx, companyID, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]*models.Employee), args.Error(1)
+}
+
+func (m *MockRepository) GetEmployeesByExternalIDs(ctx context.Context, companyID uuid.UUID, externalIDs []string) (map[string]*models.Employee, error) {
+	args := m.Called(ctx, companyID, externalIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*models.Employee), args.Error(1)
+}
+
+func (m *MockRepository) GetEmployeeIDsWithCards(ctx context.Context, companyID uuid.UUID, employeeIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	args := m.Called(ctx, companyID, employeeIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]bool), args.Error(1)
+}
+
+func (m *MockRepository) GetEmployeeIDsPendingIssue(ctx context.Context, clientID uuid.UUID, employeeIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	args := m.Called(ctx, clientID, employeeIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uuid.UUID]bool), args.Error(1)
+}
+
+func (m *MockRepository) CreateImportJob(ctx context.Context, job *models.ImportJob) error {
+	args := m.Called(ctx, job)
 	return args.Error(0)
 }
 
+func (m *MockRepository) GetImportJob(ctx context.Context, companyID, id uuid.UUID) (*models.ImportJob, error) {
+	args := m.Called(ctx, companyID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImportJob), args.Error(1)
+}
+
+func (m *MockRepository) ClaimImportJob(ctx context.Context, id uuid.UUID, lease time.Duration) (*models.ImportJob, error) {
+	args := m.Called(ctx, id, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ImportJob), args.Error(1)
+}
+
+func (m *MockRepository) ExtendImportJobLease(ctx context.Context, id uuid.UUID, lease time.Duration) (bool, error) {
+	args := m.Called(ctx, id, lease)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) RequeueStaleImportJobs(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) GetQueuedImportJobIDs(ctx context.Context) ([]uuid.UUID, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockRepository) SaveImportChunk(ctx context.Context, job *models.ImportJob, cards []*models.CardToIssue, rows []*models.ImportJobRow) error {
+	args := m.Called(ctx, job, cards, rows)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UpdateImportJobStatus(ctx context.Context, job *models.ImportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetImportJobRows(ctx context.Context, jobID uuid.UUID, limit, offset int) ([]*models.ImportJobRow, error) {
+	args := m.Called(ctx, jobID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.ImportJobRow), args.Error(1)
+}
+
 type MockRedis struct {
 	mock.Mock
 }
@@ -105,7 +259,7 @@ func TestRegisterCompany(t *testing.T) {
 		RefreshTokenDuration: time.Hour * 24,
 	}
 
-	svc := client.NewService(mockRepo, jwtConfig, helper.Redis)
+	svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{}, newTestVault(t), helper.Redis)
 
 	t.Run("success", func(t *testing.T) {
 		req := &request.RegisterCompany{
@@ -184,7 +338,7 @@ func TestLogin(t *testing.T) {
 		RefreshTokenDuration: time.Hour * 24,
 	}
 
-	svc := client.NewService(mockRepo, jwtConfig, helper.Redis)
+	svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{}, newTestVault(t), helper.Redis)
 
 	t.Run("success", func(t *testing.T) {
 		password := "password123"
@@ -297,7 +451,7 @@ func TestRefreshToken(t *testing.T) {
 		RefreshTokenDuration: time.Hour * 24,
 	}
 
-	svc := client.NewService(mockRepo, jwtConfig, helper.Redis)
+	svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{}, newTestVault(t), helper.Redis)
 
 	t.Run("not_implemented", func(t *testing.T) {
 		resp, err := svc.RefreshToken(context.Background(), "some-refresh-token")
@@ -316,7 +470,7 @@ func TestIssueNewCards(t *testing.T) {
 		RefreshTokenDuration: time.Hour * 24,
 	}
 
-	svc := client.NewService(mockRepo, jwtConfig, helper.Redis)
+	svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{}, newTestVault(t), helper.Redis)
 	ctx := context.Background()
 	companyID := uuid.New()
 
@@ -344,40 +498,64 @@ func TestIssueNewCards(t *testing.T) {
 			},
 		}
 
-		mockRepo.On("GetPendingCardsToIssue", ctx, companyID).Return(pendingCards, nil).Once()
-		mockRepo.On("CreateCardsInBatch", ctx, mock.AnythingOfType("[]*models.Card")).Return(nil).Once()
-
-		var cardIDs []uuid.UUID
-		for _, card := range pendingCards {
-			cardIDs = append(cardIDs, card.ID)
+		employee := &models.Employee{
+			ID:        pendingCards[0].EmployeeID,
+			CompanyID: companyID,
+			Email:     pendingCards[0].EmployeeEmail,
+			Name:      "Jane Doe",
+			Status:    models.EmployeeStatusActive,
 		}
-		mockRepo.On("UpdateCardsToIssueStatusBatch", ctx, cardIDs, models.CardToIssueStatusGenerated).Return(nil).Once()
-
-		issuedCount, err := svc.IssueNewCards(ctx, companyID)
+		employeeIDs := []uuid.UUID{pendingCards[0].EmployeeID, pendingCards[1].EmployeeID}
+
+		mockRepo.On("IssuePendingCards", ctx, companyID, []uuid.UUID(nil)).Return(pendingCards, nil).Once()
+		mockRepo.On("GetEmployeesByIDs", ctx, companyID, employeeIDs).Return(map[uuid.UUID]*models.Employee{employee.ID: employee}, nil).Once()
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+		mockRepo.On("GetDefaultPolicyTemplate", ctx, companyID).Return(nil, nil).Once()
+		var saved []*models.Card
+		mockRepo.On("SaveIssuedCards", ctx, mock.MatchedBy(func(cards []*models.Card) bool {
+			saved = cards
+			return len(cards) == 2 &&
+				cards[0].CardHolderName == "Jane Doe" &&
+				cards[0].EmployeeRefID != nil && *cards[0].EmployeeRefID == employee.ID &&
+				cards[1].CardHolderName == "Employee - employee2@example.com" &&
+				cards[1].EmployeeRefID == nil &&
+				cards[0].Token != "" && cards[0].Token != cards[1].Token &&
+				cards[0].Secret != nil && len(cards[0].Secret.Ciphertext) > 0
+		}), mock.Anything).Return(nil).Once()
+
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, nil, nil)
 		require.NoError(t, err)
-		assert.Equal(t, len(pendingCards), issuedCount)
+		require.Len(t, issuedCards, len(pendingCards))
+
+		cardVault := newTestVault(t)
+		for i, issued := range issuedCards {
+			assert.Regexp(t, `^[0-9]{3}$`, issued.CVV)
+			assert.Equal(t, saved[i].ID, issued.CardID)
+			assert.True(t, cardVault.VerifyCVV(issued.CardID, issued.CVV, saved[i].CVVMAC))
+			assert.False(t, cardVault.VerifyCVV(uuid.New(), issued.CVV, saved[i].CVVMAC))
+		}
 
 		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("no_pending_cards", func(t *testing.T) {
-		mockRepo.On("GetPendingCardsToIssue", ctx, companyID).Return([]*models.CardToIssue{}, nil).Once()
+		mockRepo.On("IssuePendingCards", ctx, companyID, []uuid.UUID(nil)).Return([]*models.CardToIssue{}, nil).Once()
 
-		issuedCount, err := svc.IssueNewCards(ctx, companyID)
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, nil, nil)
 		require.Error(t, err)
 		assert.Equal(t, errors.ErrNotFound, err)
-		assert.Equal(t, 0, issuedCount)
+		assert.Empty(t, issuedCards)
 
 		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("error_fetching_pending_cards", func(t *testing.T) {
-		mockRepo.On("GetPendingCardsToIssue", ctx, companyID).Return(nil, fmt.Errorf("database error")).Once()
+		mockRepo.On("IssuePendingCards", ctx, companyID, []uuid.UUID(nil)).Return(nil, fmt.Errorf("failed to fetch pending cards: database error")).Once()
 
-		issuedCount, err := svc.IssueNewCards(ctx, companyID)
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, nil, nil)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to fetch pending cards")
-		assert.Equal(t, 0, issuedCount)
+		assert.Empty(t, issuedCards)
 
 		mockRepo.AssertExpectations(t)
 	})
@@ -396,13 +574,16 @@ func TestIssueNewCards(t *testing.T) {
 			},
 		}
 
-		mockRepo.On("GetPendingCardsToIssue", ctx, companyID).Return(pendingCards, nil).Once()
-		mockRepo.On("CreateCardsInBatch", ctx, mock.AnythingOfType("[]*models.Card")).Return(fmt.Errorf("database error")).Once()
+		mockRepo.On("IssuePendingCards", ctx, companyID, []uuid.UUID(nil)).Return(pendingCards, nil).Once()
+		mockRepo.On("GetEmployeesByIDs", ctx, companyID, []uuid.UUID{pendingCards[0].EmployeeID}).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+		mockRepo.On("GetDefaultPolicyTemplate", ctx, companyID).Return(nil, nil).Once()
+		mockRepo.On("SaveIssuedCards", ctx, mock.AnythingOfType("[]*models.Card"), mock.Anything).Return(fmt.Errorf("failed to create cards: database error")).Once()
 
-		issuedCount, err := svc.IssueNewCards(ctx, companyID)
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, nil, nil)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "failed to create cards")
-		assert.Equal(t, 0, issuedCount)
+		assert.Empty(t, issuedCards)
 
 		mockRepo.AssertExpectations(t)
 	})
@@ -421,23 +602,265 @@ func TestIssueNewCards(t *testing.T) {
 			},
 		}
 
-		mockRepo.On("GetPendingCardsToIssue", ctx, companyID).Return(pendingCards, nil).Once()
-		mockRepo.On("CreateCardsInBatch", ctx, mock.AnythingOfType("[]*models.Card")).Return(nil).Once()
+		mockRepo.On("IssuePendingCards", ctx, companyID, []uuid.UUID(nil)).Return(pendingCards, nil).Once()
+		mockRepo.On("GetEmployeesByIDs", ctx, companyID, []uuid.UUID{pendingCards[0].EmployeeID}).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+		mockRepo.On("GetDefaultPolicyTemplate", ctx, companyID).Return(nil, nil).Once()
+		mockRepo.On("SaveIssuedCards", ctx, mock.AnythingOfType("[]*models.Card"), mock.Anything).Return(fmt.Errorf("failed to update status: database error")).Once()
+
+		// The status update is part of the issuance transaction, so a failure
+		// rolls back the cards instead of leaving the rows pending.
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, nil, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to update status")
+		assert.Empty(t, issuedCards)
+
+		mockRepo.AssertExpectations(t)
+	})
+}
 
-		var cardIDs []uuid.UUID
-		for _, card := range pendingCards {
-			cardIDs = append(cardIDs, card.ID)
+func TestIssueNewCardsWithPolicy(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	svc := client.NewService(new(MockRepository), config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+	physical := models.CardTypePhysical
+	dailyLimit := 200.0
+	monthlyLimit := 2000.0
+	rowDailyLimit := 50.0
+
+	t.Run("selected_cards_with_policy", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+		pending := []*models.CardToIssue{
+			{ID: uuid.New(), ClientID: companyID, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "a@example.com"},
+			{ID: uuid.New(), ClientID: companyID, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "b@example.com", DailyLimit: &rowDailyLimit},
+		}
+		ids := []uuid.UUID{pending[0].ID, pending[1].ID}
+		policy := &models.IssuancePolicy{
+			CardType:     &physical,
+			DailyLimit:   &dailyLimit,
+			MonthlyLimit: &monthlyLimit,
+			Controls: []models.IssuanceControl{
+				{ControlType: "time_based", Value: json.RawMessage(`{"start_time":"09:00","end_time":"18:00"}`)},
+			},
 		}
-		mockRepo.On("UpdateCardsToIssueStatusBatch", ctx, cardIDs, models.CardToIssueStatusGenerated).Return(fmt.Errorf("database error")).Once()
 
-		issuedCount, err := svc.IssueNewCards(ctx, companyID)
+		mockRepo.On("IssuePendingCards", ctx, companyID, ids).Return(pending, nil).Once()
+		mockRepo.On("GetEmployeesByIDs", ctx, companyID, mock.Anything).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+		mockRepo.On("GetDefaultPolicyTemplate", ctx, companyID).Return(nil, nil).Once()
+		mockRepo.On("SaveIssuedCards", ctx, mock.MatchedBy(func(cards []*models.Card) bool {
+			return len(cards) == 2 &&
+				cards[0].CardType == models.CardTypePhysical &&
+				*cards[0].DailyLimit == dailyLimit &&
+				*cards[0].MonthlyLimit == monthlyLimit &&
+				*cards[1].DailyLimit == rowDailyLimit
+		}), mock.MatchedBy(func(controls []*models.SpendingControl) bool {
+			return len(controls) == 2 &&
+				controls[0].CardID == pending[0].CardID &&
+				controls[1].CardID == pending[1].CardID &&
+				controls[0].ControlType == "time_based"
+		})).Return(nil).Once()
+
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, ids, policy)
 		require.NoError(t, err)
-		assert.Equal(t, len(pendingCards), issuedCount)
+		assert.Len(t, issuedCards, 2)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid_policy", func(t *testing.T) {
+		invalid := &models.IssuancePolicy{
+			Controls: []models.IssuanceControl{
+				{ControlType: "time_based", Value: json.RawMessage(`{"start_time":"25:00","end_time":"18:00"}`)},
+			},
+		}
+
+		issuedCards, err := svc.IssueNewCards(ctx, companyID, nil, invalid)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPolicy))
+		assert.Empty(t, issuedCards)
+	})
+}
+
+func TestIssueNewCardsWithTemplate(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	physical := models.CardTypePhysical
+	templateLimit := 500.0
+	templateDaily := 100.0
+	policyDaily := 150.0
+	rowDaily := 75.0
+
+	defaultTemplate := &models.CardPolicyTemplate{
+		ID:            uuid.New(),
+		CompanyID:     companyID,
+		Name:          "Default",
+		SpendingLimit: &templateLimit,
+		DailyLimit:    &templateDaily,
+		Controls: []models.IssuanceControl{
+			{ControlType: "merchant_category", Value: json.RawMessage(`{"allowed_categories":["food"]}`)},
+		},
+		IsDefault: true,
+	}
+	travelTemplate := &models.CardPolicyTemplate{
+		ID:        uuid.New(),
+		CompanyID: companyID,
+		Name:      "Travel",
+		CardType:  &physical,
+		Controls:  []models.IssuanceControl{},
+	}
+
+	t.Run("default_template_applied", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
 
+		pending := []*models.CardToIssue{
+			{ID: uuid.New(), ClientID: companyID, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "a@example.com"},
+			{ID: uuid.New(), ClientID: companyID, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "b@example.com", DailyLimit: &rowDaily},
+		}
+
+		mockRepo.On("IssuePendingCards", ctx, companyID, []uuid.UUID(nil)).Return(pending, nil).Once()
+		mockRepo.On("GetEmployeesByIDs", ctx, companyID, mock.Anything).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+		mockRepo.On("GetDefaultPolicyTemplate", ctx, companyID).Return(defaultTemplate, nil).Once()
+		mockRepo.On("SaveIssuedCards", ctx, mock.MatchedBy(func(cards []*models.Card) bool {
+			return len(cards) == 2 &&
+				*cards[0].PolicyTemplateID == defaultTemplate.ID &&
+				*cards[0].SpendingLimit == templateLimit &&
+				*cards[0].DailyLimit == templateDaily &&
+				*cards[1].DailyLimit == rowDaily
+		}), mock.MatchedBy(func(controls []*models.SpendingControl) bool {
+			return len(controls) == 2 &&
+				controls[0].ControlType == "merchant_category" &&
+				*controls[0].PolicyTemplateID == defaultTemplate.ID
+		})).Return(nil).Once()
+
+		issued, err := svc.IssueNewCards(ctx, companyID, nil, nil)
+		require.NoError(t, err)
+		assert.Len(t, issued, 2)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("chosen_template_with_policy_override", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+		pending := []*models.CardToIssue{
+			{ID: uuid.New(), ClientID: companyID, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "a@example.com"},
+		}
+		ids := []uuid.UUID{pending[0].ID}
+		policy := &models.IssuancePolicy{TemplateID: &travelTemplate.ID, DailyLimit: &policyDaily}
+		chosen := map[uuid.UUID]*models.CardPolicyTemplate{travelTemplate.ID: travelTemplate}
+
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID{travelTemplate.ID}).Return(chosen, nil).Twice()
+		mockRepo.On("IssuePendingCards", ctx, companyID, ids).Return(pending, nil).Once()
+		mockRepo.On("GetEmployeesByIDs", ctx, companyID, mock.Anything).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+		mockRepo.On("GetDefaultPolicyTemplate", ctx, companyID).Return(defaultTemplate, nil).Once()
+		mockRepo.On("SaveIssuedCards", ctx, mock.MatchedBy(func(cards []*models.Card) bool {
+			return len(cards) == 1 &&
+				*cards[0].PolicyTemplateID == travelTemplate.ID &&
+				cards[0].CardType == models.CardTypePhysical &&
+				cards[0].SpendingLimit == nil &&
+				*cards[0].DailyLimit == policyDaily
+		}), mock.MatchedBy(func(controls []*models.SpendingControl) bool {
+			return len(controls) == 0
+		})).Return(nil).Once()
+
+		issued, err := svc.IssueNewCards(ctx, companyID, ids, policy)
+		require.NoError(t, err)
+		assert.Len(t, issued, 1)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown_template", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+		unknownID := uuid.New()
+		mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyID, []uuid.UUID{unknownID}).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+
+		issued, err := svc.IssueNewCards(ctx, companyID, nil, &models.IssuancePolicy{TemplateID: &unknownID})
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPolicy))
+		assert.Empty(t, issued)
+		mockRepo.AssertNotCalled(t, "IssuePendingCards", mock.Anything, mock.Anything, mock.Anything)
 		mockRepo.AssertExpectations(t)
 	})
 }
 
+func TestScheduleCardIssuance(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	scheduledFor := time.Now().Add(48 * time.Hour)
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+		ids := []uuid.UUID{uuid.New()}
+		mockRepo.On("ScheduleCardsToIssue", ctx, companyID, ids, scheduledFor, (*models.IssuancePolicy)(nil)).Return(1, nil).Once()
+
+		scheduled, err := svc.ScheduleCardIssuance(ctx, companyID, ids, scheduledFor, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, scheduled)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("nothing_pending", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+		mockRepo.On("ScheduleCardsToIssue", ctx, companyID, []uuid.UUID(nil), scheduledFor, (*models.IssuancePolicy)(nil)).Return(0, nil).Once()
+
+		_, err := svc.ScheduleCardIssuance(ctx, companyID, nil, scheduledFor, nil)
+		assert.Equal(t, errors.ErrNotFound, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestIssueScheduledCards(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	companyA := uuid.New()
+	companyB := uuid.New()
+
+	mockRepo := new(MockRepository)
+	svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+	physical := models.CardTypePhysical
+	due := []*models.CardToIssue{
+		{ID: uuid.New(), ClientID: companyA, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "a1@example.com", Policy: &models.IssuancePolicy{CardType: &physical}},
+		{ID: uuid.New(), ClientID: companyA, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "a2@example.com"},
+		{ID: uuid.New(), ClientID: companyB, CardID: uuid.New(), EmployeeID: uuid.New(), EmployeeEmail: "b1@example.com"},
+	}
+
+	mockRepo.On("GetDueScheduledCardsToIssue", ctx, now).Return(due, nil).Once()
+	mockRepo.On("IssuePendingCards", ctx, companyA, []uuid.UUID{due[0].ID, due[1].ID}).Return(due[:2], nil).Once()
+	mockRepo.On("IssuePendingCards", ctx, companyB, []uuid.UUID{due[2].ID}).Return(due[2:], nil).Once()
+	mockRepo.On("GetEmployeesByIDs", ctx, companyA, mock.Anything).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+	mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyA, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+	mockRepo.On("GetDefaultPolicyTemplate", ctx, companyA).Return(nil, nil).Once()
+	mockRepo.On("GetEmployeesByIDs", ctx, companyB, mock.Anything).Return(map[uuid.UUID]*models.Employee{}, nil).Once()
+	mockRepo.On("GetPolicyTemplatesByIDs", ctx, companyB, []uuid.UUID(nil)).Return(map[uuid.UUID]*models.CardPolicyTemplate{}, nil).Once()
+	mockRepo.On("GetDefaultPolicyTemplate", ctx, companyB).Return(nil, nil).Once()
+	mockRepo.On("SaveIssuedCards", ctx, mock.MatchedBy(func(cards []*models.Card) bool {
+		return len(cards) == 2 && cards[0].CompanyID == companyA &&
+			cards[0].CardType == models.CardTypePhysical && cards[1].CardType == models.CardTypeVirtual
+	}), mock.Anything).Return(nil).Once()
+	mockRepo.On("SaveIssuedCards", ctx, mock.MatchedBy(func(cards []*models.Card) bool {
+		return len(cards) == 1 && cards[0].CompanyID == companyB
+	}), mock.Anything).Return(fmt.Errorf("database error")).Once()
+
+	issued, err := svc.IssueScheduledCards(ctx, now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), companyB.String())
+	assert.Equal(t, 2, issued)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestGetCompanyByID(t *testing.T) {
 	helper := setup.NewTestHelper(t)
 	mockRepo := new(MockRepository)
@@ -447,7 +870,7 @@ func TestGetCompanyByID(t *testing.T) {
 		RefreshTokenDuration: time.Hour * 24,
 	}
 
-	svc := client.NewService(mockRepo, jwtConfig, helper.Redis)
+	svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{}, newTestVault(t), helper.Redis)
 
 	t.Run("success", func(t *testing.T) {
 		companyID := uuid.New()
@@ -507,7 +930,7 @@ func TestGetCompanyByEmail(t *testing.T) {
 		RefreshTokenDuration: time.Hour * 24,
 	}
 
-	svc := client.NewService(mockRepo, jwtConfig, helper.Redis)
+	svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{}, newTestVault(t), helper.Redis)
 
 	t.Run("success", func(t *testing.T) {
 		email := "test@example.com"
@@ -557,3 +980,356 @@ func TestGetCompanyByEmail(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestFundCompany(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockRepository)
+	svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+	t.Run("success", func(t *testing.T) {
+		companyID := uuid.New()
+		req := &request.FundCompany{Amount: 250, Reference: "wire-0042"}
+
+		mockRepo.On("FundCompany", ctx, mock.MatchedBy(func(f *models.CompanyFunding) bool {
+			return f.ID != uuid.Nil && f.CompanyID == companyID && f.Amount == 250 && f.Reference == "wire-0042"
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.CompanyFunding).Balance = 300
+		}).Return(nil).Once()
+
+		funding, err := svc.FundCompany(ctx, companyID, req)
+		require.NoError(t, err)
+		assert.Equal(t, companyID, funding.CompanyID)
+		assert.Equal(t, 300.0, funding.Balance)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("company_not_found", func(t *testing.T) {
+		companyID := uuid.New()
+
+		mockRepo.On("FundCompany", ctx, mock.MatchedBy(func(f *models.CompanyFunding) bool {
+			return f.CompanyID == companyID
+		})).Return(errors.ErrNotFound).Once()
+
+		funding, err := svc.FundCompany(ctx, companyID, &request.FundCompany{Amount: 10})
+		require.ErrorIs(t, err, errors.ErrNotFound)
+		assert.Nil(t, funding)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func writeImportFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "employees.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRunImportJob(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	jwtConfig := config.JWTConfig{Secret: "test-secret"}
+	importConfig := config.ImportConfig{ChunkSize: 3, LeaseDuration: time.Minute}
+
+	existingHolder := uuid.New()
+	queuedEmployee := uuid.New()
+	newEmployee := uuid.New()
+	directoryEmployee := &models.Employee{ID: uuid.New(), CompanyID: companyID, Name: "Directory Person"}
+
+	csvData := "employee_email,employee_id,name,card_type,daily_limit,monthly_limit,initial_funding,notes\n" +
+		"new@example.com," + newEmployee.String() + ",New Hire,physical,100,1000,50,ignored\n" +
+		"dir@example.com,EMP-42,,,,,,\n" +
+		"unknown@example.com,EMP-404,,,,,,\n" +
+		"not-an-email," + uuid.New().String() + ",,plastic,500,100,-5,\n" +
+		"holder@example.com," + existingHolder.String() + ",,,,,,\n" +
+		"queued@example.com," + queuedEmployee.String() + ",,,,,,\n" +
+		"again@example.com," + newEmployee.String() + ",,,,,,\n"
+
+	setupMocks := func(mockRepo *MockRepository) {
+		mockRepo.On("GetEmployeesByExternalIDs", mock.Anything, companyID, []string{"EMP-42", "EMP-404"}).
+			Return(map[string]*models.Employee{"EMP-42": directoryEmployee}, nil).Once()
+		mockRepo.On("GetEmployeesByExternalIDs", mock.Anything, companyID, mock.Anything).
+			Return(map[string]*models.Employee{}, nil)
+		mockRepo.On("GetEmployeeIDsWithCards", mock.Anything, companyID, mock.Anything).
+			Return(map[uuid.UUID]bool{existingHolder: true}, nil)
+		mockRepo.On("GetEmployeeIDsPendingIssue", mock.Anything, companyID, mock.Anything).
+			Return(map[uuid.UUID]bool{queuedEmployee: true}, nil)
+	}
+
+	t.Run("processes_file_in_chunks", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, jwtConfig, importConfig, newTestVault(t), nil)
+		setupMocks(mockRepo)
+
+		job := &models.ImportJob{
+			ID:        uuid.New(),
+			CompanyID: companyID,
+			FilePath:  writeImportFile(t, csvData),
+			FileSize:  int64(len(csvData)),
+			Status:    models.ImportJobStatusRunning,
+		}
+		mockRepo.On("ClaimImportJob", ctx, job.ID, importConfig.LeaseDuration).Return(job, nil).Once()
+
+		var savedCards []*models.CardToIssue
+		var savedRows []*models.ImportJobRow
+		mockRepo.On("SaveImportChunk", mock.Anything, job, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				savedCards = append(savedCards, args.Get(2).([]*models.CardToIssue)...)
+				savedRows = append(savedRows, args.Get(3).([]*models.ImportJobRow)...)
+			}).Return(nil).Times(3)
+		mockRepo.On("UpdateImportJobStatus", ctx, mock.MatchedBy(func(j *models.ImportJob) bool {
+			return j.Status == models.ImportJobStatusCompleted && j.CompletedAt != nil
+		})).Return(nil).Once()
+
+		err := svc.RunImportJob(ctx, job.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, 7, job.TotalRows)
+		assert.Equal(t, 2, job.Accepted)
+		assert.Equal(t, 2, job.Rejected)
+		assert.Equal(t, 3, job.Duplicates)
+		assert.Equal(t, int64(len(csvData)), job.BytesProcessed)
+		assert.Equal(t, 8, job.LastLine)
+
+		require.Len(t, savedCards, 2)
+		first := savedCards[0]
+		assert.Equal(t, newEmployee, first.EmployeeID)
+		assert.Equal(t, "New Hire", *first.CardHolderName)
+		assert.Equal(t, models.CardTypePhysical, *first.CardType)
+		assert.Equal(t, 100.0, *first.DailyLimit)
+		assert.Equal(t, 50.0, *first.InitialFunding)
+		assert.Equal(t, directoryEmployee.ID, savedCards[1].EmployeeID)
+
+		require.Len(t, savedRows, 7)
+		assert.Equal(t, 2, savedRows[0].Line)
+		assert.Equal(t, response.CSVRowAccepted, savedRows[0].Status)
+		require.NotNil(t, savedRows[0].CardToIssueID)
+		assert.Equal(t, first.ID, *savedRows[0].CardToIssueID)
+		require.NotNil(t, savedRows[1].CardToIssueID)
+		assert.Equal(t, savedCards[1].ID, *savedRows[1].CardToIssueID)
+		assert.Equal(t, 4, savedRows[2].Line)
+		assert.Equal(t, response.CSVRowRejected, savedRows[2].Status)
+		assert.Nil(t, savedRows[2].CardToIssueID)
+		assert.Len(t, savedRows[3].Reasons, 4)
+		assert.Equal(t, response.CSVRowDuplicate, savedRows[6].Status)
+		assert.Equal(t, []string{"duplicate of line 2"}, savedRows[6].Reasons)
+
+		_, err = os.Stat(job.FilePath)
+		assert.True(t, os.IsNotExist(err))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("dry_run_does_not_queue_cards", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, jwtConfig, importConfig, newTestVault(t), nil)
+		setupMocks(mockRepo)
+
+		job := &models.ImportJob{
+			ID:        uuid.New(),
+			CompanyID: companyID,
+			FilePath:  writeImportFile(t, csvData),
+			DryRun:    true,
+			Status:    models.ImportJobStatusRunning,
+		}
+		mockRepo.On("ClaimImportJob", ctx, job.ID, importConfig.LeaseDuration).Return(job, nil).Once()
+		var savedRows []*models.ImportJobRow
+		mockRepo.On("SaveImportChunk", mock.Anything, job, mock.MatchedBy(func(cards []*models.CardToIssue) bool {
+			return len(cards) == 0
+		}), mock.Anything).Run(func(args mock.Arguments) {
+			savedRows = append(savedRows, args.Get(3).([]*models.ImportJobRow)...)
+		}).Return(nil).Times(3)
+		mockRepo.On("UpdateImportJobStatus", ctx, job).Return(nil).Once()
+
+		require.NoError(t, svc.RunImportJob(ctx, job.ID))
+		assert.Equal(t, 2, job.Accepted)
+		require.Len(t, savedRows, 7)
+		assert.Equal(t, response.CSVRowAccepted, savedRows[0].Status)
+		assert.Nil(t, savedRows[0].CardToIssueID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("resumes_after_saved_chunk", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, jwtConfig, importConfig, newTestVault(t), nil)
+
+		header := "employee_id,employee_email\n"
+		done := uuid.New().String() + ",done@example.com\n"
+		remaining := uuid.New().String() + ",remaining@example.com\n"
+		content := header + done + remaining
+
+		job := &models.ImportJob{
+			ID:             uuid.New(),
+			CompanyID:      companyID,
+			FilePath:       writeImportFile(t, content),
+			Status:         models.ImportJobStatusRunning,
+			BytesProcessed: int64(len(header + done)),
+			LastLine:       2,
+			TotalRows:      1,
+			Accepted:       1,
+		}
+		mockRepo.On("ClaimImportJob", ctx, job.ID, importConfig.LeaseDuration).Return(job, nil).Once()
+		mockRepo.On("GetEmployeeIDsWithCards", mock.Anything, companyID, mock.Anything).Return(map[uuid.UUID]bool{}, nil).Once()
+		mockRepo.On("GetEmployeeIDsPendingIssue", mock.Anything, companyID, mock.Anything).Return(map[uuid.UUID]bool{}, nil).Once()
+		mockRepo.On("SaveImportChunk", mock.Anything, job, mock.MatchedBy(func(cards []*models.CardToIssue) bool {
+			return len(cards) == 1 && cards[0].EmployeeEmail == "remaining@example.com"
+		}), mock.Anything).Return(nil).Once()
+		mockRepo.On("UpdateImportJobStatus", ctx, job).Return(nil).Once()
+
+		require.NoError(t, svc.RunImportJob(ctx, job.ID))
+		assert.Equal(t, 2, job.TotalRows)
+		assert.Equal(t, 3, job.LastLine)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("failed_chunk_marks_job_failed", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, jwtConfig, importConfig, newTestVault(t), nil)
+		setupMocks(mockRepo)
+
+		job := &models.ImportJob{
+			ID:        uuid.New(),
+			CompanyID: companyID,
+			FilePath:  writeImportFile(t, csvData),
+			Status:    models.ImportJobStatusRunning,
+		}
+		mockRepo.On("ClaimImportJob", ctx, job.ID, importConfig.LeaseDuration).Return(job, nil).Once()
+		mockRepo.On("SaveImportChunk", mock.Anything, job, mock.Anything, mock.Anything).Return(fmt.Errorf("database error")).Once()
+		mockRepo.On("UpdateImportJobStatus", ctx, mock.MatchedBy(func(j *models.ImportJob) bool {
+			return j.Status == models.ImportJobStatusFailed && j.Error != nil
+		})).Return(nil).Once()
+
+		err := svc.RunImportJob(ctx, job.ID)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "database error")
+
+		_, statErr := os.Stat(job.FilePath)
+		assert.NoError(t, statErr, "file must be kept so the job can be resumed")
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("already_claimed", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, jwtConfig, importConfig, newTestVault(t), nil)
+
+		jobID := uuid.New()
+		mockRepo.On("ClaimImportJob", ctx, jobID, importConfig.LeaseDuration).Return(nil, nil).Once()
+
+		require.NoError(t, svc.RunImportJob(ctx, jobID))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("lost_lease_stops_without_failing", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		leaseConfig := config.ImportConfig{ChunkSize: 3, LeaseDuration: 30 * time.Millisecond}
+		svc := client.NewService(mockRepo, jwtConfig, leaseConfig, newTestVault(t), nil)
+		setupMocks(mockRepo)
+
+		job := &models.ImportJob{
+			ID:        uuid.New(),
+			CompanyID: companyID,
+			FilePath:  writeImportFile(t, csvData),
+			Status:    models.ImportJobStatusRunning,
+		}
+		mockRepo.On("ClaimImportJob", ctx, job.ID, leaseConfig.LeaseDuration).Return(job, nil).Once()
+		mockRepo.On("ExtendImportJobLease", mock.Anything, job.ID, leaseConfig.LeaseDuration).Return(false, nil).Once()
+		mockRepo.On("SaveImportChunk", mock.Anything, job, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				<-args.Get(0).(context.Context).Done()
+			}).Return(nil).Once()
+
+		err := svc.RunImportJob(ctx, job.ID)
+		require.ErrorIs(t, err, context.Canceled)
+
+		mockRepo.AssertNotCalled(t, "UpdateImportJobStatus", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestGetImportJob(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockRepository)
+	svc := client.NewService(mockRepo, config.JWTConfig{}, config.ImportConfig{}, newTestVault(t), nil)
+
+	companyID := uuid.New()
+	cardToIssueID := uuid.New()
+	job := &models.ImportJob{ID: uuid.New(), CompanyID: companyID, Status: models.ImportJobStatusCompleted}
+
+	mockRepo.On("GetImportJob", ctx, companyID, job.ID).Return(job, nil).Once()
+	mockRepo.On("GetImportJobRows", ctx, job.ID, 20, 0).Return([]*models.ImportJobRow{
+		{JobID: job.ID, Line: 2, EmployeeEmail: "new@example.com", Status: response.CSVRowAccepted, CardToIssueID: &cardToIssueID},
+		{JobID: job.ID, Line: 3, EmployeeEmail: "bad", Status: response.CSVRowRejected, Reasons: []string{"invalid employee_email"}},
+	}, nil).Once()
+
+	status, err := svc.GetImportJob(ctx, companyID, job.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, status.Progress)
+	require.Len(t, status.Rows, 2)
+	assert.Equal(t, response.CSVRowAccepted, status.Rows[0].Status)
+	assert.Equal(t, &cardToIssueID, status.Rows[0].CardToIssueID)
+	assert.Nil(t, status.Rows[1].CardToIssueID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestStartImportWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockRepo := new(MockRepository)
+	importConfig := config.ImportConfig{Workers: 1, LeaseDuration: time.Minute, PollInterval: time.Hour}
+	svc := client.NewService(mockRepo, config.JWTConfig{}, importConfig, newTestVault(t), nil)
+
+	staleJob := uuid.New()
+	claimed := make(chan struct{})
+	mockRepo.On("RequeueStaleImportJobs", ctx).Return(int64(1), nil).Once()
+	mockRepo.On("GetQueuedImportJobIDs", ctx).Return([]uuid.UUID{staleJob}, nil).Once()
+	mockRepo.On("ClaimImportJob", ctx, staleJob, importConfig.LeaseDuration).
+		Run(func(args mock.Arguments) { close(claimed) }).
+		Return(nil, nil).Once()
+
+	svc.StartImportWorkers(ctx)
+
+	select {
+	case <-claimed:
+	case <-time.After(time.Second):
+		t.Fatal("requeued job was not picked up")
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestStartCardImport(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	jwtConfig := config.JWTConfig{Secret: "test-secret"}
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{Dir: t.TempDir()}, newTestVault(t), nil)
+
+		content := "employee_id,employee_email\n" + uuid.New().String() + ",a@example.com\n"
+		mockRepo.On("CreateImportJob", ctx, mock.AnythingOfType("*models.ImportJob")).Return(nil).Once()
+
+		job, err := svc.StartCardImport(ctx, companyID, "employees.csv", strings.NewReader(content), false)
+		require.NoError(t, err)
+		assert.Equal(t, models.ImportJobStatusQueued, job.Status)
+		assert.Equal(t, int64(len(content)), job.FileSize)
+
+		saved, err := os.ReadFile(job.FilePath)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(saved))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("missing_required_column", func(t *testing.T) {
+		mockRepo := new(MockRepository)
+		dir := t.TempDir()
+		svc := client.NewService(mockRepo, jwtConfig, config.ImportConfig{Dir: dir}, newTestVault(t), nil)
+
+		job, err := svc.StartCardImport(ctx, companyID, "employees.csv", strings.NewReader("employee_id,name\n"+uuid.New().String()+",A\n"), false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "employee_email")
+		assert.Nil(t, job)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+		mockRepo.AssertNotCalled(t, "CreateImportJob", mock.Anything, mock.Anything)
+	})
+}
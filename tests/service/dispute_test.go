@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/dispute"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type MockDisputeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDisputeRepository) GetTransaction(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	args := m.Called(ctx, companyID, transactionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+func (m *MockDisputeRepository) CreateDispute(ctx context.Context, d *models.Dispute) error {
+	args := m.Called(ctx, d)
+	return args.Error(0)
+}
+
+func (m *MockDisputeRepository) GetDispute(ctx context.Context, companyID, disputeID uuid.UUID) (*models.Dispute, error) {
+	args := m.Called(ctx, companyID, disputeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) GetDisputes(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Dispute, error) {
+	args := m.Called(ctx, companyID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) UpdateDisputeStatus(ctx context.Context, companyID, disputeID uuid.UUID, from, to string) (*models.Dispute, error) {
+	args := m.Called(ctx, companyID, disputeID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) ResolveDispute(ctx context.Context, companyID, disputeID uuid.UUID, outcome string, note *string) (*models.Dispute, error) {
+	args := m.Called(ctx, companyID, disputeID, outcome, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) AddEvidence(ctx context.Context, evidence *models.DisputeEvidence) error {
+	args := m.Called(ctx, evidence)
+	return args.Error(0)
+}
+
+func (m *MockDisputeRepository) GetEvidence(ctx context.Context, companyID, disputeID, evidenceID uuid.UUID) (*models.DisputeEvidence, error) {
+	args := m.Called(ctx, companyID, disputeID, evidenceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DisputeEvidence), args.Error(1)
+}
+
+func purchase(companyID uuid.UUID, amount float64, age time.Duration) *models.Transaction {
+	return &models.Transaction{
+		ID:              uuid.New(),
+		CardID:          uuid.New(),
+		CompanyID:       companyID,
+		TransactionType: models.TransactionTypePurchase,
+		Amount:          amount,
+		Status:          models.TransactionStatusCompleted,
+		CreatedAt:       time.Now().Add(-age),
+	}
+}
+
+func TestFileDispute(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		txn := purchase(companyID, 120, 24*time.Hour)
+		mockRepo.On("GetTransaction", ctx, companyID, txn.ID).Return(txn, nil)
+		mockRepo.On("CreateDispute", ctx, mock.AnythingOfType("*models.Dispute")).Return(nil)
+
+		result, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{
+			TransactionID: txn.ID,
+			Reason:        "not_received",
+			Description:   "  Goods never arrived ",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, models.DisputeStatusOpened, result.Status)
+		assert.Equal(t, 120.0, result.Amount)
+		assert.Equal(t, txn.CardID, result.CardID)
+		assert.Equal(t, "Goods never arrived", *result.Description)
+		assert.WithinDuration(t, time.Now().Add(dispute.EvidenceWindow), result.EvidenceDueAt, time.Minute)
+		assert.WithinDuration(t, time.Now().Add(dispute.ResolutionWindow), result.ResolveBy, time.Minute)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("partial_amount_too_large", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		txn := purchase(companyID, 120, time.Hour)
+		mockRepo.On("GetTransaction", ctx, companyID, txn.ID).Return(txn, nil)
+
+		amount := 150.0
+		_, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{TransactionID: txn.ID, Reason: "incorrect_amount", Amount: &amount})
+		assert.True(t, errors.Is(err, errors.ErrDisputeAmount))
+		mockRepo.AssertNotCalled(t, "CreateDispute", mock.Anything, mock.Anything)
+	})
+
+	t.Run("refunded_part_not_disputable", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		txn := purchase(companyID, 120, time.Hour)
+		txn.RefundedAmount = 100
+		mockRepo.On("GetTransaction", ctx, companyID, txn.ID).Return(txn, nil)
+		mockRepo.On("CreateDispute", ctx, mock.AnythingOfType("*models.Dispute")).Return(nil)
+
+		amount := 30.0
+		_, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{TransactionID: txn.ID, Reason: "incorrect_amount", Amount: &amount})
+		assert.True(t, errors.Is(err, errors.ErrDisputeAmount))
+		mockRepo.AssertNotCalled(t, "CreateDispute", mock.Anything, mock.Anything)
+
+		result, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{TransactionID: txn.ID, Reason: "incorrect_amount"})
+		require.NoError(t, err)
+		assert.Equal(t, 20.0, result.Amount)
+	})
+
+	t.Run("filing_window_closed", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		txn := purchase(companyID, 120, dispute.FilingWindow+time.Hour)
+		mockRepo.On("GetTransaction", ctx, companyID, txn.ID).Return(txn, nil)
+
+		_, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{TransactionID: txn.ID, Reason: "fraud"})
+		assert.True(t, errors.Is(err, errors.ErrDisputeDeadline))
+	})
+
+	t.Run("not_a_purchase", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		txn := purchase(companyID, 120, time.Hour)
+		txn.TransactionType = models.TransactionTypeProvisionalCredit
+		mockRepo.On("GetTransaction", ctx, companyID, txn.ID).Return(txn, nil)
+
+		_, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{TransactionID: txn.ID, Reason: "duplicate"})
+		assert.True(t, errors.Is(err, errors.ErrNotDisputable))
+	})
+
+	t.Run("transaction_not_found", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		id := uuid.New()
+		mockRepo.On("GetTransaction", ctx, companyID, id).Return(nil, nil)
+
+		_, err := svc.FileDispute(ctx, companyID, &request.CreateDispute{TransactionID: id, Reason: "fraud"})
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+	})
+}
+
+func TestDisputeStatusChanges(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	newDispute := func(status string) *models.Dispute {
+		return &models.Dispute{
+			ID:            uuid.New(),
+			CompanyID:     companyID,
+			Status:        status,
+			EvidenceDueAt: time.Now().Add(time.Hour),
+		}
+	}
+
+	t.Run("submit", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		d := newDispute(models.DisputeStatusOpened)
+		mockRepo.On("GetDispute", ctx, companyID, d.ID).Return(d, nil)
+		mockRepo.On("UpdateDisputeStatus", ctx, companyID, d.ID, models.DisputeStatusOpened, models.DisputeStatusUnderReview).
+			Return(newDispute(models.DisputeStatusUnderReview), nil)
+
+		result, err := svc.SubmitDispute(ctx, companyID, d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.DisputeStatusUnderReview, result.Status)
+	})
+
+	t.Run("resolve_requires_review", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		d := newDispute(models.DisputeStatusOpened)
+		mockRepo.On("GetDispute", ctx, companyID, d.ID).Return(d, nil)
+
+		_, err := svc.ResolveDispute(ctx, companyID, d.ID, &request.ResolveDispute{Outcome: models.DisputeStatusWon})
+		assert.True(t, errors.Is(err, errors.ErrDisputeTransition))
+		mockRepo.AssertNotCalled(t, "ResolveDispute", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("resolve_lost", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		d := newDispute(models.DisputeStatusUnderReview)
+		mockRepo.On("GetDispute", ctx, companyID, d.ID).Return(d, nil)
+		mockRepo.On("ResolveDispute", ctx, companyID, d.ID, models.DisputeStatusLost, mock.MatchedBy(func(note *string) bool {
+			return note != nil && *note == "Merchant proved delivery"
+		})).Return(newDispute(models.DisputeStatusLost), nil)
+
+		result, err := svc.ResolveDispute(ctx, companyID, d.ID, &request.ResolveDispute{Outcome: models.DisputeStatusLost, Note: "Merchant proved delivery"})
+		require.NoError(t, err)
+		assert.Equal(t, models.DisputeStatusLost, result.Status)
+	})
+
+	t.Run("resolved_is_final", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		d := newDispute(models.DisputeStatusWon)
+		mockRepo.On("GetDispute", ctx, companyID, d.ID).Return(d, nil)
+
+		_, err := svc.SubmitDispute(ctx, companyID, d.ID)
+		assert.True(t, errors.Is(err, errors.ErrDisputeTransition))
+
+		err = svc.AddEvidence(ctx, companyID, d.ID, &models.DisputeEvidence{Content: []byte("receipt")})
+		assert.True(t, errors.Is(err, errors.ErrDisputeTransition))
+	})
+
+	t.Run("evidence_deadline", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		d := newDispute(models.DisputeStatusOpened)
+		d.EvidenceDueAt = time.Now().Add(-time.Minute)
+		mockRepo.On("GetDispute", ctx, companyID, d.ID).Return(d, nil)
+
+		err := svc.AddEvidence(ctx, companyID, d.ID, &models.DisputeEvidence{Content: []byte("receipt")})
+		assert.True(t, errors.Is(err, errors.ErrDisputeDeadline))
+	})
+
+	t.Run("evidence_added", func(t *testing.T) {
+		mockRepo := new(MockDisputeRepository)
+		svc := dispute.NewService(mockRepo)
+
+		d := newDispute(models.DisputeStatusUnderReview)
+		mockRepo.On("GetDispute", ctx, companyID, d.ID).Return(d, nil)
+		mockRepo.On("AddEvidence", ctx, mock.AnythingOfType("*models.DisputeEvidence")).Return(nil)
+
+		evidence := &models.DisputeEvidence{FileName: "receipt.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.4")}
+		err := svc.AddEvidence(ctx, companyID, d.ID, evidence)
+		require.NoError(t, err)
+		assert.Equal(t, d.ID, evidence.DisputeID)
+		assert.Equal(t, 8, evidence.SizeBytes)
+	})
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/employee"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type MockEmployeeRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmployeeRepository) CreateEmployee(ctx context.Context, emp *models.Employee) error {
+	args := m.Called(ctx, emp)
+	return args.Error(0)
+}
+
+func (m *MockEmployeeRepository) GetEmployeeByID(ctx context.Context, companyID, id uuid.UUID) (*models.Employee, error) {
+	args := m.Called(ctx, companyID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Employee), args.Error(1)
+}
+
+func (m *MockEmployeeRepository) GetEmployeesByCompanyID(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Employee, error) {
+	args := m.Called(ctx, companyID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Employee), args.Error(1)
+}
+
+func (m *MockEmployeeRepository) UpdateEmployee(ctx context.Context, emp *models.Employee) error {
+	args := m.Called(ctx, emp)
+	return args.Error(0)
+}
+
+func (m *MockEmployeeRepository) DeleteEmployee(ctx context.Context, companyID, id uuid.UUID) error {
+	args := m.Called(ctx, companyID, id)
+	return args.Error(0)
+}
+
+func (m *MockEmployeeRepository) GetCardsByEmployeeID(ctx context.Context, companyID, employeeID uuid.UUID) ([]*models.Card, error) {
+	args := m.Called(ctx, companyID, employeeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Card), args.Error(1)
+}
+
+func (m *MockEmployeeRepository) GetOffboardingByEmployeeID(ctx context.Context, companyID, employeeID uuid.UUID) (*models.EmployeeOffboarding, error) {
+	args := m.Called(ctx, companyID, employeeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.EmployeeOffboarding), args.Error(1)
+}
+
+func (m *MockEmployeeRepository) StartOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding) error {
+	args := m.Called(ctx, offboarding)
+	return args.Error(0)
+}
+
+func (m *MockEmployeeRepository) OffboardCard(ctx context.Context, offboarding *models.EmployeeOffboarding, cardID uuid.UUID) (*models.OffboardedCard, error) {
+	args := m.Called(ctx, offboarding, cardID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OffboardedCard), args.Error(1)
+}
+
+func (m *MockEmployeeRepository) GetOffboardedCards(ctx context.Context, offboardingID uuid.UUID) ([]*models.OffboardedCard, error) {
+	args := m.Called(ctx, offboardingID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.OffboardedCard), args.Error(1)
+}
+
+func (m *MockEmployeeRepository) FinishOffboarding(ctx context.Context, offboarding *models.EmployeeOffboarding) error {
+	args := m.Called(ctx, offboarding)
+	return args.Error(0)
+}
+
+func TestCreateEmployee(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		req := &request.CreateEmployee{Email: "jane@example.com", Name: "Jane Doe"}
+		mockRepo.On("CreateEmployee", ctx, mock.AnythingOfType("*models.Employee")).Return(nil).Once()
+
+		emp, err := svc.CreateEmployee(ctx, companyID, req)
+		require.NoError(t, err)
+		assert.Equal(t, companyID, emp.CompanyID)
+		assert.Equal(t, models.EmployeeStatusActive, emp.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown_manager", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		managerID := uuid.New()
+		req := &request.CreateEmployee{Email: "jane@example.com", Name: "Jane Doe", ManagerID: &managerID}
+		mockRepo.On("GetEmployeeByID", ctx, companyID, managerID).Return(nil, nil).Once()
+
+		emp, err := svc.CreateEmployee(ctx, companyID, req)
+		assert.Equal(t, errors.ErrInvalidManager, err)
+		assert.Nil(t, emp)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestOffboardEmployee(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	employeeID := uuid.New()
+	emp := &models.Employee{ID: employeeID, CompanyID: companyID, Name: "Leaver", Status: models.EmployeeStatusActive}
+	req := &request.OffboardEmployee{CardAction: models.OffboardingCardActionCancel}
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		cards := []*models.Card{{ID: uuid.New()}, {ID: uuid.New()}}
+		results := []*models.OffboardedCard{
+			{CardID: cards[0].ID, NewStatus: models.CardStatusCancelled, BalanceReturned: 120.50, HoldsVoided: 1, ControlsRevoked: 2},
+			{CardID: cards[1].ID, NewStatus: models.CardStatusCancelled, BalanceReturned: 30},
+		}
+
+		mockRepo.On("GetEmployeeByID", ctx, companyID, employeeID).Return(emp, nil).Once()
+		mockRepo.On("GetOffboardingByEmployeeID", ctx, companyID, employeeID).Return(nil, nil).Once()
+		mockRepo.On("StartOffboarding", ctx, mock.AnythingOfType("*models.EmployeeOffboarding")).Return(nil).Once()
+		mockRepo.On("GetCardsByEmployeeID", ctx, companyID, employeeID).Return(cards, nil).Once()
+		mockRepo.On("OffboardCard", ctx, mock.AnythingOfType("*models.EmployeeOffboarding"), cards[0].ID).Return(results[0], nil).Once()
+		mockRepo.On("OffboardCard", ctx, mock.AnythingOfType("*models.EmployeeOffboarding"), cards[1].ID).Return(results[1], nil).Once()
+		mockRepo.On("FinishOffboarding", ctx, mock.MatchedBy(func(o *models.EmployeeOffboarding) bool {
+			return o.Status == models.OffboardingStatusCompleted
+		})).Return(nil).Once()
+		mockRepo.On("GetOffboardedCards", ctx, mock.AnythingOfType("uuid.UUID")).Return(results, nil).Once()
+
+		report, err := svc.OffboardEmployee(ctx, companyID, employeeID, req)
+		require.NoError(t, err)
+		assert.Equal(t, models.OffboardingStatusCompleted, report.Status)
+		assert.Equal(t, 2, report.CardsProcessed)
+		assert.InDelta(t, 150.50, report.TotalBalanceReturned, 0.001)
+		assert.Equal(t, 1, report.TotalHoldsVoided)
+		assert.Equal(t, 2, report.TotalControlsRevoked)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("card_failure_marks_offboarding_failed", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		cards := []*models.Card{{ID: uuid.New()}}
+
+		mockRepo.On("GetEmployeeByID", ctx, companyID, employeeID).Return(emp, nil).Once()
+		mockRepo.On("GetOffboardingByEmployeeID", ctx, companyID, employeeID).Return(nil, nil).Once()
+		mockRepo.On("StartOffboarding", ctx, mock.AnythingOfType("*models.EmployeeOffboarding")).Return(nil).Once()
+		mockRepo.On("GetCardsByEmployeeID", ctx, companyID, employeeID).Return(cards, nil).Once()
+		mockRepo.On("OffboardCard", ctx, mock.AnythingOfType("*models.EmployeeOffboarding"), cards[0].ID).Return(nil, fmt.Errorf("database error")).Once()
+		mockRepo.On("FinishOffboarding", ctx, mock.MatchedBy(func(o *models.EmployeeOffboarding) bool {
+			return o.Status == models.OffboardingStatusFailed && o.LastError != nil
+		})).Return(nil).Once()
+
+		report, err := svc.OffboardEmployee(ctx, companyID, employeeID, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "database error")
+		assert.Nil(t, report)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("completed_offboarding_returns_stored_report", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		completedAt := time.Now()
+		existing := &models.EmployeeOffboarding{
+			ID:          uuid.New(),
+			CompanyID:   companyID,
+			EmployeeID:  employeeID,
+			CardAction:  models.OffboardingCardActionBlock,
+			Status:      models.OffboardingStatusCompleted,
+			CompletedAt: &completedAt,
+		}
+		results := []*models.OffboardedCard{{CardID: uuid.New(), BalanceReturned: 10}}
+
+		mockRepo.On("GetEmployeeByID", ctx, companyID, employeeID).Return(emp, nil).Once()
+		mockRepo.On("GetOffboardingByEmployeeID", ctx, companyID, employeeID).Return(existing, nil).Once()
+		mockRepo.On("GetOffboardedCards", ctx, existing.ID).Return(results, nil).Once()
+
+		report, err := svc.OffboardEmployee(ctx, companyID, employeeID, req)
+		require.NoError(t, err)
+		assert.Equal(t, existing.ID, report.OffboardingID)
+		assert.Equal(t, models.OffboardingCardActionBlock, report.CardAction)
+		assert.InDelta(t, 10, report.TotalBalanceReturned, 0.001)
+		mockRepo.AssertNotCalled(t, "OffboardCard", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("retry_with_different_card_action", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		failed := &models.EmployeeOffboarding{ID: uuid.New(), CompanyID: companyID, EmployeeID: employeeID,
+			CardAction: models.OffboardingCardActionBlock, Status: models.OffboardingStatusFailed}
+		mockRepo.On("GetEmployeeByID", ctx, companyID, employeeID).Return(emp, nil).Once()
+		mockRepo.On("GetOffboardingByEmployeeID", ctx, companyID, employeeID).Return(failed, nil).Once()
+		mockRepo.On("StartOffboarding", ctx, mock.AnythingOfType("*models.EmployeeOffboarding")).Return(errors.ErrOffboardingConflict).Once()
+
+		report, err := svc.OffboardEmployee(ctx, companyID, employeeID, req)
+		assert.Equal(t, errors.ErrOffboardingConflict, err)
+		assert.Nil(t, report)
+		mockRepo.AssertNotCalled(t, "OffboardCard", mock.Anything, mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("employee_not_found", func(t *testing.T) {
+		mockRepo := new(MockEmployeeRepository)
+		svc := employee.NewService(mockRepo)
+
+		mockRepo.On("GetEmployeeByID", ctx, companyID, employeeID).Return(nil, nil).Once()
+
+		report, err := svc.OffboardEmployee(ctx, companyID, employeeID, req)
+		assert.Equal(t, errors.ErrNotFound, err)
+		assert.Nil(t, report)
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"ccards/pkg/endpoint"
+	"ccards/pkg/errors"
+)
+
+func TestCheckEndpointURL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("public_addresses", func(t *testing.T) {
+		for _, url := range []string{
+			"https://93.184.216.34/hooks",
+			"https://93.184.216.34:8443/hooks",
+			"https://[2606:2800:220:1:248:1893:25c8:1946]/hooks",
+		} {
+			assert.NoError(t, endpoint.CheckURL(ctx, url), url)
+		}
+	})
+
+	t.Run("internal_addresses", func(t *testing.T) {
+		for _, url := range []string{
+			"https://127.0.0.1/hooks",
+			"https://10.0.0.5/hooks",
+			"https://172.16.0.1/hooks",
+			"https://192.168.1.10/hooks",
+			"https://169.254.169.254/latest/meta-data",
+			"https://0.0.0.0/hooks",
+			"https://100.64.0.1/hooks",
+			"https://192.0.0.8/hooks",
+			"https://198.18.0.1/hooks",
+			"https://240.0.0.1/hooks",
+			"https://224.0.0.1/hooks",
+			"https://[::1]/hooks",
+			"https://[fd00::1]/hooks",
+			"https://[fe80::1]/hooks",
+			"https://[::ffff:127.0.0.1]/hooks",
+			"https://[64:ff9b::7f00:1]/hooks",
+		} {
+			assert.ErrorIs(t, endpoint.CheckURL(ctx, url), errors.ErrWebhookURL, url)
+		}
+	})
+
+	t.Run("internal_host_names", func(t *testing.T) {
+		for _, url := range []string{
+			"https://localhost/hooks",
+			"https://LOCALHOST./hooks",
+			"https://intranet/hooks",
+			"https://api.localhost/hooks",
+			"https://printer.local/hooks",
+			"https://metadata.google.internal/computeMetadata",
+			"https://nas.home.arpa/hooks",
+		} {
+			assert.ErrorIs(t, endpoint.CheckURL(ctx, url), errors.ErrWebhookURL, url)
+		}
+	})
+
+	t.Run("malformed_urls", func(t *testing.T) {
+		for _, url := range []string{
+			"http://93.184.216.34/hooks",
+			"ftp://93.184.216.34/hooks",
+			"https:///hooks",
+			"://missing-scheme",
+		} {
+			assert.ErrorIs(t, endpoint.CheckURL(ctx, url), errors.ErrWebhookURL, url)
+		}
+	})
+}
+
+func TestEndpointClient(t *testing.T) {
+	var called bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	t.Run("refuses_internal_addresses", func(t *testing.T) {
+		client := endpoint.NewClient(time.Second, false)
+
+		_, err := client.Get(server.URL)
+		assert.ErrorIs(t, err, errors.ErrWebhookURL)
+		assert.False(t, called)
+	})
+
+	t.Run("refuses_plain_http", func(t *testing.T) {
+		client := endpoint.NewClient(time.Second, false)
+
+		_, err := client.Get(strings.Replace(server.URL, "https://", "http://", 1))
+		assert.ErrorIs(t, err, errors.ErrWebhookURL)
+		assert.False(t, called)
+	})
+
+	t.Run("private_targets_allowed", func(t *testing.T) {
+		client := endpoint.NewClient(time.Second, true)
+		client.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.True(t, called)
+	})
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/gateway"
+	"ccards/pkg/authorization"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/iso8583"
+	"ccards/pkg/models"
+	"ccards/pkg/outbox"
+)
+
+type MockGatewayRepository struct {
+	mock.Mock
+}
+
+func (m *MockGatewayRepository) GetAuthorization(ctx context.Context, terminalID, rrn string) (*models.GatewayAuthorization, error) {
+	args := m.Called(ctx, terminalID, rrn)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.GatewayAuthorization), args.Error(1)
+}
+
+func (m *MockGatewayRepository) RecordDecline(ctx context.Context, decline *models.GatewayAuthorization, event *outbox.Event) error {
+	args := m.Called(ctx, decline, event)
+	return args.Error(0)
+}
+
+func (m *MockGatewayRepository) CompleteAuthorization(ctx context.Context, terminalID, rrn string, amount float64) (*models.GatewayAuthorization, error) {
+	args := m.Called(ctx, terminalID, rrn, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.GatewayAuthorization), args.Error(1)
+}
+
+func (m *MockGatewayRepository) CreateAuthorization(ctx context.Context, approval *models.GatewayAuthorization, purchase *models.Transaction) error {
+	args := m.Called(ctx, approval, purchase)
+	return args.Error(0)
+}
+
+func (m *MockGatewayRepository) ReverseAuthorization(ctx context.Context, terminalID, rrn string) (*models.GatewayAuthorization, error) {
+	args := m.Called(ctx, terminalID, rrn)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.GatewayAuthorization), args.Error(1)
+}
+
+type MockAuthorizer struct {
+	mock.Mock
+}
+
+func (m *MockAuthorizer) Authorize(ctx context.Context, req *authorization.Request) (*authorization.Decision, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*authorization.Decision), args.Error(1)
+}
+
+var testGatewayConfig = config.GatewayConfig{
+	Host:             "127.0.0.1",
+	IdleTimeout:      time.Minute,
+	CurrencyCode:     "392",
+	CurrencyExponent: 0,
+}
+
+func newAuthorizationMessage(mti string) *iso8583.Message {
+	msg := iso8583.NewMessage(mti)
+	msg.Set(iso8583.FieldPAN, "4111111111111111")
+	msg.Set(iso8583.FieldProcessingCode, "000000")
+	msg.Set(iso8583.FieldAmount, "000000001500")
+	msg.Set(iso8583.FieldSTAN, "000123")
+	msg.Set(iso8583.FieldExpiryDate, "2912")
+	msg.Set(iso8583.FieldMerchantType, "5411")
+	msg.Set(iso8583.FieldPOSEntryMode, "071")
+	msg.Set(iso8583.FieldRRN, "123456789012")
+	msg.Set(iso8583.FieldTerminalID, "TERM0001")
+	msg.Set(iso8583.FieldCardAcceptorNameLocale, "Corner Grocery          Tokyo        JP")
+	msg.Set(iso8583.FieldCurrencyCode, "392")
+	return msg
+}
+
+func TestGatewayAuthorization(t *testing.T) {
+	ctx := context.Background()
+	cardVault := newTestVault(t)
+	card := &models.Card{ID: uuid.New(), CompanyID: uuid.New()}
+
+	approve := func(req *authorization.Request) { req.Card = card }
+
+	t.Run("approved", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(nil, nil)
+		mockAuthorizer.On("Authorize", ctx, mock.MatchedBy(func(req *authorization.Request) bool {
+			return req.PANFingerprint == cardVault.Fingerprint("4111111111111111") &&
+				req.Amount == 1500 && req.ExpiryYear == 2029 && req.ExpiryMonth == 12 &&
+				req.MCC == "5411" && req.MerchantCategory == models.MerchantCategoryOther && req.MerchantName == "Corner Grocery" &&
+				req.Channel == models.TransactionChannelContactless && req.TerminalID == "TERM0001"
+		})).Run(func(args mock.Arguments) { approve(args.Get(1).(*authorization.Request)) }).Return(&authorization.Decision{Approved: true}, nil)
+		mockRepo.On("CreateAuthorization", ctx, mock.MatchedBy(func(approval *models.GatewayAuthorization) bool {
+			return approval.RRN == "123456789012" && approval.STAN == "000123" && *approval.CardID == card.ID &&
+				approval.MTI == iso8583.MTIFinancialRequest && len(approval.AuthCode) == 6 &&
+				approval.ResponseCode == iso8583.ResponseApproved && approval.Status == models.GatewayAuthorizationStatusApproved
+		}), mock.MatchedBy(func(purchase *models.Transaction) bool {
+			return purchase.Amount == 1500 && purchase.CompanyID == card.CompanyID &&
+				purchase.Status == models.TransactionStatusCompleted
+		})).Return(nil)
+
+		resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIFinancialRequest))
+
+		assert.Equal(t, iso8583.MTIFinancialResponse, resp.MTI)
+		assert.Equal(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+		assert.Len(t, resp.Get(iso8583.FieldAuthorizationCode), 6)
+		assert.Equal(t, "000123", resp.Get(iso8583.FieldSTAN))
+		assert.False(t, resp.Has(iso8583.FieldPAN))
+		mockRepo.AssertExpectations(t)
+		mockAuthorizer.AssertExpectations(t)
+	})
+
+	t.Run("authorization_request_places_a_hold", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(nil, nil)
+		mockAuthorizer.On("Authorize", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { approve(args.Get(1).(*authorization.Request)) }).Return(&authorization.Decision{Approved: true}, nil)
+		mockRepo.On("CreateAuthorization", ctx, mock.MatchedBy(func(hold *models.GatewayAuthorization) bool {
+			return hold.MTI == iso8583.MTIAuthorizationRequest && hold.Status == models.GatewayAuthorizationStatusAuthorized
+		}), mock.MatchedBy(func(purchase *models.Transaction) bool {
+			return purchase.Status == models.TransactionStatusPending && purchase.ProcessedAt == nil
+		})).Return(nil)
+
+		resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIAuthorizationRequest))
+
+		assert.Equal(t, iso8583.MTIAuthorizationResponse, resp.MTI)
+		assert.Equal(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("declines_map_to_response_codes", func(t *testing.T) {
+		cases := map[string]string{
+			authorization.ReasonCardNotFound:      iso8583.ResponseInvalidCard,
+			authorization.ReasonCardExpired:       iso8583.ResponseExpiredCard,
+			authorization.ReasonCardInactive:      iso8583.ResponseRestrictedCard,
+			authorization.ReasonInsufficientFunds: iso8583.ResponseInsufficientFunds,
+			authorization.ReasonDailyLimit:        iso8583.ResponseExceedsLimit,
+			authorization.ReasonSpendingControl:   iso8583.ResponseNotPermitted,
+			authorization.ReasonVelocity:          iso8583.ResponseExceedsFrequency,
+			authorization.ReasonSuspectedFraud:    iso8583.ResponseSuspectedFraud,
+		}
+		for reason, code := range cases {
+			mockRepo := new(MockGatewayRepository)
+			mockAuthorizer := new(MockAuthorizer)
+			svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+			mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(nil, nil)
+			mockAuthorizer.On("Authorize", ctx, mock.Anything).
+				Run(func(args mock.Arguments) { approve(args.Get(1).(*authorization.Request)) }).
+				Return(&authorization.Decision{Decline: &authorization.Decline{Reason: reason}}, nil)
+			mockRepo.On("RecordDecline", ctx, mock.MatchedBy(func(decline *models.GatewayAuthorization) bool {
+				return decline.Status == models.GatewayAuthorizationStatusDeclined && decline.ResponseCode == code &&
+					decline.TransactionID == nil && decline.AuthCode == ""
+			}), mock.MatchedBy(func(event *outbox.Event) bool {
+				declined, ok := event.Data.(models.PaymentDeclinedEvent)
+				return event.Type == models.EventPaymentDeclined && event.CompanyID == card.CompanyID &&
+					ok && declined.CardID == card.ID && declined.Reason == reason
+			})).Return(nil)
+
+			resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIAuthorizationRequest))
+
+			assert.Equal(t, iso8583.MTIAuthorizationResponse, resp.MTI)
+			assert.Equal(t, code, resp.Get(iso8583.FieldResponseCode), reason)
+			mockRepo.AssertExpectations(t)
+			mockRepo.AssertNotCalled(t, "CreateAuthorization", mock.Anything, mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("repeated_request_gets_the_original_response", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(&models.GatewayAuthorization{
+			MTI:          iso8583.MTIAuthorizationRequest,
+			STAN:         "000123",
+			AuthCode:     "654321",
+			ResponseCode: iso8583.ResponseApproved,
+			Status:       models.GatewayAuthorizationStatusAuthorized,
+		}, nil)
+
+		resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIAuthorizationRequest))
+
+		assert.Equal(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+		assert.Equal(t, "654321", resp.Get(iso8583.FieldAuthorizationCode))
+		mockAuthorizer.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "CreateAuthorization", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("repeated_decline_gets_the_original_response", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(&models.GatewayAuthorization{
+			MTI:          iso8583.MTIAuthorizationRequest,
+			STAN:         "000123",
+			ResponseCode: iso8583.ResponseExceedsFrequency,
+			Status:       models.GatewayAuthorizationStatusDeclined,
+		}, nil)
+
+		resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIAuthorizationRequest))
+
+		assert.Equal(t, iso8583.ResponseExceedsFrequency, resp.Get(iso8583.FieldResponseCode))
+		assert.False(t, resp.Has(iso8583.FieldAuthorizationCode))
+		mockAuthorizer.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything)
+	})
+
+	t.Run("duplicate_reference", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		// The terminal used the reference number for another request.
+		mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(&models.GatewayAuthorization{
+			MTI:          iso8583.MTIAuthorizationRequest,
+			STAN:         "000099",
+			ResponseCode: iso8583.ResponseApproved,
+		}, nil)
+
+		resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIAuthorizationRequest))
+		assert.Equal(t, iso8583.ResponseDuplicate, resp.Get(iso8583.FieldResponseCode))
+		mockAuthorizer.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything)
+	})
+
+	t.Run("concurrent_duplicate", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		mockRepo.On("GetAuthorization", ctx, "TERM0001", "123456789012").Return(nil, nil)
+		mockAuthorizer.On("Authorize", ctx, mock.Anything).
+			Run(func(args mock.Arguments) { approve(args.Get(1).(*authorization.Request)) }).Return(&authorization.Decision{Approved: true}, nil)
+		mockRepo.On("CreateAuthorization", ctx, mock.Anything, mock.Anything).Return(errors.ErrPaymentExists)
+
+		resp := svc.Handle(ctx, newAuthorizationMessage(iso8583.MTIAuthorizationRequest))
+		assert.Equal(t, iso8583.ResponseDuplicate, resp.Get(iso8583.FieldResponseCode))
+	})
+
+	t.Run("invalid_requests", func(t *testing.T) {
+		mockRepo := new(MockGatewayRepository)
+		mockAuthorizer := new(MockAuthorizer)
+		svc := gateway.NewService(mockRepo, mockAuthorizer, cardVault, testGatewayConfig)
+
+		missingPAN := newAuthorizationMessage(iso8583.MTIAuthorizationRequest)
+		missingPAN.Set(iso8583.FieldPAN, "")
+		assert.Equal(t, iso8583.ResponseFormatError, svc.Handle(ctx, missingPAN).Get(iso8583.FieldResponseCode))
+
+		balanceInquiry := newAuthorizationMessage(iso8583.MTIAuthorizationRequest)
+		balanceInquiry.Set(iso8583.FieldProcessingCode, "310000")
+		assert.Equal(t, iso8583.ResponseInvalidTransaction, svc.Handle(ctx, balanceInquiry).Get(iso8583.FieldResponseCode))
+
+		otherCurrency := newAuthorizationMessage(iso8583.MTIAuthorizationRequest)
+		otherCurrency.Set(iso8583.FieldCurrencyCode, "840")
+		assert.Equal(t, iso8583.ResponseInvalidTransaction, svc.Handle(ctx, otherCurrency).Get(iso8583.FieldResponseCode))
+
+		zeroAmount := newAuthorizationMessage(iso8583.MTIAuthorizationRequest)
+		zeroAmount.Set(iso8583.FieldAmount, "000000000000")
+		assert.Equal(t, iso8583.ResponseInvalidAmount, svc.Handle(ctx, zeroAmount).Get(iso8583.FieldResponseCode))
+
+		for _, expiry := range []string{"29AB", "2913", "291"} {
+			badExpiry := newAuthorizationMessage(iso8583.MTIAuthorizationRequest)
+			badExpiry.Set(iso8583.FieldExpiryDate, expiry)
+			assert.Equal(t, iso8583.ResponseFormatError, svc.Handle(ctx, badExpiry).Get(iso8583.FieldResponseCode), expiry)
+		}
+
+		mockAuthorizer.AssertNotCalled(t, "Authorize", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGatewayCompletion(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockGatewayRepository)
+	svc := gateway.NewService(mockRepo, new(MockAuthorizer), newTestVault(t), testGatewayConfig)
+
+	advice := iso8583.NewMessage(iso8583.MTIFinancialAdvice)
+	advice.Set(iso8583.FieldProcessingCode, "000000")
+	advice.Set(iso8583.FieldAmount, "000000001200")
+	advice.Set(iso8583.FieldSTAN, "000125")
+	advice.Set(iso8583.FieldRRN, "123456789012")
+	advice.Set(iso8583.FieldTerminalID, "TERM0001")
+
+	mockRepo.On("CompleteAuthorization", ctx, "TERM0001", "123456789012", 1200.0).
+		Return(&models.GatewayAuthorization{AuthCode: "654321", Status: models.GatewayAuthorizationStatusCompleted}, nil).Once()
+	resp := svc.Handle(ctx, advice)
+	assert.Equal(t, iso8583.MTIFinancialAdviceResponse, resp.MTI)
+	assert.Equal(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+	assert.Equal(t, "654321", resp.Get(iso8583.FieldAuthorizationCode))
+
+	mockRepo.On("CompleteAuthorization", ctx, "TERM0001", "123456789012", 1200.0).Return(nil, errors.ErrPaymentAmount).Once()
+	resp = svc.Handle(ctx, advice)
+	assert.Equal(t, iso8583.ResponseInvalidAmount, resp.Get(iso8583.FieldResponseCode))
+
+	mockRepo.On("CompleteAuthorization", ctx, "TERM0001", "123456789012", 1200.0).Return(nil, errors.ErrPaymentTransition).Once()
+	resp = svc.Handle(ctx, advice)
+	assert.Equal(t, iso8583.ResponseInvalidTransaction, resp.Get(iso8583.FieldResponseCode))
+
+	mockRepo.On("CompleteAuthorization", ctx, "TERM0001", "123456789012", 1200.0).Return(nil, nil).Once()
+	resp = svc.Handle(ctx, advice)
+	assert.Equal(t, iso8583.ResponseRecordNotFound, resp.Get(iso8583.FieldResponseCode))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGatewayReversal(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockGatewayRepository)
+	svc := gateway.NewService(mockRepo, new(MockAuthorizer), newTestVault(t), testGatewayConfig)
+
+	reversal := iso8583.NewMessage(iso8583.MTIReversalRequest)
+	reversal.Set(iso8583.FieldProcessingCode, "000000")
+	reversal.Set(iso8583.FieldAmount, "000000001500")
+	reversal.Set(iso8583.FieldSTAN, "000124")
+	reversal.Set(iso8583.FieldRRN, "123456789012")
+	reversal.Set(iso8583.FieldTerminalID, "TERM0001")
+
+	mockRepo.On("ReverseAuthorization", ctx, "TERM0001", "123456789012").
+		Return(&models.GatewayAuthorization{Status: models.GatewayAuthorizationStatusReversed}, nil).Once()
+	resp := svc.Handle(ctx, reversal)
+	assert.Equal(t, iso8583.MTIReversalResponse, resp.MTI)
+	assert.Equal(t, iso8583.ResponseApproved, resp.Get(iso8583.FieldResponseCode))
+
+	mockRepo.On("ReverseAuthorization", ctx, "TERM0001", "123456789012").Return(nil, nil).Once()
+	resp = svc.Handle(ctx, reversal)
+	assert.Equal(t, iso8583.ResponseRecordNotFound, resp.Get(iso8583.FieldResponseCode))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGatewayServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockRepo := new(MockGatewayRepository)
+	mockAuthorizer := new(MockAuthorizer)
+	svc := gateway.NewService(mockRepo, mockAuthorizer, newTestVault(t), testGatewayConfig)
+
+	mockRepo.On("GetAuthorization", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	mockRepo.On("RecordDecline", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockAuthorizer.On("Authorize", mock.Anything, mock.Anything).
+		Return(&authorization.Decision{Decline: &authorization.Decline{Reason: authorization.ReasonInsufficientFunds}}, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gateway.NewServer(svc, testGatewayConfig).Serve(ctx, listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Two messages on one connection are answered in order.
+	for _, stan := range []string{"000001", "000002"} {
+		msg := newAuthorizationMessage(iso8583.MTIAuthorizationRequest)
+		msg.Set(iso8583.FieldSTAN, stan)
+		require.NoError(t, iso8583.WriteMessage(conn, msg))
+
+		frame, err := iso8583.ReadFrame(conn)
+		require.NoError(t, err)
+		resp, err := iso8583.Unpack(frame)
+		require.NoError(t, err)
+
+		assert.Equal(t, iso8583.MTIAuthorizationResponse, resp.MTI)
+		assert.Equal(t, stan, resp.Get(iso8583.FieldSTAN))
+		assert.Equal(t, iso8583.ResponseInsufficientFunds, resp.Get(iso8583.FieldResponseCode))
+		assert.Equal(t, "TERM0001", resp.Get(iso8583.FieldTerminalID))
+	}
+
+	// A message with a field the gateway does not know gets a format error.
+	_, err = conn.Write([]byte{0, 12, '0', '1', '0', '0', 0, 0, 0, 0, 0, 0, 0, 0x01})
+	require.NoError(t, err)
+	frame, err := iso8583.ReadFrame(conn)
+	require.NoError(t, err)
+	resp, err := iso8583.Unpack(frame)
+	require.NoError(t, err)
+	assert.Equal(t, iso8583.MTIAuthorizationResponse, resp.MTI)
+	assert.Equal(t, iso8583.ResponseFormatError, resp.Get(iso8583.FieldResponseCode))
+}
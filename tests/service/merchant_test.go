@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/merchant"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type MockMerchantRepository struct {
+	mock.Mock
+}
+
+func (m *MockMerchantRepository) GetCategoryCodes(ctx context.Context, category string) ([]*models.MerchantCategoryCode, error) {
+	args := m.Called(ctx, category)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.MerchantCategoryCode), args.Error(1)
+}
+
+func (m *MockMerchantRepository) GetCategoryCode(ctx context.Context, mcc string) (*models.MerchantCategoryCode, error) {
+	args := m.Called(ctx, mcc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MerchantCategoryCode), args.Error(1)
+}
+
+func (m *MockMerchantRepository) CreateMerchant(ctx context.Context, merchant *models.Merchant) error {
+	args := m.Called(ctx, merchant)
+	return args.Error(0)
+}
+
+func (m *MockMerchantRepository) GetMerchantByID(ctx context.Context, id uuid.UUID) (*models.Merchant, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Merchant), args.Error(1)
+}
+
+func (m *MockMerchantRepository) GetMerchants(ctx context.Context) ([]*models.Merchant, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Merchant), args.Error(1)
+}
+
+func TestCreateMerchant(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockMerchantRepository)
+		svc := merchant.NewService(mockRepo)
+
+		mockRepo.On("GetCategoryCode", ctx, "5812").Return(&models.MerchantCategoryCode{
+			MCC:         "5812",
+			Description: "Eating places and restaurants",
+			Category:    "food",
+		}, nil).Once()
+		mockRepo.On("CreateMerchant", ctx, mock.AnythingOfType("*models.Merchant")).Return(nil).Once()
+
+		created, err := svc.CreateMerchant(ctx, &request.CreateMerchant{Name: " Sushi Bar ", MCC: "5812"})
+		require.NoError(t, err)
+		assert.Equal(t, "Sushi Bar", created.Name)
+		assert.Equal(t, "5812", created.MCC)
+		assert.Equal(t, "food", created.Category)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown_mcc", func(t *testing.T) {
+		mockRepo := new(MockMerchantRepository)
+		svc := merchant.NewService(mockRepo)
+
+		mockRepo.On("GetCategoryCode", ctx, "0001").Return(nil, nil).Once()
+
+		created, err := svc.CreateMerchant(ctx, &request.CreateMerchant{Name: "Nowhere", MCC: "0001"})
+		assert.Equal(t, errors.ErrUnknownMCC, err)
+		assert.Nil(t, created)
+		mockRepo.AssertNotCalled(t, "CreateMerchant", mock.Anything, mock.Anything)
+	})
+}
+
+func TestListCategoryCodes(t *testing.T) {
+	mockRepo := new(MockMerchantRepository)
+	svc := merchant.NewService(mockRepo)
+	ctx := context.Background()
+
+	codes := []*models.MerchantCategoryCode{{MCC: "7995", Description: "Betting", Category: "gambling"}}
+	mockRepo.On("GetCategoryCodes", ctx, "gambling").Return(codes, nil).Once()
+
+	result, err := svc.ListCategoryCodes(ctx, " Gambling ")
+	require.NoError(t, err)
+	assert.Equal(t, codes, result)
+	mockRepo.AssertExpectations(t)
+}
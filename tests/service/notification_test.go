@@ -0,0 +1,667 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/notification"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type MockNotificationRepository struct {
+	mock.Mock
+}
+
+func (m *MockNotificationRepository) GetPreferences(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationPreference, error) {
+	args := m.Called(ctx, companyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.NotificationPreference), args.Error(1)
+}
+
+func (m *MockNotificationRepository) UpsertPreference(ctx context.Context, preference *models.NotificationPreference) (*models.NotificationPreference, error) {
+	args := m.Called(ctx, preference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationPreference), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetTemplates(ctx context.Context, companyID uuid.UUID) ([]*models.NotificationTemplate, error) {
+	args := m.Called(ctx, companyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.NotificationTemplate), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (*models.NotificationTemplate, error) {
+	args := m.Called(ctx, companyID, trigger)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationTemplate), args.Error(1)
+}
+
+func (m *MockNotificationRepository) UpsertTemplate(ctx context.Context, template *models.NotificationTemplate) (*models.NotificationTemplate, error) {
+	args := m.Called(ctx, template)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationTemplate), args.Error(1)
+}
+
+func (m *MockNotificationRepository) DeleteTemplate(ctx context.Context, companyID uuid.UUID, trigger string) (bool, error) {
+	args := m.Called(ctx, companyID, trigger)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetCard(ctx context.Context, companyID, cardID uuid.UUID) (*models.Card, error) {
+	args := m.Called(ctx, companyID, cardID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Card), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetCardSpending(ctx context.Context, cardID uuid.UUID, from, to time.Time) (float64, error) {
+	args := m.Called(ctx, cardID, from, to)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockNotificationRepository) MarkExpiringCards(ctx context.Context, before time.Time, limit int) (int, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) CreateNotification(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	args := m.Called(ctx, notification)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) GetSentChannels(ctx context.Context, notificationID uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, notificationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockNotificationRepository) MarkSent(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string) error {
+	args := m.Called(ctx, notificationID, channel, recipients)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) MarkFailed(ctx context.Context, notificationID uuid.UUID, channel string, recipients []string, reason string) error {
+	args := m.Called(ctx, notificationID, channel, recipients, reason)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) AddToInbox(ctx context.Context, notification *models.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
+
+func (m *MockNotificationRepository) GetInbox(ctx context.Context, companyID uuid.UUID, unreadOnly bool, limit, offset int) ([]*models.InboxNotification, error) {
+	args := m.Called(ctx, companyID, unreadOnly, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.InboxNotification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) CountUnread(ctx context.Context, companyID uuid.UUID) (int, error) {
+	args := m.Called(ctx, companyID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationRepository) MarkRead(ctx context.Context, companyID, inboxID uuid.UUID) (*models.InboxNotification, error) {
+	args := m.Called(ctx, companyID, inboxID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.InboxNotification), args.Error(1)
+}
+
+func (m *MockNotificationRepository) MarkAllRead(ctx context.Context, companyID uuid.UUID) (int, error) {
+	args := m.Called(ctx, companyID)
+	return args.Int(0), args.Error(1)
+}
+
+// fakeChannel records what it was asked to send and fails with err.
+type fakeChannel struct {
+	name       string
+	err        error
+	sent       []*models.Notification
+	recipients [][]string
+}
+
+func (f *fakeChannel) Name() string {
+	return f.name
+}
+
+func (f *fakeChannel) Send(ctx context.Context, notification *models.Notification, recipients []string) error {
+	f.sent = append(f.sent, notification)
+	f.recipients = append(f.recipients, recipients)
+	return f.err
+}
+
+var testNotificationConfig = config.NotificationConfig{
+	LowBalanceThreshold: 100,
+	LimitWarningRatio:   0.8,
+	ExpiryWarningDays:   30,
+}
+
+func notificationCard(companyID uuid.UUID, balance float64) *models.Card {
+	return &models.Card{
+		ID:             uuid.New(),
+		CompanyID:      companyID,
+		CardHolderName: "Jane Doe",
+		EmployeeEmail:  "jane@example.com",
+		Status:         models.CardStatusActive,
+		Balance:        balance,
+		LastFour:       "4242",
+		ExpiryDate:     time.Now().AddDate(1, 0, 0),
+	}
+}
+
+func paymentEvent(t *testing.T, card *models.Card, amount float64) *models.Event {
+	data, err := json.Marshal(models.Transaction{
+		ID:              uuid.New(),
+		CardID:          card.ID,
+		CompanyID:       card.CompanyID,
+		TransactionType: models.TransactionTypePurchase,
+		Amount:          amount,
+		Status:          models.TransactionStatusCompleted,
+		CreatedAt:       time.Now(),
+	})
+	require.NoError(t, err)
+
+	return &models.Event{
+		ID:        uuid.New(),
+		CompanyID: card.CompanyID,
+		Type:      models.EventPaymentCompleted,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+}
+
+// expectNotification makes CreateNotification store the notification it is
+// given and returns where it is stored.
+func expectNotification(mockRepo *MockNotificationRepository, sent ...string) *models.Notification {
+	stored := &models.Notification{}
+	mockRepo.On("CreateNotification", mock.Anything, mock.AnythingOfType("*models.Notification")).
+		Run(func(args mock.Arguments) {
+			*stored = *args.Get(1).(*models.Notification)
+		}).
+		Return(stored, nil)
+	mockRepo.On("GetSentChannels", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(sent, nil)
+	return stored
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("defaults_enable_only_the_inbox", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		custom := &models.NotificationPreference{
+			CompanyID:  companyID,
+			Trigger:    models.NotificationTriggerCardBlocked,
+			Channel:    models.NotificationChannelEmail,
+			IsEnabled:  true,
+			Recipients: []string{"finance@example.com"},
+		}
+		mockRepo.On("GetPreferences", ctx, companyID).Return([]*models.NotificationPreference{custom}, nil)
+
+		preferences, err := svc.GetPreferences(ctx, companyID)
+		require.NoError(t, err)
+		require.Len(t, preferences, len(models.NotificationTriggers)*len(models.NotificationChannels))
+
+		for _, preference := range preferences {
+			switch {
+			case preference.Trigger == custom.Trigger && preference.Channel == custom.Channel:
+				assert.Same(t, custom, preference)
+			case preference.Channel == models.NotificationChannelInApp:
+				assert.True(t, preference.IsEnabled, preference.Trigger)
+			default:
+				assert.False(t, preference.IsEnabled, preference.Trigger+"/"+preference.Channel)
+			}
+		}
+	})
+
+	t.Run("recipients_are_validated_per_channel", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		_, err := svc.UpdatePreference(ctx, companyID, &request.NotificationPreference{
+			Trigger:    models.NotificationTriggerLowBalance,
+			Channel:    models.NotificationChannelEmail,
+			Recipients: []string{"Finance <finance@example.com>"},
+		})
+		assert.ErrorIs(t, err, errors.ErrBadRequest)
+
+		_, err = svc.UpdatePreference(ctx, companyID, &request.NotificationPreference{
+			Trigger:    models.NotificationTriggerLowBalance,
+			Channel:    models.NotificationChannelWebhook,
+			Recipients: []string{"ftp://example.com/hook"},
+		})
+		assert.ErrorIs(t, err, errors.ErrBadRequest)
+
+		for _, recipient := range []string{"http://93.184.216.34/hook", "https://169.254.169.254/latest", "https://localhost/hook"} {
+			_, err = svc.UpdatePreference(ctx, companyID, &request.NotificationPreference{
+				Trigger:    models.NotificationTriggerLowBalance,
+				Channel:    models.NotificationChannelWebhook,
+				Recipients: []string{recipient},
+			})
+			assert.ErrorIs(t, err, errors.ErrBadRequest, recipient)
+		}
+		mockRepo.AssertNotCalled(t, "UpsertPreference", mock.Anything, mock.Anything)
+	})
+
+	t.Run("webhook_channel_decides_recipients", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig, notification.NewWebhookChannel(time.Second, true))
+
+		mockRepo.On("UpsertPreference", ctx, mock.AnythingOfType("*models.NotificationPreference")).Return(&models.NotificationPreference{}, nil).Once()
+
+		_, err := svc.UpdatePreference(ctx, companyID, &request.NotificationPreference{
+			Trigger:    models.NotificationTriggerLowBalance,
+			Channel:    models.NotificationChannelWebhook,
+			Recipients: []string{"http://127.0.0.1:8080/hook"},
+		})
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("inbox_has_no_recipients", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		disabled := false
+		mockRepo.On("UpsertPreference", ctx, mock.MatchedBy(func(p *models.NotificationPreference) bool {
+			return p.CompanyID == companyID && p.Channel == models.NotificationChannelInApp &&
+				!p.IsEnabled && len(p.Recipients) == 0 && !p.NotifyCardholder
+		})).Return(&models.NotificationPreference{}, nil)
+
+		_, err := svc.UpdatePreference(ctx, companyID, &request.NotificationPreference{
+			Trigger:          models.NotificationTriggerLowBalance,
+			Channel:          models.NotificationChannelInApp,
+			Recipients:       []string{"finance@example.com"},
+			NotifyCardholder: true,
+			IsEnabled:        &disabled,
+		})
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestNotificationTemplates(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("custom_templates_replace_defaults", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		custom := &models.NotificationTemplate{
+			CompanyID: companyID,
+			Trigger:   models.NotificationTriggerCardBlocked,
+			Subject:   "Blocked: {{.LastFour}}",
+			Body:      "{{.Reason}}",
+			IsCustom:  true,
+		}
+		mockRepo.On("GetTemplates", ctx, companyID).Return([]*models.NotificationTemplate{custom}, nil)
+
+		templates, err := svc.GetTemplates(ctx, companyID)
+		require.NoError(t, err)
+		require.Len(t, templates, len(models.NotificationTriggers))
+		for _, template := range templates {
+			assert.Equal(t, template.Trigger == custom.Trigger, template.IsCustom, template.Trigger)
+			assert.NotEmpty(t, template.Subject)
+		}
+	})
+
+	t.Run("broken_template_is_rejected", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		_, err := svc.UpdateTemplate(ctx, companyID, models.NotificationTriggerLowBalance, &request.NotificationTemplate{
+			Subject: "Low balance {{.LastFour",
+			Body:    "body",
+		})
+		assert.ErrorIs(t, err, errors.ErrInvalidTemplate)
+
+		_, err = svc.UpdateTemplate(ctx, companyID, models.NotificationTriggerLowBalance, &request.NotificationTemplate{
+			Subject: "Low balance",
+			Body:    "{{.NoSuchField}}",
+		})
+		assert.ErrorIs(t, err, errors.ErrInvalidTemplate)
+		mockRepo.AssertNotCalled(t, "UpsertTemplate", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown_trigger", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		_, err := svc.UpdateTemplate(ctx, companyID, "unknown", &request.NotificationTemplate{Subject: "s", Body: "b"})
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+		_, err = svc.ResetTemplate(ctx, companyID, "unknown")
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+	})
+}
+
+func TestNotificationTriggers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("low_balance_notifies_once_crossed", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		email := &fakeChannel{name: models.NotificationChannelEmail}
+		inbox := &fakeChannel{name: models.NotificationChannelInApp}
+		svc := notification.NewService(mockRepo, testNotificationConfig, email, inbox)
+
+		card := notificationCard(uuid.New(), 60)
+		mockRepo.On("GetCard", ctx, card.CompanyID, card.ID).Return(card, nil)
+		mockRepo.On("GetPreferences", ctx, card.CompanyID).Return([]*models.NotificationPreference{{
+			CompanyID:        card.CompanyID,
+			Trigger:          models.NotificationTriggerLowBalance,
+			Channel:          models.NotificationChannelEmail,
+			IsEnabled:        true,
+			Recipients:       []string{"finance@example.com"},
+			NotifyCardholder: true,
+		}}, nil)
+		mockRepo.On("GetTemplate", ctx, card.CompanyID, models.NotificationTriggerLowBalance).Return(nil, nil)
+		stored := expectNotification(mockRepo)
+		mockRepo.On("MarkSent", ctx, mock.AnythingOfType("uuid.UUID"), mock.Anything, mock.Anything).Return(nil)
+
+		// 110 before the payment, 60 after: the threshold of 100 is crossed.
+		require.NoError(t, svc.HandleEvent(ctx, paymentEvent(t, card, 50)))
+
+		assert.Equal(t, models.NotificationTriggerLowBalance, stored.Trigger)
+		assert.Equal(t, "Low balance on card ending 4242", stored.Subject)
+		assert.Contains(t, stored.Body, "fell to 60.00 after a payment of 50.00")
+		require.Len(t, email.sent, 1)
+		assert.Equal(t, []string{"finance@example.com", "jane@example.com"}, email.recipients[0])
+		require.Len(t, inbox.sent, 1)
+		mockRepo.AssertCalled(t, "MarkSent", ctx, stored.ID, models.NotificationChannelEmail, []string{"finance@example.com", "jane@example.com"})
+		mockRepo.AssertCalled(t, "MarkSent", ctx, stored.ID, models.NotificationChannelInApp, []string{})
+
+		// A card already below the threshold does not notify again.
+		belowRepo := new(MockNotificationRepository)
+		svc = notification.NewService(belowRepo, testNotificationConfig, email, inbox)
+		below := notificationCard(card.CompanyID, 40)
+		belowRepo.On("GetCard", ctx, below.CompanyID, below.ID).Return(below, nil)
+		require.NoError(t, svc.HandleEvent(ctx, paymentEvent(t, below, 10)))
+		belowRepo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+	})
+
+	t.Run("daily_limit_nearly_reached", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		inbox := &fakeChannel{name: models.NotificationChannelInApp}
+		svc := notification.NewService(mockRepo, testNotificationConfig, inbox)
+
+		card := notificationCard(uuid.New(), 500)
+		dailyLimit := 1000.0
+		card.DailyLimit = &dailyLimit
+		mockRepo.On("GetCard", ctx, card.CompanyID, card.ID).Return(card, nil)
+		// 750 before the payment, 850 after: 80% of the limit is crossed.
+		mockRepo.On("GetCardSpending", ctx, card.ID, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(850.0, nil)
+		mockRepo.On("GetPreferences", ctx, card.CompanyID).Return([]*models.NotificationPreference{}, nil)
+		mockRepo.On("GetTemplate", ctx, card.CompanyID, models.NotificationTriggerLimitNearlyReached).Return(nil, nil)
+		stored := expectNotification(mockRepo)
+		mockRepo.On("MarkSent", ctx, mock.AnythingOfType("uuid.UUID"), models.NotificationChannelInApp, []string{}).Return(nil)
+
+		event := paymentEvent(t, card, 100)
+		require.NoError(t, svc.HandleEvent(ctx, event))
+
+		assert.Equal(t, event.ID.String()+":daily", stored.DedupeKey)
+		assert.Equal(t, "Card ending 4242 is close to its daily limit", stored.Subject)
+		assert.Equal(t, "Jane Doe's card ending 4242 has spent 850.00 of its 1000.00 daily limit.", stored.Body)
+		assert.Len(t, inbox.sent, 1)
+		// The card has no monthly limit.
+		mockRepo.AssertNumberOfCalls(t, "GetCardSpending", 1)
+	})
+
+	t.Run("failed_channel_is_retried_alone", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		inbox := &fakeChannel{name: models.NotificationChannelInApp}
+		hook := &fakeChannel{name: models.NotificationChannelWebhook, err: assert.AnError}
+		svc := notification.NewService(mockRepo, testNotificationConfig, inbox, hook)
+
+		card := notificationCard(uuid.New(), 500)
+		blockedData, err := json.Marshal(models.CardStatusChangedEvent{
+			CardID: card.ID,
+			Status: models.CardStatusBlocked,
+			Reason: "too many declined transactions",
+		})
+		require.NoError(t, err)
+		event := &models.Event{ID: uuid.New(), CompanyID: card.CompanyID, Type: models.EventCardBlocked, Data: blockedData}
+
+		mockRepo.On("GetCard", ctx, card.CompanyID, card.ID).Return(card, nil)
+		mockRepo.On("GetPreferences", ctx, card.CompanyID).Return([]*models.NotificationPreference{{
+			CompanyID:  card.CompanyID,
+			Trigger:    models.NotificationTriggerCardBlocked,
+			Channel:    models.NotificationChannelWebhook,
+			IsEnabled:  true,
+			Recipients: []string{"https://ops.example.com/alerts"},
+		}}, nil)
+		mockRepo.On("GetTemplate", ctx, card.CompanyID, models.NotificationTriggerCardBlocked).Return(nil, nil)
+		// The inbox already has the notification from an earlier attempt.
+		stored := expectNotification(mockRepo, models.NotificationChannelInApp)
+		mockRepo.On("MarkFailed", ctx, mock.AnythingOfType("uuid.UUID"), models.NotificationChannelWebhook,
+			[]string{"https://ops.example.com/alerts"}, assert.AnError.Error()).Return(nil)
+
+		err = svc.HandleEvent(ctx, event)
+		assert.ErrorIs(t, err, assert.AnError)
+
+		assert.Empty(t, inbox.sent)
+		require.Len(t, hook.sent, 1)
+		assert.Equal(t, "Jane Doe's card ending 4242 was blocked: too many declined transactions.", stored.Body)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("expiring_cards_are_marked_in_batches", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		inWarningPeriod := mock.MatchedBy(func(before time.Time) bool {
+			return time.Until(before) > 29*24*time.Hour && time.Until(before) <= 30*24*time.Hour
+		})
+		mockRepo.On("MarkExpiringCards", ctx, inWarningPeriod, 500).Return(500, nil).Once()
+		mockRepo.On("MarkExpiringCards", ctx, inWarningPeriod, 500).Return(12, nil).Once()
+
+		found, err := svc.CheckExpiringCards(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 512, found)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestNotificationInbox(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("page_size_is_bounded", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		mockRepo.On("GetInbox", ctx, companyID, true, 100, 0).Return([]*models.InboxNotification{}, nil)
+		mockRepo.On("GetInbox", ctx, companyID, false, 20, 0).Return([]*models.InboxNotification{}, nil)
+
+		_, err := svc.ListInbox(ctx, companyID, true, 500, -20)
+		require.NoError(t, err)
+		_, err = svc.ListInbox(ctx, companyID, false, 0, 0)
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("mark_read", func(t *testing.T) {
+		mockRepo := new(MockNotificationRepository)
+		svc := notification.NewService(mockRepo, testNotificationConfig)
+
+		readAt := time.Now()
+		read := &models.InboxNotification{ID: uuid.New(), ReadAt: &readAt}
+		mockRepo.On("MarkRead", ctx, companyID, read.ID).Return(read, nil)
+		mockRepo.On("MarkRead", ctx, companyID, mock.AnythingOfType("uuid.UUID")).Return(nil, nil)
+
+		inbox, err := svc.MarkRead(ctx, companyID, read.ID)
+		require.NoError(t, err)
+		assert.Equal(t, read, inbox)
+
+		_, err = svc.MarkRead(ctx, companyID, uuid.New())
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+	})
+}
+
+func TestWebhookChannel(t *testing.T) {
+	ctx := context.Background()
+
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notice := &models.Notification{ID: uuid.New(), Trigger: models.NotificationTriggerCardBlocked, CreatedAt: time.Now()}
+	loopback := strings.Replace(server.URL, "http://", "https://", 1)
+
+	t.Run("refuses_internal_addresses", func(t *testing.T) {
+		channel := notification.NewWebhookChannel(time.Second, false)
+
+		err := channel.Send(ctx, notice, []string{loopback})
+		assert.ErrorIs(t, err, errors.ErrWebhookURL)
+		assert.ErrorIs(t, channel.(notification.RecipientChecker).CheckRecipient(ctx, loopback), errors.ErrWebhookURL)
+		assert.False(t, called)
+	})
+
+	t.Run("private_targets_allowed", func(t *testing.T) {
+		channel := notification.NewWebhookChannel(time.Second, true)
+
+		require.NoError(t, channel.Send(ctx, notice, []string{server.URL}))
+		assert.True(t, called)
+	})
+}
+
+func TestEmailChannel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// A minimal SMTP sink accepting one message.
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 sink ready")
+
+		var lines []string
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			if inData {
+				if line == "." {
+					inData = false
+					reply("250 queued")
+					continue
+				}
+				lines = append(lines, line)
+				continue
+			}
+
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				reply("250 sink")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				reply("250 ok")
+			case "DATA":
+				inData = true
+				reply("354 send data")
+			case "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	channel := notification.NewEmailChannel(config.SMTPConfig{
+		Host: host,
+		Port: portNumber,
+		From: "notifications@ccards.local",
+	})
+	assert.Equal(t, models.NotificationChannelEmail, channel.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = channel.Send(ctx, &models.Notification{
+		ID:        uuid.New(),
+		CompanyID: uuid.New(),
+		Trigger:   models.NotificationTriggerCardBlocked,
+		// A line break in the subject must not start a new header.
+		Subject:   "Card ending 4242 was blocked\r\nBcc: attacker@example.com",
+		Body:      "First line\nSecond line",
+		CreatedAt: time.Now(),
+	}, []string{"finance@example.com", "jane@example.com"})
+	require.NoError(t, err)
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	assert.Contains(t, lines, "MAIL FROM:<notifications@ccards.local>")
+	assert.Contains(t, lines, "RCPT TO:<finance@example.com>")
+	assert.Contains(t, lines, "RCPT TO:<jane@example.com>")
+	assert.Contains(t, lines, "To: finance@example.com, jane@example.com")
+	assert.Contains(t, lines, "Subject: Card ending 4242 was blocked  Bcc: attacker@example.com")
+	assert.Contains(t, lines, "Second line")
+	for _, line := range lines {
+		assert.False(t, strings.HasPrefix(line, "Bcc:"), line)
+	}
+}
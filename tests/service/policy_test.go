@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/policy"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type MockPolicyRepository struct {
+	mock.Mock
+}
+
+func (m *MockPolicyRepository) CreateTemplate(ctx context.Context, template *models.CardPolicyTemplate) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *MockPolicyRepository) GetTemplateByID(ctx context.Context, companyID, id uuid.UUID) (*models.CardPolicyTemplate, error) {
+	args := m.Called(ctx, companyID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.CardPolicyTemplate), args.Error(1)
+}
+
+func (m *MockPolicyRepository) GetTemplatesByCompanyID(ctx context.Context, companyID uuid.UUID) ([]*models.CardPolicyTemplate, error) {
+	args := m.Called(ctx, companyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.CardPolicyTemplate), args.Error(1)
+}
+
+func (m *MockPolicyRepository) UpdateTemplate(ctx context.Context, template *models.CardPolicyTemplate) error {
+	args := m.Called(ctx, template)
+	return args.Error(0)
+}
+
+func (m *MockPolicyRepository) DeleteTemplate(ctx context.Context, companyID, id uuid.UUID) error {
+	args := m.Called(ctx, companyID, id)
+	return args.Error(0)
+}
+
+func (m *MockPolicyRepository) ReapplyTemplate(ctx context.Context, template *models.CardPolicyTemplate) (int, error) {
+	args := m.Called(ctx, template)
+	return args.Int(0), args.Error(1)
+}
+
+func TestCreatePolicyTemplate(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	dailyLimit := 100.0
+	monthlyLimit := 1000.0
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		req := &request.PolicyTemplate{
+			Name:         "Office",
+			DailyLimit:   &dailyLimit,
+			MonthlyLimit: &monthlyLimit,
+			Controls: []models.IssuanceControl{
+				{ControlType: "time_based", Value: json.RawMessage(`{"start_time":"09:00","end_time":"18:00"}`)},
+			},
+			IsDefault: true,
+		}
+		mockRepo.On("CreateTemplate", ctx, mock.AnythingOfType("*models.CardPolicyTemplate")).Return(nil).Once()
+
+		template, err := svc.CreateTemplate(ctx, companyID, req)
+		require.NoError(t, err)
+		assert.Equal(t, companyID, template.CompanyID)
+		assert.True(t, template.IsDefault)
+		assert.Len(t, template.Controls, 1)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid_limits", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		req := &request.PolicyTemplate{Name: "Broken", DailyLimit: &monthlyLimit, MonthlyLimit: &dailyLimit}
+
+		template, err := svc.CreateTemplate(ctx, companyID, req)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPolicy))
+		assert.Nil(t, template)
+		mockRepo.AssertNotCalled(t, "CreateTemplate", mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid_control", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		req := &request.PolicyTemplate{
+			Name:     "Broken",
+			Controls: []models.IssuanceControl{{ControlType: "unknown", Value: json.RawMessage(`{}`)}},
+		}
+
+		_, err := svc.CreateTemplate(ctx, companyID, req)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPolicy))
+	})
+
+	t.Run("channel_control", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		mockRepo.On("CreateTemplate", ctx, mock.AnythingOfType("*models.CardPolicyTemplate")).Return(nil).Once()
+
+		req := &request.PolicyTemplate{
+			Name: "No cash",
+			Controls: []models.IssuanceControl{
+				{ControlType: "channel", Value: json.RawMessage(`{"blocked_channels":["atm"],"blocked_categories":{"online":["gambling"]}}`)},
+			},
+		}
+
+		_, err := svc.CreateTemplate(ctx, companyID, req)
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid_mcc_range", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		req := &request.PolicyTemplate{
+			Name: "Broken MCCs",
+			Controls: []models.IssuanceControl{
+				{ControlType: "merchant_category", Value: json.RawMessage(`{"blocked_mccs":["7995-7800"]}`)},
+			},
+		}
+
+		_, err := svc.CreateTemplate(ctx, companyID, req)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPolicy))
+	})
+
+	t.Run("invalid_category_limit", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		for _, value := range []string{
+			`{"limits":[]}`,
+			`{"limits":[{"category":"travel","amount":2000,"period":"yearly"}]}`,
+			`{"limits":[{"category":"meals","amount":0,"period":"daily"}]}`,
+			`{"limits":[{"amount":50,"period":"daily"}]}`,
+		} {
+			req := &request.PolicyTemplate{
+				Name:     "Broken limits",
+				Controls: []models.IssuanceControl{{ControlType: "category_limit", Value: json.RawMessage(value)}},
+			}
+
+			_, err := svc.CreateTemplate(ctx, companyID, req)
+			assert.True(t, errors.Is(err, errors.ErrInvalidPolicy), value)
+		}
+	})
+
+	t.Run("invalid_velocity", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		for _, value := range []string{
+			`{}`,
+			`{"max_transactions":5}`,
+			`{"max_transactions":5,"transaction_window":"2d"}`,
+			`{"max_declines":3,"decline_window":"48h"}`,
+			`{"max_transactions":-1,"max_declines":3,"decline_window":"10m"}`,
+		} {
+			req := &request.PolicyTemplate{
+				Name:     "Broken velocity",
+				Controls: []models.IssuanceControl{{ControlType: "velocity", Value: json.RawMessage(value)}},
+			}
+
+			_, err := svc.CreateTemplate(ctx, companyID, req)
+			assert.True(t, errors.Is(err, errors.ErrInvalidPolicy), value)
+		}
+	})
+
+	t.Run("unknown_channel", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		req := &request.PolicyTemplate{
+			Name: "Broken channels",
+			Controls: []models.IssuanceControl{
+				{ControlType: "channel", Value: json.RawMessage(`{"blocked_channels":["telepathy"]}`)},
+			},
+		}
+
+		_, err := svc.CreateTemplate(ctx, companyID, req)
+		assert.True(t, errors.Is(err, errors.ErrInvalidPolicy))
+	})
+}
+
+func TestReapplyPolicyTemplate(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	templateID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		template := &models.CardPolicyTemplate{ID: templateID, CompanyID: companyID, Name: "Office"}
+		mockRepo.On("GetTemplateByID", ctx, companyID, templateID).Return(template, nil).Once()
+		mockRepo.On("ReapplyTemplate", ctx, template).Return(12, nil).Once()
+
+		updated, err := svc.ReapplyTemplate(ctx, companyID, templateID)
+		require.NoError(t, err)
+		assert.Equal(t, 12, updated)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("template_not_found", func(t *testing.T) {
+		mockRepo := new(MockPolicyRepository)
+		svc := policy.NewService(mockRepo)
+
+		mockRepo.On("GetTemplateByID", ctx, companyID, templateID).Return(nil, nil).Once()
+
+		_, err := svc.ReapplyTemplate(ctx, companyID, templateID)
+		assert.Equal(t, errors.ErrNotFound, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
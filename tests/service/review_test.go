@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/review"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+)
+
+type MockReviewRepository struct {
+	mock.Mock
+}
+
+func (m *MockReviewRepository) GetReviews(ctx context.Context, companyID uuid.UUID, status string, limit, offset int) ([]*models.Transaction, error) {
+	args := m.Called(ctx, companyID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Transaction), args.Error(1)
+}
+
+func (m *MockReviewRepository) GetReview(ctx context.Context, companyID, transactionID uuid.UUID) (*models.Transaction, error) {
+	args := m.Called(ctx, companyID, transactionID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+func (m *MockReviewRepository) ResolveReview(ctx context.Context, companyID, transactionID uuid.UUID, status string, blockReason *string) (*models.Transaction, error) {
+	args := m.Called(ctx, companyID, transactionID, status, blockReason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Transaction), args.Error(1)
+}
+
+func flaggedTransaction(companyID uuid.UUID, reviewStatus string) *models.Transaction {
+	return &models.Transaction{
+		ID:           uuid.New(),
+		CompanyID:    companyID,
+		CardID:       uuid.New(),
+		ReviewStatus: &reviewStatus,
+	}
+}
+
+func TestListReviews(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	mockRepo := new(MockReviewRepository)
+	svc := review.NewService(mockRepo)
+
+	mockRepo.On("GetReviews", ctx, companyID, models.ReviewStatusPending, 100, 0).Return([]*models.Transaction{}, nil)
+
+	_, err := svc.ListReviews(ctx, companyID, "", 500, -10)
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResolveReview(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("approve", func(t *testing.T) {
+		mockRepo := new(MockReviewRepository)
+		svc := review.NewService(mockRepo)
+
+		txn := flaggedTransaction(companyID, models.ReviewStatusPending)
+		approved := flaggedTransaction(companyID, models.ReviewStatusApproved)
+		mockRepo.On("GetReview", ctx, companyID, txn.ID).Return(txn, nil)
+		mockRepo.On("ResolveReview", ctx, companyID, txn.ID, models.ReviewStatusApproved, (*string)(nil)).Return(approved, nil)
+
+		result, err := svc.ApproveReview(ctx, companyID, txn.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.ReviewStatusApproved, *result.ReviewStatus)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("reject_blocks_card", func(t *testing.T) {
+		mockRepo := new(MockReviewRepository)
+		svc := review.NewService(mockRepo)
+
+		txn := flaggedTransaction(companyID, models.ReviewStatusPending)
+		mockRepo.On("GetReview", ctx, companyID, txn.ID).Return(txn, nil)
+		mockRepo.On("ResolveReview", ctx, companyID, txn.ID, models.ReviewStatusRejected, mock.MatchedBy(func(reason *string) bool {
+			return reason != nil && *reason == "fraud confirmed by review"
+		})).Return(flaggedTransaction(companyID, models.ReviewStatusRejected), nil)
+
+		_, err := svc.RejectReview(ctx, companyID, txn.ID)
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("already_resolved", func(t *testing.T) {
+		mockRepo := new(MockReviewRepository)
+		svc := review.NewService(mockRepo)
+
+		txn := flaggedTransaction(companyID, models.ReviewStatusApproved)
+		mockRepo.On("GetReview", ctx, companyID, txn.ID).Return(txn, nil)
+
+		_, err := svc.RejectReview(ctx, companyID, txn.ID)
+		assert.True(t, errors.Is(err, errors.ErrReviewClosed))
+		mockRepo.AssertNotCalled(t, "ResolveReview", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		mockRepo := new(MockReviewRepository)
+		svc := review.NewService(mockRepo)
+
+		id := uuid.New()
+		mockRepo.On("GetReview", ctx, companyID, id).Return(nil, nil)
+
+		_, err := svc.ApproveReview(ctx, companyID, id)
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+	})
+}
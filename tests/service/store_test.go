@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/store"
+	"ccards/pkg/authorization"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/utils"
+)
+
+type MockStoreRepository struct {
+	mock.Mock
+}
+
+func (m *MockStoreRepository) CreateStore(ctx context.Context, s *models.Store, apiKey *models.StoreAPIKey) error {
+	args := m.Called(ctx, s, apiKey)
+	return args.Error(0)
+}
+
+func (m *MockStoreRepository) GetStore(ctx context.Context, storeID uuid.UUID) (*models.Store, error) {
+	args := m.Called(ctx, storeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Store), args.Error(1)
+}
+
+func (m *MockStoreRepository) CreateAPIKey(ctx context.Context, apiKey *models.StoreAPIKey, retireAt time.Time) error {
+	args := m.Called(ctx, apiKey, retireAt)
+	return args.Error(0)
+}
+
+func (m *MockStoreRepository) GetAPIKeys(ctx context.Context, storeID uuid.UUID) ([]*models.StoreAPIKey, error) {
+	args := m.Called(ctx, storeID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.StoreAPIKey), args.Error(1)
+}
+
+func (m *MockStoreRepository) RevokeAPIKey(ctx context.Context, storeID, id uuid.UUID) (*models.StoreAPIKey, error) {
+	args := m.Called(ctx, storeID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StoreAPIKey), args.Error(1)
+}
+
+func (m *MockStoreRepository) EncryptAPIKeySecrets(ctx context.Context, seal func(secret string) (string, error)) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStoreRepository) CreatePayment(ctx context.Context, payment *models.StorePayment, purchase *models.Transaction) error {
+	args := m.Called(ctx, payment, purchase)
+	return args.Error(0)
+}
+
+func (m *MockStoreRepository) GetPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	args := m.Called(ctx, storeID, paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StorePayment), args.Error(1)
+}
+
+func (m *MockStoreRepository) GetPayments(ctx context.Context, storeID uuid.UUID, status string, limit, offset int) ([]*models.StorePayment, error) {
+	args := m.Called(ctx, storeID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.StorePayment), args.Error(1)
+}
+
+func (m *MockStoreRepository) CapturePayment(ctx context.Context, storeID, paymentID uuid.UUID, amount *float64) (*models.StorePayment, error) {
+	args := m.Called(ctx, storeID, paymentID, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StorePayment), args.Error(1)
+}
+
+func (m *MockStoreRepository) VoidPayment(ctx context.Context, storeID, paymentID uuid.UUID) (*models.StorePayment, error) {
+	args := m.Called(ctx, storeID, paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StorePayment), args.Error(1)
+}
+
+func (m *MockStoreRepository) RefundPayment(ctx context.Context, storeID, paymentID uuid.UUID, amount *float64, reason *string) (*models.StoreRefund, error) {
+	args := m.Called(ctx, storeID, paymentID, amount, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.StoreRefund), args.Error(1)
+}
+
+func (m *MockStoreRepository) GetRefunds(ctx context.Context, storeID, paymentID uuid.UUID) ([]*models.StoreRefund, error) {
+	args := m.Called(ctx, storeID, paymentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.StoreRefund), args.Error(1)
+}
+
+var testStoreConfig = config.StoreConfig{SignatureTolerance: 5 * time.Minute, KeyRotationGrace: 24 * time.Hour}
+
+func TestRegisterStore(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockStoreRepository)
+	svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+	var stored string
+	mockRepo.On("CreateStore", ctx, mock.AnythingOfType("*models.Store"), mock.AnythingOfType("*models.StoreAPIKey")).
+		Run(func(args mock.Arguments) { stored = args.Get(2).(*models.StoreAPIKey).Secret }).
+		Return(nil)
+
+	result, apiKey, err := svc.Register(ctx, &request.RegisterStore{
+		Name:  " Corner Shop ",
+		Email: "Owner@Example.com",
+		MCC:   "5411",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Corner Shop", result.Name)
+	assert.Equal(t, "owner@example.com", result.Email)
+	assert.Equal(t, models.StoreStatusActive, result.Status)
+	assert.NotEqual(t, uuid.Nil, result.MerchantID)
+
+	assert.Equal(t, result.ID, apiKey.StoreID)
+	assert.True(t, strings.HasPrefix(apiKey.KeyID, "pk_"))
+	assert.True(t, strings.HasPrefix(apiKey.Secret, "sk_"))
+	mockRepo.AssertExpectations(t)
+
+	// Only the sealed secret is stored.
+	secret, err := newTestVault(t).OpenSecret(stored, utils.APIKeySecretPurpose)
+	require.NoError(t, err)
+	assert.Equal(t, apiKey.Secret, secret)
+}
+
+func TestStoreAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	storeID := uuid.New()
+
+	t.Run("rotation_retires_other_keys_after_grace", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		var retireAt time.Time
+		var stored string
+		mockRepo.On("CreateAPIKey", ctx, mock.AnythingOfType("*models.StoreAPIKey"), mock.AnythingOfType("time.Time")).
+			Run(func(args mock.Arguments) {
+				stored = args.Get(1).(*models.StoreAPIKey).Secret
+				retireAt = args.Get(2).(time.Time)
+			}).
+			Return(nil)
+
+		apiKey, err := svc.RotateAPIKey(ctx, storeID)
+		require.NoError(t, err)
+		assert.Equal(t, storeID, apiKey.StoreID)
+		assert.True(t, strings.HasPrefix(apiKey.Secret, "sk_"))
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), retireAt, time.Minute)
+
+		secret, err := newTestVault(t).OpenSecret(stored, utils.APIKeySecretPurpose)
+		require.NoError(t, err)
+		assert.Equal(t, apiKey.Secret, secret)
+	})
+
+	keys := []*models.StoreAPIKey{
+		{ID: uuid.New(), StoreID: storeID, KeyID: "pk_current"},
+		{ID: uuid.New(), StoreID: storeID, KeyID: "pk_old"},
+	}
+
+	t.Run("revoke", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		revokedAt := time.Now()
+		revoked := &models.StoreAPIKey{ID: keys[1].ID, KeyID: "pk_old", RevokedAt: &revokedAt}
+		mockRepo.On("GetAPIKeys", ctx, storeID).Return(keys, nil)
+		mockRepo.On("RevokeAPIKey", ctx, storeID, keys[1].ID).Return(revoked, nil)
+
+		result, err := svc.RevokeAPIKey(ctx, storeID, keys[1].ID, "pk_current")
+		require.NoError(t, err)
+		assert.NotNil(t, result.RevokedAt)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("revoke_signing_key", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		mockRepo.On("GetAPIKeys", ctx, storeID).Return(keys, nil)
+
+		_, err := svc.RevokeAPIKey(ctx, storeID, keys[0].ID, "pk_current")
+		assert.ErrorIs(t, err, errors.ErrAPIKeyInUse)
+		mockRepo.AssertNotCalled(t, "RevokeAPIKey", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("revoke_unknown_key", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		mockRepo.On("GetAPIKeys", ctx, storeID).Return(keys, nil)
+
+		_, err := svc.RevokeAPIKey(ctx, storeID, uuid.New(), "pk_current")
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+	})
+}
+
+func TestStoreAuthorize(t *testing.T) {
+	ctx := context.Background()
+	storeID, merchantID := uuid.New(), uuid.New()
+	card := &models.Card{ID: uuid.New(), CompanyID: uuid.New(), LastFour: "4242"}
+
+	t.Run("records_purchase_at_store_merchant", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		var purchase *models.Transaction
+		mockRepo.On("CreatePayment", ctx, mock.AnythingOfType("*models.StorePayment"), mock.AnythingOfType("*models.Transaction")).
+			Run(func(args mock.Arguments) { purchase = args.Get(2).(*models.Transaction) }).
+			Return(nil)
+
+		payment, err := svc.Authorize(ctx, storeID, &authorization.Request{
+			Card:             card,
+			CompanyID:        card.CompanyID,
+			CardID:           card.ID,
+			Amount:           42.5,
+			MerchantID:       &merchantID,
+			MerchantName:     "Corner Shop",
+			MCC:              "5411",
+			MerchantCategory: "groceries",
+			Channel:          models.TransactionChannelOnline,
+			Risk:             &models.RiskAssessment{Score: 70, Action: models.RiskActionReview},
+		}, "order-1")
+		require.NoError(t, err)
+
+		assert.Equal(t, models.StorePaymentStatusAuthorized, payment.Status)
+		assert.Equal(t, 42.5, payment.Amount)
+		assert.Equal(t, "4242", payment.CardLastFour)
+		assert.Equal(t, "order-1", *payment.Reference)
+		assert.Equal(t, purchase.ID, payment.TransactionID)
+
+		assert.Equal(t, models.TransactionTypePurchase, purchase.TransactionType)
+		assert.Equal(t, models.TransactionStatusCompleted, purchase.Status)
+		assert.Equal(t, merchantID, *purchase.MerchantID)
+		assert.Equal(t, "5411", *purchase.MCC)
+		assert.Equal(t, models.ReviewStatusPending, *purchase.ReviewStatus)
+		assert.WithinDuration(t, time.Now(), *purchase.ProcessedAt, time.Minute)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("duplicate_reference", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		mockRepo.On("CreatePayment", ctx, mock.Anything, mock.Anything).Return(errors.ErrPaymentExists)
+
+		_, err := svc.Authorize(ctx, storeID, &authorization.Request{Card: card, Amount: 10, MerchantID: &merchantID}, "order-1")
+		assert.ErrorIs(t, err, errors.ErrPaymentExists)
+	})
+}
+
+func TestStorePaymentActions(t *testing.T) {
+	ctx := context.Background()
+	storeID, paymentID := uuid.New(), uuid.New()
+
+	t.Run("capture_unknown_payment", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		amount := 5.0
+		mockRepo.On("CapturePayment", ctx, storeID, paymentID, &amount).Return(nil, nil)
+
+		_, err := svc.Capture(ctx, storeID, paymentID, &request.StoreCapture{Amount: &amount})
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+	})
+
+	t.Run("void_captured_payment", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		mockRepo.On("VoidPayment", ctx, storeID, paymentID).Return(nil, errors.ErrPaymentTransition)
+
+		_, err := svc.Void(ctx, storeID, paymentID)
+		assert.ErrorIs(t, err, errors.ErrPaymentTransition)
+	})
+
+	t.Run("refund_trims_reason", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		reason := "Returned item"
+		refund := &models.StoreRefund{ID: uuid.New(), PaymentID: paymentID, Amount: 10, Reason: &reason}
+		mockRepo.On("RefundPayment", ctx, storeID, paymentID, (*float64)(nil), &reason).Return(refund, nil)
+
+		result, err := svc.Refund(ctx, storeID, paymentID, &request.StoreRefund{Reason: "  Returned item "})
+		require.NoError(t, err)
+		assert.Equal(t, refund.ID, result.ID)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("refunds_of_other_store", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		mockRepo.On("GetPayment", ctx, storeID, paymentID).Return(nil, nil)
+
+		_, err := svc.ListRefunds(ctx, storeID, paymentID)
+		assert.ErrorIs(t, err, errors.ErrNotFound)
+		mockRepo.AssertNotCalled(t, "GetRefunds", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("list_clamps_page_size", func(t *testing.T) {
+		mockRepo := new(MockStoreRepository)
+		svc := store.NewService(mockRepo, newTestVault(t), testStoreConfig)
+
+		mockRepo.On("GetPayments", ctx, storeID, models.StorePaymentStatusCaptured, 100, 0).Return([]*models.StorePayment{}, nil)
+
+		_, err := svc.ListPayments(ctx, storeID, models.StorePaymentStatusCaptured, 500, -10)
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
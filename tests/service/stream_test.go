@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/stream"
+	"ccards/pkg/config"
+	"ccards/pkg/models"
+	"ccards/tests/setup"
+)
+
+type MockStreamRepository struct {
+	mock.Mock
+}
+
+func (m *MockStreamRepository) GetEventsSince(ctx context.Context, companyID, lastEventID uuid.UUID, eventTypes []string, limit int) ([]*models.Event, error) {
+	args := m.Called(ctx, companyID, lastEventID, eventTypes, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Event), args.Error(1)
+}
+
+// fakeStreamService hands out a fixed list of events.
+type fakeStreamService struct {
+	events      []*models.Event
+	lastEventID *uuid.UUID
+}
+
+func (f *fakeStreamService) HandleEvent(ctx context.Context, event *models.Event) error {
+	return nil
+}
+
+func (f *fakeStreamService) Subscribe(ctx context.Context, companyID uuid.UUID, lastEventID *uuid.UUID) (<-chan *models.Event, error) {
+	f.lastEventID = lastEventID
+	events := make(chan *models.Event, len(f.events))
+	for _, event := range f.events {
+		events <- event
+	}
+	close(events)
+	return events, nil
+}
+
+func (f *fakeStreamService) HeartbeatInterval() time.Duration {
+	return time.Hour
+}
+
+func streamEvent(companyID uuid.UUID, eventType string) *models.Event {
+	return &models.Event{
+		ID:        uuid.New(),
+		CompanyID: companyID,
+		Type:      eventType,
+		Data:      json.RawMessage(`{"card_id":"` + uuid.NewString() + `"}`),
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+func TestStreamService(t *testing.T) {
+	helper := setup.NewTestHelper(t)
+	companyID := uuid.New()
+
+	mockRepo := new(MockStreamRepository)
+	svc := stream.NewService(mockRepo, helper.Redis, config.StreamConfig{HeartbeatInterval: time.Second, ReplayLimit: 50})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lastEventID := uuid.New()
+	missed := []*models.Event{
+		streamEvent(companyID, models.EventPaymentCompleted),
+		streamEvent(companyID, models.EventPaymentDeclined),
+	}
+	mockRepo.On("GetEventsSince", ctx, companyID, lastEventID, stream.EventTypes, 50).Return(missed, nil)
+
+	events, err := svc.Subscribe(ctx, companyID, &lastEventID)
+	require.NoError(t, err)
+
+	live := streamEvent(companyID, models.EventCardBlocked)
+	// A missed event published again, an event that is not streamed and
+	// another company's event are not sent.
+	require.NoError(t, svc.HandleEvent(ctx, missed[1]))
+	require.NoError(t, svc.HandleEvent(ctx, streamEvent(companyID, models.EventCardIssued)))
+	require.NoError(t, svc.HandleEvent(ctx, streamEvent(uuid.New(), models.EventCardBlocked)))
+	require.NoError(t, svc.HandleEvent(ctx, live))
+
+	var received []uuid.UUID
+	for len(received) < 3 {
+		select {
+		case event := <-events:
+			received = append(received, event.ID)
+		case <-ctx.Done():
+			t.Fatalf("received %d of 3 events", len(received))
+		}
+	}
+	assert.Equal(t, []uuid.UUID{missed[0].ID, missed[1].ID, live.ID}, received)
+
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %s", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	for range events {
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestStreamHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	companyID := uuid.New()
+
+	newRequest := func(lastEventID string) (*httptest.ResponseRecorder, *gin.Context) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api/events/stream", nil)
+		if lastEventID != "" {
+			c.Request.Header.Set("Last-Event-ID", lastEventID)
+		}
+		c.Set("company_id", companyID)
+		return w, c
+	}
+
+	t.Run("writes_server_sent_events", func(t *testing.T) {
+		declined := streamEvent(companyID, models.EventPaymentDeclined)
+		blocked := streamEvent(companyID, models.EventCardBlocked)
+		svc := &fakeStreamService{events: []*models.Event{declined, blocked}}
+		handler := stream.NewHandler(svc)
+
+		lastEventID := uuid.New()
+		w, c := newRequest(lastEventID.String())
+		handler.Stream(c)
+
+		require.NotNil(t, svc.lastEventID)
+		assert.Equal(t, lastEventID, *svc.lastEventID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+		messages := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+		require.Len(t, messages, 2)
+		for i, event := range []*models.Event{declined, blocked} {
+			lines := strings.Split(messages[i], "\n")
+			require.Len(t, lines, 3)
+			assert.Equal(t, "id: "+event.ID.String(), lines[0])
+			assert.Equal(t, "event: "+event.Type, lines[1])
+
+			var data models.Event
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &data))
+			assert.Equal(t, event.ID, data.ID)
+			assert.JSONEq(t, string(event.Data), string(data.Data))
+		}
+	})
+
+	t.Run("invalid_last_event_id", func(t *testing.T) {
+		svc := &fakeStreamService{}
+		handler := stream.NewHandler(svc)
+
+		w, c := newRequest("not-a-uuid")
+		handler.Stream(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
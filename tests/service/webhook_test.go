@@ -0,0 +1,452 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"ccards/internal/api/request"
+	"ccards/internal/webhook"
+	"ccards/pkg/config"
+	"ccards/pkg/errors"
+	"ccards/pkg/models"
+	"ccards/pkg/vault"
+)
+
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	args := m.Called(ctx, endpoint)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) GetEndpoints(ctx context.Context, companyID uuid.UUID) ([]*models.WebhookEndpoint, error) {
+	args := m.Called(ctx, companyID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (*models.WebhookEndpoint, error) {
+	args := m.Called(ctx, companyID, endpointID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookRepository) UpdateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) (*models.WebhookEndpoint, error) {
+	args := m.Called(ctx, endpoint)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookRepository) UpdateEndpointSecret(ctx context.Context, companyID, endpointID uuid.UUID, secret string) (*models.WebhookEndpoint, error) {
+	args := m.Called(ctx, companyID, endpointID, secret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookEndpoint), args.Error(1)
+}
+
+func (m *MockWebhookRepository) DeleteEndpoint(ctx context.Context, companyID, endpointID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, companyID, endpointID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockWebhookRepository) EncryptEndpointSecrets(ctx context.Context, seal func(secret string) (string, error)) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetDeliveries(ctx context.Context, companyID, endpointID uuid.UUID, status string, limit, offset int) ([]*models.WebhookDelivery, error) {
+	args := m.Called(ctx, companyID, endpointID, status, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ReplayDelivery(ctx context.Context, companyID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	args := m.Called(ctx, companyID, endpointID, deliveryID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ReplayDeadDeliveries(ctx context.Context, companyID, endpointID uuid.UUID) (int, error) {
+	args := m.Called(ctx, companyID, endpointID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ReplayEvents(ctx context.Context, companyID, endpointID uuid.UUID, since time.Time) (int, error) {
+	args := m.Called(ctx, companyID, endpointID, since)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhookRepository) CreateDeliveries(ctx context.Context, event *models.Event) (int, error) {
+	args := m.Called(ctx, event)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*webhook.Job, error) {
+	args := m.Called(ctx, limit, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*webhook.Job), args.Error(1)
+}
+
+func (m *MockWebhookRepository) MarkDelivered(ctx context.Context, deliveryID uuid.UUID, statusCode int) error {
+	args := m.Called(ctx, deliveryID, statusCode)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) MarkFailed(ctx context.Context, deliveryID uuid.UUID, statusCode *int, reason string, nextAttemptAt *time.Time) error {
+	args := m.Called(ctx, deliveryID, statusCode, reason, nextAttemptAt)
+	return args.Error(0)
+}
+
+// testWebhookConfig allows private targets so deliveries can go to local
+// test servers.
+var testWebhookConfig = config.WebhookConfig{
+	Timeout:             time.Second,
+	MaxAttempts:         3,
+	RetryBackoff:        30 * time.Second,
+	MaxBackoff:          time.Hour,
+	AllowPrivateTargets: true,
+}
+
+// webhookJob returns a delivery to url signed with the secret "whsec_test",
+// sealed as the repository returns it.
+func webhookJob(t *testing.T, url string, attempts int) *webhook.Job {
+	secret, err := newTestVault(t).SealSecret("whsec_test", "webhook-secret")
+	require.NoError(t, err)
+
+	delivery := &models.WebhookDelivery{
+		ID:        uuid.New(),
+		EventID:   uuid.New(),
+		EventType: models.EventPaymentCompleted,
+		Status:    models.WebhookDeliveryStatusPending,
+		Attempts:  attempts,
+	}
+	return &webhook.Job{
+		Delivery: delivery,
+		URL:      url,
+		Secret:   secret,
+		Event: &models.Event{
+			ID:        delivery.EventID,
+			CompanyID: uuid.New(),
+			Type:      delivery.EventType,
+			Data:      json.RawMessage(`{"amount":42.5}`),
+			CreatedAt: time.Now(),
+		},
+	}
+}
+
+func TestWebhookSign(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" keyed with "secret".
+	signature := webhook.Sign("secret", 1700000000, []byte("{}"))
+	assert.Equal(t, "v1=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", signature)
+	assert.NotEqual(t, signature, webhook.Sign("other", 1700000000, []byte("{}")))
+	assert.NotEqual(t, signature, webhook.Sign("secret", 1700000001, []byte("{}")))
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+
+	t.Run("create_returns_secret", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		var stored string
+		mockRepo.On("CreateEndpoint", ctx, mock.MatchedBy(func(endpoint *models.WebhookEndpoint) bool {
+			stored = endpoint.Secret
+			return true
+		})).Return(nil)
+
+		endpoint, err := svc.CreateEndpoint(ctx, companyID, &request.WebhookEndpoint{
+			URL:        "https://erp.example.com/hooks",
+			EventTypes: []string{models.EventPaymentCompleted},
+		})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(endpoint.Secret, "whsec_"))
+		assert.True(t, endpoint.IsActive)
+		assert.Equal(t, companyID, endpoint.CompanyID)
+
+		// Only the sealed secret is stored.
+		require.True(t, vault.IsSealed(stored))
+		secret, err := newTestVault(t).OpenSecret(stored, "webhook-secret")
+		require.NoError(t, err)
+		assert.Equal(t, endpoint.Secret, secret)
+	})
+
+	t.Run("rotate_stores_sealed_secret", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		endpointID := uuid.New()
+		var stored string
+		mockRepo.On("UpdateEndpointSecret", ctx, companyID, endpointID, mock.MatchedBy(func(secret string) bool {
+			stored = secret
+			return vault.IsSealed(secret)
+		})).Return(&models.WebhookEndpoint{ID: endpointID, CompanyID: companyID}, nil)
+
+		endpoint, err := svc.RotateSecret(ctx, companyID, endpointID)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(endpoint.Secret, "whsec_"))
+
+		secret, err := newTestVault(t).OpenSecret(stored, "webhook-secret")
+		require.NoError(t, err)
+		assert.Equal(t, endpoint.Secret, secret)
+	})
+
+	t.Run("list_hides_secret", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		mockRepo.On("GetEndpoints", ctx, companyID).Return([]*models.WebhookEndpoint{
+			{ID: uuid.New(), CompanyID: companyID, Secret: "whsec_hidden"},
+		}, nil)
+
+		endpoints, err := svc.ListEndpoints(ctx, companyID)
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		assert.Empty(t, endpoints[0].Secret)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		endpointID := uuid.New()
+		mockRepo.On("GetEndpoint", ctx, companyID, endpointID).Return(nil, nil)
+		mockRepo.On("DeleteEndpoint", ctx, companyID, endpointID).Return(false, nil)
+
+		_, err := svc.GetEndpoint(ctx, companyID, endpointID)
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+
+		err = svc.DeleteEndpoint(ctx, companyID, endpointID)
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+
+		_, err = svc.ReplayEvents(ctx, companyID, endpointID, nil)
+		assert.True(t, errors.Is(err, errors.ErrNotFound))
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		endpointID := uuid.New()
+		since := time.Now().Add(-time.Hour)
+		mockRepo.On("GetEndpoint", ctx, companyID, endpointID).Return(&models.WebhookEndpoint{ID: endpointID, CompanyID: companyID}, nil)
+		mockRepo.On("ReplayDeadDeliveries", ctx, companyID, endpointID).Return(2, nil)
+		mockRepo.On("ReplayEvents", ctx, companyID, endpointID, since).Return(5, nil)
+
+		replayed, err := svc.ReplayEvents(ctx, companyID, endpointID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, replayed)
+
+		replayed, err = svc.ReplayEvents(ctx, companyID, endpointID, &since)
+		require.NoError(t, err)
+		assert.Equal(t, 5, replayed)
+	})
+}
+
+func TestWebhookEndpointTargets(t *testing.T) {
+	ctx := context.Background()
+	companyID := uuid.New()
+	strictConfig := testWebhookConfig
+	strictConfig.AllowPrivateTargets = false
+
+	t.Run("rejects_internal_targets", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), strictConfig)
+
+		for _, url := range []string{
+			"http://93.184.216.34/hooks",
+			"https://127.0.0.1/hooks",
+			"https://10.0.0.8/hooks",
+			"https://192.168.1.20:8443/hooks",
+			"https://169.254.169.254/latest/meta-data",
+			"https://[::1]/hooks",
+			"https://[fd00::1]/hooks",
+			"https://[::ffff:127.0.0.1]/hooks",
+			"https://100.64.0.1/hooks",
+			"https://localhost/hooks",
+			"https://metadata.google.internal/computeMetadata",
+			"https://printer.local/hooks",
+			"https://intranet/hooks",
+		} {
+			_, err := svc.CreateEndpoint(ctx, companyID, &request.WebhookEndpoint{URL: url})
+			assert.ErrorIs(t, err, errors.ErrWebhookURL, url)
+
+			_, err = svc.UpdateEndpoint(ctx, companyID, uuid.New(), &request.WebhookEndpoint{URL: url})
+			assert.ErrorIs(t, err, errors.ErrWebhookURL, url)
+		}
+		mockRepo.AssertNotCalled(t, "CreateEndpoint", mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "UpdateEndpoint", mock.Anything, mock.Anything)
+	})
+
+	t.Run("accepts_public_address", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), strictConfig)
+
+		mockRepo.On("CreateEndpoint", ctx, mock.AnythingOfType("*models.WebhookEndpoint")).Return(nil)
+
+		_, err := svc.CreateEndpoint(ctx, companyID, &request.WebhookEndpoint{URL: "https://93.184.216.34/hooks"})
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("delivery_to_internal_address_is_refused", func(t *testing.T) {
+		var called bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer server.Close()
+
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), strictConfig)
+
+		// The endpoint was registered with plain http before the check, and
+		// its https form still points at a loopback address.
+		plain := webhookJob(t, server.URL, 0)
+		loopback := webhookJob(t, strings.Replace(server.URL, "http://", "https://", 1), 0)
+		mockRepo.On("ClaimDeliveries", ctx, 100, mock.AnythingOfType("time.Duration")).Return([]*webhook.Job{plain, loopback}, nil)
+		refused := mock.MatchedBy(func(reason string) bool {
+			return strings.Contains(reason, errors.ErrWebhookURL.Error())
+		})
+		mockRepo.On("MarkFailed", ctx, plain.Delivery.ID, (*int)(nil), refused, mock.Anything).Return(nil)
+		mockRepo.On("MarkFailed", ctx, loopback.Delivery.ID, (*int)(nil), refused, mock.Anything).Return(nil)
+
+		delivered, err := svc.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		assert.False(t, called)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signed_delivery", func(t *testing.T) {
+		var received *http.Request
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		job := webhookJob(t, server.URL, 0)
+		mockRepo.On("ClaimDeliveries", ctx, 100, mock.AnythingOfType("time.Duration")).Return([]*webhook.Job{job}, nil)
+		mockRepo.On("MarkDelivered", ctx, job.Delivery.ID, http.StatusNoContent).Return(nil)
+
+		delivered, err := svc.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		mockRepo.AssertExpectations(t)
+
+		require.NotNil(t, received)
+		assert.Equal(t, models.EventPaymentCompleted, received.Header.Get(webhook.EventHeader))
+		assert.Equal(t, job.Delivery.ID.String(), received.Header.Get(webhook.DeliveryHeader))
+
+		timestamp, err := strconv.ParseInt(received.Header.Get(webhook.TimestampHeader), 10, 64)
+		require.NoError(t, err)
+		assert.Equal(t, webhook.Sign("whsec_test", timestamp, body), received.Header.Get(webhook.SignatureHeader))
+
+		var event models.Event
+		require.NoError(t, json.Unmarshal(body, &event))
+		assert.Equal(t, job.Event.ID, event.ID)
+		assert.JSONEq(t, `{"amount":42.5}`, string(event.Data))
+	})
+
+	t.Run("failure_is_retried_with_backoff", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		first := webhookJob(t, server.URL, 0)
+		second := webhookJob(t, server.URL, 1)
+		mockRepo.On("ClaimDeliveries", ctx, 100, mock.AnythingOfType("time.Duration")).Return([]*webhook.Job{first, second}, nil)
+
+		statusCode := http.StatusInternalServerError
+		retryAfter := func(wait time.Duration) interface{} {
+			return mock.MatchedBy(func(next *time.Time) bool {
+				if next == nil {
+					return false
+				}
+				delay := time.Until(*next)
+				return delay > wait-5*time.Second && delay <= wait
+			})
+		}
+		mockRepo.On("MarkFailed", ctx, first.Delivery.ID, &statusCode, "endpoint responded with status 500", retryAfter(30*time.Second)).Return(nil)
+		mockRepo.On("MarkFailed", ctx, second.Delivery.ID, &statusCode, "endpoint responded with status 500", retryAfter(time.Minute)).Return(nil)
+
+		delivered, err := svc.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("last_attempt_becomes_dead_letter", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		// Nothing listens on this address.
+		server := httptest.NewServer(http.NotFoundHandler())
+		url := server.URL
+		server.Close()
+
+		job := webhookJob(t, url, testWebhookConfig.MaxAttempts-1)
+		mockRepo.On("ClaimDeliveries", ctx, 100, mock.AnythingOfType("time.Duration")).Return([]*webhook.Job{job}, nil)
+		mockRepo.On("MarkFailed", ctx, job.Delivery.ID, (*int)(nil), mock.AnythingOfType("string"), (*time.Time)(nil)).Return(nil)
+
+		delivered, err := svc.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("outbox_event_creates_deliveries", func(t *testing.T) {
+		mockRepo := new(MockWebhookRepository)
+		svc := webhook.NewService(mockRepo, newTestVault(t), testWebhookConfig)
+
+		event := webhookJob(t, "https://erp.example.com/hooks", 0).Event
+		mockRepo.On("CreateDeliveries", ctx, event).Return(2, nil).Once()
+		require.NoError(t, svc.HandleEvent(ctx, event))
+
+		// A failure is returned so the outbox retries the event.
+		mockRepo.On("CreateDeliveries", ctx, event).Return(0, assert.AnError).Once()
+		assert.ErrorIs(t, svc.HandleEvent(ctx, event), assert.AnError)
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -216,7 +216,7 @@ func (h *TestHelper) CleanDatabase() error {
 		SELECT tablename 
 		FROM pg_tables 
 		WHERE schemaname = 'public' 
-		AND tablename NOT IN ('goose_db_version', 'schema_migrations')
+		AND tablename NOT IN ('goose_db_version', 'schema_migrations', 'merchant_category_codes')
 		ORDER BY tablename
 	`)
 	if err != nil {